@@ -1,84 +1,130 @@
-package main
-
-import (
-	"context"
-	"log"
-	"net/http"
-	"os"
-	"os/signal"
-	"syscall"
-	"time"
-
-	"control-financiero/internal/config"
-	"control-financiero/internal/routes"
-
-	"github.com/gin-gonic/gin"
-)
-
-func main() {
-	// Cargar configuración
-	cfg := config.Load()
-
-	// Conectar a MongoDB
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
-
-	mongoClient, err := config.ConnectMongoDB(ctx, cfg)
-	if err != nil {
-		log.Fatal("❌ Error conectando a MongoDB:", err)
-	}
-	defer func() {
-		if err := mongoClient.Disconnect(context.Background()); err != nil {
-			log.Println("Error desconectando MongoDB:", err)
-		}
-	}()
-
-	log.Println("✅ Conectado a MongoDB")
-
-	// Inicializar colecciones y datos por defecto
-	if err := config.InitializeCollections(mongoClient, cfg); err != nil {
-		log.Fatal("❌ Error inicializando colecciones:", err)
-	}
-
-	// Configurar Gin
-	if cfg.Env == "production" {
-		gin.SetMode(gin.ReleaseMode)
-	}
-
-	router := gin.Default()
-
-	// Configurar rutas
-	routes.Setup(router, mongoClient, cfg)
-
-	// Servidor con graceful shutdown
-	srv := &http.Server{
-		Addr:    ":" + cfg.Port,
-		Handler: router,
-	}
-
-	// Canal para señales del sistema
-	quit := make(chan os.Signal, 1)
-	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
-
-	go func() {
-		log.Printf("🚀 Servidor iniciando en el puerto %s\n", cfg.Port)
-		log.Printf("🌐 API disponible en: http://localhost:%s/api/v1\n", cfg.Port)
-		log.Printf("🖥️  Aplicación web en: http://localhost:%s\n", cfg.Port)
-		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			log.Fatalf("Error al iniciar servidor: %v\n", err)
-		}
-	}()
-
-	// Esperar señal de terminación
-	<-quit
-	log.Println("🛑 Apagando servidor...")
-
-	ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-
-	if err := srv.Shutdown(ctx); err != nil {
-		log.Fatal("❌ Error en shutdown:", err)
-	}
-
-	log.Println("✅ Servidor detenido correctamente")
-}
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"control-financiero/internal/config"
+	"control-financiero/internal/models"
+	"control-financiero/internal/routes"
+	"control-financiero/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func main() {
+	// Cargar configuración
+	cfg := config.Load()
+	if err := cfg.Validate(); err != nil {
+		log.Fatal("❌ Configuración inválida: ", err)
+	}
+
+	// Claves de firma de los access tokens
+	if err := cfg.LoadJWTKeys(); err != nil {
+		log.Fatal("❌ Error cargando las claves JWT: ", err)
+	}
+	log.Printf("🔑 Firmando tokens con la clave %s\n", cfg.JWTKeys.SigningKeyID())
+
+	// Conectar a MongoDB
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	mongoClient, err := config.ConnectMongoDB(ctx, cfg)
+	if err != nil {
+		log.Fatal("❌ Error conectando a MongoDB:", err)
+	}
+	defer func() {
+		if err := mongoClient.Disconnect(context.Background()); err != nil {
+			log.Println("Error desconectando MongoDB:", err)
+		}
+	}()
+
+	log.Println("✅ Conectado a MongoDB")
+
+	// Inicializar colecciones y datos por defecto
+	if err := config.InitializeCollections(mongoClient, cfg); err != nil {
+		log.Fatal("❌ Error inicializando colecciones:", err)
+	}
+
+	// Tipos de cambio iniciales
+	if cfg.ExchangeRatesFile != "" {
+		if err := loadExchangeRates(mongoClient.Database(cfg.MongoDB), cfg.ExchangeRatesFile); err != nil {
+			log.Fatal("❌ Error cargando los tipos de cambio: ", err)
+		}
+	}
+
+	// Programador de transacciones recurrentes
+	schedulerCtx, stopScheduler := context.WithCancel(context.Background())
+	defer stopScheduler()
+	if cfg.RecurringInterval > 0 {
+		go services.NewRecurrenciaService(mongoClient.Database(cfg.MongoDB)).Run(schedulerCtx, cfg.RecurringInterval)
+		log.Printf("🔁 Transacciones recurrentes cada %s\n", cfg.RecurringInterval)
+	}
+
+	// Configurar Gin
+	if cfg.Env == "production" {
+		gin.SetMode(gin.ReleaseMode)
+	}
+
+	router := gin.Default()
+
+	// Configurar rutas
+	routes.Setup(router, mongoClient, cfg)
+
+	// Servidor con graceful shutdown
+	srv := &http.Server{
+		Addr:    ":" + cfg.Port,
+		Handler: router,
+	}
+
+	// Canal para señales del sistema
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+
+	go func() {
+		log.Printf("🚀 Servidor iniciando en el puerto %s\n", cfg.Port)
+		log.Printf("🌐 API disponible en: http://localhost:%s/api/v1\n", cfg.Port)
+		log.Printf("🖥️  Aplicación web en: http://localhost:%s\n", cfg.Port)
+		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Fatalf("Error al iniciar servidor: %v\n", err)
+		}
+	}()
+
+	// Esperar señal de terminación
+	<-quit
+	log.Println("🛑 Apagando servidor...")
+	stopScheduler()
+
+	ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := srv.Shutdown(ctx); err != nil {
+		log.Fatal("❌ Error en shutdown:", err)
+	}
+
+	log.Println("✅ Servidor detenido correctamente")
+}
+
+// loadExchangeRates importa el CSV de tipos de cambio indicado en
+// EXCHANGE_RATES_FILE. Los que ya existen se reemplazan, así que puede
+// cargarse en cada arranque.
+func loadExchangeRates(db *mongo.Database, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	cantidad, err := services.NewExchangeRateService(db).ImportCSV(context.Background(), f, models.ClientInfo{})
+	if err != nil {
+		return err
+	}
+	log.Printf("💱 %d tipos de cambio cargados desde %s\n", cantidad, path)
+	return nil
+}
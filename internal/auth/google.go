@@ -1,69 +1,18 @@
-package auth
-
-import (
-	"context"
-	"encoding/json"
-	"errors"
-	"io"
-	"net/http"
-	"os"
-
-	"golang.org/x/oauth2"
-	"golang.org/x/oauth2/google"
-)
-
-type GoogleUserInfo struct {
-	ID      string `json:"id"`
-	Email   string `json:"email"`
-	Name    string `json:"name"`
-	Picture string `json:"picture"`
-	Verified bool  `json:"verified_email"`
-}
-
-var googleOAuthConfig *oauth2.Config
-
-func InitGoogleOAuth() {
-	googleOAuthConfig = &oauth2.Config{
-		ClientID:     os.Getenv("GOOGLE_CLIENT_ID"),
-		ClientSecret: os.Getenv("GOOGLE_CLIENT_SECRET"),
-		RedirectURL:  os.Getenv("GOOGLE_REDIRECT_URL"),
-		Scopes: []string{
-			"https://www.googleapis.com/auth/userinfo.email",
-			"https://www.googleapis.com/auth/userinfo.profile",
-		},
-		Endpoint: google.Endpoint,
-	}
-}
-
-func GetGoogleAuthURL(state string) string {
-	return googleOAuthConfig.AuthCodeURL(state, oauth2.AccessTypeOffline)
-}
-
-func ExchangeGoogleCode(code string) (*oauth2.Token, error) {
-	return googleOAuthConfig.Exchange(context.Background(), code)
-}
-
-func GetGoogleUserInfo(token *oauth2.Token) (*GoogleUserInfo, error) {
-	client := googleOAuthConfig.Client(context.Background(), token)
-	resp, err := client.Get("https://www.googleapis.com/oauth2/v2/userinfo")
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return nil, errors.New("error obteniendo información del usuario")
-	}
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, err
-	}
-
-	var userInfo GoogleUserInfo
-	if err := json.Unmarshal(body, &userInfo); err != nil {
-		return nil, err
-	}
-
-	return &userInfo, nil
-}
+package auth
+
+// GoogleIssuer es el issuer OIDC de Google.
+const GoogleIssuer = "https://accounts.google.com"
+
+// GoogleProvider devuelve la configuración OIDC de Google a partir de las
+// credenciales del cliente OAuth.
+func GoogleProvider(clientID, clientSecret, redirectURL string) ProviderConfig {
+	return ProviderConfig{
+		Name:         "google",
+		DisplayName:  "Google",
+		Issuer:       GoogleIssuer,
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		RedirectURL:  redirectURL,
+		Scopes:       []string{"openid", "email", "profile"},
+	}
+}
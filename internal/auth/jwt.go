@@ -1,79 +1,109 @@
-package auth
-
-import (
-	"crypto/rand"
-	"encoding/base64"
-	"errors"
-	"time"
-
-	"control-financiero/internal/models"
-
-	"github.com/golang-jwt/jwt/v5"
-	"go.mongodb.org/mongo-driver/bson/primitive"
-	"golang.org/x/crypto/bcrypt"
-)
-
-type Claims struct {
-	UserID primitive.ObjectID `json:"userId"`
-	Email  string             `json:"email"`
-	Rol    string             `json:"rol"`
-	jwt.RegisteredClaims
-}
-
-func HashPassword(password string) (string, error) {
-	bytes, err := bcrypt.GenerateFromPassword([]byte(password), 14)
-	return string(bytes), err
-}
-
-func CheckPassword(password, hash string) bool {
-	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
-	return err == nil
-}
-
-func GenerateJWT(usuario *models.Usuario, secret string, expiration string) (string, error) {
-	duration, err := time.ParseDuration(expiration)
-	if err != nil {
-		return "", err
-	}
-
-	claims := Claims{
-		UserID: usuario.ID,
-		Email:  usuario.Email,
-		Rol:    usuario.Rol,
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(duration)),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-			NotBefore: jwt.NewNumericDate(time.Now()),
-		},
-	}
-
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(secret))
-}
-
-func ValidateJWT(tokenString, secret string) (*Claims, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, errors.New("método de firma inválido")
-		}
-		return []byte(secret), nil
-	})
-
-	if err != nil {
-		return nil, err
-	}
-
-	if claims, ok := token.Claims.(*Claims); ok && token.Valid {
-		return claims, nil
-	}
-
-	return nil, errors.New("token inválido")
-}
-
-func GenerateRefreshToken() (string, error) {
-	b := make([]byte, 32)
-	if _, err := rand.Read(b); err != nil {
-		return "", err
-	}
-	return base64.URLEncoding.EncodeToString(b), nil
-}
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"control-financiero/internal/models"
+
+	"github.com/golang-jwt/jwt/v5"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"golang.org/x/crypto/bcrypt"
+)
+
+type Claims struct {
+	UserID primitive.ObjectID `json:"userId"`
+	Email  string             `json:"email"`
+	Rol    string             `json:"rol"`
+	jwt.RegisteredClaims
+}
+
+func HashPassword(password string) (string, error) {
+	bytes, err := bcrypt.GenerateFromPassword([]byte(password), 14)
+	return string(bytes), err
+}
+
+func CheckPassword(password, hash string) bool {
+	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+	return err == nil
+}
+
+// GenerateJWT firma el access token con la clave activa del KeySet e incluye
+// su kid en la cabecera.
+func GenerateJWT(usuario *models.Usuario, keys *KeySet, expiration string) (string, error) {
+	duration, err := time.ParseDuration(expiration)
+	if err != nil {
+		return "", err
+	}
+
+	claims := Claims{
+		UserID: usuario.ID,
+		Email:  usuario.Email,
+		Rol:    usuario.Rol,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(duration)),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			NotBefore: jwt.NewNumericDate(time.Now()),
+		},
+	}
+
+	return keys.sign(claims)
+}
+
+// ValidateJWT verifica el token con la clave que indica su kid. Solo se
+// aceptan firmas asimétricas, así que un token HS256 no es válido.
+func ValidateJWT(tokenString string, keys *KeySet) (*Claims, error) {
+	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, keys.keyfunc,
+		jwt.WithValidMethods([]string{AlgRS256, AlgEdDSA}))
+
+	if err != nil {
+		return nil, err
+	}
+
+	if claims, ok := token.Claims.(*Claims); ok && token.Valid {
+		return claims, nil
+	}
+
+	return nil, errors.New("token inválido")
+}
+
+func GenerateRefreshToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.URLEncoding.EncodeToString(b), nil
+}
+
+// HashRefreshToken devuelve el hash con el que se persiste un refresh token.
+// El token tiene 256 bits aleatorios, por lo que basta un SHA-256 sin sal.
+func HashRefreshToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// PersonalAccessTokenPrefix identifica los tokens de acceso personal. Permite
+// distinguirlos de un JWT sin consultar la base de datos y que los escáneres
+// de secretos los reconozcan si se filtran.
+const PersonalAccessTokenPrefix = "cfp_"
+
+// GeneratePersonalAccessToken genera un token de acceso personal con 256 bits
+// aleatorios. Se persiste con HashRefreshToken.
+func GeneratePersonalAccessToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return PersonalAccessTokenPrefix + base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// IsPersonalAccessToken indica si el bearer token es un token de acceso
+// personal en lugar de un JWT.
+func IsPersonalAccessToken(token string) bool {
+	return strings.HasPrefix(token, PersonalAccessTokenPrefix)
+}
@@ -1,95 +1,150 @@
-package auth
-
-import (
-	"testing"
-	"time"
-
-	"github.com/golang-jwt/jwt/v5"
-	"github.com/stretchr/testify/assert"
-)
-
-func TestHashPassword(t *testing.T) {
-	password := "testPassword123"
-	
-	hash, err := HashPassword(password)
-	assert.NoError(t, err)
-	assert.NotEmpty(t, hash)
-	assert.NotEqual(t, password, hash)
-}
-
-func TestCheckPassword(t *testing.T) {
-	password := "testPassword123"
-	
-	hash, err := HashPassword(password)
-	assert.NoError(t, err)
-	
-	// Password correcto
-	err = CheckPassword(hash, password)
-	assert.NoError(t, err)
-	
-	// Password incorrecto
-	err = CheckPassword(hash, "wrongPassword")
-	assert.Error(t, err)
-}
-
-func TestGenerateJWT(t *testing.T) {
-	userID := "user123"
-	email := "test@example.com"
-	rol := "user"
-	secret := "test-secret-key"
-	
-	token, err := GenerateJWT(userID, email, rol, secret, 15*time.Minute)
-	assert.NoError(t, err)
-	assert.NotEmpty(t, token)
-}
-
-func TestValidateJWT(t *testing.T) {
-	userID := "user123"
-	email := "test@example.com"
-	rol := "user"
-	secret := "test-secret-key"
-	
-	// Generar token válido
-	token, err := GenerateJWT(userID, email, rol, secret, 15*time.Minute)
-	assert.NoError(t, err)
-	
-	// Validar token
-	claims, err := ValidateJWT(token, secret)
-	assert.NoError(t, err)
-	assert.NotNil(t, claims)
-	assert.Equal(t, userID, claims.UserID)
-	assert.Equal(t, email, claims.Email)
-	assert.Equal(t, rol, claims.Rol)
-}
-
-func TestValidateJWT_Expired(t *testing.T) {
-	userID := "user123"
-	email := "test@example.com"
-	rol := "user"
-	secret := "test-secret-key"
-	
-	// Generar token expirado
-	token, err := GenerateJWT(userID, email, rol, secret, -1*time.Hour)
-	assert.NoError(t, err)
-	
-	// Validar token expirado
-	_, err = ValidateJWT(token, secret)
-	assert.Error(t, err)
-	assert.ErrorIs(t, err, jwt.ErrTokenExpired)
-}
-
-func TestValidateJWT_InvalidSecret(t *testing.T) {
-	userID := "user123"
-	email := "test@example.com"
-	rol := "user"
-	secret := "test-secret-key"
-	wrongSecret := "wrong-secret-key"
-	
-	// Generar token
-	token, err := GenerateJWT(userID, email, rol, secret, 15*time.Minute)
-	assert.NoError(t, err)
-	
-	// Validar con secreto incorrecto
-	_, err = ValidateJWT(token, wrongSecret)
-	assert.Error(t, err)
-}
+package auth
+
+import (
+	"testing"
+
+	"control-financiero/internal/models"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func testUsuario() *models.Usuario {
+	return &models.Usuario{
+		ID:    primitive.NewObjectID(),
+		Email: "test@example.com",
+		Rol:   "user",
+	}
+}
+
+func TestHashPassword(t *testing.T) {
+	password := "testPassword123"
+
+	hash, err := HashPassword(password)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, hash)
+	assert.NotEqual(t, password, hash)
+}
+
+func TestCheckPassword(t *testing.T) {
+	password := "testPassword123"
+
+	hash, err := HashPassword(password)
+	assert.NoError(t, err)
+
+	// Password correcto
+	assert.True(t, CheckPassword(password, hash))
+
+	// Password incorrecto
+	assert.False(t, CheckPassword("wrongPassword", hash))
+}
+
+func testKeySet(t *testing.T) *KeySet {
+	key, err := GenerateSigningKey(AlgEdDSA)
+	require.NoError(t, err)
+	return NewKeySet(key)
+}
+
+func TestGenerateJWT(t *testing.T) {
+	keys := testKeySet(t)
+
+	token, err := GenerateJWT(testUsuario(), keys, "15m")
+	assert.NoError(t, err)
+	assert.NotEmpty(t, token)
+
+	// La cabecera identifica la clave con la que se firmó
+	parsed, _, err := jwt.NewParser().ParseUnverified(token, &Claims{})
+	require.NoError(t, err)
+	assert.Equal(t, keys.SigningKeyID(), parsed.Header["kid"])
+	assert.Equal(t, AlgEdDSA, parsed.Method.Alg())
+}
+
+func TestGenerateJWT_InvalidExpiration(t *testing.T) {
+	_, err := GenerateJWT(testUsuario(), testKeySet(t), "quince")
+	assert.Error(t, err)
+}
+
+func TestValidateJWT(t *testing.T) {
+	usuario := testUsuario()
+	keys := testKeySet(t)
+
+	// Generar token válido
+	token, err := GenerateJWT(usuario, keys, "15m")
+	assert.NoError(t, err)
+
+	// Validar token
+	claims, err := ValidateJWT(token, keys)
+	assert.NoError(t, err)
+	assert.NotNil(t, claims)
+	assert.Equal(t, usuario.ID, claims.UserID)
+	assert.Equal(t, usuario.Email, claims.Email)
+	assert.Equal(t, usuario.Rol, claims.Rol)
+}
+
+func TestValidateJWT_Expired(t *testing.T) {
+	keys := testKeySet(t)
+
+	// Generar token expirado
+	token, err := GenerateJWT(testUsuario(), keys, "-1h")
+	assert.NoError(t, err)
+
+	// Validar token expirado
+	_, err = ValidateJWT(token, keys)
+	assert.Error(t, err)
+	assert.ErrorIs(t, err, jwt.ErrTokenExpired)
+}
+
+func TestValidateJWT_InvalidKey(t *testing.T) {
+	// Generar token
+	token, err := GenerateJWT(testUsuario(), testKeySet(t), "15m")
+	assert.NoError(t, err)
+
+	// Validar con otro juego de claves
+	_, err = ValidateJWT(token, testKeySet(t))
+	assert.Error(t, err)
+}
+
+func TestValidateJWT_RejectsHMAC(t *testing.T) {
+	keys := testKeySet(t)
+
+	// Un token HS256 firmado con el kid de la clave activa no debe aceptarse
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, Claims{UserID: primitive.NewObjectID()})
+	token.Header["kid"] = keys.SigningKeyID()
+	signed, err := token.SignedString([]byte("secret"))
+	require.NoError(t, err)
+
+	_, err = ValidateJWT(signed, keys)
+	assert.Error(t, err)
+}
+
+func TestHashRefreshToken(t *testing.T) {
+	token, err := GenerateRefreshToken()
+	assert.NoError(t, err)
+
+	hash := HashRefreshToken(token)
+	assert.Len(t, hash, 64)
+	assert.NotEqual(t, token, hash)
+	assert.Equal(t, hash, HashRefreshToken(token))
+
+	other, err := GenerateRefreshToken()
+	assert.NoError(t, err)
+	assert.NotEqual(t, hash, HashRefreshToken(other))
+}
+
+func TestGeneratePersonalAccessToken(t *testing.T) {
+	token, err := GeneratePersonalAccessToken()
+	assert.NoError(t, err)
+	assert.True(t, IsPersonalAccessToken(token))
+	assert.Len(t, token, len(PersonalAccessTokenPrefix)+43)
+
+	otro, err := GeneratePersonalAccessToken()
+	assert.NoError(t, err)
+	assert.NotEqual(t, token, otro)
+
+	// Un JWT nunca empieza por el prefijo
+	jwtToken, err := GenerateJWT(testUsuario(), testKeySet(t), "1h")
+	assert.NoError(t, err)
+	assert.False(t, IsPersonalAccessToken(jwtToken))
+}
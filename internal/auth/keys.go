@@ -0,0 +1,312 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Algoritmos de firma admitidos para los access tokens.
+const (
+	AlgRS256 = "RS256"
+	AlgEdDSA = "EdDSA"
+)
+
+const (
+	rsaKeyBits = 3072
+	// keysReloadInterval limita cuántas veces se relee el directorio de
+	// claves al recibir un kid desconocido, por ejemplo el de una clave que
+	// otra réplica acaba de generar.
+	keysReloadInterval = time.Minute
+)
+
+// SigningKey es una clave privada identificada por su kid. El kid es el
+// nombre del archivo sin la extensión .pem.
+type SigningKey struct {
+	ID        string
+	Algorithm string
+	CreatedAt time.Time
+	private   crypto.Signer
+}
+
+// GenerateSigningKey genera una clave nueva con un kid que empieza por la
+// fecha, de modo que ordenar los kids alfabéticamente las ordena por
+// antigüedad.
+func GenerateSigningKey(algorithm string) (*SigningKey, error) {
+	var private crypto.Signer
+	var err error
+	switch algorithm {
+	case AlgRS256:
+		private, err = rsa.GenerateKey(rand.Reader, rsaKeyBits)
+	case AlgEdDSA:
+		_, private, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		return nil, fmt.Errorf("algoritmo de firma no soportado: %s", algorithm)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	return &SigningKey{
+		ID:        now.Format("20060102T150405") + "-" + hex.EncodeToString(suffix),
+		Algorithm: algorithm,
+		CreatedAt: now,
+		private:   private,
+	}, nil
+}
+
+// ParseSigningKey lee una clave privada PEM (PKCS#8, o PKCS#1 para RSA). El
+// algoritmo se deduce del tipo de clave.
+func ParseSigningKey(id string, data []byte) (*SigningKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("PEM inválido")
+	}
+
+	var parsed any
+	var err error
+	switch block.Type {
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("tipo de PEM no soportado: %s", block.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	key := &SigningKey{ID: id}
+	switch k := parsed.(type) {
+	case *rsa.PrivateKey:
+		if k.N.BitLen() < 2048 {
+			return nil, errors.New("la clave RSA debe tener al menos 2048 bits")
+		}
+		key.Algorithm, key.private = AlgRS256, k
+	case ed25519.PrivateKey:
+		key.Algorithm, key.private = AlgEdDSA, k
+	default:
+		return nil, fmt.Errorf("tipo de clave no soportado: %T", parsed)
+	}
+	return key, nil
+}
+
+// MarshalPEM codifica la clave privada en PKCS#8.
+func (k *SigningKey) MarshalPEM() ([]byte, error) {
+	der, err := x509.MarshalPKCS8PrivateKey(k.private)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), nil
+}
+
+func (k *SigningKey) method() jwt.SigningMethod {
+	if k.Algorithm == AlgEdDSA {
+		return jwt.SigningMethodEdDSA
+	}
+	return jwt.SigningMethodRS256
+}
+
+func (k *SigningKey) jwk() jwk {
+	switch pub := k.private.Public().(type) {
+	case *rsa.PublicKey:
+		return jwk{
+			Kty: "RSA",
+			Kid: k.ID,
+			Use: "sig",
+			Alg: AlgRS256,
+			N:   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+		}
+	case ed25519.PublicKey:
+		return jwk{
+			Kty: "OKP",
+			Kid: k.ID,
+			Use: "sig",
+			Alg: AlgEdDSA,
+			Crv: "Ed25519",
+			X:   base64.RawURLEncoding.EncodeToString(pub),
+		}
+	}
+	return jwk{Kid: k.ID}
+}
+
+// JSONWebKeySet es el documento que se publica en /.well-known/jwks.json.
+type JSONWebKeySet struct {
+	Keys []jwk `json:"keys"`
+}
+
+// KeySet reúne la clave con la que se firman los tokens nuevos y las claves
+// anteriores que siguen sirviendo para verificar. Rotar consiste en añadir
+// una clave nueva: los tokens firmados con la anterior siguen siendo válidos
+// hasta que expiran, o hasta que se borra su archivo y se reinicia.
+type KeySet struct {
+	dir     string
+	signing *SigningKey
+
+	mu         sync.RWMutex
+	keys       map[string]*SigningKey
+	reloadedAt time.Time
+}
+
+// NewKeySet crea un conjunto en memoria. signing firma los tokens nuevos y
+// others solo se usan para verificar.
+func NewKeySet(signing *SigningKey, others ...*SigningKey) *KeySet {
+	s := &KeySet{signing: signing, keys: map[string]*SigningKey{signing.ID: signing}}
+	for _, k := range others {
+		s.keys[k.ID] = k
+	}
+	return s
+}
+
+// LoadKeySet carga las claves *.pem de dir y firma con la más reciente. Si el
+// directorio está vacío, o si la clave más reciente tiene más de rotation
+// (cero desactiva la rotación), genera una clave nueva con algorithm y la
+// guarda en dir.
+func LoadKeySet(dir, algorithm string, rotation time.Duration) (*KeySet, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+
+	keys, err := readKeys(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var signing *SigningKey
+	if len(keys) > 0 {
+		signing = keys[len(keys)-1]
+	}
+
+	if signing == nil || (rotation > 0 && time.Since(signing.CreatedAt) > rotation) {
+		signing, err = GenerateSigningKey(algorithm)
+		if err != nil {
+			return nil, err
+		}
+		data, err := signing.MarshalPEM()
+		if err != nil {
+			return nil, err
+		}
+		if err := os.WriteFile(filepath.Join(dir, signing.ID+".pem"), data, 0o600); err != nil {
+			return nil, err
+		}
+	}
+
+	s := NewKeySet(signing, keys...)
+	s.dir = dir
+	s.reloadedAt = time.Now()
+	return s, nil
+}
+
+// readKeys lee las claves de dir ordenadas por kid, de la más antigua a la
+// más reciente.
+func readKeys(dir string) ([]*SigningKey, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+
+	keys := make([]*SigningKey, 0, len(files))
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		key, err := ParseSigningKey(strings.TrimSuffix(filepath.Base(file), ".pem"), data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", file, err)
+		}
+		if info, err := os.Stat(file); err == nil {
+			key.CreatedAt = info.ModTime()
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// SigningKeyID devuelve el kid con el que se firman los tokens nuevos.
+func (s *KeySet) SigningKeyID() string {
+	return s.signing.ID
+}
+
+// JWKS devuelve las claves públicas de todas las claves vigentes.
+func (s *KeySet) JWKS() JSONWebKeySet {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	set := JSONWebKeySet{Keys: make([]jwk, 0, len(s.keys))}
+	for _, k := range s.keys {
+		set.Keys = append(set.Keys, k.jwk())
+	}
+	sort.Slice(set.Keys, func(i, j int) bool { return set.Keys[i].Kid > set.Keys[j].Kid })
+	return set
+}
+
+func (s *KeySet) sign(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(s.signing.method(), claims)
+	token.Header["kid"] = s.signing.ID
+	return token.SignedString(s.signing.private)
+}
+
+// keyfunc busca la clave pública por kid y exige que el algoritmo del token
+// sea el de la clave.
+func (s *KeySet) keyfunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	key, ok := s.lookup(kid)
+	if !ok {
+		return nil, fmt.Errorf("clave de firma desconocida: %q", kid)
+	}
+	if token.Method.Alg() != key.Algorithm {
+		return nil, errors.New("método de firma inválido")
+	}
+	return key.private.Public(), nil
+}
+
+func (s *KeySet) lookup(kid string) (*SigningKey, bool) {
+	s.mu.RLock()
+	key, ok := s.keys[kid]
+	canReload := s.dir != "" && time.Since(s.reloadedAt) > keysReloadInterval
+	s.mu.RUnlock()
+	if ok || !canReload || kid == "" {
+		return key, ok
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.reloadedAt = time.Now()
+	keys, err := readKeys(s.dir)
+	if err != nil {
+		return nil, false
+	}
+	for _, k := range keys {
+		if _, exists := s.keys[k.ID]; !exists {
+			s.keys[k.ID] = k
+		}
+	}
+	key, ok = s.keys[kid]
+	return key, ok
+}
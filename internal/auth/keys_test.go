@@ -0,0 +1,118 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadKeySet_GeneraYPersiste(t *testing.T) {
+	dir := t.TempDir()
+
+	keys, err := LoadKeySet(dir, AlgEdDSA, 0)
+	require.NoError(t, err)
+
+	info, err := os.Stat(filepath.Join(dir, keys.SigningKeyID()+".pem"))
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+
+	// Al reiniciar se reutiliza la misma clave
+	reloaded, err := LoadKeySet(dir, AlgEdDSA, 0)
+	require.NoError(t, err)
+	assert.Equal(t, keys.SigningKeyID(), reloaded.SigningKeyID())
+
+	token, err := GenerateJWT(testUsuario(), keys, "15m")
+	require.NoError(t, err)
+	_, err = ValidateJWT(token, reloaded)
+	assert.NoError(t, err)
+}
+
+func TestLoadKeySet_Rotacion(t *testing.T) {
+	dir := t.TempDir()
+
+	old, err := LoadKeySet(dir, AlgEdDSA, time.Hour)
+	require.NoError(t, err)
+	oldToken, err := GenerateJWT(testUsuario(), old, "15m")
+	require.NoError(t, err)
+
+	// Envejecer la clave para que toque rotarla
+	file := filepath.Join(dir, old.SigningKeyID()+".pem")
+	past := time.Now().Add(-2 * time.Hour)
+	require.NoError(t, os.Chtimes(file, past, past))
+
+	rotated, err := LoadKeySet(dir, AlgEdDSA, time.Hour)
+	require.NoError(t, err)
+	assert.NotEqual(t, old.SigningKeyID(), rotated.SigningKeyID())
+	assert.Len(t, rotated.JWKS().Keys, 2)
+
+	// Los tokens firmados con la clave anterior siguen siendo válidos
+	_, err = ValidateJWT(oldToken, rotated)
+	assert.NoError(t, err)
+}
+
+func TestLoadKeySet_RecargaKidDesconocido(t *testing.T) {
+	dir := t.TempDir()
+
+	keys, err := LoadKeySet(dir, AlgEdDSA, 0)
+	require.NoError(t, err)
+
+	// Otra réplica agrega una clave nueva al directorio compartido
+	other, err := GenerateSigningKey(AlgEdDSA)
+	require.NoError(t, err)
+	data, err := other.MarshalPEM()
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, other.ID+".pem"), data, 0o600))
+
+	token, err := GenerateJWT(testUsuario(), NewKeySet(other), "15m")
+	require.NoError(t, err)
+
+	keys.reloadedAt = time.Now().Add(-2 * keysReloadInterval)
+	_, err = ValidateJWT(token, keys)
+	assert.NoError(t, err)
+}
+
+func TestLoadKeySet_AlgoritmoInvalido(t *testing.T) {
+	_, err := LoadKeySet(t.TempDir(), "HS256", 0)
+	assert.Error(t, err)
+}
+
+func TestKeySet_JWKS(t *testing.T) {
+	rsaKey, err := GenerateSigningKey(AlgRS256)
+	require.NoError(t, err)
+	edKey, err := GenerateSigningKey(AlgEdDSA)
+	require.NoError(t, err)
+
+	set := NewKeySet(edKey, rsaKey).JWKS()
+	require.Len(t, set.Keys, 2)
+
+	// El documento publicado no contiene material privado y se puede volver
+	// a leer como claves públicas
+	raw, err := json.Marshal(set)
+	require.NoError(t, err)
+	assert.NotContains(t, string(raw), `"d"`)
+
+	var decoded JSONWebKeySet
+	require.NoError(t, json.Unmarshal(raw, &decoded))
+	for _, k := range decoded.Keys {
+		pub, err := k.publicKey()
+		require.NoError(t, err)
+
+		switch k.Kid {
+		case rsaKey.ID:
+			assert.Equal(t, AlgRS256, k.Alg)
+			assert.True(t, rsaKey.private.Public().(*rsa.PublicKey).Equal(pub))
+		case edKey.ID:
+			assert.Equal(t, AlgEdDSA, k.Alg)
+			assert.True(t, edKey.private.Public().(ed25519.PublicKey).Equal(pub))
+		default:
+			t.Fatalf("kid inesperado: %s", k.Kid)
+		}
+	}
+}
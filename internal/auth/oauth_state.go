@@ -0,0 +1,102 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+// OAuthState es lo que se guarda en la cookie entre el inicio del flujo OAuth
+// y el callback: el state enviado al proveedor, el code verifier de PKCE, el
+// nonce esperado en el ID token y el proveedor que inició el flujo.
+type OAuthState struct {
+	State     string
+	Verifier  string
+	Nonce     string
+	Provider  string
+	ExpiresAt time.Time
+}
+
+// NewOAuthState genera un state, un nonce y un code verifier PKCE aleatorios
+// para el proveedor indicado.
+func NewOAuthState(provider string, ttl time.Duration) (*OAuthState, error) {
+	state, err := GenerateRefreshToken()
+	if err != nil {
+		return nil, err
+	}
+
+	nonce, err := GenerateRefreshToken()
+	if err != nil {
+		return nil, err
+	}
+
+	return &OAuthState{
+		State:     state,
+		Verifier:  oauth2.GenerateVerifier(),
+		Nonce:     nonce,
+		Provider:  provider,
+		ExpiresAt: time.Now().Add(ttl),
+	}, nil
+}
+
+// Encode serializa el state firmado con HMAC-SHA256 para guardarlo en una
+// cookie. Ninguno de los campos contiene el separador "|".
+func (s *OAuthState) Encode(secret string) string {
+	payload := strings.Join([]string{s.State, s.Verifier, s.Nonce, s.Provider, strconv.FormatInt(s.ExpiresAt.Unix(), 10)}, "|")
+	encoded := base64.RawURLEncoding.EncodeToString([]byte(payload))
+	return encoded + "." + signState(encoded, secret)
+}
+
+// DecodeOAuthState verifica la firma y la vigencia del valor de la cookie.
+func DecodeOAuthState(value, secret string) (*OAuthState, error) {
+	encoded, signature, ok := strings.Cut(value, ".")
+	if !ok || !hmac.Equal([]byte(signature), []byte(signState(encoded, secret))) {
+		return nil, errors.New("state inválido")
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, errors.New("state inválido")
+	}
+
+	parts := strings.Split(string(payload), "|")
+	if len(parts) != 5 {
+		return nil, errors.New("state inválido")
+	}
+
+	expiresAt, err := strconv.ParseInt(parts[4], 10, 64)
+	if err != nil {
+		return nil, errors.New("state inválido")
+	}
+
+	state := &OAuthState{
+		State:     parts[0],
+		Verifier:  parts[1],
+		Nonce:     parts[2],
+		Provider:  parts[3],
+		ExpiresAt: time.Unix(expiresAt, 0),
+	}
+	if time.Now().After(state.ExpiresAt) {
+		return nil, errors.New("state expirado")
+	}
+
+	return state, nil
+}
+
+// Matches compara en tiempo constante el state recibido en el callback y
+// comprueba que el callback sea del mismo proveedor que inició el flujo.
+func (s *OAuthState) Matches(provider, state string) bool {
+	return state != "" && s.Provider == provider && hmac.Equal([]byte(s.State), []byte(state))
+}
+
+func signState(encoded, secret string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte("oauth-state:" + encoded))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
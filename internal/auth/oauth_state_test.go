@@ -0,0 +1,52 @@
+package auth
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOAuthState_RoundTrip(t *testing.T) {
+	state, err := NewOAuthState("google", 10*time.Minute)
+	require.NoError(t, err)
+	assert.NotEmpty(t, state.State)
+	assert.NotEmpty(t, state.Verifier)
+
+	decoded, err := DecodeOAuthState(state.Encode("secret"), "secret")
+	require.NoError(t, err)
+	assert.Equal(t, state.State, decoded.State)
+	assert.Equal(t, state.Verifier, decoded.Verifier)
+	assert.Equal(t, state.Nonce, decoded.Nonce)
+	assert.Equal(t, "google", decoded.Provider)
+	assert.True(t, decoded.Matches("google", state.State))
+	assert.False(t, decoded.Matches("google", "otro-state"))
+	assert.False(t, decoded.Matches("google", ""))
+	assert.False(t, decoded.Matches("keycloak", state.State))
+}
+
+func TestOAuthState_InvalidSignature(t *testing.T) {
+	state, err := NewOAuthState("google", 10*time.Minute)
+	require.NoError(t, err)
+
+	_, err = DecodeOAuthState(state.Encode("secret"), "otro-secret")
+	assert.Error(t, err)
+
+	// Alterar el contenido invalida la firma
+	forged := &OAuthState{State: "atacante", Verifier: state.Verifier, Nonce: state.Nonce, Provider: state.Provider, ExpiresAt: state.ExpiresAt}
+	encoded := forged.Encode("otro-secret")
+	_, err = DecodeOAuthState(encoded, "secret")
+	assert.Error(t, err)
+
+	_, err = DecodeOAuthState("sin-firma", "secret")
+	assert.Error(t, err)
+}
+
+func TestOAuthState_Expired(t *testing.T) {
+	state, err := NewOAuthState("google", -time.Minute)
+	require.NoError(t, err)
+
+	_, err = DecodeOAuthState(state.Encode("secret"), "secret")
+	assert.EqualError(t, err, "state expirado")
+}
@@ -0,0 +1,501 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"golang.org/x/oauth2"
+)
+
+// ClaimMapping indica qué claim del ID token (o de userinfo) contiene cada
+// dato del usuario. Los campos vacíos toman los nombres estándar de OIDC.
+type ClaimMapping struct {
+	Subject       string
+	Email         string
+	EmailVerified string
+	Name          string
+	Picture       string
+}
+
+// ProviderConfig describe un proveedor OpenID Connect. Los endpoints se
+// obtienen de Issuer + "/.well-known/openid-configuration".
+type ProviderConfig struct {
+	Name         string
+	DisplayName  string
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+	Claims       ClaimMapping
+}
+
+// UserInfo son los datos de la identidad devueltos por el proveedor.
+type UserInfo struct {
+	Provider string
+	Subject  string
+	Email    string
+	Verified bool
+	Name     string
+	Picture  string
+}
+
+// ProviderInfo es la información pública de un proveedor configurado.
+type ProviderInfo struct {
+	Name        string `json:"name"`
+	DisplayName string `json:"displayName"`
+}
+
+type discoveryDocument struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	UserinfoEndpoint      string `json:"userinfo_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// jwksRefreshInterval evita descargar el JWKS en cada token con un kid
+// desconocido.
+const jwksRefreshInterval = time.Minute
+
+var providerNamePattern = regexp.MustCompile(`^[a-z0-9_-]+$`)
+
+// Provider implementa el flujo authorization code + PKCE de un proveedor
+// OIDC. El documento de descubrimiento y las claves se obtienen al primer uso.
+type Provider struct {
+	cfg        ProviderConfig
+	httpClient *http.Client
+
+	mu            sync.Mutex
+	discovery     *discoveryDocument
+	keys          map[string]crypto.PublicKey
+	keysFetchedAt time.Time
+}
+
+func NewProvider(cfg ProviderConfig) *Provider {
+	if cfg.DisplayName == "" {
+		cfg.DisplayName = cfg.Name
+	}
+	if len(cfg.Scopes) == 0 {
+		cfg.Scopes = []string{"openid", "email", "profile"}
+	}
+	cfg.Issuer = strings.TrimSuffix(cfg.Issuer, "/")
+	cfg.Claims = cfg.Claims.withDefaults()
+
+	return &Provider{
+		cfg:        cfg,
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (p *Provider) Name() string {
+	return p.cfg.Name
+}
+
+// AuthCodeURL construye la URL de autorización con state, nonce y el
+// challenge PKCE (S256) derivado del verifier.
+func (p *Provider) AuthCodeURL(ctx context.Context, state, verifier, nonce string) (string, error) {
+	conf, err := p.oauth2Config(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	return conf.AuthCodeURL(state,
+		oauth2.S256ChallengeOption(verifier),
+		oauth2.SetAuthURLParam("nonce", nonce),
+	), nil
+}
+
+// Exchange canjea el código de autorización, valida el ID token y devuelve
+// la identidad con los claims ya mapeados.
+func (p *Provider) Exchange(ctx context.Context, code, verifier, nonce string) (*UserInfo, error) {
+	conf, err := p.oauth2Config(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx = context.WithValue(ctx, oauth2.HTTPClient, p.httpClient)
+	token, err := conf.Exchange(ctx, code, oauth2.VerifierOption(verifier))
+	if err != nil {
+		return nil, err
+	}
+
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok || rawIDToken == "" {
+		return nil, errors.New("el proveedor no devolvió un id_token")
+	}
+
+	claims, err := p.verifyIDToken(ctx, rawIDToken, nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	// Algunos proveedores solo entregan el email o el nombre en userinfo
+	if claimString(claims, p.cfg.Claims.Email) == "" {
+		if err := p.mergeUserinfo(ctx, token, claims); err != nil {
+			return nil, err
+		}
+	}
+
+	info := &UserInfo{
+		Provider: p.cfg.Name,
+		Subject:  claimString(claims, p.cfg.Claims.Subject),
+		Email:    strings.ToLower(claimString(claims, p.cfg.Claims.Email)),
+		Verified: claimBool(claims, p.cfg.Claims.EmailVerified),
+		Name:     claimString(claims, p.cfg.Claims.Name),
+		Picture:  claimString(claims, p.cfg.Claims.Picture),
+	}
+	if info.Subject == "" || info.Email == "" {
+		return nil, errors.New("el proveedor no devolvió el identificador o el email del usuario")
+	}
+
+	return info, nil
+}
+
+func (p *Provider) oauth2Config(ctx context.Context) (*oauth2.Config, error) {
+	doc, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &oauth2.Config{
+		ClientID:     p.cfg.ClientID,
+		ClientSecret: p.cfg.ClientSecret,
+		RedirectURL:  p.cfg.RedirectURL,
+		Scopes:       p.cfg.Scopes,
+		Endpoint: oauth2.Endpoint{
+			AuthURL:  doc.AuthorizationEndpoint,
+			TokenURL: doc.TokenEndpoint,
+		},
+	}, nil
+}
+
+func (p *Provider) discover(ctx context.Context) (*discoveryDocument, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.discovery != nil {
+		return p.discovery, nil
+	}
+
+	var doc discoveryDocument
+	if err := p.getJSON(ctx, p.cfg.Issuer+"/.well-known/openid-configuration", &doc); err != nil {
+		return nil, fmt.Errorf("error en el descubrimiento OIDC de %s: %w", p.cfg.Name, err)
+	}
+
+	if strings.TrimSuffix(doc.Issuer, "/") != p.cfg.Issuer {
+		return nil, fmt.Errorf("el issuer de %s no coincide: %s", p.cfg.Name, doc.Issuer)
+	}
+	if doc.AuthorizationEndpoint == "" || doc.TokenEndpoint == "" || doc.JWKSURI == "" {
+		return nil, fmt.Errorf("documento de descubrimiento incompleto para %s", p.cfg.Name)
+	}
+
+	p.discovery = &doc
+	return p.discovery, nil
+}
+
+func (p *Provider) verifyIDToken(ctx context.Context, rawIDToken, nonce string) (jwt.MapClaims, error) {
+	doc, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	claims := jwt.MapClaims{}
+	_, err = jwt.ParseWithClaims(rawIDToken, claims,
+		func(token *jwt.Token) (interface{}, error) {
+			kid, _ := token.Header["kid"].(string)
+			return p.publicKey(ctx, kid)
+		},
+		jwt.WithValidMethods([]string{"RS256", "RS384", "RS512", "PS256", "ES256", "ES384"}),
+		jwt.WithIssuer(doc.Issuer),
+		jwt.WithAudience(p.cfg.ClientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(30*time.Second),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("id_token inválido: %w", err)
+	}
+
+	if got, _ := claims["nonce"].(string); got != nonce {
+		return nil, errors.New("id_token inválido: nonce no coincide")
+	}
+
+	return claims, nil
+}
+
+// publicKey busca la clave por kid y vuelve a descargar el JWKS si no la
+// encuentra, para soportar la rotación de claves del proveedor.
+func (p *Provider) publicKey(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	doc, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if key, ok := p.lookupKey(kid); ok {
+		return key, nil
+	}
+
+	if time.Since(p.keysFetchedAt) < jwksRefreshInterval {
+		return nil, errors.New("clave de firma desconocida")
+	}
+
+	keys, err := p.fetchJWKS(ctx, doc.JWKSURI)
+	if err != nil {
+		return nil, err
+	}
+	p.keys = keys
+	p.keysFetchedAt = time.Now()
+
+	if key, ok := p.lookupKey(kid); ok {
+		return key, nil
+	}
+	return nil, errors.New("clave de firma desconocida")
+}
+
+// lookupKey acepta un kid vacío solo si el proveedor publica una única clave.
+func (p *Provider) lookupKey(kid string) (crypto.PublicKey, bool) {
+	if kid == "" && len(p.keys) == 1 {
+		for _, key := range p.keys {
+			return key, true
+		}
+	}
+	key, ok := p.keys[kid]
+	return key, ok
+}
+
+func (p *Provider) fetchJWKS(ctx context.Context, uri string) (map[string]crypto.PublicKey, error) {
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := p.getJSON(ctx, uri, &set); err != nil {
+		return nil, fmt.Errorf("error descargando JWKS de %s: %w", p.cfg.Name, err)
+	}
+
+	keys := make(map[string]crypto.PublicKey)
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		key, err := k.publicKey()
+		if err != nil {
+			continue
+		}
+		keys[k.Kid] = key
+	}
+	return keys, nil
+}
+
+func (p *Provider) mergeUserinfo(ctx context.Context, token *oauth2.Token, claims jwt.MapClaims) error {
+	doc, err := p.discover(ctx)
+	if err != nil {
+		return err
+	}
+	if doc.UserinfoEndpoint == "" {
+		return nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, doc.UserinfoEndpoint, nil)
+	if err != nil {
+		return err
+	}
+	token.SetAuthHeader(req)
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return errors.New("error obteniendo información del usuario")
+	}
+
+	var extra map[string]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&extra); err != nil {
+		return err
+	}
+
+	// userinfo debe referirse al mismo sujeto que el ID token
+	if sub, _ := extra["sub"].(string); sub != "" && sub != claimString(claims, "sub") {
+		return errors.New("userinfo no corresponde al id_token")
+	}
+	for k, v := range extra {
+		if _, exists := claims[k]; !exists {
+			claims[k] = v
+		}
+	}
+	return nil
+}
+
+func (p *Provider) getJSON(ctx context.Context, url string, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("respuesta %d de %s", resp.StatusCode, url)
+	}
+
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// Registry agrupa los proveedores configurados por nombre.
+type Registry struct {
+	providers map[string]*Provider
+	order     []string
+}
+
+// NewRegistry crea los proveedores válidos; los que no tienen issuer o
+// client ID se omiten.
+func NewRegistry(configs []ProviderConfig) *Registry {
+	r := &Registry{providers: make(map[string]*Provider)}
+	for _, cfg := range configs {
+		if !providerNamePattern.MatchString(cfg.Name) || cfg.Issuer == "" || cfg.ClientID == "" {
+			continue
+		}
+		if _, exists := r.providers[cfg.Name]; exists {
+			continue
+		}
+		r.providers[cfg.Name] = NewProvider(cfg)
+		r.order = append(r.order, cfg.Name)
+	}
+	return r
+}
+
+func (r *Registry) Get(name string) (*Provider, bool) {
+	p, ok := r.providers[name]
+	return p, ok
+}
+
+func (r *Registry) List() []ProviderInfo {
+	list := make([]ProviderInfo, 0, len(r.order))
+	for _, name := range r.order {
+		list = append(list, ProviderInfo{Name: name, DisplayName: r.providers[name].cfg.DisplayName})
+	}
+	return list
+}
+
+func (m ClaimMapping) withDefaults() ClaimMapping {
+	if m.Subject == "" {
+		m.Subject = "sub"
+	}
+	if m.Email == "" {
+		m.Email = "email"
+	}
+	if m.EmailVerified == "" {
+		m.EmailVerified = "email_verified"
+	}
+	if m.Name == "" {
+		m.Name = "name"
+	}
+	if m.Picture == "" {
+		m.Picture = "picture"
+	}
+	return m
+}
+
+func claimString(claims jwt.MapClaims, name string) string {
+	switch v := claims[name].(type) {
+	case string:
+		return v
+	case float64:
+		return fmt.Sprintf("%.0f", v)
+	}
+	return ""
+}
+
+// claimBool acepta booleanos y también la cadena "true", que algunos
+// proveedores usan para email_verified.
+func claimBool(claims jwt.MapClaims, name string) bool {
+	switch v := claims[name].(type) {
+	case bool:
+		return v
+	case string:
+		return strings.EqualFold(v, "true")
+	}
+	return false
+}
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use,omitempty"`
+	Alg string `json:"alg,omitempty"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+func (k jwk) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		default:
+			return nil, fmt.Errorf("curva no soportada: %s", k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := base64.RawURLEncoding.DecodeString(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}, nil
+	case "OKP":
+		if k.Crv != "Ed25519" {
+			return nil, fmt.Errorf("curva no soportada: %s", k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, err
+		}
+		if len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("clave Ed25519 inválida")
+		}
+		return ed25519.PublicKey(x), nil
+	}
+	return nil, fmt.Errorf("tipo de clave no soportado: %s", k.Kty)
+}
@@ -0,0 +1,271 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeOIDCServer es un proveedor OIDC mínimo: descubrimiento, autorización
+// con PKCE, token endpoint, JWKS y userinfo.
+type fakeOIDCServer struct {
+	*httptest.Server
+	t        *testing.T
+	key      *rsa.PrivateKey
+	clientID string
+	audience string
+	claims   jwt.MapClaims
+	userinfo map[string]interface{}
+
+	mu    sync.Mutex
+	codes map[string]pendingCode
+}
+
+type pendingCode struct {
+	challenge string
+	nonce     string
+}
+
+func newFakeOIDCServer(t *testing.T, clientID string, claims jwt.MapClaims) *fakeOIDCServer {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	f := &fakeOIDCServer{t: t, key: key, clientID: clientID, claims: claims, codes: map[string]pendingCode{}}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 f.URL,
+			"authorization_endpoint": f.URL + "/authorize",
+			"token_endpoint":         f.URL + "/token",
+			"userinfo_endpoint":      f.URL + "/userinfo",
+			"jwks_uri":               f.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/authorize", f.authorize)
+	mux.HandleFunc("/token", f.token)
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": "test-key",
+			"use": "sig",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("/userinfo", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer access-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		json.NewEncoder(w).Encode(f.userinfo)
+	})
+
+	f.Server = httptest.NewServer(mux)
+	t.Cleanup(f.Close)
+	return f
+}
+
+// authorize simula al usuario aceptando el consentimiento y redirige al
+// redirect_uri con el código y el state.
+func (f *fakeOIDCServer) authorize(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	assert.Equal(f.t, "code", q.Get("response_type"))
+	assert.Equal(f.t, f.clientID, q.Get("client_id"))
+	assert.Equal(f.t, "S256", q.Get("code_challenge_method"))
+
+	f.mu.Lock()
+	f.codes["auth-code"] = pendingCode{challenge: q.Get("code_challenge"), nonce: q.Get("nonce")}
+	f.mu.Unlock()
+
+	redirect, _ := url.Parse(q.Get("redirect_uri"))
+	params := redirect.Query()
+	params.Set("code", "auth-code")
+	params.Set("state", q.Get("state"))
+	redirect.RawQuery = params.Encode()
+	http.Redirect(w, r, redirect.String(), http.StatusFound)
+}
+
+func (f *fakeOIDCServer) token(w http.ResponseWriter, r *http.Request) {
+	require.NoError(f.t, r.ParseForm())
+
+	f.mu.Lock()
+	pending, ok := f.codes[r.Form.Get("code")]
+	delete(f.codes, r.Form.Get("code"))
+	f.mu.Unlock()
+
+	sum := sha256.Sum256([]byte(r.Form.Get("code_verifier")))
+	if !ok || base64.RawURLEncoding.EncodeToString(sum[:]) != pending.challenge {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	audience := f.clientID
+	if f.audience != "" {
+		audience = f.audience
+	}
+
+	claims := jwt.MapClaims{
+		"iss":   f.URL,
+		"aud":   audience,
+		"exp":   time.Now().Add(time.Hour).Unix(),
+		"iat":   time.Now().Unix(),
+		"nonce": pending.nonce,
+	}
+	for k, v := range f.claims {
+		claims[k] = v
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = "test-key"
+	idToken, err := token.SignedString(f.key)
+	require.NoError(f.t, err)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"access_token": "access-token",
+		"token_type":   "Bearer",
+		"expires_in":   3600,
+		"id_token":     idToken,
+	})
+}
+
+// runFlow recorre el flujo completo: URL de autorización, redirección del
+// proveedor al callback, comprobación del state e intercambio del código.
+func runFlow(t *testing.T, provider *Provider, tamper func(*OAuthState)) (*UserInfo, error) {
+	ctx := context.Background()
+
+	state, err := NewOAuthState(provider.Name(), time.Minute)
+	require.NoError(t, err)
+	cookie := state.Encode("secret")
+
+	authURL, err := provider.AuthCodeURL(ctx, state.State, state.Verifier, state.Nonce)
+	require.NoError(t, err)
+
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	resp, err := client.Get(authURL)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusFound, resp.StatusCode)
+
+	callback, err := url.Parse(resp.Header.Get("Location"))
+	require.NoError(t, err)
+
+	saved, err := DecodeOAuthState(cookie, "secret")
+	require.NoError(t, err)
+	require.True(t, saved.Matches(provider.Name(), callback.Query().Get("state")))
+
+	if tamper != nil {
+		tamper(saved)
+	}
+	return provider.Exchange(ctx, callback.Query().Get("code"), saved.Verifier, saved.Nonce)
+}
+
+func TestProvider_EndToEnd(t *testing.T) {
+	server := newFakeOIDCServer(t, "client-id", jwt.MapClaims{
+		"sub":                "user-123",
+		"email":              "Ana@Example.com",
+		"email_verified":     true,
+		"preferred_username": "ana",
+	})
+
+	registry := NewRegistry([]ProviderConfig{{
+		Name:        "fake",
+		DisplayName: "Fake IdP",
+		Issuer:      server.URL,
+		ClientID:    "client-id",
+		RedirectURL: "http://localhost:8080/api/v1/auth/fake/callback",
+		Claims:      ClaimMapping{Name: "preferred_username"},
+	}})
+	provider, ok := registry.Get("fake")
+	require.True(t, ok)
+	assert.Equal(t, []ProviderInfo{{Name: "fake", DisplayName: "Fake IdP"}}, registry.List())
+
+	info, err := runFlow(t, provider, nil)
+	require.NoError(t, err)
+	assert.Equal(t, &UserInfo{
+		Provider: "fake",
+		Subject:  "user-123",
+		Email:    "ana@example.com",
+		Verified: true,
+		Name:     "ana",
+	}, info)
+}
+
+func TestProvider_EmailFromUserinfo(t *testing.T) {
+	server := newFakeOIDCServer(t, "client-id", jwt.MapClaims{"sub": "user-123"})
+	server.userinfo = map[string]interface{}{
+		"sub":            "user-123",
+		"mail":           "ana@example.com",
+		"email_verified": "true",
+	}
+
+	provider := NewProvider(ProviderConfig{
+		Name:     "fake",
+		Issuer:   server.URL,
+		ClientID: "client-id",
+		Claims:   ClaimMapping{Email: "mail"},
+	})
+
+	info, err := runFlow(t, provider, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "ana@example.com", info.Email)
+	assert.True(t, info.Verified)
+}
+
+func TestProvider_RejectsInvalidFlows(t *testing.T) {
+	server := newFakeOIDCServer(t, "client-id", jwt.MapClaims{"sub": "user-123", "email": "ana@example.com"})
+
+	newProvider := func() *Provider {
+		return NewProvider(ProviderConfig{Name: "fake", Issuer: server.URL, ClientID: "client-id"})
+	}
+
+	_, err := runFlow(t, newProvider(), func(s *OAuthState) { s.Nonce = "otro-nonce" })
+	assert.ErrorContains(t, err, "nonce")
+
+	// Sin el verifier correcto el proveedor rechaza el código
+	_, err = runFlow(t, newProvider(), func(s *OAuthState) { s.Verifier = "verifier-incorrecto" })
+	assert.Error(t, err)
+
+	// Un ID token emitido para otro cliente no es válido
+	server.audience = "otro-cliente"
+	_, err = runFlow(t, newProvider(), nil)
+	assert.ErrorContains(t, err, "id_token inválido")
+}
+
+func TestProvider_IssuerMismatch(t *testing.T) {
+	server := newFakeOIDCServer(t, "client-id", nil)
+
+	provider := NewProvider(ProviderConfig{Name: "fake", Issuer: server.URL + "/otro", ClientID: "client-id"})
+	_, err := provider.AuthCodeURL(context.Background(), "state", "verifier", "nonce")
+	assert.Error(t, err)
+}
+
+func TestNewRegistry_SkipsInvalidProviders(t *testing.T) {
+	registry := NewRegistry([]ProviderConfig{
+		{Name: "sin-issuer", ClientID: "id"},
+		{Name: "Mal|Nombre", Issuer: "https://idp.example.com", ClientID: "id"},
+		GoogleProvider("id", "secret", "http://localhost/callback"),
+	})
+
+	_, ok := registry.Get("sin-issuer")
+	assert.False(t, ok)
+	assert.Equal(t, []ProviderInfo{{Name: "google", DisplayName: "Google"}}, registry.List())
+}
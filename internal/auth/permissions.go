@@ -0,0 +1,76 @@
+package auth
+
+import "strings"
+
+// Permisos con el formato "recurso:acción". Un rol puede usar "*" (o el
+// valor heredado "all") para concederlos todos, o "recurso:*" para todas las
+// acciones de un recurso.
+const (
+	PermTransaccionesRead  = "transacciones:read"
+	PermTransaccionesWrite = "transacciones:write"
+	PermCategoriasRead     = "categorias:read"
+	PermCategoriasWrite    = "categorias:write"
+	PermCategoriasGlobal   = "categorias:global" // modificar categorías globales
+	PermReportesRead       = "reportes:read"
+	PermUsuariosRead       = "usuarios:read"
+	PermUsuariosWrite      = "usuarios:write"
+	PermRolesRead          = "roles:read"
+	PermRolesWrite         = "roles:write"
+	PermAuditoriaRead      = "auditoria:read"
+	PermTiposCambioWrite   = "tipos_cambio:write"
+
+	PermAll       = "*"
+	permAllLegacy = "all"
+)
+
+// Permisos es el catálogo de permisos que entiende la aplicación.
+var Permisos = []string{
+	PermTransaccionesRead,
+	PermTransaccionesWrite,
+	PermCategoriasRead,
+	PermCategoriasWrite,
+	PermCategoriasGlobal,
+	PermReportesRead,
+	PermUsuariosRead,
+	PermUsuariosWrite,
+	PermRolesRead,
+	PermRolesWrite,
+	PermAuditoriaRead,
+	PermTiposCambioWrite,
+}
+
+// DefaultUserPermissions son los permisos del rol "user" creado al iniciar.
+var DefaultUserPermissions = []string{
+	PermTransaccionesRead,
+	PermTransaccionesWrite,
+	PermCategoriasRead,
+	PermCategoriasWrite,
+	PermReportesRead,
+}
+
+// HasPermission indica si alguno de los permisos concedidos cubre el requerido.
+func HasPermission(granted []string, required string) bool {
+	resource, _, _ := strings.Cut(required, ":")
+	for _, p := range granted {
+		switch p {
+		case PermAll, permAllLegacy, required, resource + ":*":
+			return true
+		}
+	}
+	return false
+}
+
+// ValidPermission indica si el permiso existe en el catálogo o es un comodín
+// válido.
+func ValidPermission(permiso string) bool {
+	if permiso == PermAll || permiso == permAllLegacy {
+		return true
+	}
+	for _, p := range Permisos {
+		resource, _, _ := strings.Cut(p, ":")
+		if permiso == p || permiso == resource+":*" {
+			return true
+		}
+	}
+	return false
+}
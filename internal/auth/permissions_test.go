@@ -0,0 +1,40 @@
+package auth
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHasPermission(t *testing.T) {
+	tests := []struct {
+		name     string
+		granted  []string
+		required string
+		want     bool
+	}{
+		{"permiso exacto", []string{PermTransaccionesRead}, PermTransaccionesRead, true},
+		{"otra acción", []string{PermTransaccionesRead}, PermTransaccionesWrite, false},
+		{"otro recurso", []string{PermTransaccionesWrite}, PermCategoriasWrite, false},
+		{"comodín de recurso", []string{"transacciones:*"}, PermTransaccionesWrite, true},
+		{"comodín total", []string{PermAll}, PermRolesWrite, true},
+		{"valor heredado all", []string{"all"}, PermUsuariosWrite, true},
+		{"valores heredados read/write", []string{"read", "write"}, PermUsuariosRead, false},
+		{"sin permisos", nil, PermReportesRead, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, HasPermission(tt.granted, tt.required))
+		})
+	}
+}
+
+func TestValidPermission(t *testing.T) {
+	assert.True(t, ValidPermission(PermReportesRead))
+	assert.True(t, ValidPermission("reportes:*"))
+	assert.True(t, ValidPermission("*"))
+	assert.False(t, ValidPermission("reportes:delete"))
+	assert.False(t, ValidPermission("desconocido:*"))
+	assert.False(t, ValidPermission("read"))
+}
@@ -0,0 +1,405 @@
+// Package camt lee extractos bancarios camt.053 de ISO 20022 (Bank to
+// Customer Statement), en cualquiera de sus versiones: los elementos se
+// buscan por su nombre, sin importar el espacio de nombres.
+package camt
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"control-financiero/internal/money"
+
+	"golang.org/x/text/encoding/htmlindex"
+)
+
+var ErrInvalidCamt = errors.New("archivo camt.053 inválido")
+
+// Estados de un apunte (Sts). Solo los contabilizados forman parte del saldo.
+const (
+	Contabilizado = "BOOK"
+	Pendiente     = "PDNG"
+	Informativo   = "INFO"
+)
+
+// Tipos de saldo (Bal/Tp) más usados.
+const (
+	SaldoApertura   = "OPBD"
+	SaldoCierre     = "CLBD"
+	SaldoDisponible = "CLAV"
+)
+
+// Statement es el extracto de una cuenta (Stmt).
+type Statement struct {
+	ID       string
+	Account  Account
+	From, To time.Time // FrToDt; cero si no se indica
+	Balances []*Balance
+	Entries  []*Entry
+}
+
+// Account es la cuenta del extracto.
+type Account struct {
+	IBAN     string
+	Other    string // Othr/Id, para las cuentas sin IBAN
+	Currency string
+	Owner    string
+}
+
+// Balance es un saldo del extracto. Amount tiene signo: negativo si es
+// deudor.
+type Balance struct {
+	Type     string // OPBD, CLBD, CLAV...
+	Amount   money.Amount
+	Currency string
+	Date     time.Time
+}
+
+// Entry es un apunte (Ntry). Amount es positivo si el dinero entra en la
+// cuenta. Un apunte agrupa una o varias transacciones, como en las remesas.
+type Entry struct {
+	Reference      string // NtryRef
+	BankReference  string // AcctSvcrRef, la referencia única del banco
+	Amount         money.Amount
+	Currency       string
+	Status         string // BOOK, PDNG o INFO
+	Reversal       bool   // es la anulación de otro apunte
+	BookingDate    time.Time
+	ValueDate      time.Time // cero si no se indica
+	AdditionalInfo string    // AddtlNtryInf
+	Transactions   []*Transaction
+}
+
+// Transaction son los detalles de una transacción del apunte (TxDtls).
+// Counterparty es el deudor en los abonos y el acreedor en los cargos.
+type Transaction struct {
+	BankReference       string        // Refs/AcctSvcrRef
+	EndToEndID          string        // vacía si es NOTPROVIDED
+	Amount              *money.Amount // con el signo del apunte; nil si no se indica
+	CounterpartyName    string
+	CounterpartyAccount string // IBAN u otro identificador
+	RemittanceInfo      string // RmtInf: el texto libre o las referencias estructuradas
+	AdditionalInfo      string // AddtlTxInf
+}
+
+// Detect indica si data parece un camt.053.
+func Detect(data []byte) bool {
+	inicio := data
+	if len(inicio) > 4096 {
+		inicio = inicio[:4096]
+	}
+	return bytes.Contains(inicio, []byte("camt.053")) || bytes.Contains(inicio, []byte("BkToCstmrStmt"))
+}
+
+// Parse lee todos los extractos de un documento camt.053. Las fechas con hora
+// conservan la zona horaria en que están escritas; las que no la tienen son
+// UTC.
+func Parse(data []byte) ([]*Statement, error) {
+	var doc document
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	decoder.CharsetReader = func(nombre string, input io.Reader) (io.Reader, error) {
+		enc, err := htmlindex.Get(nombre)
+		if err != nil {
+			return nil, err
+		}
+		return enc.NewDecoder().Reader(input), nil
+	}
+	if err := decoder.Decode(&doc); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCamt, err)
+	}
+	if doc.Statement == nil || len(doc.Statement.Stmts) == 0 {
+		return nil, fmt.Errorf("%w: no contiene extractos (BkToCstmrStmt/Stmt)", ErrInvalidCamt)
+	}
+
+	statements := make([]*Statement, 0, len(doc.Statement.Stmts))
+	for _, s := range doc.Statement.Stmts {
+		st, err := s.convert()
+		if err != nil {
+			return nil, fmt.Errorf("%w: extracto %s: %v", ErrInvalidCamt, s.ID, err)
+		}
+		statements = append(statements, st)
+	}
+	return statements, nil
+}
+
+// document refleja la parte de camt.053 que se lee. encoding/xml compara los
+// nombres sin espacio de nombres cuando la etiqueta no lo indica.
+type document struct {
+	Statement *struct {
+		Stmts []*xmlStatement `xml:"Stmt"`
+	} `xml:"BkToCstmrStmt"`
+}
+
+type xmlStatement struct {
+	ID   string `xml:"Id"`
+	Acct struct {
+		ID   xmlAccountID `xml:"Id"`
+		Ccy  string       `xml:"Ccy"`
+		Ownr xmlParty     `xml:"Ownr"`
+	} `xml:"Acct"`
+	FrToDt struct {
+		From string `xml:"FrDtTm"`
+		To   string `xml:"ToDtTm"`
+	} `xml:"FrToDt"`
+	Bal  []xmlBalance `xml:"Bal"`
+	Ntry []xmlEntry   `xml:"Ntry"`
+}
+
+type xmlAccountID struct {
+	IBAN  string `xml:"IBAN"`
+	Other string `xml:"Othr>Id"`
+}
+
+// xmlParty lee el nombre de las versiones 2 a 7 (Nm) y de la 8 en adelante
+// (Pty/Nm).
+type xmlParty struct {
+	Name    string `xml:"Nm"`
+	PtyName string `xml:"Pty>Nm"`
+}
+
+func (p xmlParty) name() string {
+	if p.Name != "" {
+		return p.Name
+	}
+	return p.PtyName
+}
+
+type xmlAmount struct {
+	Value string `xml:",chardata"`
+	Ccy   string `xml:"Ccy,attr"`
+}
+
+type xmlDate struct {
+	Dt   string `xml:"Dt"`
+	DtTm string `xml:"DtTm"`
+}
+
+type xmlBalance struct {
+	Type      string    `xml:"Tp>CdOrPrtry>Cd"`
+	Prtry     string    `xml:"Tp>CdOrPrtry>Prtry"`
+	Amt       xmlAmount `xml:"Amt"`
+	CdtDbtInd string    `xml:"CdtDbtInd"`
+	Dt        xmlDate   `xml:"Dt"`
+}
+
+// xmlStatus lee el estado de las versiones 2 a 7 (<Sts>BOOK</Sts>) y de la
+// 8 en adelante (<Sts><Cd>BOOK</Cd></Sts>).
+type xmlStatus struct {
+	Text string `xml:",chardata"`
+	Cd   string `xml:"Cd"`
+}
+
+type xmlEntry struct {
+	NtryRef      string         `xml:"NtryRef"`
+	Amt          xmlAmount      `xml:"Amt"`
+	CdtDbtInd    string         `xml:"CdtDbtInd"`
+	RvslInd      bool           `xml:"RvslInd"`
+	Sts          xmlStatus      `xml:"Sts"`
+	BookgDt      xmlDate        `xml:"BookgDt"`
+	ValDt        xmlDate        `xml:"ValDt"`
+	AcctSvcrRef  string         `xml:"AcctSvcrRef"`
+	AddtlNtryInf string         `xml:"AddtlNtryInf"`
+	TxDtls       []xmlTxDetails `xml:"NtryDtls>TxDtls"`
+}
+
+type xmlTxDetails struct {
+	Refs struct {
+		AcctSvcrRef string `xml:"AcctSvcrRef"`
+		EndToEndID  string `xml:"EndToEndId"`
+	} `xml:"Refs"`
+	Amt       *xmlAmount `xml:"Amt"`
+	TxAmt     *xmlAmount `xml:"AmtDtls>TxAmt>Amt"`
+	RltdPties struct {
+		Dbtr     xmlParty     `xml:"Dbtr"`
+		DbtrAcct xmlAccountID `xml:"DbtrAcct>Id"`
+		Cdtr     xmlParty     `xml:"Cdtr"`
+		CdtrAcct xmlAccountID `xml:"CdtrAcct>Id"`
+	} `xml:"RltdPties"`
+	RmtInf struct {
+		Ustrd []string        `xml:"Ustrd"`
+		Strd  []xmlStructured `xml:"Strd"`
+	} `xml:"RmtInf"`
+	AddtlTxInf string `xml:"AddtlTxInf"`
+}
+
+// xmlStructured es una referencia estructurada de la remesa, como la
+// RF de ISO 11649.
+type xmlStructured struct {
+	Ref string `xml:"CdtrRefInf>Ref"`
+}
+
+func (s *xmlStatement) convert() (*Statement, error) {
+	st := &Statement{
+		ID: strings.TrimSpace(s.ID),
+		Account: Account{
+			IBAN:     strings.TrimSpace(s.Acct.ID.IBAN),
+			Other:    strings.TrimSpace(s.Acct.ID.Other),
+			Currency: strings.ToUpper(strings.TrimSpace(s.Acct.Ccy)),
+			Owner:    strings.TrimSpace(s.Acct.Ownr.name()),
+		},
+	}
+	if st.Account.IBAN == "" && st.Account.Other == "" {
+		return nil, errors.New("falta la cuenta (Acct/Id)")
+	}
+
+	var err error
+	if s.FrToDt.From != "" {
+		if st.From, err = parseDateTime(s.FrToDt.From); err != nil {
+			return nil, fmt.Errorf("FrDtTm: %v", err)
+		}
+	}
+	if s.FrToDt.To != "" {
+		if st.To, err = parseDateTime(s.FrToDt.To); err != nil {
+			return nil, fmt.Errorf("ToDtTm: %v", err)
+		}
+	}
+
+	for _, b := range s.Bal {
+		balance := &Balance{Type: strings.TrimSpace(b.Type), Currency: strings.TrimSpace(b.Amt.Ccy)}
+		if balance.Type == "" {
+			balance.Type = strings.TrimSpace(b.Prtry)
+		}
+		if balance.Amount, err = parseAmount(b.Amt.Value, b.CdtDbtInd); err != nil {
+			return nil, fmt.Errorf("saldo %s: %v", balance.Type, err)
+		}
+		if balance.Date, err = b.Dt.parse(); err != nil {
+			return nil, fmt.Errorf("saldo %s: %v", balance.Type, err)
+		}
+		st.Balances = append(st.Balances, balance)
+	}
+
+	for i, n := range s.Ntry {
+		entry, err := n.convert()
+		if err != nil {
+			return nil, fmt.Errorf("apunte %d: %v", i+1, err)
+		}
+		st.Entries = append(st.Entries, entry)
+	}
+	return st, nil
+}
+
+func (n *xmlEntry) convert() (*Entry, error) {
+	e := &Entry{
+		Reference:      strings.TrimSpace(n.NtryRef),
+		BankReference:  strings.TrimSpace(n.AcctSvcrRef),
+		Currency:       strings.TrimSpace(n.Amt.Ccy),
+		Status:         strings.TrimSpace(n.Sts.Cd),
+		Reversal:       n.RvslInd,
+		AdditionalInfo: strings.TrimSpace(n.AddtlNtryInf),
+	}
+	if e.Status == "" {
+		e.Status = strings.TrimSpace(n.Sts.Text)
+	}
+
+	var err error
+	if e.Amount, err = parseAmount(n.Amt.Value, n.CdtDbtInd); err != nil {
+		return nil, err
+	}
+	if e.BookingDate, err = n.BookgDt.parse(); err != nil {
+		return nil, fmt.Errorf("BookgDt: %v", err)
+	}
+	if n.ValDt.Dt != "" || n.ValDt.DtTm != "" {
+		if e.ValueDate, err = n.ValDt.parse(); err != nil {
+			return nil, fmt.Errorf("ValDt: %v", err)
+		}
+	}
+
+	for _, tx := range n.TxDtls {
+		t := &Transaction{
+			BankReference:  strings.TrimSpace(tx.Refs.AcctSvcrRef),
+			EndToEndID:     strings.TrimSpace(tx.Refs.EndToEndID),
+			RemittanceInfo: remittanceInfo(tx.RmtInf.Ustrd, tx.RmtInf.Strd),
+			AdditionalInfo: strings.TrimSpace(tx.AddtlTxInf),
+		}
+		if t.EndToEndID == "NOTPROVIDED" {
+			t.EndToEndID = ""
+		}
+
+		contraparte, cuenta := tx.RltdPties.Cdtr, tx.RltdPties.CdtrAcct
+		if e.Amount > 0 {
+			contraparte, cuenta = tx.RltdPties.Dbtr, tx.RltdPties.DbtrAcct
+		}
+		t.CounterpartyName = strings.TrimSpace(contraparte.name())
+		if t.CounterpartyAccount = strings.TrimSpace(cuenta.IBAN); t.CounterpartyAccount == "" {
+			t.CounterpartyAccount = strings.TrimSpace(cuenta.Other)
+		}
+
+		monto := tx.Amt
+		if monto == nil {
+			monto = tx.TxAmt
+		}
+		if monto != nil {
+			a, err := parseAmount(monto.Value, n.CdtDbtInd)
+			if err != nil {
+				return nil, fmt.Errorf("transacción %d: %v", len(e.Transactions)+1, err)
+			}
+			t.Amount = &a
+		}
+		e.Transactions = append(e.Transactions, t)
+	}
+	return e, nil
+}
+
+// remittanceInfo une el texto libre de la remesa o, si no lo hay, las
+// referencias estructuradas del acreedor.
+func remittanceInfo(ustrd []string, strd []xmlStructured) string {
+	var partes []string
+	for _, u := range ustrd {
+		if u = strings.TrimSpace(u); u != "" {
+			partes = append(partes, u)
+		}
+	}
+	if len(partes) == 0 {
+		for _, s := range strd {
+			if ref := strings.TrimSpace(s.Ref); ref != "" {
+				partes = append(partes, ref)
+			}
+		}
+	}
+	return strings.Join(partes, " ")
+}
+
+// parseAmount lee un importe y le pone el signo de CdtDbtInd: CRDT suma y
+// DBIT resta.
+func parseAmount(valor, indicador string) (money.Amount, error) {
+	a, err := money.Parse(strings.TrimSpace(valor))
+	if err != nil {
+		return 0, fmt.Errorf("importe inválido %q", valor)
+	}
+	switch strings.TrimSpace(indicador) {
+	case "CRDT":
+		return a, nil
+	case "DBIT":
+		return -a, nil
+	}
+	return 0, fmt.Errorf("indicador de crédito o débito inválido %q", indicador)
+}
+
+func (d xmlDate) parse() (time.Time, error) {
+	if d.Dt != "" {
+		t, err := time.Parse(time.DateOnly, strings.TrimSpace(d.Dt))
+		if err != nil {
+			return time.Time{}, fmt.Errorf("fecha inválida %q", d.Dt)
+		}
+		return t, nil
+	}
+	if d.DtTm != "" {
+		return parseDateTime(d.DtTm)
+	}
+	return time.Time{}, errors.New("falta la fecha")
+}
+
+// parseDateTime lee una fecha ISO 8601 con hora, con o sin zona horaria.
+func parseDateTime(s string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	for _, layout := range []string{time.RFC3339, "2006-01-02T15:04:05"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("fecha inválida %q", s)
+}
@@ -0,0 +1,56 @@
+package camt
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"control-financiero/internal/testutil"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse_Golden(t *testing.T) {
+	archivos, err := filepath.Glob(filepath.Join("testdata", "*.xml"))
+	require.NoError(t, err)
+	require.NotEmpty(t, archivos)
+
+	for _, archivo := range archivos {
+		t.Run(filepath.Base(archivo), func(t *testing.T) {
+			data, err := os.ReadFile(archivo)
+			require.NoError(t, err)
+			require.True(t, Detect(data))
+
+			statements, err := Parse(data)
+			require.NoError(t, err)
+			testutil.Golden(t, archivo, statements)
+		})
+	}
+}
+
+func TestParse_Errores(t *testing.T) {
+	stmt := func(ntry string) string {
+		return `<Document><BkToCstmrStmt><Stmt><Id>1</Id><Acct><Id><IBAN>DE89370400440532013000</IBAN></Id></Acct>` +
+			ntry + `</Stmt></BkToCstmrStmt></Document>`
+	}
+	tests := []struct {
+		name string
+		data string
+		want string
+	}{
+		{"no es XML", "fecha,monto", "archivo camt.053 inválido"},
+		{"sin extractos", "<Document><BkToCstmrStmt></BkToCstmrStmt></Document>", "no contiene extractos"},
+		{"sin cuenta", "<Document><BkToCstmrStmt><Stmt><Id>1</Id></Stmt></BkToCstmrStmt></Document>", "falta la cuenta"},
+		{"importe inválido", stmt(`<Ntry><Amt Ccy="EUR">1,5</Amt><CdtDbtInd>CRDT</CdtDbtInd><BookgDt><Dt>2025-01-01</Dt></BookgDt></Ntry>`), "apunte 1: importe inválido"},
+		{"sin indicador", stmt(`<Ntry><Amt Ccy="EUR">1.5</Amt><BookgDt><Dt>2025-01-01</Dt></BookgDt></Ntry>`), "indicador de crédito o débito"},
+		{"sin fecha", stmt(`<Ntry><Amt Ccy="EUR">1.5</Amt><CdtDbtInd>DBIT</CdtDbtInd></Ntry>`), "BookgDt: falta la fecha"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse([]byte(tt.data))
+			require.ErrorIs(t, err, ErrInvalidCamt)
+			assert.Contains(t, err.Error(), tt.want)
+		})
+	}
+}
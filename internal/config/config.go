@@ -1,42 +1,156 @@
-package config
-
-import (
-	"os"
-)
-
-type Config struct {
-	Env               string
-	Port              string
-	MongoURI          string
-	MongoDB           string
-	JWTSecret         string
-	JWTExpiration     string
-	RefreshExpiration string
-	GoogleClientID    string
-	GoogleSecret      string
-	GoogleRedirectURL string
-	AppURL            string
-}
-
-func Load() *Config {
-	return &Config{
-		Env:               getEnv("ENV", "development"),
-		Port:              getEnv("PORT", "8080"),
-		MongoURI:          getEnv("MONGO_URI", "mongodb://localhost:27017"),
-		MongoDB:           getEnv("MONGO_DB", "control_financiero"),
-		JWTSecret:         getEnv("JWT_SECRET", "your-secret-key-change-in-production"),
-		JWTExpiration:     getEnv("JWT_EXPIRATION", "15m"),
-		RefreshExpiration: getEnv("REFRESH_EXPIRATION", "168h"),
-		GoogleClientID:    getEnv("GOOGLE_CLIENT_ID", ""),
-		GoogleSecret:      getEnv("GOOGLE_CLIENT_SECRET", ""),
-		GoogleRedirectURL: getEnv("GOOGLE_REDIRECT_URL", ""),
-		AppURL:            getEnv("APP_URL", "http://localhost:8080"),
-	}
-}
-
-func getEnv(key, defaultValue string) string {
-	if value := os.Getenv(key); value != "" {
-		return value
-	}
-	return defaultValue
-}
+package config
+
+import (
+	"errors"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"control-financiero/internal/auth"
+)
+
+type Config struct {
+	Env               string
+	Port              string
+	MongoURI          string
+	MongoDB           string
+	JWTSecret         string // firma el state de OAuth; los JWT usan JWTKeys
+	JWTExpiration     string
+	JWTAlgorithm      string // algoritmo de las claves que se generan: RS256 o EdDSA
+	JWTKeysDir        string
+	JWTKeyRotation    time.Duration
+	JWTKeys           *auth.KeySet // se carga al iniciar con LoadJWTKeys
+	RefreshExpiration string
+	GoogleClientID    string
+	GoogleSecret      string
+	GoogleRedirectURL string
+	AppURL            string
+	TrustedProxies    []string
+	OIDCProviders     []auth.ProviderConfig
+	MailDriver        string
+	MailFrom          string
+	MailDir           string
+	SMTPHost          string
+	SMTPPort          string
+	SMTPUser          string
+	SMTPPassword      string
+	ExchangeRatesFile string        // CSV de tipos de cambio que se carga al iniciar
+	RecurringInterval time.Duration // cada cuánto se registran las transacciones recurrentes; 0 lo desactiva
+}
+
+// DefaultJWTSecret es el valor de JWT_SECRET cuando no se configura. Solo
+// sirve para desarrollo.
+const DefaultJWTSecret = "your-secret-key-change-in-production"
+
+func Load() *Config {
+	cfg := &Config{
+		Env:               getEnv("ENV", "development"),
+		Port:              getEnv("PORT", "8080"),
+		MongoURI:          getEnv("MONGO_URI", "mongodb://localhost:27017"),
+		MongoDB:           getEnv("MONGO_DB", "control_financiero"),
+		JWTSecret:         getEnv("JWT_SECRET", DefaultJWTSecret),
+		JWTExpiration:     getEnv("JWT_EXPIRATION", "15m"),
+		JWTAlgorithm:      getEnv("JWT_ALGORITHM", auth.AlgRS256),
+		JWTKeysDir:        getEnv("JWT_KEYS_DIR", "keys"),
+		JWTKeyRotation:    getDuration("JWT_KEY_ROTATION", 30*24*time.Hour),
+		RefreshExpiration: getEnv("REFRESH_EXPIRATION", "168h"),
+		GoogleClientID:    getEnv("GOOGLE_CLIENT_ID", ""),
+		GoogleSecret:      getEnv("GOOGLE_CLIENT_SECRET", ""),
+		GoogleRedirectURL: getEnv("GOOGLE_REDIRECT_URL", ""),
+		AppURL:            getEnv("APP_URL", "http://localhost:8080"),
+		TrustedProxies:    strings.FieldsFunc(getEnv("TRUSTED_PROXIES", ""), func(r rune) bool { return r == ',' || r == ' ' }),
+		MailDriver:        getEnv("MAIL_DRIVER", "console"),
+		MailFrom:          getEnv("MAIL_FROM", "Control Financiero <no-reply@localhost>"),
+		MailDir:           getEnv("MAIL_DIR", "tmp/mails"),
+		SMTPHost:          getEnv("SMTP_HOST", "localhost"),
+		SMTPPort:          getEnv("SMTP_PORT", "587"),
+		SMTPUser:          getEnv("SMTP_USER", ""),
+		SMTPPassword:      getEnv("SMTP_PASSWORD", ""),
+		ExchangeRatesFile: getEnv("EXCHANGE_RATES_FILE", ""),
+		RecurringInterval: getDuration("RECURRENCIAS_INTERVALO", time.Hour),
+	}
+	cfg.OIDCProviders = loadOIDCProviders(cfg)
+	return cfg
+}
+
+// Validate rechaza configuraciones inseguras para producción.
+func (c *Config) Validate() error {
+	if c.Env == "production" && (c.JWTSecret == DefaultJWTSecret || len(c.JWTSecret) < 32) {
+		return errors.New("JWT_SECRET debe configurarse con al menos 32 caracteres en producción")
+	}
+	return nil
+}
+
+// LoadJWTKeys carga (o genera) las claves con las que se firman los access
+// tokens.
+func (c *Config) LoadJWTKeys() error {
+	keys, err := auth.LoadKeySet(c.JWTKeysDir, c.JWTAlgorithm, c.JWTKeyRotation)
+	if err != nil {
+		return err
+	}
+	c.JWTKeys = keys
+	return nil
+}
+
+// loadOIDCProviders arma la lista de proveedores OIDC. Google se configura con
+// las variables GOOGLE_*; el resto se declara en OIDC_PROVIDERS (por ejemplo
+// "keycloak,authentik") y cada uno lee sus variables OIDC_<NOMBRE>_*.
+func loadOIDCProviders(cfg *Config) []auth.ProviderConfig {
+	var providers []auth.ProviderConfig
+
+	if cfg.GoogleClientID != "" {
+		redirectURL := cfg.GoogleRedirectURL
+		if redirectURL == "" {
+			redirectURL = cfg.AppURL + "/api/v1/auth/google/callback"
+		}
+		providers = append(providers, auth.GoogleProvider(cfg.GoogleClientID, cfg.GoogleSecret, redirectURL))
+	}
+
+	for _, name := range strings.Split(getEnv("OIDC_PROVIDERS", ""), ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+
+		prefix := "OIDC_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_")) + "_"
+		providers = append(providers, auth.ProviderConfig{
+			Name:         name,
+			DisplayName:  getEnv(prefix+"DISPLAY_NAME", name),
+			Issuer:       getEnv(prefix+"ISSUER", ""),
+			ClientID:     getEnv(prefix+"CLIENT_ID", ""),
+			ClientSecret: getEnv(prefix+"CLIENT_SECRET", ""),
+			RedirectURL:  getEnv(prefix+"REDIRECT_URL", cfg.AppURL+"/api/v1/auth/"+name+"/callback"),
+			Scopes:       strings.Fields(getEnv(prefix+"SCOPES", "openid email profile")),
+			Claims: auth.ClaimMapping{
+				Subject:       getEnv(prefix+"CLAIM_SUBJECT", ""),
+				Email:         getEnv(prefix+"CLAIM_EMAIL", ""),
+				EmailVerified: getEnv(prefix+"CLAIM_EMAIL_VERIFIED", ""),
+				Name:          getEnv(prefix+"CLAIM_NAME", ""),
+				Picture:       getEnv(prefix+"CLAIM_PICTURE", ""),
+			},
+		})
+	}
+
+	return providers
+}
+
+func getEnv(key, defaultValue string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return defaultValue
+}
+
+func getDuration(key string, defaultValue time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		log.Printf("⚠️  %s inválido (%q), se usa %s\n", key, value, defaultValue)
+		return defaultValue
+	}
+	return d
+}
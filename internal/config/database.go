@@ -1,124 +1,641 @@
-package config
-
-import (
-	"context"
-	"log"
-
-	"go.mongodb.org/mongo-driver/bson"
-	"go.mongodb.org/mongo-driver/mongo"
-	"go.mongodb.org/mongo-driver/mongo/options"
-)
-
-func ConnectMongoDB(ctx context.Context, cfg *Config) (*mongo.Client, error) {
-	clientOptions := options.Client().ApplyURI(cfg.MongoURI)
-	client, err := mongo.Connect(ctx, clientOptions)
-	if err != nil {
-		return nil, err
-	}
-
-	// Verificar conexión
-	if err := client.Ping(ctx, nil); err != nil {
-		return nil, err
-	}
-
-	return client, nil
-}
-
-func InitializeCollections(client *mongo.Client, cfg *Config) error {
-	db := client.Database(cfg.MongoDB)
-
-	// Crear índices para usuarios
-	usersCollection := db.Collection("usuarios")
-	_, err := usersCollection.Indexes().CreateMany(context.Background(), []mongo.IndexModel{
-		{
-			Keys:    bson.D{{Key: "email", Value: 1}},
-			Options: options.Index().SetUnique(true),
-		},
-		{
-			Keys: bson.D{{Key: "googleId", Value: 1}},
-			Options: options.Index().SetUnique(true).SetSparse(true),
-		},
-	})
-	if err != nil {
-		return err
-	}
-
-	// Crear índices para transacciones
-	transaccionesCollection := db.Collection("transacciones")
-	_, err = transaccionesCollection.Indexes().CreateMany(context.Background(), []mongo.IndexModel{
-		{
-			Keys: bson.D{{Key: "usuarioId", Value: 1}, {Key: "fecha", Value: -1}},
-		},
-	})
-	if err != nil {
-		return err
-	}
-
-	// Crear índices para refresh tokens
-	refreshTokensCollection := db.Collection("refresh_tokens")
-	_, err = refreshTokensCollection.Indexes().CreateMany(context.Background(), []mongo.IndexModel{
-		{
-			Keys: bson.D{{Key: "token", Value: 1}},
-		},
-		{
-			Keys:    bson.D{{Key: "expiresAt", Value: 1}},
-			Options: options.Index().SetExpireAfterSeconds(0),
-		},
-	})
-	if err != nil {
-		return err
-	}
-
-	// Inicializar roles por defecto
-	rolesCollection := db.Collection("roles")
-	count, err := rolesCollection.CountDocuments(context.Background(), bson.M{})
-	if err != nil {
-		return err
-	}
-
-	if count == 0 {
-		roles := []interface{}{
-			bson.M{
-				"nombre":   "admin",
-				"permisos": []string{"all"},
-			},
-			bson.M{
-				"nombre":   "user",
-				"permisos": []string{"read", "write"},
-			},
-		}
-		_, err = rolesCollection.InsertMany(context.Background(), roles)
-		if err != nil {
-			return err
-		}
-		log.Println("✅ Roles por defecto creados")
-	}
-
-	// Crear categorías por defecto
-	categoriasCollection := db.Collection("categorias")
-	count, err = categoriasCollection.CountDocuments(context.Background(), bson.M{})
-	if err != nil {
-		return err
-	}
-
-	if count == 0 {
-		categorias := []interface{}{
-			bson.M{"nombre": "Salario", "tipo": "ingreso", "color": "#10b981", "usuarioId": nil},
-			bson.M{"nombre": "Freelance", "tipo": "ingreso", "color": "#3b82f6", "usuarioId": nil},
-			bson.M{"nombre": "Alimentación", "tipo": "egreso", "color": "#ef4444", "usuarioId": nil},
-			bson.M{"nombre": "Transporte", "tipo": "egreso", "color": "#f59e0b", "usuarioId": nil},
-			bson.M{"nombre": "Entretenimiento", "tipo": "egreso", "color": "#8b5cf6", "usuarioId": nil},
-			bson.M{"nombre": "Servicios", "tipo": "egreso", "color": "#ec4899", "usuarioId": nil},
-			bson.M{"nombre": "Alquiler", "tipo": "egreso", "color": "#6366f1", "usuarioId": nil},
-			bson.M{"nombre": "Préstamo", "tipo": "ambos", "color": "#14b8a6", "usuarioId": nil},
-		}
-		_, err = categoriasCollection.InsertMany(context.Background(), categorias)
-		if err != nil {
-			return err
-		}
-		log.Println("✅ Categorías por defecto creadas")
-	}
-
-	log.Println("✅ Colecciones e índices inicializados")
-	return nil
-}
+package config
+
+import (
+	"context"
+	"log"
+	"strings"
+	"time"
+
+	"control-financiero/internal/auth"
+	"control-financiero/internal/money"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func ConnectMongoDB(ctx context.Context, cfg *Config) (*mongo.Client, error) {
+	clientOptions := options.Client().ApplyURI(cfg.MongoURI)
+	client, err := mongo.Connect(ctx, clientOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	// Verificar conexión
+	if err := client.Ping(ctx, nil); err != nil {
+		return nil, err
+	}
+
+	return client, nil
+}
+
+func InitializeCollections(client *mongo.Client, cfg *Config) error {
+	db := client.Database(cfg.MongoDB)
+
+	// Crear índices para usuarios
+	usersCollection := db.Collection("usuarios")
+	if err := migrateGoogleIdentities(context.Background(), usersCollection); err != nil {
+		return err
+	}
+	_, err := usersCollection.Indexes().CreateMany(context.Background(), []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "email", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			// Una identidad externa solo puede pertenecer a un usuario
+			Keys: bson.D{{Key: "identidades.provider", Value: 1}, {Key: "identidades.subject", Value: 1}},
+			Options: options.Index().SetUnique(true).
+				SetPartialFilterExpression(bson.M{"identidades.subject": bson.M{"$exists": true}}),
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	// Crear índices para transacciones: uno por cada forma de consulta del
+	// listado, con el filtro de igualdad, el campo de orden y _id para
+	// desempatar el cursor
+	transaccionesCollection := db.Collection("transacciones")
+	if err := migrateMontosDecimal(context.Background(), transaccionesCollection); err != nil {
+		return err
+	}
+	if err := migrateMonedas(context.Background(), transaccionesCollection); err != nil {
+		return err
+	}
+	if err := migrateCuentas(context.Background(), db); err != nil {
+		return err
+	}
+	// Reemplazados por usuarioId_1_fecha_-1__id_-1 y por el índice de
+	// cuentaId; pueden no existir
+	_, _ = transaccionesCollection.Indexes().DropOne(context.Background(), "usuarioId_1_fecha_-1")
+	_, _ = transaccionesCollection.Indexes().DropOne(context.Background(), "usuarioId_1_cuenta.id_1_fecha_-1")
+	_, err = transaccionesCollection.Indexes().CreateMany(context.Background(), []mongo.IndexModel{
+		{
+			Keys: bson.D{{Key: "usuarioId", Value: 1}, {Key: "fecha", Value: -1}, {Key: "_id", Value: -1}},
+		},
+		{
+			Keys: bson.D{{Key: "usuarioId", Value: 1}, {Key: "monto", Value: -1}, {Key: "_id", Value: -1}},
+		},
+		{
+			Keys: bson.D{{Key: "usuarioId", Value: 1}, {Key: "tipo", Value: 1}, {Key: "fecha", Value: -1}},
+		},
+		{
+			Keys: bson.D{{Key: "usuarioId", Value: 1}, {Key: "categoriaId", Value: 1}, {Key: "fecha", Value: -1}},
+		},
+		{
+			Keys: bson.D{{Key: "usuarioId", Value: 1}, {Key: "divisiones.categoriaId", Value: 1}, {Key: "fecha", Value: -1}},
+		},
+		{
+			// El extracto de una cuenta recorre sus transacciones en orden
+			// cronológico
+			Keys: bson.D{{Key: "usuarioId", Value: 1}, {Key: "cuentaId", Value: 1}, {Key: "fecha", Value: 1}, {Key: "_id", Value: 1}},
+		},
+		{
+			Keys: bson.D{{Key: "usuarioId", Value: 1}, {Key: "tags", Value: 1}, {Key: "fecha", Value: -1}},
+		},
+		{
+			// Para encontrar la otra pata de una transferencia
+			Keys:    bson.D{{Key: "transferencia.id", Value: 1}},
+			Options: options.Index().SetPartialFilterExpression(bson.M{"transferencia": bson.M{"$exists": true}}),
+		},
+		{
+			// Cada ocurrencia de una recurrencia se registra una sola vez,
+			// aunque el programador corra en varias réplicas
+			Keys: bson.D{{Key: "recurrencia.id", Value: 1}, {Key: "recurrencia.fecha", Value: 1}},
+			Options: options.Index().SetUnique(true).
+				SetPartialFilterExpression(bson.M{"recurrencia": bson.M{"$exists": true}}),
+		},
+		{
+			// Pagos y desembolsos de un préstamo
+			Keys:    bson.D{{Key: "usuarioId", Value: 1}, {Key: "prestamo.id", Value: 1}, {Key: "prestamo.cuota", Value: 1}},
+			Options: options.Index().SetPartialFilterExpression(bson.M{"prestamo": bson.M{"$exists": true}}),
+		},
+		{
+			// Cobros de un contrato de alquiler
+			Keys:    bson.D{{Key: "usuarioId", Value: 1}, {Key: "contrato.id", Value: 1}},
+			Options: options.Index().SetPartialFilterExpression(bson.M{"contrato": bson.M{"$exists": true}}),
+		},
+		{
+			// Estado de resultados de una propiedad
+			Keys:    bson.D{{Key: "usuarioId", Value: 1}, {Key: "propiedadId", Value: 1}, {Key: "fecha", Value: -1}},
+			Options: options.Index().SetPartialFilterExpression(bson.M{"propiedadId": bson.M{"$exists": true}}),
+		},
+		{
+			// Deshacer una importación
+			Keys:    bson.D{{Key: "usuarioId", Value: 1}, {Key: "importacionId", Value: 1}},
+			Options: options.Index().SetPartialFilterExpression(bson.M{"importacionId": bson.M{"$exists": true}}),
+		},
+		{
+			// Las importaciones descartan las referencias que ya están en la
+			// cuenta
+			Keys:    bson.D{{Key: "usuarioId", Value: 1}, {Key: "cuentaId", Value: 1}, {Key: "referencia", Value: 1}},
+			Options: options.Index().SetPartialFilterExpression(bson.M{"referencia": bson.M{"$exists": true}}),
+		},
+		{
+			Keys: bson.D{{Key: "usuarioId", Value: 1}, {Key: "metodoPago", Value: 1}, {Key: "fecha", Value: -1}},
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	// Crear índices para cuentas
+	cuentasCollection := db.Collection("cuentas")
+	_, err = cuentasCollection.Indexes().CreateMany(context.Background(), []mongo.IndexModel{
+		{
+			Keys: bson.D{{Key: "usuarioId", Value: 1}, {Key: "nombre", Value: 1}},
+		},
+		{
+			// Los extractos OFX se importan en la cuenta con su número
+			Keys:    bson.D{{Key: "usuarioId", Value: 1}, {Key: "numeroCuenta", Value: 1}},
+			Options: options.Index().SetPartialFilterExpression(bson.M{"numeroCuenta": bson.M{"$exists": true}}),
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	// Crear índices para préstamos
+	prestamosCollection := db.Collection("prestamos")
+	_, err = prestamosCollection.Indexes().CreateOne(context.Background(), mongo.IndexModel{
+		Keys: bson.D{{Key: "usuarioId", Value: 1}, {Key: "nombre", Value: 1}},
+	})
+	if err != nil {
+		return err
+	}
+
+	// Crear índices para propiedades e inquilinos
+	for _, coleccion := range []string{"propiedades", "inquilinos"} {
+		_, err = db.Collection(coleccion).Indexes().CreateOne(context.Background(), mongo.IndexModel{
+			Keys: bson.D{{Key: "usuarioId", Value: 1}, {Key: "nombre", Value: 1}},
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	// Crear índices para importaciones y sus perfiles
+	_, err = db.Collection("importaciones").Indexes().CreateOne(context.Background(), mongo.IndexModel{
+		Keys: bson.D{{Key: "usuarioId", Value: 1}, {Key: "createdAt", Value: -1}},
+	})
+	if err != nil {
+		return err
+	}
+	_, err = db.Collection("perfiles_importacion").Indexes().CreateOne(context.Background(), mongo.IndexModel{
+		Keys: bson.D{{Key: "usuarioId", Value: 1}, {Key: "nombre", Value: 1}},
+	})
+	if err != nil {
+		return err
+	}
+
+	// Crear índices para contratos
+	contratosCollection := db.Collection("contratos")
+	_, err = contratosCollection.Indexes().CreateMany(context.Background(), []mongo.IndexModel{
+		{
+			Keys: bson.D{{Key: "usuarioId", Value: 1}, {Key: "propiedadId", Value: 1}},
+		},
+		{
+			Keys: bson.D{{Key: "usuarioId", Value: 1}, {Key: "inquilinoId", Value: 1}},
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	// Crear índices para recurrencias
+	recurrenciasCollection := db.Collection("recurrencias")
+	_, err = recurrenciasCollection.Indexes().CreateMany(context.Background(), []mongo.IndexModel{
+		{
+			Keys: bson.D{{Key: "usuarioId", Value: 1}, {Key: "nombre", Value: 1}},
+		},
+		{
+			// El programador busca las que tienen ocurrencias vencidas
+			Keys:    bson.D{{Key: "proxima", Value: 1}},
+			Options: options.Index().SetSparse(true),
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	// Crear índices para refresh tokens
+	refreshTokensCollection := db.Collection("refresh_tokens")
+	if err := migrateRefreshTokens(context.Background(), refreshTokensCollection); err != nil {
+		return err
+	}
+	_, err = refreshTokensCollection.Indexes().CreateMany(context.Background(), []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "tokenHash", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys: bson.D{{Key: "familyId", Value: 1}},
+		},
+		{
+			Keys: bson.D{{Key: "usuarioId", Value: 1}},
+		},
+		{
+			Keys:    bson.D{{Key: "expiresAt", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(0),
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	// Los códigos de intercambio OAuth expiran solos
+	oauthCodesCollection := db.Collection("oauth_codes")
+	_, err = oauthCodesCollection.Indexes().CreateMany(context.Background(), []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "codeHash", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys:    bson.D{{Key: "expiresAt", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(0),
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	// Crear índices para los challenges de 2FA
+	loginChallengesCollection := db.Collection("login_challenges")
+	_, err = loginChallengesCollection.Indexes().CreateMany(context.Background(), []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "tokenHash", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys:    bson.D{{Key: "expiresAt", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(0),
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	// Crear índices para los tokens enviados por correo
+	emailTokensCollection := db.Collection("email_tokens")
+	_, err = emailTokensCollection.Indexes().CreateMany(context.Background(), []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "tokenHash", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys: bson.D{{Key: "usuarioId", Value: 1}, {Key: "tipo", Value: 1}},
+		},
+		{
+			Keys:    bson.D{{Key: "expiresAt", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(0),
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	// Los contadores de intentos fallidos expiran solos
+	loginAttemptsCollection := db.Collection("login_attempts")
+	_, err = loginAttemptsCollection.Indexes().CreateOne(context.Background(), mongo.IndexModel{
+		Keys:    bson.D{{Key: "expiresAt", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	})
+	if err != nil {
+		return err
+	}
+
+	// Crear índices para los tokens de acceso personal
+	patCollection := db.Collection("personal_access_tokens")
+	_, err = patCollection.Indexes().CreateMany(context.Background(), []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "tokenHash", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys: bson.D{{Key: "usuarioId", Value: 1}, {Key: "revoked", Value: 1}},
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	// Índices del registro de auditoría: por actor, por afectado y por acción
+	auditLogsCollection := db.Collection("audit_logs")
+	_, err = auditLogsCollection.Indexes().CreateMany(context.Background(), []mongo.IndexModel{
+		{Keys: bson.D{{Key: "createdAt", Value: -1}}},
+		{Keys: bson.D{{Key: "usuarioId", Value: 1}, {Key: "createdAt", Value: -1}}},
+		{Keys: bson.D{{Key: "afectadoId", Value: 1}, {Key: "createdAt", Value: -1}}},
+		{Keys: bson.D{{Key: "accion", Value: 1}, {Key: "createdAt", Value: -1}}},
+	})
+	if err != nil {
+		return err
+	}
+
+	// Un tipo de cambio por par de monedas y día; la búsqueda del vigente
+	// recorre el índice hacia atrás desde la fecha pedida
+	exchangeRatesCollection := db.Collection("exchange_rates")
+	_, err = exchangeRatesCollection.Indexes().CreateOne(context.Background(), mongo.IndexModel{
+		Keys:    bson.D{{Key: "moneda", Value: 1}, {Key: "base", Value: 1}, {Key: "fecha", Value: -1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		return err
+	}
+
+	// Inicializar roles por defecto
+	rolesCollection := db.Collection("roles")
+	count, err := rolesCollection.CountDocuments(context.Background(), bson.M{})
+	if err != nil {
+		return err
+	}
+
+	if count == 0 {
+		roles := []interface{}{
+			bson.M{
+				"nombre":      "admin",
+				"descripcion": "Acceso total",
+				"permisos":    []string{auth.PermAll},
+			},
+			bson.M{
+				"nombre":      "user",
+				"descripcion": "Gestión de sus propias finanzas",
+				"permisos":    auth.DefaultUserPermissions,
+			},
+		}
+		_, err = rolesCollection.InsertMany(context.Background(), roles)
+		if err != nil {
+			return err
+		}
+		log.Println("✅ Roles por defecto creados")
+	}
+
+	// Los permisos genéricos "read"/"write" de versiones anteriores no
+	// distinguen recursos; se reemplazan por los permisos por defecto
+	result, err := rolesCollection.UpdateOne(context.Background(),
+		bson.M{"nombre": "user", "permisos": bson.M{"$in": []string{"read", "write"}}},
+		bson.M{"$set": bson.M{"permisos": auth.DefaultUserPermissions}},
+	)
+	if err != nil {
+		return err
+	}
+	if result.ModifiedCount > 0 {
+		log.Println("✅ Permisos del rol user migrados")
+	}
+
+	_, err = rolesCollection.Indexes().CreateOne(context.Background(), mongo.IndexModel{
+		Keys:    bson.D{{Key: "nombre", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		return err
+	}
+
+	// Crear categorías por defecto
+	categoriasCollection := db.Collection("categorias")
+	count, err = categoriasCollection.CountDocuments(context.Background(), bson.M{})
+	if err != nil {
+		return err
+	}
+
+	if count == 0 {
+		categorias := []interface{}{
+			bson.M{"nombre": "Salario", "tipo": "ingreso", "color": "#10b981", "usuarioId": nil},
+			bson.M{"nombre": "Freelance", "tipo": "ingreso", "color": "#3b82f6", "usuarioId": nil},
+			bson.M{"nombre": "Alimentación", "tipo": "egreso", "color": "#ef4444", "usuarioId": nil},
+			bson.M{"nombre": "Transporte", "tipo": "egreso", "color": "#f59e0b", "usuarioId": nil},
+			bson.M{"nombre": "Entretenimiento", "tipo": "egreso", "color": "#8b5cf6", "usuarioId": nil},
+			bson.M{"nombre": "Servicios", "tipo": "egreso", "color": "#ec4899", "usuarioId": nil},
+			bson.M{"nombre": "Alquiler", "tipo": "egreso", "color": "#6366f1", "usuarioId": nil},
+			bson.M{"nombre": "Préstamo", "tipo": "ambos", "color": "#14b8a6", "usuarioId": nil},
+		}
+		_, err = categoriasCollection.InsertMany(context.Background(), categorias)
+		if err != nil {
+			return err
+		}
+		log.Println("✅ Categorías por defecto creadas")
+	}
+
+	log.Println("✅ Colecciones e índices inicializados")
+	return nil
+}
+
+// migrateGoogleIdentities convierte el antiguo campo googleId en una identidad
+// del proveedor "google".
+func migrateGoogleIdentities(ctx context.Context, collection *mongo.Collection) error {
+	result, err := collection.UpdateMany(ctx,
+		bson.M{"googleId": bson.M{"$exists": true}},
+		mongo.Pipeline{
+			{{Key: "$set", Value: bson.M{"identidades": bson.M{"$concatArrays": bson.A{
+				bson.M{"$ifNull": bson.A{"$identidades", bson.A{}}},
+				bson.A{bson.M{"provider": "google", "subject": "$googleId", "email": "$email", "linkedAt": "$updatedAt"}},
+			}}}}},
+			{{Key: "$unset", Value: "googleId"}},
+		},
+	)
+	if err != nil {
+		return err
+	}
+
+	if result.ModifiedCount > 0 {
+		log.Printf("✅ %d usuarios de Google migrados a identidades\n", result.ModifiedCount)
+	}
+
+	// El índice sobre googleId ya no se usa; puede no existir
+	_, _ = collection.Indexes().DropOne(ctx, "googleId_1")
+	return nil
+}
+
+// migrateMontosDecimal convierte a Decimal128 los montos guardados como
+// double o entero, redondeados a los decimales que conserva money.Amount.
+func migrateMontosDecimal(ctx context.Context, collection *mongo.Collection) error {
+	result, err := collection.UpdateMany(ctx,
+		bson.M{"monto": bson.M{"$type": bson.A{"double", "int", "long"}}},
+		mongo.Pipeline{{{Key: "$set", Value: bson.M{
+			"monto": bson.M{"$toDecimal": bson.M{"$round": bson.A{"$monto", money.Scale}}},
+		}}}},
+	)
+	if err != nil {
+		return err
+	}
+
+	if result.ModifiedCount > 0 {
+		log.Printf("✅ %d montos de transacciones migrados a Decimal128\n", result.ModifiedCount)
+	}
+	return nil
+}
+
+// migrateMonedas pasa a mayúsculas los códigos de moneda guardados antes de
+// validarlos, para que coincidan con los de los tipos de cambio.
+func migrateMonedas(ctx context.Context, collection *mongo.Collection) error {
+	result, err := collection.UpdateMany(ctx,
+		bson.M{"moneda": bson.M{"$regex": "[a-z]"}},
+		mongo.Pipeline{{{Key: "$set", Value: bson.M{
+			"moneda": bson.M{"$toUpper": bson.M{"$trim": bson.M{"input": "$moneda"}}},
+		}}}},
+	)
+	if err != nil {
+		return err
+	}
+
+	if result.ModifiedCount > 0 {
+		log.Printf("✅ %d monedas de transacciones pasadas a mayúsculas\n", result.ModifiedCount)
+	}
+	return nil
+}
+
+// migrateCuentas convierte la cuenta que antes se guardaba dentro de cada
+// transacción ({id, nombre}) en un documento de la colección cuentas, una por
+// usuario, nombre y moneda, y deja en la transacción solo su cuentaId.
+func migrateCuentas(ctx context.Context, db *mongo.Database) error {
+	transacciones := db.Collection("transacciones")
+	cursor, err := transacciones.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"cuenta": bson.M{"$exists": true}}}},
+		{{Key: "$group", Value: bson.M{
+			"_id":       bson.M{"usuarioId": "$usuarioId", "nombre": "$cuenta.nombre", "moneda": "$moneda"},
+			"createdAt": bson.M{"$min": "$createdAt"},
+		}}},
+		{{Key: "$lookup", Value: bson.M{
+			"from":         "usuarios",
+			"localField":   "_id.usuarioId",
+			"foreignField": "_id",
+			"as":           "usuario",
+		}}},
+		{{Key: "$set", Value: bson.M{"monedaBase": bson.M{"$first": "$usuario.monedaBase"}}}},
+	})
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	var grupos []struct {
+		ID struct {
+			UsuarioID primitive.ObjectID `bson:"usuarioId"`
+			Nombre    string             `bson:"nombre"`
+			Moneda    string             `bson:"moneda"`
+		} `bson:"_id"`
+		MonedaBase string    `bson:"monedaBase"`
+		CreatedAt  time.Time `bson:"createdAt"`
+	}
+	if err := cursor.All(ctx, &grupos); err != nil {
+		return err
+	}
+
+	// Un mismo nombre con varias monedas da una cuenta por moneda
+	monedasPorNombre := make(map[string]int)
+	for _, g := range grupos {
+		monedasPorNombre[g.ID.UsuarioID.Hex()+g.ID.Nombre]++
+	}
+
+	cuentas := db.Collection("cuentas")
+	migradas := 0
+	for _, g := range grupos {
+		// Las transacciones sin moneda están en la moneda base del usuario
+		moneda := g.ID.Moneda
+		if moneda == "" {
+			moneda = g.MonedaBase
+		}
+		if !money.ValidCurrency(moneda) {
+			moneda = money.DefaultCurrency
+		}
+		nombre := strings.TrimSpace(g.ID.Nombre)
+		if nombre == "" {
+			nombre = "Cuenta"
+		}
+		if monedasPorNombre[g.ID.UsuarioID.Hex()+g.ID.Nombre] > 1 {
+			nombre += " (" + moneda + ")"
+		}
+
+		// Upsert para poder repetir la migración si se interrumpe
+		var cuenta struct {
+			ID primitive.ObjectID `bson:"_id"`
+		}
+		err := cuentas.FindOneAndUpdate(ctx,
+			bson.M{"usuarioId": g.ID.UsuarioID, "nombre": nombre, "moneda": moneda},
+			bson.M{"$setOnInsert": bson.M{
+				"tipo":         "banco",
+				"saldoInicial": primitive.NewDecimal128(0, 0),
+				"archivada":    false,
+				"createdAt":    g.CreatedAt,
+				"updatedAt":    time.Now(),
+			}},
+			options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After),
+		).Decode(&cuenta)
+		if err != nil {
+			return err
+		}
+
+		result, err := transacciones.UpdateMany(ctx,
+			bson.M{
+				"usuarioId":     g.ID.UsuarioID,
+				"cuenta":        bson.M{"$exists": true}, // sin él, un nombre vacío también elige las que nunca tuvieron cuenta
+				"cuenta.nombre": vacioONulo(g.ID.Nombre),
+				"moneda":        vacioONulo(g.ID.Moneda),
+			},
+			bson.M{"$set": bson.M{"cuentaId": cuenta.ID}, "$unset": bson.M{"cuenta": ""}},
+		)
+		if err != nil {
+			return err
+		}
+		migradas += int(result.ModifiedCount)
+	}
+
+	if migradas > 0 {
+		log.Printf("✅ %d transacciones migradas a %d cuentas\n", migradas, len(grupos))
+	}
+	return nil
+}
+
+// vacioONulo filtra por valor o, si está vacío, por cadena vacía, null o
+// campo ausente, que $group agrupa juntos.
+func vacioONulo(valor string) interface{} {
+	if valor == "" {
+		return bson.M{"$in": bson.A{"", nil}}
+	}
+	return valor
+}
+
+// migrateRefreshTokens reemplaza los refresh tokens guardados en texto plano
+// por su hash, usando el propio documento como familia de rotación.
+func migrateRefreshTokens(ctx context.Context, collection *mongo.Collection) error {
+	cursor, err := collection.Find(ctx, bson.M{"token": bson.M{"$exists": true}})
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	migrated := 0
+	for cursor.Next(ctx) {
+		var doc struct {
+			ID    primitive.ObjectID `bson:"_id"`
+			Token string             `bson:"token"`
+		}
+		if err := cursor.Decode(&doc); err != nil {
+			return err
+		}
+
+		_, err := collection.UpdateOne(ctx, bson.M{"_id": doc.ID}, bson.M{
+			"$set":   bson.M{"tokenHash": auth.HashRefreshToken(doc.Token), "familyId": doc.ID},
+			"$unset": bson.M{"token": ""},
+		})
+		if err != nil {
+			return err
+		}
+		migrated++
+	}
+	if err := cursor.Err(); err != nil {
+		return err
+	}
+
+	if migrated > 0 {
+		log.Printf("✅ %d refresh tokens migrados a hash\n", migrated)
+	}
+
+	// Los tokens anteriores al registro de sesiones toman su fecha de creación
+	_, err = collection.UpdateMany(ctx,
+		bson.M{"startedAt": bson.M{"$exists": false}},
+		mongo.Pipeline{{{Key: "$set", Value: bson.M{"startedAt": "$createdAt", "lastUsedAt": "$createdAt"}}}},
+	)
+	return err
+}
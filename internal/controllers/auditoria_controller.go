@@ -0,0 +1,99 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+
+	"control-financiero/internal/middleware"
+	"control-financiero/internal/models"
+	"control-financiero/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type AuditoriaController struct {
+	auditService *services.AuditService
+}
+
+func NewAuditoriaController(db *mongo.Database) *AuditoriaController {
+	return &AuditoriaController{
+		auditService: services.NewAuditService(db),
+	}
+}
+
+// GetAll lista el registro de todos los usuarios, opcionalmente filtrado por
+// usuarioId.
+func (c *AuditoriaController) GetAll(ctx *gin.Context) {
+	var query models.AuditLogQuery
+	if err := ctx.ShouldBindQuery(&query); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	filtro, err := auditLogFilter(&query)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if query.UsuarioID != "" {
+		id, err := primitive.ObjectIDFromHex(query.UsuarioID)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "ID inválido"})
+			return
+		}
+		filtro.UsuarioID = &id
+	}
+
+	c.search(ctx, filtro)
+}
+
+// GetProfile lista las acciones hechas por el usuario autenticado o sobre su
+// cuenta. El parámetro usuarioId se ignora.
+func (c *AuditoriaController) GetProfile(ctx *gin.Context) {
+	userID, _ := middleware.GetUserID(ctx)
+
+	var query models.AuditLogQuery
+	if err := ctx.ShouldBindQuery(&query); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	filtro, err := auditLogFilter(&query)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	filtro.UsuarioID = &userID
+
+	c.search(ctx, filtro)
+}
+
+func (c *AuditoriaController) search(ctx *gin.Context, filtro models.AuditLogFilter) {
+	entries, err := c.auditService.Search(context.Background(), filtro)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, entries)
+}
+
+// auditLogFilter convierte los parámetros comunes de la consulta.
+func auditLogFilter(query *models.AuditLogQuery) (models.AuditLogFilter, error) {
+	filtro := models.AuditLogFilter{
+		Accion: query.Accion,
+		Limite: query.Limite,
+		Pagina: query.Pagina,
+	}
+
+	desde, hasta, err := parseRangoFechas(query.Desde, query.Hasta)
+	if err != nil {
+		return filtro, err
+	}
+	filtro.Desde, filtro.Hasta = desde, hasta
+
+	return filtro, nil
+}
@@ -1,151 +1,333 @@
-package controllers
-
-import (
-	"context"
-	"fmt"
-	"net/http"
-	"time"
-
-	"control-financiero/internal/auth"
-	"control-financiero/internal/config"
-	"control-financiero/internal/models"
-	"control-financiero/internal/services"
-
-	"github.com/gin-gonic/gin"
-	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo"
-)
-
-type AuthController struct {
-	authService *services.AuthService
-	cfg         *config.Config
-}
-
-func NewAuthController(db *mongo.Database, cfg *config.Config) *AuthController {
-	return &AuthController{
-		authService: services.NewAuthService(db, cfg),
-		cfg:         cfg,
-	}
-}
-
-func (c *AuthController) Register(ctx *gin.Context) {
-	var req models.RegisterRequest
-	if err := ctx.ShouldBindJSON(&req); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
-	}
-
-	usuario, err := c.authService.Register(context.Background(), &req)
-	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
-	}
-
-	ctx.JSON(http.StatusCreated, gin.H{
-		"mensaje": "Usuario registrado. Pendiente de aprobación por administrador",
-		"usuario": usuario,
-	})
-}
-
-func (c *AuthController) Login(ctx *gin.Context) {
-	var req models.LoginRequest
-	if err := ctx.ShouldBindJSON(&req); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
-	}
-
-	response, err := c.authService.Login(context.Background(), &req)
-	if err != nil {
-		ctx.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
-		return
-	}
-
-	ctx.JSON(http.StatusOK, response)
-}
-
-func (c *AuthController) GoogleAuthURL(ctx *gin.Context) {
-	state := fmt.Sprintf("state-%d", time.Now().Unix())
-	url := auth.GetGoogleAuthURL(state)
-
-	ctx.JSON(http.StatusOK, gin.H{
-		"url": url,
-	})
-}
-
-func (c *AuthController) GoogleCallback(ctx *gin.Context) {
-	code := ctx.Query("code")
-	if code == "" {
-		ctx.Redirect(http.StatusFound, c.cfg.AppURL+"?error=no_code")
-		return
-	}
-
-	// Intercambiar código por token
-	token, err := auth.ExchangeGoogleCode(code)
-	if err != nil {
-		ctx.Redirect(http.StatusFound, c.cfg.AppURL+"?error=exchange_failed")
-		return
-	}
-
-	// Obtener información del usuario
-	userInfo, err := auth.GetGoogleUserInfo(token)
-	if err != nil {
-		ctx.Redirect(http.StatusFound, c.cfg.AppURL+"?error=userinfo_failed")
-		return
-	}
-
-	// Login o registro con Google
-	response, err := c.authService.LoginWithGoogle(
-		context.Background(),
-		userInfo.ID,
-		userInfo.Email,
-		userInfo.Name,
-		userInfo.Picture,
-	)
-	if err != nil {
-		ctx.Redirect(http.StatusFound, c.cfg.AppURL+"?error="+err.Error())
-		return
-	}
-
-	// Redirigir al frontend con tokens
-	redirectURL := fmt.Sprintf("%s?access_token=%s&refresh_token=%s",
-		c.cfg.AppURL,
-		response.AccessToken,
-		response.RefreshToken,
-	)
-	ctx.Redirect(http.StatusFound, redirectURL)
-}
-
-func (c *AuthController) RefreshToken(ctx *gin.Context) {
-	var req models.RefreshRequest
-	if err := ctx.ShouldBindJSON(&req); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
-	}
-
-	accessToken, err := c.authService.RefreshToken(context.Background(), req.RefreshToken)
-	if err != nil {
-		ctx.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
-		return
-	}
-
-	ctx.JSON(http.StatusOK, gin.H{
-		"accessToken": accessToken,
-	})
-}
-
-func (c *AuthController) ChangePassword(ctx *gin.Context) {
-	userID := ctx.MustGet("userId").(primitive.ObjectID)
-
-	var req models.ChangePasswordRequest
-	if err := ctx.ShouldBindJSON(&req); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
-	}
-
-	if err := c.authService.ChangePassword(context.Background(), userID, &req); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
-	}
-
-	ctx.JSON(http.StatusOK, gin.H{"mensaje": "Contraseña actualizada correctamente"})
-}
+package controllers
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"control-financiero/internal/auth"
+	"control-financiero/internal/config"
+	"control-financiero/internal/middleware"
+	"control-financiero/internal/models"
+	"control-financiero/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type AuthController struct {
+	authService *services.AuthService
+	providers   *auth.Registry
+	cfg         *config.Config
+}
+
+func NewAuthController(db *mongo.Database, cfg *config.Config) *AuthController {
+	return &AuthController{
+		authService: services.NewAuthService(db, cfg),
+		providers:   auth.NewRegistry(cfg.OIDCProviders),
+		cfg:         cfg,
+	}
+}
+
+func (c *AuthController) Register(ctx *gin.Context) {
+	var req models.RegisterRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	usuario, err := c.authService.Register(context.Background(), &req, clientInfo(ctx))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, gin.H{
+		"mensaje": "Usuario registrado. Pendiente de aprobación por administrador",
+		"usuario": usuario,
+	})
+}
+
+func (c *AuthController) Login(ctx *gin.Context) {
+	var req models.LoginRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	response, challenge, err := c.authService.Login(context.Background(), &req, clientInfo(ctx))
+	if err != nil {
+		respondAuthError(ctx, http.StatusUnauthorized, err)
+		return
+	}
+
+	if challenge != nil {
+		ctx.JSON(http.StatusOK, challenge)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, response)
+}
+
+func (c *AuthController) LoginTwoFactor(ctx *gin.Context) {
+	var req models.TwoFactorLoginRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	response, err := c.authService.LoginTwoFactor(context.Background(), &req, clientInfo(ctx))
+	if err != nil {
+		respondAuthError(ctx, http.StatusUnauthorized, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, response)
+}
+
+// oauthStateCookie guarda el state firmado, el nonce y el verifier PKCE entre
+// el inicio del flujo con el proveedor y el callback.
+const (
+	oauthStateCookie = "oauth_state"
+	oauthStateTTL    = 10 * time.Minute
+)
+
+func (c *AuthController) Providers(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, c.providers.List())
+}
+
+func (c *AuthController) ProviderAuthURL(ctx *gin.Context) {
+	provider, ok := c.providers.Get(ctx.Param("provider"))
+	if !ok {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Proveedor no configurado"})
+		return
+	}
+
+	state, err := auth.NewOAuthState(provider.Name(), oauthStateTTL)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	authURL, err := provider.AuthCodeURL(ctx.Request.Context(), state.State, state.Verifier, state.Nonce)
+	if err != nil {
+		ctx.JSON(http.StatusBadGateway, gin.H{"error": "Proveedor no disponible"})
+		return
+	}
+
+	ctx.SetSameSite(http.SameSiteLaxMode)
+	ctx.SetCookie(oauthStateCookie, state.Encode(c.cfg.JWTSecret), int(oauthStateTTL.Seconds()),
+		"/api/v1/auth", "", c.cfg.Env == "production", true)
+
+	ctx.Redirect(http.StatusFound, authURL)
+}
+
+func (c *AuthController) ProviderCallback(ctx *gin.Context) {
+	// El state solo sirve una vez
+	cookie, _ := ctx.Cookie(oauthStateCookie)
+	ctx.SetSameSite(http.SameSiteLaxMode)
+	ctx.SetCookie(oauthStateCookie, "", -1, "/api/v1/auth", "", c.cfg.Env == "production", true)
+
+	provider, ok := c.providers.Get(ctx.Param("provider"))
+	if !ok {
+		c.redirectWithError(ctx, "unknown_provider")
+		return
+	}
+
+	state, err := auth.DecodeOAuthState(cookie, c.cfg.JWTSecret)
+	if err != nil || !state.Matches(provider.Name(), ctx.Query("state")) {
+		c.redirectWithError(ctx, "invalid_state")
+		return
+	}
+
+	code := ctx.Query("code")
+	if code == "" {
+		c.redirectWithError(ctx, "no_code")
+		return
+	}
+
+	// Intercambiar el código y validar el ID token
+	userInfo, err := provider.Exchange(ctx.Request.Context(), code, state.Verifier, state.Nonce)
+	if err != nil {
+		c.redirectWithError(ctx, "exchange_failed")
+		return
+	}
+
+	// Login o registro con la identidad del proveedor
+	exchangeCode, err := c.authService.LoginWithProvider(context.Background(), userInfo, clientInfo(ctx))
+	if err != nil {
+		c.redirectWithError(ctx, err.Error())
+		return
+	}
+
+	// El fragmento no se envía al servidor ni queda en los logs de acceso
+	ctx.Redirect(http.StatusFound, c.cfg.AppURL+"/#code="+url.QueryEscape(exchangeCode))
+}
+
+func (c *AuthController) ExchangeCode(ctx *gin.Context) {
+	var req models.ExchangeCodeRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	response, challenge, err := c.authService.ExchangeCode(context.Background(), req.Code, clientInfo(ctx))
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	if challenge != nil {
+		ctx.JSON(http.StatusOK, challenge)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, response)
+}
+
+func (c *AuthController) redirectWithError(ctx *gin.Context, reason string) {
+	ctx.Redirect(http.StatusFound, c.cfg.AppURL+"?error="+url.QueryEscape(reason))
+}
+
+func (c *AuthController) RefreshToken(ctx *gin.Context) {
+	var req models.RefreshRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	response, err := c.authService.RefreshToken(context.Background(), req.RefreshToken, clientInfo(ctx))
+	if err != nil {
+		respondAuthError(ctx, http.StatusUnauthorized, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, response)
+}
+
+func (c *AuthController) ForgotPassword(ctx *gin.Context) {
+	var req models.ForgotPasswordRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := c.authService.ForgotPassword(context.Background(), req.Email, clientInfo(ctx)); err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Misma respuesta exista o no la cuenta
+	ctx.JSON(http.StatusOK, gin.H{"mensaje": "Si el correo está registrado, recibirás un enlace para restablecer la contraseña"})
+}
+
+func (c *AuthController) ResetPassword(ctx *gin.Context) {
+	var req models.ResetPasswordRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := c.authService.ResetPassword(context.Background(), &req, clientInfo(ctx)); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"mensaje": "Contraseña restablecida correctamente"})
+}
+
+// VerifyEmail se abre desde el enlace del correo, así que responde con una
+// redirección al frontend en lugar de JSON.
+func (c *AuthController) VerifyEmail(ctx *gin.Context) {
+	token := ctx.Query("token")
+	if token == "" {
+		c.redirectWithError(ctx, "invalid_token")
+		return
+	}
+
+	if err := c.authService.VerifyEmail(context.Background(), token, clientInfo(ctx)); err != nil {
+		c.redirectWithError(ctx, "invalid_token")
+		return
+	}
+
+	ctx.Redirect(http.StatusFound, c.cfg.AppURL+"/?email_verificado=1")
+}
+
+func (c *AuthController) ChangePassword(ctx *gin.Context) {
+	userID := ctx.MustGet("userId").(primitive.ObjectID)
+
+	var req models.ChangePasswordRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := c.authService.ChangePassword(context.Background(), userID, &req, clientInfo(ctx)); err != nil {
+		respondAuthError(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"mensaje": "Contraseña actualizada correctamente"})
+}
+
+func (c *AuthController) Logout(ctx *gin.Context) {
+	var req models.RefreshRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := c.authService.Logout(context.Background(), req.RefreshToken, clientInfo(ctx)); err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"mensaje": "Sesión cerrada correctamente"})
+}
+
+func (c *AuthController) LogoutAll(ctx *gin.Context) {
+	userID, _ := middleware.GetUserID(ctx)
+
+	if err := c.authService.LogoutAll(context.Background(), userID, clientInfo(ctx)); err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"mensaje": "Todas las sesiones fueron cerradas"})
+}
+
+// JWKS publica las claves públicas vigentes para que otros servicios puedan
+// verificar los access tokens.
+func (c *AuthController) JWKS(ctx *gin.Context) {
+	ctx.Header("Cache-Control", "public, max-age=300")
+	ctx.JSON(http.StatusOK, c.cfg.JWTKeys.JWKS())
+}
+
+// respondAuthError responde 429 con Retry-After cuando se superó el límite de
+// intentos y con status en cualquier otro caso.
+func respondAuthError(ctx *gin.Context, status int, err error) {
+	var rateLimit *services.RateLimitError
+	if errors.As(err, &rateLimit) {
+		ctx.Header("Retry-After", strconv.Itoa(int(math.Ceil(rateLimit.RetryAfter.Seconds()))))
+		ctx.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(status, gin.H{"error": err.Error()})
+}
+
+// clientInfo extrae el usuario autenticado (si lo hay), la IP y el
+// User-Agent de la petición.
+func clientInfo(ctx *gin.Context) models.ClientInfo {
+	userID, _ := middleware.GetUserID(ctx)
+	return models.ClientInfo{
+		UsuarioID: userID,
+		IP:        ctx.ClientIP(),
+		UserAgent: ctx.Request.UserAgent(),
+	}
+}
@@ -0,0 +1,159 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"control-financiero/internal/middleware"
+	"control-financiero/internal/models"
+	"control-financiero/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type ContratoController struct {
+	contratoService *services.ContratoService
+}
+
+func NewContratoController(db *mongo.Database) *ContratoController {
+	return &ContratoController{
+		contratoService: services.NewContratoService(db),
+	}
+}
+
+func (c *ContratoController) Create(ctx *gin.Context) {
+	userID, _ := middleware.GetUserID(ctx)
+
+	var contrato models.Contrato
+	if err := ctx.ShouldBindJSON(&contrato); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	contrato.UsuarioID = userID
+
+	if err := c.contratoService.Create(context.Background(), &contrato, clientInfo(ctx)); err != nil {
+		respondContratoError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, contrato)
+}
+
+func (c *ContratoController) GetAll(ctx *gin.Context) {
+	userID, _ := middleware.GetUserID(ctx)
+
+	var query models.ContratoQuery
+	if err := ctx.ShouldBindQuery(&query); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	var propiedadID, inquilinoID *primitive.ObjectID
+	if query.PropiedadID != "" {
+		id, err := primitive.ObjectIDFromHex(query.PropiedadID)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "propiedadId inválido"})
+			return
+		}
+		propiedadID = &id
+	}
+	if query.InquilinoID != "" {
+		id, err := primitive.ObjectIDFromHex(query.InquilinoID)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "inquilinoId inválido"})
+			return
+		}
+		inquilinoID = &id
+	}
+
+	contratos, err := c.contratoService.GetAll(context.Background(), userID, propiedadID, inquilinoID)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, contratos)
+}
+
+func (c *ContratoController) GetByID(ctx *gin.Context) {
+	userID, _ := middleware.GetUserID(ctx)
+
+	idParam := ctx.Param("id")
+	id, err := primitive.ObjectIDFromHex(idParam)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "ID inválido"})
+		return
+	}
+
+	contrato, err := c.contratoService.GetByID(context.Background(), id, userID)
+	if err != nil {
+		respondContratoError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, contrato)
+}
+
+func (c *ContratoController) Update(ctx *gin.Context) {
+	userID, _ := middleware.GetUserID(ctx)
+
+	idParam := ctx.Param("id")
+	id, err := primitive.ObjectIDFromHex(idParam)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "ID inválido"})
+		return
+	}
+
+	var contrato models.Contrato
+	if err := ctx.ShouldBindJSON(&contrato); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	contrato.ID = id
+	contrato.UsuarioID = userID
+
+	if err := c.contratoService.Update(context.Background(), &contrato, clientInfo(ctx)); err != nil {
+		respondContratoError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, contrato)
+}
+
+func (c *ContratoController) Delete(ctx *gin.Context) {
+	userID, _ := middleware.GetUserID(ctx)
+
+	idParam := ctx.Param("id")
+	id, err := primitive.ObjectIDFromHex(idParam)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "ID inválido"})
+		return
+	}
+
+	if err := c.contratoService.Delete(context.Background(), id, userID, clientInfo(ctx)); err != nil {
+		respondContratoError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"mensaje": "Contrato eliminado correctamente"})
+}
+
+func respondContratoError(ctx *gin.Context, err error) {
+	if errors.Is(err, services.ErrNotFound) {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Contrato no encontrado"})
+		return
+	}
+	if errors.Is(err, services.ErrInvalidContrato) || errors.Is(err, services.ErrInvalidMonto) || errors.Is(err, services.ErrInvalidMoneda) {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if errors.Is(err, services.ErrContratoEnUso) || errors.Is(err, services.ErrUnidadOcupada) {
+		ctx.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+}
@@ -0,0 +1,202 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"control-financiero/internal/middleware"
+	"control-financiero/internal/models"
+	"control-financiero/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type CuentaController struct {
+	cuentaService *services.CuentaService
+}
+
+func NewCuentaController(db *mongo.Database) *CuentaController {
+	return &CuentaController{
+		cuentaService: services.NewCuentaService(db),
+	}
+}
+
+func (c *CuentaController) Create(ctx *gin.Context) {
+	userID, _ := middleware.GetUserID(ctx)
+
+	var cuenta models.Cuenta
+	if err := ctx.ShouldBindJSON(&cuenta); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	cuenta.UsuarioID = userID
+
+	if err := c.cuentaService.Create(context.Background(), &cuenta, clientInfo(ctx)); err != nil {
+		respondCuentaError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, cuenta)
+}
+
+// GetAll lista las cuentas del usuario con su saldo. Las archivadas solo se
+// incluyen con ?archivadas=true.
+func (c *CuentaController) GetAll(ctx *gin.Context) {
+	userID, _ := middleware.GetUserID(ctx)
+
+	cuentas, err := c.cuentaService.GetAll(context.Background(), userID, ctx.Query("archivadas") == "true")
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, cuentas)
+}
+
+func (c *CuentaController) GetByID(ctx *gin.Context) {
+	userID, _ := middleware.GetUserID(ctx)
+
+	idParam := ctx.Param("id")
+	id, err := primitive.ObjectIDFromHex(idParam)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "ID inválido"})
+		return
+	}
+
+	cuenta, err := c.cuentaService.GetByID(context.Background(), id, userID)
+	if err != nil {
+		respondCuentaError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, cuenta)
+}
+
+func (c *CuentaController) Update(ctx *gin.Context) {
+	userID, _ := middleware.GetUserID(ctx)
+
+	idParam := ctx.Param("id")
+	id, err := primitive.ObjectIDFromHex(idParam)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "ID inválido"})
+		return
+	}
+
+	var cuenta models.Cuenta
+	if err := ctx.ShouldBindJSON(&cuenta); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	cuenta.ID = id
+	cuenta.UsuarioID = userID
+
+	if err := c.cuentaService.Update(context.Background(), &cuenta, clientInfo(ctx)); err != nil {
+		respondCuentaError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, cuenta)
+}
+
+func (c *CuentaController) Delete(ctx *gin.Context) {
+	userID, _ := middleware.GetUserID(ctx)
+
+	idParam := ctx.Param("id")
+	id, err := primitive.ObjectIDFromHex(idParam)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "ID inválido"})
+		return
+	}
+
+	if err := c.cuentaService.Delete(context.Background(), id, userID, clientInfo(ctx)); err != nil {
+		respondCuentaError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"mensaje": "Cuenta eliminada correctamente"})
+}
+
+// GetExtracto lista los movimientos de la cuenta en orden cronológico con el
+// saldo acumulado, por páginas.
+func (c *CuentaController) GetExtracto(ctx *gin.Context) {
+	userID, _ := middleware.GetUserID(ctx)
+
+	idParam := ctx.Param("id")
+	id, err := primitive.ObjectIDFromHex(idParam)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "ID inválido"})
+		return
+	}
+
+	var query models.ExtractoQuery
+	if err := ctx.ShouldBindQuery(&query); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	desde, hasta, err := parseRangoFechas(query.Desde, query.Hasta)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	extracto, err := c.cuentaService.Extracto(context.Background(), id, userID, desde, hasta, query.Limite, query.Cursor)
+	if err != nil {
+		respondCuentaError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, extracto)
+}
+
+// GetHistorial devuelve el saldo de la cuenta al cierre de cada día o mes
+// con movimientos.
+func (c *CuentaController) GetHistorial(ctx *gin.Context) {
+	userID, _ := middleware.GetUserID(ctx)
+
+	idParam := ctx.Param("id")
+	id, err := primitive.ObjectIDFromHex(idParam)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "ID inválido"})
+		return
+	}
+
+	var query models.HistorialQuery
+	if err := ctx.ShouldBindQuery(&query); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	desde, hasta, err := parseRangoFechas(query.Desde, query.Hasta)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	historial, err := c.cuentaService.Historial(context.Background(), id, userID, desde, hasta, query.Intervalo)
+	if err != nil {
+		respondCuentaError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, historial)
+}
+
+func respondCuentaError(ctx *gin.Context, err error) {
+	if errors.Is(err, services.ErrNotFound) {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Cuenta no encontrada"})
+		return
+	}
+	if errors.Is(err, services.ErrInvalidMoneda) || errors.Is(err, services.ErrInvalidCursor) {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if errors.Is(err, services.ErrCuentaEnUso) {
+		ctx.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+}
@@ -0,0 +1,124 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"control-financiero/internal/models"
+	"control-financiero/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Tamaño máximo del CSV de tipos de cambio
+const exchangeRatesCSVMaxBytes = 5 << 20
+
+type ExchangeRateController struct {
+	rateService *services.ExchangeRateService
+}
+
+func NewExchangeRateController(db *mongo.Database) *ExchangeRateController {
+	return &ExchangeRateController{
+		rateService: services.NewExchangeRateService(db),
+	}
+}
+
+// GetAll lista los tipos de cambio cargados, los más recientes primero.
+func (c *ExchangeRateController) GetAll(ctx *gin.Context) {
+	var query models.ExchangeRateQuery
+	if err := ctx.ShouldBindQuery(&query); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	desde, hasta, err := parseRangoFechas(query.Desde, query.Hasta)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	rates, err := c.rateService.List(context.Background(), &models.ExchangeRateFilter{
+		Moneda: query.Moneda,
+		Base:   query.Base,
+		Desde:  desde,
+		Hasta:  hasta,
+	})
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, rates)
+}
+
+// Create guarda una lista de tipos de cambio enviada como JSON.
+func (c *ExchangeRateController) Create(ctx *gin.Context) {
+	var req []models.ExchangeRateRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if len(req) == 0 {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Debe enviar al menos un tipo de cambio"})
+		return
+	}
+
+	rates := make([]*models.ExchangeRate, 0, len(req))
+	for i, r := range req {
+		fecha, _, err := parseFechaConsulta(r.Fecha)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("fecha inválida en el elemento %d", i+1)})
+			return
+		}
+		rates = append(rates, &models.ExchangeRate{Moneda: r.Moneda, Base: r.Base, Fecha: fecha, Tasa: r.Tasa})
+	}
+
+	if err := c.rateService.Save(context.Background(), rates, "api", clientInfo(ctx)); err != nil {
+		respondExchangeRateError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, gin.H{"mensaje": "Tipos de cambio guardados correctamente", "cantidad": len(rates)})
+}
+
+// ImportCSV carga tipos de cambio desde un CSV enviado como cuerpo de la
+// petición o como el archivo "archivo" de un formulario multipart.
+func (c *ExchangeRateController) ImportCSV(ctx *gin.Context) {
+	ctx.Request.Body = http.MaxBytesReader(ctx.Writer, ctx.Request.Body, exchangeRatesCSVMaxBytes)
+
+	var body io.Reader = ctx.Request.Body
+	if ctx.ContentType() == "multipart/form-data" {
+		file, err := ctx.FormFile("archivo")
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "Falta el archivo CSV"})
+			return
+		}
+		f, err := file.Open()
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		defer f.Close()
+		body = f
+	}
+
+	cantidad, err := c.rateService.ImportCSV(context.Background(), body, clientInfo(ctx))
+	if err != nil {
+		respondExchangeRateError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, gin.H{"mensaje": "Tipos de cambio importados correctamente", "cantidad": cantidad})
+}
+
+func respondExchangeRateError(ctx *gin.Context, err error) {
+	if errors.Is(err, services.ErrInvalidExchangeRate) {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+}
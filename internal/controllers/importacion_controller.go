@@ -0,0 +1,265 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"control-financiero/internal/middleware"
+	"control-financiero/internal/models"
+	"control-financiero/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Tamaño máximo del archivo de una importación
+const importacionMaxBytes = 10 << 20
+
+type ImportacionController struct {
+	importacionService *services.ImportacionService
+}
+
+func NewImportacionController(db *mongo.Database) *ImportacionController {
+	return &ImportacionController{
+		importacionService: services.NewImportacionService(db),
+	}
+}
+
+// Importar lee el extracto, en CSV u OFX, enviado como el archivo "archivo"
+// de un formulario multipart y devuelve la vista previa de sus filas. Con
+// confirmar=true además importa las filas válidas.
+func (c *ImportacionController) Importar(ctx *gin.Context) {
+	userID, _ := middleware.GetUserID(ctx)
+	ctx.Request.Body = http.MaxBytesReader(ctx.Writer, ctx.Request.Body, importacionMaxBytes)
+
+	var req models.ImportacionRequest
+	if err := ctx.ShouldBind(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	file, err := ctx.FormFile("archivo")
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Falta el archivo a importar"})
+		return
+	}
+	f, err := file.Open()
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	defer f.Close()
+	data, err := io.ReadAll(f)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	opciones, err := opcionesImportacion(&req, file.Filename)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	resultado, err := c.importacionService.Importar(context.Background(), userID, data, opciones, clientInfo(ctx))
+	if err != nil {
+		respondImportacionError(ctx, err)
+		return
+	}
+
+	if resultado.Importacion != nil {
+		ctx.JSON(http.StatusCreated, resultado)
+		return
+	}
+	ctx.JSON(http.StatusOK, resultado)
+}
+
+func (c *ImportacionController) GetAll(ctx *gin.Context) {
+	userID, _ := middleware.GetUserID(ctx)
+
+	importaciones, err := c.importacionService.GetAll(context.Background(), userID)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, importaciones)
+}
+
+// Deshacer elimina una importación y todas sus transacciones.
+func (c *ImportacionController) Deshacer(ctx *gin.Context) {
+	userID, _ := middleware.GetUserID(ctx)
+
+	idParam := ctx.Param("id")
+	id, err := primitive.ObjectIDFromHex(idParam)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "ID inválido"})
+		return
+	}
+
+	eliminadas, err := c.importacionService.Deshacer(context.Background(), id, userID, clientInfo(ctx))
+	if err != nil {
+		respondImportacionError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"mensaje": "Importación deshecha correctamente", "eliminadas": eliminadas})
+}
+
+func (c *ImportacionController) CreatePerfil(ctx *gin.Context) {
+	userID, _ := middleware.GetUserID(ctx)
+
+	var perfil models.PerfilImportacion
+	if err := ctx.ShouldBindJSON(&perfil); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	perfil.UsuarioID = userID
+
+	if err := c.importacionService.CreatePerfil(context.Background(), &perfil, clientInfo(ctx)); err != nil {
+		respondPerfilImportacionError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, perfil)
+}
+
+func (c *ImportacionController) GetPerfiles(ctx *gin.Context) {
+	userID, _ := middleware.GetUserID(ctx)
+
+	perfiles, err := c.importacionService.GetPerfiles(context.Background(), userID)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, perfiles)
+}
+
+func (c *ImportacionController) GetPerfil(ctx *gin.Context) {
+	userID, _ := middleware.GetUserID(ctx)
+
+	idParam := ctx.Param("id")
+	id, err := primitive.ObjectIDFromHex(idParam)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "ID inválido"})
+		return
+	}
+
+	perfil, err := c.importacionService.GetPerfil(context.Background(), id, userID)
+	if err != nil {
+		respondPerfilImportacionError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, perfil)
+}
+
+func (c *ImportacionController) UpdatePerfil(ctx *gin.Context) {
+	userID, _ := middleware.GetUserID(ctx)
+
+	idParam := ctx.Param("id")
+	id, err := primitive.ObjectIDFromHex(idParam)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "ID inválido"})
+		return
+	}
+
+	var perfil models.PerfilImportacion
+	if err := ctx.ShouldBindJSON(&perfil); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	perfil.ID = id
+	perfil.UsuarioID = userID
+
+	if err := c.importacionService.UpdatePerfil(context.Background(), &perfil, clientInfo(ctx)); err != nil {
+		respondPerfilImportacionError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, perfil)
+}
+
+func (c *ImportacionController) DeletePerfil(ctx *gin.Context) {
+	userID, _ := middleware.GetUserID(ctx)
+
+	idParam := ctx.Param("id")
+	id, err := primitive.ObjectIDFromHex(idParam)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "ID inválido"})
+		return
+	}
+
+	if err := c.importacionService.DeletePerfil(context.Background(), id, userID, clientInfo(ctx)); err != nil {
+		respondPerfilImportacionError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"mensaje": "Perfil eliminado correctamente"})
+}
+
+// opcionesImportacion valida los campos del formulario de importación.
+func opcionesImportacion(req *models.ImportacionRequest, archivo string) (*models.OpcionesImportacion, error) {
+	opciones := &models.OpcionesImportacion{
+		Archivo:   archivo,
+		Confirmar: req.Confirmar,
+		Omitir:    req.Omitir,
+	}
+
+	ids := []struct {
+		valor   string
+		campo   string
+		destino **primitive.ObjectID
+	}{
+		{req.CuentaID, "cuentaId", &opciones.CuentaID},
+		{req.PerfilID, "perfilId", &opciones.PerfilID},
+		{req.CategoriaIngresoID, "categoriaIngresoId", &opciones.CategoriaIngresoID},
+		{req.CategoriaEgresoID, "categoriaEgresoId", &opciones.CategoriaEgresoID},
+	}
+	for _, id := range ids {
+		if id.valor == "" {
+			continue
+		}
+		oid, err := primitive.ObjectIDFromHex(id.valor)
+		if err != nil {
+			return nil, fmt.Errorf("%s inválido", id.campo)
+		}
+		*id.destino = &oid
+	}
+
+	if req.Mapeo != "" {
+		var mapeo models.MapeoImportacion
+		if err := json.Unmarshal([]byte(req.Mapeo), &mapeo); err != nil {
+			return nil, fmt.Errorf("mapeo inválido: %v", err)
+		}
+		opciones.Mapeo = &mapeo
+	}
+	return opciones, nil
+}
+
+func respondImportacionError(ctx *gin.Context, err error) {
+	if errors.Is(err, services.ErrNotFound) {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Importación no encontrada"})
+		return
+	}
+	if errors.Is(err, services.ErrInvalidImportacion) || errors.Is(err, services.ErrInvalidCuenta) {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+}
+
+func respondPerfilImportacionError(ctx *gin.Context, err error) {
+	if errors.Is(err, services.ErrNotFound) {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Perfil no encontrado"})
+		return
+	}
+	respondImportacionError(ctx, err)
+}
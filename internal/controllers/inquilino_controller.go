@@ -0,0 +1,136 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"control-financiero/internal/middleware"
+	"control-financiero/internal/models"
+	"control-financiero/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type InquilinoController struct {
+	inquilinoService *services.InquilinoService
+}
+
+func NewInquilinoController(db *mongo.Database) *InquilinoController {
+	return &InquilinoController{
+		inquilinoService: services.NewInquilinoService(db),
+	}
+}
+
+func (c *InquilinoController) Create(ctx *gin.Context) {
+	userID, _ := middleware.GetUserID(ctx)
+
+	var inquilino models.Inquilino
+	if err := ctx.ShouldBindJSON(&inquilino); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	inquilino.UsuarioID = userID
+
+	if err := c.inquilinoService.Create(context.Background(), &inquilino, clientInfo(ctx)); err != nil {
+		respondInquilinoError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, inquilino)
+}
+
+func (c *InquilinoController) GetAll(ctx *gin.Context) {
+	userID, _ := middleware.GetUserID(ctx)
+
+	inquilinos, err := c.inquilinoService.GetAll(context.Background(), userID)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, inquilinos)
+}
+
+func (c *InquilinoController) GetByID(ctx *gin.Context) {
+	userID, _ := middleware.GetUserID(ctx)
+
+	idParam := ctx.Param("id")
+	id, err := primitive.ObjectIDFromHex(idParam)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "ID inválido"})
+		return
+	}
+
+	inquilino, err := c.inquilinoService.GetByID(context.Background(), id, userID)
+	if err != nil {
+		respondInquilinoError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, inquilino)
+}
+
+func (c *InquilinoController) Update(ctx *gin.Context) {
+	userID, _ := middleware.GetUserID(ctx)
+
+	idParam := ctx.Param("id")
+	id, err := primitive.ObjectIDFromHex(idParam)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "ID inválido"})
+		return
+	}
+
+	var inquilino models.Inquilino
+	if err := ctx.ShouldBindJSON(&inquilino); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	inquilino.ID = id
+	inquilino.UsuarioID = userID
+
+	if err := c.inquilinoService.Update(context.Background(), &inquilino, clientInfo(ctx)); err != nil {
+		respondInquilinoError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, inquilino)
+}
+
+func (c *InquilinoController) Delete(ctx *gin.Context) {
+	userID, _ := middleware.GetUserID(ctx)
+
+	idParam := ctx.Param("id")
+	id, err := primitive.ObjectIDFromHex(idParam)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "ID inválido"})
+		return
+	}
+
+	if err := c.inquilinoService.Delete(context.Background(), id, userID, clientInfo(ctx)); err != nil {
+		respondInquilinoError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"mensaje": "Inquilino eliminado correctamente"})
+}
+
+func respondInquilinoError(ctx *gin.Context, err error) {
+	if errors.Is(err, services.ErrNotFound) {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Inquilino no encontrado"})
+		return
+	}
+	if errors.Is(err, services.ErrInvalidInquilino) {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if errors.Is(err, services.ErrInquilinoEnUso) {
+		ctx.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+}
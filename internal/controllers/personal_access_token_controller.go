@@ -0,0 +1,76 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"control-financiero/internal/middleware"
+	"control-financiero/internal/models"
+	"control-financiero/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type PersonalAccessTokenController struct {
+	tokenService *services.PersonalAccessTokenService
+}
+
+func NewPersonalAccessTokenController(db *mongo.Database) *PersonalAccessTokenController {
+	return &PersonalAccessTokenController{
+		tokenService: services.NewPersonalAccessTokenService(db),
+	}
+}
+
+func (c *PersonalAccessTokenController) GetAll(ctx *gin.Context) {
+	userID, _ := middleware.GetUserID(ctx)
+
+	tokens, err := c.tokenService.GetByUsuario(context.Background(), userID)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, tokens)
+}
+
+func (c *PersonalAccessTokenController) Create(ctx *gin.Context) {
+	userID, _ := middleware.GetUserID(ctx)
+
+	var req models.PersonalAccessTokenRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	response, err := c.tokenService.Create(context.Background(), userID, ctx.GetStringSlice("userPermisos"), &req, clientInfo(ctx))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, response)
+}
+
+func (c *PersonalAccessTokenController) Delete(ctx *gin.Context) {
+	userID, _ := middleware.GetUserID(ctx)
+
+	id, err := primitive.ObjectIDFromHex(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "ID inválido"})
+		return
+	}
+
+	if err := c.tokenService.Revoke(context.Background(), userID, id, clientInfo(ctx)); err != nil {
+		if errors.Is(err, services.ErrNotFound) {
+			ctx.JSON(http.StatusNotFound, gin.H{"error": "Token no encontrado"})
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"mensaje": "Token revocado correctamente"})
+}
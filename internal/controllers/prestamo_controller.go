@@ -0,0 +1,136 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"control-financiero/internal/middleware"
+	"control-financiero/internal/models"
+	"control-financiero/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type PrestamoController struct {
+	prestamoService *services.PrestamoService
+}
+
+func NewPrestamoController(db *mongo.Database) *PrestamoController {
+	return &PrestamoController{
+		prestamoService: services.NewPrestamoService(db),
+	}
+}
+
+func (c *PrestamoController) Create(ctx *gin.Context) {
+	userID, _ := middleware.GetUserID(ctx)
+
+	var prestamo models.Prestamo
+	if err := ctx.ShouldBindJSON(&prestamo); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	prestamo.UsuarioID = userID
+
+	if err := c.prestamoService.Create(context.Background(), &prestamo, clientInfo(ctx)); err != nil {
+		respondPrestamoError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, prestamo)
+}
+
+func (c *PrestamoController) GetAll(ctx *gin.Context) {
+	userID, _ := middleware.GetUserID(ctx)
+
+	prestamos, err := c.prestamoService.GetAll(context.Background(), userID)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, prestamos)
+}
+
+func (c *PrestamoController) GetByID(ctx *gin.Context) {
+	userID, _ := middleware.GetUserID(ctx)
+
+	idParam := ctx.Param("id")
+	id, err := primitive.ObjectIDFromHex(idParam)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "ID inválido"})
+		return
+	}
+
+	prestamo, err := c.prestamoService.GetByID(context.Background(), id, userID)
+	if err != nil {
+		respondPrestamoError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, prestamo)
+}
+
+func (c *PrestamoController) Update(ctx *gin.Context) {
+	userID, _ := middleware.GetUserID(ctx)
+
+	idParam := ctx.Param("id")
+	id, err := primitive.ObjectIDFromHex(idParam)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "ID inválido"})
+		return
+	}
+
+	var prestamo models.Prestamo
+	if err := ctx.ShouldBindJSON(&prestamo); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	prestamo.ID = id
+	prestamo.UsuarioID = userID
+
+	if err := c.prestamoService.Update(context.Background(), &prestamo, clientInfo(ctx)); err != nil {
+		respondPrestamoError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, prestamo)
+}
+
+func (c *PrestamoController) Delete(ctx *gin.Context) {
+	userID, _ := middleware.GetUserID(ctx)
+
+	idParam := ctx.Param("id")
+	id, err := primitive.ObjectIDFromHex(idParam)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "ID inválido"})
+		return
+	}
+
+	if err := c.prestamoService.Delete(context.Background(), id, userID, clientInfo(ctx)); err != nil {
+		respondPrestamoError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"mensaje": "Préstamo eliminado correctamente"})
+}
+
+func respondPrestamoError(ctx *gin.Context, err error) {
+	if errors.Is(err, services.ErrNotFound) {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Préstamo no encontrado"})
+		return
+	}
+	if errors.Is(err, services.ErrInvalidPrestamo) || errors.Is(err, services.ErrInvalidMonto) || errors.Is(err, services.ErrInvalidMoneda) {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if errors.Is(err, services.ErrPrestamoEnUso) {
+		ctx.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+}
@@ -0,0 +1,175 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"control-financiero/internal/middleware"
+	"control-financiero/internal/models"
+	"control-financiero/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type PropiedadController struct {
+	propiedadService *services.PropiedadService
+}
+
+func NewPropiedadController(db *mongo.Database) *PropiedadController {
+	return &PropiedadController{
+		propiedadService: services.NewPropiedadService(db),
+	}
+}
+
+func (c *PropiedadController) Create(ctx *gin.Context) {
+	userID, _ := middleware.GetUserID(ctx)
+
+	var propiedad models.Propiedad
+	if err := ctx.ShouldBindJSON(&propiedad); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	propiedad.UsuarioID = userID
+
+	if err := c.propiedadService.Create(context.Background(), &propiedad, clientInfo(ctx)); err != nil {
+		respondPropiedadError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, propiedad)
+}
+
+func (c *PropiedadController) GetAll(ctx *gin.Context) {
+	userID, _ := middleware.GetUserID(ctx)
+
+	propiedades, err := c.propiedadService.GetAll(context.Background(), userID)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, propiedades)
+}
+
+func (c *PropiedadController) GetByID(ctx *gin.Context) {
+	userID, _ := middleware.GetUserID(ctx)
+
+	idParam := ctx.Param("id")
+	id, err := primitive.ObjectIDFromHex(idParam)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "ID inválido"})
+		return
+	}
+
+	propiedad, err := c.propiedadService.GetByID(context.Background(), id, userID)
+	if err != nil {
+		respondPropiedadError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, propiedad)
+}
+
+func (c *PropiedadController) Update(ctx *gin.Context) {
+	userID, _ := middleware.GetUserID(ctx)
+
+	idParam := ctx.Param("id")
+	id, err := primitive.ObjectIDFromHex(idParam)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "ID inválido"})
+		return
+	}
+
+	var propiedad models.Propiedad
+	if err := ctx.ShouldBindJSON(&propiedad); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	propiedad.ID = id
+	propiedad.UsuarioID = userID
+
+	if err := c.propiedadService.Update(context.Background(), &propiedad, clientInfo(ctx)); err != nil {
+		respondPropiedadError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, propiedad)
+}
+
+func (c *PropiedadController) Delete(ctx *gin.Context) {
+	userID, _ := middleware.GetUserID(ctx)
+
+	idParam := ctx.Param("id")
+	id, err := primitive.ObjectIDFromHex(idParam)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "ID inválido"})
+		return
+	}
+
+	if err := c.propiedadService.Delete(context.Background(), id, userID, clientInfo(ctx)); err != nil {
+		respondPropiedadError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"mensaje": "Propiedad eliminada correctamente"})
+}
+
+// GetResultados devuelve el estado de resultados de la propiedad entre desde
+// y hasta: los alquileres cobrados, los otros ingresos y los egresos
+// asignados a ella.
+func (c *PropiedadController) GetResultados(ctx *gin.Context) {
+	userID, _ := middleware.GetUserID(ctx)
+
+	idParam := ctx.Param("id")
+	id, err := primitive.ObjectIDFromHex(idParam)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "ID inválido"})
+		return
+	}
+
+	var query models.ResultadosQuery
+	if err := ctx.ShouldBindQuery(&query); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	desde, hasta, err := parseRangoFechas(query.Desde, query.Hasta)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	resultados, err := c.propiedadService.Resultados(context.Background(), id, userID, desde, hasta)
+	if err != nil {
+		// Falta cargar el tipo de cambio de alguna transacción o cargo
+		var sinCambio *services.RateNotFoundError
+		if errors.As(err, &sinCambio) {
+			ctx.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+			return
+		}
+		respondPropiedadError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, resultados)
+}
+
+func respondPropiedadError(ctx *gin.Context, err error) {
+	if errors.Is(err, services.ErrNotFound) {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Propiedad no encontrada"})
+		return
+	}
+	if errors.Is(err, services.ErrInvalidPropiedad) {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if errors.Is(err, services.ErrPropiedadEnUso) {
+		ctx.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+}
@@ -0,0 +1,46 @@
+package controllers
+
+import (
+	"errors"
+	"time"
+)
+
+// parseRangoFechas convierte los parámetros desde y hasta de una consulta.
+// Los vacíos quedan en nil y una fecha sin hora en hasta incluye el día
+// completo.
+func parseRangoFechas(desdeParam, hastaParam string) (*time.Time, *time.Time, error) {
+	var desde, hasta *time.Time
+
+	if desdeParam != "" {
+		t, _, err := parseFechaConsulta(desdeParam)
+		if err != nil {
+			return nil, nil, errors.New("desde inválido")
+		}
+		desde = &t
+	}
+	if hastaParam != "" {
+		t, soloFecha, err := parseFechaConsulta(hastaParam)
+		if err != nil {
+			return nil, nil, errors.New("hasta inválido")
+		}
+		if soloFecha {
+			t = t.AddDate(0, 0, 1).Add(-time.Nanosecond)
+		}
+		hasta = &t
+	}
+	if desde != nil && hasta != nil && hasta.Before(*desde) {
+		return nil, nil, errors.New("hasta debe ser posterior a desde")
+	}
+
+	return desde, hasta, nil
+}
+
+// parseFechaConsulta acepta RFC 3339 o YYYY-MM-DD (en UTC) e indica cuál de
+// los dos formatos se usó.
+func parseFechaConsulta(value string) (time.Time, bool, error) {
+	if t, err := time.Parse(time.DateOnly, value); err == nil {
+		return t, true, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	return t, false, err
+}
@@ -0,0 +1,224 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"control-financiero/internal/middleware"
+	"control-financiero/internal/models"
+	"control-financiero/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type RecurrenciaController struct {
+	recurrenciaService *services.RecurrenciaService
+}
+
+func NewRecurrenciaController(db *mongo.Database) *RecurrenciaController {
+	return &RecurrenciaController{
+		recurrenciaService: services.NewRecurrenciaService(db),
+	}
+}
+
+func (c *RecurrenciaController) Create(ctx *gin.Context) {
+	userID, _ := middleware.GetUserID(ctx)
+
+	var recurrencia models.Recurrencia
+	if err := ctx.ShouldBindJSON(&recurrencia); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	recurrencia.UsuarioID = userID
+
+	if err := c.recurrenciaService.Create(context.Background(), &recurrencia, clientInfo(ctx)); err != nil {
+		respondRecurrenciaError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, recurrencia)
+}
+
+func (c *RecurrenciaController) GetAll(ctx *gin.Context) {
+	userID, _ := middleware.GetUserID(ctx)
+
+	recurrencias, err := c.recurrenciaService.GetAll(context.Background(), userID)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, recurrencias)
+}
+
+func (c *RecurrenciaController) GetByID(ctx *gin.Context) {
+	userID, _ := middleware.GetUserID(ctx)
+
+	idParam := ctx.Param("id")
+	id, err := primitive.ObjectIDFromHex(idParam)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "ID inválido"})
+		return
+	}
+
+	recurrencia, err := c.recurrenciaService.GetByID(context.Background(), id, userID)
+	if err != nil {
+		respondRecurrenciaError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, recurrencia)
+}
+
+func (c *RecurrenciaController) Update(ctx *gin.Context) {
+	userID, _ := middleware.GetUserID(ctx)
+
+	idParam := ctx.Param("id")
+	id, err := primitive.ObjectIDFromHex(idParam)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "ID inválido"})
+		return
+	}
+
+	var recurrencia models.Recurrencia
+	if err := ctx.ShouldBindJSON(&recurrencia); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	recurrencia.ID = id
+	recurrencia.UsuarioID = userID
+
+	if err := c.recurrenciaService.Update(context.Background(), &recurrencia, clientInfo(ctx)); err != nil {
+		respondRecurrenciaError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, recurrencia)
+}
+
+func (c *RecurrenciaController) Delete(ctx *gin.Context) {
+	userID, _ := middleware.GetUserID(ctx)
+
+	idParam := ctx.Param("id")
+	id, err := primitive.ObjectIDFromHex(idParam)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "ID inválido"})
+		return
+	}
+
+	if err := c.recurrenciaService.Delete(context.Background(), id, userID, clientInfo(ctx)); err != nil {
+		respondRecurrenciaError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"mensaje": "Recurrencia eliminada correctamente"})
+}
+
+// GetOcurrencias es la vista previa de las próximas ocurrencias.
+func (c *RecurrenciaController) GetOcurrencias(ctx *gin.Context) {
+	userID, _ := middleware.GetUserID(ctx)
+
+	idParam := ctx.Param("id")
+	id, err := primitive.ObjectIDFromHex(idParam)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "ID inválido"})
+		return
+	}
+
+	var query models.OcurrenciasQuery
+	if err := ctx.ShouldBindQuery(&query); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ocurrencias, err := c.recurrenciaService.Ocurrencias(context.Background(), id, userID, query.Limite)
+	if err != nil {
+		respondRecurrenciaError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, ocurrencias)
+}
+
+// UpdateOcurrencia omite o modifica la ocurrencia del día :fecha
+// (YYYY-MM-DD).
+func (c *RecurrenciaController) UpdateOcurrencia(ctx *gin.Context) {
+	userID, _ := middleware.GetUserID(ctx)
+
+	id, fecha, ok := ocurrenciaParams(ctx)
+	if !ok {
+		return
+	}
+
+	var req models.ExcepcionRecurrenciaRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	excepcion := models.ExcepcionRecurrencia{Fecha: fecha, Omitir: req.Omitir, Monto: req.Monto, Descripcion: req.Descripcion}
+	recurrencia, err := c.recurrenciaService.SetExcepcion(context.Background(), id, userID, excepcion, clientInfo(ctx))
+	if err != nil {
+		respondRecurrenciaError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, recurrencia)
+}
+
+// DeleteOcurrencia quita la excepción de la ocurrencia del día :fecha.
+func (c *RecurrenciaController) DeleteOcurrencia(ctx *gin.Context) {
+	userID, _ := middleware.GetUserID(ctx)
+
+	id, fecha, ok := ocurrenciaParams(ctx)
+	if !ok {
+		return
+	}
+
+	recurrencia, err := c.recurrenciaService.DeleteExcepcion(context.Background(), id, userID, fecha, clientInfo(ctx))
+	if err != nil {
+		respondRecurrenciaError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, recurrencia)
+}
+
+// ocurrenciaParams lee :id y :fecha; si alguno es inválido responde 400 y
+// devuelve false.
+func ocurrenciaParams(ctx *gin.Context) (primitive.ObjectID, time.Time, bool) {
+	id, err := primitive.ObjectIDFromHex(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "ID inválido"})
+		return id, time.Time{}, false
+	}
+	fecha, err := time.Parse(time.DateOnly, ctx.Param("fecha"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Fecha inválida, use YYYY-MM-DD"})
+		return id, time.Time{}, false
+	}
+	return id, fecha, true
+}
+
+func respondRecurrenciaError(ctx *gin.Context, err error) {
+	if errors.Is(err, services.ErrNotFound) {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Recurrencia no encontrada"})
+		return
+	}
+	if errors.Is(err, services.ErrInvalidRecurrencia) || errors.Is(err, services.ErrInvalidMonto) ||
+		errors.Is(err, services.ErrInvalidMoneda) || errors.Is(err, services.ErrInvalidCuenta) {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if errors.Is(err, services.ErrOcurrenciaRegistrada) {
+		ctx.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+}
@@ -0,0 +1,56 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"control-financiero/internal/middleware"
+	"control-financiero/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type ReporteController struct {
+	reporteService *services.ReporteService
+}
+
+func NewReporteController(db *mongo.Database) *ReporteController {
+	return &ReporteController{
+		reporteService: services.NewReporteService(db),
+	}
+}
+
+// GetPatrimonio devuelve el patrimonio neto del usuario: sus cuentas más los
+// préstamos por cobrar menos los préstamos por pagar.
+func (c *ReporteController) GetPatrimonio(ctx *gin.Context) {
+	userID, _ := middleware.GetUserID(ctx)
+
+	patrimonio, err := c.reporteService.Patrimonio(context.Background(), userID)
+	if err != nil {
+		// Falta cargar el tipo de cambio de alguna cuenta o préstamo
+		var sinCambio *services.RateNotFoundError
+		if errors.As(err, &sinCambio) {
+			ctx.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, patrimonio)
+}
+
+// GetMorosidad devuelve la deuda vencida de cada inquilino.
+func (c *ReporteController) GetMorosidad(ctx *gin.Context) {
+	userID, _ := middleware.GetUserID(ctx)
+
+	morosidad, err := c.reporteService.Morosidad(context.Background(), userID)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, morosidad)
+}
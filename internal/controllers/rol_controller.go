@@ -0,0 +1,121 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"control-financiero/internal/auth"
+	"control-financiero/internal/models"
+	"control-financiero/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type RolController struct {
+	rolService *services.RolService
+}
+
+func NewRolController(db *mongo.Database) *RolController {
+	return &RolController{
+		rolService: services.NewRolService(db),
+	}
+}
+
+func (c *RolController) GetAll(ctx *gin.Context) {
+	roles, err := c.rolService.GetAll(context.Background())
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, roles)
+}
+
+func (c *RolController) GetPermisos(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, auth.Permisos)
+}
+
+func (c *RolController) GetByID(ctx *gin.Context) {
+	idParam := ctx.Param("id")
+	id, err := primitive.ObjectIDFromHex(idParam)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "ID inválido"})
+		return
+	}
+
+	rol, err := c.rolService.GetByID(context.Background(), id)
+	if err != nil {
+		respondRolError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, rol)
+}
+
+func (c *RolController) Create(ctx *gin.Context) {
+	var rol models.Rol
+	if err := ctx.ShouldBindJSON(&rol); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := c.rolService.Create(context.Background(), &rol, clientInfo(ctx)); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, rol)
+}
+
+func (c *RolController) Update(ctx *gin.Context) {
+	idParam := ctx.Param("id")
+	id, err := primitive.ObjectIDFromHex(idParam)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "ID inválido"})
+		return
+	}
+
+	var req struct {
+		Descripcion string   `json:"descripcion"`
+		Permisos    []string `json:"permisos" binding:"required"`
+	}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	rol := models.Rol{ID: id, Descripcion: req.Descripcion, Permisos: req.Permisos}
+	if err := c.rolService.Update(context.Background(), &rol, clientInfo(ctx)); err != nil {
+		respondRolError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, rol)
+}
+
+func (c *RolController) Delete(ctx *gin.Context) {
+	idParam := ctx.Param("id")
+	id, err := primitive.ObjectIDFromHex(idParam)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "ID inválido"})
+		return
+	}
+
+	if err := c.rolService.Delete(context.Background(), id, clientInfo(ctx)); err != nil {
+		respondRolError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"mensaje": "Rol eliminado correctamente"})
+}
+
+func respondRolError(ctx *gin.Context, err error) {
+	if errors.Is(err, services.ErrNotFound) {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Rol no encontrado"})
+		return
+	}
+	ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+}
@@ -0,0 +1,76 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+
+	"control-financiero/internal/middleware"
+	"control-financiero/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type SesionController struct {
+	sesionService *services.SesionService
+}
+
+func NewSesionController(db *mongo.Database) *SesionController {
+	return &SesionController{
+		sesionService: services.NewSesionService(db),
+	}
+}
+
+func (c *SesionController) GetAll(ctx *gin.Context) {
+	userID, _ := middleware.GetUserID(ctx)
+	c.list(ctx, userID)
+}
+
+func (c *SesionController) Delete(ctx *gin.Context) {
+	userID, _ := middleware.GetUserID(ctx)
+	c.revoke(ctx, userID, ctx.Param("id"))
+}
+
+func (c *SesionController) GetByUsuario(ctx *gin.Context) {
+	userID, err := primitive.ObjectIDFromHex(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "ID inválido"})
+		return
+	}
+	c.list(ctx, userID)
+}
+
+func (c *SesionController) DeleteByUsuario(ctx *gin.Context) {
+	userID, err := primitive.ObjectIDFromHex(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "ID inválido"})
+		return
+	}
+	c.revoke(ctx, userID, ctx.Param("sesionId"))
+}
+
+func (c *SesionController) list(ctx *gin.Context, userID primitive.ObjectID) {
+	sesiones, err := c.sesionService.GetByUsuario(context.Background(), userID)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, sesiones)
+}
+
+func (c *SesionController) revoke(ctx *gin.Context, userID primitive.ObjectID, idParam string) {
+	sesionID, err := primitive.ObjectIDFromHex(idParam)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "ID inválido"})
+		return
+	}
+
+	if err := c.sesionService.Revoke(context.Background(), userID, sesionID, clientInfo(ctx)); err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"mensaje": "Sesión cerrada correctamente"})
+}
@@ -1,130 +1,238 @@
-package controllers
-
-import (
-	"context"
-	"net/http"
-
-	"control-financiero/internal/middleware"
-	"control-financiero/internal/models"
-	"control-financiero/internal/services"
-
-	"github.com/gin-gonic/gin"
-	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo"
-)
-
-type TransaccionController struct {
-	transaccionService *services.TransaccionService
-}
-
-func NewTransaccionController(db *mongo.Database) *TransaccionController {
-	return &TransaccionController{
-		transaccionService: services.NewTransaccionService(db),
-	}
-}
-
-func (c *TransaccionController) Create(ctx *gin.Context) {
-	userID, _ := middleware.GetUserID(ctx)
-
-	var transaccion models.Transaccion
-	if err := ctx.ShouldBindJSON(&transaccion); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
-	}
-
-	transaccion.UsuarioID = userID
-
-	if err := c.transaccionService.Create(context.Background(), &transaccion); err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
-	}
-
-	ctx.JSON(http.StatusCreated, transaccion)
-}
-
-func (c *TransaccionController) GetAll(ctx *gin.Context) {
-	userID, _ := middleware.GetUserID(ctx)
-
-	transacciones, err := c.transaccionService.GetByUsuario(context.Background(), userID)
-	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
-	}
-
-	ctx.JSON(http.StatusOK, transacciones)
-}
-
-func (c *TransaccionController) GetByID(ctx *gin.Context) {
-	idParam := ctx.Param("id")
-	id, err := primitive.ObjectIDFromHex(idParam)
-	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "ID inválido"})
-		return
-	}
-
-	transaccion, err := c.transaccionService.GetByID(context.Background(), id)
-	if err != nil {
-		ctx.JSON(http.StatusNotFound, gin.H{"error": "Transacción no encontrada"})
-		return
-	}
-
-	ctx.JSON(http.StatusOK, transaccion)
-}
-
-func (c *TransaccionController) Update(ctx *gin.Context) {
-	idParam := ctx.Param("id")
-	id, err := primitive.ObjectIDFromHex(idParam)
-	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "ID inválido"})
-		return
-	}
-
-	var transaccion models.Transaccion
-	if err := ctx.ShouldBindJSON(&transaccion); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
-	}
-
-	transaccion.ID = id
-
-	if err := c.transaccionService.Update(context.Background(), &transaccion); err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
-	}
-
-	ctx.JSON(http.StatusOK, transaccion)
-}
-
-func (c *TransaccionController) Delete(ctx *gin.Context) {
-	idParam := ctx.Param("id")
-	id, err := primitive.ObjectIDFromHex(idParam)
-	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "ID inválido"})
-		return
-	}
-
-	if err := c.transaccionService.Delete(context.Background(), id); err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
-	}
-
-	ctx.JSON(http.StatusOK, gin.H{"mensaje": "Transacción eliminada correctamente"})
-}
-
-func (c *TransaccionController) GetEstadisticas(ctx *gin.Context) {
-	userID, _ := middleware.GetUserID(ctx)
-
-	var req models.ReporteRequest
-	if err := ctx.ShouldBindQuery(&req); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
-	}
-
-	estadisticas, err := c.transaccionService.GetEstadisticas(context.Background(), userID, req.Year, req.Month)
-	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
-	}
-
-	ctx.JSON(http.StatusOK, estadisticas)
-}
+package controllers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"control-financiero/internal/middleware"
+	"control-financiero/internal/models"
+	"control-financiero/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type TransaccionController struct {
+	transaccionService *services.TransaccionService
+}
+
+func NewTransaccionController(db *mongo.Database) *TransaccionController {
+	return &TransaccionController{
+		transaccionService: services.NewTransaccionService(db),
+	}
+}
+
+func (c *TransaccionController) Create(ctx *gin.Context) {
+	userID, _ := middleware.GetUserID(ctx)
+
+	var transaccion models.Transaccion
+	if err := ctx.ShouldBindJSON(&transaccion); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	transaccion.UsuarioID = userID
+
+	if err := c.transaccionService.Create(context.Background(), &transaccion, clientInfo(ctx)); err != nil {
+		respondTransaccionError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, transaccion)
+}
+
+// GetAll lista las transacciones del usuario por páginas. La respuesta
+// incluye el total que cumple el filtro y el cursor de la página siguiente.
+func (c *TransaccionController) GetAll(ctx *gin.Context) {
+	userID, _ := middleware.GetUserID(ctx)
+
+	var query models.TransaccionQuery
+	if err := ctx.ShouldBindQuery(&query); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	filtro, err := transaccionFilter(userID, &query)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	page, err := c.transaccionService.List(context.Background(), filtro, query.Cursor)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidCursor) {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, page)
+}
+
+func (c *TransaccionController) GetByID(ctx *gin.Context) {
+	userID, _ := middleware.GetUserID(ctx)
+
+	idParam := ctx.Param("id")
+	id, err := primitive.ObjectIDFromHex(idParam)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "ID inválido"})
+		return
+	}
+
+	transaccion, err := c.transaccionService.GetByID(context.Background(), id, userID)
+	if err != nil {
+		respondTransaccionError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, transaccion)
+}
+
+func (c *TransaccionController) Update(ctx *gin.Context) {
+	userID, _ := middleware.GetUserID(ctx)
+
+	idParam := ctx.Param("id")
+	id, err := primitive.ObjectIDFromHex(idParam)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "ID inválido"})
+		return
+	}
+
+	var transaccion models.Transaccion
+	if err := ctx.ShouldBindJSON(&transaccion); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	transaccion.ID = id
+	transaccion.UsuarioID = userID
+
+	if err := c.transaccionService.Update(context.Background(), &transaccion, clientInfo(ctx)); err != nil {
+		respondTransaccionError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, transaccion)
+}
+
+func (c *TransaccionController) Delete(ctx *gin.Context) {
+	userID, _ := middleware.GetUserID(ctx)
+
+	idParam := ctx.Param("id")
+	id, err := primitive.ObjectIDFromHex(idParam)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "ID inválido"})
+		return
+	}
+
+	if err := c.transaccionService.Delete(context.Background(), id, userID, clientInfo(ctx)); err != nil {
+		respondTransaccionError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"mensaje": "Transacción eliminada correctamente"})
+}
+
+// CreateTransferencia mueve dinero entre dos cuentas del usuario y devuelve
+// las dos transacciones creadas.
+func (c *TransaccionController) CreateTransferencia(ctx *gin.Context) {
+	userID, _ := middleware.GetUserID(ctx)
+
+	var req models.TransferenciaRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	transferencia, err := c.transaccionService.Transferir(context.Background(), userID, &req, clientInfo(ctx))
+	if err != nil {
+		respondTransaccionError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, transferencia)
+}
+
+func (c *TransaccionController) GetEstadisticas(ctx *gin.Context) {
+	userID, _ := middleware.GetUserID(ctx)
+
+	var req models.ReporteRequest
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	estadisticas, err := c.transaccionService.GetEstadisticas(context.Background(), userID, req.Year, req.Month)
+	if err != nil {
+		respondTransaccionError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, estadisticas)
+}
+
+func respondTransaccionError(ctx *gin.Context, err error) {
+	if errors.Is(err, services.ErrNotFound) {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Transacción no encontrada"})
+		return
+	}
+	if errors.Is(err, services.ErrInvalidMonto) || errors.Is(err, services.ErrInvalidMoneda) || errors.Is(err, services.ErrInvalidCuenta) ||
+		errors.Is(err, services.ErrInvalidTransferencia) || errors.Is(err, services.ErrInvalidDivisiones) || errors.Is(err, services.ErrInvalidPrestamo) ||
+		errors.Is(err, services.ErrInvalidContrato) || errors.Is(err, services.ErrInvalidPropiedad) {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	// Falta cargar el tipo de cambio; la transacción o el reporte no se
+	// pueden expresar en la moneda base
+	var sinCambio *services.RateNotFoundError
+	if errors.As(err, &sinCambio) {
+		ctx.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+}
+
+func transaccionFilter(userID primitive.ObjectID, query *models.TransaccionQuery) (*models.TransaccionFilter, error) {
+	filtro := &models.TransaccionFilter{
+		UsuarioID:   userID,
+		Tipo:        query.Tipo,
+		Tag:         query.Tag,
+		MetodoPago:  query.MetodoPago,
+		MontoMin:    query.MontoMin,
+		MontoMax:    query.MontoMax,
+		Descripcion: query.Descripcion,
+		Orden:       query.Orden,
+		Limite:      query.Limite,
+	}
+
+	desde, hasta, err := parseRangoFechas(query.Desde, query.Hasta)
+	if err != nil {
+		return nil, err
+	}
+	filtro.Desde, filtro.Hasta = desde, hasta
+
+	if query.MontoMin != nil && query.MontoMax != nil && *query.MontoMax < *query.MontoMin {
+		return nil, errors.New("montoMax debe ser mayor o igual que montoMin")
+	}
+
+	if query.CategoriaID != "" {
+		id, err := primitive.ObjectIDFromHex(query.CategoriaID)
+		if err != nil {
+			return nil, errors.New("categoriaId inválido")
+		}
+		filtro.CategoriaID = &id
+	}
+	if query.CuentaID != "" {
+		id, err := primitive.ObjectIDFromHex(query.CuentaID)
+		if err != nil {
+			return nil, errors.New("cuentaId inválido")
+		}
+		filtro.CuentaID = &id
+	}
+
+	return filtro, nil
+}
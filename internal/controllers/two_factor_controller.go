@@ -0,0 +1,82 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"control-financiero/internal/middleware"
+	"control-financiero/internal/models"
+	"control-financiero/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type TwoFactorController struct {
+	twoFactorService *services.TwoFactorService
+}
+
+func NewTwoFactorController(db *mongo.Database) *TwoFactorController {
+	return &TwoFactorController{
+		twoFactorService: services.NewTwoFactorService(db),
+	}
+}
+
+func (c *TwoFactorController) Enroll(ctx *gin.Context) {
+	userID, _ := middleware.GetUserID(ctx)
+
+	response, err := c.twoFactorService.Enroll(context.Background(), userID)
+	if err != nil {
+		respondTwoFactorError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, response)
+}
+
+func (c *TwoFactorController) Confirm(ctx *gin.Context) {
+	userID, _ := middleware.GetUserID(ctx)
+
+	var req models.TwoFactorCodeRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	response, err := c.twoFactorService.Confirm(context.Background(), userID, req.Code, clientInfo(ctx))
+	if err != nil {
+		respondTwoFactorError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, response)
+}
+
+func (c *TwoFactorController) Disable(ctx *gin.Context) {
+	userID, _ := middleware.GetUserID(ctx)
+
+	var req models.TwoFactorCodeRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := c.twoFactorService.Disable(context.Background(), userID, req.Code, clientInfo(ctx)); err != nil {
+		respondTwoFactorError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"mensaje": "Verificación en dos pasos desactivada"})
+}
+
+func respondTwoFactorError(ctx *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrNotFound):
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Usuario no encontrado"})
+	case errors.Is(err, services.ErrInvalidTwoFactorCode):
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+	default:
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	}
+}
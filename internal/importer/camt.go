@@ -0,0 +1,110 @@
+package importer
+
+import (
+	"fmt"
+	"strconv"
+
+	"control-financiero/internal/camt"
+)
+
+// extractoCamt convierte un extracto camt.053. Cada apunte es un movimiento,
+// salvo las remesas cuyas transacciones indican su importe, que se importan
+// una a una. La referencia es la del banco (AcctSvcrRef) y, si no la hay, la
+// del apunte. Los apuntes pendientes o informativos se muestran con error:
+// solo se importan los contabilizados.
+func extractoCamt(st *camt.Statement) *Extracto {
+	extracto := &Extracto{
+		Cuenta: st.Account.IBAN,
+		Moneda: st.Account.Currency,
+	}
+	if extracto.Cuenta == "" {
+		extracto.Cuenta = st.Account.Other
+	}
+	for _, b := range st.Balances {
+		if b.Type == camt.SaldoCierre {
+			extracto.Saldo = &Saldo{Monto: b.Amount, Fecha: dia(b.Date)}
+			if extracto.Moneda == "" {
+				extracto.Moneda = b.Currency
+			}
+		}
+	}
+
+	for i, e := range st.Entries {
+		referencia := primera(e.BankReference, e.Reference, st.ID+"-"+strconv.Itoa(i+1))
+		if !desglosable(e) {
+			m := movimientoCamt(st, e, nil)
+			m.Referencia = referencia
+			if len(e.Transactions) == 1 {
+				m.Referencia = primera(e.BankReference, e.Transactions[0].BankReference, referencia)
+			}
+			extracto.Movimientos = append(extracto.Movimientos, m)
+			continue
+		}
+		for j, t := range e.Transactions {
+			m := movimientoCamt(st, e, t)
+			m.Monto = *t.Amount
+			m.Referencia = primera(t.BankReference, referencia+"-"+strconv.Itoa(j+1))
+			extracto.Movimientos = append(extracto.Movimientos, m)
+		}
+	}
+	return extracto
+}
+
+// desglosable indica si el apunte agrupa varias transacciones con importe.
+func desglosable(e *camt.Entry) bool {
+	if len(e.Transactions) < 2 {
+		return false
+	}
+	for _, t := range e.Transactions {
+		if t.Amount == nil {
+			return false
+		}
+	}
+	return true
+}
+
+// movimientoCamt convierte un apunte con los detalles de t, que puede ser nil.
+// Sin t, y si el apunte tiene una sola transacción, los detalles son los de
+// ella.
+func movimientoCamt(st *camt.Statement, e *camt.Entry, t *camt.Transaction) *Movimiento {
+	if t == nil && len(e.Transactions) == 1 {
+		t = e.Transactions[0]
+	}
+	m := &Movimiento{
+		Fecha:  dia(e.BookingDate),
+		Monto:  e.Amount,
+		Moneda: primera(e.Currency, st.Account.Currency),
+	}
+	if !e.ValueDate.IsZero() {
+		m.FechaValor = dia(e.ValueDate)
+	}
+	informacion := e.AdditionalInfo
+	if t != nil {
+		m.Contraparte = t.CounterpartyName
+		m.CuentaContraparte = t.CounterpartyAccount
+		m.Concepto = t.RemittanceInfo
+		informacion = primera(t.AdditionalInfo, informacion)
+	}
+	m.Descripcion = unirDescripcion(m.Contraparte, primera(m.Concepto, informacion))
+
+	switch {
+	case e.Status == camt.Pendiente:
+		m.agregarError("el movimiento está pendiente de contabilizar")
+	case e.Status != "" && e.Status != camt.Contabilizado:
+		m.agregarError(fmt.Sprintf("el movimiento no está contabilizado (%s)", e.Status))
+	}
+	if e.Amount == 0 || (t != nil && t.Amount != nil && *t.Amount == 0) {
+		m.agregarError("el monto es cero")
+	}
+	return m
+}
+
+// primera devuelve el primero de los textos que no está vacío.
+func primera(textos ...string) string {
+	for _, s := range textos {
+		if s != "" {
+			return s
+		}
+	}
+	return ""
+}
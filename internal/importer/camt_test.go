@@ -0,0 +1,82 @@
+package importer
+
+import (
+	"testing"
+	"time"
+
+	"control-financiero/internal/camt"
+	"control-financiero/internal/money"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExtractoCamt(t *testing.T) {
+	monto := func(s string) *money.Amount {
+		a := money.MustParse(s)
+		return &a
+	}
+	madrid := time.FixedZone("CET", 3600)
+	st := &camt.Statement{
+		ID:      "EXT-2025-02",
+		Account: camt.Account{IBAN: "ES9121000418450200051332", Currency: "EUR"},
+		Balances: []*camt.Balance{
+			{Type: camt.SaldoApertura, Amount: money.MustParse("1000"), Currency: "EUR", Date: time.Date(2025, 1, 31, 0, 0, 0, 0, time.UTC)},
+			{Type: camt.SaldoCierre, Amount: money.MustParse("1764.5"), Currency: "EUR", Date: time.Date(2025, 2, 28, 0, 0, 0, 0, time.UTC)},
+		},
+		Entries: []*camt.Entry{
+			{
+				BankReference: "B1", Amount: money.MustParse("-35.5"), Status: camt.Contabilizado,
+				BookingDate: time.Date(2025, 2, 3, 0, 30, 0, 0, madrid), ValueDate: time.Date(2025, 2, 1, 0, 0, 0, 0, time.UTC),
+				Transactions: []*camt.Transaction{{CounterpartyName: "Iberdrola", CounterpartyAccount: "ES7620770024003102575766", RemittanceInfo: "Factura luz enero"}},
+			},
+			{
+				BankReference: "B2", Amount: money.MustParse("800"), Status: camt.Contabilizado,
+				BookingDate: time.Date(2025, 2, 10, 0, 0, 0, 0, time.UTC),
+				Transactions: []*camt.Transaction{
+					{BankReference: "B2-A", Amount: monto("500"), CounterpartyName: "Ana Pérez", RemittanceInfo: "Alquiler febrero"},
+					{Amount: monto("300"), CounterpartyName: "Luis Gómez", RemittanceInfo: "Alquiler febrero"},
+				},
+			},
+			{Reference: "N3", Amount: money.MustParse("-20"), Status: camt.Pendiente, BookingDate: time.Date(2025, 2, 28, 0, 0, 0, 0, time.UTC), AdditionalInfo: "Comisión"},
+			{Amount: money.MustParse("100"), Status: camt.Contabilizado, BookingDate: time.Date(2025, 2, 28, 0, 0, 0, 0, time.UTC), AdditionalInfo: "Ingreso en efectivo"},
+		},
+	}
+
+	extracto := extractoCamt(st)
+	assert.Equal(t, "ES9121000418450200051332", extracto.Cuenta)
+	assert.Equal(t, "EUR", extracto.Moneda)
+	require.NotNil(t, extracto.Saldo)
+	assert.Equal(t, "1764.5", extracto.Saldo.Monto.String())
+	assert.Equal(t, "2025-02-28", extracto.Saldo.Fecha.Format(time.DateOnly))
+
+	movimientos := extracto.Movimientos
+	require.Len(t, movimientos, 5) // la remesa se desglosa
+
+	m := movimientos[0]
+	assert.Equal(t, "2025-02-03", m.Fecha.Format(time.DateOnly)) // contable, en la zona del banco
+	assert.Equal(t, "2025-02-01", m.FechaValor.Format(time.DateOnly))
+	assert.Equal(t, "-35.5", m.Monto.String())
+	assert.Equal(t, "EUR", m.Moneda)
+	assert.Equal(t, "Iberdrola", m.Contraparte)
+	assert.Equal(t, "ES7620770024003102575766", m.CuentaContraparte)
+	assert.Equal(t, "Factura luz enero", m.Concepto)
+	assert.Equal(t, "Iberdrola - Factura luz enero", m.Descripcion)
+	assert.Equal(t, "B1", m.Referencia)
+	assert.Empty(t, m.Errores)
+
+	assert.Equal(t, "500", movimientos[1].Monto.String())
+	assert.Equal(t, "B2-A", movimientos[1].Referencia)
+	assert.Equal(t, "Ana Pérez", movimientos[1].Contraparte)
+	assert.True(t, movimientos[1].FechaValor.IsZero())
+	assert.Equal(t, "300", movimientos[2].Monto.String())
+	assert.Equal(t, "B2-2", movimientos[2].Referencia)
+
+	assert.Equal(t, "N3", movimientos[3].Referencia)
+	assert.Equal(t, "Comisión", movimientos[3].Descripcion)
+	assert.Equal(t, []string{"el movimiento está pendiente de contabilizar"}, movimientos[3].Errores)
+
+	assert.Equal(t, "EXT-2025-02-4", movimientos[4].Referencia)
+	assert.Equal(t, "Ingreso en efectivo", movimientos[4].Descripcion)
+	assert.Empty(t, movimientos[4].Errores)
+}
@@ -0,0 +1,539 @@
+package importer
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"control-financiero/internal/models"
+	"control-financiero/internal/money"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/unicode"
+)
+
+// Delimitadores que se prueban, en orden de preferencia ante un empate.
+var delimitadores = []rune{',', ';', '\t', '|'}
+
+// lineasMuestra es cuántas filas se leen para detectar el delimitador.
+const lineasMuestra = 20
+
+// Formatos de fecha que se prueban, en orden de preferencia: ante una fecha
+// ambigua como 03/04/2025 se elige el día antes que el mes.
+var formatosFecha = []string{
+	"YYYY-MM-DD", "DD/MM/YYYY", "MM/DD/YYYY", "DD-MM-YYYY", "MM-DD-YYYY", "DD.MM.YYYY",
+	"YYYY/MM/DD", "YYYY.MM.DD", "DD/MM/YY", "MM/DD/YY", "DD-MM-YY", "DD.MM.YY", "YYYYMMDD",
+}
+
+var codificaciones = map[string]encoding.Encoding{
+	"utf-8":        encoding.Nop,
+	"utf-16le":     unicode.UTF16(unicode.LittleEndian, unicode.UseBOM),
+	"utf-16be":     unicode.UTF16(unicode.BigEndian, unicode.UseBOM),
+	"windows-1252": charmap.Windows1252,
+	"iso-8859-1":   charmap.ISO8859_1,
+}
+
+// ValidarMapeo comprueba un mapeo y normaliza sus campos: quita espacios y
+// pasa la codificación a minúsculas.
+func ValidarMapeo(mapeo *models.MapeoImportacion) error {
+	for _, campo := range []*string{
+		&mapeo.Fecha, &mapeo.Descripcion, &mapeo.Monto, &mapeo.Debito, &mapeo.Credito,
+		&mapeo.Referencia, &mapeo.Moneda, &mapeo.FormatoFecha,
+	} {
+		*campo = strings.TrimSpace(*campo)
+	}
+	mapeo.Codificacion = strings.ToLower(strings.TrimSpace(mapeo.Codificacion))
+
+	if mapeo.Fecha == "" {
+		return fmt.Errorf("%w: indique la columna de la fecha", ErrInvalidMapeo)
+	}
+	if mapeo.Monto == "" && mapeo.Debito == "" && mapeo.Credito == "" {
+		return fmt.Errorf("%w: indique la columna del monto o las de débito y crédito", ErrInvalidMapeo)
+	}
+	if mapeo.Monto != "" && (mapeo.Debito != "" || mapeo.Credito != "") {
+		return fmt.Errorf("%w: el monto va en una columna con signo o en las de débito y crédito, no en ambas", ErrInvalidMapeo)
+	}
+	if mapeo.SaltarLineas < 0 || mapeo.SaltarLineas > 100 {
+		return fmt.Errorf("%w: saltarLineas debe estar entre 0 y 100", ErrInvalidMapeo)
+	}
+	if mapeo.SinCabecera {
+		for _, col := range []string{mapeo.Fecha, mapeo.Descripcion, mapeo.Monto, mapeo.Debito, mapeo.Credito, mapeo.Referencia, mapeo.Moneda} {
+			if _, ok := numeroColumna(col); col != "" && !ok {
+				return fmt.Errorf("%w: sin cabecera las columnas se indican por número", ErrInvalidMapeo)
+			}
+		}
+	}
+
+	if _, ok := codificaciones[mapeo.Codificacion]; mapeo.Codificacion != "" && !ok {
+		return fmt.Errorf("%w: codificación desconocida %q", ErrInvalidMapeo, mapeo.Codificacion)
+	}
+	if _, ok := delimitador(mapeo.Delimitador); mapeo.Delimitador != "" && !ok {
+		return fmt.Errorf("%w: el delimitador debe ser , ; | o tab", ErrInvalidMapeo)
+	}
+	if s := mapeo.SeparadorDecimal; s != "" && s != "." && s != "," {
+		return fmt.Errorf("%w: el separador decimal debe ser . o ,", ErrInvalidMapeo)
+	}
+	if mapeo.FormatoFecha != "" {
+		if _, err := layoutFecha(mapeo.FormatoFecha); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// LeerCSV lee un extracto en CSV con el mapeo indicado. Devuelve un
+// movimiento por fila de datos, con sus errores si no se pudo leer, y el
+// formato con que se leyó el archivo: el del mapeo, completado con lo
+// detectado.
+func LeerCSV(data []byte, mapeo *models.MapeoImportacion) ([]*Movimiento, *models.FormatoCSV, error) {
+	if err := ValidarMapeo(mapeo); err != nil {
+		return nil, nil, err
+	}
+	formato := mapeo.FormatoCSV
+
+	texto, codificacion, err := decodificar(data, formato.Codificacion)
+	if err != nil {
+		return nil, nil, err
+	}
+	formato.Codificacion = codificacion
+	texto = saltarLineas(texto, mapeo.SaltarLineas)
+
+	coma, ok := delimitador(formato.Delimitador)
+	if !ok {
+		if coma, ok = detectarDelimitador(texto); !ok {
+			return nil, nil, fmt.Errorf("%w: no se pudo detectar el delimitador", ErrInvalidArchivo)
+		}
+	}
+	formato.Delimitador = nombreDelimitador(coma)
+
+	registros, lineas, err := leerRegistros(texto, coma)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	cabecera := map[string]int{}
+	if !mapeo.SinCabecera {
+		if len(registros) == 0 {
+			return nil, nil, fmt.Errorf("%w: el CSV no tiene cabecera", ErrInvalidArchivo)
+		}
+		for i, nombre := range registros[0] {
+			cabecera[strings.ToLower(strings.TrimSpace(nombre))] = i
+		}
+		registros, lineas = registros[1:], lineas[1:]
+	}
+	if len(registros) == 0 {
+		return nil, nil, fmt.Errorf("%w: el CSV no tiene filas", ErrInvalidArchivo)
+	}
+
+	columnas := make(map[string]int)
+	for _, col := range []string{mapeo.Fecha, mapeo.Descripcion, mapeo.Monto, mapeo.Debito, mapeo.Credito, mapeo.Referencia, mapeo.Moneda} {
+		if col == "" {
+			continue
+		}
+		i, ok := numeroColumna(col)
+		if !ok {
+			if i, ok = cabecera[strings.ToLower(col)]; !ok {
+				return nil, nil, fmt.Errorf("%w: falta la columna %s", ErrInvalidArchivo, col)
+			}
+		}
+		columnas[col] = i
+	}
+	valor := func(registro []string, col string) string {
+		i, ok := columnas[col]
+		if !ok || i >= len(registro) {
+			return ""
+		}
+		return strings.TrimSpace(registro[i])
+	}
+
+	if formato.FormatoFecha == "" {
+		fechas := make([]string, len(registros))
+		for i, r := range registros {
+			fechas[i] = valor(r, mapeo.Fecha)
+		}
+		if formato.FormatoFecha = detectarFormatoFecha(fechas); formato.FormatoFecha == "" {
+			return nil, nil, fmt.Errorf("%w: no se reconoce el formato de las fechas", ErrInvalidArchivo)
+		}
+	}
+	layout, _ := layoutFecha(formato.FormatoFecha)
+
+	if formato.SeparadorDecimal == "" {
+		var montos []string
+		for _, r := range registros {
+			montos = append(montos, valor(r, mapeo.Monto), valor(r, mapeo.Debito), valor(r, mapeo.Credito))
+		}
+		formato.SeparadorDecimal = detectarSeparadorDecimal(montos, coma)
+	}
+
+	movimientos := make([]*Movimiento, 0, len(registros))
+	for i, r := range registros {
+		m := &Movimiento{
+			Fila:        lineas[i] + mapeo.SaltarLineas,
+			Descripcion: valor(r, mapeo.Descripcion),
+			Referencia:  valor(r, mapeo.Referencia),
+			Moneda:      strings.ToUpper(valor(r, mapeo.Moneda)),
+		}
+
+		if fecha, err := time.Parse(layout, soloFecha(valor(r, mapeo.Fecha))); err != nil {
+			m.agregarError("fecha inválida")
+		} else {
+			m.Fecha = fecha
+		}
+
+		if mapeo.Monto != "" {
+			monto, vacio, err := parseMonto(valor(r, mapeo.Monto), formato.SeparadorDecimal)
+			switch {
+			case err != nil:
+				m.agregarError("monto inválido")
+			case vacio:
+				m.agregarError("falta el monto")
+			}
+			m.Monto = monto
+		} else {
+			debito, sinDebito, errDebito := parseMonto(valor(r, mapeo.Debito), formato.SeparadorDecimal)
+			credito, sinCredito, errCredito := parseMonto(valor(r, mapeo.Credito), formato.SeparadorDecimal)
+			switch {
+			case errDebito != nil:
+				m.agregarError("débito inválido")
+			case errCredito != nil:
+				m.agregarError("crédito inválido")
+			case sinDebito && sinCredito:
+				m.agregarError("falta el monto")
+			}
+			m.Monto = abs(credito) - abs(debito)
+		}
+		if mapeo.InvertirSigno {
+			m.Monto = -m.Monto
+		}
+		if m.Monto == 0 && len(m.Errores) == 0 {
+			m.agregarError("el monto es cero")
+		}
+
+		movimientos = append(movimientos, m)
+	}
+	return movimientos, &formato, nil
+}
+
+// decodificar pasa el archivo a UTF-8. Sin codificación indicada la detecta
+// por la marca de orden de bytes o, sin ella, por los bytes nulos de UTF-16;
+// un archivo que no es UTF-8 válido se lee como Windows-1252, la de las
+// exportaciones de Excel en español.
+func decodificar(data []byte, codificacion string) (string, string, error) {
+	switch {
+	case bytes.HasPrefix(data, []byte{0xEF, 0xBB, 0xBF}):
+		codificacion = "utf-8"
+	case bytes.HasPrefix(data, []byte{0xFF, 0xFE}):
+		codificacion = "utf-16le"
+	case bytes.HasPrefix(data, []byte{0xFE, 0xFF}):
+		codificacion = "utf-16be"
+	case codificacion != "":
+	case len(data) >= 2 && data[0] != 0 && data[1] == 0:
+		codificacion = "utf-16le"
+	case len(data) >= 2 && data[0] == 0 && data[1] != 0:
+		codificacion = "utf-16be"
+	case utf8.Valid(data):
+		codificacion = "utf-8"
+	default:
+		codificacion = "windows-1252"
+	}
+
+	if codificacion == "utf-8" && !utf8.Valid(data) {
+		return "", "", fmt.Errorf("%w: el archivo no es UTF-8 válido", ErrInvalidArchivo)
+	}
+	decodificado, err := codificaciones[codificacion].NewDecoder().Bytes(data)
+	if err != nil {
+		return "", "", fmt.Errorf("%w: el archivo no está en %s", ErrInvalidArchivo, codificacion)
+	}
+	return strings.TrimPrefix(string(decodificado), "\ufeff"), codificacion, nil
+}
+
+// saltarLineas quita las n primeras líneas, como el encabezado con los datos
+// de la cuenta que algunos bancos ponen antes de la cabecera.
+func saltarLineas(texto string, n int) string {
+	for ; n > 0; n-- {
+		_, resto, ok := strings.Cut(texto, "\n")
+		if !ok {
+			return ""
+		}
+		texto = resto
+	}
+	return texto
+}
+
+// detectarDelimitador elige el delimitador con el que más filas de la
+// muestra tienen el mismo número de campos, que debe ser mayor que uno. Ante
+// un empate gana el que da más campos.
+func detectarDelimitador(texto string) (rune, bool) {
+	var (
+		mejor                   rune
+		mejorFilas, mejorCampos int
+	)
+	for _, coma := range delimitadores {
+		reader := csv.NewReader(strings.NewReader(texto))
+		reader.Comma = coma
+		reader.FieldsPerRecord = -1
+		reader.LazyQuotes = true
+
+		frecuencia := make(map[int]int)
+		for i := 0; i < lineasMuestra; i++ {
+			registro, err := reader.Read()
+			if err != nil {
+				break
+			}
+			frecuencia[len(registro)]++
+		}
+		for campos, filas := range frecuencia {
+			if campos < 2 {
+				continue
+			}
+			if filas > mejorFilas || filas == mejorFilas && campos > mejorCampos {
+				mejor, mejorFilas, mejorCampos = coma, filas, campos
+			}
+		}
+	}
+	return mejor, mejorFilas > 0
+}
+
+// leerRegistros lee todas las filas no vacías del CSV junto con la línea en
+// que empieza cada una.
+func leerRegistros(texto string, coma rune) ([][]string, []int, error) {
+	reader := csv.NewReader(strings.NewReader(texto))
+	reader.Comma = coma
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+
+	var (
+		registros [][]string
+		lineas    []int
+	)
+	for {
+		registro, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("%w: %v", ErrInvalidArchivo, err)
+		}
+		if strings.TrimSpace(strings.Join(registro, "")) == "" {
+			continue
+		}
+		linea, _ := reader.FieldPos(0)
+		registros = append(registros, registro)
+		lineas = append(lineas, linea)
+	}
+	return registros, lineas, nil
+}
+
+// detectarFormatoFecha elige el primer formato con el que se leen todas las
+// fechas o, si ninguno las lee todas, el que lee más. Devuelve "" si ninguno
+// lee ninguna.
+func detectarFormatoFecha(fechas []string) string {
+	mejor, mejorLeidas := "", 0
+	for _, formato := range formatosFecha {
+		layout, _ := layoutFecha(formato)
+		leidas, total := 0, 0
+		for _, f := range fechas {
+			if f == "" {
+				continue
+			}
+			total++
+			if _, err := time.Parse(layout, soloFecha(f)); err == nil {
+				leidas++
+			}
+		}
+		if leidas == total && leidas > 0 {
+			return formato
+		}
+		if leidas > mejorLeidas {
+			mejor, mejorLeidas = formato, leidas
+		}
+	}
+	return mejor
+}
+
+// layoutFecha traduce un formato como DD/MM/YYYY al layout de time.Parse.
+// Con separadores el día y el mes pueden tener una o dos cifras.
+func layoutFecha(formato string) (string, error) {
+	separado := strings.ContainsAny(formato, "/-. ")
+	dia, mes := "02", "01"
+	if separado {
+		dia, mes = "2", "1"
+	}
+
+	var layout strings.Builder
+	vistos := make(map[byte]bool)
+	for resto := strings.ToUpper(formato); resto != ""; {
+		var token, parte string
+		switch {
+		case strings.HasPrefix(resto, "YYYY"):
+			token, parte = "YYYY", "2006"
+		case strings.HasPrefix(resto, "YY"):
+			token, parte = "YY", "06"
+		case strings.HasPrefix(resto, "MM"):
+			token, parte = "MM", mes
+		case strings.HasPrefix(resto, "DD"):
+			token, parte = "DD", dia
+		case strings.ContainsAny(resto[:1], "/-. "):
+			token, parte = resto[:1], resto[:1]
+		default:
+			return "", fmt.Errorf("%w: formato de fecha inválido %q", ErrInvalidMapeo, formato)
+		}
+		if len(token) > 1 {
+			if vistos[token[0]] {
+				return "", fmt.Errorf("%w: formato de fecha inválido %q", ErrInvalidMapeo, formato)
+			}
+			vistos[token[0]] = true
+		}
+		layout.WriteString(parte)
+		resto = resto[len(token):]
+	}
+	if !vistos['Y'] || !vistos['M'] || !vistos['D'] {
+		return "", fmt.Errorf("%w: el formato de fecha debe tener día, mes y año", ErrInvalidMapeo)
+	}
+	return layout.String(), nil
+}
+
+// soloFecha quita la hora que algunos bancos añaden a la fecha.
+func soloFecha(s string) string {
+	if i := strings.IndexAny(s, " T"); i > 0 {
+		return s[:i]
+	}
+	return s
+}
+
+// detectarSeparadorDecimal decide el separador decimal con el primer monto
+// que no es ambiguo: si lleva los dos separadores el decimal es el último, y
+// si lleva uno solo es el de miles cuando se repite o va seguido de
+// exactamente tres cifras. Si todos son ambiguos, como 1.234, se usa la coma
+// con el delimitador punto y coma, como en las exportaciones europeas, y el
+// punto con los demás.
+func detectarSeparadorDecimal(montos []string, coma rune) string {
+	for _, monto := range montos {
+		punto, comaDecimal := strings.LastIndex(monto, "."), strings.LastIndex(monto, ",")
+		switch {
+		case punto >= 0 && comaDecimal >= 0:
+			if comaDecimal > punto {
+				return ","
+			}
+			return "."
+		case punto >= 0:
+			if sep, ok := separadorUnico(monto, ".", punto); ok {
+				return sep
+			}
+		case comaDecimal >= 0:
+			if sep, ok := separadorUnico(monto, ",", comaDecimal); ok {
+				return sep
+			}
+		}
+	}
+	if coma == ';' {
+		return ","
+	}
+	return "."
+}
+
+// separadorUnico resuelve un monto con un solo tipo de separador, sep, cuya
+// última aparición está en i.
+func separadorUnico(monto, sep string, i int) (string, bool) {
+	otro := map[string]string{".": ",", ",": "."}[sep]
+	if strings.Count(monto, sep) > 1 {
+		return otro, true
+	}
+	cifras := 0
+	for _, r := range monto[i+1:] {
+		if r >= '0' && r <= '9' {
+			cifras++
+		}
+	}
+	if cifras == 3 {
+		return "", false
+	}
+	return sep, true
+}
+
+// parseMonto lee un monto con el separador decimal indicado. Admite
+// separadores de miles, símbolos y códigos de moneda, y el signo negativo
+// delante, detrás o como paréntesis. vacio indica que no había monto, o
+// solo un guion.
+func parseMonto(s, decimal string) (monto money.Amount, vacio bool, err error) {
+	if s == "" {
+		return 0, true, nil
+	}
+	negativo := strings.HasPrefix(s, "(") && strings.HasSuffix(s, ")")
+
+	var limpio strings.Builder
+	for _, r := range s {
+		if (r >= '0' && r <= '9') || r == '.' || r == ',' || r == '-' || r == '+' {
+			limpio.WriteRune(r)
+		}
+	}
+	cifras := limpio.String()
+	if strings.HasSuffix(cifras, "-") {
+		negativo, cifras = true, strings.TrimSuffix(cifras, "-")
+	}
+	if strings.HasPrefix(cifras, "-") {
+		negativo, cifras = true, strings.TrimPrefix(cifras, "-")
+	}
+	cifras = strings.TrimPrefix(cifras, "+")
+	if cifras == "" {
+		// Algunos bancos marcan con un guion la columna sin monto
+		if strings.Trim(s, "-– ") == "" {
+			return 0, true, nil
+		}
+		return 0, false, money.ErrInvalidAmount
+	}
+
+	miles := ","
+	if decimal == "," {
+		miles = "."
+	}
+	cifras = strings.ReplaceAll(cifras, miles, "")
+	cifras = strings.Replace(cifras, decimal, ".", 1)
+	monto, err = money.Parse(cifras)
+	if err != nil {
+		return 0, false, err
+	}
+	if negativo {
+		monto = -monto
+	}
+	return monto, false, nil
+}
+
+// numeroColumna lee una columna indicada por su número, empezando en 1.
+func numeroColumna(col string) (int, bool) {
+	n, err := strconv.Atoi(col)
+	if err != nil || n < 1 {
+		return 0, false
+	}
+	return n - 1, true
+}
+
+func delimitador(nombre string) (rune, bool) {
+	switch nombre {
+	case ",", ";", "|":
+		return rune(nombre[0]), true
+	case "tab", "\t":
+		return '\t', true
+	}
+	return 0, false
+}
+
+func nombreDelimitador(coma rune) string {
+	if coma == '\t' {
+		return "tab"
+	}
+	return string(coma)
+}
+
+func abs(a money.Amount) money.Amount {
+	if a < 0 {
+		return -a
+	}
+	return a
+}
@@ -0,0 +1,229 @@
+package importer
+
+import (
+	"testing"
+	"time"
+
+	"control-financiero/internal/models"
+	"control-financiero/internal/money"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/unicode"
+)
+
+func mustDate(s string) time.Time {
+	t, err := time.Parse(time.DateOnly, s)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func TestLeerCSV_Detecta(t *testing.T) {
+	tests := []struct {
+		name    string
+		csv     string
+		formato models.FormatoCSV
+		montos  []string
+		fechas  []string
+	}{
+		{
+			name:    "coma y punto decimal",
+			csv:     "Fecha,Concepto,Importe\n2025-03-01,Sueldo,1500.00\n2025-03-04,\"Super, mercado\",-85.4\n",
+			formato: models.FormatoCSV{Codificacion: "utf-8", Delimitador: ",", FormatoFecha: "YYYY-MM-DD", SeparadorDecimal: "."},
+			montos:  []string{"1500", "-85.4"},
+			fechas:  []string{"2025-03-01", "2025-03-04"},
+		},
+		{
+			name:    "punto y coma con miles europeos",
+			csv:     "Fecha;Concepto;Importe\n01/03/2025;Sueldo;1.500,00\n04/03/2025;Super;-85,40\n",
+			formato: models.FormatoCSV{Codificacion: "utf-8", Delimitador: ";", FormatoFecha: "DD/MM/YYYY", SeparadorDecimal: ","},
+			montos:  []string{"1500", "-85.4"},
+			fechas:  []string{"2025-03-01", "2025-03-04"},
+		},
+		{
+			name:    "mes antes que el día",
+			csv:     "Fecha\tConcepto\tImporte\n03/01/2025\tSueldo\t1,500.00\n03/14/2025\tSuper\t(85.40)\n",
+			formato: models.FormatoCSV{Codificacion: "utf-8", Delimitador: "tab", FormatoFecha: "MM/DD/YYYY", SeparadorDecimal: "."},
+			montos:  []string{"1500", "-85.4"},
+			fechas:  []string{"2025-03-01", "2025-03-14"},
+		},
+		{
+			name:    "miles ambiguos con punto y coma",
+			csv:     "Fecha;Concepto;Importe\n1.3.2025 10:15;Sueldo;1.500\n4.3.2025 09:00;Super;85-\n",
+			formato: models.FormatoCSV{Codificacion: "utf-8", Delimitador: ";", FormatoFecha: "DD.MM.YYYY", SeparadorDecimal: ","},
+			montos:  []string{"1500", "-85"},
+			fechas:  []string{"2025-03-01", "2025-03-04"},
+		},
+		{
+			name:    "barra vertical y fecha compacta",
+			csv:     "Fecha|Concepto|Importe\n20250301|Sueldo|USD 1500\n20250304|Super|-$85.40\n",
+			formato: models.FormatoCSV{Codificacion: "utf-8", Delimitador: "|", FormatoFecha: "YYYYMMDD", SeparadorDecimal: "."},
+			montos:  []string{"1500", "-85.4"},
+			fechas:  []string{"2025-03-01", "2025-03-04"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			movimientos, formato, err := LeerCSV([]byte(tt.csv), &models.MapeoImportacion{
+				Fecha: "fecha", Descripcion: "Concepto", Monto: "importe",
+			})
+			require.NoError(t, err)
+			assert.Equal(t, tt.formato, *formato)
+			require.Len(t, movimientos, len(tt.montos))
+			for i, m := range movimientos {
+				assert.Empty(t, m.Errores)
+				assert.Equal(t, i+2, m.Fila)
+				assert.Equal(t, money.MustParse(tt.montos[i]), m.Monto)
+				assert.Equal(t, mustDate(tt.fechas[i]), m.Fecha)
+			}
+		})
+	}
+}
+
+func TestLeerCSV_Codificacion(t *testing.T) {
+	texto := "Fecha;Descripción;Importe\n01/03/2025;Café Ñandú;-3,50\n"
+
+	t.Run("windows-1252 sin indicarla", func(t *testing.T) {
+		data, err := charmap.Windows1252.NewEncoder().Bytes([]byte(texto))
+		require.NoError(t, err)
+
+		movimientos, formato, err := LeerCSV(data, &models.MapeoImportacion{Fecha: "1", Descripcion: "descripción", Monto: "3"})
+		require.NoError(t, err)
+		assert.Equal(t, "windows-1252", formato.Codificacion)
+		assert.Equal(t, "Café Ñandú", movimientos[0].Descripcion)
+	})
+
+	t.Run("utf-16 con marca de orden", func(t *testing.T) {
+		data, err := unicode.UTF16(unicode.LittleEndian, unicode.UseBOM).NewEncoder().Bytes([]byte(texto))
+		require.NoError(t, err)
+
+		movimientos, formato, err := LeerCSV(data, &models.MapeoImportacion{Fecha: "fecha", Descripcion: "descripción", Monto: "importe"})
+		require.NoError(t, err)
+		assert.Equal(t, "utf-16le", formato.Codificacion)
+		assert.Equal(t, "Café Ñandú", movimientos[0].Descripcion)
+		assert.Equal(t, money.MustParse("-3.5"), movimientos[0].Monto)
+	})
+
+	t.Run("utf-8 con BOM", func(t *testing.T) {
+		data := append([]byte{0xEF, 0xBB, 0xBF}, texto...)
+
+		movimientos, formato, err := LeerCSV(data, &models.MapeoImportacion{Fecha: "fecha", Monto: "importe"})
+		require.NoError(t, err)
+		assert.Equal(t, "utf-8", formato.Codificacion)
+		assert.Len(t, movimientos, 1)
+	})
+}
+
+func TestLeerCSV_Mapeo(t *testing.T) {
+	t.Run("débito y crédito con líneas previas y sin cabecera", func(t *testing.T) {
+		csv := "Banco Ejemplo\nCuenta 123\n\n" +
+			"05/01/2025,Transferencia recibida,,200.00,TRX-1\n" +
+			"06/01/2025,Pago tarjeta,50.25,,TRX-2\n"
+
+		movimientos, _, err := LeerCSV([]byte(csv), &models.MapeoImportacion{
+			SaltarLineas: 2, SinCabecera: true,
+			Fecha: "1", Descripcion: "2", Debito: "3", Credito: "4", Referencia: "5",
+		})
+		require.NoError(t, err)
+		require.Len(t, movimientos, 2)
+		assert.Equal(t, 4, movimientos[0].Fila)
+		assert.Equal(t, money.MustParse("200"), movimientos[0].Monto)
+		assert.Equal(t, "TRX-1", movimientos[0].Referencia)
+		assert.Equal(t, money.MustParse("-50.25"), movimientos[1].Monto)
+		assert.Equal(t, mustDate("2025-01-06"), movimientos[1].Fecha)
+	})
+
+	t.Run("invertir el signo y moneda por fila", func(t *testing.T) {
+		csv := "fecha,monto,moneda\n2025-01-05,120.5,usd\n"
+
+		movimientos, _, err := LeerCSV([]byte(csv), &models.MapeoImportacion{Fecha: "fecha", Monto: "monto", Moneda: "moneda", InvertirSigno: true})
+		require.NoError(t, err)
+		assert.Equal(t, money.MustParse("-120.5"), movimientos[0].Monto)
+		assert.Equal(t, "USD", movimientos[0].Moneda)
+	})
+
+	t.Run("formato indicado en el mapeo", func(t *testing.T) {
+		csv := "fecha;monto\n03/04/2025;1.234\n"
+
+		movimientos, formato, err := LeerCSV([]byte(csv), &models.MapeoImportacion{
+			FormatoCSV: models.FormatoCSV{FormatoFecha: "MM/DD/YYYY", SeparadorDecimal: "."},
+			Fecha:      "fecha", Monto: "monto",
+		})
+		require.NoError(t, err)
+		assert.Equal(t, ";", formato.Delimitador)
+		assert.Equal(t, mustDate("2025-03-04"), movimientos[0].Fecha)
+		assert.Equal(t, money.MustParse("1.234"), movimientos[0].Monto)
+	})
+
+	t.Run("errores por fila", func(t *testing.T) {
+		csv := "fecha,monto\n2025-01-05,10\n2025-13-40,10\n2025-01-07,abc\n2025-01-08,\n2025-01-09,0.00\n"
+
+		movimientos, _, err := LeerCSV([]byte(csv), &models.MapeoImportacion{Fecha: "fecha", Monto: "monto"})
+		require.NoError(t, err)
+		require.Len(t, movimientos, 5)
+		assert.Empty(t, movimientos[0].Errores)
+		assert.Equal(t, []string{"fecha inválida"}, movimientos[1].Errores)
+		assert.Equal(t, []string{"monto inválido"}, movimientos[2].Errores)
+		assert.Equal(t, []string{"falta el monto"}, movimientos[3].Errores)
+		assert.Equal(t, []string{"el monto es cero"}, movimientos[4].Errores)
+	})
+
+	t.Run("columna inexistente", func(t *testing.T) {
+		_, _, err := LeerCSV([]byte("fecha,monto\n2025-01-05,10\n"), &models.MapeoImportacion{Fecha: "fecha", Monto: "importe"})
+		assert.ErrorIs(t, err, ErrInvalidArchivo)
+	})
+}
+
+func TestValidarMapeo(t *testing.T) {
+	tests := []struct {
+		name  string
+		mapeo models.MapeoImportacion
+		ok    bool
+	}{
+		{"monto con signo", models.MapeoImportacion{Fecha: "fecha", Monto: "monto"}, true},
+		{"débito y crédito", models.MapeoImportacion{Fecha: "fecha", Debito: "cargo", Credito: "abono"}, true},
+		{"sin fecha", models.MapeoImportacion{Monto: "monto"}, false},
+		{"sin monto", models.MapeoImportacion{Fecha: "fecha"}, false},
+		{"monto y débito", models.MapeoImportacion{Fecha: "fecha", Monto: "monto", Debito: "cargo"}, false},
+		{"sin cabecera con nombres", models.MapeoImportacion{SinCabecera: true, Fecha: "fecha", Monto: "2"}, false},
+		{"codificación desconocida", models.MapeoImportacion{FormatoCSV: models.FormatoCSV{Codificacion: "ebcdic"}, Fecha: "1", Monto: "2"}, false},
+		{"delimitador inválido", models.MapeoImportacion{FormatoCSV: models.FormatoCSV{Delimitador: ":"}, Fecha: "1", Monto: "2"}, false},
+		{"formato de fecha sin año", models.MapeoImportacion{FormatoCSV: models.FormatoCSV{FormatoFecha: "DD/MM"}, Fecha: "1", Monto: "2"}, false},
+		{"formato de fecha con día repetido", models.MapeoImportacion{FormatoCSV: models.FormatoCSV{FormatoFecha: "DD/DD/YYYY"}, Fecha: "1", Monto: "2"}, false},
+		{"formato de fecha en minúsculas", models.MapeoImportacion{FormatoCSV: models.FormatoCSV{FormatoFecha: "dd-mm-yy"}, Fecha: "1", Monto: "2"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidarMapeo(&tt.mapeo)
+			if tt.ok {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, ErrInvalidMapeo)
+			}
+		})
+	}
+}
+
+func TestDetectarSeparadorDecimal(t *testing.T) {
+	tests := []struct {
+		montos []string
+		coma   rune
+		want   string
+	}{
+		{[]string{"1.234,56"}, ',', ","},
+		{[]string{"1,234.56"}, ';', "."},
+		{[]string{"1.234.567"}, ',', ","},
+		{[]string{"12,5"}, ',', ","},
+		{[]string{"1.234", "", "7.5"}, ';', "."},
+		{[]string{"1.234"}, ';', ","},
+		{[]string{"1,234"}, ',', "."},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, detectarSeparadorDecimal(tt.montos, tt.coma), tt.montos)
+	}
+}
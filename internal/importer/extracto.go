@@ -0,0 +1,133 @@
+package importer
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"control-financiero/internal/camt"
+	"control-financiero/internal/money"
+	"control-financiero/internal/mt940"
+	"control-financiero/internal/ofx"
+)
+
+// Formatos de archivo que se pueden importar.
+const (
+	FormatoCSV   = "csv"
+	FormatoOFX   = "ofx" // también QFX
+	FormatoCamt  = "camt053"
+	FormatoMT940 = "mt940"
+)
+
+// Extracto son los movimientos de una cuenta leídos de un extracto bancario
+// estructurado: OFX, camt.053 o MT940.
+type Extracto struct {
+	Cuenta      string // número de cuenta o IBAN, tal como lo escribe el banco
+	Moneda      string // vacía si el extracto no la indica
+	Movimientos []*Movimiento
+	Saldo       *Saldo // saldo contable al cierre; nil si el extracto no lo indica
+}
+
+// Saldo es el saldo contable de la cuenta al final del día Fecha.
+type Saldo struct {
+	Monto money.Amount
+	Fecha time.Time
+}
+
+// Detectar devuelve el formato de data según su contenido. Lo que no es un
+// extracto estructurado se lee como CSV.
+func Detectar(data []byte) string {
+	switch {
+	case ofx.Detect(data):
+		return FormatoOFX
+	case camt.Detect(data):
+		return FormatoCamt
+	case mt940.Detect(data):
+		return FormatoMT940
+	}
+	return FormatoCSV
+}
+
+// LeerExtractos lee un archivo OFX, camt.053 o MT940 y devuelve un extracto
+// por cuenta. Los extractos de una misma cuenta, como los diarios de un
+// MT940, se unen en uno con el saldo del último.
+func LeerExtractos(formato string, data []byte) ([]*Extracto, error) {
+	var extractos []*Extracto
+	switch formato {
+	case FormatoOFX:
+		statements, err := ofx.Parse(data)
+		if err != nil {
+			return nil, err
+		}
+		for _, st := range statements {
+			extractos = append(extractos, extractoOFX(st))
+		}
+	case FormatoCamt:
+		statements, err := camt.Parse(data)
+		if err != nil {
+			return nil, err
+		}
+		for _, st := range statements {
+			extractos = append(extractos, extractoCamt(st))
+		}
+	case FormatoMT940:
+		statements, err := mt940.Parse(data)
+		if err != nil {
+			return nil, err
+		}
+		for _, st := range statements {
+			extractos = append(extractos, extractoMT940(st))
+		}
+	default:
+		return nil, fmt.Errorf("%w: formato %q desconocido", ErrInvalidArchivo, formato)
+	}
+	return unirExtractos(extractos), nil
+}
+
+// unirExtractos une los extractos de una misma cuenta, en el orden del
+// archivo, y numera sus movimientos desde 1.
+func unirExtractos(extractos []*Extracto) []*Extracto {
+	var unidos []*Extracto
+	porCuenta := make(map[string]*Extracto)
+	for _, e := range extractos {
+		u, ok := porCuenta[e.Cuenta]
+		if !ok {
+			porCuenta[e.Cuenta] = e
+			unidos = append(unidos, e)
+			continue
+		}
+		u.Movimientos = append(u.Movimientos, e.Movimientos...)
+		if u.Moneda == "" {
+			u.Moneda = e.Moneda
+		}
+		if e.Saldo != nil && (u.Saldo == nil || !e.Saldo.Fecha.Before(u.Saldo.Fecha)) {
+			u.Saldo = e.Saldo
+		}
+	}
+	for _, e := range unidos {
+		for i, m := range e.Movimientos {
+			m.Fila = i + 1
+		}
+	}
+	return unidos
+}
+
+// dia devuelve el día de t en su propia zona horaria, la del banco, como
+// fecha UTC.
+func dia(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+// unirDescripcion une el nombre de la contraparte, o del comercio, con el
+// detalle de la operación.
+func unirDescripcion(nombre, detalle string) string {
+	nombre, detalle = strings.TrimSpace(nombre), strings.TrimSpace(detalle)
+	switch {
+	case nombre == "":
+		return detalle
+	case detalle == "" || strings.Contains(nombre, detalle):
+		return nombre
+	}
+	return nombre + " - " + detalle
+}
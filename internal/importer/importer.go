@@ -0,0 +1,43 @@
+// Package importer lee extractos bancarios y los normaliza en movimientos
+// con signo, listos para registrarse como transacciones. Lee CSV con un
+// mapeo de columnas y detecta lo que el mapeo no indica: la codificación, el
+// delimitador, el formato de las fechas y el separador decimal. Los
+// extractos OFX, camt.053 y MT940 se leen con los paquetes ofx, camt y mt940
+// y se convierten aquí.
+package importer
+
+import (
+	"errors"
+	"time"
+
+	"control-financiero/internal/money"
+)
+
+// ErrInvalidArchivo envuelve los errores que impiden leer el archivo entero.
+// Los de una fila se devuelven en sus Errores.
+var ErrInvalidArchivo = errors.New("archivo de importación inválido")
+
+// ErrInvalidMapeo envuelve los errores de validación de un mapeo de columnas.
+var ErrInvalidMapeo = errors.New("mapeo de importación inválido")
+
+// Movimiento es una fila de un extracto. Monto es positivo si el dinero
+// entra en la cuenta y negativo si sale. Fecha es la fecha contable.
+type Movimiento struct {
+	Fila        int // línea del CSV, o posición del movimiento en el extracto
+	Fecha       time.Time
+	Monto       money.Amount
+	Moneda      string // vacía si el extracto no la indica
+	Descripcion string
+	Referencia  string
+	Errores     []string // con errores, los demás campos pueden estar incompletos
+
+	// Datos que dan los extractos camt.053 y MT940; vacíos en los demás
+	FechaValor        time.Time
+	Contraparte       string
+	CuentaContraparte string // IBAN u otro número de cuenta
+	Concepto          string // el concepto o las referencias de la remesa
+}
+
+func (m *Movimiento) agregarError(msg string) {
+	m.Errores = append(m.Errores, msg)
+}
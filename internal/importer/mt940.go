@@ -0,0 +1,40 @@
+package importer
+
+import (
+	"fmt"
+
+	"control-financiero/internal/mt940"
+)
+
+// extractoMT940 convierte un extracto MT940. La referencia es la del banco
+// del campo :61: o, si no la hay, la del cliente; sin ninguna de las dos, el
+// número del extracto, la fecha valor y la posición del movimiento, que se
+// repiten si se vuelve a importar el mismo extracto.
+func extractoMT940(st *mt940.Statement) *Extracto {
+	extracto := &Extracto{
+		Cuenta:      st.Account,
+		Moneda:      st.Currency,
+		Movimientos: make([]*Movimiento, 0, len(st.Transactions)),
+	}
+	if st.ClosingBalance != nil {
+		extracto.Saldo = &Saldo{Monto: st.ClosingBalance.Amount, Fecha: st.ClosingBalance.Date}
+	}
+	for i, t := range st.Transactions {
+		m := &Movimiento{
+			Fecha:             t.EntryDate,
+			FechaValor:        t.ValueDate,
+			Monto:             t.Amount,
+			Moneda:            st.Currency,
+			Contraparte:       t.CounterpartyName,
+			CuentaContraparte: t.CounterpartyAccount,
+			Concepto:          t.RemittanceInfo,
+			Referencia:        primera(t.BankReference, t.CustomerReference, fmt.Sprintf("%s-%s-%d", st.Number, t.ValueDate.Format("20060102"), i+1)),
+		}
+		m.Descripcion = unirDescripcion(m.Contraparte, primera(m.Concepto, t.Information, t.Supplementary))
+		if m.Monto == 0 {
+			m.agregarError("el monto es cero")
+		}
+		extracto.Movimientos = append(extracto.Movimientos, m)
+	}
+	return extracto
+}
@@ -0,0 +1,69 @@
+package importer
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLeerExtractos_MT940(t *testing.T) {
+	data := []byte(":20:STARTUMSE\r\n:25:37040044/0532013000\r\n:28C:00012/001\r\n" +
+		":60F:C241230EUR1500,00\r\n" +
+		":61:2412301231DR250,00NMSCNONREF//2412300001\r\n" +
+		":86:005?00LASTSCHRIFT?20Miete Januar?32Hausverwaltung Schäfer?31DE44500105175407324931\r\n" +
+		":62F:C241231EUR1250,00\r\n" +
+		":20:STARTUMSE\r\n:25:37040044/0532013000\r\n:28C:00013/001\r\n" +
+		":60F:C241231EUR1250,00\r\n" +
+		":61:250102C12,5NTRFNONREF\r\n" +
+		":86:Zinsen\r\n" +
+		":62F:C250102EUR1262,50\r\n")
+
+	formato := Detectar(data)
+	require.Equal(t, FormatoMT940, formato)
+	extractos, err := LeerExtractos(formato, data)
+	require.NoError(t, err)
+	require.Len(t, extractos, 1) // los extractos diarios de la cuenta se unen
+
+	extracto := extractos[0]
+	assert.Equal(t, "37040044/0532013000", extracto.Cuenta)
+	assert.Equal(t, "EUR", extracto.Moneda)
+	require.NotNil(t, extracto.Saldo)
+	assert.Equal(t, "1262.5", extracto.Saldo.Monto.String())
+	assert.Equal(t, "2025-01-02", extracto.Saldo.Fecha.Format(time.DateOnly))
+	require.Len(t, extracto.Movimientos, 2)
+
+	m := extracto.Movimientos[0]
+	assert.Equal(t, 1, m.Fila)
+	assert.Equal(t, "2024-12-31", m.Fecha.Format(time.DateOnly))
+	assert.Equal(t, "2024-12-30", m.FechaValor.Format(time.DateOnly))
+	assert.Equal(t, "-250", m.Monto.String())
+	assert.Equal(t, "EUR", m.Moneda)
+	assert.Equal(t, "Hausverwaltung Schäfer", m.Contraparte)
+	assert.Equal(t, "DE44500105175407324931", m.CuentaContraparte)
+	assert.Equal(t, "Miete Januar", m.Concepto)
+	assert.Equal(t, "Hausverwaltung Schäfer - Miete Januar", m.Descripcion)
+	assert.Equal(t, "2412300001", m.Referencia)
+
+	m = extracto.Movimientos[1]
+	assert.Equal(t, 2, m.Fila)
+	assert.Equal(t, "12.5", m.Monto.String())
+	assert.Equal(t, "Zinsen", m.Descripcion)
+	assert.Equal(t, "00013/001-20250102-1", m.Referencia)
+}
+
+func TestDetectar(t *testing.T) {
+	tests := map[string]string{
+		"fecha;monto\n2025-01-01;10\n":         FormatoCSV,
+		"OFXHEADER:100\nDATA:OFXSGML\n\n<OFX>": FormatoOFX,
+		`<?xml version="1.0"?><Document xmlns="urn:iso:std:iso:20022:tech:xsd:camt.053.001.02">`: FormatoCamt,
+		":20:REF\r\n:25:NL20INGB0001234567\r\n":                                                  FormatoMT940,
+	}
+	for data, want := range tests {
+		assert.Equal(t, want, Detectar([]byte(data)), data)
+	}
+
+	_, err := LeerExtractos(FormatoCSV, []byte("a;b\n"))
+	assert.ErrorIs(t, err, ErrInvalidArchivo)
+}
@@ -0,0 +1,35 @@
+package importer
+
+import (
+	"control-financiero/internal/ofx"
+)
+
+// extractoOFX convierte un extracto OFX. El FITID es la referencia, de modo
+// que volver a importar el mismo extracto no repite sus movimientos.
+func extractoOFX(st *ofx.Statement) *Extracto {
+	extracto := &Extracto{
+		Cuenta:      st.Account.ID,
+		Moneda:      st.Currency,
+		Movimientos: make([]*Movimiento, 0, len(st.Transactions)),
+	}
+	if st.LedgerBalance != nil {
+		extracto.Saldo = &Saldo{Monto: st.LedgerBalance.Amount, Fecha: dia(st.LedgerBalance.AsOf)}
+	}
+	for _, t := range st.Transactions {
+		m := &Movimiento{
+			Fecha:       dia(t.Posted),
+			Monto:       t.Amount,
+			Moneda:      t.Currency,
+			Descripcion: unirDescripcion(t.Name, t.Memo), // muchos bancos usan el memo para el detalle
+			Referencia:  t.FITID,
+		}
+		if m.Moneda == "" {
+			m.Moneda = st.Currency
+		}
+		if m.Monto == 0 {
+			m.agregarError("el monto es cero")
+		}
+		extracto.Movimientos = append(extracto.Movimientos, m)
+	}
+	return extracto
+}
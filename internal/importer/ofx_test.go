@@ -0,0 +1,52 @@
+package importer
+
+import (
+	"testing"
+	"time"
+
+	"control-financiero/internal/money"
+	"control-financiero/internal/ofx"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExtractoOFX(t *testing.T) {
+	lima := time.FixedZone("PET", -5*3600)
+	st := &ofx.Statement{
+		Currency:      "PEN",
+		Account:       ofx.Account{ID: "191-2345678-0-12"},
+		LedgerBalance: &ofx.Balance{Amount: money.MustParse("1500"), AsOf: time.Date(2025, 2, 2, 23, 59, 0, 0, lima)},
+		Transactions: []*ofx.Transaction{
+			{Posted: time.Date(2025, 1, 31, 23, 0, 0, 0, lima), Amount: money.MustParse("-45.9"), FITID: "1", Name: "Panadería", Memo: "Compra con tarjeta"},
+			{Posted: time.Date(2025, 2, 1, 0, 0, 0, 0, time.UTC), Amount: money.MustParse("20"), FITID: "2", Memo: "Abono", Currency: "USD"},
+			{Posted: time.Date(2025, 2, 2, 0, 0, 0, 0, time.UTC), FITID: "3", Name: "CHEQUE 451", Memo: "451"},
+		},
+	}
+
+	extracto := extractoOFX(st)
+	assert.Equal(t, "191-2345678-0-12", extracto.Cuenta)
+	assert.Equal(t, "PEN", extracto.Moneda)
+	require.NotNil(t, extracto.Saldo)
+	assert.Equal(t, "1500", extracto.Saldo.Monto.String())
+	assert.Equal(t, "2025-02-02", extracto.Saldo.Fecha.Format(time.DateOnly))
+
+	movimientos := unirExtractos([]*Extracto{extracto})[0].Movimientos
+	require.Len(t, movimientos, 3)
+
+	m := movimientos[0]
+	assert.Equal(t, 1, m.Fila)
+	assert.Equal(t, "2025-01-31T00:00:00Z", m.Fecha.Format(time.RFC3339)) // el día del banco, no el de UTC
+	assert.Equal(t, "-45.9", m.Monto.String())
+	assert.Equal(t, "PEN", m.Moneda)
+	assert.Equal(t, "Panadería - Compra con tarjeta", m.Descripcion)
+	assert.Equal(t, "1", m.Referencia)
+	assert.Empty(t, m.Errores)
+
+	assert.Equal(t, "USD", movimientos[1].Moneda)
+	assert.Equal(t, "Abono", movimientos[1].Descripcion)
+
+	assert.Equal(t, 3, movimientos[2].Fila)
+	assert.Equal(t, "CHEQUE 451", movimientos[2].Descripcion)
+	assert.Equal(t, []string{"el monto es cero"}, movimientos[2].Errores)
+}
@@ -0,0 +1,52 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// FileMailer guarda cada correo como un archivo .eml en un directorio.
+type FileMailer struct {
+	dir  string
+	from string
+}
+
+func NewFileMailer(dir, from string) *FileMailer {
+	return &FileMailer{dir: dir, from: from}
+}
+
+func (m *FileMailer) Send(ctx context.Context, msg Message) error {
+	now := time.Now()
+	data, err := buildMessage(m.from, msg, now)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(m.dir, 0o755); err != nil {
+		return err
+	}
+
+	name := fmt.Sprintf("%s-%s.eml", now.Format("20060102T150405"), primitive.NewObjectID().Hex())
+	return os.WriteFile(filepath.Join(m.dir, name), data, 0o600)
+}
+
+// ConsoleMailer escribe los correos en el log. Útil en desarrollo para copiar
+// los enlaces de verificación sin configurar un servidor SMTP.
+type ConsoleMailer struct {
+	from string
+}
+
+func NewConsoleMailer(from string) *ConsoleMailer {
+	return &ConsoleMailer{from: from}
+}
+
+func (m *ConsoleMailer) Send(ctx context.Context, msg Message) error {
+	log.Printf("📧 Correo para %s\nAsunto: %s\n\n%s\n", msg.To, msg.Subject, msg.Body)
+	return nil
+}
@@ -0,0 +1,59 @@
+package mailer
+
+import (
+	"context"
+	"io"
+	"mime"
+	"mime/quotedprintable"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuildMessage(t *testing.T) {
+	msg := Message{To: "ana@example.com", Subject: "Verifica tu correo", Body: "Hola Ana,\nañade este enlace: https://example.com/?token=abc"}
+
+	data, err := buildMessage("Control Financiero <no-reply@example.com>", msg, time.Now())
+	require.NoError(t, err)
+
+	parsed, err := mail.ReadMessage(strings.NewReader(string(data)))
+	require.NoError(t, err)
+	assert.Equal(t, "ana@example.com", parsed.Header.Get("To"))
+	assert.Equal(t, "text/plain; charset=utf-8", parsed.Header.Get("Content-Type"))
+
+	subject, err := new(mime.WordDecoder).DecodeHeader(parsed.Header.Get("Subject"))
+	require.NoError(t, err)
+	assert.Equal(t, "Verifica tu correo", subject)
+
+	body, err := io.ReadAll(quotedprintable.NewReader(parsed.Body))
+	require.NoError(t, err)
+	// Los saltos de línea viajan como CRLF
+	assert.Equal(t, strings.ReplaceAll(msg.Body, "\n", "\r\n"), string(body))
+}
+
+func TestBuildMessage_InvalidRecipient(t *testing.T) {
+	_, err := buildMessage("no-reply@example.com", Message{To: "no es un correo"}, time.Now())
+	assert.Error(t, err)
+}
+
+func TestFileMailer(t *testing.T) {
+	dir := t.TempDir()
+	m := NewFileMailer(dir, "no-reply@example.com")
+
+	require.NoError(t, m.Send(context.Background(), Message{To: "ana@example.com", Subject: "Hola", Body: "Cuerpo"}))
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.eml"))
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+
+	data, err := os.ReadFile(files[0])
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "To: ana@example.com")
+	assert.Contains(t, string(data), "Cuerpo")
+}
@@ -1,71 +1,94 @@
-package middleware
-
-import (
-	"control-financiero/internal/auth"
-	"control-financiero/internal/config"
-	"net/http"
-	"strings"
-
-	"github.com/gin-gonic/gin"
-	"go.mongodb.org/mongo-driver/bson/primitive"
-)
-
-func AuthMiddleware(cfg *config.Config) gin.HandlerFunc {
-	return func(c *gin.Context) {
-		authHeader := c.GetHeader("Authorization")
-		if authHeader == "" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Token no proporcionado"})
-			c.Abort()
-			return
-		}
-
-		parts := strings.Split(authHeader, " ")
-		if len(parts) != 2 || parts[0] != "Bearer" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Formato de token inválido"})
-			c.Abort()
-			return
-		}
-
-		tokenString := parts[1]
-		claims, err := auth.ValidateJWT(tokenString, cfg.JWTSecret)
-		if err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Token inválido o expirado"})
-			c.Abort()
-			return
-		}
-
-		// Establecer información del usuario en el contexto
-		c.Set("userId", claims.UserID)
-		c.Set("userEmail", claims.Email)
-		c.Set("userRol", claims.Rol)
-
-		c.Next()
-	}
-}
-
-func AdminMiddleware() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		rol, exists := c.Get("userRol")
-		if !exists {
-			c.JSON(http.StatusForbidden, gin.H{"error": "No autorizado"})
-			c.Abort()
-			return
-		}
-
-		if rol != "admin" {
-			c.JSON(http.StatusForbidden, gin.H{"error": "Acceso denegado: se requieren permisos de administrador"})
-			c.Abort()
-			return
-		}
-
-		c.Next()
-	}
-}
-
-func GetUserID(c *gin.Context) (primitive.ObjectID, error) {
-	userID, exists := c.Get("userId")
-	if !exists {
-		return primitive.NilObjectID, nil
-	}
-	return userID.(primitive.ObjectID), nil
-}
+package middleware
+
+import (
+	"context"
+	"control-financiero/internal/auth"
+	"control-financiero/internal/config"
+	"control-financiero/internal/models"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// AccessTokenAuthenticator valida los tokens de acceso personal y registra su
+// último uso.
+type AccessTokenAuthenticator interface {
+	Authenticate(ctx context.Context, token, ip string) (*models.Usuario, *models.PersonalAccessToken, error)
+}
+
+// AuthMiddleware acepta un JWT de sesión o un token de acceso personal. Con
+// un token de acceso personal también deja sus scopes en el contexto para que
+// LoadPermissions limite los permisos del rol.
+func AuthMiddleware(cfg *config.Config, tokens AccessTokenAuthenticator) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		authHeader := c.GetHeader("Authorization")
+		if authHeader == "" {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Token no proporcionado"})
+			c.Abort()
+			return
+		}
+
+		parts := strings.Split(authHeader, " ")
+		if len(parts) != 2 || parts[0] != "Bearer" {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Formato de token inválido"})
+			c.Abort()
+			return
+		}
+
+		tokenString := parts[1]
+		if auth.IsPersonalAccessToken(tokenString) {
+			usuario, pat, err := tokens.Authenticate(c.Request.Context(), tokenString, c.ClientIP())
+			if err != nil {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "Token inválido o expirado"})
+				c.Abort()
+				return
+			}
+
+			c.Set("userId", usuario.ID)
+			c.Set("userEmail", usuario.Email)
+			c.Set("userRol", usuario.Rol)
+			c.Set("tokenScopes", pat.Scopes)
+			c.Next()
+			return
+		}
+
+		claims, err := auth.ValidateJWT(tokenString, cfg.JWTKeys)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Token inválido o expirado"})
+			c.Abort()
+			return
+		}
+
+		// Establecer información del usuario en el contexto
+		c.Set("userId", claims.UserID)
+		c.Set("userEmail", claims.Email)
+		c.Set("userRol", claims.Rol)
+
+		c.Next()
+	}
+}
+
+func GetUserID(c *gin.Context) (primitive.ObjectID, error) {
+	userID, exists := c.Get("userId")
+	if !exists {
+		return primitive.NilObjectID, nil
+	}
+	return userID.(primitive.ObjectID), nil
+}
+
+// RequireSession rechaza las peticiones autenticadas con un token de acceso
+// personal. Se usa en las operaciones sobre la propia cuenta, que no deben
+// quedar al alcance de un token filtrado.
+func RequireSession() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, ok := c.Get("tokenScopes"); ok {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Esta operación no admite tokens de acceso personal"})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -0,0 +1,66 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+
+	"control-financiero/internal/auth"
+
+	"github.com/gin-gonic/gin"
+)
+
+// PermissionResolver obtiene los permisos asociados a un rol.
+type PermissionResolver interface {
+	Permisos(ctx context.Context, rol string) ([]string, error)
+}
+
+// LoadPermissions resuelve los permisos del rol del usuario autenticado y los
+// deja en el contexto. Si la petición usa un token de acceso personal, solo
+// quedan los scopes del token que el rol sigue concediendo. Debe ir después
+// de AuthMiddleware.
+func LoadPermissions(resolver PermissionResolver) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		permisos, err := resolver.Permisos(c.Request.Context(), c.GetString("userRol"))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error obteniendo permisos"})
+			c.Abort()
+			return
+		}
+
+		if scopes, ok := c.Get("tokenScopes"); ok {
+			permisos = limitToScopes(permisos, scopes.([]string))
+		}
+
+		c.Set("userPermisos", permisos)
+		c.Next()
+	}
+}
+
+// RequirePermission rechaza la petición si el usuario no tiene el permiso.
+func RequirePermission(permiso string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !HasPermission(c, permiso) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Acceso denegado: se requiere el permiso " + permiso})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// HasPermission indica si el usuario autenticado tiene el permiso.
+func HasPermission(c *gin.Context, permiso string) bool {
+	return auth.HasPermission(c.GetStringSlice("userPermisos"), permiso)
+}
+
+// limitToScopes devuelve los scopes cubiertos por los permisos del rol.
+func limitToScopes(permisos, scopes []string) []string {
+	limitados := make([]string, 0, len(scopes))
+	for _, scope := range scopes {
+		if auth.HasPermission(permisos, scope) {
+			limitados = append(limitados, scope)
+		}
+	}
+	return limitados
+}
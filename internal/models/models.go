@@ -1,116 +1,1096 @@
-package models
-
-import (
-	"time"
-
-	"go.mongodb.org/mongo-driver/bson/primitive"
-)
-
-type Usuario struct {
-	ID           primitive.ObjectID `bson:"_id,omitempty" json:"id"`
-	Nombre       string             `bson:"nombre" json:"nombre" binding:"required"`
-	Email        string             `bson:"email" json:"email" binding:"required,email"`
-	PasswordHash string             `bson:"passwordHash,omitempty" json:"-"`
-	Foto         string             `bson:"foto,omitempty" json:"foto"`
-	GoogleID     string             `bson:"googleId,omitempty" json:"googleId"`
-	Rol          string             `bson:"rol" json:"rol"`
-	Estado       string             `bson:"estado" json:"estado"` // pending, active, suspended
-	CreatedAt    time.Time          `bson:"createdAt" json:"createdAt"`
-	UpdatedAt    time.Time          `bson:"updatedAt" json:"updatedAt"`
-}
-
-type Categoria struct {
-	ID        primitive.ObjectID  `bson:"_id,omitempty" json:"id"`
-	Nombre    string              `bson:"nombre" json:"nombre" binding:"required"`
-	Tipo      string              `bson:"tipo" json:"tipo" binding:"required"` // ingreso, egreso, ambos
-	Color     string              `bson:"color" json:"color"`
-	UsuarioID *primitive.ObjectID `bson:"usuarioId,omitempty" json:"usuarioId"` // null para categorías globales
-	CreatedAt time.Time           `bson:"createdAt" json:"createdAt"`
-	UpdatedAt time.Time           `bson:"updatedAt" json:"updatedAt"`
-}
-
-type Transaccion struct {
-	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id"`
-	UsuarioID   primitive.ObjectID `bson:"usuarioId" json:"usuarioId" binding:"required"`
-	Tipo        string             `bson:"tipo" json:"tipo" binding:"required"` // ingreso, egreso, prestamo, alquiler, otro
-	CategoriaID primitive.ObjectID `bson:"categoriaId" json:"categoriaId" binding:"required"`
-	Monto       float64            `bson:"monto" json:"monto" binding:"required,gt=0"`
-	Moneda      string             `bson:"moneda" json:"moneda"`
-	Fecha       time.Time          `bson:"fecha" json:"fecha" binding:"required"`
-	Descripcion string             `bson:"descripcion" json:"descripcion"`
-	Cuenta      *Cuenta            `bson:"cuenta,omitempty" json:"cuenta"`
-	MetodoPago  string             `bson:"metodoPago,omitempty" json:"metodoPago"`
-	Tags        []string           `bson:"tags,omitempty" json:"tags"`
-	Referencia  string             `bson:"referencia,omitempty" json:"referencia"`
-	CreatedAt   time.Time          `bson:"createdAt" json:"createdAt"`
-	UpdatedAt   time.Time          `bson:"updatedAt" json:"updatedAt"`
-}
-
-type Cuenta struct {
-	ID     primitive.ObjectID `bson:"id,omitempty" json:"id"`
-	Nombre string             `bson:"nombre" json:"nombre"`
-}
-
-type RefreshToken struct {
-	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
-	UsuarioID primitive.ObjectID `bson:"usuarioId" json:"usuarioId"`
-	Token     string             `bson:"token" json:"token"`
-	ExpiresAt time.Time          `bson:"expiresAt" json:"expiresAt"`
-	Revoked   bool               `bson:"revoked" json:"revoked"`
-	CreatedAt time.Time          `bson:"createdAt" json:"createdAt"`
-}
-
-type Rol struct {
-	ID       primitive.ObjectID `bson:"_id,omitempty" json:"id"`
-	Nombre   string             `bson:"nombre" json:"nombre"`
-	Permisos []string           `bson:"permisos" json:"permisos"`
-}
-
-type AuditLog struct {
-	ID        primitive.ObjectID  `bson:"_id,omitempty" json:"id"`
-	UsuarioID *primitive.ObjectID `bson:"usuarioId,omitempty" json:"usuarioId"`
-	Accion    string              `bson:"accion" json:"accion"`
-	Detalle   interface{}         `bson:"detalle,omitempty" json:"detalle"`
-	IP        string              `bson:"ip,omitempty" json:"ip"`
-	UserAgent string              `bson:"userAgent,omitempty" json:"userAgent"`
-	CreatedAt time.Time           `bson:"createdAt" json:"createdAt"`
-}
-
-// DTOs para requests/responses
-type RegisterRequest struct {
-	Nombre   string `json:"nombre" binding:"required"`
-	Email    string `json:"email" binding:"required,email"`
-	Password string `json:"password" binding:"required,min=6"`
-}
-
-type LoginRequest struct {
-	Email    string `json:"email" binding:"required,email"`
-	Password string `json:"password" binding:"required"`
-}
-
-type LoginResponse struct {
-	AccessToken  string   `json:"accessToken"`
-	RefreshToken string   `json:"refreshToken"`
-	Usuario      *Usuario `json:"usuario"`
-}
-
-type RefreshRequest struct {
-	RefreshToken string `json:"refreshToken" binding:"required"`
-}
-
-type ChangePasswordRequest struct {
-	CurrentPassword string `json:"currentPassword" binding:"required"`
-	NewPassword     string `json:"newPassword" binding:"required,min=6"`
-}
-
-type ReporteRequest struct {
-	Year  int `form:"year" binding:"required"`
-	Month int `form:"month" binding:"required,min=1,max=12"`
-}
-
-type EstadisticasResponse struct {
-	TotalIngresos float64            `json:"totalIngresos"`
-	TotalEgresos  float64            `json:"totalEgresos"`
-	Balance       float64            `json:"balance"`
-	PorCategoria  map[string]float64 `json:"porCategoria"`
-}
+package models
+
+import (
+	"time"
+
+	"control-financiero/internal/money"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type Usuario struct {
+	ID              primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Nombre          string             `bson:"nombre" json:"nombre" binding:"required"`
+	Email           string             `bson:"email" json:"email" binding:"required,email"`
+	EmailVerificado bool               `bson:"emailVerificado" json:"emailVerificado"`
+	PasswordHash    string             `bson:"passwordHash,omitempty" json:"-"`
+	Foto            string             `bson:"foto,omitempty" json:"foto"`
+	MonedaBase      string             `bson:"monedaBase,omitempty" json:"monedaBase"` // ISO 4217; vacía usa money.DefaultCurrency
+	Identidades     []Identidad        `bson:"identidades,omitempty" json:"identidades"`
+	TwoFactor       *TwoFactor         `bson:"twoFactor,omitempty" json:"twoFactor,omitempty"`
+	Rol             string             `bson:"rol" json:"rol"`
+	Estado          string             `bson:"estado" json:"estado"` // pending, active, suspended
+	CreatedAt       time.Time          `bson:"createdAt" json:"createdAt"`
+	UpdatedAt       time.Time          `bson:"updatedAt" json:"updatedAt"`
+}
+
+// Identidad es una cuenta de un proveedor OIDC vinculada al usuario.
+type Identidad struct {
+	Provider string    `bson:"provider" json:"provider"`
+	Subject  string    `bson:"subject" json:"-"`
+	Email    string    `bson:"email,omitempty" json:"email"`
+	LinkedAt time.Time `bson:"linkedAt" json:"linkedAt"`
+}
+
+// TwoFactor es la configuración TOTP del usuario. Mientras Enabled sea false
+// la inscripción está pendiente de confirmar con un primer código.
+type TwoFactor struct {
+	Secret        string     `bson:"secret" json:"-"`
+	Enabled       bool       `bson:"enabled" json:"enabled"`
+	RecoveryCodes []string   `bson:"recoveryCodes,omitempty" json:"-"` // hashes SHA-256
+	LastStep      int64      `bson:"lastStep" json:"-"`                // último paso TOTP aceptado
+	EnabledAt     *time.Time `bson:"enabledAt,omitempty" json:"enabledAt,omitempty"`
+}
+
+func (u *Usuario) TwoFactorEnabled() bool {
+	return u.TwoFactor != nil && u.TwoFactor.Enabled
+}
+
+// Moneda devuelve la moneda base del usuario, en la que se expresan sus
+// reportes.
+func (u *Usuario) Moneda() string {
+	if u.MonedaBase == "" {
+		return money.DefaultCurrency
+	}
+	return u.MonedaBase
+}
+
+type Categoria struct {
+	ID        primitive.ObjectID  `bson:"_id,omitempty" json:"id"`
+	Nombre    string              `bson:"nombre" json:"nombre" binding:"required"`
+	Tipo      string              `bson:"tipo" json:"tipo" binding:"required"` // ingreso, egreso, ambos
+	Color     string              `bson:"color" json:"color"`
+	UsuarioID *primitive.ObjectID `bson:"usuarioId,omitempty" json:"usuarioId"` // null para categorías globales
+	CreatedAt time.Time           `bson:"createdAt" json:"createdAt"`
+	UpdatedAt time.Time           `bson:"updatedAt" json:"updatedAt"`
+}
+
+type Transaccion struct {
+	ID            primitive.ObjectID   `bson:"_id,omitempty" json:"id"`
+	UsuarioID     primitive.ObjectID   `bson:"usuarioId" json:"usuarioId" binding:"required"`
+	Tipo          string               `bson:"tipo" json:"tipo" binding:"required"` // ingreso, egreso, prestamo, alquiler, otro, transferencia
+	CategoriaID   primitive.ObjectID   `bson:"categoriaId,omitempty" json:"categoriaId" binding:"required_unless=Tipo transferencia"`
+	Monto         money.Amount         `bson:"monto" json:"monto" binding:"required,gt=0"`
+	Moneda        string               `bson:"moneda" json:"moneda"` // ISO 4217; vacía usa la moneda base
+	Fecha         time.Time            `bson:"fecha" json:"fecha" binding:"required"`
+	Descripcion   string               `bson:"descripcion" json:"descripcion"`
+	CuentaID      *primitive.ObjectID  `bson:"cuentaId,omitempty" json:"cuentaId"`
+	MetodoPago    string               `bson:"metodoPago,omitempty" json:"metodoPago"`
+	Tags          []string             `bson:"tags,omitempty" json:"tags"`
+	Divisiones    []Division           `bson:"divisiones,omitempty" json:"divisiones,omitempty" binding:"dive"` // opcional; deben sumar Monto
+	Referencia    string               `bson:"referencia,omitempty" json:"referencia"`
+	MontoBase     *money.Amount        `bson:"montoBase,omitempty" json:"montoBase,omitempty"` // en MonedaBase con el cambio de la fecha; lo calcula el servicio
+	MonedaBase    string               `bson:"monedaBase,omitempty" json:"monedaBase,omitempty"`
+	TipoCambio    *money.Rate          `bson:"tipoCambio,omitempty" json:"tipoCambio,omitempty"`
+	Transferencia *EnlaceTransferencia `bson:"transferencia,omitempty" json:"transferencia,omitempty"` // solo en las patas de una transferencia
+	Recurrencia   *EnlaceRecurrencia   `bson:"recurrencia,omitempty" json:"recurrencia,omitempty"`     // solo en las que registra una recurrencia
+	Prestamo      *EnlacePrestamo      `bson:"prestamo,omitempty" json:"prestamo,omitempty"`           // desembolso o cuota de un préstamo
+	Contrato      *EnlaceContrato      `bson:"contrato,omitempty" json:"contrato,omitempty"`           // cobro del alquiler de un contrato
+	PropiedadID   *primitive.ObjectID  `bson:"propiedadId,omitempty" json:"propiedadId,omitempty"`     // propiedad alquilada a la que corresponde
+	ImportacionID *primitive.ObjectID  `bson:"importacionId,omitempty" json:"importacionId,omitempty"` // lote de importación que la creó
+	Bancario      *DatosBancarios      `bson:"bancario,omitempty" json:"bancario,omitempty"`           // datos del extracto del que se importó
+	CreatedAt     time.Time            `bson:"createdAt" json:"createdAt"`
+	UpdatedAt     time.Time            `bson:"updatedAt" json:"updatedAt"`
+}
+
+// Importe es el efecto de la transacción en el saldo de su cuenta: los
+// ingresos y la entrada de una transferencia suman, los egresos y la salida
+// restan, los movimientos de un préstamo suman o restan según su sentido y
+// los cobros de alquiler de un contrato suman. Los demás tipos no lo
+// modifican.
+func (t *Transaccion) Importe() money.Amount {
+	switch t.Tipo {
+	case "ingreso":
+		return t.Monto
+	case "egreso":
+		return -t.Monto
+	case "transferencia":
+		if t.Transferencia != nil && t.Transferencia.Sentido == TransferenciaSalida {
+			return -t.Monto
+		}
+		return t.Monto
+	case "prestamo":
+		if t.Prestamo == nil {
+			return 0
+		}
+		if t.Prestamo.Sentido == PrestamoSalida {
+			return -t.Monto
+		}
+		return t.Monto
+	case "alquiler":
+		if t.Contrato == nil {
+			return 0
+		}
+		return t.Monto
+	}
+	return 0
+}
+
+// Division es una línea de una transacción repartida entre varias
+// categorías, por ejemplo un ticket de supermercado con comida y limpieza.
+// Los reportes por categoría usan las divisiones en lugar de la categoría de
+// la transacción.
+type Division struct {
+	CategoriaID primitive.ObjectID `bson:"categoriaId" json:"categoriaId" binding:"required"`
+	Monto       money.Amount       `bson:"monto" json:"monto" binding:"required,gt=0"` // en la moneda de la transacción
+	Nota        string             `bson:"nota,omitempty" json:"nota"`
+	Tags        []string           `bson:"tags,omitempty" json:"tags"`
+}
+
+// Sentidos de las patas de una transferencia.
+const (
+	TransferenciaSalida  = "salida"  // en la cuenta de origen
+	TransferenciaEntrada = "entrada" // en la cuenta de destino
+)
+
+// EnlaceTransferencia une las dos transacciones de una transferencia entre
+// cuentas, que se crean, editan y eliminan juntas. No cuentan como ingresos
+// ni egresos en las estadísticas.
+type EnlaceTransferencia struct {
+	ID      primitive.ObjectID `bson:"id" json:"id"` // común a las dos patas
+	Sentido string             `bson:"sentido" json:"sentido"`
+	Tasa    money.Rate         `bson:"tasa" json:"tasa"` // unidades de la moneda de destino por unidad de la de origen
+}
+
+// Transferencia son las dos patas de una transferencia entre cuentas.
+type Transferencia struct {
+	ID      primitive.ObjectID `bson:"id" json:"id"`
+	Salida  *Transaccion       `bson:"salida" json:"salida"`
+	Entrada *Transaccion       `bson:"entrada" json:"entrada"`
+}
+
+// TransferenciaRequest mueve Monto, en la moneda de la cuenta de origen, a
+// la cuenta de destino. Entre cuentas de distinta moneda hay que indicar el
+// tipo de cambio aplicado.
+type TransferenciaRequest struct {
+	CuentaOrigenID  primitive.ObjectID `json:"cuentaOrigenId" binding:"required"`
+	CuentaDestinoID primitive.ObjectID `json:"cuentaDestinoId" binding:"required"`
+	Monto           money.Amount       `json:"monto" binding:"required,gt=0"`
+	TipoCambio      *money.Rate        `json:"tipoCambio" binding:"omitempty,gt=0"` // unidades de destino por unidad de origen
+	Fecha           time.Time          `json:"fecha" binding:"required"`
+	Descripcion     string             `json:"descripcion"`
+	Tags            []string           `json:"tags"`
+}
+
+// Frecuencias de una recurrencia. Con FrecuenciaRRule la regla se escribe
+// como una RRULE de RFC 5545, con el subconjunto que admite el paquete
+// recurrence.
+const (
+	FrecuenciaDiaria         = "diaria"
+	FrecuenciaSemanal        = "semanal"          // el día de la semana de Inicio
+	FrecuenciaMensual        = "mensual"          // el día DiaMes, o el último si el mes es más corto
+	FrecuenciaUltimoDiaHabil = "ultimo_dia_habil" // último día de lunes a viernes del mes
+	FrecuenciaAnual          = "anual"            // el día y mes de Inicio
+	FrecuenciaRRule          = "rrule"
+)
+
+// Recurrencia es una plantilla de transacción que se repite, como el sueldo o
+// un alquiler. El programador registra cada ocurrencia vencida como una
+// transacción enlazada con la plantilla; las ya registradas no cambian al
+// editarla.
+type Recurrencia struct {
+	ID          primitive.ObjectID     `bson:"_id,omitempty" json:"id"`
+	UsuarioID   primitive.ObjectID     `bson:"usuarioId" json:"usuarioId"`
+	Nombre      string                 `bson:"nombre" json:"nombre" binding:"required,max=100"`
+	Tipo        string                 `bson:"tipo" json:"tipo" binding:"required,oneof=ingreso egreso prestamo alquiler otro"`
+	CategoriaID primitive.ObjectID     `bson:"categoriaId" json:"categoriaId" binding:"required"`
+	Monto       money.Amount           `bson:"monto" json:"monto" binding:"required,gt=0"`
+	Moneda      string                 `bson:"moneda" json:"moneda"` // ISO 4217; vacía usa la de la cuenta o la moneda base
+	Descripcion string                 `bson:"descripcion" json:"descripcion"`
+	CuentaID    *primitive.ObjectID    `bson:"cuentaId,omitempty" json:"cuentaId"`
+	MetodoPago  string                 `bson:"metodoPago,omitempty" json:"metodoPago"`
+	Tags        []string               `bson:"tags,omitempty" json:"tags"`
+	Frecuencia  string                 `bson:"frecuencia" json:"frecuencia" binding:"required,oneof=diaria semanal mensual ultimo_dia_habil anual rrule"`
+	Intervalo   int                    `bson:"intervalo,omitempty" json:"intervalo" binding:"min=0,max=1000"` // cada cuántos periodos; 0 equivale a 1
+	DiaMes      int                    `bson:"diaMes,omitempty" json:"diaMes" binding:"min=0,max=31"`         // solo mensual; 0 usa el día de Inicio
+	RRule       string                 `bson:"rrule,omitempty" json:"rrule" binding:"required_if=Frecuencia rrule,max=500"`
+	Inicio      time.Time              `bson:"inicio" json:"inicio" binding:"required"`
+	Fin         *time.Time             `bson:"fin,omitempty" json:"fin"` // último día posible, inclusive
+	Pausada     bool                   `bson:"pausada" json:"pausada"`   // sus ocurrencias se saltan sin registrarse
+	Excepciones []ExcepcionRecurrencia `bson:"excepciones,omitempty" json:"excepciones"`
+	UltimaFecha *time.Time             `bson:"ultimaFecha,omitempty" json:"ultimaFecha"` // última ocurrencia procesada
+	Proxima     *time.Time             `bson:"proxima,omitempty" json:"proxima"`         // siguiente ocurrencia; nil si no quedan
+	Error       string                 `bson:"error,omitempty" json:"error,omitempty"`   // por qué no se pudo registrar Proxima
+	CreatedAt   time.Time              `bson:"createdAt" json:"createdAt"`
+	UpdatedAt   time.Time              `bson:"updatedAt" json:"updatedAt"`
+}
+
+// Excepcion devuelve la excepción de la ocurrencia de fecha, o nil.
+func (r *Recurrencia) Excepcion(fecha time.Time) *ExcepcionRecurrencia {
+	for i := range r.Excepciones {
+		if r.Excepciones[i].Fecha.Equal(fecha) {
+			return &r.Excepciones[i]
+		}
+	}
+	return nil
+}
+
+// ExcepcionRecurrencia cambia una sola ocurrencia de una recurrencia: la
+// omite o registra otro monto o descripción.
+type ExcepcionRecurrencia struct {
+	Fecha       time.Time     `bson:"fecha" json:"fecha"` // medianoche UTC de la ocurrencia
+	Omitir      bool          `bson:"omitir" json:"omitir"`
+	Monto       *money.Amount `bson:"monto,omitempty" json:"monto,omitempty"`
+	Descripcion string        `bson:"descripcion,omitempty" json:"descripcion,omitempty"`
+}
+
+// ExcepcionRecurrenciaRequest es el cuerpo con que se omite o modifica una
+// ocurrencia.
+type ExcepcionRecurrenciaRequest struct {
+	Omitir      bool          `json:"omitir"`
+	Monto       *money.Amount `json:"monto" binding:"omitempty,gt=0"`
+	Descripcion string        `json:"descripcion" binding:"max=500"`
+}
+
+// OcurrenciasQuery son los parámetros de la vista previa de una recurrencia.
+type OcurrenciasQuery struct {
+	Limite int `form:"limite" binding:"min=0"`
+}
+
+// OcurrenciaRecurrencia es una ocurrencia pendiente de una recurrencia, con
+// su excepción aplicada.
+type OcurrenciaRecurrencia struct {
+	Fecha       time.Time    `json:"fecha"`
+	Monto       money.Amount `json:"monto"`
+	Moneda      string       `json:"moneda"`
+	Descripcion string       `json:"descripcion"`
+	Omitida     bool         `json:"omitida"`
+	Modificada  bool         `json:"modificada"`
+}
+
+// EnlaceRecurrencia une una transacción con la recurrencia que la registró.
+// Fecha es la de la ocurrencia y, con ID, identifica la transacción: cada
+// ocurrencia se registra una sola vez.
+type EnlaceRecurrencia struct {
+	ID    primitive.ObjectID `bson:"id" json:"id"`
+	Fecha time.Time          `bson:"fecha" json:"fecha"`
+}
+
+// Sentidos de un préstamo.
+const (
+	PrestamoOtorgado = "otorgado" // el usuario prestó el dinero y cobra las cuotas
+	PrestamoRecibido = "recibido" // el usuario recibió el dinero y paga las cuotas
+)
+
+// Sistemas de amortización de un préstamo.
+const (
+	AmortizacionFrancesa = "frances" // cuota constante: el interés baja y la amortización sube
+	AmortizacionAlemana  = "aleman"  // amortización constante: la cuota baja con el interés
+)
+
+// Sentidos del dinero en un movimiento de préstamo, vistos desde la cuenta
+// del usuario.
+const (
+	PrestamoEntrada = "entrada"
+	PrestamoSalida  = "salida"
+)
+
+// Estados de una cuota de un préstamo.
+const (
+	CuotaPendiente = "pendiente"
+	CuotaParcial   = "parcial" // pagada en parte y aún no vencida
+	CuotaPagada    = "pagada"
+	CuotaVencida   = "vencida" // venció sin pagarse del todo
+)
+
+// Prestamo es un préstamo otorgado o recibido que se devuelve en cuotas
+// mensuales. El cronograma se calcula al crearlo; el desembolso y los pagos
+// son transacciones de tipo prestamo enlazadas con él, y el saldo, los
+// intereses pagados y las cuotas vencidas se calculan a partir de ellas.
+type Prestamo struct {
+	ID             primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UsuarioID      primitive.ObjectID `bson:"usuarioId" json:"usuarioId"`
+	Nombre         string             `bson:"nombre" json:"nombre" binding:"required,max=100"`
+	Contraparte    string             `bson:"contraparte" json:"contraparte" binding:"required,max=100"` // a quién se prestó o quién prestó
+	Sentido        string             `bson:"sentido" json:"sentido" binding:"required,oneof=otorgado recibido"`
+	Principal      money.Amount       `bson:"principal" json:"principal" binding:"required,gt=0"`
+	Moneda         string             `bson:"moneda" json:"moneda"`                                // ISO 4217; vacía usa la moneda base
+	TasaAnual      money.Rate         `bson:"tasaAnual" json:"tasaAnual" binding:"min=0"`          // porcentaje nominal anual: 12.5 es 12,5 %
+	Plazo          int                `bson:"plazo" json:"plazo" binding:"required,min=1,max=600"` // cantidad de cuotas mensuales
+	Sistema        string             `bson:"sistema" json:"sistema" binding:"required,oneof=frances aleman"`
+	Inicio         time.Time          `bson:"inicio" json:"inicio" binding:"required"` // desembolso; la primera cuota vence un mes después
+	Cuotas         []CuotaPrestamo    `bson:"cuotas" json:"cuotas"`                    // lo calcula el servicio
+	Desembolsado   money.Amount       `bson:"-" json:"desembolsado"`
+	CapitalPagado  money.Amount       `bson:"-" json:"capitalPagado"`
+	InteresPagado  money.Amount       `bson:"-" json:"interesPagado"`
+	Saldo          money.Amount       `bson:"-" json:"saldo"` // capital pendiente: Principal menos CapitalPagado
+	CuotasVencidas int                `bson:"-" json:"cuotasVencidas"`
+	MontoVencido   money.Amount       `bson:"-" json:"montoVencido"` // lo que falta pagar de las cuotas vencidas
+	CreatedAt      time.Time          `bson:"createdAt" json:"createdAt"`
+	UpdatedAt      time.Time          `bson:"updatedAt" json:"updatedAt"`
+}
+
+// CuotaPrestamo es una fila del cronograma de un préstamo. Los pagos de una
+// cuota cubren primero el interés y luego el capital.
+type CuotaPrestamo struct {
+	Numero      int          `bson:"numero" json:"numero"`
+	Vencimiento time.Time    `bson:"vencimiento" json:"vencimiento"`
+	Capital     money.Amount `bson:"capital" json:"capital"`
+	Interes     money.Amount `bson:"interes" json:"interes"`
+	Total       money.Amount `bson:"total" json:"total"`
+	Saldo       money.Amount `bson:"saldo" json:"saldo"` // capital pendiente después de pagarla
+	Pagado      money.Amount `bson:"-" json:"pagado"`
+	Estado      string       `bson:"-" json:"estado"`
+}
+
+// EnlacePrestamo une una transacción de tipo prestamo con el préstamo que
+// desembolsa (Cuota 0) o con la cuota que paga. Sentido lo calcula el
+// servicio a partir del sentido del préstamo.
+type EnlacePrestamo struct {
+	ID      primitive.ObjectID `bson:"id" json:"id" binding:"required"`
+	Cuota   int                `bson:"cuota" json:"cuota" binding:"min=0"`
+	Sentido string             `bson:"sentido" json:"sentido"` // PrestamoEntrada o PrestamoSalida
+}
+
+// PagoCuota es lo pagado de una cuota de un préstamo, o lo desembolsado si
+// Cuota es 0.
+type PagoCuota struct {
+	PrestamoID primitive.ObjectID `bson:"prestamoId"`
+	Cuota      int                `bson:"cuota"`
+	Pagado     money.Amount       `bson:"pagado"`
+}
+
+// Propiedad es un inmueble que el usuario alquila, entero o por unidades
+// (departamentos, locales, habitaciones).
+type Propiedad struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UsuarioID primitive.ObjectID `bson:"usuarioId" json:"usuarioId"`
+	Nombre    string             `bson:"nombre" json:"nombre" binding:"required,max=100"`
+	Direccion string             `bson:"direccion,omitempty" json:"direccion" binding:"max=200"`
+	Unidades  []UnidadPropiedad  `bson:"unidades" json:"unidades" binding:"dive"`
+	CreatedAt time.Time          `bson:"createdAt" json:"createdAt"`
+	UpdatedAt time.Time          `bson:"updatedAt" json:"updatedAt"`
+}
+
+// UnidadPropiedad es una parte de una propiedad que se alquila por separado.
+type UnidadPropiedad struct {
+	ID     primitive.ObjectID `bson:"id" json:"id"` // el servicio lo asigna a las nuevas
+	Nombre string             `bson:"nombre" json:"nombre" binding:"required,max=100"`
+}
+
+// Inquilino es una persona o empresa que alquila una propiedad del usuario.
+type Inquilino struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UsuarioID primitive.ObjectID `bson:"usuarioId" json:"usuarioId"`
+	Nombre    string             `bson:"nombre" json:"nombre" binding:"required,max=100"`
+	Documento string             `bson:"documento,omitempty" json:"documento" binding:"max=30"`
+	Email     string             `bson:"email,omitempty" json:"email" binding:"omitempty,email"`
+	Telefono  string             `bson:"telefono,omitempty" json:"telefono" binding:"max=30"`
+	Notas     string             `bson:"notas,omitempty" json:"notas" binding:"max=500"`
+	CreatedAt time.Time          `bson:"createdAt" json:"createdAt"`
+	UpdatedAt time.Time          `bson:"updatedAt" json:"updatedAt"`
+}
+
+// Estados de un cargo de alquiler.
+const (
+	CargoPendiente = "pendiente"
+	CargoParcial   = "parcial" // cobrado en parte y aún no vencido
+	CargoPagado    = "pagado"
+	CargoVencido   = "vencido" // venció sin cobrarse del todo
+)
+
+// Contrato es el alquiler de una propiedad, o de una de sus unidades, a un
+// inquilino. Cada mes desde Inicio genera un cargo por la renta, indexada
+// según Indexacion; los cobros son transacciones de tipo alquiler enlazadas
+// con él y cancelan los cargos por orden de vencimiento.
+type Contrato struct {
+	ID             primitive.ObjectID  `bson:"_id,omitempty" json:"id"`
+	UsuarioID      primitive.ObjectID  `bson:"usuarioId" json:"usuarioId"`
+	PropiedadID    primitive.ObjectID  `bson:"propiedadId" json:"propiedadId" binding:"required"`
+	UnidadID       *primitive.ObjectID `bson:"unidadId,omitempty" json:"unidadId"` // nil alquila la propiedad entera
+	InquilinoID    primitive.ObjectID  `bson:"inquilinoId" json:"inquilinoId" binding:"required"`
+	Renta          money.Amount        `bson:"renta" json:"renta" binding:"required,gt=0"` // mensual, antes de indexar
+	Moneda         string              `bson:"moneda" json:"moneda"`                       // ISO 4217; vacía usa la moneda base
+	DiaVencimiento int                 `bson:"diaVencimiento" json:"diaVencimiento" binding:"required,min=1,max=31"`
+	Deposito       money.Amount        `bson:"deposito" json:"deposito" binding:"min=0"` // garantía; no es un ingreso
+	Inicio         time.Time           `bson:"inicio" json:"inicio" binding:"required"`
+	Fin            *time.Time          `bson:"fin,omitempty" json:"fin"` // nil mientras siga vigente
+	Indexacion     *IndexacionContrato `bson:"indexacion,omitempty" json:"indexacion"`
+	Cargos         []CargoAlquiler     `bson:"-" json:"cargos"` // hasta el mes actual; los calcula el servicio
+	Cobrado        money.Amount        `bson:"-" json:"cobrado"`
+	Deuda          money.Amount        `bson:"-" json:"deuda"`       // lo que falta cobrar de los cargos vencidos
+	SaldoAFavor    money.Amount        `bson:"-" json:"saldoAFavor"` // lo cobrado por encima de los cargos generados
+	CargosVencidos int                 `bson:"-" json:"cargosVencidos"`
+	CreatedAt      time.Time           `bson:"createdAt" json:"createdAt"`
+	UpdatedAt      time.Time           `bson:"updatedAt" json:"updatedAt"`
+}
+
+// ContratoQuery filtra el listado de contratos.
+type ContratoQuery struct {
+	PropiedadID string `form:"propiedadId"`
+	InquilinoID string `form:"inquilinoId"`
+}
+
+// IndexacionContrato actualiza la renta cada Meses meses en Porcentaje,
+// sobre la renta ya indexada.
+type IndexacionContrato struct {
+	Porcentaje money.Rate `bson:"porcentaje" json:"porcentaje" binding:"gt=0"`
+	Meses      int        `bson:"meses" json:"meses" binding:"required,min=1,max=120"`
+}
+
+// CargoAlquiler es la renta de un mes de un contrato.
+type CargoAlquiler struct {
+	Periodo     string       `json:"periodo"` // YYYY-MM
+	Vencimiento time.Time    `json:"vencimiento"`
+	Monto       money.Amount `json:"monto"`
+	Pagado      money.Amount `json:"pagado"`
+	Estado      string       `json:"estado"`
+}
+
+// EnlaceContrato une una transacción de tipo alquiler con el contrato cuya
+// renta cobra.
+type EnlaceContrato struct {
+	ID primitive.ObjectID `bson:"id" json:"id" binding:"required"`
+}
+
+// Morosidad es la deuda vencida de un inquilino en una moneda.
+type Morosidad struct {
+	InquilinoID    primitive.ObjectID `json:"inquilinoId"`
+	Inquilino      string             `json:"inquilino"`
+	Moneda         string             `json:"moneda"`
+	Deuda          money.Amount       `json:"deuda"`
+	CargosVencidos int                `json:"cargosVencidos"`
+	VencidaDesde   time.Time          `json:"vencidaDesde"` // vencimiento del cargo impago más antiguo
+}
+
+// ResultadosPropiedad es el estado de resultados de una propiedad en un
+// rango de fechas, con los importes en Moneda, la moneda base del usuario.
+type ResultadosPropiedad struct {
+	PropiedadID   primitive.ObjectID      `json:"propiedadId"`
+	Moneda        string                  `json:"moneda"`
+	Desde         *time.Time              `json:"desde"`
+	Hasta         *time.Time              `json:"hasta"`
+	RentaEsperada money.Amount            `json:"rentaEsperada"` // cargos que vencen en el rango
+	Alquileres    money.Amount            `json:"alquileres"`    // cobros de los contratos de la propiedad
+	Ingresos      money.Amount            `json:"ingresos"`      // otros ingresos asignados a la propiedad
+	Egresos       money.Amount            `json:"egresos"`
+	Resultado     money.Amount            `json:"resultado"`
+	PorCategoria  map[string]money.Amount `json:"porCategoria"`
+}
+
+// ResultadosQuery son los parámetros del estado de resultados de una
+// propiedad.
+type ResultadosQuery struct {
+	Desde string `form:"desde"`
+	Hasta string `form:"hasta"`
+}
+
+// Cuenta es una cuenta del usuario: un banco, efectivo, una tarjeta de
+// crédito o una billetera digital. Su saldo no se guarda, se calcula a partir
+// de SaldoInicial y de las transacciones que la referencian.
+type Cuenta struct {
+	ID           primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UsuarioID    primitive.ObjectID `bson:"usuarioId" json:"usuarioId"`
+	Nombre       string             `bson:"nombre" json:"nombre" binding:"required,max=100"`
+	Tipo         string             `bson:"tipo" json:"tipo" binding:"required,oneof=banco efectivo tarjeta_credito billetera"`
+	Moneda       string             `bson:"moneda" json:"moneda"` // ISO 4217; vacía usa la moneda base
+	SaldoInicial money.Amount       `bson:"saldoInicial" json:"saldoInicial"`
+	NumeroCuenta string             `bson:"numeroCuenta,omitempty" json:"numeroCuenta,omitempty" binding:"max=50"` // en el banco; enlaza los extractos OFX con la cuenta
+	Archivada    bool               `bson:"archivada" json:"archivada"`
+	Saldo        money.Amount       `bson:"-" json:"saldo"` // SaldoInicial más los importes de sus transacciones
+	CreatedAt    time.Time          `bson:"createdAt" json:"createdAt"`
+	UpdatedAt    time.Time          `bson:"updatedAt" json:"updatedAt"`
+}
+
+// MovimientoCuenta es una fila del extracto de una cuenta.
+type MovimientoCuenta struct {
+	*Transaccion
+	Importe money.Amount `json:"importe"` // con signo, ver Transaccion.Importe
+	Saldo   money.Amount `json:"saldo"`   // saldo de la cuenta después del movimiento
+}
+
+// ExtractoCuenta es una página del extracto de una cuenta, de la transacción
+// más antigua a la más reciente.
+type ExtractoCuenta struct {
+	Cuenta        *Cuenta             `json:"cuenta"`
+	SaldoAnterior money.Amount        `json:"saldoAnterior"` // saldo antes del primer movimiento de la página
+	Movimientos   []*MovimientoCuenta `json:"movimientos"`
+	NextCursor    string              `json:"nextCursor,omitempty"`
+}
+
+// ExtractoQuery son los parámetros de consulta del extracto. Desde y Hasta
+// aceptan RFC 3339 o una fecha YYYY-MM-DD.
+type ExtractoQuery struct {
+	Desde  string `form:"desde"`
+	Hasta  string `form:"hasta"`
+	Limite int64  `form:"limite" binding:"min=0"`
+	Cursor string `form:"cursor"`
+}
+
+// HistorialQuery son los parámetros del historial de saldos de una cuenta.
+type HistorialQuery struct {
+	Desde     string `form:"desde"`
+	Hasta     string `form:"hasta"`
+	Intervalo string `form:"intervalo" binding:"omitempty,oneof=dia mes"`
+}
+
+// SaldoPeriodo es el saldo de una cuenta al final de un periodo (YYYY-MM-DD
+// o YYYY-MM) en el que tuvo movimientos.
+type SaldoPeriodo struct {
+	Periodo string       `bson:"_id" json:"periodo"`
+	Neto    money.Amount `bson:"neto" json:"neto"` // suma de los importes del periodo
+	Saldo   money.Amount `bson:"-" json:"saldo"`
+}
+
+// Criterios de orden del listado de transacciones. El prefijo "-" indica
+// orden descendente.
+const (
+	OrdenFechaDesc = "-fecha"
+	OrdenFechaAsc  = "fecha"
+	OrdenMontoDesc = "-monto"
+	OrdenMontoAsc  = "monto"
+)
+
+// TransaccionFilter son los criterios del listado de transacciones de un
+// usuario. Los campos vacíos no filtran.
+type TransaccionFilter struct {
+	UsuarioID   primitive.ObjectID
+	Desde       *time.Time
+	Hasta       *time.Time
+	Tipo        string
+	CategoriaID *primitive.ObjectID
+	CuentaID    *primitive.ObjectID
+	Tag         string
+	MetodoPago  string
+	MontoMin    *money.Amount
+	MontoMax    *money.Amount
+	Descripcion string // subcadena, sin distinguir mayúsculas
+	Orden       string
+	Limite      int64
+	Despues     *TransaccionCursor // continuar a partir de esta posición
+}
+
+// TransaccionCursor es la posición de la última transacción de una página:
+// el valor del campo de orden y el _id que desempata.
+type TransaccionCursor struct {
+	Orden string             `json:"o"`
+	Fecha time.Time          `json:"f"`
+	Monto money.Amount       `json:"m"`
+	ID    primitive.ObjectID `json:"id"`
+}
+
+// TransaccionPage es una página del listado. Total cuenta todas las
+// transacciones que cumplen el filtro y NextCursor está vacío en la última
+// página.
+type TransaccionPage struct {
+	Transacciones []*Transaccion `json:"transacciones"`
+	Total         int64          `json:"total"`
+	NextCursor    string         `json:"nextCursor,omitempty"`
+}
+
+// FormatoCSV describe cómo está escrito un CSV. Los campos vacíos de un
+// mapeo se detectan al leer el archivo.
+type FormatoCSV struct {
+	Codificacion     string `bson:"codificacion,omitempty" json:"codificacion,omitempty"`         // utf-8, utf-16le, utf-16be o windows-1252
+	Delimitador      string `bson:"delimitador,omitempty" json:"delimitador,omitempty"`           // , ; | o tab
+	FormatoFecha     string `bson:"formatoFecha,omitempty" json:"formatoFecha,omitempty"`         // como DD/MM/YYYY o YYYY-MM-DD
+	SeparadorDecimal string `bson:"separadorDecimal,omitempty" json:"separadorDecimal,omitempty"` // . o ,
+}
+
+// MapeoImportacion indica en qué columnas de un CSV bancario están los datos
+// de cada movimiento. Las columnas se indican por el nombre de la cabecera,
+// sin distinguir mayúsculas, o por su número empezando en 1. El monto está
+// en una columna con signo o repartido entre una de débitos y otra de
+// créditos.
+type MapeoImportacion struct {
+	FormatoCSV    `bson:",inline"`
+	SaltarLineas  int    `bson:"saltarLineas,omitempty" json:"saltarLineas" binding:"min=0,max=100"` // líneas antes de la cabecera
+	SinCabecera   bool   `bson:"sinCabecera,omitempty" json:"sinCabecera"`                           // las columnas se indican por número
+	Fecha         string `bson:"fecha" json:"fecha" binding:"required"`
+	Descripcion   string `bson:"descripcion,omitempty" json:"descripcion"`
+	Monto         string `bson:"monto,omitempty" json:"monto"`
+	Debito        string `bson:"debito,omitempty" json:"debito"`
+	Credito       string `bson:"credito,omitempty" json:"credito"`
+	Referencia    string `bson:"referencia,omitempty" json:"referencia"`
+	Moneda        string `bson:"moneda,omitempty" json:"moneda"`               // vacía usa la moneda de la cuenta
+	InvertirSigno bool   `bson:"invertirSigno,omitempty" json:"invertirSigno"` // para bancos que exportan los cargos en positivo
+}
+
+// PerfilImportacion guarda el mapeo de los CSV de un banco para no repetirlo
+// en cada importación.
+type PerfilImportacion struct {
+	ID        primitive.ObjectID  `bson:"_id,omitempty" json:"id"`
+	UsuarioID primitive.ObjectID  `bson:"usuarioId" json:"usuarioId"`
+	Nombre    string              `bson:"nombre" json:"nombre" binding:"required,max=100"` // normalmente el banco
+	Mapeo     MapeoImportacion    `bson:"mapeo" json:"mapeo"`
+	CuentaID  *primitive.ObjectID `bson:"cuentaId,omitempty" json:"cuentaId"` // cuenta por defecto de sus importaciones
+	CreatedAt time.Time           `bson:"createdAt" json:"createdAt"`
+	UpdatedAt time.Time           `bson:"updatedAt" json:"updatedAt"`
+}
+
+// DatosBancarios son los datos del movimiento en el extracto bancario que no
+// tienen campo propio en la transacción. Solo los dan los extractos camt.053
+// y MT940.
+type DatosBancarios struct {
+	Contraparte       string     `bson:"contraparte,omitempty" json:"contraparte,omitempty"`
+	CuentaContraparte string     `bson:"cuentaContraparte,omitempty" json:"cuentaContraparte,omitempty"` // IBAN u otro número de cuenta
+	Concepto          string     `bson:"concepto,omitempty" json:"concepto,omitempty"`
+	FechaValor        *time.Time `bson:"fechaValor,omitempty" json:"fechaValor,omitempty"` // Fecha es la contable
+}
+
+// Importacion es un lote de transacciones creadas a la vez desde un archivo.
+// Sus transacciones llevan su ID y se deshacen juntas.
+type Importacion struct {
+	ID        primitive.ObjectID  `bson:"_id,omitempty" json:"id"`
+	UsuarioID primitive.ObjectID  `bson:"usuarioId" json:"usuarioId"`
+	Origen    string              `bson:"origen" json:"origen"` // formato del archivo: csv, ofx, camt053 o mt940
+	Archivo   string              `bson:"archivo" json:"archivo"`
+	CuentaID  primitive.ObjectID  `bson:"cuentaId" json:"cuentaId"`
+	PerfilID  *primitive.ObjectID `bson:"perfilId,omitempty" json:"perfilId"`
+	Cantidad  int                 `bson:"cantidad" json:"cantidad"`
+	CreatedAt time.Time           `bson:"createdAt" json:"createdAt"`
+}
+
+// ImportacionRequest son los campos del formulario de importación, que
+// acompañan al archivo. Sin Confirmar solo se devuelve la vista previa.
+type ImportacionRequest struct {
+	CuentaID           string `form:"cuentaId"` // vacía usa la del perfil
+	PerfilID           string `form:"perfilId"`
+	Mapeo              string `form:"mapeo"` // MapeoImportacion en JSON; tiene prioridad sobre el perfil
+	CategoriaIngresoID string `form:"categoriaIngresoId"`
+	CategoriaEgresoID  string `form:"categoriaEgresoId"`
+	Confirmar          bool   `form:"confirmar"`
+	Omitir             []int  `form:"omitir"` // filas que no se importan
+}
+
+// OpcionesImportacion son los datos ya validados de ImportacionRequest.
+type OpcionesImportacion struct {
+	Archivo            string // nombre del archivo subido
+	CuentaID           *primitive.ObjectID
+	PerfilID           *primitive.ObjectID
+	Mapeo              *MapeoImportacion
+	CategoriaIngresoID *primitive.ObjectID
+	CategoriaEgresoID  *primitive.ObjectID
+	Confirmar          bool
+	Omitir             []int
+}
+
+// FilaImportacion es el resultado de leer una fila del archivo. Una fila con
+// errores, duplicada u omitida no se importa.
+type FilaImportacion struct {
+	Fila        int          `json:"fila"` // línea del CSV, o posición del movimiento en el extracto
+	Transaccion *Transaccion `json:"transaccion,omitempty"`
+	Duplicada   bool         `json:"duplicada"` // su referencia ya está en la cuenta o más arriba en el archivo
+	Omitida     bool         `json:"omitida"`
+	Errores     []string     `json:"errores,omitempty"`
+}
+
+// Conciliacion compara el saldo contable que informa el banco en un extracto
+// con el de la cuenta al final del mismo día.
+type Conciliacion struct {
+	Fecha       time.Time    `json:"fecha"`
+	SaldoBanco  money.Amount `json:"saldoBanco"`
+	SaldoCuenta money.Amount `json:"saldoCuenta"` // con las filas a importar, aunque aún no se confirmen
+	Diferencia  money.Amount `json:"diferencia"`  // SaldoBanco - SaldoCuenta
+}
+
+// ResultadoImportacion es la vista previa de una importación o, si se
+// confirmó, lo que se importó.
+type ResultadoImportacion struct {
+	Importacion  *Importacion       `json:"importacion,omitempty"`  // solo al confirmar
+	CuentaID     primitive.ObjectID `json:"cuentaId"`               // cuenta en que se registran las filas
+	Formato      *FormatoCSV        `json:"formato,omitempty"`      // el usado para leer un CSV
+	Conciliacion *Conciliacion      `json:"conciliacion,omitempty"` // si el extracto informa su saldo
+	Filas        []*FilaImportacion `json:"filas"`
+	Validas      int                `json:"validas"` // se importan al confirmar
+	ConErrores   int                `json:"conErrores"`
+	Duplicadas   int                `json:"duplicadas"`
+	Omitidas     int                `json:"omitidas"`
+}
+
+type RefreshToken struct {
+	ID          primitive.ObjectID  `bson:"_id,omitempty" json:"id"`
+	UsuarioID   primitive.ObjectID  `bson:"usuarioId" json:"usuarioId"`
+	TokenHash   string              `bson:"tokenHash" json:"-"`                     // SHA-256 del token, nunca el token en claro
+	FamilyID    primitive.ObjectID  `bson:"familyId" json:"familyId"`               // agrupa las rotaciones de una misma sesión
+	ReplacedBy  *primitive.ObjectID `bson:"replacedBy,omitempty" json:"replacedBy"` // token emitido al rotar este
+	Dispositivo string              `bson:"dispositivo,omitempty" json:"dispositivo"`
+	IP          string              `bson:"ip,omitempty" json:"ip"`
+	UserAgent   string              `bson:"userAgent,omitempty" json:"userAgent"`
+	StartedAt   time.Time           `bson:"startedAt" json:"startedAt"` // inicio de la sesión, se conserva al rotar
+	LastUsedAt  time.Time           `bson:"lastUsedAt" json:"lastUsedAt"`
+	ExpiresAt   time.Time           `bson:"expiresAt" json:"expiresAt"`
+	Revoked     bool                `bson:"revoked" json:"revoked"`
+	RevokedAt   *time.Time          `bson:"revokedAt,omitempty" json:"revokedAt"`
+	CreatedAt   time.Time           `bson:"createdAt" json:"createdAt"`
+}
+
+// Sesion es la vista pública de una familia de refresh tokens activa.
+type Sesion struct {
+	ID          primitive.ObjectID `json:"id"`
+	Dispositivo string             `json:"dispositivo"`
+	IP          string             `json:"ip"`
+	UserAgent   string             `json:"userAgent"`
+	StartedAt   time.Time          `json:"startedAt"`
+	LastUsedAt  time.Time          `json:"lastUsedAt"`
+	ExpiresAt   time.Time          `json:"expiresAt"`
+}
+
+// ClientInfo identifica el origen de una petición: el usuario autenticado,
+// si lo hay, su IP y su User-Agent.
+type ClientInfo struct {
+	UsuarioID primitive.ObjectID
+	IP        string
+	UserAgent string
+}
+
+// OAuthCode es un código de un solo uso que el callback OAuth entrega al
+// frontend para que obtenga los tokens sin exponerlos en la URL.
+type OAuthCode struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	CodeHash  string             `bson:"codeHash" json:"-"`
+	UsuarioID primitive.ObjectID `bson:"usuarioId" json:"usuarioId"`
+	ExpiresAt time.Time          `bson:"expiresAt" json:"expiresAt"`
+	CreatedAt time.Time          `bson:"createdAt" json:"createdAt"`
+}
+
+// Tipos de EmailToken
+const (
+	EmailTokenVerificacion = "verify_email"
+	EmailTokenReset        = "reset_password"
+)
+
+// EmailToken es un token de un solo uso enviado por correo para verificar la
+// dirección o restablecer la contraseña. Solo se guarda su hash.
+type EmailToken struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	TokenHash string             `bson:"tokenHash" json:"-"`
+	UsuarioID primitive.ObjectID `bson:"usuarioId" json:"usuarioId"`
+	Tipo      string             `bson:"tipo" json:"tipo"`
+	ExpiresAt time.Time          `bson:"expiresAt" json:"expiresAt"`
+	CreatedAt time.Time          `bson:"createdAt" json:"createdAt"`
+}
+
+// LoginAttempt cuenta los fallos de autenticación recientes de una clave:
+// una cuenta ("cuenta:<email>"), un usuario ("usuario:<id>") o una IP
+// ("ip:<dirección>").
+type LoginAttempt struct {
+	Key         string    `bson:"_id" json:"key"`
+	Failures    int       `bson:"failures" json:"failures"`
+	LockedUntil time.Time `bson:"lockedUntil,omitempty" json:"lockedUntil"`
+	LastFailure time.Time `bson:"lastFailure" json:"lastFailure"`
+	ExpiresAt   time.Time `bson:"expiresAt" json:"expiresAt"`
+}
+
+// LoginChallenge es el paso intermedio del login cuando el usuario tiene 2FA:
+// la contraseña ya fue verificada y falta el código TOTP.
+type LoginChallenge struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	TokenHash string             `bson:"tokenHash" json:"-"`
+	UsuarioID primitive.ObjectID `bson:"usuarioId" json:"usuarioId"`
+	Attempts  int                `bson:"attempts" json:"attempts"`
+	ExpiresAt time.Time          `bson:"expiresAt" json:"expiresAt"`
+	CreatedAt time.Time          `bson:"createdAt" json:"createdAt"`
+}
+
+// PersonalAccessToken es un token de larga duración que el usuario crea para
+// scripts e integraciones. Solo se guarda el hash; el token en claro se
+// muestra una única vez al crearlo.
+type PersonalAccessToken struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UsuarioID  primitive.ObjectID `bson:"usuarioId" json:"usuarioId"`
+	Nombre     string             `bson:"nombre" json:"nombre"`
+	TokenHash  string             `bson:"tokenHash" json:"-"`
+	Prefijo    string             `bson:"prefijo" json:"prefijo"` // primeros caracteres, para reconocerlo en la lista
+	Scopes     []string           `bson:"scopes" json:"scopes"`   // permisos "recurso:acción" a los que se limita
+	LastUsedAt *time.Time         `bson:"lastUsedAt,omitempty" json:"lastUsedAt"`
+	LastUsedIP string             `bson:"lastUsedIp,omitempty" json:"lastUsedIp"`
+	ExpiresAt  *time.Time         `bson:"expiresAt,omitempty" json:"expiresAt"` // nil si no expira
+	Revoked    bool               `bson:"revoked" json:"revoked"`
+	RevokedAt  *time.Time         `bson:"revokedAt,omitempty" json:"revokedAt"`
+	CreatedAt  time.Time          `bson:"createdAt" json:"createdAt"`
+}
+
+type Rol struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Nombre      string             `bson:"nombre" json:"nombre" binding:"required"`
+	Descripcion string             `bson:"descripcion,omitempty" json:"descripcion"`
+	Permisos    []string           `bson:"permisos" json:"permisos" binding:"required"`
+	CreatedAt   time.Time          `bson:"createdAt,omitempty" json:"createdAt"`
+	UpdatedAt   time.Time          `bson:"updatedAt,omitempty" json:"updatedAt"`
+}
+
+// AuditLog registra una acción que modificó datos. UsuarioID es quien la hizo
+// y AfectadoID el usuario cuya cuenta o datos cambiaron; coinciden salvo en
+// las acciones de administración. Detalle guarda los campos "antes" y
+// "despues" del recurso modificado.
+type AuditLog struct {
+	ID         primitive.ObjectID  `bson:"_id,omitempty" json:"id"`
+	UsuarioID  *primitive.ObjectID `bson:"usuarioId,omitempty" json:"usuarioId"`
+	AfectadoID *primitive.ObjectID `bson:"afectadoId,omitempty" json:"afectadoId"`
+	Accion     string              `bson:"accion" json:"accion"`
+	Recurso    string              `bson:"recurso,omitempty" json:"recurso"`
+	RecursoID  *primitive.ObjectID `bson:"recursoId,omitempty" json:"recursoId"`
+	Detalle    interface{}         `bson:"detalle,omitempty" json:"detalle"`
+	IP         string              `bson:"ip,omitempty" json:"ip"`
+	UserAgent  string              `bson:"userAgent,omitempty" json:"userAgent"`
+	CreatedAt  time.Time           `bson:"createdAt" json:"createdAt"`
+}
+
+// Acciones del registro de auditoría.
+const (
+	AuditRegistro              = "registro"
+	AuditLogin                 = "login"
+	AuditLoginFallido          = "login_fallido"
+	AuditLogout                = "logout"
+	AuditLogoutTodas           = "logout_todas"
+	AuditPasswordCambiar       = "password_cambiar"
+	AuditPasswordSolicitud     = "password_solicitar_reset"
+	AuditPasswordReset         = "password_reset"
+	AuditEmailVerificar        = "email_verificar"
+	AuditIdentidadVincular     = "identidad_vincular"
+	AuditRefreshTokenReuse     = "refresh_token_reuse"
+	AuditTwoFactorActivar      = "2fa_activar"
+	AuditTwoFactorDesactivar   = "2fa_desactivar"
+	AuditSesionRevocar         = "sesion_revocar"
+	AuditTokenAccesoCrear      = "token_acceso_crear"
+	AuditTokenAccesoRevocar    = "token_acceso_revocar"
+	AuditPerfilEditar          = "perfil_editar"
+	AuditTransaccionCrear      = "transaccion_crear"
+	AuditTransaccionEditar     = "transaccion_editar"
+	AuditTransaccionEliminar   = "transaccion_eliminar"
+	AuditCategoriaCrear        = "categoria_crear"
+	AuditCategoriaEditar       = "categoria_editar"
+	AuditCategoriaEliminar     = "categoria_eliminar"
+	AuditUsuarioAprobar        = "usuario_aprobar"
+	AuditUsuarioActivar        = "usuario_activar"
+	AuditUsuarioSuspender      = "usuario_suspender"
+	AuditUsuarioCambiarRol     = "usuario_cambiar_rol"
+	AuditUsuarioRestablecer2FA = "usuario_reset_2fa"
+	AuditUsuarioEliminar       = "usuario_eliminar"
+	AuditRolCrear              = "rol_crear"
+	AuditRolEditar             = "rol_editar"
+	AuditRolEliminar           = "rol_eliminar"
+	AuditTipoCambioCargar      = "tipo_cambio_cargar"
+	AuditCuentaCrear           = "cuenta_crear"
+	AuditCuentaEditar          = "cuenta_editar"
+	AuditCuentaEliminar        = "cuenta_eliminar"
+	AuditTransferenciaCrear    = "transferencia_crear"
+	AuditRecurrenciaCrear      = "recurrencia_crear"
+	AuditRecurrenciaEditar     = "recurrencia_editar"
+	AuditRecurrenciaEliminar   = "recurrencia_eliminar"
+	AuditPrestamoCrear         = "prestamo_crear"
+	AuditPrestamoEditar        = "prestamo_editar"
+	AuditPrestamoEliminar      = "prestamo_eliminar"
+	AuditPropiedadCrear        = "propiedad_crear"
+	AuditPropiedadEditar       = "propiedad_editar"
+	AuditPropiedadEliminar     = "propiedad_eliminar"
+	AuditInquilinoCrear        = "inquilino_crear"
+	AuditInquilinoEditar       = "inquilino_editar"
+	AuditInquilinoEliminar     = "inquilino_eliminar"
+	AuditContratoCrear         = "contrato_crear"
+	AuditContratoEditar        = "contrato_editar"
+	AuditContratoEliminar      = "contrato_eliminar"
+	AuditImportacionCrear      = "importacion_crear"
+	AuditImportacionDeshacer   = "importacion_deshacer"
+	AuditPerfilBancoCrear      = "perfil_importacion_crear"
+	AuditPerfilBancoEditar     = "perfil_importacion_editar"
+	AuditPerfilBancoEliminar   = "perfil_importacion_eliminar"
+)
+
+// AuditLogFilter son los filtros de la consulta del registro de auditoría.
+// UsuarioID selecciona las entradas en las que el usuario es el actor o el
+// afectado.
+type AuditLogFilter struct {
+	UsuarioID *primitive.ObjectID
+	Accion    string
+	Desde     *time.Time
+	Hasta     *time.Time
+	Limite    int64
+	Pagina    int64
+}
+
+// DTOs para requests/responses
+type RegisterRequest struct {
+	Nombre   string `json:"nombre" binding:"required"`
+	Email    string `json:"email" binding:"required,email"`
+	Password string `json:"password" binding:"required,min=6"`
+}
+
+type LoginRequest struct {
+	Email    string `json:"email" binding:"required,email"`
+	Password string `json:"password" binding:"required"`
+}
+
+type LoginResponse struct {
+	AccessToken  string   `json:"accessToken"`
+	RefreshToken string   `json:"refreshToken"`
+	Usuario      *Usuario `json:"usuario"`
+}
+
+// LoginChallengeResponse se devuelve en lugar de LoginResponse cuando el
+// usuario tiene 2FA activado.
+type LoginChallengeResponse struct {
+	TwoFactorRequired bool      `json:"twoFactorRequired"`
+	ChallengeToken    string    `json:"challengeToken"`
+	ExpiresAt         time.Time `json:"expiresAt"`
+}
+
+type TwoFactorLoginRequest struct {
+	ChallengeToken string `json:"challengeToken" binding:"required"`
+	Code           string `json:"code" binding:"required"`
+}
+
+type TwoFactorCodeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+type TwoFactorEnrollResponse struct {
+	Secret     string `json:"secret"`
+	OTPAuthURI string `json:"otpauthUri"`
+}
+
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recoveryCodes"`
+}
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refreshToken" binding:"required"`
+}
+
+type RefreshResponse struct {
+	AccessToken  string `json:"accessToken"`
+	RefreshToken string `json:"refreshToken"`
+}
+
+type ExchangeCodeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+type ResetPasswordRequest struct {
+	Token       string `json:"token" binding:"required"`
+	NewPassword string `json:"newPassword" binding:"required,min=6"`
+}
+
+type PersonalAccessTokenRequest struct {
+	Nombre        string   `json:"nombre" binding:"required,max=100"`
+	Scopes        []string `json:"scopes" binding:"required,min=1"`
+	ExpiresInDays int      `json:"expiresInDays" binding:"min=0,max=3650"` // 0 = sin expiración
+}
+
+// PersonalAccessTokenResponse incluye el token en claro; es la única vez que
+// se devuelve.
+type PersonalAccessTokenResponse struct {
+	Token       string               `json:"token"`
+	AccessToken *PersonalAccessToken `json:"accessToken"`
+}
+
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"currentPassword" binding:"required"`
+	NewPassword     string `json:"newPassword" binding:"required,min=6"`
+}
+
+type ReporteRequest struct {
+	Year  int `form:"year" binding:"required"`
+	Month int `form:"month" binding:"required,min=1,max=12"`
+}
+
+// TransaccionQuery son los parámetros de consulta del listado de
+// transacciones. Desde y Hasta aceptan RFC 3339 o una fecha YYYY-MM-DD.
+type TransaccionQuery struct {
+	Desde       string        `form:"desde"`
+	Hasta       string        `form:"hasta"`
+	Tipo        string        `form:"tipo" binding:"omitempty,oneof=ingreso egreso prestamo alquiler otro transferencia"`
+	CategoriaID string        `form:"categoriaId"`
+	CuentaID    string        `form:"cuentaId"`
+	Tag         string        `form:"tag"`
+	MetodoPago  string        `form:"metodoPago"`
+	MontoMin    *money.Amount `form:"montoMin" binding:"omitempty,gte=0"`
+	MontoMax    *money.Amount `form:"montoMax" binding:"omitempty,gte=0"`
+	Descripcion string        `form:"q" binding:"max=100"`
+	Orden       string        `form:"orden" binding:"omitempty,oneof=fecha -fecha monto -monto"`
+	Limite      int64         `form:"limite" binding:"min=0"`
+	Cursor      string        `form:"cursor"`
+}
+
+// AuditLogQuery son los parámetros de consulta del registro de auditoría.
+// Desde y Hasta aceptan RFC 3339 o una fecha YYYY-MM-DD.
+type AuditLogQuery struct {
+	UsuarioID string `form:"usuarioId"`
+	Accion    string `form:"accion"`
+	Desde     string `form:"desde"`
+	Hasta     string `form:"hasta"`
+	Limite    int64  `form:"limite" binding:"min=0"`
+	Pagina    int64  `form:"pagina" binding:"min=0"`
+}
+
+// TotalTransacciones es la suma de los montos de un tipo de transacción en
+// una categoría, por moneda y día (YYYY-MM-DD en UTC) para poder aplicar el
+// tipo de cambio de cada fecha.
+type TotalTransacciones struct {
+	Tipo        string             `bson:"tipo"`
+	CategoriaID primitive.ObjectID `bson:"categoriaId"`
+	Moneda      string             `bson:"moneda"`
+	Dia         string             `bson:"dia"`
+	Sentido     string             `bson:"sentido,omitempty"` // solo en los movimientos de préstamos
+	Cobro       bool               `bson:"cobro"`             // alquiler cobrado de un contrato
+	Total       money.Amount       `bson:"total"`
+}
+
+// EstadisticasResponse expresa todos los importes en Moneda, la moneda base
+// del usuario.
+type EstadisticasResponse struct {
+	Moneda            string                  `json:"moneda"`
+	TotalIngresos     money.Amount            `json:"totalIngresos"`
+	TotalEgresos      money.Amount            `json:"totalEgresos"`
+	Balance           money.Amount            `json:"balance"`
+	PrestamosEntradas money.Amount            `json:"prestamosEntradas"` // desembolsos recibidos y cuotas cobradas
+	PrestamosSalidas  money.Amount            `json:"prestamosSalidas"`  // desembolsos otorgados y cuotas pagadas
+	Alquileres        money.Amount            `json:"alquileres"`        // cobros de contratos de alquiler
+	FlujoCaja         money.Amount            `json:"flujoCaja"`         // Balance más los alquileres y las entradas, menos las salidas de préstamos
+	PorCategoria      map[string]money.Amount `json:"porCategoria"`
+}
+
+// Patrimonio es el patrimonio neto del usuario en Moneda, su moneda base,
+// con los tipos de cambio de Fecha.
+type Patrimonio struct {
+	Moneda             string       `json:"moneda"`
+	Fecha              time.Time    `json:"fecha"`
+	Cuentas            money.Amount `json:"cuentas"`            // saldo de todas las cuentas
+	PrestamosPorCobrar money.Amount `json:"prestamosPorCobrar"` // capital pendiente de los préstamos otorgados
+	PrestamosPorPagar  money.Amount `json:"prestamosPorPagar"`  // capital pendiente de los préstamos recibidos
+	Total              money.Amount `json:"total"`
+}
+
+// ExchangeRate es el tipo de cambio de un día: una unidad de Moneda vale Tasa
+// unidades de Base. Rige desde Fecha hasta el siguiente registro del mismo
+// par.
+type ExchangeRate struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Moneda    string             `bson:"moneda" json:"moneda"`
+	Base      string             `bson:"base" json:"base"`
+	Fecha     time.Time          `bson:"fecha" json:"fecha"` // medianoche UTC
+	Tasa      money.Rate         `bson:"tasa" json:"tasa"`
+	Fuente    string             `bson:"fuente" json:"fuente"` // api, csv o el nombre del proveedor
+	UpdatedAt time.Time          `bson:"updatedAt" json:"updatedAt"`
+}
+
+// ExchangeRateRequest es un tipo de cambio enviado por un administrador. Fecha
+// es YYYY-MM-DD.
+type ExchangeRateRequest struct {
+	Moneda string     `json:"moneda" binding:"required,len=3"`
+	Base   string     `json:"base" binding:"required,len=3"`
+	Fecha  string     `json:"fecha" binding:"required"`
+	Tasa   money.Rate `json:"tasa" binding:"required,gt=0"`
+}
+
+// ExchangeRateQuery son los parámetros de consulta de los tipos de cambio.
+type ExchangeRateQuery struct {
+	Moneda string `form:"moneda"`
+	Base   string `form:"base"`
+	Desde  string `form:"desde"`
+	Hasta  string `form:"hasta"`
+}
+
+// ExchangeRateFilter son los criterios ya validados de ExchangeRateQuery.
+type ExchangeRateFilter struct {
+	Moneda string
+	Base   string
+	Desde  *time.Time
+	Hasta  *time.Time
+	Limite int64
+}
@@ -0,0 +1,38 @@
+package money
+
+import "strings"
+
+// DefaultCurrency es la moneda base de los usuarios que no eligieron otra.
+const DefaultCurrency = "USD"
+
+// monedas son los códigos ISO 4217 vigentes con sus decimales. No incluye
+// metales preciosos ni unidades sin decimales definidos (XAU, XDR, XXX...).
+var monedas = func() map[string]int {
+	m := make(map[string]int)
+	for d, codigos := range map[int]string{
+		0: "BIF CLP DJF GNF ISK JPY KMF KRW PYG RWF UGX UYI VND VUV XAF XOF XPF",
+		2: "AED AFN ALL AMD ANG AOA ARS AUD AWG AZN BAM BBD BDT BGN BMD BND BOB " +
+			"BOV BRL BSD BTN BWP BYN BZD CAD CDF CHE CHF CHW CNY COP COU CRC CUP " +
+			"CVE CZK DKK DOP DZD EGP ERN ETB EUR FJD FKP GBP GEL GHS GIP GMD GTQ " +
+			"GYD HKD HNL HTG HUF IDR ILS INR IRR JMD KES KGS KHR KPW KYD KZT LAK " +
+			"LBP LKR LRD LSL MAD MDL MGA MKD MMK MNT MOP MRU MUR MVR MWK MXN MXV " +
+			"MYR MZN NAD NGN NIO NOK NPR NZD PAB PEN PGK PHP PKR PLN QAR RON RSD " +
+			"RUB SAR SBD SCR SDG SEK SGD SHP SLE SOS SRD SSP STN SVC SYP SZL THB " +
+			"TJS TMT TOP TRY TTD TWD TZS UAH USD USN UYU UZS VED VES WST XCD XCG " +
+			"YER ZAR ZMW ZWG",
+		3: "BHD IQD JOD KWD LYD OMR TND",
+		4: "CLF UYW",
+	} {
+		for _, codigo := range strings.Fields(codigos) {
+			m[codigo] = d
+		}
+	}
+	return m
+}()
+
+// ValidCurrency indica si moneda es un código ISO 4217 vigente. Los códigos
+// se escriben en mayúsculas.
+func ValidCurrency(moneda string) bool {
+	_, ok := monedas[moneda]
+	return ok
+}
@@ -0,0 +1,306 @@
+// Package money representa importes exactos. Un Amount es un entero en
+// diezmilésimas, suficiente para cualquier moneda ISO 4217, y se guarda en
+// MongoDB como Decimal128 para que las agregaciones sumen sin errores de
+// redondeo.
+package money
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/x/bsonx/bsoncore"
+)
+
+// Scale es la cantidad de decimales que conserva un Amount.
+const Scale = 4
+
+const unit = 10000 // 10^Scale
+
+// Amount es un importe en diezmilésimas de la unidad monetaria. El valor cero
+// es un importe de cero.
+type Amount int64
+
+var ErrInvalidAmount = errors.New("importe inválido")
+
+// Decimals devuelve los decimales de la moneda. Las monedas desconocidas o
+// vacías usan dos.
+func Decimals(moneda string) int {
+	if d, ok := monedas[strings.ToUpper(moneda)]; ok {
+		return d
+	}
+	return 2
+}
+
+// Parse lee un importe decimal como "1234.5" o "-0.01". Rechaza más de
+// Scale decimales en lugar de redondearlos en silencio.
+func Parse(s string) (Amount, error) {
+	units, ok := parseFixed(s, Scale)
+	if !ok {
+		return 0, ErrInvalidAmount
+	}
+	return Amount(units), nil
+}
+
+// MustParse es Parse para constantes; entra en pánico si s no es válido.
+func MustParse(s string) Amount {
+	a, err := Parse(s)
+	if err != nil {
+		panic(fmt.Sprintf("money: %q: %v", s, err))
+	}
+	return a
+}
+
+// parseFixed lee un decimal con hasta scale decimales como un entero en
+// unidades de 10^-scale.
+func parseFixed(s string, scale int) (int64, bool) {
+	s = strings.TrimSpace(s)
+	neg := strings.HasPrefix(s, "-")
+	s = strings.TrimPrefix(strings.TrimPrefix(s, "-"), "+")
+
+	entero, fraccion, _ := strings.Cut(s, ".")
+	if entero == "" && fraccion == "" || len(fraccion) > scale || !digits(entero) || !digits(fraccion) {
+		return 0, false
+	}
+	if entero == "" {
+		entero = "0"
+	}
+
+	units, err := strconv.ParseInt(entero+fraccion+strings.Repeat("0", scale-len(fraccion)), 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	if neg {
+		units = -units
+	}
+	return units, true
+}
+
+func digits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// FromFloat convierte un float64, redondeando a Scale decimales. Solo debe
+// usarse con datos heredados que ya se guardaron como float.
+func FromFloat(f float64) Amount {
+	return Amount(math.Round(f * unit))
+}
+
+// Round redondea a los decimales de la moneda, con los empates hacia el
+// lado contrario al cero.
+func (a Amount) Round(moneda string) Amount {
+	paso := int64(math.Pow10(Scale - Decimals(moneda)))
+	if paso == 1 {
+		return a
+	}
+
+	units := int64(a)
+	resto := units % paso
+	units -= resto
+	if 2*abs(resto) >= paso {
+		if units < 0 || resto < 0 {
+			units -= paso
+		} else {
+			units += paso
+		}
+	}
+	return Amount(units)
+}
+
+func abs(n int64) int64 {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// String devuelve el importe sin ceros finales innecesarios, por ejemplo
+// "1234.5" o "-0.01".
+func (a Amount) String() string {
+	return formatFixed(int64(a), Scale)
+}
+
+// formatFixed escribe units en unidades de 10^-scale sin ceros finales.
+func formatFixed(units int64, scale int) string {
+	signo := ""
+	if units < 0 {
+		signo = "-"
+	}
+	u := uint64(abs(units))
+	div := uint64(math.Pow10(scale))
+
+	s := strconv.FormatUint(u/div, 10)
+	if fraccion := u % div; fraccion != 0 {
+		s += "." + strings.TrimRight(fmt.Sprintf("%0*d", scale, fraccion), "0")
+	}
+	return signo + s
+}
+
+// StringFixed devuelve el importe con exactamente los decimales de la
+// moneda, ya redondeado.
+func (a Amount) StringFixed(moneda string) string {
+	d := Decimals(moneda)
+	s := a.Round(moneda).String()
+	entero, fraccion, _ := strings.Cut(s, ".")
+	if d == 0 {
+		return entero
+	}
+	return entero + "." + fraccion + strings.Repeat("0", d-len(fraccion))
+}
+
+// Decimal128 convierte el importe sin pérdida.
+func (a Amount) Decimal128() primitive.Decimal128 {
+	return toDecimal128(int64(a), Scale)
+}
+
+// FromDecimal128 convierte un Decimal128, redondeando a Scale decimales si
+// tiene más.
+func FromDecimal128(d primitive.Decimal128) (Amount, error) {
+	units, ok := fromDecimal128(d, Scale)
+	if !ok {
+		return 0, ErrInvalidAmount
+	}
+	return Amount(units), nil
+}
+
+func toDecimal128(units int64, scale int) primitive.Decimal128 {
+	exp := -scale
+	for exp < 0 && units != 0 && units%10 == 0 {
+		units /= 10
+		exp++
+	}
+	if units == 0 {
+		exp = 0
+	}
+	d, _ := primitive.ParseDecimal128FromBigInt(big.NewInt(units), exp)
+	return d
+}
+
+func fromDecimal128(d primitive.Decimal128, scale int) (int64, bool) {
+	bi, exp, err := d.BigInt()
+	if err != nil {
+		return 0, false
+	}
+
+	exp += scale
+	if exp >= 0 {
+		bi.Mul(bi, pow10(exp))
+	} else {
+		bi = roundQuo(bi, pow10(-exp))
+	}
+
+	if !bi.IsInt64() {
+		return 0, false
+	}
+	return bi.Int64(), true
+}
+
+func pow10(n int) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(n)), nil)
+}
+
+// roundQuo divide n entre d con los empates hacia el lado contrario al cero.
+func roundQuo(n, d *big.Int) *big.Int {
+	q, r := new(big.Int).QuoRem(n, d, new(big.Int))
+	if r.Abs(r).Lsh(r, 1).Cmp(new(big.Int).Abs(d)) >= 0 {
+		if n.Sign()*d.Sign() < 0 {
+			q.Sub(q, big.NewInt(1))
+		} else {
+			q.Add(q, big.NewInt(1))
+		}
+	}
+	return q
+}
+
+// MarshalBSONValue guarda el importe como Decimal128.
+func (a Amount) MarshalBSONValue() (bsontype.Type, []byte, error) {
+	return bsontype.Decimal128, bsoncore.AppendDecimal128(nil, a.Decimal128()), nil
+}
+
+// UnmarshalBSONValue acepta Decimal128 y, para los documentos anteriores a la
+// migración, double y enteros.
+func (a *Amount) UnmarshalBSONValue(t bsontype.Type, data []byte) error {
+	v := bsoncore.Value{Type: t, Data: data}
+	switch t {
+	case bsontype.Decimal128:
+		parsed, err := FromDecimal128(v.Decimal128())
+		if err != nil {
+			return err
+		}
+		*a = parsed
+	case bsontype.Double:
+		*a = FromFloat(v.Double())
+	case bsontype.Int32:
+		*a = Amount(int64(v.Int32()) * unit)
+	case bsontype.Int64:
+		*a = Amount(v.Int64() * unit)
+	case bsontype.Null:
+		*a = 0
+	default:
+		return fmt.Errorf("money: no se puede leer un importe de tipo %s", t)
+	}
+	return nil
+}
+
+// MarshalJSON escribe el importe como número JSON con su valor exacto.
+func (a Amount) MarshalJSON() ([]byte, error) {
+	return []byte(a.String()), nil
+}
+
+// UnmarshalJSON acepta un número o una cadena con el importe. El número se
+// lee como texto, sin pasar por float64.
+func (a *Amount) UnmarshalJSON(b []byte) error {
+	units, ok := unmarshalJSONFixed(b, Scale)
+	if !ok {
+		return ErrInvalidAmount
+	}
+	if units != nil {
+		*a = Amount(*units)
+	}
+	return nil
+}
+
+// unmarshalJSONFixed lee un número JSON, o una cadena con el número, en
+// unidades de 10^-scale. Devuelve nil para null.
+func unmarshalJSONFixed(b []byte, scale int) (*int64, bool) {
+	s := string(b)
+	if s == "null" {
+		return nil, true
+	}
+	if unquoted, err := strconv.Unquote(s); err == nil {
+		s = unquoted
+	}
+
+	// Admitir la notación exponencial que generan algunos clientes
+	if strings.ContainsAny(s, "eE") {
+		d, err := primitive.ParseDecimal128(s)
+		if err != nil {
+			return nil, false
+		}
+		units, ok := fromDecimal128(d, scale)
+		return &units, ok
+	}
+
+	units, ok := parseFixed(s, scale)
+	return &units, ok
+}
+
+// UnmarshalParam permite usar Amount en parámetros de consulta de Gin.
+func (a *Amount) UnmarshalParam(param string) error {
+	parsed, err := Parse(param)
+	if err != nil {
+		return err
+	}
+	*a = parsed
+	return nil
+}
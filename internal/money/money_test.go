@@ -0,0 +1,182 @@
+package money
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		in   string
+		want Amount
+	}{
+		{"0", 0},
+		{"1", 10000},
+		{"1234.5", 12345000},
+		{"-0.01", -100},
+		{".5", 5000},
+		{"+2.0001", 20001},
+	}
+	for _, tt := range tests {
+		got, err := Parse(tt.in)
+		require.NoError(t, err, tt.in)
+		assert.Equal(t, tt.want, got, tt.in)
+	}
+
+	for _, in := range []string{"", "-", ".", "1.23456", "1,5", "abc", "1e3", "99999999999999999"} {
+		_, err := Parse(in)
+		assert.ErrorIs(t, err, ErrInvalidAmount, in)
+	}
+}
+
+func TestString(t *testing.T) {
+	assert.Equal(t, "0", Amount(0).String())
+	assert.Equal(t, "1234.5", MustParse("1234.50").String())
+	assert.Equal(t, "-0.01", MustParse("-0.01").String())
+	assert.Equal(t, "0.0001", Amount(1).String())
+
+	assert.Equal(t, "1234.50", MustParse("1234.5").StringFixed("USD"))
+	assert.Equal(t, "1235", MustParse("1234.5").StringFixed("JPY"))
+	assert.Equal(t, "0.125", MustParse("0.125").StringFixed("KWD"))
+}
+
+func TestRound(t *testing.T) {
+	tests := []struct {
+		in, moneda, want string
+	}{
+		{"10.005", "PEN", "10.01"},
+		{"10.0049", "PEN", "10"},
+		{"-10.005", "USD", "-10.01"},
+		{"-0.005", "USD", "-0.01"},
+		{"0.0049", "", "0"},
+		{"1234.5", "JPY", "1235"},
+		{"-1234.5", "CLP", "-1235"},
+		{"1.2345", "BHD", "1.235"},
+		{"1.2345", "CLF", "1.2345"},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, MustParse(tt.in).Round(tt.moneda).String(), "%s %s", tt.in, tt.moneda)
+	}
+}
+
+func TestValidCurrency(t *testing.T) {
+	for _, moneda := range []string{"PEN", "USD", "EUR", "JPY", "CLF", "VES"} {
+		assert.True(t, ValidCurrency(moneda), moneda)
+	}
+	for _, moneda := range []string{"", "usd", "US", "S/", "XXX", "XAU", "HRK"} {
+		assert.False(t, ValidCurrency(moneda), moneda)
+	}
+	assert.Equal(t, 0, Decimals("JPY"))
+	assert.Equal(t, 3, Decimals("KWD"))
+}
+
+func TestSumaExacta(t *testing.T) {
+	var total Amount
+	for i := 0; i < 1000; i++ {
+		total += MustParse("0.10")
+	}
+	assert.Equal(t, "100", total.String())
+
+	// Con float64 la misma suma no da exactamente 100
+	var f float64
+	for i := 0; i < 1000; i++ {
+		f += 0.10
+	}
+	assert.NotEqual(t, 100.0, f)
+}
+
+func TestDecimal128(t *testing.T) {
+	for _, s := range []string{"0", "1234.5", "-0.0001", "92233720368.5477"} {
+		a := MustParse(s)
+		d := a.Decimal128()
+		assert.Equal(t, s, d.String())
+
+		back, err := FromDecimal128(d)
+		require.NoError(t, err)
+		assert.Equal(t, a, back)
+	}
+
+	// Los resultados de $sum pueden traer exponente positivo o más decimales
+	d, err := primitive.ParseDecimal128("1.2E+3")
+	require.NoError(t, err)
+	a, err := FromDecimal128(d)
+	require.NoError(t, err)
+	assert.Equal(t, "1200", a.String())
+
+	d, err = primitive.ParseDecimal128("-0.100000000000000")
+	require.NoError(t, err)
+	a, err = FromDecimal128(d)
+	require.NoError(t, err)
+	assert.Equal(t, "-0.1", a.String())
+
+	d, err = primitive.ParseDecimal128("2.00005")
+	require.NoError(t, err)
+	a, err = FromDecimal128(d)
+	require.NoError(t, err)
+	assert.Equal(t, "2.0001", a.String())
+
+	d, err = primitive.ParseDecimal128("1E+30")
+	require.NoError(t, err)
+	_, err = FromDecimal128(d)
+	assert.ErrorIs(t, err, ErrInvalidAmount)
+}
+
+func TestBSON(t *testing.T) {
+	type doc struct {
+		Monto Amount `bson:"monto"`
+	}
+
+	raw, err := bson.Marshal(doc{Monto: MustParse("120.75")})
+	require.NoError(t, err)
+	assert.Equal(t, bson.TypeDecimal128, bson.Raw(raw).Lookup("monto").Type)
+
+	var decoded doc
+	require.NoError(t, bson.Unmarshal(raw, &decoded))
+	assert.Equal(t, MustParse("120.75"), decoded.Monto)
+
+	// Documentos anteriores a la migración
+	for value, want := range map[interface{}]string{
+		0.1:        "0.1",
+		int32(15):  "15",
+		int64(-20): "-20",
+	} {
+		raw, err := bson.Marshal(bson.M{"monto": value})
+		require.NoError(t, err)
+		require.NoError(t, bson.Unmarshal(raw, &decoded))
+		assert.Equal(t, want, decoded.Monto.String())
+	}
+
+	raw, err = bson.Marshal(bson.M{"monto": "10"})
+	require.NoError(t, err)
+	assert.Error(t, bson.Unmarshal(raw, &decoded))
+}
+
+func TestJSON(t *testing.T) {
+	type body struct {
+		Monto Amount `json:"monto"`
+	}
+
+	out, err := json.Marshal(body{Monto: MustParse("0.30")})
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"monto":0.3}`, string(out))
+
+	for in, want := range map[string]string{
+		`{"monto":0.3}`:     "0.3",
+		`{"monto":"19.99"}`: "19.99",
+		`{"monto":1.5e2}`:   "150",
+		`{"monto":null}`:    "0",
+	} {
+		var b body
+		require.NoError(t, json.Unmarshal([]byte(in), &b), in)
+		assert.Equal(t, want, b.Monto.String(), in)
+	}
+
+	var b body
+	assert.Error(t, json.Unmarshal([]byte(`{"monto":"diez"}`), &b))
+	assert.Error(t, json.Unmarshal([]byte(`{"monto":1.00001}`), &b))
+}
@@ -0,0 +1,128 @@
+package money
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/x/bsonx/bsoncore"
+)
+
+// RateScale es la cantidad de decimales que conserva un Rate. Es mayor que
+// Scale porque el cambio entre monedas de valor muy distinto (VND a KWD, por
+// ejemplo) tiene muchos ceros tras la coma.
+const RateScale = 10
+
+// Rate es un tipo de cambio: cuántas unidades de una moneda vale una unidad
+// de otra, en unidades de 10^-RateScale.
+type Rate int64
+
+var ErrInvalidRate = errors.New("tipo de cambio inválido")
+
+// OneRate es el cambio de una moneda consigo misma.
+const OneRate Rate = 10000000000 // 10^RateScale
+
+// ParseRate lee un tipo de cambio decimal como "3.7512". Debe ser mayor que
+// cero y tener como mucho RateScale decimales.
+func ParseRate(s string) (Rate, error) {
+	units, ok := parseFixed(s, RateScale)
+	if !ok || units <= 0 {
+		return 0, ErrInvalidRate
+	}
+	return Rate(units), nil
+}
+
+// MustParseRate es ParseRate para constantes; entra en pánico si s no es
+// válido.
+func MustParseRate(s string) Rate {
+	r, err := ParseRate(s)
+	if err != nil {
+		panic(fmt.Sprintf("money: %q: %v", s, err))
+	}
+	return r
+}
+
+func (r Rate) String() string {
+	return formatFixed(int64(r), RateScale)
+}
+
+// Inverse devuelve el cambio en sentido contrario, redondeado a RateScale
+// decimales.
+func (r Rate) Inverse() Rate {
+	if r <= 0 {
+		return 0
+	}
+	q := roundQuo(pow10(2*RateScale), big.NewInt(int64(r)))
+	if !q.IsInt64() || q.Sign() == 0 {
+		return 0
+	}
+	return Rate(q.Int64())
+}
+
+// Convert multiplica el importe por el tipo de cambio, redondeando a Scale
+// decimales. El resultado debe redondearse después a la moneda de destino.
+func (a Amount) Convert(r Rate) (Amount, error) {
+	n := new(big.Int).Mul(big.NewInt(int64(a)), big.NewInt(int64(r)))
+	q := roundQuo(n, pow10(RateScale))
+	if !q.IsInt64() {
+		return 0, ErrInvalidAmount
+	}
+	return Amount(q.Int64()), nil
+}
+
+// Decimal128 convierte el tipo de cambio sin pérdida.
+func (r Rate) Decimal128() primitive.Decimal128 {
+	return toDecimal128(int64(r), RateScale)
+}
+
+// MarshalBSONValue guarda el tipo de cambio como Decimal128.
+func (r Rate) MarshalBSONValue() (bsontype.Type, []byte, error) {
+	return bsontype.Decimal128, bsoncore.AppendDecimal128(nil, r.Decimal128()), nil
+}
+
+// UnmarshalBSONValue acepta Decimal128 y double.
+func (r *Rate) UnmarshalBSONValue(t bsontype.Type, data []byte) error {
+	v := bsoncore.Value{Type: t, Data: data}
+	switch t {
+	case bsontype.Decimal128:
+		units, ok := fromDecimal128(v.Decimal128(), RateScale)
+		if !ok {
+			return ErrInvalidRate
+		}
+		*r = Rate(units)
+	case bsontype.Double:
+		d, err := primitive.ParseDecimal128(fmt.Sprint(v.Double()))
+		if err != nil {
+			return ErrInvalidRate
+		}
+		units, ok := fromDecimal128(d, RateScale)
+		if !ok {
+			return ErrInvalidRate
+		}
+		*r = Rate(units)
+	case bsontype.Null:
+		*r = 0
+	default:
+		return fmt.Errorf("money: no se puede leer un tipo de cambio de tipo %s", t)
+	}
+	return nil
+}
+
+// MarshalJSON escribe el tipo de cambio como número JSON con su valor exacto.
+func (r Rate) MarshalJSON() ([]byte, error) {
+	return []byte(r.String()), nil
+}
+
+// UnmarshalJSON acepta un número o una cadena, igual que Amount.
+func (r *Rate) UnmarshalJSON(b []byte) error {
+	units, ok := unmarshalJSONFixed(b, RateScale)
+	if !ok {
+		return ErrInvalidRate
+	}
+	if units != nil {
+		*r = Rate(*units)
+	}
+	return nil
+}
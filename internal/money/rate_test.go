@@ -0,0 +1,79 @@
+package money
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestParseRate(t *testing.T) {
+	r, err := ParseRate("3.7512")
+	require.NoError(t, err)
+	assert.Equal(t, "3.7512", r.String())
+	assert.Equal(t, "0.0000393", MustParseRate("0.0000393").String())
+	assert.Equal(t, OneRate, MustParseRate("1"))
+
+	for _, in := range []string{"", "0", "-1.5", "0.00000000001", "abc"} {
+		_, err := ParseRate(in)
+		assert.ErrorIs(t, err, ErrInvalidRate, in)
+	}
+}
+
+func TestRate_Inverse(t *testing.T) {
+	assert.Equal(t, "0.2666666667", MustParseRate("3.75").Inverse().String())
+	assert.Equal(t, "0.5", MustParseRate("2").Inverse().String())
+	assert.Equal(t, OneRate, OneRate.Inverse())
+	assert.Equal(t, Rate(0), Rate(0).Inverse())
+}
+
+func TestAmount_Convert(t *testing.T) {
+	tests := []struct {
+		monto, tasa, moneda, want string
+	}{
+		{"100", "3.75", "PEN", "375"},
+		{"10.01", "3.7512", "PEN", "37.55"},
+		{"1000", "0.2666666667", "USD", "266.67"},
+		{"-20", "3.75", "PEN", "-75"},
+		{"1000000", "0.0000393", "USD", "39.3"},
+		{"99.99", "151.2", "JPY", "15118"},
+	}
+	for _, tt := range tests {
+		got, err := MustParse(tt.monto).Convert(MustParseRate(tt.tasa))
+		require.NoError(t, err)
+		assert.Equal(t, tt.want, got.Round(tt.moneda).String(), "%s × %s", tt.monto, tt.tasa)
+	}
+
+	_, err := MustParse("900000000").Convert(MustParseRate("9000000"))
+	assert.ErrorIs(t, err, ErrInvalidAmount)
+}
+
+func TestRate_BSONyJSON(t *testing.T) {
+	type doc struct {
+		Tasa Rate `bson:"tasa" json:"tasa"`
+	}
+
+	raw, err := bson.Marshal(doc{Tasa: MustParseRate("3.7512")})
+	require.NoError(t, err)
+	assert.Equal(t, bson.TypeDecimal128, bson.Raw(raw).Lookup("tasa").Type)
+
+	var decoded doc
+	require.NoError(t, bson.Unmarshal(raw, &decoded))
+	assert.Equal(t, MustParseRate("3.7512"), decoded.Tasa)
+
+	raw, err = bson.Marshal(bson.M{"tasa": 0.27})
+	require.NoError(t, err)
+	require.NoError(t, bson.Unmarshal(raw, &decoded))
+	assert.Equal(t, "0.27", decoded.Tasa.String())
+
+	out, err := json.Marshal(doc{Tasa: MustParseRate("0.0000393")})
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"tasa":0.0000393}`, string(out))
+
+	var b doc
+	require.NoError(t, json.Unmarshal([]byte(`{"tasa":"3.75"}`), &b))
+	assert.Equal(t, MustParseRate("3.75"), b.Tasa)
+	assert.Error(t, json.Unmarshal([]byte(`{"tasa":1.00000000001}`), &b))
+}
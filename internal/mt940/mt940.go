@@ -0,0 +1,392 @@
+// Package mt940 lee extractos bancarios SWIFT MT940. Reconoce en el campo
+// :86: el formato con subcampos ?NN de los bancos alemanes y el de claves
+// /XXXX/ de los neerlandeses para extraer la contraparte y el concepto; en
+// cualquier otro formato el campo entero es el concepto.
+package mt940
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"control-financiero/internal/money"
+
+	"golang.org/x/text/encoding/charmap"
+)
+
+var ErrInvalidMT940 = errors.New("archivo MT940 inválido")
+
+// Statement es un extracto, desde :20: hasta :62F: o el campo que le siga.
+type Statement struct {
+	Reference        string // :20:
+	Account          string // :25:, IBAN o banco/cuenta
+	Number           string // :28C:, número de extracto/hoja
+	Currency         string // la del saldo inicial
+	OpeningBalance   *Balance
+	ClosingBalance   *Balance // saldo contable al cierre (:62F: o :62M:)
+	AvailableBalance *Balance // :64:; nil si no se indica
+	Transactions     []*Transaction
+}
+
+// Balance es un saldo. Amount es negativo si es deudor.
+type Balance struct {
+	Amount   money.Amount
+	Currency string
+	Date     time.Time
+}
+
+// Transaction es un movimiento (:61: con su :86:). Amount es positivo si el
+// dinero entra en la cuenta.
+type Transaction struct {
+	ValueDate           time.Time
+	EntryDate           time.Time // fecha contable; la de valor si no se indica
+	Amount              money.Amount
+	Reversal            bool   // RC o RD: anula un movimiento anterior
+	Type                string // código de transacción SWIFT, como NTRF o NMSC
+	CustomerReference   string // vacía si es NONREF
+	BankReference       string // la que sigue a //
+	Supplementary       string // segunda línea del :61:
+	Information         string // :86: tal como viene, sin los saltos de línea
+	CounterpartyName    string
+	CounterpartyAccount string // IBAN o número de cuenta
+	RemittanceInfo      string
+}
+
+// Detect indica si data parece un MT940: un :20: y un :25: al inicio de
+// línea.
+func Detect(data []byte) bool {
+	inicio := data
+	if len(inicio) > 4096 {
+		inicio = inicio[:4096]
+	}
+	return reCampo20.Match(inicio) && reCampo25.Match(inicio)
+}
+
+var (
+	reCampo20 = regexp.MustCompile(`(?m)^:20:`)
+	reCampo25 = regexp.MustCompile(`(?m)^:25:`)
+	reCampo   = regexp.MustCompile(`^:(\d{2}[A-Z]?):`)
+	// :61: fecha valor, fecha contable opcional, D/C/RD/RC, tercera letra de
+	// la divisa opcional, importe, código de transacción y referencias
+	re61 = regexp.MustCompile(`^(\d{6})(\d{4})?(R?[CD])([A-Z])?(\d+(?:,\d*)?)([NFS][A-Z0-9]{3})(.*)$`)
+	// :60F:, :62F:... C o D, fecha, divisa e importe
+	reSaldo = regexp.MustCompile(`^([CD])(\d{6})([A-Z]{3})(\d+(?:,\d*)?)$`)
+)
+
+// Parse lee todos los extractos de un archivo MT940, con o sin los bloques
+// de cabecera SWIFT ({1:...}{2:...}{4:). Si el contenido no es UTF-8 válido
+// se lee como Windows-1252.
+func Parse(data []byte) ([]*Statement, error) {
+	if !utf8.Valid(data) {
+		decoded, err := charmap.Windows1252.NewDecoder().Bytes(data)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidMT940, err)
+		}
+		data = decoded
+	}
+
+	campos, err := leerCampos(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var statements []*Statement
+	var st *Statement
+	var ultimo *Transaction // el :61: al que se le agrega el :86:
+	for _, c := range campos {
+		if c.tag == "20" {
+			st = &Statement{Reference: c.valor}
+			statements = append(statements, st)
+			ultimo = nil
+			continue
+		}
+		if st == nil {
+			return nil, fmt.Errorf("%w: línea %d: el campo :%s: está antes de :20:", ErrInvalidMT940, c.linea, c.tag)
+		}
+
+		switch c.tag {
+		case "25":
+			st.Account = c.valor
+		case "28", "28C":
+			st.Number = c.valor
+		case "60F", "60M":
+			if st.OpeningBalance, err = parseBalance(c.valor); err != nil {
+				return nil, fmt.Errorf("%w: línea %d: %v", ErrInvalidMT940, c.linea, err)
+			}
+			st.Currency = st.OpeningBalance.Currency
+		case "61":
+			t, err := parseTransaction(c.valor)
+			if err != nil {
+				return nil, fmt.Errorf("%w: línea %d: %v", ErrInvalidMT940, c.linea, err)
+			}
+			if len(c.lineas) > 1 {
+				t.Supplementary = strings.TrimSpace(strings.Join(c.lineas[1:], " "))
+			}
+			st.Transactions = append(st.Transactions, t)
+			ultimo = t
+		case "86":
+			// Un :86: sin :61: es información del extracto entero
+			if ultimo != nil {
+				ultimo.setInformation(c.lineas)
+				ultimo = nil
+			}
+		case "62F", "62M":
+			if st.ClosingBalance, err = parseBalance(c.valor); err != nil {
+				return nil, fmt.Errorf("%w: línea %d: %v", ErrInvalidMT940, c.linea, err)
+			}
+			ultimo = nil
+		case "64":
+			if st.AvailableBalance, err = parseBalance(c.valor); err != nil {
+				return nil, fmt.Errorf("%w: línea %d: %v", ErrInvalidMT940, c.linea, err)
+			}
+			ultimo = nil
+		}
+	}
+
+	if len(statements) == 0 {
+		return nil, fmt.Errorf("%w: no contiene extractos", ErrInvalidMT940)
+	}
+	for _, st := range statements {
+		if st.Account == "" {
+			return nil, fmt.Errorf("%w: el extracto %s no indica la cuenta (:25:)", ErrInvalidMT940, st.Reference)
+		}
+	}
+	return statements, nil
+}
+
+// campo es un campo :XX: con sus líneas de continuación.
+type campo struct {
+	tag    string
+	valor  string   // la primera línea
+	lineas []string // todas, empezando por la primera
+	linea  int      // número de la primera línea en el archivo
+}
+
+func leerCampos(data []byte) ([]*campo, error) {
+	var campos []*campo
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	n := 0
+	for scanner.Scan() {
+		n++
+		linea := strings.TrimRight(scanner.Text(), " \r")
+		// Los bloques de cabecera y de cierre del mensaje SWIFT
+		if i := strings.Index(linea, "{4:"); i >= 0 {
+			linea = linea[i+3:]
+		}
+		if strings.HasPrefix(linea, "-}") || strings.HasPrefix(linea, "{") || linea == "-" || linea == "" {
+			continue
+		}
+
+		if m := reCampo.FindStringSubmatch(linea); m != nil {
+			valor := linea[len(m[0]):]
+			campos = append(campos, &campo{tag: m[1], valor: valor, lineas: []string{valor}, linea: n})
+			continue
+		}
+		if len(campos) == 0 {
+			return nil, fmt.Errorf("%w: línea %d: se esperaba un campo", ErrInvalidMT940, n)
+		}
+		c := campos[len(campos)-1]
+		c.lineas = append(c.lineas, linea)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidMT940, err)
+	}
+	return campos, nil
+}
+
+func parseBalance(s string) (*Balance, error) {
+	m := reSaldo.FindStringSubmatch(s)
+	if m == nil {
+		return nil, fmt.Errorf("saldo inválido %q", s)
+	}
+	fecha, err := parseDate(m[2])
+	if err != nil {
+		return nil, err
+	}
+	monto, err := parseAmount(m[4])
+	if err != nil {
+		return nil, err
+	}
+	if m[1] == "D" {
+		monto = -monto
+	}
+	return &Balance{Amount: monto, Currency: m[3], Date: fecha}, nil
+}
+
+// parseTransaction lee la primera línea de un :61:.
+func parseTransaction(s string) (*Transaction, error) {
+	m := re61.FindStringSubmatch(s)
+	if m == nil {
+		return nil, fmt.Errorf("movimiento inválido %q", s)
+	}
+	t := &Transaction{Type: m[6]}
+
+	var err error
+	if t.ValueDate, err = parseDate(m[1]); err != nil {
+		return nil, err
+	}
+	t.EntryDate = t.ValueDate
+	if m[2] != "" {
+		if t.EntryDate, err = entryDate(t.ValueDate, m[2]); err != nil {
+			return nil, err
+		}
+	}
+
+	if t.Amount, err = parseAmount(m[5]); err != nil {
+		return nil, err
+	}
+	// RC anula un abono y RD un cargo, así que mueven el dinero al revés
+	switch m[3] {
+	case "D", "RC":
+		t.Amount = -t.Amount
+	}
+	t.Reversal = strings.HasPrefix(m[3], "R")
+
+	cliente, banco, _ := strings.Cut(m[7], "//")
+	t.CustomerReference = strings.TrimSpace(cliente)
+	if t.CustomerReference == "NONREF" {
+		t.CustomerReference = ""
+	}
+	t.BankReference = strings.TrimSpace(banco)
+	return t, nil
+}
+
+// entryDate completa la fecha contable MMDD con el año de la fecha valor. Si
+// entre ambas cambia el año, como un cargo del 31 de diciembre con valor del 2
+// de enero, usa el año anterior o el siguiente.
+func entryDate(valor time.Time, mmdd string) (time.Time, error) {
+	t, err := time.Parse("20060102", fmt.Sprintf("%04d%s", valor.Year(), mmdd))
+	if err != nil {
+		return time.Time{}, fmt.Errorf("fecha contable inválida %q", mmdd)
+	}
+	switch {
+	case t.Sub(valor) > 180*24*time.Hour:
+		t = t.AddDate(-1, 0, 0)
+	case valor.Sub(t) > 180*24*time.Hour:
+		t = t.AddDate(1, 0, 0)
+	}
+	return t, nil
+}
+
+// setInformation guarda el :86: y extrae de él la contraparte y el concepto.
+func (t *Transaction) setInformation(lineas []string) {
+	info := strings.Join(lineas, "")
+	switch {
+	case reSubcampos.MatchString(info):
+		t.parseSubcampos(info)
+	case reClave.MatchString(info):
+		t.parseClaves(info)
+	default:
+		info = strings.Join(lineas, " ")
+		t.RemittanceInfo = strings.TrimSpace(info)
+	}
+	t.Information = info
+}
+
+var (
+	// Formato alemán: código de operación de tres cifras y subcampos ?NN
+	reSubcampos = regexp.MustCompile(`^\d{3}\?\d{2}`)
+	reSubcampo  = regexp.MustCompile(`\?(\d{2})`)
+	// Formato neerlandés: /CLAVE/valor/CLAVE/valor...
+	reClave = regexp.MustCompile(`^/[A-Z]{3,4}/`)
+)
+
+func (t *Transaction) parseSubcampos(info string) {
+	var concepto, nombre []string
+	var texto, cuenta, iban string
+	indices := reSubcampo.FindAllStringSubmatchIndex(info, -1)
+	for i, idx := range indices {
+		fin := len(info)
+		if i+1 < len(indices) {
+			fin = indices[i+1][0]
+		}
+		valor := info[idx[1]:fin]
+		switch codigo := info[idx[2]:idx[3]]; {
+		case codigo == "00":
+			texto = strings.TrimSpace(valor)
+		case codigo >= "20" && codigo <= "29", codigo >= "60" && codigo <= "63":
+			concepto = append(concepto, valor)
+		case codigo == "31":
+			cuenta = strings.TrimSpace(valor)
+		case codigo == "32", codigo == "33":
+			nombre = append(nombre, valor)
+		case codigo == "38":
+			iban = strings.TrimSpace(valor)
+		}
+	}
+	// Sin concepto queda el texto de la operación, como "LASTSCHRIFT"
+	if t.RemittanceInfo = strings.TrimSpace(strings.Join(concepto, "")); t.RemittanceInfo == "" {
+		t.RemittanceInfo = texto
+	}
+	t.CounterpartyName = strings.TrimSpace(strings.Join(nombre, ""))
+	t.CounterpartyAccount = iban
+	if t.CounterpartyAccount == "" {
+		t.CounterpartyAccount = cuenta
+	}
+}
+
+func (t *Transaction) parseClaves(info string) {
+	valores := make(map[string][]string)
+	var clave string
+	for _, parte := range strings.Split(info, "/") {
+		if esClave(parte) {
+			clave = parte
+			valores[clave] = nil
+		} else if clave != "" {
+			valores[clave] = append(valores[clave], parte)
+		}
+	}
+	valor := func(partes []string) string {
+		return strings.Trim(strings.TrimSpace(strings.Join(partes, "/")), "/")
+	}
+
+	t.CounterpartyName = valor(valores["NAME"])
+	t.CounterpartyAccount = valor(valores["IBAN"])
+	// ING: /CNTP/cuenta/BIC/nombre/ciudad/
+	if cntp := valores["CNTP"]; len(cntp) > 0 {
+		if t.CounterpartyAccount == "" {
+			t.CounterpartyAccount = strings.TrimSpace(cntp[0])
+		}
+		if t.CounterpartyName == "" && len(cntp) > 2 {
+			t.CounterpartyName = strings.TrimSpace(cntp[2])
+		}
+	}
+	// ING: /REMI/USTD//texto/ o /REMI/STRD/CUR/referencia/
+	remi := valores["REMI"]
+	if len(remi) > 1 && (remi[0] == "USTD" || remi[0] == "STRD") {
+		remi = remi[2:]
+	}
+	t.RemittanceInfo = valor(remi)
+}
+
+// esClave indica si una parte del :86: es una de las claves reconocidas.
+func esClave(s string) bool {
+	switch s {
+	case "NAME", "IBAN", "BIC", "REMI", "CNTP", "EREF", "MARF", "CSID", "ORDP", "BENM", "ADDR", "TRTP", "PREF", "RTRN", "SVCL", "ISDT":
+		return true
+	}
+	return false
+}
+
+func parseDate(yymmdd string) (time.Time, error) {
+	t, err := time.Parse("060102", yymmdd)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("fecha inválida %q", yymmdd)
+	}
+	return t, nil
+}
+
+// parseAmount lee un importe con coma decimal, como 1234,56 o 10,.
+func parseAmount(s string) (money.Amount, error) {
+	s = strings.TrimSuffix(strings.Replace(s, ",", ".", 1), ".")
+	a, err := money.Parse(s)
+	if err != nil {
+		return 0, fmt.Errorf("importe inválido %q", s)
+	}
+	return a, nil
+}
@@ -0,0 +1,71 @@
+package mt940
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"control-financiero/internal/testutil"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse_Golden(t *testing.T) {
+	archivos, err := filepath.Glob(filepath.Join("testdata", "*.sta"))
+	require.NoError(t, err)
+	require.NotEmpty(t, archivos)
+
+	for _, archivo := range archivos {
+		t.Run(filepath.Base(archivo), func(t *testing.T) {
+			data, err := os.ReadFile(archivo)
+			require.NoError(t, err)
+			require.True(t, Detect(data))
+
+			statements, err := Parse(data)
+			require.NoError(t, err)
+			testutil.Golden(t, archivo, statements)
+		})
+	}
+}
+
+func TestParse_Errores(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want string
+	}{
+		{"sin extractos", "hola\n", "se esperaba un campo"},
+		{"campo antes de :20:", ":25:123\n:20:X\n", "está antes de :20:"},
+		{"sin cuenta", ":20:X\n:60F:C250101EUR1,00\n", "no indica la cuenta"},
+		{"saldo inválido", ":20:X\n:25:123\n:60F:X250101EUR1,00\n", "línea 3: saldo inválido"},
+		{"movimiento inválido", ":20:X\n:25:123\n:61:2501011D\n", "línea 3: movimiento inválido"},
+		{"fecha inválida", ":20:X\n:25:123\n:61:251301D1,00NTRFNONREF\n", "fecha inválida"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse([]byte(tt.data))
+			require.ErrorIs(t, err, ErrInvalidMT940)
+			assert.Contains(t, err.Error(), tt.want)
+		})
+	}
+}
+
+func TestEntryDate(t *testing.T) {
+	tests := []struct {
+		valor, mmdd, want string
+	}{
+		{"2025-01-02", "0102", "2025-01-02"},
+		{"2024-12-30", "1231", "2024-12-31"},
+		{"2025-01-02", "1231", "2024-12-31"},
+		{"2024-12-31", "0102", "2025-01-02"},
+	}
+	for _, tt := range tests {
+		valor, err := time.Parse(time.DateOnly, tt.valor)
+		require.NoError(t, err)
+		got, err := entryDate(valor, tt.mmdd)
+		require.NoError(t, err)
+		assert.Equal(t, tt.want, got.Format(time.DateOnly), tt.valor+" "+tt.mmdd)
+	}
+}
@@ -0,0 +1,423 @@
+// Package ofx lee extractos bancarios en OFX, tanto la versión 1.x en SGML,
+// donde los elementos con valor no se cierran, como la 2.x en XML. Los QFX
+// son OFX con etiquetas propias de Intuit, que se ignoran igual que las demás
+// desconocidas. Lee los extractos de cuentas bancarias (STMTRS) y de
+// tarjetas de crédito (CCSTMTRS).
+package ofx
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"html"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"control-financiero/internal/money"
+
+	"golang.org/x/text/encoding/charmap"
+)
+
+var ErrInvalidOFX = errors.New("archivo OFX inválido")
+
+// TipoTarjeta es el Account.Type de los extractos de tarjetas de crédito,
+// que en OFX no lo indican.
+const TipoTarjeta = "CREDITCARD"
+
+// Statement es el extracto de una cuenta.
+type Statement struct {
+	Currency         string // CURDEF, ISO 4217
+	Account          Account
+	Start, End       time.Time // periodo que cubre; cero si no se indica
+	Transactions     []*Transaction
+	LedgerBalance    *Balance // saldo contable al final del extracto
+	AvailableBalance *Balance // saldo disponible; nil si no se indica
+}
+
+// Account identifica la cuenta de un extracto en su banco.
+type Account struct {
+	BankID   string
+	BranchID string
+	ID       string // ACCTID, el número de la cuenta
+	Type     string // CHECKING, SAVINGS, CREDITLINE... o TipoTarjeta
+}
+
+// Transaction es un movimiento (STMTTRN). Amount es positivo si el dinero
+// entra en la cuenta.
+type Transaction struct {
+	Type     string    // TRNTYPE: CREDIT, DEBIT, CHECK, FEE...
+	Posted   time.Time // DTPOSTED, fecha contable
+	User     time.Time // DTUSER, fecha de la operación; cero si no se indica
+	Amount   money.Amount
+	FITID    string // identificador del movimiento, único en la cuenta
+	CheckNum string
+	RefNum   string
+	Name     string // NAME, o el de PAYEE
+	Memo     string
+	Currency string // moneda de Amount si no es la del extracto (CURRENCY); con ORIGCURRENCY Amount ya está convertido
+}
+
+// Balance es un saldo a una fecha.
+type Balance struct {
+	Amount money.Amount
+	AsOf   time.Time
+}
+
+// Detect indica si data parece un archivo OFX, por su cabecera o por el
+// elemento raíz.
+func Detect(data []byte) bool {
+	inicio := data
+	if len(inicio) > 1024 {
+		inicio = inicio[:1024]
+	}
+	inicio = bytes.ToUpper(inicio)
+	return bytes.Contains(inicio, []byte("OFXHEADER")) || bytes.Contains(inicio, []byte("<OFX>"))
+}
+
+// Parse lee todos los extractos de un archivo OFX. Las fechas conservan la
+// zona horaria en que están escritas, de modo que Date devuelve el día que
+// indica el banco.
+func Parse(data []byte) ([]*Statement, error) {
+	if !Detect(data) {
+		return nil, fmt.Errorf("%w: no es un archivo OFX", ErrInvalidOFX)
+	}
+	// Los OFX 1.x suelen declarar CHARSET:1252; si el contenido no es UTF-8
+	// válido se lee como Windows-1252.
+	if !utf8.Valid(data) {
+		decoded, err := charmap.Windows1252.NewDecoder().Bytes(data)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidOFX, err)
+		}
+		data = decoded
+	}
+
+	raiz, err := parseTree(string(data))
+	if err != nil {
+		return nil, err
+	}
+	ofx := raiz.child("OFX")
+	if ofx == nil {
+		return nil, fmt.Errorf("%w: falta el elemento OFX", ErrInvalidOFX)
+	}
+
+	var statements []*Statement
+	for _, nodo := range ofx.findAll("STMTRS", "CCSTMTRS") {
+		st, err := parseStatement(nodo)
+		if err != nil {
+			return nil, err
+		}
+		statements = append(statements, st)
+	}
+	if len(statements) == 0 {
+		return nil, fmt.Errorf("%w: no contiene extractos", ErrInvalidOFX)
+	}
+	return statements, nil
+}
+
+func parseStatement(n *node) (*Statement, error) {
+	st := &Statement{Currency: strings.ToUpper(n.value("CURDEF"))}
+
+	if n.name == "CCSTMTRS" {
+		st.Account = Account{ID: n.path("CCACCTFROM", "ACCTID"), Type: TipoTarjeta}
+	} else if cuenta := n.child("BANKACCTFROM"); cuenta != nil {
+		st.Account = Account{
+			BankID:   cuenta.value("BANKID"),
+			BranchID: cuenta.value("BRANCHID"),
+			ID:       cuenta.value("ACCTID"),
+			Type:     strings.ToUpper(cuenta.value("ACCTTYPE")),
+		}
+	}
+	if st.Account.ID == "" {
+		return nil, fmt.Errorf("%w: falta el número de cuenta (ACCTID)", ErrInvalidOFX)
+	}
+
+	var err error
+	if lista := n.child("BANKTRANLIST"); lista != nil {
+		if st.Start, err = parseOptionalDate(lista, "DTSTART"); err != nil {
+			return nil, err
+		}
+		if st.End, err = parseOptionalDate(lista, "DTEND"); err != nil {
+			return nil, err
+		}
+		for _, trn := range lista.children {
+			if trn.name != "STMTTRN" {
+				continue
+			}
+			t, err := parseTransaction(trn)
+			if err != nil {
+				return nil, fmt.Errorf("%w: movimiento %d de la cuenta %s: %v", ErrInvalidOFX, len(st.Transactions)+1, st.Account.ID, err)
+			}
+			st.Transactions = append(st.Transactions, t)
+		}
+	}
+
+	if st.LedgerBalance, err = parseBalance(n.child("LEDGERBAL")); err != nil {
+		return nil, fmt.Errorf("%w: LEDGERBAL: %v", ErrInvalidOFX, err)
+	}
+	if st.AvailableBalance, err = parseBalance(n.child("AVAILBAL")); err != nil {
+		return nil, fmt.Errorf("%w: AVAILBAL: %v", ErrInvalidOFX, err)
+	}
+	return st, nil
+}
+
+func parseTransaction(n *node) (*Transaction, error) {
+	t := &Transaction{
+		Type:     strings.ToUpper(n.value("TRNTYPE")),
+		FITID:    n.value("FITID"),
+		CheckNum: n.value("CHECKNUM"),
+		RefNum:   n.value("REFNUM"),
+		Name:     n.value("NAME"),
+		Memo:     n.value("MEMO"),
+	}
+	if t.Name == "" {
+		t.Name = n.path("PAYEE", "NAME")
+	}
+	t.Currency = strings.ToUpper(n.path("CURRENCY", "CURSYM"))
+
+	var err error
+	if t.Posted, err = parseDate(n.value("DTPOSTED")); err != nil {
+		return nil, fmt.Errorf("DTPOSTED: %v", err)
+	}
+	if t.User, err = parseOptionalDate(n, "DTUSER"); err != nil {
+		return nil, err
+	}
+	if t.Amount, err = parseAmount(n.value("TRNAMT")); err != nil {
+		return nil, fmt.Errorf("TRNAMT: %v", err)
+	}
+	return t, nil
+}
+
+func parseBalance(n *node) (*Balance, error) {
+	if n == nil {
+		return nil, nil
+	}
+	monto, err := parseAmount(n.value("BALAMT"))
+	if err != nil {
+		return nil, err
+	}
+	fecha, err := parseDate(n.value("DTASOF"))
+	if err != nil {
+		return nil, err
+	}
+	return &Balance{Amount: monto, AsOf: fecha}, nil
+}
+
+// parseAmount lee un importe de OFX. Algunos bancos usan la coma decimal.
+func parseAmount(s string) (money.Amount, error) {
+	s = strings.TrimPrefix(strings.TrimSpace(s), "+")
+	if !strings.Contains(s, ".") {
+		s = strings.Replace(s, ",", ".", 1)
+	}
+	if s == "" {
+		return 0, errors.New("falta el importe")
+	}
+	a, err := money.Parse(s)
+	if err != nil {
+		return 0, fmt.Errorf("%q: %v", s, err)
+	}
+	return a, nil
+}
+
+func parseOptionalDate(n *node, nombre string) (time.Time, error) {
+	v := n.value(nombre)
+	if v == "" {
+		return time.Time{}, nil
+	}
+	t, err := parseDate(v)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%s: %v", nombre, err)
+	}
+	return t, nil
+}
+
+// parseDate lee una fecha de OFX: YYYYMMDD seguida opcionalmente de HHMMSS,
+// milisegundos y la zona horaria entre corchetes, como
+// 20250103120000.000[-5:EST]. Sin zona horaria es UTC.
+func parseDate(s string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	zona := time.UTC
+	if i := strings.IndexByte(s, '['); i >= 0 {
+		fin := strings.IndexByte(s, ']')
+		if fin < i {
+			return time.Time{}, fmt.Errorf("fecha inválida %q", s)
+		}
+		offset, nombre, _ := strings.Cut(s[i+1:fin], ":")
+		horas, err := strconv.ParseFloat(offset, 64)
+		if err != nil || horas < -14 || horas > 14 {
+			return time.Time{}, fmt.Errorf("zona horaria inválida %q", s)
+		}
+		zona = time.FixedZone(nombre, int(horas*3600))
+		s = s[:i]
+	}
+	s, _, _ = strings.Cut(s, ".")
+
+	var layout string
+	switch len(s) {
+	case 8:
+		layout = "20060102"
+	case 10:
+		layout = "2006010215"
+	case 12:
+		layout = "200601021504"
+	case 14:
+		layout = "20060102150405"
+	default:
+		return time.Time{}, fmt.Errorf("fecha inválida %q", s)
+	}
+	t, err := time.ParseInLocation(layout, s, zona)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("fecha inválida %q", s)
+	}
+	return t, nil
+}
+
+// node es un elemento del documento: un agregado con hijos o un elemento con
+// valor.
+type node struct {
+	name     string
+	text     string
+	children []*node
+}
+
+func (n *node) child(nombre string) *node {
+	for _, c := range n.children {
+		if c.name == nombre {
+			return c
+		}
+	}
+	return nil
+}
+
+// value devuelve el valor del hijo nombre, o vacío si no existe.
+func (n *node) value(nombre string) string {
+	if c := n.child(nombre); c != nil {
+		return c.text
+	}
+	return ""
+}
+
+// path devuelve el valor del descendiente que sigue los nombres.
+func (n *node) path(nombres ...string) string {
+	for _, nombre := range nombres[:len(nombres)-1] {
+		if n = n.child(nombre); n == nil {
+			return ""
+		}
+	}
+	return n.value(nombres[len(nombres)-1])
+}
+
+// findAll devuelve los descendientes con alguno de los nombres, en orden, sin
+// buscar dentro de ellos.
+func (n *node) findAll(nombres ...string) []*node {
+	var nodos []*node
+	for _, c := range n.children {
+		encontrado := false
+		for _, nombre := range nombres {
+			if c.name == nombre {
+				encontrado = true
+				break
+			}
+		}
+		if encontrado {
+			nodos = append(nodos, c)
+		} else {
+			nodos = append(nodos, c.findAll(nombres...)...)
+		}
+	}
+	return nodos
+}
+
+// parseTree arma el árbol del documento, ignorando la cabecera, las
+// instrucciones de procesamiento y los comentarios. En SGML un elemento con
+// valor termina donde empieza la siguiente etiqueta, y uno sin valor, como un
+// <MEMO> vacío, donde se cierra el agregado que lo contiene.
+func parseTree(s string) (*node, error) {
+	raiz := &node{}
+	pila := []*node{raiz}
+	tope := func() *node { return pila[len(pila)-1] }
+	// cerrarValor cierra el elemento con valor que quedó abierto
+	cerrarValor := func() {
+		if n := tope(); len(pila) > 1 && n.text != "" && len(n.children) == 0 {
+			pila = pila[:len(pila)-1]
+		}
+	}
+
+	for len(s) > 0 {
+		i := strings.IndexByte(s, '<')
+		if i < 0 {
+			i = len(s)
+		}
+		if texto := strings.TrimSpace(s[:i]); texto != "" && len(pila) > 1 {
+			tope().text = html.UnescapeString(texto)
+		}
+		s = s[i:]
+		if s == "" {
+			break
+		}
+
+		switch {
+		case strings.HasPrefix(s, "<!--"):
+			fin := strings.Index(s, "-->")
+			if fin < 0 {
+				return nil, fmt.Errorf("%w: comentario sin cerrar", ErrInvalidOFX)
+			}
+			s = s[fin+3:]
+			continue
+		case strings.HasPrefix(s, "<?"), strings.HasPrefix(s, "<!"):
+			fin := strings.IndexByte(s, '>')
+			if fin < 0 {
+				return nil, fmt.Errorf("%w: etiqueta sin cerrar", ErrInvalidOFX)
+			}
+			s = s[fin+1:]
+			continue
+		}
+
+		fin := strings.IndexByte(s, '>')
+		if fin < 0 {
+			return nil, fmt.Errorf("%w: etiqueta sin cerrar", ErrInvalidOFX)
+		}
+		etiqueta := strings.TrimSpace(s[1:fin])
+		s = s[fin+1:]
+
+		if nombre, ok := strings.CutPrefix(etiqueta, "/"); ok {
+			nombre = strings.ToUpper(strings.TrimSpace(nombre))
+			if tope().name != nombre {
+				cerrarValor()
+			}
+			// Cierra hasta el elemento indicado; un cierre sin apertura se ignora
+			for j := len(pila) - 1; j > 0; j-- {
+				if pila[j].name == nombre {
+					// Los agregados siempre se cierran: los que quedaron
+					// abiertos en medio eran elementos sin valor, y lo que
+					// se leyó dentro de ellos son sus hermanos
+					for k := len(pila) - 1; k > j; k-- {
+						padre, vacio := pila[k-1], pila[k]
+						padre.children = append(padre.children, vacio.children...)
+						vacio.children = nil
+					}
+					pila = pila[:j]
+					break
+				}
+			}
+			continue
+		}
+
+		cerrarValor()
+		vacio := strings.HasSuffix(etiqueta, "/")
+		etiqueta = strings.TrimSuffix(etiqueta, "/")
+		if j := strings.IndexAny(etiqueta, " \t\r\n"); j >= 0 {
+			etiqueta = etiqueta[:j] // atributos
+		}
+		if etiqueta == "" {
+			return nil, fmt.Errorf("%w: etiqueta vacía", ErrInvalidOFX)
+		}
+		n := &node{name: strings.ToUpper(etiqueta)}
+		tope().children = append(tope().children, n)
+		if !vacio {
+			pila = append(pila, n)
+		}
+	}
+	return raiz, nil
+}
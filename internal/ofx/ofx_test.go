@@ -0,0 +1,102 @@
+package ofx
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"control-financiero/internal/money"
+	"control-financiero/internal/testutil"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse_Golden(t *testing.T) {
+	archivos, err := filepath.Glob(filepath.Join("testdata", "*.[oq]fx"))
+	require.NoError(t, err)
+	require.NotEmpty(t, archivos)
+
+	for _, archivo := range archivos {
+		t.Run(filepath.Base(archivo), func(t *testing.T) {
+			data, err := os.ReadFile(archivo)
+			require.NoError(t, err)
+
+			statements, err := Parse(data)
+			require.NoError(t, err)
+			testutil.Golden(t, archivo, statements)
+		})
+	}
+}
+
+func TestParse_FechaConZonaHoraria(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("testdata", "sgml_banco.ofx"))
+	require.NoError(t, err)
+	statements, err := Parse(data)
+	require.NoError(t, err)
+
+	// 23:59 en Lima ya es el día siguiente en UTC; Date debe dar el del banco
+	asOf := statements[0].LedgerBalance.AsOf
+	y, m, d := asOf.Date()
+	assert.Equal(t, "2025-01-31", time.Date(y, m, d, 0, 0, 0, 0, time.UTC).Format(time.DateOnly))
+	assert.Equal(t, "2025-02-01T04:59:59Z", asOf.UTC().Format(time.RFC3339))
+}
+
+func TestParse_Errores(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want string
+	}{
+		{"no es OFX", "fecha,monto\n2025-01-01,10\n", "no es un archivo OFX"},
+		{"sin extractos", "OFXHEADER:100\n\n<OFX><SIGNONMSGSRSV1></SIGNONMSGSRSV1></OFX>", "no contiene extractos"},
+		{"sin número de cuenta", "<OFX><STMTRS><CURDEF>USD<BANKACCTFROM><BANKID>1</BANKACCTFROM></STMTRS></OFX>", "ACCTID"},
+		{"importe inválido", "<OFX><STMTRS><BANKACCTFROM><ACCTID>1</BANKACCTFROM><BANKTRANLIST>" +
+			"<STMTTRN><DTPOSTED>20250101<TRNAMT>diez<FITID>1</STMTTRN></BANKTRANLIST></STMTRS></OFX>", "movimiento 1 de la cuenta 1: TRNAMT"},
+		{"fecha inválida", "<OFX><STMTRS><BANKACCTFROM><ACCTID>1</BANKACCTFROM><BANKTRANLIST>" +
+			"<STMTTRN><DTPOSTED>2025-01-01<TRNAMT>10<FITID>1</STMTTRN></BANKTRANLIST></STMTRS></OFX>", "DTPOSTED"},
+		{"etiqueta sin cerrar", "<OFX><STMTRS", "etiqueta sin cerrar"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse([]byte(tt.data))
+			require.ErrorIs(t, err, ErrInvalidOFX)
+			assert.Contains(t, err.Error(), tt.want)
+		})
+	}
+}
+
+func TestParseDate(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"20250103", "2025-01-03T00:00:00Z"},
+		{"20250103143000", "2025-01-03T14:30:00Z"},
+		{"20250103143000.123", "2025-01-03T14:30:00Z"},
+		{"20250103143000.000[-5:EST]", "2025-01-03T14:30:00-05:00"},
+		{"20250103143000[+5.5:IST]", "2025-01-03T14:30:00+05:30"},
+		{"20250103[0:GMT]", "2025-01-03T00:00:00Z"},
+	}
+	for _, tt := range tests {
+		got, err := parseDate(tt.in)
+		require.NoError(t, err, tt.in)
+		assert.Equal(t, tt.want, got.Format(time.RFC3339), tt.in)
+	}
+
+	for _, in := range []string{"", "2025-01-03", "202501", "20250103[-25:X]", "20251303"} {
+		_, err := parseDate(in)
+		assert.Error(t, err, in)
+	}
+}
+
+func TestParseAmount(t *testing.T) {
+	for in, want := range map[string]string{"-45.90": "-45.9", "+500.00": "500", "-1200,00": "-1200", "1,5": "1.5", "-300": "-300"} {
+		got, err := parseAmount(in)
+		require.NoError(t, err, in)
+		assert.Equal(t, money.MustParse(want), got, in)
+	}
+	_, err := parseAmount("")
+	assert.Error(t, err)
+}
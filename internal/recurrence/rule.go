@@ -0,0 +1,438 @@
+// Package recurrence calcula las fechas de una regla de repetición. Admite un
+// subconjunto de las RRULE de RFC 5545 con precisión de día: FREQ (DAILY,
+// WEEKLY, MONTHLY o YEARLY), INTERVAL, COUNT, UNTIL, BYDAY, BYMONTHDAY,
+// BYMONTH, BYSETPOS y WKST. Todas las fechas son días a medianoche UTC.
+package recurrence
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Frequency es el periodo base de la regla.
+type Frequency int
+
+const (
+	Daily Frequency = iota
+	Weekly
+	Monthly
+	Yearly
+)
+
+var frequencies = map[string]Frequency{"DAILY": Daily, "WEEKLY": Weekly, "MONTHLY": Monthly, "YEARLY": Yearly}
+
+var weekdays = map[string]time.Weekday{
+	"SU": time.Sunday, "MO": time.Monday, "TU": time.Tuesday, "WE": time.Wednesday,
+	"TH": time.Thursday, "FR": time.Friday, "SA": time.Saturday,
+}
+
+// horizonte limita la búsqueda de fechas de las reglas que casi nunca, o
+// nunca, se cumplen, como el 30 de febrero.
+const horizonte = 100 // años
+
+var ErrInvalidRule = errors.New("regla de recurrencia inválida")
+
+// Weekday es un día de BYDAY. Con N distinto de cero elige el N-ésimo de ese
+// día de la semana en el mes, o contando desde el final si es negativo: -1FR
+// es el último viernes.
+type Weekday struct {
+	Day time.Weekday
+	N   int
+}
+
+// Rule es una regla de repetición. Las fechas se cuentan desde el inicio que
+// se pasa a Next y Occurrences, que solo es una ocurrencia si cumple la regla.
+type Rule struct {
+	Freq       Frequency
+	Interval   int // cada cuántos periodos; 0 equivale a 1
+	ByMonth    []time.Month
+	ByMonthDay []int // los negativos cuentan desde el final del mes
+	ByDay      []Weekday
+	BySetPos   []int        // posiciones dentro de las fechas de cada periodo
+	WeekStart  time.Weekday // WKST; Parse usa el lunes si no se indica
+	Count      int          // 0 es sin límite
+	Until      time.Time    // último día posible; cero es sin límite
+}
+
+// Parse lee una RRULE como "FREQ=MONTHLY;BYDAY=MO,TU,WE,TH,FR;BYSETPOS=-1",
+// con o sin el prefijo "RRULE:". Rechaza las partes que no admite.
+func Parse(s string) (*Rule, error) {
+	s = strings.TrimSpace(s)
+	if len(s) >= 6 && strings.EqualFold(s[:6], "RRULE:") {
+		s = s[6:]
+	}
+
+	r := &Rule{WeekStart: time.Monday}
+	vistas := make(map[string]bool)
+	for _, parte := range strings.Split(strings.ToUpper(s), ";") {
+		clave, valor, ok := strings.Cut(parte, "=")
+		if !ok || valor == "" {
+			return nil, fmt.Errorf("%w: %q no es CLAVE=VALOR", ErrInvalidRule, parte)
+		}
+		if vistas[clave] {
+			return nil, fmt.Errorf("%w: %s repetido", ErrInvalidRule, clave)
+		}
+		vistas[clave] = true
+
+		var err error
+		switch clave {
+		case "FREQ":
+			f, ok := frequencies[valor]
+			if !ok {
+				return nil, fmt.Errorf("%w: FREQ=%s no admitida", ErrInvalidRule, valor)
+			}
+			r.Freq = f
+		case "INTERVAL":
+			r.Interval, err = parseInt(valor, 1, 1000)
+		case "COUNT":
+			r.Count, err = parseInt(valor, 1, 10000)
+		case "UNTIL":
+			r.Until, err = parseUntil(valor)
+		case "BYMONTH":
+			err = parseList(valor, func(v string) error {
+				m, err := parseInt(v, 1, 12)
+				r.ByMonth = append(r.ByMonth, time.Month(m))
+				return err
+			})
+		case "BYMONTHDAY":
+			err = parseList(valor, func(v string) error {
+				d, err := parseInt(v, -31, 31)
+				if d == 0 {
+					err = errors.New("el día 0 no existe")
+				}
+				r.ByMonthDay = append(r.ByMonthDay, d)
+				return err
+			})
+		case "BYDAY":
+			err = parseList(valor, func(v string) error {
+				d, err := parseWeekday(v)
+				r.ByDay = append(r.ByDay, d)
+				return err
+			})
+		case "BYSETPOS":
+			err = parseList(valor, func(v string) error {
+				p, err := parseInt(v, -366, 366)
+				if p == 0 {
+					err = errors.New("la posición 0 no existe")
+				}
+				r.BySetPos = append(r.BySetPos, p)
+				return err
+			})
+		case "WKST":
+			d, ok := weekdays[valor]
+			if !ok {
+				return nil, fmt.Errorf("%w: WKST=%s", ErrInvalidRule, valor)
+			}
+			r.WeekStart = d
+		default:
+			return nil, fmt.Errorf("%w: %s no está admitido", ErrInvalidRule, clave)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s=%s: %v", ErrInvalidRule, clave, valor, err)
+		}
+	}
+
+	if !vistas["FREQ"] {
+		return nil, fmt.Errorf("%w: falta FREQ", ErrInvalidRule)
+	}
+	if vistas["COUNT"] && vistas["UNTIL"] {
+		return nil, fmt.Errorf("%w: COUNT y UNTIL no pueden usarse juntos", ErrInvalidRule)
+	}
+	if err := r.Validate(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Validate comprueba las combinaciones que RFC 5545 no permite o que este
+// paquete no admite.
+func (r *Rule) Validate() error {
+	if r.Interval < 0 || r.Count < 0 {
+		return fmt.Errorf("%w: INTERVAL y COUNT no pueden ser negativos", ErrInvalidRule)
+	}
+	if r.Freq == Weekly && len(r.ByMonthDay) > 0 {
+		return fmt.Errorf("%w: BYMONTHDAY no se usa con FREQ=WEEKLY", ErrInvalidRule)
+	}
+	for _, d := range r.ByDay {
+		if d.N == 0 {
+			continue
+		}
+		if r.Freq != Monthly && (r.Freq != Yearly || len(r.ByMonth) == 0) {
+			return fmt.Errorf("%w: BYDAY con posición solo se admite con FREQ=MONTHLY o FREQ=YEARLY y BYMONTH", ErrInvalidRule)
+		}
+	}
+	if len(r.BySetPos) > 0 && len(r.ByMonth)+len(r.ByMonthDay)+len(r.ByDay) == 0 {
+		return fmt.Errorf("%w: BYSETPOS necesita otra parte BY", ErrInvalidRule)
+	}
+	return nil
+}
+
+// Next devuelve la primera ocurrencia a partir de desde, inclusive, o false
+// si no quedan.
+func (r *Rule) Next(inicio, desde time.Time) (time.Time, bool) {
+	fechas := r.Occurrences(inicio, desde, time.Time{}, 1)
+	if len(fechas) == 0 {
+		return time.Time{}, false
+	}
+	return fechas[0], true
+}
+
+// Occurrences devuelve las ocurrencias entre desde y hasta, ambos inclusive,
+// contando la regla desde inicio. Con hasta cero no hay fecha máxima y con
+// limite cero no hay cantidad máxima.
+func (r *Rule) Occurrences(inicio, desde, hasta time.Time, limite int) []time.Time {
+	desde, hasta = Day(desde), Day(hasta)
+	var fechas []time.Time
+	r.each(Day(inicio), func(fecha time.Time) bool {
+		if !hasta.IsZero() && fecha.After(hasta) {
+			return false
+		}
+		if !fecha.Before(desde) {
+			fechas = append(fechas, fecha)
+		}
+		return limite == 0 || len(fechas) < limite
+	})
+	return fechas
+}
+
+// each recorre las ocurrencias en orden mientras fn devuelva true.
+func (r *Rule) each(inicio time.Time, fn func(time.Time) bool) {
+	intervalo := r.Interval
+	if intervalo == 0 {
+		intervalo = 1
+	}
+	until := Day(r.Until)
+	fin := inicio.AddDate(horizonte, 0, 0)
+
+	cantidad := 0
+	for n := 0; ; n += intervalo {
+		periodo := r.period(inicio, n)
+		if periodo.After(fin) || !until.IsZero() && periodo.After(until) {
+			return
+		}
+		for _, fecha := range r.setPos(r.expand(inicio, periodo)) {
+			if fecha.Before(inicio) {
+				continue
+			}
+			if !until.IsZero() && fecha.After(until) {
+				return
+			}
+			if !fn(fecha) {
+				return
+			}
+			cantidad++
+			if r.Count > 0 && cantidad >= r.Count {
+				return
+			}
+		}
+	}
+}
+
+// period devuelve el primer día del periodo n contado desde el de inicio.
+func (r *Rule) period(inicio time.Time, n int) time.Time {
+	switch r.Freq {
+	case Weekly:
+		retroceso := (int(inicio.Weekday()) - int(r.WeekStart) + 7) % 7
+		return inicio.AddDate(0, 0, 7*n-retroceso)
+	case Monthly:
+		return date(inicio.Year(), inicio.Month(), 1).AddDate(0, n, 0)
+	case Yearly:
+		return date(inicio.Year()+n, time.January, 1)
+	}
+	return inicio.AddDate(0, 0, n)
+}
+
+// expand devuelve en orden los días del periodo que cumplen la regla.
+func (r *Rule) expand(inicio, periodo time.Time) []time.Time {
+	var fechas []time.Time
+	switch r.Freq {
+	case Daily:
+		if r.matchMonth(periodo.Month()) && r.matchMonthDay(periodo) && r.matchDay(periodo) {
+			fechas = append(fechas, periodo)
+		}
+	case Weekly:
+		for i := 0; i < 7; i++ {
+			fecha := periodo.AddDate(0, 0, i)
+			mismoDia := len(r.ByDay) == 0 && fecha.Weekday() == inicio.Weekday()
+			if (mismoDia || len(r.ByDay) > 0 && r.matchDay(fecha)) && r.matchMonth(fecha.Month()) {
+				fechas = append(fechas, fecha)
+			}
+		}
+	case Monthly:
+		if r.matchMonth(periodo.Month()) {
+			fechas = r.expandMonth(inicio, periodo.Year(), periodo.Month())
+		}
+	case Yearly:
+		meses := r.ByMonth
+		if len(meses) == 0 {
+			meses = []time.Month{inicio.Month()}
+			// Sin BYMONTH, los días del mes o de la semana valen en todo el año
+			if len(r.ByMonthDay)+len(r.ByDay) > 0 {
+				meses = []time.Month{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12}
+			}
+		}
+		for _, mes := range meses {
+			fechas = append(fechas, r.expandMonth(inicio, periodo.Year(), mes)...)
+		}
+		sort.Slice(fechas, func(i, j int) bool { return fechas[i].Before(fechas[j]) })
+	}
+	return fechas
+}
+
+// expandMonth devuelve los días del mes que cumplen BYMONTHDAY y BYDAY, o el
+// mismo día que inicio si la regla no tiene ninguno de los dos.
+func (r *Rule) expandMonth(inicio time.Time, year int, mes time.Month) []time.Time {
+	ultimo := daysIn(year, mes)
+	if len(r.ByMonthDay)+len(r.ByDay) == 0 {
+		if inicio.Day() > ultimo {
+			return nil
+		}
+		return []time.Time{date(year, mes, inicio.Day())}
+	}
+
+	var fechas []time.Time
+	for d := 1; d <= ultimo; d++ {
+		fecha := date(year, mes, d)
+		if r.matchMonthDay(fecha) && r.matchDay(fecha) {
+			fechas = append(fechas, fecha)
+		}
+	}
+	return fechas
+}
+
+func (r *Rule) matchMonth(mes time.Month) bool {
+	if len(r.ByMonth) == 0 {
+		return true
+	}
+	for _, m := range r.ByMonth {
+		if m == mes {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Rule) matchMonthDay(fecha time.Time) bool {
+	if len(r.ByMonthDay) == 0 {
+		return true
+	}
+	ultimo := daysIn(fecha.Year(), fecha.Month())
+	for _, d := range r.ByMonthDay {
+		if d == fecha.Day() || d < 0 && ultimo+d+1 == fecha.Day() {
+			return true
+		}
+	}
+	return false
+}
+
+// matchDay cuenta las posiciones de BYDAY dentro del mes de fecha.
+func (r *Rule) matchDay(fecha time.Time) bool {
+	if len(r.ByDay) == 0 {
+		return true
+	}
+	ultimo := daysIn(fecha.Year(), fecha.Month())
+	for _, d := range r.ByDay {
+		if d.Day != fecha.Weekday() {
+			continue
+		}
+		switch {
+		case d.N == 0,
+			d.N > 0 && (fecha.Day()-1)/7+1 == d.N,
+			d.N < 0 && (ultimo-fecha.Day())/7+1 == -d.N:
+			return true
+		}
+	}
+	return false
+}
+
+// setPos se queda con las posiciones de BYSETPOS de las fechas del periodo.
+func (r *Rule) setPos(fechas []time.Time) []time.Time {
+	if len(r.BySetPos) == 0 || len(fechas) == 0 {
+		return fechas
+	}
+	elegidas := make(map[int]bool)
+	for _, p := range r.BySetPos {
+		i := p - 1
+		if p < 0 {
+			i = len(fechas) + p
+		}
+		if i >= 0 && i < len(fechas) {
+			elegidas[i] = true
+		}
+	}
+	var resultado []time.Time
+	for i, fecha := range fechas {
+		if elegidas[i] {
+			resultado = append(resultado, fecha)
+		}
+	}
+	return resultado
+}
+
+// Day lleva t a la medianoche UTC de su día. El tiempo cero no cambia.
+func Day(t time.Time) time.Time {
+	if t.IsZero() {
+		return t
+	}
+	y, m, d := t.UTC().Date()
+	return date(y, m, d)
+}
+
+func date(year int, mes time.Month, dia int) time.Time {
+	return time.Date(year, mes, dia, 0, 0, 0, 0, time.UTC)
+}
+
+func daysIn(year int, mes time.Month) int {
+	return date(year, mes+1, 0).Day()
+}
+
+func parseInt(s string, min, max int) (int, error) {
+	n, err := strconv.Atoi(s)
+	if err != nil || n < min || n > max {
+		return 0, fmt.Errorf("debe ser un número entre %d y %d", min, max)
+	}
+	return n, nil
+}
+
+func parseList(s string, fn func(string) error) error {
+	for _, v := range strings.Split(s, ",") {
+		if err := fn(strings.TrimSpace(v)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parseWeekday lee un día de BYDAY como "MO", "2TU" o "-1FR".
+func parseWeekday(s string) (Weekday, error) {
+	if len(s) < 2 {
+		return Weekday{}, errors.New("día de la semana inválido")
+	}
+	dia, ok := weekdays[s[len(s)-2:]]
+	if !ok {
+		return Weekday{}, errors.New("día de la semana inválido")
+	}
+	var n int
+	if prefijo := s[:len(s)-2]; prefijo != "" {
+		var err error
+		if n, err = parseInt(strings.TrimPrefix(prefijo, "+"), -5, 5); err != nil || n == 0 {
+			return Weekday{}, errors.New("la posición del día debe estar entre -5 y 5")
+		}
+	}
+	return Weekday{Day: dia, N: n}, nil
+}
+
+// parseUntil acepta una fecha (20251231) o una fecha y hora UTC
+// (20251231T235959Z) y se queda con el día.
+func parseUntil(s string) (time.Time, error) {
+	for _, layout := range []string{"20060102", "20060102T150405Z"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return Day(t), nil
+		}
+	}
+	return time.Time{}, errors.New("use AAAAMMDD o AAAAMMDDTHHMMSSZ")
+}
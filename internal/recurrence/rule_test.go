@@ -0,0 +1,121 @@
+package recurrence
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func dias(fechas []time.Time) []string {
+	s := make([]string, len(fechas))
+	for i, f := range fechas {
+		s[i] = f.Format(time.DateOnly)
+	}
+	return s
+}
+
+func mustDate(s string) time.Time {
+	t, err := time.Parse(time.DateOnly, s)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func TestOccurrences(t *testing.T) {
+	tests := []struct {
+		name   string
+		rrule  string
+		inicio string
+		limite int
+		want   []string
+	}{
+		{"diaria cada tres días", "FREQ=DAILY;INTERVAL=3", "2025-01-30", 3,
+			[]string{"2025-01-30", "2025-02-02", "2025-02-05"}},
+		{"semanal el día de inicio", "FREQ=WEEKLY;INTERVAL=2", "2025-01-01", 3,
+			[]string{"2025-01-01", "2025-01-15", "2025-01-29"}},
+		{"semanal varios días", "RRULE:FREQ=WEEKLY;BYDAY=MO,FR", "2025-01-01", 4,
+			[]string{"2025-01-03", "2025-01-06", "2025-01-10", "2025-01-13"}},
+		{"mensual salta los meses sin el día", "FREQ=MONTHLY", "2025-01-31", 3,
+			[]string{"2025-01-31", "2025-03-31", "2025-05-31"}},
+		{"mensual día 31 o el último del mes", "FREQ=MONTHLY;BYMONTHDAY=28,29,30,31;BYSETPOS=-1", "2025-01-31", 3,
+			[]string{"2025-01-31", "2025-02-28", "2025-03-31"}},
+		{"último día hábil", "FREQ=MONTHLY;BYDAY=MO,TU,WE,TH,FR;BYSETPOS=-1", "2025-05-01", 3,
+			[]string{"2025-05-30", "2025-06-30", "2025-07-31"}},
+		{"último viernes", "FREQ=MONTHLY;BYDAY=-1FR", "2025-01-01", 2,
+			[]string{"2025-01-31", "2025-02-28"}},
+		{"segundo martes", "FREQ=MONTHLY;BYDAY=2TU;COUNT=2", "2025-01-01", 0,
+			[]string{"2025-01-14", "2025-02-11"}},
+		{"penúltimo día del mes", "FREQ=MONTHLY;BYMONTHDAY=-2", "2024-02-01", 2,
+			[]string{"2024-02-28", "2024-03-30"}},
+		{"anual el día de inicio", "FREQ=YEARLY", "2024-02-29", 2,
+			[]string{"2024-02-29", "2028-02-29"}},
+		{"anual en varios meses", "FREQ=YEARLY;BYMONTH=6,12;BYMONTHDAY=15", "2025-07-01", 3,
+			[]string{"2025-12-15", "2026-06-15", "2026-12-15"}},
+		{"anual primer lunes de septiembre", "FREQ=YEARLY;BYMONTH=9;BYDAY=1MO", "2025-01-01", 2,
+			[]string{"2025-09-01", "2026-09-07"}},
+		{"hasta una fecha", "FREQ=MONTHLY;BYMONTHDAY=1;UNTIL=20250301T120000Z", "2025-01-01", 0,
+			[]string{"2025-01-01", "2025-02-01", "2025-03-01"}},
+		{"semana que empieza el domingo", "FREQ=WEEKLY;INTERVAL=2;BYDAY=SU,MO;WKST=SU", "2025-01-05", 4,
+			[]string{"2025-01-05", "2025-01-06", "2025-01-19", "2025-01-20"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := Parse(tt.rrule)
+			require.NoError(t, err)
+			inicio := mustDate(tt.inicio)
+			got := r.Occurrences(inicio, inicio, time.Time{}, tt.limite)
+			assert.Equal(t, tt.want, dias(got))
+		})
+	}
+}
+
+func TestOccurrences_Rango(t *testing.T) {
+	r, err := Parse("FREQ=WEEKLY;COUNT=5")
+	require.NoError(t, err)
+	inicio := mustDate("2025-01-01")
+
+	// COUNT se cuenta desde el inicio aunque el rango empiece después
+	got := r.Occurrences(inicio, mustDate("2025-01-10"), mustDate("2025-12-31"), 0)
+	assert.Equal(t, []string{"2025-01-15", "2025-01-22", "2025-01-29"}, dias(got))
+
+	siguiente, ok := r.Next(inicio, mustDate("2025-01-16").Add(15*time.Hour))
+	require.True(t, ok)
+	assert.Equal(t, "2025-01-22", siguiente.Format(time.DateOnly))
+
+	_, ok = r.Next(inicio, mustDate("2025-01-30"))
+	assert.False(t, ok)
+}
+
+func TestNext_SinOcurrencias(t *testing.T) {
+	r, err := Parse("FREQ=YEARLY;BYMONTH=2;BYMONTHDAY=30")
+	require.NoError(t, err)
+	_, ok := r.Next(mustDate("2025-01-01"), mustDate("2025-01-01"))
+	assert.False(t, ok)
+}
+
+func TestParse_Errores(t *testing.T) {
+	for _, rrule := range []string{
+		"",
+		"INTERVAL=2",
+		"FREQ=HOURLY",
+		"FREQ=DAILY;FREQ=WEEKLY",
+		"FREQ=DAILY;BYHOUR=9",
+		"FREQ=DAILY;INTERVAL=0",
+		"FREQ=DAILY;COUNT=2;UNTIL=20250101",
+		"FREQ=DAILY;UNTIL=2025-01-01",
+		"FREQ=MONTHLY;BYMONTHDAY=0",
+		"FREQ=MONTHLY;BYMONTHDAY=32",
+		"FREQ=MONTHLY;BYDAY=XX",
+		"FREQ=MONTHLY;BYDAY=6MO",
+		"FREQ=WEEKLY;BYDAY=1MO",
+		"FREQ=WEEKLY;BYMONTHDAY=1",
+		"FREQ=YEARLY;BYDAY=1MO",
+		"FREQ=MONTHLY;BYSETPOS=1",
+	} {
+		_, err := Parse(rrule)
+		assert.ErrorIs(t, err, ErrInvalidRule, rrule)
+	}
+}
@@ -0,0 +1,75 @@
+package repositories
+
+import (
+	"context"
+	"control-financiero/internal/models"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type AuditLogRepository struct {
+	collection *mongo.Collection
+}
+
+func NewAuditLogRepository(db *mongo.Database) *AuditLogRepository {
+	// Detalle es un documento libre: se decodifica como mapa para que la
+	// respuesta JSON tenga la misma forma que se guardó
+	opts := options.Collection().SetBSONOptions(&options.BSONOptions{DefaultDocumentM: true})
+	return &AuditLogRepository{
+		collection: db.Collection("audit_logs", opts),
+	}
+}
+
+func (r *AuditLogRepository) Create(ctx context.Context, entry *models.AuditLog) error {
+	entry.ID = primitive.NewObjectID()
+	entry.CreatedAt = time.Now()
+
+	_, err := r.collection.InsertOne(ctx, entry)
+	return err
+}
+
+// Find devuelve las entradas que cumplen el filtro, las más recientes
+// primero.
+func (r *AuditLogRepository) Find(ctx context.Context, filtro models.AuditLogFilter) ([]*models.AuditLog, error) {
+	filter := bson.M{}
+	if filtro.UsuarioID != nil {
+		filter["$or"] = []bson.M{
+			{"usuarioId": filtro.UsuarioID},
+			{"afectadoId": filtro.UsuarioID},
+		}
+	}
+	if filtro.Accion != "" {
+		filter["accion"] = filtro.Accion
+	}
+	if filtro.Desde != nil || filtro.Hasta != nil {
+		rango := bson.M{}
+		if filtro.Desde != nil {
+			rango["$gte"] = filtro.Desde
+		}
+		if filtro.Hasta != nil {
+			rango["$lte"] = filtro.Hasta
+		}
+		filter["createdAt"] = rango
+	}
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "createdAt", Value: -1}, {Key: "_id", Value: -1}}).
+		SetLimit(filtro.Limite).
+		SetSkip((filtro.Pagina - 1) * filtro.Limite)
+
+	cursor, err := r.collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	entries := []*models.AuditLog{}
+	if err := cursor.All(ctx, &entries); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
@@ -0,0 +1,105 @@
+package repositories
+
+import (
+	"context"
+	"control-financiero/internal/models"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type ContratoRepository struct {
+	collection *mongo.Collection
+}
+
+func NewContratoRepository(db *mongo.Database) *ContratoRepository {
+	return &ContratoRepository{
+		collection: db.Collection("contratos"),
+	}
+}
+
+func (r *ContratoRepository) Create(ctx context.Context, contrato *models.Contrato) error {
+	contrato.ID = primitive.NewObjectID()
+	contrato.CreatedAt = time.Now()
+	contrato.UpdatedAt = time.Now()
+
+	_, err := r.collection.InsertOne(ctx, contrato)
+	return err
+}
+
+// FindByID solo encuentra el contrato si pertenece a usuarioID.
+func (r *ContratoRepository) FindByID(ctx context.Context, id, usuarioID primitive.ObjectID) (*models.Contrato, error) {
+	var contrato models.Contrato
+	err := r.collection.FindOne(ctx, bson.M{"_id": id, "usuarioId": usuarioID}).Decode(&contrato)
+	if err != nil {
+		return nil, err
+	}
+	return &contrato, nil
+}
+
+// Find devuelve los contratos del usuario, de los más nuevos a los más
+// antiguos. propiedadID e inquilinoID, si no son nil, filtran por propiedad e
+// inquilino.
+func (r *ContratoRepository) Find(ctx context.Context, usuarioID primitive.ObjectID, propiedadID, inquilinoID *primitive.ObjectID) ([]*models.Contrato, error) {
+	filter := bson.M{"usuarioId": usuarioID}
+	if propiedadID != nil {
+		filter["propiedadId"] = propiedadID
+	}
+	if inquilinoID != nil {
+		filter["inquilinoId"] = inquilinoID
+	}
+
+	cursor, err := r.collection.Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "inicio", Value: -1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	contratos := []*models.Contrato{}
+	if err := cursor.All(ctx, &contratos); err != nil {
+		return nil, err
+	}
+	return contratos, nil
+}
+
+// CountByPropiedad cuenta los contratos de la propiedad.
+func (r *ContratoRepository) CountByPropiedad(ctx context.Context, usuarioID, propiedadID primitive.ObjectID) (int64, error) {
+	return r.collection.CountDocuments(ctx, bson.M{"usuarioId": usuarioID, "propiedadId": propiedadID})
+}
+
+// CountByInquilino cuenta los contratos del inquilino.
+func (r *ContratoRepository) CountByInquilino(ctx context.Context, usuarioID, inquilinoID primitive.ObjectID) (int64, error) {
+	return r.collection.CountDocuments(ctx, bson.M{"usuarioId": usuarioID, "inquilinoId": inquilinoID})
+}
+
+// Update reemplaza el contrato si pertenece a contrato.UsuarioID.
+func (r *ContratoRepository) Update(ctx context.Context, contrato *models.Contrato) error {
+	contrato.UpdatedAt = time.Now()
+	result, err := r.collection.ReplaceOne(
+		ctx,
+		bson.M{"_id": contrato.ID, "usuarioId": contrato.UsuarioID},
+		contrato,
+	)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+// Delete solo elimina el contrato si pertenece a usuarioID.
+func (r *ContratoRepository) Delete(ctx context.Context, id, usuarioID primitive.ObjectID) error {
+	result, err := r.collection.DeleteOne(ctx, bson.M{"_id": id, "usuarioId": usuarioID})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
@@ -0,0 +1,123 @@
+package repositories
+
+import (
+	"context"
+	"control-financiero/internal/models"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type CuentaRepository struct {
+	collection *mongo.Collection
+}
+
+func NewCuentaRepository(db *mongo.Database) *CuentaRepository {
+	return &CuentaRepository{
+		collection: db.Collection("cuentas"),
+	}
+}
+
+func (r *CuentaRepository) Create(ctx context.Context, cuenta *models.Cuenta) error {
+	cuenta.ID = primitive.NewObjectID()
+	cuenta.CreatedAt = time.Now()
+	cuenta.UpdatedAt = time.Now()
+
+	_, err := r.collection.InsertOne(ctx, cuenta)
+	return err
+}
+
+// FindByID solo encuentra la cuenta si pertenece a usuarioID.
+func (r *CuentaRepository) FindByID(ctx context.Context, id, usuarioID primitive.ObjectID) (*models.Cuenta, error) {
+	var cuenta models.Cuenta
+	err := r.collection.FindOne(ctx, bson.M{"_id": id, "usuarioId": usuarioID}).Decode(&cuenta)
+	if err != nil {
+		return nil, err
+	}
+	return &cuenta, nil
+}
+
+// FindByUsuario devuelve las cuentas del usuario ordenadas por nombre. Las
+// archivadas solo se incluyen si se piden.
+func (r *CuentaRepository) FindByUsuario(ctx context.Context, usuarioID primitive.ObjectID, archivadas bool) ([]*models.Cuenta, error) {
+	filter := bson.M{"usuarioId": usuarioID}
+	if !archivadas {
+		filter["archivada"] = false
+	}
+
+	cursor, err := r.collection.Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "nombre", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	cuentas := []*models.Cuenta{}
+	if err := cursor.All(ctx, &cuentas); err != nil {
+		return nil, err
+	}
+	return cuentas, nil
+}
+
+// FindByNumeroCuenta devuelve las cuentas no archivadas del usuario con el
+// número de cuenta indicado.
+func (r *CuentaRepository) FindByNumeroCuenta(ctx context.Context, usuarioID primitive.ObjectID, numero string) ([]*models.Cuenta, error) {
+	cursor, err := r.collection.Find(ctx, bson.M{"usuarioId": usuarioID, "numeroCuenta": numero, "archivada": false})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	cuentas := []*models.Cuenta{}
+	if err := cursor.All(ctx, &cuentas); err != nil {
+		return nil, err
+	}
+	return cuentas, nil
+}
+
+// Update solo modifica la cuenta si pertenece a cuenta.UsuarioID.
+func (r *CuentaRepository) Update(ctx context.Context, cuenta *models.Cuenta) error {
+	cuenta.UpdatedAt = time.Now()
+	result, err := r.collection.UpdateOne(
+		ctx,
+		bson.M{"_id": cuenta.ID, "usuarioId": cuenta.UsuarioID},
+		bson.M{"$set": cuenta},
+	)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+// SetNumeroCuenta guarda el número de cuenta en el banco.
+func (r *CuentaRepository) SetNumeroCuenta(ctx context.Context, id, usuarioID primitive.ObjectID, numero string) error {
+	result, err := r.collection.UpdateOne(
+		ctx,
+		bson.M{"_id": id, "usuarioId": usuarioID},
+		bson.M{"$set": bson.M{"numeroCuenta": numero, "updatedAt": time.Now()}},
+	)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+// Delete solo elimina la cuenta si pertenece a usuarioID.
+func (r *CuentaRepository) Delete(ctx context.Context, id, usuarioID primitive.ObjectID) error {
+	result, err := r.collection.DeleteOne(ctx, bson.M{"_id": id, "usuarioId": usuarioID})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
@@ -0,0 +1,53 @@
+package repositories
+
+import (
+	"context"
+	"control-financiero/internal/models"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type EmailTokenRepository struct {
+	collection *mongo.Collection
+}
+
+func NewEmailTokenRepository(db *mongo.Database) *EmailTokenRepository {
+	return &EmailTokenRepository{
+		collection: db.Collection("email_tokens"),
+	}
+}
+
+func (r *EmailTokenRepository) Create(ctx context.Context, token *models.EmailToken) error {
+	token.ID = primitive.NewObjectID()
+	token.CreatedAt = time.Now()
+
+	_, err := r.collection.InsertOne(ctx, token)
+	return err
+}
+
+// Consume obtiene y elimina el token vigente del tipo indicado en una sola
+// operación, de modo que no pueda usarse dos veces.
+func (r *EmailTokenRepository) Consume(ctx context.Context, tokenHash, tipo string) (*models.EmailToken, error) {
+	filter := bson.M{
+		"tokenHash": tokenHash,
+		"tipo":      tipo,
+		"expiresAt": bson.M{"$gt": time.Now()},
+	}
+
+	var token models.EmailToken
+	err := r.collection.FindOneAndDelete(ctx, filter).Decode(&token)
+	if err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// DeleteByUsuario invalida los tokens pendientes de un tipo, por ejemplo al
+// pedir un nuevo enlace o después de usar uno.
+func (r *EmailTokenRepository) DeleteByUsuario(ctx context.Context, usuarioID primitive.ObjectID, tipo string) error {
+	_, err := r.collection.DeleteMany(ctx, bson.M{"usuarioId": usuarioID, "tipo": tipo})
+	return err
+}
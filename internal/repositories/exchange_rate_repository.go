@@ -0,0 +1,98 @@
+package repositories
+
+import (
+	"context"
+	"control-financiero/internal/models"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type ExchangeRateRepository struct {
+	collection *mongo.Collection
+}
+
+func NewExchangeRateRepository(db *mongo.Database) *ExchangeRateRepository {
+	return &ExchangeRateRepository{
+		collection: db.Collection("exchange_rates"),
+	}
+}
+
+// Upsert guarda los tipos de cambio; los que ya existen para el mismo par y
+// fecha se reemplazan.
+func (r *ExchangeRateRepository) Upsert(ctx context.Context, rates []*models.ExchangeRate) error {
+	if len(rates) == 0 {
+		return nil
+	}
+
+	now := time.Now()
+	writes := make([]mongo.WriteModel, 0, len(rates))
+	for _, rate := range rates {
+		rate.UpdatedAt = now
+		writes = append(writes, mongo.NewUpdateOneModel().
+			SetFilter(bson.M{"moneda": rate.Moneda, "base": rate.Base, "fecha": rate.Fecha}).
+			SetUpdate(bson.M{"$set": bson.M{
+				"tasa":      rate.Tasa,
+				"fuente":    rate.Fuente,
+				"updatedAt": rate.UpdatedAt,
+			}}).
+			SetUpsert(true))
+	}
+
+	_, err := r.collection.BulkWrite(ctx, writes, options.BulkWrite().SetOrdered(false))
+	return err
+}
+
+// FindVigente devuelve el tipo de cambio de moneda a base vigente en fecha:
+// el de esa fecha o, si no hay, el último anterior.
+func (r *ExchangeRateRepository) FindVigente(ctx context.Context, moneda, base string, fecha time.Time) (*models.ExchangeRate, error) {
+	var rate models.ExchangeRate
+	err := r.collection.FindOne(ctx,
+		bson.M{"moneda": moneda, "base": base, "fecha": bson.M{"$lte": fecha}},
+		options.FindOne().SetSort(bson.D{{Key: "fecha", Value: -1}}),
+	).Decode(&rate)
+	if err != nil {
+		return nil, err
+	}
+	return &rate, nil
+}
+
+// Find devuelve los tipos de cambio que cumplen el filtro, los más recientes
+// primero.
+func (r *ExchangeRateRepository) Find(ctx context.Context, filtro *models.ExchangeRateFilter) ([]*models.ExchangeRate, error) {
+	filter := bson.M{}
+	if filtro.Moneda != "" {
+		filter["moneda"] = filtro.Moneda
+	}
+	if filtro.Base != "" {
+		filter["base"] = filtro.Base
+	}
+	if filtro.Desde != nil || filtro.Hasta != nil {
+		rango := bson.M{}
+		if filtro.Desde != nil {
+			rango["$gte"] = filtro.Desde
+		}
+		if filtro.Hasta != nil {
+			rango["$lte"] = filtro.Hasta
+		}
+		filter["fecha"] = rango
+	}
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "fecha", Value: -1}, {Key: "moneda", Value: 1}, {Key: "base", Value: 1}}).
+		SetLimit(filtro.Limite)
+
+	cursor, err := r.collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	rates := []*models.ExchangeRate{}
+	if err := cursor.All(ctx, &rates); err != nil {
+		return nil, err
+	}
+	return rates, nil
+}
@@ -0,0 +1,72 @@
+package repositories
+
+import (
+	"context"
+	"control-financiero/internal/models"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type ImportacionRepository struct {
+	collection *mongo.Collection
+}
+
+func NewImportacionRepository(db *mongo.Database) *ImportacionRepository {
+	return &ImportacionRepository{
+		collection: db.Collection("importaciones"),
+	}
+}
+
+// Create guarda la importación con el ID que ya tenga, el que llevan sus
+// transacciones, o con uno nuevo.
+func (r *ImportacionRepository) Create(ctx context.Context, importacion *models.Importacion) error {
+	if importacion.ID.IsZero() {
+		importacion.ID = primitive.NewObjectID()
+	}
+	importacion.CreatedAt = time.Now()
+
+	_, err := r.collection.InsertOne(ctx, importacion)
+	return err
+}
+
+// FindByID solo encuentra la importación si pertenece a usuarioID.
+func (r *ImportacionRepository) FindByID(ctx context.Context, id, usuarioID primitive.ObjectID) (*models.Importacion, error) {
+	var importacion models.Importacion
+	err := r.collection.FindOne(ctx, bson.M{"_id": id, "usuarioId": usuarioID}).Decode(&importacion)
+	if err != nil {
+		return nil, err
+	}
+	return &importacion, nil
+}
+
+// FindByUsuario devuelve las importaciones del usuario, las más recientes
+// primero.
+func (r *ImportacionRepository) FindByUsuario(ctx context.Context, usuarioID primitive.ObjectID) ([]*models.Importacion, error) {
+	cursor, err := r.collection.Find(ctx, bson.M{"usuarioId": usuarioID}, options.Find().SetSort(bson.D{{Key: "createdAt", Value: -1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	importaciones := []*models.Importacion{}
+	if err := cursor.All(ctx, &importaciones); err != nil {
+		return nil, err
+	}
+	return importaciones, nil
+}
+
+// Delete solo elimina la importación si pertenece a usuarioID.
+func (r *ImportacionRepository) Delete(ctx context.Context, id, usuarioID primitive.ObjectID) error {
+	result, err := r.collection.DeleteOne(ctx, bson.M{"_id": id, "usuarioId": usuarioID})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
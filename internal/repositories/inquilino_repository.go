@@ -0,0 +1,85 @@
+package repositories
+
+import (
+	"context"
+	"control-financiero/internal/models"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type InquilinoRepository struct {
+	collection *mongo.Collection
+}
+
+func NewInquilinoRepository(db *mongo.Database) *InquilinoRepository {
+	return &InquilinoRepository{
+		collection: db.Collection("inquilinos"),
+	}
+}
+
+func (r *InquilinoRepository) Create(ctx context.Context, inquilino *models.Inquilino) error {
+	inquilino.ID = primitive.NewObjectID()
+	inquilino.CreatedAt = time.Now()
+	inquilino.UpdatedAt = time.Now()
+
+	_, err := r.collection.InsertOne(ctx, inquilino)
+	return err
+}
+
+// FindByID solo encuentra el inquilino si pertenece a usuarioID.
+func (r *InquilinoRepository) FindByID(ctx context.Context, id, usuarioID primitive.ObjectID) (*models.Inquilino, error) {
+	var inquilino models.Inquilino
+	err := r.collection.FindOne(ctx, bson.M{"_id": id, "usuarioId": usuarioID}).Decode(&inquilino)
+	if err != nil {
+		return nil, err
+	}
+	return &inquilino, nil
+}
+
+// FindByUsuario devuelve los inquilinos del usuario ordenados por nombre.
+func (r *InquilinoRepository) FindByUsuario(ctx context.Context, usuarioID primitive.ObjectID) ([]*models.Inquilino, error) {
+	cursor, err := r.collection.Find(ctx, bson.M{"usuarioId": usuarioID}, options.Find().SetSort(bson.D{{Key: "nombre", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	inquilinos := []*models.Inquilino{}
+	if err := cursor.All(ctx, &inquilinos); err != nil {
+		return nil, err
+	}
+	return inquilinos, nil
+}
+
+// Update reemplaza el inquilino si pertenece a inquilino.UsuarioID.
+func (r *InquilinoRepository) Update(ctx context.Context, inquilino *models.Inquilino) error {
+	inquilino.UpdatedAt = time.Now()
+	result, err := r.collection.ReplaceOne(
+		ctx,
+		bson.M{"_id": inquilino.ID, "usuarioId": inquilino.UsuarioID},
+		inquilino,
+	)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+// Delete solo elimina el inquilino si pertenece a usuarioID.
+func (r *InquilinoRepository) Delete(ctx context.Context, id, usuarioID primitive.ObjectID) error {
+	result, err := r.collection.DeleteOne(ctx, bson.M{"_id": id, "usuarioId": usuarioID})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
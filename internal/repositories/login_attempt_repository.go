@@ -0,0 +1,74 @@
+package repositories
+
+import (
+	"context"
+	"control-financiero/internal/models"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type LoginAttemptRepository struct {
+	collection *mongo.Collection
+}
+
+func NewLoginAttemptRepository(db *mongo.Database) *LoginAttemptRepository {
+	return &LoginAttemptRepository{
+		collection: db.Collection("login_attempts"),
+	}
+}
+
+// FindLocked devuelve las claves que siguen bloqueadas.
+func (r *LoginAttemptRepository) FindLocked(ctx context.Context, keys []string) ([]*models.LoginAttempt, error) {
+	cursor, err := r.collection.Find(ctx, bson.M{
+		"_id":         bson.M{"$in": keys},
+		"lockedUntil": bson.M{"$gt": time.Now()},
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var attempts []*models.LoginAttempt
+	if err := cursor.All(ctx, &attempts); err != nil {
+		return nil, err
+	}
+	return attempts, nil
+}
+
+// RegisterFailure suma un fallo a la clave y devuelve el contador actualizado.
+// El documento expira solo tras window sin nuevos fallos.
+func (r *LoginAttemptRepository) RegisterFailure(ctx context.Context, key string, window time.Duration) (*models.LoginAttempt, error) {
+	now := time.Now()
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+
+	var attempt models.LoginAttempt
+	err := r.collection.FindOneAndUpdate(ctx,
+		bson.M{"_id": key},
+		bson.M{
+			"$inc": bson.M{"failures": 1},
+			"$set": bson.M{"lastFailure": now, "expiresAt": now.Add(window)},
+		},
+		opts,
+	).Decode(&attempt)
+	if err != nil {
+		return nil, err
+	}
+	return &attempt, nil
+}
+
+// Lock bloquea la clave hasta until; nunca acorta un bloqueo vigente.
+func (r *LoginAttemptRepository) Lock(ctx context.Context, key string, until time.Time) error {
+	_, err := r.collection.UpdateOne(ctx,
+		bson.M{"_id": key},
+		bson.M{"$max": bson.M{"lockedUntil": until, "expiresAt": until}},
+	)
+	return err
+}
+
+func (r *LoginAttemptRepository) Reset(ctx context.Context, key string) error {
+	_, err := r.collection.DeleteOne(ctx, bson.M{"_id": key})
+	return err
+}
@@ -0,0 +1,56 @@
+package repositories
+
+import (
+	"context"
+	"control-financiero/internal/models"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type LoginChallengeRepository struct {
+	collection *mongo.Collection
+}
+
+func NewLoginChallengeRepository(db *mongo.Database) *LoginChallengeRepository {
+	return &LoginChallengeRepository{
+		collection: db.Collection("login_challenges"),
+	}
+}
+
+func (r *LoginChallengeRepository) Create(ctx context.Context, challenge *models.LoginChallenge) error {
+	challenge.ID = primitive.NewObjectID()
+	challenge.CreatedAt = time.Now()
+
+	_, err := r.collection.InsertOne(ctx, challenge)
+	return err
+}
+
+// RegisterAttempt suma un intento al challenge vigente y lo devuelve ya
+// actualizado. Un challenge con demasiados intentos deja de encontrarse.
+func (r *LoginChallengeRepository) RegisterAttempt(ctx context.Context, tokenHash string, maxAttempts int) (*models.LoginChallenge, error) {
+	filter := bson.M{
+		"tokenHash": tokenHash,
+		"expiresAt": bson.M{"$gt": time.Now()},
+		"attempts":  bson.M{"$lt": maxAttempts},
+	}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	var challenge models.LoginChallenge
+	err := r.collection.FindOneAndUpdate(ctx, filter, bson.M{"$inc": bson.M{"attempts": 1}}, opts).Decode(&challenge)
+	if err != nil {
+		return nil, err
+	}
+	return &challenge, nil
+}
+
+func (r *LoginChallengeRepository) Delete(ctx context.Context, id primitive.ObjectID) (bool, error) {
+	result, err := r.collection.DeleteOne(ctx, bson.M{"_id": id})
+	if err != nil {
+		return false, err
+	}
+	return result.DeletedCount > 0, nil
+}
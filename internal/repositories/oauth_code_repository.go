@@ -0,0 +1,45 @@
+package repositories
+
+import (
+	"context"
+	"control-financiero/internal/models"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type OAuthCodeRepository struct {
+	collection *mongo.Collection
+}
+
+func NewOAuthCodeRepository(db *mongo.Database) *OAuthCodeRepository {
+	return &OAuthCodeRepository{
+		collection: db.Collection("oauth_codes"),
+	}
+}
+
+func (r *OAuthCodeRepository) Create(ctx context.Context, code *models.OAuthCode) error {
+	code.ID = primitive.NewObjectID()
+	code.CreatedAt = time.Now()
+
+	_, err := r.collection.InsertOne(ctx, code)
+	return err
+}
+
+// Consume obtiene y elimina el código en una sola operación, de modo que
+// no pueda canjearse dos veces.
+func (r *OAuthCodeRepository) Consume(ctx context.Context, codeHash string) (*models.OAuthCode, error) {
+	filter := bson.M{
+		"codeHash":  codeHash,
+		"expiresAt": bson.M{"$gt": time.Now()},
+	}
+
+	var code models.OAuthCode
+	err := r.collection.FindOneAndDelete(ctx, filter).Decode(&code)
+	if err != nil {
+		return nil, err
+	}
+	return &code, nil
+}
@@ -0,0 +1,85 @@
+package repositories
+
+import (
+	"context"
+	"control-financiero/internal/models"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type PerfilImportacionRepository struct {
+	collection *mongo.Collection
+}
+
+func NewPerfilImportacionRepository(db *mongo.Database) *PerfilImportacionRepository {
+	return &PerfilImportacionRepository{
+		collection: db.Collection("perfiles_importacion"),
+	}
+}
+
+func (r *PerfilImportacionRepository) Create(ctx context.Context, perfil *models.PerfilImportacion) error {
+	perfil.ID = primitive.NewObjectID()
+	perfil.CreatedAt = time.Now()
+	perfil.UpdatedAt = time.Now()
+
+	_, err := r.collection.InsertOne(ctx, perfil)
+	return err
+}
+
+// FindByID solo encuentra el perfil si pertenece a usuarioID.
+func (r *PerfilImportacionRepository) FindByID(ctx context.Context, id, usuarioID primitive.ObjectID) (*models.PerfilImportacion, error) {
+	var perfil models.PerfilImportacion
+	err := r.collection.FindOne(ctx, bson.M{"_id": id, "usuarioId": usuarioID}).Decode(&perfil)
+	if err != nil {
+		return nil, err
+	}
+	return &perfil, nil
+}
+
+// FindByUsuario devuelve los perfiles del usuario ordenados por nombre.
+func (r *PerfilImportacionRepository) FindByUsuario(ctx context.Context, usuarioID primitive.ObjectID) ([]*models.PerfilImportacion, error) {
+	cursor, err := r.collection.Find(ctx, bson.M{"usuarioId": usuarioID}, options.Find().SetSort(bson.D{{Key: "nombre", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	perfiles := []*models.PerfilImportacion{}
+	if err := cursor.All(ctx, &perfiles); err != nil {
+		return nil, err
+	}
+	return perfiles, nil
+}
+
+// Update reemplaza el perfil si pertenece a perfil.UsuarioID.
+func (r *PerfilImportacionRepository) Update(ctx context.Context, perfil *models.PerfilImportacion) error {
+	perfil.UpdatedAt = time.Now()
+	result, err := r.collection.ReplaceOne(
+		ctx,
+		bson.M{"_id": perfil.ID, "usuarioId": perfil.UsuarioID},
+		perfil,
+	)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+// Delete solo elimina el perfil si pertenece a usuarioID.
+func (r *PerfilImportacionRepository) Delete(ctx context.Context, id, usuarioID primitive.ObjectID) error {
+	result, err := r.collection.DeleteOne(ctx, bson.M{"_id": id, "usuarioId": usuarioID})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package repositories
+
+import (
+	"context"
+	"control-financiero/internal/models"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type PersonalAccessTokenRepository struct {
+	collection *mongo.Collection
+}
+
+func NewPersonalAccessTokenRepository(db *mongo.Database) *PersonalAccessTokenRepository {
+	return &PersonalAccessTokenRepository{
+		collection: db.Collection("personal_access_tokens"),
+	}
+}
+
+func (r *PersonalAccessTokenRepository) Create(ctx context.Context, token *models.PersonalAccessToken) error {
+	token.ID = primitive.NewObjectID()
+	token.CreatedAt = time.Now()
+
+	_, err := r.collection.InsertOne(ctx, token)
+	return err
+}
+
+// FindActiveByHash devuelve el token si no está revocado ni expirado.
+func (r *PersonalAccessTokenRepository) FindActiveByHash(ctx context.Context, tokenHash string) (*models.PersonalAccessToken, error) {
+	filter := bson.M{
+		"tokenHash": tokenHash,
+		"revoked":   false,
+		"$or": []bson.M{
+			{"expiresAt": nil},
+			{"expiresAt": bson.M{"$gt": time.Now()}},
+		},
+	}
+
+	var token models.PersonalAccessToken
+	err := r.collection.FindOne(ctx, filter).Decode(&token)
+	if err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// FindByUsuario lista los tokens no revocados del usuario, los más recientes
+// primero.
+func (r *PersonalAccessTokenRepository) FindByUsuario(ctx context.Context, usuarioID primitive.ObjectID) ([]*models.PersonalAccessToken, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "createdAt", Value: -1}})
+	cursor, err := r.collection.Find(ctx, bson.M{"usuarioId": usuarioID, "revoked": false}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	tokens := []*models.PersonalAccessToken{}
+	if err := cursor.All(ctx, &tokens); err != nil {
+		return nil, err
+	}
+	return tokens, nil
+}
+
+// Revoke revoca el token solo si pertenece al usuario. Devuelve false si no
+// existe, es de otro usuario o ya estaba revocado.
+func (r *PersonalAccessTokenRepository) Revoke(ctx context.Context, id, usuarioID primitive.ObjectID) (bool, error) {
+	result, err := r.collection.UpdateOne(
+		ctx,
+		bson.M{"_id": id, "usuarioId": usuarioID, "revoked": false},
+		bson.M{"$set": bson.M{"revoked": true, "revokedAt": time.Now()}},
+	)
+	if err != nil {
+		return false, err
+	}
+	return result.ModifiedCount == 1, nil
+}
+
+// TouchLastUsed registra el último uso. Para no escribir en cada petición solo
+// actualiza si el registro anterior es más antiguo que minInterval.
+func (r *PersonalAccessTokenRepository) TouchLastUsed(ctx context.Context, id primitive.ObjectID, ip string, minInterval time.Duration) error {
+	now := time.Now()
+	filter := bson.M{
+		"_id": id,
+		"$or": []bson.M{
+			{"lastUsedAt": nil},
+			{"lastUsedAt": bson.M{"$lt": now.Add(-minInterval)}},
+		},
+	}
+
+	_, err := r.collection.UpdateOne(ctx, filter, bson.M{"$set": bson.M{"lastUsedAt": now, "lastUsedIp": ip}})
+	return err
+}
@@ -0,0 +1,85 @@
+package repositories
+
+import (
+	"context"
+	"control-financiero/internal/models"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type PrestamoRepository struct {
+	collection *mongo.Collection
+}
+
+func NewPrestamoRepository(db *mongo.Database) *PrestamoRepository {
+	return &PrestamoRepository{
+		collection: db.Collection("prestamos"),
+	}
+}
+
+func (r *PrestamoRepository) Create(ctx context.Context, prestamo *models.Prestamo) error {
+	prestamo.ID = primitive.NewObjectID()
+	prestamo.CreatedAt = time.Now()
+	prestamo.UpdatedAt = time.Now()
+
+	_, err := r.collection.InsertOne(ctx, prestamo)
+	return err
+}
+
+// FindByID solo encuentra el préstamo si pertenece a usuarioID.
+func (r *PrestamoRepository) FindByID(ctx context.Context, id, usuarioID primitive.ObjectID) (*models.Prestamo, error) {
+	var prestamo models.Prestamo
+	err := r.collection.FindOne(ctx, bson.M{"_id": id, "usuarioId": usuarioID}).Decode(&prestamo)
+	if err != nil {
+		return nil, err
+	}
+	return &prestamo, nil
+}
+
+// FindByUsuario devuelve los préstamos del usuario ordenados por nombre.
+func (r *PrestamoRepository) FindByUsuario(ctx context.Context, usuarioID primitive.ObjectID) ([]*models.Prestamo, error) {
+	cursor, err := r.collection.Find(ctx, bson.M{"usuarioId": usuarioID}, options.Find().SetSort(bson.D{{Key: "nombre", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	prestamos := []*models.Prestamo{}
+	if err := cursor.All(ctx, &prestamos); err != nil {
+		return nil, err
+	}
+	return prestamos, nil
+}
+
+// Update reemplaza el préstamo si pertenece a prestamo.UsuarioID.
+func (r *PrestamoRepository) Update(ctx context.Context, prestamo *models.Prestamo) error {
+	prestamo.UpdatedAt = time.Now()
+	result, err := r.collection.ReplaceOne(
+		ctx,
+		bson.M{"_id": prestamo.ID, "usuarioId": prestamo.UsuarioID},
+		prestamo,
+	)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+// Delete solo elimina el préstamo si pertenece a usuarioID.
+func (r *PrestamoRepository) Delete(ctx context.Context, id, usuarioID primitive.ObjectID) error {
+	result, err := r.collection.DeleteOne(ctx, bson.M{"_id": id, "usuarioId": usuarioID})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
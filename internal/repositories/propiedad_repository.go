@@ -0,0 +1,85 @@
+package repositories
+
+import (
+	"context"
+	"control-financiero/internal/models"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type PropiedadRepository struct {
+	collection *mongo.Collection
+}
+
+func NewPropiedadRepository(db *mongo.Database) *PropiedadRepository {
+	return &PropiedadRepository{
+		collection: db.Collection("propiedades"),
+	}
+}
+
+func (r *PropiedadRepository) Create(ctx context.Context, propiedad *models.Propiedad) error {
+	propiedad.ID = primitive.NewObjectID()
+	propiedad.CreatedAt = time.Now()
+	propiedad.UpdatedAt = time.Now()
+
+	_, err := r.collection.InsertOne(ctx, propiedad)
+	return err
+}
+
+// FindByID solo encuentra la propiedad si pertenece a usuarioID.
+func (r *PropiedadRepository) FindByID(ctx context.Context, id, usuarioID primitive.ObjectID) (*models.Propiedad, error) {
+	var propiedad models.Propiedad
+	err := r.collection.FindOne(ctx, bson.M{"_id": id, "usuarioId": usuarioID}).Decode(&propiedad)
+	if err != nil {
+		return nil, err
+	}
+	return &propiedad, nil
+}
+
+// FindByUsuario devuelve las propiedades del usuario ordenadas por nombre.
+func (r *PropiedadRepository) FindByUsuario(ctx context.Context, usuarioID primitive.ObjectID) ([]*models.Propiedad, error) {
+	cursor, err := r.collection.Find(ctx, bson.M{"usuarioId": usuarioID}, options.Find().SetSort(bson.D{{Key: "nombre", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	propiedades := []*models.Propiedad{}
+	if err := cursor.All(ctx, &propiedades); err != nil {
+		return nil, err
+	}
+	return propiedades, nil
+}
+
+// Update reemplaza la propiedad si pertenece a propiedad.UsuarioID.
+func (r *PropiedadRepository) Update(ctx context.Context, propiedad *models.Propiedad) error {
+	propiedad.UpdatedAt = time.Now()
+	result, err := r.collection.ReplaceOne(
+		ctx,
+		bson.M{"_id": propiedad.ID, "usuarioId": propiedad.UsuarioID},
+		propiedad,
+	)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+// Delete solo elimina la propiedad si pertenece a usuarioID.
+func (r *PropiedadRepository) Delete(ctx context.Context, id, usuarioID primitive.ObjectID) error {
+	result, err := r.collection.DeleteOne(ctx, bson.M{"_id": id, "usuarioId": usuarioID})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
@@ -0,0 +1,149 @@
+package repositories
+
+import (
+	"context"
+	"control-financiero/internal/models"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type RecurrenciaRepository struct {
+	collection *mongo.Collection
+}
+
+func NewRecurrenciaRepository(db *mongo.Database) *RecurrenciaRepository {
+	return &RecurrenciaRepository{
+		collection: db.Collection("recurrencias"),
+	}
+}
+
+func (r *RecurrenciaRepository) Create(ctx context.Context, recurrencia *models.Recurrencia) error {
+	recurrencia.ID = primitive.NewObjectID()
+	recurrencia.CreatedAt = time.Now()
+	recurrencia.UpdatedAt = time.Now()
+
+	_, err := r.collection.InsertOne(ctx, recurrencia)
+	return err
+}
+
+// FindByID solo encuentra la recurrencia si pertenece a usuarioID.
+func (r *RecurrenciaRepository) FindByID(ctx context.Context, id, usuarioID primitive.ObjectID) (*models.Recurrencia, error) {
+	var recurrencia models.Recurrencia
+	err := r.collection.FindOne(ctx, bson.M{"_id": id, "usuarioId": usuarioID}).Decode(&recurrencia)
+	if err != nil {
+		return nil, err
+	}
+	return &recurrencia, nil
+}
+
+// FindByUsuario devuelve las recurrencias del usuario ordenadas por nombre.
+func (r *RecurrenciaRepository) FindByUsuario(ctx context.Context, usuarioID primitive.ObjectID) ([]*models.Recurrencia, error) {
+	cursor, err := r.collection.Find(ctx, bson.M{"usuarioId": usuarioID}, options.Find().SetSort(bson.D{{Key: "nombre", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	recurrencias := []*models.Recurrencia{}
+	if err := cursor.All(ctx, &recurrencias); err != nil {
+		return nil, err
+	}
+	return recurrencias, nil
+}
+
+// FindVencidas devuelve las recurrencias de todos los usuarios con alguna
+// ocurrencia pendiente hasta hoy, la más atrasada primero.
+func (r *RecurrenciaRepository) FindVencidas(ctx context.Context, hoy time.Time) ([]*models.Recurrencia, error) {
+	cursor, err := r.collection.Find(ctx, bson.M{"proxima": bson.M{"$lte": hoy}}, options.Find().SetSort(bson.D{{Key: "proxima", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	recurrencias := []*models.Recurrencia{}
+	if err := cursor.All(ctx, &recurrencias); err != nil {
+		return nil, err
+	}
+	return recurrencias, nil
+}
+
+// Update reemplaza la recurrencia si pertenece a recurrencia.UsuarioID.
+func (r *RecurrenciaRepository) Update(ctx context.Context, recurrencia *models.Recurrencia) error {
+	recurrencia.UpdatedAt = time.Now()
+	result, err := r.collection.ReplaceOne(
+		ctx,
+		bson.M{"_id": recurrencia.ID, "usuarioId": recurrencia.UsuarioID},
+		recurrencia,
+	)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+// SetExcepciones reemplaza solo las excepciones, sin tocar el avance que
+// guarda el programador.
+func (r *RecurrenciaRepository) SetExcepciones(ctx context.Context, id, usuarioID primitive.ObjectID, excepciones []models.ExcepcionRecurrencia) error {
+	result, err := r.collection.UpdateOne(
+		ctx,
+		bson.M{"_id": id, "usuarioId": usuarioID},
+		bson.M{"$set": bson.M{"excepciones": excepciones, "updatedAt": time.Now()}},
+	)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+// Avanzar guarda UltimaFecha, Proxima y Error de recurrencia solo si su
+// próxima ocurrencia sigue siendo anterior, es decir, si nadie la procesó ni
+// la editó mientras tanto. Devuelve si la actualizó.
+func (r *RecurrenciaRepository) Avanzar(ctx context.Context, recurrencia *models.Recurrencia, anterior time.Time) (bool, error) {
+	set := bson.M{"updatedAt": time.Now()}
+	unset := bson.M{}
+	if recurrencia.UltimaFecha != nil {
+		set["ultimaFecha"] = recurrencia.UltimaFecha
+	}
+	if recurrencia.Proxima != nil {
+		set["proxima"] = recurrencia.Proxima
+	} else {
+		unset["proxima"] = ""
+	}
+	if recurrencia.Error != "" {
+		set["error"] = recurrencia.Error
+	} else {
+		unset["error"] = ""
+	}
+
+	update := bson.M{"$set": set}
+	if len(unset) > 0 {
+		update["$unset"] = unset
+	}
+	result, err := r.collection.UpdateOne(ctx, bson.M{"_id": recurrencia.ID, "proxima": anterior}, update)
+	if err != nil {
+		return false, err
+	}
+	return result.MatchedCount > 0, nil
+}
+
+// Delete solo elimina la recurrencia si pertenece a usuarioID.
+func (r *RecurrenciaRepository) Delete(ctx context.Context, id, usuarioID primitive.ObjectID) error {
+	result, err := r.collection.DeleteOne(ctx, bson.M{"_id": id, "usuarioId": usuarioID})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
@@ -1,56 +1,123 @@
-package repositories
-
-import (
-	"context"
-	"control-financiero/internal/models"
-	"time"
-
-	"go.mongodb.org/mongo-driver/bson"
-	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo"
-)
-
-type RefreshTokenRepository struct {
-	collection *mongo.Collection
-}
-
-func NewRefreshTokenRepository(db *mongo.Database) *RefreshTokenRepository {
-	return &RefreshTokenRepository{
-		collection: db.Collection("refresh_tokens"),
-	}
-}
-
-func (r *RefreshTokenRepository) Create(ctx context.Context, refreshToken *models.RefreshToken) error {
-	refreshToken.ID = primitive.NewObjectID()
-	refreshToken.CreatedAt = time.Now()
-	
-	_, err := r.collection.InsertOne(ctx, refreshToken)
-	return err
-}
-
-func (r *RefreshTokenRepository) FindByToken(ctx context.Context, token string) (*models.RefreshToken, error) {
-	var refreshToken models.RefreshToken
-	err := r.collection.FindOne(ctx, bson.M{"token": token, "revoked": false}).Decode(&refreshToken)
-	if err != nil {
-		return nil, err
-	}
-	return &refreshToken, nil
-}
-
-func (r *RefreshTokenRepository) Revoke(ctx context.Context, token string) error {
-	_, err := r.collection.UpdateOne(
-		ctx,
-		bson.M{"token": token},
-		bson.M{"$set": bson.M{"revoked": true}},
-	)
-	return err
-}
-
-func (r *RefreshTokenRepository) RevokeAllByUsuario(ctx context.Context, usuarioID primitive.ObjectID) error {
-	_, err := r.collection.UpdateMany(
-		ctx,
-		bson.M{"usuarioId": usuarioID},
-		bson.M{"$set": bson.M{"revoked": true}},
-	)
-	return err
-}
+package repositories
+
+import (
+	"context"
+	"control-financiero/internal/models"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type RefreshTokenRepository struct {
+	collection *mongo.Collection
+}
+
+func NewRefreshTokenRepository(db *mongo.Database) *RefreshTokenRepository {
+	return &RefreshTokenRepository{
+		collection: db.Collection("refresh_tokens"),
+	}
+}
+
+func (r *RefreshTokenRepository) Create(ctx context.Context, refreshToken *models.RefreshToken) error {
+	refreshToken.ID = primitive.NewObjectID()
+	refreshToken.CreatedAt = time.Now()
+	if refreshToken.FamilyID.IsZero() {
+		refreshToken.FamilyID = refreshToken.ID
+	}
+
+	_, err := r.collection.InsertOne(ctx, refreshToken)
+	return err
+}
+
+// FindByHash devuelve el token aunque esté revocado, para poder detectar la
+// reutilización de un token ya rotado.
+func (r *RefreshTokenRepository) FindByHash(ctx context.Context, tokenHash string) (*models.RefreshToken, error) {
+	var refreshToken models.RefreshToken
+	err := r.collection.FindOne(ctx, bson.M{"tokenHash": tokenHash}).Decode(&refreshToken)
+	if err != nil {
+		return nil, err
+	}
+	return &refreshToken, nil
+}
+
+// MarkRotated revoca el token indicando cuál lo reemplaza. Devuelve false si
+// el token ya estaba revocado, es decir, si otra petición lo usó primero.
+func (r *RefreshTokenRepository) MarkRotated(ctx context.Context, id, replacedBy primitive.ObjectID) (bool, error) {
+	result, err := r.collection.UpdateOne(
+		ctx,
+		bson.M{"_id": id, "revoked": false},
+		bson.M{"$set": bson.M{"revoked": true, "revokedAt": time.Now(), "replacedBy": replacedBy}},
+	)
+	if err != nil {
+		return false, err
+	}
+	return result.ModifiedCount == 1, nil
+}
+
+// FindActiveByUsuario devuelve el token vigente de cada sesión abierta del
+// usuario. Tras cada rotación solo el último token de la familia sigue sin
+// revocar, así que hay un documento por sesión.
+func (r *RefreshTokenRepository) FindActiveByUsuario(ctx context.Context, usuarioID primitive.ObjectID) ([]*models.RefreshToken, error) {
+	filter := bson.M{
+		"usuarioId": usuarioID,
+		"revoked":   false,
+		"expiresAt": bson.M{"$gt": time.Now()},
+	}
+
+	opts := options.Find().SetSort(bson.D{{Key: "lastUsedAt", Value: -1}})
+	cursor, err := r.collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var tokens []*models.RefreshToken
+	if err := cursor.All(ctx, &tokens); err != nil {
+		return nil, err
+	}
+	return tokens, nil
+}
+
+func (r *RefreshTokenRepository) Revoke(ctx context.Context, tokenHash string) error {
+	_, err := r.collection.UpdateOne(
+		ctx,
+		bson.M{"tokenHash": tokenHash, "revoked": false},
+		bson.M{"$set": bson.M{"revoked": true, "revokedAt": time.Now()}},
+	)
+	return err
+}
+
+func (r *RefreshTokenRepository) RevokeFamily(ctx context.Context, familyID primitive.ObjectID) error {
+	_, err := r.collection.UpdateMany(
+		ctx,
+		bson.M{"familyId": familyID, "revoked": false},
+		bson.M{"$set": bson.M{"revoked": true, "revokedAt": time.Now()}},
+	)
+	return err
+}
+
+// RevokeFamilyByUsuario cierra una sesión solo si pertenece al usuario.
+// Devuelve false si no había ninguna sesión activa con ese ID.
+func (r *RefreshTokenRepository) RevokeFamilyByUsuario(ctx context.Context, familyID, usuarioID primitive.ObjectID) (bool, error) {
+	result, err := r.collection.UpdateMany(
+		ctx,
+		bson.M{"familyId": familyID, "usuarioId": usuarioID, "revoked": false},
+		bson.M{"$set": bson.M{"revoked": true, "revokedAt": time.Now()}},
+	)
+	if err != nil {
+		return false, err
+	}
+	return result.ModifiedCount > 0, nil
+}
+
+func (r *RefreshTokenRepository) RevokeAllByUsuario(ctx context.Context, usuarioID primitive.ObjectID) error {
+	_, err := r.collection.UpdateMany(
+		ctx,
+		bson.M{"usuarioId": usuarioID, "revoked": false},
+		bson.M{"$set": bson.M{"revoked": true, "revokedAt": time.Now()}},
+	)
+	return err
+}
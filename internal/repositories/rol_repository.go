@@ -0,0 +1,91 @@
+package repositories
+
+import (
+	"context"
+	"control-financiero/internal/models"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type RolRepository struct {
+	collection *mongo.Collection
+}
+
+func NewRolRepository(db *mongo.Database) *RolRepository {
+	return &RolRepository{
+		collection: db.Collection("roles"),
+	}
+}
+
+func (r *RolRepository) Create(ctx context.Context, rol *models.Rol) error {
+	rol.ID = primitive.NewObjectID()
+	rol.CreatedAt = time.Now()
+	rol.UpdatedAt = time.Now()
+
+	_, err := r.collection.InsertOne(ctx, rol)
+	return err
+}
+
+func (r *RolRepository) FindByID(ctx context.Context, id primitive.ObjectID) (*models.Rol, error) {
+	var rol models.Rol
+	err := r.collection.FindOne(ctx, bson.M{"_id": id}).Decode(&rol)
+	if err != nil {
+		return nil, err
+	}
+	return &rol, nil
+}
+
+func (r *RolRepository) FindByNombre(ctx context.Context, nombre string) (*models.Rol, error) {
+	var rol models.Rol
+	err := r.collection.FindOne(ctx, bson.M{"nombre": nombre}).Decode(&rol)
+	if err != nil {
+		return nil, err
+	}
+	return &rol, nil
+}
+
+func (r *RolRepository) FindAll(ctx context.Context) ([]*models.Rol, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "nombre", Value: 1}})
+	cursor, err := r.collection.Find(ctx, bson.M{}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var roles []*models.Rol
+	if err := cursor.All(ctx, &roles); err != nil {
+		return nil, err
+	}
+	return roles, nil
+}
+
+// Update modifica la descripción y los permisos; el nombre es la clave con
+// la que los usuarios referencian el rol y no cambia.
+func (r *RolRepository) Update(ctx context.Context, rol *models.Rol) error {
+	rol.UpdatedAt = time.Now()
+	result, err := r.collection.UpdateOne(
+		ctx,
+		bson.M{"_id": rol.ID},
+		bson.M{"$set": bson.M{
+			"descripcion": rol.Descripcion,
+			"permisos":    rol.Permisos,
+			"updatedAt":   rol.UpdatedAt,
+		}},
+	)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+func (r *RolRepository) Delete(ctx context.Context, id primitive.ObjectID) error {
+	_, err := r.collection.DeleteOne(ctx, bson.M{"_id": id})
+	return err
+}
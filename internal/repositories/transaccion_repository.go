@@ -1,93 +1,531 @@
-package repositories
-
-import (
-	"context"
-	"control-financiero/internal/models"
-	"time"
-
-	"go.mongodb.org/mongo-driver/bson"
-	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo"
-	"go.mongodb.org/mongo-driver/mongo/options"
-)
-
-type TransaccionRepository struct {
-	collection *mongo.Collection
-}
-
-func NewTransaccionRepository(db *mongo.Database) *TransaccionRepository {
-	return &TransaccionRepository{
-		collection: db.Collection("transacciones"),
-	}
-}
-
-func (r *TransaccionRepository) Create(ctx context.Context, transaccion *models.Transaccion) error {
-	transaccion.ID = primitive.NewObjectID()
-	transaccion.CreatedAt = time.Now()
-	transaccion.UpdatedAt = time.Now()
-	
-	_, err := r.collection.InsertOne(ctx, transaccion)
-	return err
-}
-
-func (r *TransaccionRepository) FindByID(ctx context.Context, id primitive.ObjectID) (*models.Transaccion, error) {
-	var transaccion models.Transaccion
-	err := r.collection.FindOne(ctx, bson.M{"_id": id}).Decode(&transaccion)
-	if err != nil {
-		return nil, err
-	}
-	return &transaccion, nil
-}
-
-func (r *TransaccionRepository) FindByUsuario(ctx context.Context, usuarioID primitive.ObjectID) ([]*models.Transaccion, error) {
-	opts := options.Find().SetSort(bson.D{{Key: "fecha", Value: -1}})
-	cursor, err := r.collection.Find(ctx, bson.M{"usuarioId": usuarioID}, opts)
-	if err != nil {
-		return nil, err
-	}
-	defer cursor.Close(ctx)
-
-	var transacciones []*models.Transaccion
-	if err := cursor.All(ctx, &transacciones); err != nil {
-		return nil, err
-	}
-	return transacciones, nil
-}
-
-func (r *TransaccionRepository) FindByUsuarioAndRange(ctx context.Context, usuarioID primitive.ObjectID, start, end time.Time) ([]*models.Transaccion, error) {
-	filter := bson.M{
-		"usuarioId": usuarioID,
-		"fecha": bson.M{
-			"$gte": start,
-			"$lte": end,
-		},
-	}
-	
-	opts := options.Find().SetSort(bson.D{{Key: "fecha", Value: -1}})
-	cursor, err := r.collection.Find(ctx, filter, opts)
-	if err != nil {
-		return nil, err
-	}
-	defer cursor.Close(ctx)
-
-	var transacciones []*models.Transaccion
-	if err := cursor.All(ctx, &transacciones); err != nil {
-		return nil, err
-	}
-	return transacciones, nil
-}
-
-func (r *TransaccionRepository) Update(ctx context.Context, transaccion *models.Transaccion) error {
-	transaccion.UpdatedAt = time.Now()
-	_, err := r.collection.UpdateOne(
-		ctx,
-		bson.M{"_id": transaccion.ID},
-		bson.M{"$set": transaccion},
-	)
-	return err
-}
-
-func (r *TransaccionRepository) Delete(ctx context.Context, id primitive.ObjectID) error {
-	_, err := r.collection.DeleteOne(ctx, bson.M{"_id": id})
-	return err
-}
+package repositories
+
+import (
+	"context"
+	"control-financiero/internal/models"
+	"control-financiero/internal/money"
+	"regexp"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type TransaccionRepository struct {
+	collection *mongo.Collection
+}
+
+func NewTransaccionRepository(db *mongo.Database) *TransaccionRepository {
+	return &TransaccionRepository{
+		collection: db.Collection("transacciones"),
+	}
+}
+
+func (r *TransaccionRepository) Create(ctx context.Context, transaccion *models.Transaccion) error {
+	transaccion.ID = primitive.NewObjectID()
+	transaccion.CreatedAt = time.Now()
+	transaccion.UpdatedAt = time.Now()
+	
+	_, err := r.collection.InsertOne(ctx, transaccion)
+	return err
+}
+
+// CreateMany inserta varias transacciones de una vez, como las de una
+// importación.
+func (r *TransaccionRepository) CreateMany(ctx context.Context, transacciones []*models.Transaccion) error {
+	docs := make([]interface{}, len(transacciones))
+	for i, transaccion := range transacciones {
+		transaccion.ID = primitive.NewObjectID()
+		transaccion.CreatedAt = time.Now()
+		transaccion.UpdatedAt = transaccion.CreatedAt
+		docs[i] = transaccion
+	}
+
+	_, err := r.collection.InsertMany(ctx, docs)
+	return err
+}
+
+func (r *TransaccionRepository) FindByID(ctx context.Context, id, usuarioID primitive.ObjectID) (*models.Transaccion, error) {
+	var transaccion models.Transaccion
+	err := r.collection.FindOne(ctx, bson.M{"_id": id, "usuarioId": usuarioID}).Decode(&transaccion)
+	if err != nil {
+		return nil, err
+	}
+	return &transaccion, nil
+}
+
+// Find devuelve hasta filtro.Limite transacciones que cumplen el filtro,
+// ordenadas por filtro.Orden y por _id para desempatar. Con filtro.Despues
+// continúa a partir de esa posición.
+func (r *TransaccionRepository) Find(ctx context.Context, filtro *models.TransaccionFilter) ([]*models.Transaccion, error) {
+	filter := transaccionFilter(filtro)
+	campo, dir := transaccionOrden(filtro.Orden)
+
+	if filtro.Despues != nil {
+		var valor interface{} = filtro.Despues.Fecha
+		if campo == "monto" {
+			valor = filtro.Despues.Monto
+		}
+		op := "$lt"
+		if dir > 0 {
+			op = "$gt"
+		}
+		filter["$or"] = []bson.M{
+			{campo: bson.M{op: valor}},
+			{campo: valor, "_id": bson.M{op: filtro.Despues.ID}},
+		}
+	}
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: campo, Value: dir}, {Key: "_id", Value: dir}}).
+		SetLimit(filtro.Limite)
+
+	cursor, err := r.collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	transacciones := []*models.Transaccion{}
+	if err := cursor.All(ctx, &transacciones); err != nil {
+		return nil, err
+	}
+	return transacciones, nil
+}
+
+// Count cuenta las transacciones que cumplen el filtro, sin tener en cuenta
+// la paginación.
+func (r *TransaccionRepository) Count(ctx context.Context, filtro *models.TransaccionFilter) (int64, error) {
+	return r.collection.CountDocuments(ctx, transaccionFilter(filtro))
+}
+
+func transaccionFilter(filtro *models.TransaccionFilter) bson.M {
+	filter := bson.M{"usuarioId": filtro.UsuarioID}
+
+	if filtro.Desde != nil || filtro.Hasta != nil {
+		rango := bson.M{}
+		if filtro.Desde != nil {
+			rango["$gte"] = filtro.Desde
+		}
+		if filtro.Hasta != nil {
+			rango["$lte"] = filtro.Hasta
+		}
+		filter["fecha"] = rango
+	}
+	if filtro.Tipo != "" {
+		filter["tipo"] = filtro.Tipo
+	}
+	// La categoría y la etiqueta pueden estar en la transacción o en una de
+	// sus divisiones
+	var condiciones []bson.M
+	if filtro.CategoriaID != nil {
+		condiciones = append(condiciones, bson.M{"$or": []bson.M{
+			{"categoriaId": filtro.CategoriaID},
+			{"divisiones.categoriaId": filtro.CategoriaID},
+		}})
+	}
+	if filtro.CuentaID != nil {
+		filter["cuentaId"] = filtro.CuentaID
+	}
+	if filtro.Tag != "" {
+		condiciones = append(condiciones, bson.M{"$or": []bson.M{
+			{"tags": filtro.Tag},
+			{"divisiones.tags": filtro.Tag},
+		}})
+	}
+	if len(condiciones) > 0 {
+		filter["$and"] = condiciones
+	}
+	if filtro.MetodoPago != "" {
+		filter["metodoPago"] = filtro.MetodoPago
+	}
+	if filtro.MontoMin != nil || filtro.MontoMax != nil {
+		rango := bson.M{}
+		if filtro.MontoMin != nil {
+			rango["$gte"] = filtro.MontoMin
+		}
+		if filtro.MontoMax != nil {
+			rango["$lte"] = filtro.MontoMax
+		}
+		filter["monto"] = rango
+	}
+	if filtro.Descripcion != "" {
+		filter["descripcion"] = primitive.Regex{Pattern: regexp.QuoteMeta(filtro.Descripcion), Options: "i"}
+	}
+
+	return filter
+}
+
+// transaccionOrden devuelve el campo y la dirección de orden. Por defecto las
+// más recientes primero.
+func transaccionOrden(orden string) (string, int) {
+	switch orden {
+	case models.OrdenFechaAsc:
+		return "fecha", 1
+	case models.OrdenMontoDesc:
+		return "monto", -1
+	case models.OrdenMontoAsc:
+		return "monto", 1
+	}
+	return "fecha", -1
+}
+
+// SumByTipoYCategoria suma en la base de datos los montos del rango por tipo
+// y categoría, separados por moneda y día para convertirlos con el tipo de
+// cambio de cada fecha. Los montos son Decimal128, así que la suma es exacta.
+// Las transacciones con divisiones suman cada división en su categoría, y
+// las transferencias entre cuentas no son ingresos ni egresos y no se suman.
+// Los movimientos de préstamos se separan además por sentido, y los
+// alquileres cobrados de un contrato de los demás.
+func (r *TransaccionRepository) SumByTipoYCategoria(ctx context.Context, usuarioID primitive.ObjectID, start, end time.Time) ([]*models.TotalTransacciones, error) {
+	return r.sumByTipoYCategoria(ctx, bson.M{
+		"usuarioId": usuarioID,
+		"fecha":     bson.M{"$gte": start, "$lte": end},
+		"tipo":      bson.M{"$ne": "transferencia"},
+	})
+}
+
+// SumByPropiedad suma como SumByTipoYCategoria las transacciones asignadas a
+// la propiedad. desde y hasta, si no son nil, limitan el rango de fechas.
+func (r *TransaccionRepository) SumByPropiedad(ctx context.Context, usuarioID, propiedadID primitive.ObjectID, desde, hasta *time.Time) ([]*models.TotalTransacciones, error) {
+	match := bson.M{
+		"usuarioId":   usuarioID,
+		"propiedadId": propiedadID,
+		"tipo":        bson.M{"$ne": "transferencia"},
+	}
+	if desde != nil || hasta != nil {
+		rango := bson.M{}
+		if desde != nil {
+			rango["$gte"] = desde
+		}
+		if hasta != nil {
+			rango["$lte"] = hasta
+		}
+		match["fecha"] = rango
+	}
+	return r.sumByTipoYCategoria(ctx, match)
+}
+
+func (r *TransaccionRepository) sumByTipoYCategoria(ctx context.Context, match bson.M) ([]*models.TotalTransacciones, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: match}},
+		{{Key: "$set", Value: bson.M{"lineas": bson.M{"$cond": bson.A{
+			bson.M{"$gt": bson.A{bson.M{"$size": bson.M{"$ifNull": bson.A{"$divisiones", bson.A{}}}}, 0}},
+			"$divisiones",
+			bson.A{bson.M{"categoriaId": "$categoriaId", "monto": "$monto"}},
+		}}}}},
+		{{Key: "$unwind", Value: "$lineas"}},
+		{{Key: "$group", Value: bson.M{
+			"_id": bson.M{
+				"tipo":        "$tipo",
+				"categoriaId": "$lineas.categoriaId",
+				"moneda":      "$moneda",
+				"dia":         bson.M{"$dateToString": bson.M{"format": "%Y-%m-%d", "date": "$fecha"}},
+				"sentido":     "$prestamo.sentido",
+				"cobro":       bson.M{"$eq": bson.A{bson.M{"$type": "$contrato"}, "object"}},
+			},
+			"total": bson.M{"$sum": "$lineas.monto"},
+		}}},
+		{{Key: "$project", Value: bson.M{
+			"_id":         0,
+			"tipo":        "$_id.tipo",
+			"categoriaId": "$_id.categoriaId",
+			"moneda":      "$_id.moneda",
+			"dia":         "$_id.dia",
+			"sentido":     "$_id.sentido",
+			"cobro":       "$_id.cobro",
+			"total":       1,
+		}}},
+	}
+
+	cursor, err := r.collection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	totales := []*models.TotalTransacciones{}
+	if err := cursor.All(ctx, &totales); err != nil {
+		return nil, err
+	}
+	return totales, nil
+}
+
+// importeConSigno es la expresión de agregación equivalente a
+// Transaccion.Importe.
+var importeConSigno = bson.M{"$switch": bson.M{
+	"branches": bson.A{
+		bson.M{"case": bson.M{"$eq": bson.A{"$tipo", "ingreso"}}, "then": "$monto"},
+		bson.M{"case": bson.M{"$eq": bson.A{"$tipo", "egreso"}}, "then": bson.M{"$multiply": bson.A{"$monto", -1}}},
+		bson.M{"case": bson.M{"$eq": bson.A{"$transferencia.sentido", models.TransferenciaSalida}}, "then": bson.M{"$multiply": bson.A{"$monto", -1}}},
+		bson.M{"case": bson.M{"$eq": bson.A{"$tipo", "transferencia"}}, "then": "$monto"},
+		bson.M{"case": bson.M{"$eq": bson.A{"$prestamo.sentido", models.PrestamoSalida}}, "then": bson.M{"$multiply": bson.A{"$monto", -1}}},
+		bson.M{"case": bson.M{"$eq": bson.A{"$prestamo.sentido", models.PrestamoEntrada}}, "then": "$monto"},
+		bson.M{"case": bson.M{"$eq": bson.A{bson.M{"$type": "$contrato"}, "object"}}, "then": "$monto"},
+	},
+	"default": 0,
+}}
+
+// SaldosPorCuenta suma los importes de las transacciones de cada cuenta. Las
+// cuentas sin transacciones no aparecen en el resultado.
+func (r *TransaccionRepository) SaldosPorCuenta(ctx context.Context, usuarioID primitive.ObjectID, cuentaIDs []primitive.ObjectID) (map[primitive.ObjectID]money.Amount, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"usuarioId": usuarioID, "cuentaId": bson.M{"$in": cuentaIDs}}}},
+		{{Key: "$group", Value: bson.M{"_id": "$cuentaId", "saldo": bson.M{"$sum": importeConSigno}}}},
+	}
+
+	cursor, err := r.collection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var resultados []struct {
+		CuentaID primitive.ObjectID `bson:"_id"`
+		Saldo    money.Amount       `bson:"saldo"`
+	}
+	if err := cursor.All(ctx, &resultados); err != nil {
+		return nil, err
+	}
+
+	saldos := make(map[primitive.ObjectID]money.Amount, len(resultados))
+	for _, r := range resultados {
+		saldos[r.CuentaID] = r.Saldo
+	}
+	return saldos, nil
+}
+
+// SumImportesAntes suma los importes de las transacciones de la cuenta
+// anteriores a fecha. Con hastaID incluye también las de esa misma fecha con
+// _id menor o igual, que es la posición de un cursor del extracto.
+func (r *TransaccionRepository) SumImportesAntes(ctx context.Context, usuarioID, cuentaID primitive.ObjectID, fecha time.Time, hastaID *primitive.ObjectID) (money.Amount, error) {
+	match := bson.M{"usuarioId": usuarioID, "cuentaId": cuentaID, "fecha": bson.M{"$lt": fecha}}
+	if hastaID != nil {
+		delete(match, "fecha")
+		match["$or"] = []bson.M{
+			{"fecha": bson.M{"$lt": fecha}},
+			{"fecha": fecha, "_id": bson.M{"$lte": hastaID}},
+		}
+	}
+
+	cursor, err := r.collection.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: match}},
+		{{Key: "$group", Value: bson.M{"_id": nil, "total": bson.M{"$sum": importeConSigno}}}},
+	})
+	if err != nil {
+		return 0, err
+	}
+	defer cursor.Close(ctx)
+
+	var resultado struct {
+		Total money.Amount `bson:"total"`
+	}
+	if cursor.Next(ctx) {
+		if err := cursor.Decode(&resultado); err != nil {
+			return 0, err
+		}
+	}
+	return resultado.Total, cursor.Err()
+}
+
+// SumImportesPorPeriodo suma los importes de la cuenta por periodo, con
+// formato "%Y-%m-%d" o "%Y-%m", en orden cronológico.
+func (r *TransaccionRepository) SumImportesPorPeriodo(ctx context.Context, usuarioID, cuentaID primitive.ObjectID, desde, hasta *time.Time, formato string) ([]*models.SaldoPeriodo, error) {
+	filtro := &models.TransaccionFilter{UsuarioID: usuarioID, CuentaID: &cuentaID, Desde: desde, Hasta: hasta}
+
+	cursor, err := r.collection.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: transaccionFilter(filtro)}},
+		{{Key: "$group", Value: bson.M{
+			"_id":  bson.M{"$dateToString": bson.M{"format": formato, "date": "$fecha"}},
+			"neto": bson.M{"$sum": importeConSigno},
+		}}},
+		{{Key: "$sort", Value: bson.D{{Key: "_id", Value: 1}}}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	periodos := []*models.SaldoPeriodo{}
+	if err := cursor.All(ctx, &periodos); err != nil {
+		return nil, err
+	}
+	return periodos, nil
+}
+
+// FindContraparte busca la otra pata de la transferencia a la que pertenece
+// transaccion.
+func (r *TransaccionRepository) FindContraparte(ctx context.Context, transaccion *models.Transaccion) (*models.Transaccion, error) {
+	var contraparte models.Transaccion
+	err := r.collection.FindOne(ctx, bson.M{
+		"usuarioId":        transaccion.UsuarioID,
+		"transferencia.id": transaccion.Transferencia.ID,
+		"_id":              bson.M{"$ne": transaccion.ID},
+	}).Decode(&contraparte)
+	if err != nil {
+		return nil, err
+	}
+	return &contraparte, nil
+}
+
+// CountByCuenta cuenta las transacciones que referencian la cuenta.
+func (r *TransaccionRepository) CountByCuenta(ctx context.Context, usuarioID, cuentaID primitive.ObjectID) (int64, error) {
+	return r.collection.CountDocuments(ctx, bson.M{"usuarioId": usuarioID, "cuentaId": cuentaID})
+}
+
+// CountByPrestamo cuenta las transacciones enlazadas con el préstamo.
+func (r *TransaccionRepository) CountByPrestamo(ctx context.Context, usuarioID, prestamoID primitive.ObjectID) (int64, error) {
+	return r.collection.CountDocuments(ctx, bson.M{"usuarioId": usuarioID, "prestamo.id": prestamoID})
+}
+
+// SumPagosPrestamos suma los montos de las transacciones enlazadas con cada
+// cuota de los préstamos, y en la cuota 0 los desembolsos. excluir, si no es
+// nil, es una transacción que no se cuenta, la que se está editando.
+func (r *TransaccionRepository) SumPagosPrestamos(ctx context.Context, usuarioID primitive.ObjectID, prestamoIDs []primitive.ObjectID, excluir *primitive.ObjectID) ([]*models.PagoCuota, error) {
+	match := bson.M{"usuarioId": usuarioID, "prestamo.id": bson.M{"$in": prestamoIDs}}
+	if excluir != nil {
+		match["_id"] = bson.M{"$ne": excluir}
+	}
+
+	cursor, err := r.collection.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: match}},
+		{{Key: "$group", Value: bson.M{
+			"_id":    bson.M{"prestamoId": "$prestamo.id", "cuota": "$prestamo.cuota"},
+			"pagado": bson.M{"$sum": "$monto"},
+		}}},
+		{{Key: "$project", Value: bson.M{
+			"_id":        0,
+			"prestamoId": "$_id.prestamoId",
+			"cuota":      "$_id.cuota",
+			"pagado":     1,
+		}}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	pagos := []*models.PagoCuota{}
+	if err := cursor.All(ctx, &pagos); err != nil {
+		return nil, err
+	}
+	return pagos, nil
+}
+
+// CountByContrato cuenta los cobros enlazados con el contrato.
+func (r *TransaccionRepository) CountByContrato(ctx context.Context, usuarioID, contratoID primitive.ObjectID) (int64, error) {
+	return r.collection.CountDocuments(ctx, bson.M{"usuarioId": usuarioID, "contrato.id": contratoID})
+}
+
+// CountByPropiedad cuenta las transacciones asignadas a la propiedad.
+func (r *TransaccionRepository) CountByPropiedad(ctx context.Context, usuarioID, propiedadID primitive.ObjectID) (int64, error) {
+	return r.collection.CountDocuments(ctx, bson.M{"usuarioId": usuarioID, "propiedadId": propiedadID})
+}
+
+// SumCobrosContratos suma los montos de las transacciones enlazadas con cada
+// contrato. Los contratos sin cobros no aparecen en el resultado.
+func (r *TransaccionRepository) SumCobrosContratos(ctx context.Context, usuarioID primitive.ObjectID, contratoIDs []primitive.ObjectID) (map[primitive.ObjectID]money.Amount, error) {
+	cursor, err := r.collection.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"usuarioId": usuarioID, "contrato.id": bson.M{"$in": contratoIDs}}}},
+		{{Key: "$group", Value: bson.M{"_id": "$contrato.id", "cobrado": bson.M{"$sum": "$monto"}}}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var resultados []struct {
+		ID      primitive.ObjectID `bson:"_id"`
+		Cobrado money.Amount       `bson:"cobrado"`
+	}
+	if err := cursor.All(ctx, &resultados); err != nil {
+		return nil, err
+	}
+	cobros := make(map[primitive.ObjectID]money.Amount, len(resultados))
+	for _, c := range resultados {
+		cobros[c.ID] = c.Cobrado
+	}
+	return cobros, nil
+}
+
+// FindReferencias devuelve cuáles de las referencias ya tienen una
+// transacción en la cuenta.
+func (r *TransaccionRepository) FindReferencias(ctx context.Context, usuarioID, cuentaID primitive.ObjectID, referencias []string) (map[string]bool, error) {
+	existentes := make(map[string]bool)
+	if len(referencias) == 0 {
+		return existentes, nil
+	}
+
+	valores, err := r.collection.Distinct(ctx, "referencia", bson.M{
+		"usuarioId":  usuarioID,
+		"cuentaId":   cuentaID,
+		"referencia": bson.M{"$in": referencias},
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range valores {
+		if ref, ok := v.(string); ok {
+			existentes[ref] = true
+		}
+	}
+	return existentes, nil
+}
+
+// DeleteByImportacion elimina las transacciones creadas por la importación y
+// devuelve cuántas eran.
+func (r *TransaccionRepository) DeleteByImportacion(ctx context.Context, usuarioID, importacionID primitive.ObjectID) (int64, error) {
+	result, err := r.collection.DeleteMany(ctx, bson.M{"usuarioId": usuarioID, "importacionId": importacionID})
+	if err != nil {
+		return 0, err
+	}
+	return result.DeletedCount, nil
+}
+
+// Update solo modifica la transacción si pertenece a transaccion.UsuarioID.
+func (r *TransaccionRepository) Update(ctx context.Context, transaccion *models.Transaccion) error {
+	transaccion.UpdatedAt = time.Now()
+	update := bson.M{"$set": transaccion}
+	// Sin divisiones, préstamo, contrato ni propiedad se quitan los que tuviera
+	unset := bson.M{}
+	if len(transaccion.Divisiones) == 0 {
+		unset["divisiones"] = ""
+	}
+	if transaccion.Prestamo == nil {
+		unset["prestamo"] = ""
+	}
+	if transaccion.Contrato == nil {
+		unset["contrato"] = ""
+	}
+	if transaccion.PropiedadID == nil {
+		unset["propiedadId"] = ""
+	}
+	if len(unset) > 0 {
+		update["$unset"] = unset
+	}
+	result, err := r.collection.UpdateOne(
+		ctx,
+		bson.M{"_id": transaccion.ID, "usuarioId": transaccion.UsuarioID},
+		update,
+	)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+func (r *TransaccionRepository) Delete(ctx context.Context, id, usuarioID primitive.ObjectID) error {
+	result, err := r.collection.DeleteOne(ctx, bson.M{"_id": id, "usuarioId": usuarioID})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
@@ -1,95 +1,216 @@
-package repositories
-
-import (
-	"context"
-	"control-financiero/internal/models"
-	"time"
-
-	"go.mongodb.org/mongo-driver/bson"
-	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo"
-)
-
-type UsuarioRepository struct {
-	collection *mongo.Collection
-}
-
-func NewUsuarioRepository(db *mongo.Database) *UsuarioRepository {
-	return &UsuarioRepository{
-		collection: db.Collection("usuarios"),
-	}
-}
-
-func (r *UsuarioRepository) Create(ctx context.Context, usuario *models.Usuario) error {
-	usuario.ID = primitive.NewObjectID()
-	usuario.CreatedAt = time.Now()
-	usuario.UpdatedAt = time.Now()
-	
-	_, err := r.collection.InsertOne(ctx, usuario)
-	return err
-}
-
-func (r *UsuarioRepository) FindByEmail(ctx context.Context, email string) (*models.Usuario, error) {
-	var usuario models.Usuario
-	err := r.collection.FindOne(ctx, bson.M{"email": email}).Decode(&usuario)
-	if err != nil {
-		return nil, err
-	}
-	return &usuario, nil
-}
-
-func (r *UsuarioRepository) FindByGoogleID(ctx context.Context, googleID string) (*models.Usuario, error) {
-	var usuario models.Usuario
-	err := r.collection.FindOne(ctx, bson.M{"googleId": googleID}).Decode(&usuario)
-	if err != nil {
-		return nil, err
-	}
-	return &usuario, nil
-}
-
-func (r *UsuarioRepository) FindByID(ctx context.Context, id primitive.ObjectID) (*models.Usuario, error) {
-	var usuario models.Usuario
-	err := r.collection.FindOne(ctx, bson.M{"_id": id}).Decode(&usuario)
-	if err != nil {
-		return nil, err
-	}
-	return &usuario, nil
-}
-
-func (r *UsuarioRepository) FindAll(ctx context.Context) ([]*models.Usuario, error) {
-	cursor, err := r.collection.Find(ctx, bson.M{})
-	if err != nil {
-		return nil, err
-	}
-	defer cursor.Close(ctx)
-
-	var usuarios []*models.Usuario
-	if err := cursor.All(ctx, &usuarios); err != nil {
-		return nil, err
-	}
-	return usuarios, nil
-}
-
-func (r *UsuarioRepository) Update(ctx context.Context, usuario *models.Usuario) error {
-	usuario.UpdatedAt = time.Now()
-	_, err := r.collection.UpdateOne(
-		ctx,
-		bson.M{"_id": usuario.ID},
-		bson.M{"$set": usuario},
-	)
-	return err
-}
-
-func (r *UsuarioRepository) UpdateEstado(ctx context.Context, id primitive.ObjectID, estado string) error {
-	_, err := r.collection.UpdateOne(
-		ctx,
-		bson.M{"_id": id},
-		bson.M{"$set": bson.M{"estado": estado, "updatedAt": time.Now()}},
-	)
-	return err
-}
-
-func (r *UsuarioRepository) Delete(ctx context.Context, id primitive.ObjectID) error {
-	_, err := r.collection.DeleteOne(ctx, bson.M{"_id": id})
-	return err
-}
+package repositories
+
+import (
+	"context"
+	"control-financiero/internal/models"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type UsuarioRepository struct {
+	collection *mongo.Collection
+}
+
+func NewUsuarioRepository(db *mongo.Database) *UsuarioRepository {
+	return &UsuarioRepository{
+		collection: db.Collection("usuarios"),
+	}
+}
+
+func (r *UsuarioRepository) Create(ctx context.Context, usuario *models.Usuario) error {
+	usuario.ID = primitive.NewObjectID()
+	usuario.CreatedAt = time.Now()
+	usuario.UpdatedAt = time.Now()
+	
+	_, err := r.collection.InsertOne(ctx, usuario)
+	return err
+}
+
+func (r *UsuarioRepository) FindByEmail(ctx context.Context, email string) (*models.Usuario, error) {
+	var usuario models.Usuario
+	err := r.collection.FindOne(ctx, bson.M{"email": email}).Decode(&usuario)
+	if err != nil {
+		return nil, err
+	}
+	return &usuario, nil
+}
+
+func (r *UsuarioRepository) FindByIdentidad(ctx context.Context, provider, subject string) (*models.Usuario, error) {
+	var usuario models.Usuario
+	filter := bson.M{"identidades": bson.M{"$elemMatch": bson.M{"provider": provider, "subject": subject}}}
+	err := r.collection.FindOne(ctx, filter).Decode(&usuario)
+	if err != nil {
+		return nil, err
+	}
+	return &usuario, nil
+}
+
+// AddIdentidad vincula una identidad externa si el usuario aún no tiene una
+// del mismo proveedor.
+func (r *UsuarioRepository) AddIdentidad(ctx context.Context, id primitive.ObjectID, identidad models.Identidad) error {
+	_, err := r.collection.UpdateOne(
+		ctx,
+		bson.M{"_id": id, "identidades.provider": bson.M{"$ne": identidad.Provider}},
+		bson.M{
+			"$push": bson.M{"identidades": identidad},
+			"$set":  bson.M{"updatedAt": time.Now()},
+		},
+	)
+	return err
+}
+
+func (r *UsuarioRepository) FindByID(ctx context.Context, id primitive.ObjectID) (*models.Usuario, error) {
+	var usuario models.Usuario
+	err := r.collection.FindOne(ctx, bson.M{"_id": id}).Decode(&usuario)
+	if err != nil {
+		return nil, err
+	}
+	return &usuario, nil
+}
+
+func (r *UsuarioRepository) FindAll(ctx context.Context) ([]*models.Usuario, error) {
+	cursor, err := r.collection.Find(ctx, bson.M{})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var usuarios []*models.Usuario
+	if err := cursor.All(ctx, &usuarios); err != nil {
+		return nil, err
+	}
+	return usuarios, nil
+}
+
+func (r *UsuarioRepository) CountByRol(ctx context.Context, rol string) (int64, error) {
+	return r.collection.CountDocuments(ctx, bson.M{"rol": rol})
+}
+
+func (r *UsuarioRepository) Update(ctx context.Context, usuario *models.Usuario) error {
+	usuario.UpdatedAt = time.Now()
+	_, err := r.collection.UpdateOne(
+		ctx,
+		bson.M{"_id": usuario.ID},
+		bson.M{"$set": usuario},
+	)
+	return err
+}
+
+func (r *UsuarioRepository) UpdateEstado(ctx context.Context, id primitive.ObjectID, estado string) error {
+	_, err := r.collection.UpdateOne(
+		ctx,
+		bson.M{"_id": id},
+		bson.M{"$set": bson.M{"estado": estado, "updatedAt": time.Now()}},
+	)
+	return err
+}
+
+func (r *UsuarioRepository) MarkEmailVerificado(ctx context.Context, id primitive.ObjectID) error {
+	_, err := r.collection.UpdateOne(
+		ctx,
+		bson.M{"_id": id},
+		bson.M{"$set": bson.M{"emailVerificado": true, "updatedAt": time.Now()}},
+	)
+	return err
+}
+
+func (r *UsuarioRepository) UpdatePassword(ctx context.Context, id primitive.ObjectID, passwordHash string) error {
+	_, err := r.collection.UpdateOne(
+		ctx,
+		bson.M{"_id": id},
+		bson.M{"$set": bson.M{"passwordHash": passwordHash, "updatedAt": time.Now()}},
+	)
+	return err
+}
+
+// StartTwoFactor guarda un secreto TOTP pendiente de confirmar. No modifica
+// a un usuario que ya tiene 2FA activado.
+func (r *UsuarioRepository) StartTwoFactor(ctx context.Context, id primitive.ObjectID, secret string) (bool, error) {
+	result, err := r.collection.UpdateOne(
+		ctx,
+		bson.M{"_id": id, "twoFactor.enabled": bson.M{"$ne": true}},
+		bson.M{"$set": bson.M{
+			"twoFactor": models.TwoFactor{Secret: secret},
+			"updatedAt": time.Now(),
+		}},
+	)
+	if err != nil {
+		return false, err
+	}
+	return result.MatchedCount > 0, nil
+}
+
+// EnableTwoFactor activa la inscripción pendiente con el primer código
+// aceptado y los hashes de los códigos de recuperación.
+func (r *UsuarioRepository) EnableTwoFactor(ctx context.Context, id primitive.ObjectID, step int64, recoveryCodes []string) (bool, error) {
+	now := time.Now()
+	result, err := r.collection.UpdateOne(
+		ctx,
+		bson.M{"_id": id, "twoFactor.enabled": false, "twoFactor.lastStep": bson.M{"$lt": step}},
+		bson.M{"$set": bson.M{
+			"twoFactor.enabled":       true,
+			"twoFactor.lastStep":      step,
+			"twoFactor.recoveryCodes": recoveryCodes,
+			"twoFactor.enabledAt":     now,
+			"updatedAt":               now,
+		}},
+	)
+	if err != nil {
+		return false, err
+	}
+	return result.ModifiedCount > 0, nil
+}
+
+// UseTOTPStep registra el paso TOTP usado; falla si ese paso o uno posterior
+// ya se aceptó, de modo que un código no sirve dos veces.
+func (r *UsuarioRepository) UseTOTPStep(ctx context.Context, id primitive.ObjectID, step int64) (bool, error) {
+	result, err := r.collection.UpdateOne(
+		ctx,
+		bson.M{"_id": id, "twoFactor.enabled": true, "twoFactor.lastStep": bson.M{"$lt": step}},
+		bson.M{"$set": bson.M{"twoFactor.lastStep": step}},
+	)
+	if err != nil {
+		return false, err
+	}
+	return result.ModifiedCount > 0, nil
+}
+
+// UseRecoveryCode elimina el código de recuperación si todavía existe.
+func (r *UsuarioRepository) UseRecoveryCode(ctx context.Context, id primitive.ObjectID, codeHash string) (bool, error) {
+	result, err := r.collection.UpdateOne(
+		ctx,
+		bson.M{"_id": id, "twoFactor.enabled": true, "twoFactor.recoveryCodes": codeHash},
+		bson.M{"$pull": bson.M{"twoFactor.recoveryCodes": codeHash}},
+	)
+	if err != nil {
+		return false, err
+	}
+	return result.ModifiedCount > 0, nil
+}
+
+// ResetTwoFactor elimina la configuración 2FA del usuario.
+func (r *UsuarioRepository) ResetTwoFactor(ctx context.Context, id primitive.ObjectID) error {
+	result, err := r.collection.UpdateOne(
+		ctx,
+		bson.M{"_id": id},
+		bson.M{
+			"$unset": bson.M{"twoFactor": ""},
+			"$set":   bson.M{"updatedAt": time.Now()},
+		},
+	)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+func (r *UsuarioRepository) Delete(ctx context.Context, id primitive.ObjectID) error {
+	_, err := r.collection.DeleteOne(ctx, bson.M{"_id": id})
+	return err
+}
@@ -1,119 +1,290 @@
-package routes
-
-import (
-	"net/http"
-
-	"control-financiero/internal/auth"
-	"control-financiero/internal/config"
-	"control-financiero/internal/controllers"
-	"control-financiero/internal/middleware"
-
-	"github.com/gin-contrib/cors"
-	"github.com/gin-gonic/gin"
-	"go.mongodb.org/mongo-driver/mongo"
-)
-
-func Setup(router *gin.Engine, db *mongo.Client, cfg *config.Config) {
-	// Inicializar Google OAuth
-	auth.InitGoogleOAuth()
-
-	// CORS
-	router.Use(cors.New(cors.Config{
-		AllowOrigins:     []string{"*"},
-		AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
-		AllowHeaders:     []string{"Origin", "Content-Type", "Authorization"},
-		ExposeHeaders:    []string{"Content-Length"},
-		AllowCredentials: true,
-	}))
-
-	// Controladores
-	database := db.Database(cfg.MongoDB)
-	authController := controllers.NewAuthController(database, cfg)
-	usuarioController := controllers.NewUsuarioController(database)
-	categoriaController := controllers.NewCategoriaController(database)
-	transaccionController := controllers.NewTransaccionController(database)
-
-	// Rutas públicas
-	api := router.Group("/api/v1")
-	{
-		// Autenticación
-		auth := api.Group("/auth")
-		{
-			auth.POST("/register", authController.Register)
-			auth.POST("/login", authController.Login)
-			auth.POST("/refresh", authController.RefreshToken)
-			auth.GET("/google", authController.GoogleAuthURL)
-			auth.GET("/google/callback", authController.GoogleCallback)
-		}
-
-		// Health check
-		api.GET("/health", func(c *gin.Context) {
-			c.JSON(http.StatusOK, gin.H{
-				"status": "ok",
-				"service": "control-financiero",
-			})
-		})
-	}
-
-	// Rutas protegidas
-	protected := api.Group("")
-	protected.Use(middleware.AuthMiddleware(cfg))
-	{
-		// Perfil
-		protected.GET("/perfil", usuarioController.GetProfile)
-		protected.PUT("/perfil", usuarioController.UpdateProfile)
-		protected.POST("/cambiar-password", authController.ChangePassword)
-
-		// Categorías
-		categorias := protected.Group("/categorias")
-		{
-			categorias.POST("", categoriaController.Create)
-			categorias.GET("", categoriaController.GetAll)
-			categorias.GET("/:id", categoriaController.GetByID)
-			categorias.PUT("/:id", categoriaController.Update)
-			categorias.DELETE("/:id", categoriaController.Delete)
-		}
-
-		// Transacciones
-		transacciones := protected.Group("/transacciones")
-		{
-			transacciones.POST("", transaccionController.Create)
-			transacciones.GET("", transaccionController.GetAll)
-			transacciones.GET("/:id", transaccionController.GetByID)
-			transacciones.PUT("/:id", transaccionController.Update)
-			transacciones.DELETE("/:id", transaccionController.Delete)
-		}
-
-		// Reportes
-		reportes := protected.Group("/reportes")
-		{
-			reportes.GET("/estadisticas", transaccionController.GetEstadisticas)
-		}
-
-		// Rutas de administrador
-		admin := protected.Group("/admin")
-		admin.Use(middleware.AdminMiddleware())
-		{
-			// Usuarios
-			admin.GET("/usuarios", usuarioController.GetAll)
-			admin.GET("/usuarios/:id", usuarioController.GetByID)
-			admin.PATCH("/usuarios/:id/aprobar", usuarioController.Approve)
-			admin.PATCH("/usuarios/:id/activar", usuarioController.Activate)
-			admin.PATCH("/usuarios/:id/desactivar", usuarioController.Deactivate)
-			admin.PATCH("/usuarios/:id/rol", usuarioController.ChangeRole)
-			admin.DELETE("/usuarios/:id", usuarioController.Delete)
-		}
-	}
-
-	// Servir archivos estáticos
-	router.Static("/assets", "./assets")
-	router.Static("/web/static", "./web/static")
-	router.LoadHTMLGlob("web/templates/*")
-
-	// Ruta principal
-	router.GET("/", func(c *gin.Context) {
-		c.HTML(http.StatusOK, "index.html", gin.H{
-			"title": "Control Financiero",
-		})
-	})
-}
+package routes
+
+import (
+	"log"
+	"net/http"
+
+	"control-financiero/internal/auth"
+	"control-financiero/internal/config"
+	"control-financiero/internal/controllers"
+	"control-financiero/internal/middleware"
+	"control-financiero/internal/services"
+
+	"github.com/gin-contrib/cors"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func Setup(router *gin.Engine, db *mongo.Client, cfg *config.Config) {
+	// Solo se acepta X-Forwarded-For de los proxies configurados; de lo
+	// contrario la IP de los límites de intentos podría falsificarse
+	if err := router.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		log.Printf("❌ TRUSTED_PROXIES inválido: %v\n", err)
+	}
+
+	// CORS
+	router.Use(cors.New(cors.Config{
+		AllowOrigins:     []string{"*"},
+		AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
+		AllowHeaders:     []string{"Origin", "Content-Type", "Authorization"},
+		ExposeHeaders:    []string{"Content-Length"},
+		AllowCredentials: true,
+	}))
+
+	// Controladores
+	database := db.Database(cfg.MongoDB)
+	authController := controllers.NewAuthController(database, cfg)
+	usuarioController := controllers.NewUsuarioController(database)
+	categoriaController := controllers.NewCategoriaController(database)
+	transaccionController := controllers.NewTransaccionController(database)
+	cuentaController := controllers.NewCuentaController(database)
+	recurrenciaController := controllers.NewRecurrenciaController(database)
+	prestamoController := controllers.NewPrestamoController(database)
+	propiedadController := controllers.NewPropiedadController(database)
+	inquilinoController := controllers.NewInquilinoController(database)
+	contratoController := controllers.NewContratoController(database)
+	importacionController := controllers.NewImportacionController(database)
+	reporteController := controllers.NewReporteController(database)
+	sesionController := controllers.NewSesionController(database)
+	rolController := controllers.NewRolController(database)
+	twoFactorController := controllers.NewTwoFactorController(database)
+	tokenController := controllers.NewPersonalAccessTokenController(database)
+	auditoriaController := controllers.NewAuditoriaController(database)
+	exchangeRateController := controllers.NewExchangeRateController(database)
+	rolService := services.NewRolService(database)
+	authMiddleware := middleware.AuthMiddleware(cfg, services.NewPersonalAccessTokenService(database))
+	sessionOnly := middleware.RequireSession()
+
+	// Claves públicas de los access tokens
+	router.GET("/.well-known/jwks.json", authController.JWKS)
+
+	// Rutas públicas
+	api := router.Group("/api/v1")
+	{
+		// Autenticación
+		auth := api.Group("/auth")
+		{
+			auth.POST("/register", authController.Register)
+			auth.POST("/login", authController.Login)
+			auth.POST("/login/2fa", authController.LoginTwoFactor)
+			auth.POST("/refresh", authController.RefreshToken)
+			auth.POST("/logout", authController.Logout)
+			auth.POST("/logout-all", authMiddleware, sessionOnly, authController.LogoutAll)
+			auth.POST("/forgot-password", authController.ForgotPassword)
+			auth.POST("/reset-password", authController.ResetPassword)
+			auth.GET("/verify-email", authController.VerifyEmail)
+			auth.POST("/exchange", authController.ExchangeCode)
+			auth.GET("/providers", authController.Providers)
+			auth.GET("/:provider", authController.ProviderAuthURL)
+			auth.GET("/:provider/callback", authController.ProviderCallback)
+		}
+
+		// Health check
+		api.GET("/health", func(c *gin.Context) {
+			c.JSON(http.StatusOK, gin.H{
+				"status":  "ok",
+				"service": "control-financiero",
+			})
+		})
+	}
+
+	// Rutas protegidas
+	protected := api.Group("")
+	protected.Use(authMiddleware, middleware.LoadPermissions(rolService))
+	{
+		// Perfil. Los cambios en la cuenta exigen una sesión iniciada y no
+		// aceptan tokens de acceso personal
+		protected.GET("/perfil", usuarioController.GetProfile)
+		protected.PUT("/perfil", sessionOnly, usuarioController.UpdateProfile)
+		protected.POST("/cambiar-password", sessionOnly, authController.ChangePassword)
+		protected.GET("/perfil/auditoria", sessionOnly, auditoriaController.GetProfile)
+
+		// Verificación en dos pasos
+		protected.POST("/perfil/2fa", sessionOnly, twoFactorController.Enroll)
+		protected.POST("/perfil/2fa/confirmar", sessionOnly, twoFactorController.Confirm)
+		protected.POST("/perfil/2fa/desactivar", sessionOnly, twoFactorController.Disable)
+
+		// Tokens de acceso personal
+		tokens := protected.Group("/perfil/tokens")
+		tokens.Use(sessionOnly)
+		{
+			tokens.GET("", tokenController.GetAll)
+			tokens.POST("", tokenController.Create)
+			tokens.DELETE("/:id", tokenController.Delete)
+		}
+
+		// Sesiones
+		sesiones := protected.Group("/sesiones")
+		sesiones.Use(sessionOnly)
+		{
+			sesiones.GET("", sesionController.GetAll)
+			sesiones.DELETE("/:id", sesionController.Delete)
+		}
+
+		// Categorías
+		categorias := protected.Group("/categorias")
+		{
+			categorias.POST("", middleware.RequirePermission(auth.PermCategoriasWrite), categoriaController.Create)
+			categorias.GET("", middleware.RequirePermission(auth.PermCategoriasRead), categoriaController.GetAll)
+			categorias.GET("/:id", middleware.RequirePermission(auth.PermCategoriasRead), categoriaController.GetByID)
+			categorias.PUT("/:id", middleware.RequirePermission(auth.PermCategoriasWrite), categoriaController.Update)
+			categorias.DELETE("/:id", middleware.RequirePermission(auth.PermCategoriasWrite), categoriaController.Delete)
+		}
+
+		// Transacciones
+		transacciones := protected.Group("/transacciones")
+		{
+			transacciones.POST("", middleware.RequirePermission(auth.PermTransaccionesWrite), transaccionController.Create)
+			transacciones.GET("", middleware.RequirePermission(auth.PermTransaccionesRead), transaccionController.GetAll)
+			transacciones.GET("/:id", middleware.RequirePermission(auth.PermTransaccionesRead), transaccionController.GetByID)
+			transacciones.PUT("/:id", middleware.RequirePermission(auth.PermTransaccionesWrite), transaccionController.Update)
+			transacciones.DELETE("/:id", middleware.RequirePermission(auth.PermTransaccionesWrite), transaccionController.Delete)
+			transacciones.POST("/import", middleware.RequirePermission(auth.PermTransaccionesWrite), importacionController.Importar)
+		}
+
+		// Importaciones: lotes de transacciones importadas desde un archivo,
+		// que se deshacen juntas, y los perfiles con el mapeo de columnas de
+		// cada banco
+		importaciones := protected.Group("/importaciones")
+		{
+			importaciones.GET("", middleware.RequirePermission(auth.PermTransaccionesRead), importacionController.GetAll)
+			importaciones.DELETE("/:id", middleware.RequirePermission(auth.PermTransaccionesWrite), importacionController.Deshacer)
+			importaciones.POST("/perfiles", middleware.RequirePermission(auth.PermTransaccionesWrite), importacionController.CreatePerfil)
+			importaciones.GET("/perfiles", middleware.RequirePermission(auth.PermTransaccionesRead), importacionController.GetPerfiles)
+			importaciones.GET("/perfiles/:id", middleware.RequirePermission(auth.PermTransaccionesRead), importacionController.GetPerfil)
+			importaciones.PUT("/perfiles/:id", middleware.RequirePermission(auth.PermTransaccionesWrite), importacionController.UpdatePerfil)
+			importaciones.DELETE("/perfiles/:id", middleware.RequirePermission(auth.PermTransaccionesWrite), importacionController.DeletePerfil)
+		}
+
+		// Transferencias entre cuentas: crean dos transacciones enlazadas que
+		// luego se editan y eliminan desde /transacciones
+		protected.POST("/transferencias", middleware.RequirePermission(auth.PermTransaccionesWrite), transaccionController.CreateTransferencia)
+
+		// Cuentas. Usan los permisos de transacciones: quien puede registrar
+		// movimientos puede gestionar las cuentas en que se registran
+		cuentas := protected.Group("/cuentas")
+		{
+			cuentas.POST("", middleware.RequirePermission(auth.PermTransaccionesWrite), cuentaController.Create)
+			cuentas.GET("", middleware.RequirePermission(auth.PermTransaccionesRead), cuentaController.GetAll)
+			cuentas.GET("/:id", middleware.RequirePermission(auth.PermTransaccionesRead), cuentaController.GetByID)
+			cuentas.PUT("/:id", middleware.RequirePermission(auth.PermTransaccionesWrite), cuentaController.Update)
+			cuentas.DELETE("/:id", middleware.RequirePermission(auth.PermTransaccionesWrite), cuentaController.Delete)
+			cuentas.GET("/:id/extracto", middleware.RequirePermission(auth.PermTransaccionesRead), cuentaController.GetExtracto)
+			cuentas.GET("/:id/historial", middleware.RequirePermission(auth.PermTransaccionesRead), cuentaController.GetHistorial)
+		}
+
+		// Recurrencias: plantillas que el programador registra como
+		// transacciones en cada fecha, con los permisos de transacciones
+		recurrencias := protected.Group("/recurrencias")
+		{
+			recurrencias.POST("", middleware.RequirePermission(auth.PermTransaccionesWrite), recurrenciaController.Create)
+			recurrencias.GET("", middleware.RequirePermission(auth.PermTransaccionesRead), recurrenciaController.GetAll)
+			recurrencias.GET("/:id", middleware.RequirePermission(auth.PermTransaccionesRead), recurrenciaController.GetByID)
+			recurrencias.PUT("/:id", middleware.RequirePermission(auth.PermTransaccionesWrite), recurrenciaController.Update)
+			recurrencias.DELETE("/:id", middleware.RequirePermission(auth.PermTransaccionesWrite), recurrenciaController.Delete)
+			recurrencias.GET("/:id/ocurrencias", middleware.RequirePermission(auth.PermTransaccionesRead), recurrenciaController.GetOcurrencias)
+			recurrencias.PUT("/:id/ocurrencias/:fecha", middleware.RequirePermission(auth.PermTransaccionesWrite), recurrenciaController.UpdateOcurrencia)
+			recurrencias.DELETE("/:id/ocurrencias/:fecha", middleware.RequirePermission(auth.PermTransaccionesWrite), recurrenciaController.DeleteOcurrencia)
+		}
+
+		// Préstamos: el desembolso y los pagos son transacciones de tipo
+		// prestamo enlazadas, así que usan los permisos de transacciones
+		prestamos := protected.Group("/prestamos")
+		{
+			prestamos.POST("", middleware.RequirePermission(auth.PermTransaccionesWrite), prestamoController.Create)
+			prestamos.GET("", middleware.RequirePermission(auth.PermTransaccionesRead), prestamoController.GetAll)
+			prestamos.GET("/:id", middleware.RequirePermission(auth.PermTransaccionesRead), prestamoController.GetByID)
+			prestamos.PUT("/:id", middleware.RequirePermission(auth.PermTransaccionesWrite), prestamoController.Update)
+			prestamos.DELETE("/:id", middleware.RequirePermission(auth.PermTransaccionesWrite), prestamoController.Delete)
+		}
+
+		// Alquileres: propiedades, inquilinos y contratos. Los cobros son
+		// transacciones de tipo alquiler, así que usan los permisos de
+		// transacciones
+		propiedades := protected.Group("/propiedades")
+		{
+			propiedades.POST("", middleware.RequirePermission(auth.PermTransaccionesWrite), propiedadController.Create)
+			propiedades.GET("", middleware.RequirePermission(auth.PermTransaccionesRead), propiedadController.GetAll)
+			propiedades.GET("/:id", middleware.RequirePermission(auth.PermTransaccionesRead), propiedadController.GetByID)
+			propiedades.PUT("/:id", middleware.RequirePermission(auth.PermTransaccionesWrite), propiedadController.Update)
+			propiedades.DELETE("/:id", middleware.RequirePermission(auth.PermTransaccionesWrite), propiedadController.Delete)
+			propiedades.GET("/:id/resultados", middleware.RequirePermission(auth.PermReportesRead), propiedadController.GetResultados)
+		}
+		inquilinos := protected.Group("/inquilinos")
+		{
+			inquilinos.POST("", middleware.RequirePermission(auth.PermTransaccionesWrite), inquilinoController.Create)
+			inquilinos.GET("", middleware.RequirePermission(auth.PermTransaccionesRead), inquilinoController.GetAll)
+			inquilinos.GET("/:id", middleware.RequirePermission(auth.PermTransaccionesRead), inquilinoController.GetByID)
+			inquilinos.PUT("/:id", middleware.RequirePermission(auth.PermTransaccionesWrite), inquilinoController.Update)
+			inquilinos.DELETE("/:id", middleware.RequirePermission(auth.PermTransaccionesWrite), inquilinoController.Delete)
+		}
+		contratos := protected.Group("/contratos")
+		{
+			contratos.POST("", middleware.RequirePermission(auth.PermTransaccionesWrite), contratoController.Create)
+			contratos.GET("", middleware.RequirePermission(auth.PermTransaccionesRead), contratoController.GetAll)
+			contratos.GET("/:id", middleware.RequirePermission(auth.PermTransaccionesRead), contratoController.GetByID)
+			contratos.PUT("/:id", middleware.RequirePermission(auth.PermTransaccionesWrite), contratoController.Update)
+			contratos.DELETE("/:id", middleware.RequirePermission(auth.PermTransaccionesWrite), contratoController.Delete)
+		}
+
+		// Tipos de cambio
+		protected.GET("/tipos-cambio", exchangeRateController.GetAll)
+
+		// Reportes
+		reportes := protected.Group("/reportes")
+		reportes.Use(middleware.RequirePermission(auth.PermReportesRead))
+		{
+			reportes.GET("/estadisticas", transaccionController.GetEstadisticas)
+			reportes.GET("/patrimonio", reporteController.GetPatrimonio)
+			reportes.GET("/morosidad", reporteController.GetMorosidad)
+		}
+
+		// Rutas de administración
+		admin := protected.Group("/admin")
+		{
+			// Usuarios
+			usuariosRead := middleware.RequirePermission(auth.PermUsuariosRead)
+			usuariosWrite := middleware.RequirePermission(auth.PermUsuariosWrite)
+			admin.GET("/usuarios", usuariosRead, usuarioController.GetAll)
+			admin.GET("/usuarios/:id", usuariosRead, usuarioController.GetByID)
+			admin.PATCH("/usuarios/:id/aprobar", usuariosWrite, usuarioController.Approve)
+			admin.PATCH("/usuarios/:id/activar", usuariosWrite, usuarioController.Activate)
+			admin.PATCH("/usuarios/:id/desactivar", usuariosWrite, usuarioController.Deactivate)
+			admin.PATCH("/usuarios/:id/rol", usuariosWrite, middleware.RequirePermission(auth.PermRolesWrite), usuarioController.ChangeRole)
+			admin.DELETE("/usuarios/:id", usuariosWrite, usuarioController.Delete)
+			admin.GET("/usuarios/:id/sesiones", usuariosRead, sesionController.GetByUsuario)
+			admin.DELETE("/usuarios/:id/sesiones/:sesionId", usuariosWrite, sesionController.DeleteByUsuario)
+			admin.DELETE("/usuarios/:id/2fa", usuariosWrite, usuarioController.ResetTwoFactor)
+
+			// Roles
+			rolesRead := middleware.RequirePermission(auth.PermRolesRead)
+			rolesWrite := middleware.RequirePermission(auth.PermRolesWrite)
+			admin.GET("/roles", rolesRead, rolController.GetAll)
+			admin.GET("/roles/permisos", rolesRead, rolController.GetPermisos)
+			admin.GET("/roles/:id", rolesRead, rolController.GetByID)
+			admin.POST("/roles", rolesWrite, rolController.Create)
+			admin.PUT("/roles/:id", rolesWrite, rolController.Update)
+			admin.DELETE("/roles/:id", rolesWrite, rolController.Delete)
+
+			// Auditoría
+			admin.GET("/auditoria", middleware.RequirePermission(auth.PermAuditoriaRead), auditoriaController.GetAll)
+
+			// Tipos de cambio
+			tiposCambioWrite := middleware.RequirePermission(auth.PermTiposCambioWrite)
+			admin.POST("/tipos-cambio", tiposCambioWrite, exchangeRateController.Create)
+			admin.POST("/tipos-cambio/csv", tiposCambioWrite, exchangeRateController.ImportCSV)
+		}
+	}
+
+	// Servir archivos estáticos
+	router.Static("/assets", "./assets")
+	router.Static("/web/static", "./web/static")
+	router.LoadHTMLGlob("web/templates/*")
+
+	// Ruta principal
+	router.GET("/", func(c *gin.Context) {
+		c.HTML(http.StatusOK, "index.html", gin.H{
+			"title": "Control Financiero",
+		})
+	})
+}
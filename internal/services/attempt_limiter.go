@@ -0,0 +1,122 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"control-financiero/internal/repositories"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// RateLimitError indica que la cuenta o la IP están bloqueadas temporalmente
+// por demasiados intentos fallidos.
+type RateLimitError struct {
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitError) Error() string {
+	return "demasiados intentos fallidos, inténtelo más tarde"
+}
+
+// attemptPolicy define cuántos fallos se toleran antes de aplicar un retardo
+// que se duplica con cada fallo adicional, hasta un bloqueo de Max.
+type attemptPolicy struct {
+	Free   int
+	Base   time.Duration
+	Max    time.Duration
+	Window time.Duration
+}
+
+var (
+	// Una cuenta concreta admite pocos fallos
+	accountPolicy = attemptPolicy{Free: 3, Base: time.Second, Max: 15 * time.Minute, Window: time.Hour}
+	// Una IP puede compartirse (NAT), así que tolera más
+	ipPolicy = attemptPolicy{Free: 20, Base: time.Second, Max: 15 * time.Minute, Window: time.Hour}
+)
+
+func (p attemptPolicy) delay(failures int) time.Duration {
+	over := failures - p.Free
+	if over <= 0 {
+		return 0
+	}
+
+	d := float64(p.Base) * math.Pow(2, float64(over-1))
+	if d >= float64(p.Max) {
+		return p.Max
+	}
+	return time.Duration(d)
+}
+
+// attemptLimiter guarda en Mongo los fallos por cuenta y por IP, de modo que
+// los límites valen para todas las réplicas.
+type attemptLimiter struct {
+	repo *repositories.LoginAttemptRepository
+}
+
+func newAttemptLimiter(db *mongo.Database) *attemptLimiter {
+	return &attemptLimiter{repo: repositories.NewLoginAttemptRepository(db)}
+}
+
+func accountKey(email string) string {
+	return "cuenta:" + strings.ToLower(strings.TrimSpace(email))
+}
+
+func userKey(id primitive.ObjectID) string {
+	return "usuario:" + id.Hex()
+}
+
+func ipKey(ip string) string {
+	return "ip:" + ip
+}
+
+// Check devuelve un RateLimitError si alguna de las claves está bloqueada.
+func (l *attemptLimiter) Check(ctx context.Context, keys ...string) error {
+	locked, err := l.repo.FindLocked(ctx, keys)
+	if err != nil {
+		return err
+	}
+
+	var retryAfter time.Duration
+	for _, attempt := range locked {
+		if d := time.Until(attempt.LockedUntil); d > retryAfter {
+			retryAfter = d
+		}
+	}
+	if retryAfter > 0 {
+		return &RateLimitError{RetryAfter: retryAfter}
+	}
+	return nil
+}
+
+// Fail registra un fallo en cada clave y aplica el retardo que corresponda.
+func (l *attemptLimiter) Fail(ctx context.Context, keys ...string) error {
+	for _, key := range keys {
+		policy := accountPolicy
+		if strings.HasPrefix(key, "ip:") {
+			policy = ipPolicy
+		}
+
+		attempt, err := l.repo.RegisterFailure(ctx, key, policy.Window)
+		if err != nil {
+			return fmt.Errorf("error registrando intento fallido: %w", err)
+		}
+
+		if d := policy.delay(attempt.Failures); d > 0 {
+			if err := l.repo.Lock(ctx, key, time.Now().Add(d)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Succeed reinicia el contador de la cuenta. El de la IP se mantiene para que
+// un login correcto no sirva para seguir probando otras cuentas.
+func (l *attemptLimiter) Succeed(ctx context.Context, key string) error {
+	return l.repo.Reset(ctx, key)
+}
@@ -0,0 +1,76 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"control-financiero/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+func TestAttemptPolicy_Delay(t *testing.T) {
+	p := attemptPolicy{Free: 3, Base: time.Second, Max: time.Minute}
+
+	assert.Zero(t, p.delay(1))
+	assert.Zero(t, p.delay(3))
+	assert.Equal(t, time.Second, p.delay(4))
+	assert.Equal(t, 2*time.Second, p.delay(5))
+	assert.Equal(t, 32*time.Second, p.delay(9))
+	assert.Equal(t, time.Minute, p.delay(10))
+	assert.Equal(t, time.Minute, p.delay(1000))
+}
+
+func TestLogin_Bloqueado(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	ctx := context.Background()
+	client := models.ClientInfo{IP: "203.0.113.7"}
+
+	mt.Run("devuelve Retry-After", func(mt *mtest.T) {
+		s, _ := newAuthServiceConMailer(mt)
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "test.login_attempts", mtest.FirstBatch, bson.D{
+			{Key: "_id", Value: "cuenta:ana@example.com"},
+			{Key: "failures", Value: 8},
+			{Key: "lockedUntil", Value: time.Now().Add(30 * time.Second)},
+		}))
+
+		_, _, err := s.Login(ctx, &models.LoginRequest{Email: "Ana@example.com", Password: "x"}, client)
+
+		var rateLimit *RateLimitError
+		require.True(t, errors.As(err, &rateLimit))
+		assert.InDelta(t, 30, rateLimit.RetryAfter.Seconds(), 2)
+
+		// Con la cuenta bloqueada no se consulta el usuario
+		evt := mt.GetStartedEvent()
+		require.Equal(t, "find", evt.CommandName)
+		assert.Nil(t, mt.GetStartedEvent())
+
+		keys, err := evt.Command.Lookup("filter", "_id", "$in").Array().Values()
+		require.NoError(t, err)
+		assert.Equal(t, "cuenta:ana@example.com", keys[0].StringValue())
+		assert.Equal(t, "ip:203.0.113.7", keys[1].StringValue())
+	})
+
+	mt.Run("usuario pendiente da el mismo error", func(mt *mtest.T) {
+		s, _ := newAuthServiceConMailer(mt)
+		usuario := usuarioActivo(t)
+		usuario.Estado = "pending"
+
+		failure := bson.D{{Key: "ok", Value: 1}, {Key: "value", Value: bson.D{{Key: "_id", Value: "k"}, {Key: "failures", Value: 1}}}}
+		mt.AddMockResponses(
+			mtest.CreateCursorResponse(0, "test.login_attempts", mtest.FirstBatch),
+			mtest.CreateCursorResponse(0, "test.usuarios", mtest.FirstBatch, bsonDoc(t, usuario)),
+			mtest.CreateSuccessResponse(), // auditoría
+			failure,
+			failure,
+		)
+
+		_, _, err := s.Login(ctx, &models.LoginRequest{Email: usuario.Email, Password: "password123"}, client)
+		assert.ErrorIs(t, err, ErrInvalidCredentials)
+	})
+}
@@ -0,0 +1,128 @@
+package services
+
+import (
+	"context"
+	"log"
+	"reflect"
+
+	"control-financiero/internal/models"
+	"control-financiero/internal/repositories"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	auditLimiteDefecto = 100
+	auditLimiteMaximo  = 500
+)
+
+// auditCamposOcultos nunca se copian al registro de auditoría.
+var auditCamposOcultos = []string{"passwordHash", "twoFactor", "tokenHash"}
+
+// auditCamposIgnorados cambian en cada escritura y no aportan nada al diff.
+var auditCamposIgnorados = map[string]bool{"updatedAt": true}
+
+type AuditService struct {
+	auditRepo *repositories.AuditLogRepository
+}
+
+func NewAuditService(db *mongo.Database) *AuditService {
+	return &AuditService{
+		auditRepo: repositories.NewAuditLogRepository(db),
+	}
+}
+
+// Record completa la entrada con el actor, la IP y el User-Agent de client y
+// la guarda. Si no se indica el afectado se asume que es el propio actor. Un
+// error al auditar queda en el log pero no hace fallar la operación, que ya
+// se completó.
+func (s *AuditService) Record(ctx context.Context, client models.ClientInfo, entry *models.AuditLog) {
+	if entry.UsuarioID == nil && !client.UsuarioID.IsZero() {
+		entry.UsuarioID = objectIDPtr(client.UsuarioID)
+	}
+	if entry.AfectadoID == nil {
+		entry.AfectadoID = entry.UsuarioID
+	}
+	entry.IP = client.IP
+	entry.UserAgent = client.UserAgent
+
+	if err := s.auditRepo.Create(ctx, entry); err != nil {
+		log.Printf("Error registrando auditoría (%s): %v\n", entry.Accion, err)
+	}
+}
+
+// Search aplica el límite por defecto y el máximo a la página pedida.
+func (s *AuditService) Search(ctx context.Context, filtro models.AuditLogFilter) ([]*models.AuditLog, error) {
+	if filtro.Limite <= 0 {
+		filtro.Limite = auditLimiteDefecto
+	}
+	if filtro.Limite > auditLimiteMaximo {
+		filtro.Limite = auditLimiteMaximo
+	}
+	if filtro.Pagina < 1 {
+		filtro.Pagina = 1
+	}
+
+	return s.auditRepo.Find(ctx, filtro)
+}
+
+// auditDiff arma el Detalle de una modificación con los campos que cambiaron
+// entre before y after. Con before nil (creación) o after nil (eliminación)
+// incluye el documento completo del lado que existe.
+func auditDiff(before, after interface{}) bson.M {
+	antes, despues := auditSnapshot(before), auditSnapshot(after)
+	if antes == nil || despues == nil {
+		detalle := bson.M{}
+		if antes != nil {
+			detalle["antes"] = antes
+		}
+		if despues != nil {
+			detalle["despues"] = despues
+		}
+		return detalle
+	}
+
+	cambiosAntes, cambiosDespues := bson.M{}, bson.M{}
+	for k, v := range despues {
+		if !auditCamposIgnorados[k] && !reflect.DeepEqual(antes[k], v) {
+			cambiosAntes[k] = antes[k]
+			cambiosDespues[k] = v
+		}
+	}
+	for k, v := range antes {
+		if _, ok := despues[k]; !ok && !auditCamposIgnorados[k] {
+			cambiosAntes[k] = v
+			cambiosDespues[k] = nil
+		}
+	}
+
+	return bson.M{"antes": cambiosAntes, "despues": cambiosDespues}
+}
+
+// auditSnapshot convierte v en documento sin los campos sensibles.
+func auditSnapshot(v interface{}) bson.M {
+	rv := reflect.ValueOf(v)
+	if !rv.IsValid() || (rv.Kind() == reflect.Ptr && rv.IsNil()) {
+		return nil
+	}
+
+	raw, err := bson.Marshal(v)
+	if err != nil {
+		return nil
+	}
+	var doc bson.M
+	if err := bson.Unmarshal(raw, &doc); err != nil {
+		return nil
+	}
+
+	for _, campo := range auditCamposOcultos {
+		delete(doc, campo)
+	}
+	return doc
+}
+
+func objectIDPtr(id primitive.ObjectID) *primitive.ObjectID {
+	return &id
+}
@@ -0,0 +1,147 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"control-financiero/internal/models"
+	"control-financiero/internal/money"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+func TestAuditDiff(t *testing.T) {
+	antes := &models.Categoria{Nombre: "Comida", Tipo: "egreso", Color: "#f00", UpdatedAt: time.Now()}
+	despues := *antes
+	despues.Nombre = "Supermercado"
+	despues.UpdatedAt = time.Now().Add(time.Minute)
+
+	// Solo los campos que cambiaron, sin updatedAt
+	assert.Equal(t, bson.M{
+		"antes":   bson.M{"nombre": "Comida"},
+		"despues": bson.M{"nombre": "Supermercado"},
+	}, auditDiff(antes, &despues))
+
+	// Al crear o eliminar se guarda el documento completo
+	creado := auditDiff(nil, antes)
+	assert.NotContains(t, creado, "antes")
+	assert.Equal(t, "Comida", creado["despues"].(bson.M)["nombre"])
+
+	eliminado := auditDiff(antes, nil)
+	assert.NotContains(t, eliminado, "despues")
+	assert.Equal(t, "egreso", eliminado["antes"].(bson.M)["tipo"])
+}
+
+func TestAuditDiff_OcultaSecretos(t *testing.T) {
+	usuario := &models.Usuario{
+		Nombre:       "Ana",
+		PasswordHash: "hash",
+		TwoFactor:    &models.TwoFactor{Secret: "secreto", Enabled: true},
+	}
+
+	detalle := auditDiff(usuario, nil)["antes"].(bson.M)
+	assert.Equal(t, "Ana", detalle["nombre"])
+	assert.NotContains(t, detalle, "passwordHash")
+	assert.NotContains(t, detalle, "twoFactor")
+}
+
+func TestTransaccion_UpdateAudita(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	id := primitive.NewObjectID()
+	categoria := primitive.NewObjectID()
+	fecha := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+
+	mt.Run("registra el diff", func(mt *mtest.T) {
+		s := NewTransaccionService(mt.DB)
+		mt.AddMockResponses(
+			mtest.CreateCursorResponse(0, "test.transacciones", mtest.FirstBatch, bson.D{
+				{Key: "_id", Value: id},
+				{Key: "usuarioId", Value: propietario},
+				{Key: "tipo", Value: "egreso"},
+				{Key: "categoriaId", Value: categoria},
+				{Key: "monto", Value: 10.0},
+				{Key: "moneda", Value: "USD"},
+				{Key: "fecha", Value: fecha},
+				{Key: "descripcion", Value: "Almuerzo"},
+				{Key: "montoBase", Value: money.MustParse("10")},
+				{Key: "monedaBase", Value: "USD"},
+				{Key: "tipoCambio", Value: money.OneRate},
+			}),
+			propietarioResponse(t, ""),
+			bson.D{{Key: "ok", Value: 1}, {Key: "n", Value: 1}, {Key: "nModified", Value: 1}},
+			mtest.CreateSuccessResponse(),
+		)
+
+		client := models.ClientInfo{UsuarioID: propietario, IP: "203.0.113.7", UserAgent: "curl/8.0"}
+		err := s.Update(context.Background(), &models.Transaccion{
+			ID:          id,
+			UsuarioID:   propietario,
+			Tipo:        "egreso",
+			CategoriaID: categoria,
+			Monto:       money.MustParse("12.5"),
+			Moneda:      "USD",
+			Fecha:       fecha,
+			Descripcion: "Almuerzo",
+		}, client)
+		require.NoError(t, err)
+
+		evt := mt.GetStartedEvent()
+		for evt != nil && evt.CommandName != "insert" {
+			evt = mt.GetStartedEvent()
+		}
+		require.NotNil(t, evt)
+		require.Equal(t, "audit_logs", evt.Command.Lookup("insert").StringValue())
+
+		doc := evt.Command.Lookup("documents").Array().Index(0).Value().Document()
+		assert.Equal(t, models.AuditTransaccionEditar, doc.Lookup("accion").StringValue())
+		assert.Equal(t, propietario, doc.Lookup("usuarioId").ObjectID())
+		assert.Equal(t, propietario, doc.Lookup("afectadoId").ObjectID())
+		assert.Equal(t, id, doc.Lookup("recursoId").ObjectID())
+		assert.Equal(t, "203.0.113.7", doc.Lookup("ip").StringValue())
+		assert.Equal(t, "curl/8.0", doc.Lookup("userAgent").StringValue())
+
+		antes, err := doc.Lookup("detalle", "antes").Document().Elements()
+		require.NoError(t, err)
+		require.Len(t, antes, 2)
+		assert.Equal(t, "10", doc.Lookup("detalle", "antes", "monto").Decimal128().String())
+		assert.Equal(t, "12.5", doc.Lookup("detalle", "despues", "monto").Decimal128().String())
+		assert.Equal(t, "12.5", doc.Lookup("detalle", "despues", "montoBase").Decimal128().String())
+	})
+}
+
+func TestAuditService_Search(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("filtros", func(mt *mtest.T) {
+		s := NewAuditService(mt.DB)
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "test.audit_logs", mtest.FirstBatch))
+
+		desde := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+		_, err := s.Search(context.Background(), models.AuditLogFilter{
+			UsuarioID: &propietario,
+			Accion:    models.AuditLogin,
+			Desde:     &desde,
+			Limite:    10000,
+		})
+		require.NoError(t, err)
+
+		evt := mt.GetStartedEvent()
+		require.Equal(t, "find", evt.CommandName)
+		assert.Equal(t, int64(auditLimiteMaximo), evt.Command.Lookup("limit").AsInt64())
+
+		// Las acciones del usuario y las que otros hicieron sobre su cuenta
+		filter := evt.Command.Lookup("filter").Document()
+		values, err := filter.Lookup("$or").Array().Values()
+		require.NoError(t, err)
+		require.Len(t, values, 2)
+		assert.Equal(t, propietario, values[0].Document().Lookup("usuarioId").ObjectID())
+		assert.Equal(t, propietario, values[1].Document().Lookup("afectadoId").ObjectID())
+		assert.Equal(t, models.AuditLogin, filter.Lookup("accion").StringValue())
+		assert.Equal(t, desde, filter.Lookup("createdAt", "$gte").Time().UTC())
+	})
+}
@@ -0,0 +1,159 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"time"
+
+	"control-financiero/internal/auth"
+	"control-financiero/internal/mailer"
+	"control-financiero/internal/models"
+)
+
+const (
+	verificationTokenExpiration = 24 * time.Hour
+	resetTokenExpiration        = time.Hour
+	mailTimeout                 = 30 * time.Second
+)
+
+// ForgotPassword envía un enlace para restablecer la contraseña. Responde
+// igual exista o no el correo, para no revelar qué cuentas están registradas.
+func (s *AuthService) ForgotPassword(ctx context.Context, email string, client models.ClientInfo) error {
+	usuario, err := s.userRepo.FindByEmail(ctx, email)
+	if err != nil {
+		return nil
+	}
+
+	if usuario.Estado == "suspended" {
+		return nil
+	}
+
+	token, err := s.newEmailToken(ctx, usuario, models.EmailTokenReset, resetTokenExpiration)
+	if err != nil {
+		return err
+	}
+
+	// El enlace apunta al frontend, que pide la nueva contraseña
+	link := s.cfg.AppURL + "/#reset=" + url.QueryEscape(token)
+	s.sendAsync(mailer.Message{
+		To:      usuario.Email,
+		Subject: "Restablecer tu contraseña",
+		Body: fmt.Sprintf("Hola %s,\n\nRecibimos una solicitud para restablecer tu contraseña. "+
+			"Abre este enlace para elegir una nueva (vale %d minutos):\n\n%s\n\n"+
+			"Si no fuiste tú, ignora este mensaje.\n",
+			usuario.Nombre, int(resetTokenExpiration.Minutes()), link),
+	})
+
+	s.audit.Record(ctx, client, &models.AuditLog{AfectadoID: objectIDPtr(usuario.ID), Accion: models.AuditPasswordSolicitud})
+	return nil
+}
+
+// ResetPassword cambia la contraseña con el token recibido por correo y
+// cierra todas las sesiones abiertas.
+func (s *AuthService) ResetPassword(ctx context.Context, req *models.ResetPasswordRequest, client models.ClientInfo) error {
+	token, err := s.emailTokenRepo.Consume(ctx, auth.HashRefreshToken(req.Token), models.EmailTokenReset)
+	if err != nil {
+		return errors.New("enlace inválido o expirado")
+	}
+
+	hashedPassword, err := auth.HashPassword(req.NewPassword)
+	if err != nil {
+		return err
+	}
+
+	if err := s.userRepo.UpdatePassword(ctx, token.UsuarioID, hashedPassword); err != nil {
+		return err
+	}
+
+	// Otros enlaces de restablecimiento pendientes dejan de servir
+	if err := s.emailTokenRepo.DeleteByUsuario(ctx, token.UsuarioID, models.EmailTokenReset); err != nil {
+		return err
+	}
+
+	// Quien recibió el enlace demostró ser el dueño del correo
+	if err := s.userRepo.MarkEmailVerificado(ctx, token.UsuarioID); err != nil {
+		return err
+	}
+
+	if err := s.refreshTokenRepo.RevokeAllByUsuario(ctx, token.UsuarioID); err != nil {
+		return err
+	}
+
+	s.audit.Record(ctx, client, &models.AuditLog{UsuarioID: objectIDPtr(token.UsuarioID), Accion: models.AuditPasswordReset})
+	return nil
+}
+
+// VerifyEmail marca el correo como verificado con el token del enlace.
+func (s *AuthService) VerifyEmail(ctx context.Context, tokenString string, client models.ClientInfo) error {
+	token, err := s.emailTokenRepo.Consume(ctx, auth.HashRefreshToken(tokenString), models.EmailTokenVerificacion)
+	if err != nil {
+		return errors.New("enlace inválido o expirado")
+	}
+
+	if err := s.userRepo.MarkEmailVerificado(ctx, token.UsuarioID); err != nil {
+		return err
+	}
+
+	s.audit.Record(ctx, client, &models.AuditLog{UsuarioID: objectIDPtr(token.UsuarioID), Accion: models.AuditEmailVerificar})
+	return nil
+}
+
+func (s *AuthService) sendVerificationEmail(ctx context.Context, usuario *models.Usuario) error {
+	token, err := s.newEmailToken(ctx, usuario, models.EmailTokenVerificacion, verificationTokenExpiration)
+	if err != nil {
+		return err
+	}
+
+	link := s.cfg.AppURL + "/api/v1/auth/verify-email?token=" + url.QueryEscape(token)
+	s.sendAsync(mailer.Message{
+		To:      usuario.Email,
+		Subject: "Verifica tu correo",
+		Body: fmt.Sprintf("Hola %s,\n\nGracias por registrarte en Control Financiero. "+
+			"Confirma tu correo abriendo este enlace (vale %d horas):\n\n%s\n\n"+
+			"Un administrador aprobará tu cuenta después de la verificación.\n",
+			usuario.Nombre, int(verificationTokenExpiration.Hours()), link),
+	})
+
+	return nil
+}
+
+// newEmailToken genera un token de un solo uso e invalida los anteriores del
+// mismo tipo.
+func (s *AuthService) newEmailToken(ctx context.Context, usuario *models.Usuario, tipo string, ttl time.Duration) (string, error) {
+	token, err := auth.GenerateRefreshToken()
+	if err != nil {
+		return "", err
+	}
+
+	if err := s.emailTokenRepo.DeleteByUsuario(ctx, usuario.ID, tipo); err != nil {
+		return "", err
+	}
+
+	emailToken := &models.EmailToken{
+		TokenHash: auth.HashRefreshToken(token),
+		UsuarioID: usuario.ID,
+		Tipo:      tipo,
+		ExpiresAt: time.Now().Add(ttl),
+	}
+	if err := s.emailTokenRepo.Create(ctx, emailToken); err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+// sendAsync envía el correo sin bloquear la respuesta: el tiempo del servidor
+// SMTP no debe delatar si la cuenta existe.
+func (s *AuthService) sendAsync(msg mailer.Message) {
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), mailTimeout)
+		defer cancel()
+
+		if err := s.mailer.Send(ctx, msg); err != nil {
+			log.Printf("❌ Error enviando correo a %s: %v\n", msg.To, err)
+		}
+	}()
+}
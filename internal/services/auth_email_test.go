@@ -0,0 +1,106 @@
+package services
+
+import (
+	"context"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"control-financiero/internal/auth"
+	"control-financiero/internal/config"
+	"control-financiero/internal/mailer"
+	"control-financiero/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+type fakeMailer struct {
+	sent chan mailer.Message
+}
+
+func (m *fakeMailer) Send(ctx context.Context, msg mailer.Message) error {
+	m.sent <- msg
+	return nil
+}
+
+func newAuthServiceConMailer(mt *mtest.T) (*AuthService, *fakeMailer) {
+	s := NewAuthService(mt.DB, &config.Config{AppURL: "http://app.test", JWTSecret: "secret"})
+	m := &fakeMailer{sent: make(chan mailer.Message, 1)}
+	s.mailer = m
+	return s, m
+}
+
+func TestForgotPassword(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	ctx := context.Background()
+
+	mt.Run("correo desconocido", func(mt *mtest.T) {
+		s, m := newAuthServiceConMailer(mt)
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "test.usuarios", mtest.FirstBatch))
+
+		require.NoError(t, s.ForgotPassword(ctx, "nadie@example.com", models.ClientInfo{}))
+		select {
+		case <-m.sent:
+			t.Fatal("no debe enviarse correo a una cuenta inexistente")
+		case <-time.After(50 * time.Millisecond):
+		}
+	})
+
+	mt.Run("envía enlace de un solo uso", func(mt *mtest.T) {
+		s, m := newAuthServiceConMailer(mt)
+		usuario := usuarioActivo(t)
+		mt.AddMockResponses(
+			mtest.CreateCursorResponse(0, "test.usuarios", mtest.FirstBatch, bsonDoc(t, usuario)),
+			bson.D{{Key: "ok", Value: 1}, {Key: "n", Value: 0}},
+			mtest.CreateSuccessResponse(),
+			mtest.CreateSuccessResponse(), // auditoría
+		)
+
+		require.NoError(t, s.ForgotPassword(ctx, usuario.Email, models.ClientInfo{}))
+
+		var msg mailer.Message
+		select {
+		case msg = <-m.sent:
+		case <-time.After(time.Second):
+			t.Fatal("no se envió el correo")
+		}
+		assert.Equal(t, usuario.Email, msg.To)
+
+		// El correo lleva el token y la base de datos solo su hash
+		i := strings.Index(msg.Body, "#reset=")
+		require.GreaterOrEqual(t, i, 0)
+		token, err := url.QueryUnescape(strings.Fields(msg.Body[i+len("#reset="):])[0])
+		require.NoError(t, err)
+
+		evt := mt.GetStartedEvent()
+		for evt != nil && evt.CommandName != "insert" {
+			evt = mt.GetStartedEvent()
+		}
+		require.NotNil(t, evt)
+		doc := evt.Command.Lookup("documents").Array().Index(0).Value().Document()
+		assert.Equal(t, auth.HashRefreshToken(token), doc.Lookup("tokenHash").StringValue())
+		assert.Equal(t, models.EmailTokenReset, doc.Lookup("tipo").StringValue())
+	})
+}
+
+func TestResetPassword_TokenInvalido(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("token", func(mt *mtest.T) {
+		s, _ := newAuthServiceConMailer(mt)
+		// findAndModify sin documento
+		mt.AddMockResponses(bson.D{{Key: "ok", Value: 1}, {Key: "value", Value: nil}})
+
+		err := s.ResetPassword(context.Background(), &models.ResetPasswordRequest{Token: "usado", NewPassword: "nueva123"}, models.ClientInfo{})
+		assert.EqualError(t, err, "enlace inválido o expirado")
+
+		evt := mt.GetStartedEvent()
+		require.Equal(t, "findAndModify", evt.CommandName)
+		assert.Equal(t, auth.HashRefreshToken("usado"), evt.Command.Lookup("query", "tokenHash").StringValue())
+		assert.Equal(t, models.EmailTokenReset, evt.Command.Lookup("query", "tipo").StringValue())
+	})
+}
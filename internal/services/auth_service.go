@@ -1,229 +1,544 @@
-package services
-
-import (
-	"context"
-	"errors"
-	"time"
-
-	"control-financiero/internal/auth"
-	"control-financiero/internal/config"
-	"control-financiero/internal/models"
-	"control-financiero/internal/repositories"
-
-	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo"
-)
-
-type AuthService struct {
-	userRepo         *repositories.UsuarioRepository
-	refreshTokenRepo *repositories.RefreshTokenRepository
-	cfg              *config.Config
-}
-
-func NewAuthService(db *mongo.Database, cfg *config.Config) *AuthService {
-	return &AuthService{
-		userRepo:         repositories.NewUsuarioRepository(db),
-		refreshTokenRepo: repositories.NewRefreshTokenRepository(db),
-		cfg:              cfg,
-	}
-}
-
-func (s *AuthService) Register(ctx context.Context, req *models.RegisterRequest) (*models.Usuario, error) {
-	// Verificar si el usuario ya existe
-	existing, _ := s.userRepo.FindByEmail(ctx, req.Email)
-	if existing != nil {
-		return nil, errors.New("el correo ya está registrado")
-	}
-
-	// Hash de la contraseña
-	hashedPassword, err := auth.HashPassword(req.Password)
-	if err != nil {
-		return nil, err
-	}
-
-	// Crear usuario
-	usuario := &models.Usuario{
-		Nombre:       req.Nombre,
-		Email:        req.Email,
-		PasswordHash: hashedPassword,
-		Rol:          "user",
-		Estado:       "pending",
-	}
-
-	if err := s.userRepo.Create(ctx, usuario); err != nil {
-		return nil, err
-	}
-
-	return usuario, nil
-}
-
-func (s *AuthService) Login(ctx context.Context, req *models.LoginRequest) (*models.LoginResponse, error) {
-	// Buscar usuario
-	usuario, err := s.userRepo.FindByEmail(ctx, req.Email)
-	if err != nil {
-		return nil, errors.New("credenciales inválidas")
-	}
-
-	// Verificar estado del usuario
-	if usuario.Estado != "active" {
-		return nil, errors.New("usuario no activo o pendiente de aprobación")
-	}
-
-	// Verificar contraseña
-	if !auth.CheckPassword(req.Password, usuario.PasswordHash) {
-		return nil, errors.New("credenciales inválidas")
-	}
-
-	// Generar tokens
-	accessToken, err := auth.GenerateJWT(usuario, s.cfg.JWTSecret, s.cfg.JWTExpiration)
-	if err != nil {
-		return nil, err
-	}
-
-	refreshToken, err := auth.GenerateRefreshToken()
-	if err != nil {
-		return nil, err
-	}
-
-	// Guardar refresh token
-	refreshDuration, _ := time.ParseDuration(s.cfg.RefreshExpiration)
-	rt := &models.RefreshToken{
-		UsuarioID: usuario.ID,
-		Token:     refreshToken,
-		ExpiresAt: time.Now().Add(refreshDuration),
-		Revoked:   false,
-	}
-
-	if err := s.refreshTokenRepo.Create(ctx, rt); err != nil {
-		return nil, err
-	}
-
-	// Ocultar password hash en la respuesta
-	usuario.PasswordHash = ""
-
-	return &models.LoginResponse{
-		AccessToken:  accessToken,
-		RefreshToken: refreshToken,
-		Usuario:      usuario,
-	}, nil
-}
-
-func (s *AuthService) LoginWithGoogle(ctx context.Context, googleID, email, nombre, foto string) (*models.LoginResponse, error) {
-	// Buscar usuario por Google ID
-	usuario, err := s.userRepo.FindByGoogleID(ctx, googleID)
-	if err != nil {
-		// Si no existe, buscar por email
-		usuario, err = s.userRepo.FindByEmail(ctx, email)
-		if err != nil {
-			// Crear nuevo usuario
-			usuario = &models.Usuario{
-				Nombre:   nombre,
-				Email:    email,
-				Foto:     foto,
-				GoogleID: googleID,
-				Rol:      "user",
-				Estado:   "pending",
-			}
-
-			if err := s.userRepo.Create(ctx, usuario); err != nil {
-				return nil, err
-			}
-		} else {
-			// Actualizar Google ID
-			usuario.GoogleID = googleID
-			if err := s.userRepo.Update(ctx, usuario); err != nil {
-				return nil, err
-			}
-		}
-	}
-
-	// Verificar estado
-	if usuario.Estado != "active" {
-		return nil, errors.New("usuario no activo o pendiente de aprobación")
-	}
-
-	// Generar tokens
-	accessToken, err := auth.GenerateJWT(usuario, s.cfg.JWTSecret, s.cfg.JWTExpiration)
-	if err != nil {
-		return nil, err
-	}
-
-	refreshToken, err := auth.GenerateRefreshToken()
-	if err != nil {
-		return nil, err
-	}
-
-	// Guardar refresh token
-	refreshDuration, _ := time.ParseDuration(s.cfg.RefreshExpiration)
-	rt := &models.RefreshToken{
-		UsuarioID: usuario.ID,
-		Token:     refreshToken,
-		ExpiresAt: time.Now().Add(refreshDuration),
-		Revoked:   false,
-	}
-
-	if err := s.refreshTokenRepo.Create(ctx, rt); err != nil {
-		return nil, err
-	}
-
-	usuario.PasswordHash = ""
-
-	return &models.LoginResponse{
-		AccessToken:  accessToken,
-		RefreshToken: refreshToken,
-		Usuario:      usuario,
-	}, nil
-}
-
-func (s *AuthService) RefreshToken(ctx context.Context, tokenString string) (string, error) {
-	// Buscar refresh token
-	rt, err := s.refreshTokenRepo.FindByToken(ctx, tokenString)
-	if err != nil {
-		return "", errors.New("refresh token inválido")
-	}
-
-	// Verificar expiración
-	if time.Now().After(rt.ExpiresAt) {
-		return "", errors.New("refresh token expirado")
-	}
-
-	// Buscar usuario
-	usuario, err := s.userRepo.FindByID(ctx, rt.UsuarioID)
-	if err != nil {
-		return "", err
-	}
-
-	// Verificar estado
-	if usuario.Estado != "active" {
-		return "", errors.New("usuario no activo")
-	}
-
-	// Generar nuevo access token
-	accessToken, err := auth.GenerateJWT(usuario, s.cfg.JWTSecret, s.cfg.JWTExpiration)
-	if err != nil {
-		return "", err
-	}
-
-	return accessToken, nil
-}
-
-func (s *AuthService) ChangePassword(ctx context.Context, usuarioID primitive.ObjectID, req *models.ChangePasswordRequest) error {
-	usuario, err := s.userRepo.FindByID(ctx, usuarioID)
-	if err != nil {
-		return err
-	}
-
-	// Verificar contraseña actual
-	if !auth.CheckPassword(req.CurrentPassword, usuario.PasswordHash) {
-		return errors.New("contraseña actual incorrecta")
-	}
-
-	// Hash nueva contraseña
-	hashedPassword, err := auth.HashPassword(req.NewPassword)
-	if err != nil {
-		return err
-	}
-
-	usuario.PasswordHash = hashedPassword
-	return s.userRepo.Update(ctx, usuario)
-}
+package services
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"control-financiero/internal/auth"
+	"control-financiero/internal/config"
+	"control-financiero/internal/mailer"
+	"control-financiero/internal/models"
+	"control-financiero/internal/repositories"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// oauthCodeExpiration es el tiempo que tiene el frontend para canjear el
+// código recibido tras el login con un proveedor OIDC.
+const oauthCodeExpiration = time.Minute
+
+// ErrInvalidCredentials es la única respuesta de un login fallido: no indica
+// si la cuenta existe ni en qué estado está.
+var ErrInvalidCredentials = errors.New("credenciales inválidas")
+
+// dummyPasswordHash se compara cuando el correo no existe, para que la
+// respuesta tarde lo mismo que con una contraseña incorrecta.
+const dummyPasswordHash = "$2a$14$0IYli2EMbDOBvCe4HzVxRuonClyy4MnvjiYDZDQwZ1VaZA5NZetk6"
+
+// Un challenge de 2FA vale unos minutos y admite pocos intentos.
+const (
+	loginChallengeExpiration  = 5 * time.Minute
+	loginChallengeMaxAttempts = 5
+)
+
+type AuthService struct {
+	userRepo         *repositories.UsuarioRepository
+	refreshTokenRepo *repositories.RefreshTokenRepository
+	oauthCodeRepo    *repositories.OAuthCodeRepository
+	challengeRepo    *repositories.LoginChallengeRepository
+	emailTokenRepo   *repositories.EmailTokenRepository
+	audit            *AuditService
+	twoFactor        *TwoFactorService
+	limiter          *attemptLimiter
+	mailer           mailer.Mailer
+	cfg              *config.Config
+}
+
+func NewAuthService(db *mongo.Database, cfg *config.Config) *AuthService {
+	return &AuthService{
+		userRepo:         repositories.NewUsuarioRepository(db),
+		refreshTokenRepo: repositories.NewRefreshTokenRepository(db),
+		oauthCodeRepo:    repositories.NewOAuthCodeRepository(db),
+		challengeRepo:    repositories.NewLoginChallengeRepository(db),
+		emailTokenRepo:   repositories.NewEmailTokenRepository(db),
+		audit:            NewAuditService(db),
+		twoFactor:        NewTwoFactorService(db),
+		limiter:          newAttemptLimiter(db),
+		mailer:           mailer.New(cfg),
+		cfg:              cfg,
+	}
+}
+
+func (s *AuthService) Register(ctx context.Context, req *models.RegisterRequest, client models.ClientInfo) (*models.Usuario, error) {
+	// Verificar si el usuario ya existe
+	existing, _ := s.userRepo.FindByEmail(ctx, req.Email)
+	if existing != nil {
+		return nil, errors.New("el correo ya está registrado")
+	}
+
+	// Hash de la contraseña
+	hashedPassword, err := auth.HashPassword(req.Password)
+	if err != nil {
+		return nil, err
+	}
+
+	// Crear usuario
+	usuario := &models.Usuario{
+		Nombre:       req.Nombre,
+		Email:        req.Email,
+		PasswordHash: hashedPassword,
+		Rol:          "user",
+		Estado:       "pending",
+	}
+
+	if err := s.userRepo.Create(ctx, usuario); err != nil {
+		return nil, err
+	}
+
+	s.audit.Record(ctx, client, &models.AuditLog{UsuarioID: objectIDPtr(usuario.ID), Accion: models.AuditRegistro})
+
+	// Un fallo al enviar el correo no invalida el registro
+	if err := s.sendVerificationEmail(ctx, usuario); err != nil {
+		log.Printf("❌ Error creando la verificación de %s: %v\n", usuario.Email, err)
+	}
+
+	return usuario, nil
+}
+
+// Login verifica las credenciales. Si el usuario tiene 2FA activado devuelve
+// un challenge en lugar de los tokens y el login se completa con LoginTwoFactor.
+// Los fallos se cuentan por cuenta y por IP; superado el límite devuelve un
+// RateLimitError.
+func (s *AuthService) Login(ctx context.Context, req *models.LoginRequest, client models.ClientInfo) (*models.LoginResponse, *models.LoginChallengeResponse, error) {
+	keys := []string{accountKey(req.Email), ipKey(client.IP)}
+	if err := s.limiter.Check(ctx, keys...); err != nil {
+		return nil, nil, err
+	}
+
+	// Buscar usuario
+	usuario, err := s.userRepo.FindByEmail(ctx, req.Email)
+	if err != nil {
+		if !errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, nil, err
+		}
+		auth.CheckPassword(req.Password, dummyPasswordHash)
+		s.audit.Record(ctx, client, &models.AuditLog{Accion: models.AuditLoginFallido, Detalle: bson.M{"email": req.Email}})
+		return nil, nil, s.registerFailure(ctx, keys, ErrInvalidCredentials)
+	}
+
+	// Contraseña y estado dan el mismo error para no revelar la cuenta
+	if !auth.CheckPassword(req.Password, usuario.PasswordHash) || usuario.Estado != "active" {
+		s.audit.Record(ctx, client, &models.AuditLog{
+			AfectadoID: objectIDPtr(usuario.ID),
+			Accion:     models.AuditLoginFallido,
+			Detalle:    bson.M{"email": req.Email, "estado": usuario.Estado},
+		})
+		return nil, nil, s.registerFailure(ctx, keys, ErrInvalidCredentials)
+	}
+
+	return s.completeLogin(ctx, usuario, client)
+}
+
+// registerFailure cuenta el fallo en las claves y devuelve cause.
+func (s *AuthService) registerFailure(ctx context.Context, keys []string, cause error) error {
+	if err := s.limiter.Fail(ctx, keys...); err != nil {
+		return err
+	}
+	return cause
+}
+
+// LoginTwoFactor completa el login con el challenge y un código TOTP o de
+// recuperación.
+func (s *AuthService) LoginTwoFactor(ctx context.Context, req *models.TwoFactorLoginRequest, client models.ClientInfo) (*models.LoginResponse, error) {
+	challenge, err := s.challengeRepo.RegisterAttempt(ctx, auth.HashRefreshToken(req.ChallengeToken), loginChallengeMaxAttempts)
+	if err != nil {
+		return nil, errors.New("challenge inválido o expirado")
+	}
+
+	usuario, err := s.userRepo.FindByID(ctx, challenge.UsuarioID)
+	if err != nil {
+		return nil, errors.New("challenge inválido o expirado")
+	}
+
+	if usuario.Estado != "active" {
+		return nil, errors.New("usuario no activo")
+	}
+
+	// Los códigos fallidos cuentan para el mismo límite que la contraseña
+	keys := []string{accountKey(usuario.Email), ipKey(client.IP)}
+	if err := s.limiter.Check(ctx, keys...); err != nil {
+		return nil, err
+	}
+
+	if err := s.twoFactor.Verify(ctx, usuario, req.Code); err != nil {
+		if errors.Is(err, ErrInvalidTwoFactorCode) {
+			s.audit.Record(ctx, client, &models.AuditLog{
+				AfectadoID: objectIDPtr(usuario.ID),
+				Accion:     models.AuditLoginFallido,
+				Detalle:    bson.M{"email": usuario.Email, "causa": "2fa"},
+			})
+			return nil, s.registerFailure(ctx, keys, err)
+		}
+		return nil, err
+	}
+
+	// El challenge solo sirve para una sesión
+	deleted, err := s.challengeRepo.Delete(ctx, challenge.ID)
+	if err != nil {
+		return nil, err
+	}
+	if !deleted {
+		return nil, errors.New("challenge inválido o expirado")
+	}
+
+	return s.newSession(ctx, usuario, client)
+}
+
+// completeLogin inicia la sesión de un usuario ya autenticado o, si tiene
+// 2FA, emite el challenge para el segundo paso.
+func (s *AuthService) completeLogin(ctx context.Context, usuario *models.Usuario, client models.ClientInfo) (*models.LoginResponse, *models.LoginChallengeResponse, error) {
+	if usuario.TwoFactorEnabled() {
+		challenge, err := s.newLoginChallenge(ctx, usuario)
+		return nil, challenge, err
+	}
+
+	response, err := s.newSession(ctx, usuario, client)
+	return response, nil, err
+}
+
+func (s *AuthService) newLoginChallenge(ctx context.Context, usuario *models.Usuario) (*models.LoginChallengeResponse, error) {
+	token, err := auth.GenerateRefreshToken()
+	if err != nil {
+		return nil, err
+	}
+
+	challenge := &models.LoginChallenge{
+		TokenHash: auth.HashRefreshToken(token),
+		UsuarioID: usuario.ID,
+		ExpiresAt: time.Now().Add(loginChallengeExpiration),
+	}
+	if err := s.challengeRepo.Create(ctx, challenge); err != nil {
+		return nil, err
+	}
+
+	return &models.LoginChallengeResponse{
+		TwoFactorRequired: true,
+		ChallengeToken:    token,
+		ExpiresAt:         challenge.ExpiresAt,
+	}, nil
+}
+
+func (s *AuthService) newSession(ctx context.Context, usuario *models.Usuario, client models.ClientInfo) (*models.LoginResponse, error) {
+	// Un login completo reinicia el contador de la cuenta
+	if err := s.limiter.Succeed(ctx, accountKey(usuario.Email)); err != nil {
+		return nil, err
+	}
+
+	// Generar tokens de una nueva sesión
+	tokens, err := s.issueTokens(ctx, usuario, nil, client)
+	if err != nil {
+		return nil, err
+	}
+
+	s.audit.Record(ctx, client, &models.AuditLog{UsuarioID: objectIDPtr(usuario.ID), Accion: models.AuditLogin})
+
+	// Ocultar password hash en la respuesta
+	usuario.PasswordHash = ""
+
+	return &models.LoginResponse{
+		AccessToken:  tokens.AccessToken,
+		RefreshToken: tokens.RefreshToken,
+		Usuario:      usuario,
+	}, nil
+}
+
+// LoginWithProvider busca o registra al usuario de la identidad OIDC y
+// devuelve un código de un solo uso que el frontend canjea por los tokens con
+// ExchangeCode.
+func (s *AuthService) LoginWithProvider(ctx context.Context, userInfo *auth.UserInfo, client models.ClientInfo) (string, error) {
+	identidad := models.Identidad{
+		Provider: userInfo.Provider,
+		Subject:  userInfo.Subject,
+		Email:    userInfo.Email,
+		LinkedAt: time.Now(),
+	}
+
+	// Buscar usuario por la identidad del proveedor
+	usuario, err := s.userRepo.FindByIdentidad(ctx, userInfo.Provider, userInfo.Subject)
+	if err != nil {
+		// Si no existe, buscar por email
+		usuario, err = s.userRepo.FindByEmail(ctx, userInfo.Email)
+		if err != nil {
+			// Crear nuevo usuario
+			usuario = &models.Usuario{
+				Nombre:          userInfo.Name,
+				Email:           userInfo.Email,
+				EmailVerificado: userInfo.Verified,
+				Foto:            userInfo.Picture,
+				Identidades:     []models.Identidad{identidad},
+				Rol:             "user",
+				Estado:          "pending",
+			}
+
+			if err := s.userRepo.Create(ctx, usuario); err != nil {
+				return "", err
+			}
+
+			s.audit.Record(ctx, client, &models.AuditLog{
+				UsuarioID: objectIDPtr(usuario.ID),
+				Accion:    models.AuditRegistro,
+				Detalle:   bson.M{"provider": userInfo.Provider},
+			})
+		} else {
+			// Solo se vincula una cuenta existente si el proveedor verificó el correo
+			if !userInfo.Verified {
+				return "", errors.New("el correo no está verificado por el proveedor")
+			}
+
+			if err := s.userRepo.AddIdentidad(ctx, usuario.ID, identidad); err != nil {
+				return "", err
+			}
+			if err := s.userRepo.MarkEmailVerificado(ctx, usuario.ID); err != nil {
+				return "", err
+			}
+
+			s.audit.Record(ctx, client, &models.AuditLog{
+				UsuarioID: objectIDPtr(usuario.ID),
+				Accion:    models.AuditIdentidadVincular,
+				Detalle:   bson.M{"provider": userInfo.Provider, "email": userInfo.Email},
+			})
+		}
+	}
+
+	// Verificar estado
+	if usuario.Estado != "active" {
+		return "", errors.New("usuario no activo o pendiente de aprobación")
+	}
+
+	code, err := auth.GenerateRefreshToken()
+	if err != nil {
+		return "", err
+	}
+
+	oauthCode := &models.OAuthCode{
+		CodeHash:  auth.HashRefreshToken(code),
+		UsuarioID: usuario.ID,
+		ExpiresAt: time.Now().Add(oauthCodeExpiration),
+	}
+	if err := s.oauthCodeRepo.Create(ctx, oauthCode); err != nil {
+		return "", err
+	}
+
+	return code, nil
+}
+
+// ExchangeCode canjea el código emitido por LoginWithProvider por una sesión.
+// El 2FA local también se exige tras el login con un proveedor OIDC.
+func (s *AuthService) ExchangeCode(ctx context.Context, code string, client models.ClientInfo) (*models.LoginResponse, *models.LoginChallengeResponse, error) {
+	oauthCode, err := s.oauthCodeRepo.Consume(ctx, auth.HashRefreshToken(code))
+	if err != nil {
+		return nil, nil, errors.New("código inválido o expirado")
+	}
+
+	usuario, err := s.userRepo.FindByID(ctx, oauthCode.UsuarioID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if usuario.Estado != "active" {
+		return nil, nil, errors.New("usuario no activo")
+	}
+
+	return s.completeLogin(ctx, usuario, client)
+}
+
+func (s *AuthService) RefreshToken(ctx context.Context, tokenString string, client models.ClientInfo) (*models.RefreshResponse, error) {
+	keys := []string{ipKey(client.IP)}
+	if err := s.limiter.Check(ctx, keys...); err != nil {
+		return nil, err
+	}
+
+	// Buscar refresh token por su hash
+	rt, err := s.refreshTokenRepo.FindByHash(ctx, auth.HashRefreshToken(tokenString))
+	if err != nil {
+		return nil, s.registerFailure(ctx, keys, errors.New("refresh token inválido"))
+	}
+
+	// Un token revocado que vuelve a presentarse indica que fue robado
+	if rt.Revoked {
+		s.handleTokenReuse(ctx, rt, client)
+		return nil, s.registerFailure(ctx, keys, errors.New("refresh token inválido"))
+	}
+
+	// Verificar expiración
+	if time.Now().After(rt.ExpiresAt) {
+		return nil, s.registerFailure(ctx, keys, errors.New("refresh token expirado"))
+	}
+
+	// Buscar usuario
+	usuario, err := s.userRepo.FindByID(ctx, rt.UsuarioID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Verificar estado
+	if usuario.Estado != "active" {
+		return nil, errors.New("usuario no activo")
+	}
+
+	// Emitir un nuevo par de tokens dentro de la misma familia
+	tokens, err := s.issueTokens(ctx, usuario, rt, client)
+	if err != nil {
+		return nil, err
+	}
+
+	// Revocar el token usado; si otra petición lo rotó antes, es reutilización
+	rotated, err := s.refreshTokenRepo.MarkRotated(ctx, rt.ID, tokens.refreshTokenID)
+	if err != nil {
+		return nil, err
+	}
+	if !rotated {
+		s.handleTokenReuse(ctx, rt, client)
+		return nil, errors.New("refresh token inválido")
+	}
+
+	return &models.RefreshResponse{
+		AccessToken:  tokens.AccessToken,
+		RefreshToken: tokens.RefreshToken,
+	}, nil
+}
+
+func (s *AuthService) ChangePassword(ctx context.Context, usuarioID primitive.ObjectID, req *models.ChangePasswordRequest, client models.ClientInfo) error {
+	keys := []string{userKey(usuarioID), ipKey(client.IP)}
+	if err := s.limiter.Check(ctx, keys...); err != nil {
+		return err
+	}
+
+	usuario, err := s.userRepo.FindByID(ctx, usuarioID)
+	if err != nil {
+		return err
+	}
+
+	// Verificar contraseña actual
+	if !auth.CheckPassword(req.CurrentPassword, usuario.PasswordHash) {
+		return s.registerFailure(ctx, keys, errors.New("contraseña actual incorrecta"))
+	}
+
+	if err := s.limiter.Succeed(ctx, userKey(usuarioID)); err != nil {
+		return err
+	}
+
+	// Hash nueva contraseña
+	hashedPassword, err := auth.HashPassword(req.NewPassword)
+	if err != nil {
+		return err
+	}
+
+	usuario.PasswordHash = hashedPassword
+	if err := s.userRepo.Update(ctx, usuario); err != nil {
+		return err
+	}
+
+	// Cerrar todas las sesiones abiertas con la contraseña anterior
+	if err := s.refreshTokenRepo.RevokeAllByUsuario(ctx, usuarioID); err != nil {
+		return err
+	}
+
+	s.audit.Record(ctx, client, &models.AuditLog{UsuarioID: objectIDPtr(usuarioID), Accion: models.AuditPasswordCambiar})
+	return nil
+}
+
+func (s *AuthService) Logout(ctx context.Context, tokenString string, client models.ClientInfo) error {
+	rt, err := s.refreshTokenRepo.FindByHash(ctx, auth.HashRefreshToken(tokenString))
+	if err != nil {
+		// Token desconocido: no hay sesión que cerrar
+		return nil
+	}
+
+	if err := s.refreshTokenRepo.RevokeFamily(ctx, rt.FamilyID); err != nil {
+		return err
+	}
+
+	s.audit.Record(ctx, client, &models.AuditLog{
+		UsuarioID: objectIDPtr(rt.UsuarioID),
+		Accion:    models.AuditLogout,
+		Recurso:   "sesion",
+		RecursoID: objectIDPtr(rt.FamilyID),
+	})
+	return nil
+}
+
+func (s *AuthService) LogoutAll(ctx context.Context, usuarioID primitive.ObjectID, client models.ClientInfo) error {
+	if err := s.refreshTokenRepo.RevokeAllByUsuario(ctx, usuarioID); err != nil {
+		return err
+	}
+
+	s.audit.Record(ctx, client, &models.AuditLog{UsuarioID: objectIDPtr(usuarioID), Accion: models.AuditLogoutTodas})
+	return nil
+}
+
+type issuedTokens struct {
+	models.RefreshResponse
+	refreshTokenID primitive.ObjectID
+}
+
+// issueTokens genera un access token y un refresh token para el usuario. Sin
+// token previo se abre una sesión nueva; en otro caso el refresh token se
+// agrega a la familia del anterior y conserva el inicio de la sesión.
+func (s *AuthService) issueTokens(ctx context.Context, usuario *models.Usuario, prev *models.RefreshToken, client models.ClientInfo) (*issuedTokens, error) {
+	accessToken, err := auth.GenerateJWT(usuario, s.cfg.JWTKeys, s.cfg.JWTExpiration)
+	if err != nil {
+		return nil, err
+	}
+
+	refreshToken, err := auth.GenerateRefreshToken()
+	if err != nil {
+		return nil, err
+	}
+
+	// Guardar solo el hash del refresh token
+	now := time.Now()
+	refreshDuration, _ := time.ParseDuration(s.cfg.RefreshExpiration)
+	rt := &models.RefreshToken{
+		UsuarioID:   usuario.ID,
+		TokenHash:   auth.HashRefreshToken(refreshToken),
+		Dispositivo: describeDevice(client.UserAgent),
+		IP:          client.IP,
+		UserAgent:   client.UserAgent,
+		StartedAt:   now,
+		LastUsedAt:  now,
+		ExpiresAt:   now.Add(refreshDuration),
+		Revoked:     false,
+	}
+	if prev != nil {
+		rt.FamilyID = prev.FamilyID
+		rt.StartedAt = prev.StartedAt
+	}
+
+	if err := s.refreshTokenRepo.Create(ctx, rt); err != nil {
+		return nil, err
+	}
+
+	return &issuedTokens{
+		RefreshResponse: models.RefreshResponse{
+			AccessToken:  accessToken,
+			RefreshToken: refreshToken,
+		},
+		refreshTokenID: rt.ID,
+	}, nil
+}
+
+// handleTokenReuse revoca toda la familia del token reutilizado y deja
+// constancia en el registro de auditoría.
+func (s *AuthService) handleTokenReuse(ctx context.Context, rt *models.RefreshToken, client models.ClientInfo) {
+	if err := s.refreshTokenRepo.RevokeFamily(ctx, rt.FamilyID); err != nil {
+		log.Println("Error revocando familia de refresh tokens:", err)
+	}
+
+	log.Printf("⚠️  Reutilización de refresh token detectada (usuario %s, familia %s)\n", rt.UsuarioID.Hex(), rt.FamilyID.Hex())
+
+	s.audit.Record(ctx, client, &models.AuditLog{
+		UsuarioID: objectIDPtr(rt.UsuarioID),
+		Accion:    models.AuditRefreshTokenReuse,
+		Recurso:   "sesion",
+		RecursoID: objectIDPtr(rt.FamilyID),
+		Detalle: bson.M{
+			"tokenId":  rt.ID,
+			"familyId": rt.FamilyID,
+		},
+	})
+}
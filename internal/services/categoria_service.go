@@ -1,41 +1,101 @@
-package services
-
-import (
-	"context"
-
-	"control-financiero/internal/models"
-	"control-financiero/internal/repositories"
-
-	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo"
-)
-
-type CategoriaService struct {
-	categoriaRepo *repositories.CategoriaRepository
-}
-
-func NewCategoriaService(db *mongo.Database) *CategoriaService {
-	return &CategoriaService{
-		categoriaRepo: repositories.NewCategoriaRepository(db),
-	}
-}
-
-func (s *CategoriaService) Create(ctx context.Context, categoria *models.Categoria) error {
-	return s.categoriaRepo.Create(ctx, categoria)
-}
-
-func (s *CategoriaService) GetAll(ctx context.Context, usuarioID *primitive.ObjectID) ([]*models.Categoria, error) {
-	return s.categoriaRepo.FindAll(ctx, usuarioID)
-}
-
-func (s *CategoriaService) GetByID(ctx context.Context, id primitive.ObjectID) (*models.Categoria, error) {
-	return s.categoriaRepo.FindByID(ctx, id)
-}
-
-func (s *CategoriaService) Update(ctx context.Context, categoria *models.Categoria) error {
-	return s.categoriaRepo.Update(ctx, categoria)
-}
-
-func (s *CategoriaService) Delete(ctx context.Context, id primitive.ObjectID) error {
-	return s.categoriaRepo.Delete(ctx, id)
-}
+package services
+
+import (
+	"context"
+
+	"control-financiero/internal/models"
+	"control-financiero/internal/repositories"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type CategoriaService struct {
+	categoriaRepo *repositories.CategoriaRepository
+	audit         *AuditService
+}
+
+func NewCategoriaService(db *mongo.Database) *CategoriaService {
+	return &CategoriaService{
+		categoriaRepo: repositories.NewCategoriaRepository(db),
+		audit:         NewAuditService(db),
+	}
+}
+
+func (s *CategoriaService) Create(ctx context.Context, categoria *models.Categoria, client models.ClientInfo) error {
+	if err := s.categoriaRepo.Create(ctx, categoria); err != nil {
+		return err
+	}
+
+	s.audit.Record(ctx, client, s.auditEntry(models.AuditCategoriaCrear, categoria.ID, nil, categoria))
+	return nil
+}
+
+func (s *CategoriaService) GetAll(ctx context.Context, usuarioID *primitive.ObjectID) ([]*models.Categoria, error) {
+	return s.categoriaRepo.FindAll(ctx, usuarioID)
+}
+
+func (s *CategoriaService) GetByID(ctx context.Context, id, usuarioID primitive.ObjectID) (*models.Categoria, error) {
+	categoria, err := s.categoriaRepo.FindByID(ctx, id, usuarioID)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return categoria, nil
+}
+
+// Update modifica una categoría propia del usuario. Las categorías globales
+// son de solo lectura salvo con el permiso categorias:global.
+func (s *CategoriaService) Update(ctx context.Context, categoria *models.Categoria, usuarioID primitive.ObjectID, permisoGlobal bool, client models.ClientInfo) error {
+	existing, err := s.editable(ctx, categoria.ID, usuarioID, permisoGlobal)
+	if err != nil {
+		return err
+	}
+
+	// El propietario y la fecha de creación no se pueden cambiar
+	categoria.UsuarioID = existing.UsuarioID
+	categoria.CreatedAt = existing.CreatedAt
+
+	if err := s.categoriaRepo.Update(ctx, categoria); err != nil {
+		return notFound(err)
+	}
+
+	s.audit.Record(ctx, client, s.auditEntry(models.AuditCategoriaEditar, categoria.ID, existing, categoria))
+	return nil
+}
+
+func (s *CategoriaService) Delete(ctx context.Context, id, usuarioID primitive.ObjectID, permisoGlobal bool, client models.ClientInfo) error {
+	existing, err := s.editable(ctx, id, usuarioID, permisoGlobal)
+	if err != nil {
+		return err
+	}
+
+	if err := s.categoriaRepo.Delete(ctx, id, existing.UsuarioID); err != nil {
+		return notFound(err)
+	}
+
+	s.audit.Record(ctx, client, s.auditEntry(models.AuditCategoriaEliminar, id, existing, nil))
+	return nil
+}
+
+func (s *CategoriaService) auditEntry(accion string, id primitive.ObjectID, before, after *models.Categoria) *models.AuditLog {
+	return &models.AuditLog{
+		Accion:    accion,
+		Recurso:   "categoria",
+		RecursoID: objectIDPtr(id),
+		Detalle:   auditDiff(before, after),
+	}
+}
+
+// editable devuelve la categoría si el usuario puede modificarla.
+func (s *CategoriaService) editable(ctx context.Context, id, usuarioID primitive.ObjectID, permisoGlobal bool) (*models.Categoria, error) {
+	existing, err := s.GetByID(ctx, id, usuarioID)
+	if err != nil {
+		return nil, err
+	}
+
+	if existing.UsuarioID == nil && !permisoGlobal {
+		return nil, ErrForbidden
+	}
+
+	return existing, nil
+}
@@ -0,0 +1,334 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"control-financiero/internal/models"
+	"control-financiero/internal/money"
+	"control-financiero/internal/repositories"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Porcentaje máximo de una indexación de la renta
+const indexacionMaxima = 1000
+
+// ErrInvalidContrato envuelve los errores de validación de un contrato de
+// alquiler y de los cobros que se enlazan con él.
+var ErrInvalidContrato = errors.New("contrato inválido")
+
+// ErrContratoEnUso se devuelve al eliminar un contrato con cobros enlazados o
+// al cambiar su moneda.
+var ErrContratoEnUso = errors.New("el contrato tiene cobros enlazados")
+
+// ErrUnidadOcupada se devuelve cuando el contrato se solapa con otro de la
+// misma unidad, o de la propiedad entera, en las mismas fechas.
+var ErrUnidadOcupada = errors.New("la unidad ya está alquilada en esas fechas")
+
+type ContratoService struct {
+	contratoRepo    *repositories.ContratoRepository
+	propiedadRepo   *repositories.PropiedadRepository
+	inquilinoRepo   *repositories.InquilinoRepository
+	transaccionRepo *repositories.TransaccionRepository
+	userRepo        *repositories.UsuarioRepository
+	audit           *AuditService
+}
+
+func NewContratoService(db *mongo.Database) *ContratoService {
+	return &ContratoService{
+		contratoRepo:    repositories.NewContratoRepository(db),
+		propiedadRepo:   repositories.NewPropiedadRepository(db),
+		inquilinoRepo:   repositories.NewInquilinoRepository(db),
+		transaccionRepo: repositories.NewTransaccionRepository(db),
+		userRepo:        repositories.NewUsuarioRepository(db),
+		audit:           NewAuditService(db),
+	}
+}
+
+// Create crea un contrato del usuario indicado en contrato.UsuarioID.
+func (s *ContratoService) Create(ctx context.Context, contrato *models.Contrato, client models.ClientInfo) error {
+	if err := s.prepare(ctx, contrato); err != nil {
+		return err
+	}
+
+	if err := s.contratoRepo.Create(ctx, contrato); err != nil {
+		return err
+	}
+
+	resumirContrato(contrato, 0, truncateDia(time.Now()))
+	s.audit.Record(ctx, client, s.auditEntry(models.AuditContratoCrear, contrato.ID, nil, contrato))
+	return nil
+}
+
+// GetAll devuelve los contratos del usuario con sus cargos y lo cobrado.
+// propiedadID e inquilinoID, si no son nil, filtran por propiedad e
+// inquilino.
+func (s *ContratoService) GetAll(ctx context.Context, usuarioID primitive.ObjectID, propiedadID, inquilinoID *primitive.ObjectID) ([]*models.Contrato, error) {
+	contratos, err := s.contratoRepo.Find(ctx, usuarioID, propiedadID, inquilinoID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.resumir(ctx, usuarioID, contratos...); err != nil {
+		return nil, err
+	}
+	return contratos, nil
+}
+
+// GetByID devuelve un contrato del usuario con sus cargos y lo cobrado.
+func (s *ContratoService) GetByID(ctx context.Context, id, usuarioID primitive.ObjectID) (*models.Contrato, error) {
+	contrato, err := s.contratoRepo.FindByID(ctx, id, usuarioID)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	if err := s.resumir(ctx, usuarioID, contrato); err != nil {
+		return nil, err
+	}
+	return contrato, nil
+}
+
+// Update modifica un contrato del usuario indicado en contrato.UsuarioID.
+// Los cargos se recalculan con las nuevas condiciones; la moneda no se puede
+// cambiar si ya hay cobros enlazados.
+func (s *ContratoService) Update(ctx context.Context, contrato *models.Contrato, client models.ClientInfo) error {
+	existing, err := s.GetByID(ctx, contrato.ID, contrato.UsuarioID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.prepare(ctx, contrato); err != nil {
+		return err
+	}
+	if contrato.Moneda != existing.Moneda {
+		n, err := s.transaccionRepo.CountByContrato(ctx, contrato.UsuarioID, contrato.ID)
+		if err != nil {
+			return err
+		}
+		if n > 0 {
+			return fmt.Errorf("%w: no se puede cambiar la moneda", ErrContratoEnUso)
+		}
+	}
+
+	// La fecha de creación no se puede cambiar
+	contrato.CreatedAt = existing.CreatedAt
+
+	if err := s.contratoRepo.Update(ctx, contrato); err != nil {
+		return notFound(err)
+	}
+
+	if err := s.resumir(ctx, contrato.UsuarioID, contrato); err != nil {
+		return err
+	}
+	s.audit.Record(ctx, client, s.auditEntry(models.AuditContratoEditar, contrato.ID, existing, contrato))
+	return nil
+}
+
+// Delete elimina un contrato sin cobros enlazados.
+func (s *ContratoService) Delete(ctx context.Context, id, usuarioID primitive.ObjectID, client models.ClientInfo) error {
+	existing, err := s.GetByID(ctx, id, usuarioID)
+	if err != nil {
+		return err
+	}
+
+	n, err := s.transaccionRepo.CountByContrato(ctx, usuarioID, id)
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		return fmt.Errorf("%w: elimine antes los cobros o indique la fecha de fin", ErrContratoEnUso)
+	}
+
+	if err := s.contratoRepo.Delete(ctx, id, usuarioID); err != nil {
+		return notFound(err)
+	}
+
+	s.audit.Record(ctx, client, s.auditEntry(models.AuditContratoEliminar, id, existing, nil))
+	return nil
+}
+
+func (s *ContratoService) auditEntry(accion string, id primitive.ObjectID, before, after *models.Contrato) *models.AuditLog {
+	return &models.AuditLog{
+		Accion:    accion,
+		Recurso:   "contrato",
+		RecursoID: objectIDPtr(id),
+		Detalle:   auditDiff(before, after),
+	}
+}
+
+// prepare valida la propiedad, la unidad, el inquilino y las condiciones del
+// contrato y redondea los montos a los decimales de su moneda. Sin moneda se
+// usa la moneda base.
+func (s *ContratoService) prepare(ctx context.Context, contrato *models.Contrato) error {
+	propiedad, err := s.propiedadRepo.FindByID(ctx, contrato.PropiedadID, contrato.UsuarioID)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return fmt.Errorf("%w: la propiedad no existe", ErrInvalidContrato)
+		}
+		return err
+	}
+	if contrato.UnidadID != nil {
+		existe := false
+		for _, u := range propiedad.Unidades {
+			existe = existe || u.ID == *contrato.UnidadID
+		}
+		if !existe {
+			return fmt.Errorf("%w: la unidad no es de la propiedad", ErrInvalidContrato)
+		}
+	}
+	if _, err := s.inquilinoRepo.FindByID(ctx, contrato.InquilinoID, contrato.UsuarioID); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return fmt.Errorf("%w: el inquilino no existe", ErrInvalidContrato)
+		}
+		return err
+	}
+
+	if contrato.Moneda == "" {
+		usuario, err := s.userRepo.FindByID(ctx, contrato.UsuarioID)
+		if err != nil {
+			return notFound(err)
+		}
+		contrato.Moneda = usuario.Moneda()
+	}
+	moneda, err := normalizeMoneda(contrato.Moneda)
+	if err != nil {
+		return err
+	}
+	contrato.Moneda = moneda
+	contrato.Renta = contrato.Renta.Round(moneda)
+	if contrato.Renta <= 0 {
+		return ErrInvalidMonto
+	}
+	contrato.Deposito = contrato.Deposito.Round(moneda)
+	if contrato.Deposito < 0 {
+		return ErrInvalidMonto
+	}
+
+	contrato.Inicio = truncateDia(contrato.Inicio)
+	if contrato.Fin != nil {
+		fin := truncateDia(*contrato.Fin)
+		if fin.Before(contrato.Inicio) {
+			return fmt.Errorf("%w: el fin es anterior al inicio", ErrInvalidContrato)
+		}
+		contrato.Fin = &fin
+	}
+	if i := contrato.Indexacion; i != nil && (i.Porcentaje <= 0 || i.Porcentaje > indexacionMaxima*money.OneRate) {
+		return fmt.Errorf("%w: la indexación debe estar entre 0 y %d %%", ErrInvalidContrato, indexacionMaxima)
+	}
+
+	otros, err := s.contratoRepo.Find(ctx, contrato.UsuarioID, &contrato.PropiedadID, nil)
+	if err != nil {
+		return err
+	}
+	for _, otro := range otros {
+		if otro.ID != contrato.ID && contratosSeSolapan(contrato, otro) {
+			return ErrUnidadOcupada
+		}
+	}
+	return nil
+}
+
+// resumir calcula los cargos y lo cobrado de los contratos, todos del mismo
+// usuario.
+func (s *ContratoService) resumir(ctx context.Context, usuarioID primitive.ObjectID, contratos ...*models.Contrato) error {
+	if len(contratos) == 0 {
+		return nil
+	}
+	ids := make([]primitive.ObjectID, len(contratos))
+	for i, c := range contratos {
+		ids[i] = c.ID
+	}
+
+	cobros, err := s.transaccionRepo.SumCobrosContratos(ctx, usuarioID, ids)
+	if err != nil {
+		return err
+	}
+
+	hoy := truncateDia(time.Now())
+	for _, c := range contratos {
+		resumirContrato(c, cobros[c.ID], hoy)
+	}
+	return nil
+}
+
+// resumirContrato genera los cargos del contrato hasta el mes de hoy y les
+// aplica lo cobrado por orden de vencimiento. Lo que sobra queda como saldo a
+// favor del inquilino, y los cargos impagos con vencimiento anterior a hoy
+// como deuda.
+func resumirContrato(contrato *models.Contrato, cobrado money.Amount, hoy time.Time) {
+	contrato.Cargos = cargosContrato(contrato, hoy)
+	contrato.Cobrado = cobrado
+	contrato.Deuda, contrato.CargosVencidos = 0, 0
+
+	restante := cobrado
+	for i := range contrato.Cargos {
+		cargo := &contrato.Cargos[i]
+		cargo.Pagado = min(restante, cargo.Monto)
+		restante -= cargo.Pagado
+
+		switch {
+		case cargo.Pagado >= cargo.Monto:
+			cargo.Estado = models.CargoPagado
+		case cargo.Vencimiento.Before(hoy):
+			cargo.Estado = models.CargoVencido
+			contrato.CargosVencidos++
+			contrato.Deuda += cargo.Monto - cargo.Pagado
+		case cargo.Pagado > 0:
+			cargo.Estado = models.CargoParcial
+		default:
+			cargo.Estado = models.CargoPendiente
+		}
+	}
+	contrato.SaldoAFavor = restante
+}
+
+// cargosContrato genera un cargo por cada mes del contrato, desde el de
+// Inicio hasta el de Fin o, si es anterior, el de hasta. Cada cargo vence el
+// DiaVencimiento de su mes, o el último día si el mes es más corto, y cobra
+// la renta completa aunque el contrato empiece o termine a mitad de mes. La
+// indexación se aplica cada Indexacion.Meses cargos sobre la renta anterior,
+// redondeando a los decimales de la moneda.
+func cargosContrato(contrato *models.Contrato, hasta time.Time) []models.CargoAlquiler {
+	ultimo := hasta
+	if contrato.Fin != nil && contrato.Fin.Before(ultimo) {
+		ultimo = *contrato.Fin
+	}
+	y, m, _ := contrato.Inicio.Date()
+	mes := time.Date(y, m, 1, 0, 0, 0, 0, time.UTC)
+	y, m, _ = ultimo.Date()
+	fin := time.Date(y, m, 1, 0, 0, 0, 0, time.UTC)
+
+	var factor *big.Rat
+	if contrato.Indexacion != nil {
+		// Porcentaje está en unidades de 10^-RateScale
+		factor = big.NewRat(int64(contrato.Indexacion.Porcentaje), 100*int64(money.OneRate))
+		factor.Add(factor, big.NewRat(1, 1))
+	}
+
+	cargos := []models.CargoAlquiler{}
+	renta := contrato.Renta
+	for n := 0; !mes.After(fin); n++ {
+		if factor != nil && n > 0 && n%contrato.Indexacion.Meses == 0 {
+			renta = redondearRat(new(big.Rat).Mul(new(big.Rat).SetInt64(int64(renta)), factor), contrato.Moneda)
+		}
+		cargos = append(cargos, models.CargoAlquiler{
+			Periodo:     mes.Format("2006-01"),
+			Vencimiento: mes.AddDate(0, 0, min(contrato.DiaVencimiento, mes.AddDate(0, 1, -1).Day())-1),
+			Monto:       renta,
+		})
+		mes = mes.AddDate(0, 1, 0)
+	}
+	return cargos
+}
+
+// contratosSeSolapan indica si dos contratos de la misma propiedad alquilan
+// la misma unidad en fechas comunes. Un contrato sin unidad ocupa todas.
+func contratosSeSolapan(a, b *models.Contrato) bool {
+	if a.UnidadID != nil && b.UnidadID != nil && *a.UnidadID != *b.UnidadID {
+		return false
+	}
+	return (b.Fin == nil || !a.Inicio.After(*b.Fin)) && (a.Fin == nil || !b.Inicio.After(*a.Fin))
+}
@@ -0,0 +1,249 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"control-financiero/internal/models"
+	"control-financiero/internal/money"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+func diaUTC(y int, m time.Month, d int) time.Time {
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+func TestCargosContrato(t *testing.T) {
+	contrato := &models.Contrato{
+		Renta:          money.MustParse("1000"),
+		Moneda:         "PEN",
+		DiaVencimiento: 31,
+		Inicio:         diaUTC(2025, 1, 15),
+		Indexacion:     &models.IndexacionContrato{Porcentaje: money.MustParseRate("3.5"), Meses: 12},
+	}
+
+	cargos := cargosContrato(contrato, diaUTC(2026, 2, 1))
+	require.Len(t, cargos, 14)
+	assert.Equal(t, "2025-01", cargos[0].Periodo)
+	assert.Equal(t, diaUTC(2025, 1, 31), cargos[0].Vencimiento)
+	// El 31 vence el último día de los meses más cortos
+	assert.Equal(t, diaUTC(2025, 2, 28), cargos[1].Vencimiento)
+	assert.Equal(t, diaUTC(2025, 4, 30), cargos[3].Vencimiento)
+	// A los 12 meses la renta sube un 3,5 %
+	assert.Equal(t, "1000", cargos[11].Monto.String())
+	assert.Equal(t, "1035", cargos[12].Monto.String())
+	assert.Equal(t, "2026-02", cargos[13].Periodo)
+
+	t.Run("la indexación se acumula y redondea", func(t *testing.T) {
+		c := *contrato
+		c.Renta = money.MustParse("999.99")
+		c.Indexacion = &models.IndexacionContrato{Porcentaje: money.MustParseRate("10"), Meses: 1}
+		cargos := cargosContrato(&c, diaUTC(2025, 3, 1))
+		require.Len(t, cargos, 3)
+		assert.Equal(t, "999.99", cargos[0].Monto.String())
+		assert.Equal(t, "1099.99", cargos[1].Monto.String())
+		assert.Equal(t, "1209.99", cargos[2].Monto.String())
+	})
+
+	t.Run("termina en el mes de fin", func(t *testing.T) {
+		c := *contrato
+		fin := diaUTC(2025, 3, 10)
+		c.Fin = &fin
+		assert.Len(t, cargosContrato(&c, diaUTC(2026, 1, 1)), 3)
+	})
+
+	t.Run("sin cargos antes del inicio", func(t *testing.T) {
+		assert.Empty(t, cargosContrato(contrato, diaUTC(2024, 12, 31)))
+	})
+}
+
+func TestResumirContrato(t *testing.T) {
+	contrato := &models.Contrato{
+		Renta:          money.MustParse("500"),
+		Moneda:         "PEN",
+		DiaVencimiento: 5,
+		Inicio:         diaUTC(2025, 1, 1),
+	}
+
+	t.Run("los cobros cancelan los cargos más antiguos", func(t *testing.T) {
+		resumirContrato(contrato, money.MustParse("700"), diaUTC(2025, 3, 10))
+		require.Len(t, contrato.Cargos, 3)
+		assert.Equal(t, models.CargoPagado, contrato.Cargos[0].Estado)
+		assert.Equal(t, models.CargoVencido, contrato.Cargos[1].Estado)
+		assert.Equal(t, "200", contrato.Cargos[1].Pagado.String())
+		assert.Equal(t, models.CargoVencido, contrato.Cargos[2].Estado)
+		assert.Equal(t, 2, contrato.CargosVencidos)
+		assert.Equal(t, "800", contrato.Deuda.String())
+		assert.Zero(t, contrato.SaldoAFavor)
+	})
+
+	t.Run("un cargo que aún no vence no es deuda", func(t *testing.T) {
+		resumirContrato(contrato, money.MustParse("1100"), diaUTC(2025, 3, 5))
+		assert.Equal(t, models.CargoPagado, contrato.Cargos[1].Estado)
+		assert.Equal(t, models.CargoParcial, contrato.Cargos[2].Estado)
+		assert.Zero(t, contrato.Deuda)
+	})
+
+	t.Run("lo cobrado de más queda a favor", func(t *testing.T) {
+		resumirContrato(contrato, money.MustParse("1600"), diaUTC(2025, 3, 10))
+		assert.Zero(t, contrato.Deuda)
+		assert.Equal(t, "100", contrato.SaldoAFavor.String())
+	})
+}
+
+func TestContratosSeSolapan(t *testing.T) {
+	unidadA, unidadB := primitive.NewObjectID(), primitive.NewObjectID()
+	fin := diaUTC(2025, 6, 30)
+	a := &models.Contrato{UnidadID: &unidadA, Inicio: diaUTC(2025, 1, 1), Fin: &fin}
+
+	assert.True(t, contratosSeSolapan(a, &models.Contrato{UnidadID: &unidadA, Inicio: diaUTC(2025, 6, 30)}))
+	assert.False(t, contratosSeSolapan(a, &models.Contrato{UnidadID: &unidadA, Inicio: diaUTC(2025, 7, 1)}))
+	assert.False(t, contratosSeSolapan(a, &models.Contrato{UnidadID: &unidadB, Inicio: diaUTC(2025, 1, 1)}))
+	// Un contrato sin unidad alquila la propiedad entera
+	assert.True(t, contratosSeSolapan(a, &models.Contrato{Inicio: diaUTC(2024, 1, 1)}))
+}
+
+func TestContrato_Create(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	ctx := context.Background()
+	unidad := models.UnidadPropiedad{ID: primitive.NewObjectID(), Nombre: "Depto 101"}
+	propiedad := &models.Propiedad{ID: primitive.NewObjectID(), UsuarioID: propietario, Nombre: "Edificio", Unidades: []models.UnidadPropiedad{unidad}}
+	inquilino := &models.Inquilino{ID: primitive.NewObjectID(), UsuarioID: propietario, Nombre: "Ana"}
+	nuevo := func() *models.Contrato {
+		return &models.Contrato{
+			UsuarioID:      propietario,
+			PropiedadID:    propiedad.ID,
+			UnidadID:       &unidad.ID,
+			InquilinoID:    inquilino.ID,
+			Renta:          money.MustParse("1200.005"),
+			Moneda:         "pen",
+			DiaVencimiento: 5,
+			Inicio:         time.Date(2025, 1, 1, 15, 0, 0, 0, time.UTC),
+		}
+	}
+
+	mt.Run("valida y redondea", func(mt *mtest.T) {
+		s := NewContratoService(mt.DB)
+		mt.AddMockResponses(
+			mtest.CreateCursorResponse(0, "test.propiedades", mtest.FirstBatch, bsonDoc(t, propiedad)),
+			mtest.CreateCursorResponse(0, "test.inquilinos", mtest.FirstBatch, bsonDoc(t, inquilino)),
+			mtest.CreateCursorResponse(0, "test.contratos", mtest.FirstBatch),
+			mtest.CreateSuccessResponse(),
+			mtest.CreateSuccessResponse(),
+		)
+
+		contrato := nuevo()
+		require.NoError(t, s.Create(ctx, contrato, models.ClientInfo{}))
+		assert.Equal(t, "PEN", contrato.Moneda)
+		assert.Equal(t, "1200.01", contrato.Renta.String())
+		assert.Equal(t, diaUTC(2025, 1, 1), contrato.Inicio)
+		assert.NotEmpty(t, contrato.Cargos)
+	})
+
+	mt.Run("la unidad debe ser de la propiedad", func(mt *mtest.T) {
+		s := NewContratoService(mt.DB)
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "test.propiedades", mtest.FirstBatch, bsonDoc(t, propiedad)))
+
+		contrato := nuevo()
+		otra := primitive.NewObjectID()
+		contrato.UnidadID = &otra
+		assert.ErrorIs(t, s.Create(ctx, contrato, models.ClientInfo{}), ErrInvalidContrato)
+	})
+
+	mt.Run("la unidad no se alquila dos veces", func(mt *mtest.T) {
+		s := NewContratoService(mt.DB)
+		vigente := nuevo()
+		vigente.ID = primitive.NewObjectID()
+		vigente.Moneda = "PEN"
+		mt.AddMockResponses(
+			mtest.CreateCursorResponse(0, "test.propiedades", mtest.FirstBatch, bsonDoc(t, propiedad)),
+			mtest.CreateCursorResponse(0, "test.inquilinos", mtest.FirstBatch, bsonDoc(t, inquilino)),
+			mtest.CreateCursorResponse(0, "test.contratos", mtest.FirstBatch, bsonDoc(t, vigente)),
+		)
+
+		assert.ErrorIs(t, s.Create(ctx, nuevo(), models.ClientInfo{}), ErrUnidadOcupada)
+	})
+}
+
+func TestPrepararPropiedad(t *testing.T) {
+	guardada := models.UnidadPropiedad{ID: primitive.NewObjectID(), Nombre: "Local"}
+	existing := &models.Propiedad{Nombre: "Casa", Unidades: []models.UnidadPropiedad{guardada}}
+
+	propiedad := &models.Propiedad{Nombre: " Casa ", Unidades: []models.UnidadPropiedad{guardada, {Nombre: "Altos"}}}
+	require.NoError(t, prepararPropiedad(propiedad, existing))
+	assert.Equal(t, "Casa", propiedad.Nombre)
+	assert.Equal(t, guardada.ID, propiedad.Unidades[0].ID)
+	assert.False(t, propiedad.Unidades[1].ID.IsZero())
+
+	ajena := &models.Propiedad{Nombre: "Casa", Unidades: []models.UnidadPropiedad{{ID: primitive.NewObjectID(), Nombre: "Local"}}}
+	assert.ErrorIs(t, prepararPropiedad(ajena, existing), ErrInvalidPropiedad)
+
+	repetida := &models.Propiedad{Nombre: "Casa", Unidades: []models.UnidadPropiedad{{Nombre: "Altos"}, {Nombre: "altos"}}}
+	assert.ErrorIs(t, prepararPropiedad(repetida, nil), ErrInvalidPropiedad)
+}
+
+func TestTransaccion_Alquiler(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	ctx := context.Background()
+	contrato := &models.Contrato{
+		ID:             primitive.NewObjectID(),
+		UsuarioID:      propietario,
+		PropiedadID:    primitive.NewObjectID(),
+		InquilinoID:    primitive.NewObjectID(),
+		Renta:          money.MustParse("800"),
+		Moneda:         "PEN",
+		DiaVencimiento: 1,
+		Inicio:         diaUTC(2025, 1, 1),
+	}
+	cobro := func() *models.Transaccion {
+		return &models.Transaccion{
+			UsuarioID: propietario,
+			Tipo:      "alquiler",
+			Monto:     money.MustParse("800"),
+			Fecha:     diaUTC(2025, 2, 1),
+			Contrato:  &models.EnlaceContrato{ID: contrato.ID},
+		}
+	}
+
+	mt.Run("el cobro entra en la cuenta y se asigna a la propiedad", func(mt *mtest.T) {
+		s := NewTransaccionService(mt.DB)
+		mt.AddMockResponses(
+			propietarioResponse(t, "PEN"),
+			mtest.CreateCursorResponse(0, "test.contratos", mtest.FirstBatch, bsonDoc(t, contrato)),
+			mtest.CreateSuccessResponse(),
+			mtest.CreateSuccessResponse(),
+		)
+
+		transaccion := cobro()
+		require.NoError(t, s.Create(ctx, transaccion, models.ClientInfo{}))
+		require.NotNil(t, transaccion.PropiedadID)
+		assert.Equal(t, contrato.PropiedadID, *transaccion.PropiedadID)
+		assert.Equal(t, "800", transaccion.Importe().String())
+	})
+
+	mt.Run("en la moneda del contrato", func(mt *mtest.T) {
+		s := NewTransaccionService(mt.DB)
+		mt.AddMockResponses(
+			propietarioResponse(t, "PEN"),
+			mtest.CreateCursorResponse(0, "test.contratos", mtest.FirstBatch, bsonDoc(t, contrato)),
+		)
+
+		transaccion := cobro()
+		transaccion.Moneda = "USD"
+		assert.ErrorIs(t, s.Create(ctx, transaccion, models.ClientInfo{}), ErrInvalidMoneda)
+	})
+
+	mt.Run("solo se enlazan transacciones de tipo alquiler", func(mt *mtest.T) {
+		s := NewTransaccionService(mt.DB)
+		mt.AddMockResponses(propietarioResponse(t, "PEN"))
+
+		transaccion := cobro()
+		transaccion.Tipo = "ingreso"
+		assert.ErrorIs(t, s.Create(ctx, transaccion, models.ClientInfo{}), ErrInvalidContrato)
+	})
+}
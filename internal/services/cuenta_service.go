@@ -0,0 +1,316 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+
+	"control-financiero/internal/models"
+	"control-financiero/internal/money"
+	"control-financiero/internal/repositories"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ErrInvalidCuenta se devuelve cuando una transacción referencia una cuenta
+// que no existe, es de otro usuario o está archivada.
+var ErrInvalidCuenta = errors.New("cuenta inválida o archivada")
+
+// ErrCuentaEnUso se devuelve al eliminar una cuenta con transacciones o al
+// cambiarle la moneda.
+var ErrCuentaEnUso = errors.New("la cuenta tiene transacciones; archívela en lugar de eliminarla")
+
+type CuentaService struct {
+	cuentaRepo      *repositories.CuentaRepository
+	transaccionRepo *repositories.TransaccionRepository
+	userRepo        *repositories.UsuarioRepository
+	audit           *AuditService
+}
+
+func NewCuentaService(db *mongo.Database) *CuentaService {
+	return &CuentaService{
+		cuentaRepo:      repositories.NewCuentaRepository(db),
+		transaccionRepo: repositories.NewTransaccionRepository(db),
+		userRepo:        repositories.NewUsuarioRepository(db),
+		audit:           NewAuditService(db),
+	}
+}
+
+// Create crea una cuenta del usuario indicado en cuenta.UsuarioID. Sin moneda
+// se usa la moneda base del usuario.
+func (s *CuentaService) Create(ctx context.Context, cuenta *models.Cuenta, client models.ClientInfo) error {
+	if cuenta.Moneda == "" {
+		usuario, err := s.userRepo.FindByID(ctx, cuenta.UsuarioID)
+		if err != nil {
+			return notFound(err)
+		}
+		cuenta.Moneda = usuario.Moneda()
+	}
+	if err := normalizeCuenta(cuenta); err != nil {
+		return err
+	}
+
+	if err := s.cuentaRepo.Create(ctx, cuenta); err != nil {
+		return err
+	}
+
+	cuenta.Saldo = cuenta.SaldoInicial
+	s.audit.Record(ctx, client, s.auditEntry(models.AuditCuentaCrear, cuenta.ID, nil, cuenta))
+	return nil
+}
+
+// GetAll devuelve las cuentas del usuario con su saldo actual.
+func (s *CuentaService) GetAll(ctx context.Context, usuarioID primitive.ObjectID, archivadas bool) ([]*models.Cuenta, error) {
+	cuentas, err := s.cuentaRepo.FindByUsuario(ctx, usuarioID, archivadas)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.saldos(ctx, usuarioID, cuentas...); err != nil {
+		return nil, err
+	}
+	return cuentas, nil
+}
+
+// GetByID devuelve una cuenta del usuario con su saldo actual.
+func (s *CuentaService) GetByID(ctx context.Context, id, usuarioID primitive.ObjectID) (*models.Cuenta, error) {
+	cuenta, err := s.cuentaRepo.FindByID(ctx, id, usuarioID)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	if err := s.saldos(ctx, usuarioID, cuenta); err != nil {
+		return nil, err
+	}
+	return cuenta, nil
+}
+
+// Update modifica una cuenta del usuario indicado en cuenta.UsuarioID. La
+// moneda solo se puede cambiar mientras la cuenta no tenga transacciones.
+func (s *CuentaService) Update(ctx context.Context, cuenta *models.Cuenta, client models.ClientInfo) error {
+	existing, err := s.GetByID(ctx, cuenta.ID, cuenta.UsuarioID)
+	if err != nil {
+		return err
+	}
+
+	if cuenta.Moneda == "" {
+		cuenta.Moneda = existing.Moneda
+	}
+	if err := normalizeCuenta(cuenta); err != nil {
+		return err
+	}
+	if cuenta.Moneda != existing.Moneda {
+		n, err := s.transaccionRepo.CountByCuenta(ctx, cuenta.UsuarioID, cuenta.ID)
+		if err != nil {
+			return err
+		}
+		if n > 0 {
+			return fmt.Errorf("%w: no se puede cambiar la moneda", ErrCuentaEnUso)
+		}
+	}
+
+	// La fecha de creación no se puede cambiar
+	cuenta.CreatedAt = existing.CreatedAt
+
+	if err := s.cuentaRepo.Update(ctx, cuenta); err != nil {
+		return notFound(err)
+	}
+
+	cuenta.Saldo = existing.Saldo - existing.SaldoInicial + cuenta.SaldoInicial
+	s.audit.Record(ctx, client, s.auditEntry(models.AuditCuentaEditar, cuenta.ID, existing, cuenta))
+	return nil
+}
+
+// Delete elimina una cuenta sin transacciones. Las que tienen historial se
+// archivan para no perderlo.
+func (s *CuentaService) Delete(ctx context.Context, id, usuarioID primitive.ObjectID, client models.ClientInfo) error {
+	existing, err := s.GetByID(ctx, id, usuarioID)
+	if err != nil {
+		return err
+	}
+
+	n, err := s.transaccionRepo.CountByCuenta(ctx, usuarioID, id)
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		return ErrCuentaEnUso
+	}
+
+	if err := s.cuentaRepo.Delete(ctx, id, usuarioID); err != nil {
+		return notFound(err)
+	}
+
+	s.audit.Record(ctx, client, s.auditEntry(models.AuditCuentaEliminar, id, existing, nil))
+	return nil
+}
+
+// Extracto devuelve una página de los movimientos de la cuenta en orden
+// cronológico con el saldo después de cada uno. cursor es el NextCursor de
+// la página anterior, o vacío para la primera.
+func (s *CuentaService) Extracto(ctx context.Context, id, usuarioID primitive.ObjectID, desde, hasta *time.Time, limite int64, cursor string) (*models.ExtractoCuenta, error) {
+	cuenta, err := s.GetByID(ctx, id, usuarioID)
+	if err != nil {
+		return nil, err
+	}
+
+	if limite <= 0 {
+		limite = transaccionesLimiteDefecto
+	}
+	if limite > transaccionesLimiteMaximo {
+		limite = transaccionesLimiteMaximo
+	}
+	filtro := &models.TransaccionFilter{
+		UsuarioID: usuarioID,
+		CuentaID:  &id,
+		Desde:     desde,
+		Hasta:     hasta,
+		Orden:     models.OrdenFechaAsc,
+		Limite:    limite + 1, // una de más para saber si hay otra página
+	}
+
+	// El saldo anterior es el inicial más todo lo que hay antes de la página
+	saldo := cuenta.SaldoInicial
+	var previo money.Amount
+	switch {
+	case cursor != "":
+		despues, err := decodeTransaccionCursor(cursor)
+		if err != nil || despues.Orden != filtro.Orden {
+			return nil, ErrInvalidCursor
+		}
+		filtro.Despues = despues
+		previo, err = s.transaccionRepo.SumImportesAntes(ctx, usuarioID, id, despues.Fecha, &despues.ID)
+		if err != nil {
+			return nil, err
+		}
+	case desde != nil:
+		previo, err = s.transaccionRepo.SumImportesAntes(ctx, usuarioID, id, *desde, nil)
+		if err != nil {
+			return nil, err
+		}
+	}
+	saldo += previo
+
+	transacciones, err := s.transaccionRepo.Find(ctx, filtro)
+	if err != nil {
+		return nil, err
+	}
+
+	extracto := &models.ExtractoCuenta{
+		Cuenta:        cuenta,
+		SaldoAnterior: saldo,
+		Movimientos:   make([]*models.MovimientoCuenta, 0, len(transacciones)),
+	}
+	if int64(len(transacciones)) > limite {
+		transacciones = transacciones[:limite]
+		last := transacciones[limite-1]
+		extracto.NextCursor, err = encodeTransaccionCursor(&models.TransaccionCursor{
+			Orden: filtro.Orden,
+			Fecha: last.Fecha,
+			ID:    last.ID,
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for _, t := range transacciones {
+		importe := t.Importe()
+		saldo += importe
+		extracto.Movimientos = append(extracto.Movimientos, &models.MovimientoCuenta{
+			Transaccion: t,
+			Importe:     importe,
+			Saldo:       saldo,
+		})
+	}
+
+	return extracto, nil
+}
+
+// Historial devuelve el saldo de la cuenta al final de cada día o mes
+// ("dia" o "mes") en el que tuvo movimientos.
+func (s *CuentaService) Historial(ctx context.Context, id, usuarioID primitive.ObjectID, desde, hasta *time.Time, intervalo string) ([]*models.SaldoPeriodo, error) {
+	cuenta, err := s.cuentaRepo.FindByID(ctx, id, usuarioID)
+	if err != nil {
+		return nil, notFound(err)
+	}
+
+	formato := "%Y-%m"
+	if intervalo == "dia" {
+		formato = "%Y-%m-%d"
+	}
+
+	saldo := cuenta.SaldoInicial
+	if desde != nil {
+		previo, err := s.transaccionRepo.SumImportesAntes(ctx, usuarioID, id, *desde, nil)
+		if err != nil {
+			return nil, err
+		}
+		saldo += previo
+	}
+
+	periodos, err := s.transaccionRepo.SumImportesPorPeriodo(ctx, usuarioID, id, desde, hasta, formato)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range periodos {
+		saldo += p.Neto
+		p.Saldo = saldo
+	}
+	return periodos, nil
+}
+
+func (s *CuentaService) auditEntry(accion string, id primitive.ObjectID, before, after *models.Cuenta) *models.AuditLog {
+	return &models.AuditLog{
+		Accion:    accion,
+		Recurso:   "cuenta",
+		RecursoID: objectIDPtr(id),
+		Detalle:   auditDiff(before, after),
+	}
+}
+
+// saldos calcula el saldo actual de las cuentas, todas del mismo usuario.
+func (s *CuentaService) saldos(ctx context.Context, usuarioID primitive.ObjectID, cuentas ...*models.Cuenta) error {
+	if len(cuentas) == 0 {
+		return nil
+	}
+	ids := make([]primitive.ObjectID, len(cuentas))
+	for i, c := range cuentas {
+		ids[i] = c.ID
+	}
+
+	saldos, err := s.transaccionRepo.SaldosPorCuenta(ctx, usuarioID, ids)
+	if err != nil {
+		return err
+	}
+	for _, c := range cuentas {
+		c.Saldo = c.SaldoInicial + saldos[c.ID]
+	}
+	return nil
+}
+
+// normalizeCuenta valida la moneda, redondea el saldo inicial a sus
+// decimales y normaliza el número de cuenta.
+func normalizeCuenta(cuenta *models.Cuenta) error {
+	moneda, err := normalizeMoneda(cuenta.Moneda)
+	if err != nil {
+		return err
+	}
+	cuenta.Moneda = moneda
+	cuenta.SaldoInicial = cuenta.SaldoInicial.Round(moneda)
+	cuenta.NumeroCuenta = normalizeNumeroCuenta(cuenta.NumeroCuenta)
+	return nil
+}
+
+// normalizeNumeroCuenta quita los espacios y guiones con que los bancos
+// escriben el número de una cuenta, para compararlo con el de los extractos.
+func normalizeNumeroCuenta(numero string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '-' || unicode.IsSpace(r) {
+			return -1
+		}
+		return unicode.ToUpper(r)
+	}, numero)
+}
@@ -0,0 +1,207 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"control-financiero/internal/models"
+	"control-financiero/internal/money"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/event"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+func cuentaDoc(id primitive.ObjectID, moneda, saldoInicial string, archivada bool) bson.D {
+	return bson.D{
+		{Key: "_id", Value: id},
+		{Key: "usuarioId", Value: propietario},
+		{Key: "nombre", Value: "Banco"},
+		{Key: "tipo", Value: "banco"},
+		{Key: "moneda", Value: moneda},
+		{Key: "saldoInicial", Value: money.MustParse(saldoInicial)},
+		{Key: "archivada", Value: archivada},
+	}
+}
+
+// sumaResponse responde a una agregación que devuelve un único total.
+func sumaResponse(t *testing.T, campo, valor string) bson.D {
+	d, err := primitive.ParseDecimal128(valor)
+	require.NoError(t, err)
+	return mtest.CreateCursorResponse(0, "test.transacciones", mtest.FirstBatch, bson.D{{Key: "_id", Value: nil}, {Key: campo, Value: d}})
+}
+
+// startedEvent devuelve el primer comando enviado que cumple match.
+func startedEvent(t *testing.T, mt *mtest.T, match func(cmd bson.Raw) bool) *event.CommandStartedEvent {
+	for evt := mt.GetStartedEvent(); evt != nil; evt = mt.GetStartedEvent() {
+		if match(evt.Command) {
+			return evt
+		}
+	}
+	require.FailNow(t, "no se envió el comando esperado")
+	return nil
+}
+
+func TestCuenta_Extracto(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	ctx := context.Background()
+	cuentaID := primitive.NewObjectID()
+	dia := func(d int) time.Time { return time.Date(2024, 5, d, 0, 0, 0, 0, time.UTC) }
+
+	movimiento := func(tipo string, d int, monto string) bson.D {
+		doc := transaccionDoc(dia(d), monto)
+		doc[2].Value = tipo
+		return append(doc, bson.E{Key: "cuentaId", Value: cuentaID})
+	}
+
+	mt.Run("saldo acumulado desde una fecha", func(mt *mtest.T) {
+		s := NewCuentaService(mt.DB)
+		mt.AddMockResponses(
+			mtest.CreateCursorResponse(0, "test.cuentas", mtest.FirstBatch, cuentaDoc(cuentaID, "PEN", "100", false)),
+			mtest.CreateCursorResponse(0, "test.transacciones", mtest.FirstBatch,
+				bson.D{{Key: "_id", Value: cuentaID}, {Key: "saldo", Value: money.MustParse("250")}}),
+			sumaResponse(t, "total", "50"),
+			mtest.CreateCursorResponse(0, "test.transacciones", mtest.FirstBatch,
+				movimiento("ingreso", 10, "200"),
+				movimiento("egreso", 12, "30.5"),
+				movimiento("prestamo", 13, "1000"),
+			),
+		)
+
+		desde := dia(10)
+		extracto, err := s.Extracto(ctx, cuentaID, propietario, &desde, nil, 2, "")
+		require.NoError(t, err)
+		assert.Equal(t, "350", extracto.Cuenta.Saldo.String())
+		assert.Equal(t, "150", extracto.SaldoAnterior.String())
+		require.Len(t, extracto.Movimientos, 2)
+		assert.Equal(t, "200", extracto.Movimientos[0].Importe.String())
+		assert.Equal(t, "350", extracto.Movimientos[0].Saldo.String())
+		assert.Equal(t, "-30.5", extracto.Movimientos[1].Importe.String())
+		assert.Equal(t, "319.5", extracto.Movimientos[1].Saldo.String())
+
+		cursor, err := decodeTransaccionCursor(extracto.NextCursor)
+		require.NoError(t, err)
+		assert.Equal(t, models.OrdenFechaAsc, cursor.Orden)
+		assert.Equal(t, extracto.Movimientos[1].ID, cursor.ID)
+
+		evt := startedEvent(t, mt, func(cmd bson.Raw) bool {
+			coleccion, _ := cmd.Lookup("find").StringValueOK()
+			return coleccion == "transacciones"
+		})
+		assert.Equal(t, cuentaID, evt.Command.Lookup("filter", "cuentaId").ObjectID())
+		assert.Equal(t, int32(1), evt.Command.Lookup("sort", "fecha").Int32())
+	})
+
+	mt.Run("la página siguiente parte del cursor", func(mt *mtest.T) {
+		s := NewCuentaService(mt.DB)
+		mt.AddMockResponses(
+			mtest.CreateCursorResponse(0, "test.cuentas", mtest.FirstBatch, cuentaDoc(cuentaID, "PEN", "100", false)),
+			mtest.CreateCursorResponse(0, "test.transacciones", mtest.FirstBatch),
+			sumaResponse(t, "total", "-20"),
+			mtest.CreateCursorResponse(0, "test.transacciones", mtest.FirstBatch, movimiento("egreso", 20, "5")),
+		)
+
+		ultimo := primitive.NewObjectID()
+		cursor, err := encodeTransaccionCursor(&models.TransaccionCursor{Orden: models.OrdenFechaAsc, Fecha: dia(12), ID: ultimo})
+		require.NoError(t, err)
+
+		extracto, err := s.Extracto(ctx, cuentaID, propietario, nil, nil, 0, cursor)
+		require.NoError(t, err)
+		assert.Equal(t, "80", extracto.SaldoAnterior.String())
+		require.Len(t, extracto.Movimientos, 1)
+		assert.Equal(t, "75", extracto.Movimientos[0].Saldo.String())
+		assert.Empty(t, extracto.NextCursor)
+
+		// El saldo anterior incluye la última transacción de la página previa
+		evt := startedEvent(t, mt, func(cmd bson.Raw) bool {
+			_, err := cmd.LookupErr("pipeline", "0", "$match", "$or")
+			return err == nil
+		})
+		or, err := evt.Command.Lookup("pipeline", "0", "$match", "$or").Array().Values()
+		require.NoError(t, err)
+		assert.Equal(t, ultimo, or[1].Document().Lookup("_id", "$lte").ObjectID())
+	})
+
+	mt.Run("cursor de otro orden", func(mt *mtest.T) {
+		s := NewCuentaService(mt.DB)
+		mt.AddMockResponses(
+			mtest.CreateCursorResponse(0, "test.cuentas", mtest.FirstBatch, cuentaDoc(cuentaID, "PEN", "0", false)),
+			mtest.CreateCursorResponse(0, "test.transacciones", mtest.FirstBatch),
+		)
+
+		cursor, err := encodeTransaccionCursor(&models.TransaccionCursor{Orden: models.OrdenFechaDesc, ID: primitive.NewObjectID()})
+		require.NoError(t, err)
+
+		_, err = s.Extracto(ctx, cuentaID, propietario, nil, nil, 0, cursor)
+		assert.ErrorIs(t, err, ErrInvalidCursor)
+	})
+}
+
+func TestCuenta_Historial(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	cuentaID := primitive.NewObjectID()
+
+	mt.Run("acumula los saldos por mes", func(mt *mtest.T) {
+		s := NewCuentaService(mt.DB)
+		periodo := func(p, neto string) bson.D {
+			return bson.D{{Key: "_id", Value: p}, {Key: "neto", Value: money.MustParse(neto)}}
+		}
+		mt.AddMockResponses(
+			mtest.CreateCursorResponse(0, "test.cuentas", mtest.FirstBatch, cuentaDoc(cuentaID, "PEN", "1000", false)),
+			sumaResponse(t, "total", "-100"),
+			mtest.CreateCursorResponse(0, "test.transacciones", mtest.FirstBatch,
+				periodo("2024-03", "250.5"),
+				periodo("2024-05", "-1200"),
+			),
+		)
+
+		desde := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+		historial, err := s.Historial(context.Background(), cuentaID, propietario, &desde, nil, "")
+		require.NoError(t, err)
+		require.Len(t, historial, 2)
+		assert.Equal(t, "2024-03", historial[0].Periodo)
+		assert.Equal(t, "1150.5", historial[0].Saldo.String())
+		assert.Equal(t, "-49.5", historial[1].Saldo.String())
+
+		evt := startedEvent(t, mt, func(cmd bson.Raw) bool {
+			_, err := cmd.LookupErr("pipeline", "1", "$group", "neto")
+			return err == nil
+		})
+		formato := evt.Command.Lookup("pipeline", "1", "$group", "_id", "$dateToString", "format")
+		assert.Equal(t, "%Y-%m", formato.StringValue())
+	})
+}
+
+func TestCuenta_Delete(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	cuentaID := primitive.NewObjectID()
+
+	mt.Run("con transacciones hay que archivarla", func(mt *mtest.T) {
+		s := NewCuentaService(mt.DB)
+		mt.AddMockResponses(
+			mtest.CreateCursorResponse(0, "test.cuentas", mtest.FirstBatch, cuentaDoc(cuentaID, "PEN", "0", false)),
+			mtest.CreateCursorResponse(0, "test.transacciones", mtest.FirstBatch),
+			countResponse(3),
+		)
+
+		err := s.Delete(context.Background(), cuentaID, propietario, models.ClientInfo{})
+		assert.ErrorIs(t, err, ErrCuentaEnUso)
+	})
+
+	mt.Run("sin transacciones se elimina", func(mt *mtest.T) {
+		s := NewCuentaService(mt.DB)
+		mt.AddMockResponses(
+			mtest.CreateCursorResponse(0, "test.cuentas", mtest.FirstBatch, cuentaDoc(cuentaID, "PEN", "0", false)),
+			mtest.CreateCursorResponse(0, "test.transacciones", mtest.FirstBatch),
+			countResponse(0),
+			bson.D{{Key: "ok", Value: 1}, {Key: "n", Value: 1}},
+			mtest.CreateSuccessResponse(),
+		)
+
+		require.NoError(t, s.Delete(context.Background(), cuentaID, propietario, models.ClientInfo{}))
+	})
+}
@@ -0,0 +1,25 @@
+package services
+
+import (
+	"errors"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var (
+	// ErrNotFound se devuelve cuando el recurso no existe o pertenece a otro
+	// usuario; ambos casos se tratan igual para no revelar IDs ajenos.
+	ErrNotFound = errors.New("recurso no encontrado")
+
+	// ErrForbidden se devuelve cuando el recurso es visible pero el usuario
+	// no puede modificarlo.
+	ErrForbidden = errors.New("no tiene permisos para modificar este recurso")
+)
+
+// notFound traduce la ausencia de documentos de MongoDB a ErrNotFound.
+func notFound(err error) error {
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return ErrNotFound
+	}
+	return err
+}
@@ -0,0 +1,282 @@
+package services
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"time"
+
+	"control-financiero/internal/models"
+	"control-financiero/internal/money"
+	"control-financiero/internal/repositories"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const exchangeRatesLimite = 1000
+
+// ErrInvalidMoneda se devuelve cuando una moneda no es un código ISO 4217
+// vigente.
+var ErrInvalidMoneda = errors.New("moneda inválida, use un código ISO 4217 como PEN o USD")
+
+// ErrInvalidExchangeRate envuelve los errores de validación de los tipos de
+// cambio que se cargan.
+var ErrInvalidExchangeRate = errors.New("tipo de cambio inválido")
+
+// ErrRateUnavailable lo devuelve un RateProvider que no conoce el tipo de
+// cambio pedido.
+var ErrRateUnavailable = errors.New("el proveedor no tiene ese tipo de cambio")
+
+// RateNotFoundError indica que no hay ningún tipo de cambio para convertir un
+// monto de Moneda a Base en Fecha.
+type RateNotFoundError struct {
+	Moneda string
+	Base   string
+	Fecha  time.Time
+}
+
+func (e *RateNotFoundError) Error() string {
+	return fmt.Sprintf("no hay tipo de cambio de %s a %s para el %s", e.Moneda, e.Base, e.Fecha.Format(time.DateOnly))
+}
+
+// RateProvider obtiene tipos de cambio de una fuente externa, por ejemplo la
+// API de un banco central.
+type RateProvider interface {
+	// Name identifica al proveedor en el campo fuente de lo que se guarda.
+	Name() string
+	// Rate devuelve cuántas unidades de base vale una unidad de moneda en
+	// fecha, o ErrRateUnavailable si el proveedor no lo sabe.
+	Rate(ctx context.Context, moneda, base string, fecha time.Time) (money.Rate, error)
+}
+
+// StaticRateProvider es un RateProvider con tipos de cambio fijos, sin
+// acceso a la red, para pruebas y desarrollo. Las claves son "MONEDA/BASE".
+type StaticRateProvider map[string]money.Rate
+
+func (p StaticRateProvider) Name() string {
+	return "static"
+}
+
+func (p StaticRateProvider) Rate(_ context.Context, moneda, base string, _ time.Time) (money.Rate, error) {
+	if tasa, ok := p[moneda+"/"+base]; ok {
+		return tasa, nil
+	}
+	return 0, ErrRateUnavailable
+}
+
+type ExchangeRateService struct {
+	rateRepo *repositories.ExchangeRateRepository
+	provider RateProvider // opcional; completa los días sin tipo de cambio
+	audit    *AuditService
+}
+
+func NewExchangeRateService(db *mongo.Database) *ExchangeRateService {
+	return &ExchangeRateService{
+		rateRepo: repositories.NewExchangeRateRepository(db),
+		audit:    NewAuditService(db),
+	}
+}
+
+// SetProvider configura el proveedor al que se piden los tipos de cambio que
+// faltan.
+func (s *ExchangeRateService) SetProvider(provider RateProvider) {
+	s.provider = provider
+}
+
+// Rate devuelve cuántas unidades de base vale una unidad de moneda en fecha.
+// Usa el tipo de cambio guardado más reciente hasta ese día, en cualquiera de
+// los dos sentidos. Si no hay uno del mismo día y hay proveedor, se le pide y
+// se guarda; si el proveedor falla se usa el último guardado.
+func (s *ExchangeRateService) Rate(ctx context.Context, moneda, base string, fecha time.Time) (money.Rate, error) {
+	if moneda == base {
+		return money.OneRate, nil
+	}
+	dia := truncateDia(fecha)
+
+	vigente, err := s.vigente(ctx, moneda, base, dia)
+	if err != nil {
+		return 0, err
+	}
+
+	if s.provider != nil && (vigente == nil || !vigente.Fecha.Equal(dia)) {
+		tasa, err := s.provider.Rate(ctx, moneda, base, dia)
+		switch {
+		case err == nil:
+			rate := &models.ExchangeRate{Moneda: moneda, Base: base, Fecha: dia, Tasa: tasa, Fuente: s.provider.Name()}
+			if err := s.rateRepo.Upsert(ctx, []*models.ExchangeRate{rate}); err != nil {
+				return 0, err
+			}
+			return tasa, nil
+		case !errors.Is(err, ErrRateUnavailable):
+			log.Printf("⚠️  Proveedor de tipos de cambio %s: %v\n", s.provider.Name(), err)
+		}
+	}
+
+	if vigente == nil {
+		return 0, &RateNotFoundError{Moneda: moneda, Base: base, Fecha: dia}
+	}
+	return vigente.Tasa, nil
+}
+
+// vigente busca el último tipo de cambio guardado hasta dia, directo o
+// inverso, y lo devuelve expresado de moneda a base. Devuelve nil si no hay.
+func (s *ExchangeRateService) vigente(ctx context.Context, moneda, base string, dia time.Time) (*models.ExchangeRate, error) {
+	directo, err := s.rateRepo.FindVigente(ctx, moneda, base, dia)
+	if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, err
+	}
+	inverso, err := s.rateRepo.FindVigente(ctx, base, moneda, dia)
+	if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, err
+	}
+
+	if inverso == nil || directo != nil && !inverso.Fecha.After(directo.Fecha) {
+		return directo, nil
+	}
+	inverso.Moneda, inverso.Base, inverso.Tasa = moneda, base, inverso.Tasa.Inverse()
+	return inverso, nil
+}
+
+// Convert convierte monto de moneda a base con el tipo de cambio de fecha y
+// lo redondea a los decimales de base. Devuelve también el tipo aplicado.
+func (s *ExchangeRateService) Convert(ctx context.Context, monto money.Amount, moneda, base string, fecha time.Time) (money.Amount, money.Rate, error) {
+	tasa, err := s.Rate(ctx, moneda, base, fecha)
+	if err != nil {
+		return 0, 0, err
+	}
+	convertido, err := monto.Convert(tasa)
+	if err != nil {
+		return 0, 0, err
+	}
+	return convertido.Round(base), tasa, nil
+}
+
+func (s *ExchangeRateService) List(ctx context.Context, filtro *models.ExchangeRateFilter) ([]*models.ExchangeRate, error) {
+	filtro.Moneda = strings.ToUpper(filtro.Moneda)
+	filtro.Base = strings.ToUpper(filtro.Base)
+	filtro.Limite = exchangeRatesLimite
+	return s.rateRepo.Find(ctx, filtro)
+}
+
+// Save valida y guarda tipos de cambio cargados a mano; los del mismo par y
+// fecha se reemplazan.
+func (s *ExchangeRateService) Save(ctx context.Context, rates []*models.ExchangeRate, fuente string, client models.ClientInfo) error {
+	for i, rate := range rates {
+		moneda, err := normalizeMoneda(rate.Moneda)
+		if err != nil {
+			return fmt.Errorf("%w: elemento %d: %v", ErrInvalidExchangeRate, i+1, err)
+		}
+		base, err := normalizeMoneda(rate.Base)
+		if err != nil {
+			return fmt.Errorf("%w: elemento %d: %v", ErrInvalidExchangeRate, i+1, err)
+		}
+		if moneda == base {
+			return fmt.Errorf("%w: elemento %d: la moneda y la base son iguales", ErrInvalidExchangeRate, i+1)
+		}
+		if rate.Tasa <= 0 {
+			return fmt.Errorf("%w: elemento %d: la tasa debe ser mayor que cero", ErrInvalidExchangeRate, i+1)
+		}
+
+		rate.Moneda, rate.Base = moneda, base
+		rate.Fecha = truncateDia(rate.Fecha)
+		rate.Fuente = fuente
+	}
+
+	if err := s.rateRepo.Upsert(ctx, rates); err != nil {
+		return err
+	}
+
+	s.audit.Record(ctx, client, &models.AuditLog{
+		Accion:  models.AuditTipoCambioCargar,
+		Recurso: "tipo_cambio",
+		Detalle: bson.M{"fuente": fuente, "cantidad": len(rates)},
+	})
+	return nil
+}
+
+// ImportCSV carga tipos de cambio desde un CSV con las columnas fecha
+// (YYYY-MM-DD), moneda, base y tasa, en cualquier orden. Devuelve cuántos se
+// guardaron.
+func (s *ExchangeRateService) ImportCSV(ctx context.Context, r io.Reader, client models.ClientInfo) (int, error) {
+	rates, err := parseExchangeRatesCSV(r)
+	if err != nil {
+		return 0, err
+	}
+	if err := s.Save(ctx, rates, "csv", client); err != nil {
+		return 0, err
+	}
+	return len(rates), nil
+}
+
+func parseExchangeRatesCSV(r io.Reader) ([]*models.ExchangeRate, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("%w: el CSV no tiene cabecera", ErrInvalidExchangeRate)
+	}
+	columnas := make(map[string]int)
+	for i, nombre := range header {
+		nombre = strings.TrimPrefix(nombre, "\ufeff")
+		columnas[strings.ToLower(strings.TrimSpace(nombre))] = i
+	}
+	for _, nombre := range []string{"fecha", "moneda", "base", "tasa"} {
+		if _, ok := columnas[nombre]; !ok {
+			return nil, fmt.Errorf("%w: falta la columna %s", ErrInvalidExchangeRate, nombre)
+		}
+	}
+
+	var rates []*models.ExchangeRate
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidExchangeRate, err)
+		}
+		linea, _ := reader.FieldPos(0)
+
+		fecha, err := time.Parse(time.DateOnly, strings.TrimSpace(record[columnas["fecha"]]))
+		if err != nil {
+			return nil, fmt.Errorf("%w: línea %d: fecha inválida", ErrInvalidExchangeRate, linea)
+		}
+		tasa, err := money.ParseRate(record[columnas["tasa"]])
+		if err != nil {
+			return nil, fmt.Errorf("%w: línea %d: tasa inválida", ErrInvalidExchangeRate, linea)
+		}
+
+		rates = append(rates, &models.ExchangeRate{
+			Moneda: record[columnas["moneda"]],
+			Base:   record[columnas["base"]],
+			Fecha:  fecha,
+			Tasa:   tasa,
+		})
+	}
+
+	if len(rates) == 0 {
+		return nil, fmt.Errorf("%w: el CSV no tiene filas", ErrInvalidExchangeRate)
+	}
+	return rates, nil
+}
+
+// truncateDia lleva una fecha a la medianoche UTC de su día.
+func truncateDia(t time.Time) time.Time {
+	y, m, d := t.UTC().Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+// normalizeMoneda valida un código ISO 4217 y lo pasa a mayúsculas.
+func normalizeMoneda(moneda string) (string, error) {
+	moneda = strings.ToUpper(strings.TrimSpace(moneda))
+	if !money.ValidCurrency(moneda) {
+		return "", ErrInvalidMoneda
+	}
+	return moneda, nil
+}
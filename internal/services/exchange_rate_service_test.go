@@ -0,0 +1,162 @@
+package services
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"control-financiero/internal/models"
+	"control-financiero/internal/money"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+func exchangeRateDoc(moneda, base string, fecha time.Time, tasa string) bson.D {
+	return bson.D{
+		{Key: "_id", Value: primitive.NewObjectID()},
+		{Key: "moneda", Value: moneda},
+		{Key: "base", Value: base},
+		{Key: "fecha", Value: fecha},
+		{Key: "tasa", Value: money.MustParseRate(tasa)},
+	}
+}
+
+func TestExchangeRate_Rate(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	ctx := context.Background()
+	dia := func(d int) time.Time { return time.Date(2024, 5, d, 0, 0, 0, 0, time.UTC) }
+
+	mt.Run("la misma moneda no consulta la base de datos", func(mt *mtest.T) {
+		s := NewExchangeRateService(mt.DB)
+		tasa, err := s.Rate(ctx, "PEN", "PEN", dia(1))
+		require.NoError(t, err)
+		assert.Equal(t, money.OneRate, tasa)
+	})
+
+	mt.Run("usa el más reciente de los dos sentidos", func(mt *mtest.T) {
+		s := NewExchangeRateService(mt.DB)
+		mt.AddMockResponses(
+			mtest.CreateCursorResponse(0, "test.exchange_rates", mtest.FirstBatch, exchangeRateDoc("USD", "PEN", dia(2), "3.7")),
+			mtest.CreateCursorResponse(0, "test.exchange_rates", mtest.FirstBatch, exchangeRateDoc("PEN", "USD", dia(9), "0.25")),
+		)
+
+		tasa, err := s.Rate(ctx, "USD", "PEN", dia(10).Add(20*time.Hour))
+		require.NoError(t, err)
+		assert.Equal(t, "4", tasa.String())
+	})
+
+	mt.Run("pide al proveedor los días que faltan y los guarda", func(mt *mtest.T) {
+		s := NewExchangeRateService(mt.DB)
+		s.SetProvider(StaticRateProvider{"EUR/PEN": money.MustParseRate("4.05")})
+		mt.AddMockResponses(
+			mtest.CreateCursorResponse(0, "test.exchange_rates", mtest.FirstBatch, exchangeRateDoc("EUR", "PEN", dia(2), "4.01")),
+			mtest.CreateCursorResponse(0, "test.exchange_rates", mtest.FirstBatch),
+			bson.D{{Key: "ok", Value: 1}, {Key: "n", Value: 1}, {Key: "nModified", Value: 0}},
+		)
+
+		tasa, err := s.Rate(ctx, "EUR", "PEN", dia(10))
+		require.NoError(t, err)
+		assert.Equal(t, "4.05", tasa.String())
+
+		evt := mt.GetStartedEvent()
+		for evt != nil && evt.CommandName != "update" {
+			evt = mt.GetStartedEvent()
+		}
+		require.NotNil(t, evt)
+		update := evt.Command.Lookup("updates").Array().Index(0).Value().Document()
+		assert.Equal(t, dia(10), update.Lookup("q", "fecha").Time().UTC())
+		assert.Equal(t, "static", update.Lookup("u", "$set", "fuente").StringValue())
+		assert.True(t, update.Lookup("upsert").Boolean())
+	})
+
+	mt.Run("si el proveedor no lo tiene usa el último guardado", func(mt *mtest.T) {
+		s := NewExchangeRateService(mt.DB)
+		s.SetProvider(StaticRateProvider{})
+		mt.AddMockResponses(
+			mtest.CreateCursorResponse(0, "test.exchange_rates", mtest.FirstBatch, exchangeRateDoc("EUR", "PEN", dia(2), "4.01")),
+			mtest.CreateCursorResponse(0, "test.exchange_rates", mtest.FirstBatch),
+		)
+
+		tasa, err := s.Rate(ctx, "EUR", "PEN", dia(10))
+		require.NoError(t, err)
+		assert.Equal(t, "4.01", tasa.String())
+	})
+
+	mt.Run("sin tipo de cambio ni proveedor", func(mt *mtest.T) {
+		s := NewExchangeRateService(mt.DB)
+		mt.AddMockResponses(
+			mtest.CreateCursorResponse(0, "test.exchange_rates", mtest.FirstBatch),
+			mtest.CreateCursorResponse(0, "test.exchange_rates", mtest.FirstBatch),
+		)
+
+		_, err := s.Rate(ctx, "EUR", "PEN", dia(10))
+		var sinCambio *RateNotFoundError
+		require.ErrorAs(t, err, &sinCambio)
+		assert.Equal(t, dia(10), sinCambio.Fecha)
+	})
+}
+
+func TestExchangeRate_Save(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	ctx := context.Background()
+
+	mt.Run("valida antes de guardar", func(mt *mtest.T) {
+		s := NewExchangeRateService(mt.DB)
+		tests := []struct {
+			name string
+			rate models.ExchangeRate
+		}{
+			{"moneda desconocida", models.ExchangeRate{Moneda: "XYZ", Base: "PEN", Tasa: money.OneRate}},
+			{"misma moneda", models.ExchangeRate{Moneda: "pen", Base: "PEN", Tasa: money.OneRate}},
+			{"sin tasa", models.ExchangeRate{Moneda: "USD", Base: "PEN"}},
+		}
+		for _, tt := range tests {
+			err := s.Save(ctx, []*models.ExchangeRate{&tt.rate}, "api", models.ClientInfo{})
+			assert.ErrorIs(t, err, ErrInvalidExchangeRate, tt.name)
+		}
+	})
+
+	mt.Run("importa un CSV", func(mt *mtest.T) {
+		s := NewExchangeRateService(mt.DB)
+		mt.AddMockResponses(
+			bson.D{{Key: "ok", Value: 1}, {Key: "n", Value: 2}, {Key: "nModified", Value: 0}},
+			mtest.CreateSuccessResponse(),
+		)
+
+		csv := "\ufefftasa,fecha,moneda,base\n3.7512,2024-05-02,usd,PEN\n0.2666,2024-05-03,PEN,USD\n"
+		cantidad, err := s.ImportCSV(ctx, strings.NewReader(csv), models.ClientInfo{})
+		require.NoError(t, err)
+		assert.Equal(t, 2, cantidad)
+
+		evt := mt.GetStartedEvent()
+		require.Equal(t, "update", evt.CommandName)
+		updates, err := evt.Command.Lookup("updates").Array().Values()
+		require.NoError(t, err)
+		require.Len(t, updates, 2)
+		assert.Equal(t, "USD", updates[0].Document().Lookup("q", "moneda").StringValue())
+		assert.Equal(t, "3.7512", updates[0].Document().Lookup("u", "$set", "tasa").Decimal128().String())
+		assert.Equal(t, "csv", updates[1].Document().Lookup("u", "$set", "fuente").StringValue())
+	})
+}
+
+func TestParseExchangeRatesCSV_Errores(t *testing.T) {
+	tests := map[string]string{
+		"sin filas":       "fecha,moneda,base,tasa\n",
+		"falta columna":   "fecha,moneda,tasa\n2024-05-02,USD,3.75\n",
+		"fecha inválida":  "fecha,moneda,base,tasa\n02/05/2024,USD,PEN,3.75\n",
+		"tasa inválida":   "fecha,moneda,base,tasa\n2024-05-02,USD,PEN,-1\n",
+		"columnas de más": "fecha,moneda,base,tasa\n2024-05-02,USD,PEN,3.75,x\n",
+	}
+	for name, csv := range tests {
+		_, err := parseExchangeRatesCSV(strings.NewReader(csv))
+		assert.ErrorIs(t, err, ErrInvalidExchangeRate, name)
+	}
+
+	_, err := parseExchangeRatesCSV(strings.NewReader("fecha,moneda,base,tasa\n2024-05-02,USD,PEN,3.75\n2024-05-03,USD,PEN,abc\n"))
+	assert.EqualError(t, err, "tipo de cambio inválido: línea 3: tasa inválida")
+}
@@ -0,0 +1,516 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"control-financiero/internal/importer"
+	"control-financiero/internal/models"
+	"control-financiero/internal/repositories"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ErrInvalidImportacion envuelve los errores de validación de una
+// importación, de su archivo y de los perfiles de importación.
+var ErrInvalidImportacion = errors.New("importación inválida")
+
+// errSinCategoria marca las filas de un tipo, ingreso o egreso, para el que
+// no se indicó categoría.
+var errSinCategoria = errors.New("indique la categoría")
+
+type ImportacionService struct {
+	client          *mongo.Client
+	importacionRepo *repositories.ImportacionRepository
+	perfilRepo      *repositories.PerfilImportacionRepository
+	transaccionRepo *repositories.TransaccionRepository
+	cuentaRepo      *repositories.CuentaRepository
+	userRepo        *repositories.UsuarioRepository
+	transacciones   *TransaccionService
+	audit           *AuditService
+}
+
+func NewImportacionService(db *mongo.Database) *ImportacionService {
+	return &ImportacionService{
+		client:          db.Client(),
+		importacionRepo: repositories.NewImportacionRepository(db),
+		perfilRepo:      repositories.NewPerfilImportacionRepository(db),
+		transaccionRepo: repositories.NewTransaccionRepository(db),
+		cuentaRepo:      repositories.NewCuentaRepository(db),
+		userRepo:        repositories.NewUsuarioRepository(db),
+		transacciones:   NewTransaccionService(db),
+		audit:           NewAuditService(db),
+	}
+}
+
+// Importar lee un extracto en OFX, camt.053, MT940 o CSV, según su contenido.
+func (s *ImportacionService) Importar(ctx context.Context, usuarioID primitive.ObjectID, data []byte, opciones *models.OpcionesImportacion, client models.ClientInfo) (*models.ResultadoImportacion, error) {
+	if formato := importer.Detectar(data); formato != importer.FormatoCSV {
+		return s.ImportarExtracto(ctx, usuarioID, formato, data, opciones, client)
+	}
+	return s.ImportarCSV(ctx, usuarioID, data, opciones, client)
+}
+
+// ImportarCSV lee un extracto en CSV y devuelve la vista previa de sus filas.
+// Con opciones.Confirmar además crea, en una misma transacción de MongoDB,
+// las transacciones de las filas válidas y el lote que permite deshacerlas.
+func (s *ImportacionService) ImportarCSV(ctx context.Context, usuarioID primitive.ObjectID, data []byte, opciones *models.OpcionesImportacion, client models.ClientInfo) (*models.ResultadoImportacion, error) {
+	mapeo := opciones.Mapeo
+	var perfil *models.PerfilImportacion
+	if opciones.PerfilID != nil {
+		var err error
+		perfil, err = s.perfilRepo.FindByID(ctx, *opciones.PerfilID, usuarioID)
+		if err != nil {
+			if errors.Is(err, mongo.ErrNoDocuments) {
+				return nil, fmt.Errorf("%w: el perfil no existe", ErrInvalidImportacion)
+			}
+			return nil, err
+		}
+		if mapeo == nil {
+			mapeo = &perfil.Mapeo
+		}
+	}
+	if mapeo == nil {
+		return nil, fmt.Errorf("%w: indique un perfil o un mapeo de columnas", ErrInvalidImportacion)
+	}
+
+	cuentaID := opciones.CuentaID
+	if cuentaID == nil && perfil != nil {
+		cuentaID = perfil.CuentaID
+	}
+	cuenta, err := s.cuentaImportacion(ctx, usuarioID, cuentaID)
+	if err != nil {
+		return nil, err
+	}
+
+	movimientos, formato, err := importer.LeerCSV(data, mapeo)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidImportacion, err)
+	}
+
+	importacion := &models.Importacion{
+		UsuarioID: usuarioID,
+		Origen:    importer.FormatoCSV,
+		Archivo:   opciones.Archivo,
+		CuentaID:  cuenta.ID,
+		PerfilID:  opciones.PerfilID,
+	}
+	resultado, err := s.importar(ctx, importacion, cuenta, movimientos, opciones, nil, client)
+	if err != nil {
+		return nil, err
+	}
+	resultado.Formato = formato
+	return resultado, nil
+}
+
+// ImportarExtracto importa un extracto OFX, QFX, camt.053 o MT940 como
+// ImportarCSV, sin perfil ni mapeo. Sin cuenta indicada se usa la que tiene
+// el número de cuenta o IBAN del extracto; si se indica una que aún no lo
+// tiene, al confirmar se le guarda para las siguientes importaciones. El
+// saldo contable del extracto se concilia con el de la cuenta.
+func (s *ImportacionService) ImportarExtracto(ctx context.Context, usuarioID primitive.ObjectID, formato string, data []byte, opciones *models.OpcionesImportacion, client models.ClientInfo) (*models.ResultadoImportacion, error) {
+	extractos, err := importer.LeerExtractos(formato, data)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidImportacion, err)
+	}
+	extracto, cuenta, err := s.elegirExtracto(ctx, usuarioID, extractos, opciones.CuentaID)
+	if err != nil {
+		return nil, err
+	}
+
+	var conciliacion *models.Conciliacion
+	if saldo := extracto.Saldo; saldo != nil && (extracto.Moneda == "" || extracto.Moneda == cuenta.Moneda) {
+		conciliacion = &models.Conciliacion{
+			Fecha:      saldo.Fecha,
+			SaldoBanco: saldo.Monto,
+		}
+		previo, err := s.transaccionRepo.SumImportesAntes(ctx, usuarioID, cuenta.ID, conciliacion.Fecha.AddDate(0, 0, 1), nil)
+		if err != nil {
+			return nil, err
+		}
+		conciliacion.SaldoCuenta = cuenta.SaldoInicial + previo
+	}
+
+	var enlazar func(ctx mongo.SessionContext) error
+	if cuenta.NumeroCuenta == "" {
+		enlazar = func(ctx mongo.SessionContext) error {
+			return s.cuentaRepo.SetNumeroCuenta(ctx, cuenta.ID, usuarioID, normalizeNumeroCuenta(extracto.Cuenta))
+		}
+	}
+
+	importacion := &models.Importacion{
+		UsuarioID: usuarioID,
+		Origen:    formato,
+		Archivo:   opciones.Archivo,
+		CuentaID:  cuenta.ID,
+	}
+	resultado, err := s.importar(ctx, importacion, cuenta, extracto.Movimientos, opciones, enlazar, client)
+	if err != nil {
+		return nil, err
+	}
+
+	// El saldo de la cuenta se calculó antes de importar: le faltan las filas
+	// que se importan hasta la fecha del saldo
+	if conciliacion != nil {
+		for _, fila := range resultado.Filas {
+			if importable(fila) && fila.Transaccion.Fecha.Before(conciliacion.Fecha.AddDate(0, 0, 1)) {
+				conciliacion.SaldoCuenta += fila.Transaccion.Importe()
+			}
+		}
+		conciliacion.Diferencia = conciliacion.SaldoBanco - conciliacion.SaldoCuenta
+		resultado.Conciliacion = conciliacion
+	}
+	return resultado, nil
+}
+
+// elegirExtracto elige el extracto del archivo que se importa y su cuenta. Con
+// cuentaID el extracto es el de su número de cuenta, o el único del archivo
+// si la cuenta aún no tiene número. Sin ella, la cuenta es la que tiene el
+// número de alguno de los extractos.
+func (s *ImportacionService) elegirExtracto(ctx context.Context, usuarioID primitive.ObjectID, extractos []*importer.Extracto, cuentaID *primitive.ObjectID) (*importer.Extracto, *models.Cuenta, error) {
+	if cuentaID != nil {
+		cuenta, err := s.cuentaImportacion(ctx, usuarioID, cuentaID)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, extracto := range extractos {
+			if normalizeNumeroCuenta(extracto.Cuenta) == cuenta.NumeroCuenta {
+				return extracto, cuenta, nil
+			}
+		}
+		switch {
+		case cuenta.NumeroCuenta == "" && len(extractos) == 1:
+			return extractos[0], cuenta, nil
+		case cuenta.NumeroCuenta == "":
+			return nil, nil, fmt.Errorf("%w: el archivo tiene extractos de %d cuentas; indique el número de la cuenta para elegir el suyo", ErrInvalidImportacion, len(extractos))
+		case len(extractos) == 1:
+			return nil, nil, fmt.Errorf("%w: el extracto es de la cuenta %s y no de la %s", ErrInvalidImportacion, extractos[0].Cuenta, cuenta.NumeroCuenta)
+		}
+		return nil, nil, fmt.Errorf("%w: el archivo no tiene extractos de la cuenta %s", ErrInvalidImportacion, cuenta.NumeroCuenta)
+	}
+
+	var elegido *importer.Extracto
+	var cuenta *models.Cuenta
+	for _, extracto := range extractos {
+		cuentas, err := s.cuentaRepo.FindByNumeroCuenta(ctx, usuarioID, normalizeNumeroCuenta(extracto.Cuenta))
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(cuentas) > 1 || (len(cuentas) == 1 && cuenta != nil) {
+			return nil, nil, fmt.Errorf("%w: el archivo corresponde a varias cuentas; indique la cuenta", ErrInvalidImportacion)
+		}
+		if len(cuentas) == 1 {
+			elegido, cuenta = extracto, cuentas[0]
+		}
+	}
+	if cuenta == nil {
+		if len(extractos) == 1 {
+			return nil, nil, fmt.Errorf("%w: ninguna cuenta tiene el número %s; indique la cuenta", ErrInvalidImportacion, extractos[0].Cuenta)
+		}
+		return nil, nil, fmt.Errorf("%w: ninguna cuenta tiene los números de cuenta del archivo; indique la cuenta", ErrInvalidImportacion)
+	}
+	return elegido, cuenta, nil
+}
+
+// cuentaImportacion valida la cuenta en que se registran los movimientos.
+func (s *ImportacionService) cuentaImportacion(ctx context.Context, usuarioID primitive.ObjectID, cuentaID *primitive.ObjectID) (*models.Cuenta, error) {
+	if cuentaID == nil {
+		return nil, fmt.Errorf("%w: indique la cuenta", ErrInvalidImportacion)
+	}
+	cuenta, err := s.cuentaRepo.FindByID(ctx, *cuentaID, usuarioID)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, ErrInvalidCuenta
+		}
+		return nil, err
+	}
+	if cuenta.Archivada {
+		return nil, ErrInvalidCuenta
+	}
+	return cuenta, nil
+}
+
+// importar convierte los movimientos leídos de un archivo en transacciones
+// de cuenta, la de la importación, y si se confirma las guarda. Los
+// ingresos y egresos reciben la categoría indicada para cada tipo. Una fila
+// cuya referencia ya está en la cuenta, o en una fila anterior, es duplicada.
+// despues, si no es nil, se ejecuta al confirmar en la misma transacción de
+// MongoDB.
+func (s *ImportacionService) importar(ctx context.Context, importacion *models.Importacion, cuenta *models.Cuenta, movimientos []*importer.Movimiento, opciones *models.OpcionesImportacion, despues func(mongo.SessionContext) error, client models.ClientInfo) (*models.ResultadoImportacion, error) {
+	omitir := make(map[int]bool, len(opciones.Omitir))
+	for _, fila := range opciones.Omitir {
+		omitir[fila] = true
+	}
+
+	var referencias []string
+	for _, m := range movimientos {
+		if m.Referencia != "" {
+			referencias = append(referencias, m.Referencia)
+		}
+	}
+	existentes, err := s.transaccionRepo.FindReferencias(ctx, importacion.UsuarioID, importacion.CuentaID, referencias)
+	if err != nil {
+		return nil, err
+	}
+	// El usuario y la cuenta se leen una vez para todas las filas
+	usuario, err := s.userRepo.FindByID(ctx, importacion.UsuarioID)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	base := usuario.Moneda()
+
+	resultado := &models.ResultadoImportacion{
+		CuentaID: importacion.CuentaID,
+		Filas:    make([]*models.FilaImportacion, 0, len(movimientos)),
+	}
+	vistas := make(map[string]bool)
+	var validas []*models.Transaccion
+	for _, m := range movimientos {
+		fila := &models.FilaImportacion{Fila: m.Fila, Errores: m.Errores}
+		resultado.Filas = append(resultado.Filas, fila)
+		if len(fila.Errores) == 0 {
+			if fila.Transaccion, err = s.transaccionImportada(ctx, importacion, base, cuenta, m, opciones); err != nil {
+				if !errorDeFila(err) {
+					return nil, err
+				}
+				fila.Errores = append(fila.Errores, err.Error())
+			}
+		}
+
+		switch {
+		case len(fila.Errores) > 0:
+			resultado.ConErrores++
+		case omitir[fila.Fila]:
+			fila.Omitida = true
+			resultado.Omitidas++
+		case m.Referencia != "" && (existentes[m.Referencia] || vistas[m.Referencia]):
+			fila.Duplicada = true
+			resultado.Duplicadas++
+		default:
+			vistas[m.Referencia] = true
+			validas = append(validas, fila.Transaccion)
+			resultado.Validas++
+		}
+	}
+
+	if !opciones.Confirmar {
+		return resultado, nil
+	}
+	if len(validas) == 0 {
+		return nil, fmt.Errorf("%w: no hay filas para importar", ErrInvalidImportacion)
+	}
+
+	importacion.ID = primitive.NewObjectID()
+	importacion.Cantidad = len(validas)
+	for _, t := range validas {
+		t.ImportacionID = &importacion.ID
+	}
+	err = withTransaction(ctx, s.client, func(ctx mongo.SessionContext) error {
+		if err := s.importacionRepo.Create(ctx, importacion); err != nil {
+			return err
+		}
+		if err := s.transaccionRepo.CreateMany(ctx, validas); err != nil {
+			return err
+		}
+		if despues != nil {
+			return despues(ctx)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.audit.Record(ctx, client, s.auditEntry(models.AuditImportacionCrear, importacion.ID, nil, importacion))
+	resultado.Importacion = importacion
+	return resultado, nil
+}
+
+// importable indica si una fila de la vista previa se importa al confirmar.
+func importable(fila *models.FilaImportacion) bool {
+	return fila.Transaccion != nil && len(fila.Errores) == 0 && !fila.Duplicada && !fila.Omitida
+}
+
+// transaccionImportada prepara la transacción de un movimiento como lo hace
+// TransaccionService.Create: en la moneda de cuenta y con su equivalente en
+// base, la moneda base del usuario.
+func (s *ImportacionService) transaccionImportada(ctx context.Context, importacion *models.Importacion, base string, cuenta *models.Cuenta, m *importer.Movimiento, opciones *models.OpcionesImportacion) (*models.Transaccion, error) {
+	transaccion := &models.Transaccion{
+		UsuarioID:   importacion.UsuarioID,
+		Tipo:        "ingreso",
+		Monto:       m.Monto,
+		Moneda:      m.Moneda,
+		Fecha:       m.Fecha,
+		Descripcion: m.Descripcion,
+		CuentaID:    &importacion.CuentaID,
+		Referencia:  m.Referencia,
+	}
+	if m.Contraparte != "" || m.CuentaContraparte != "" || m.Concepto != "" || !m.FechaValor.IsZero() {
+		transaccion.Bancario = &models.DatosBancarios{
+			Contraparte:       m.Contraparte,
+			CuentaContraparte: m.CuentaContraparte,
+			Concepto:          m.Concepto,
+		}
+		if !m.FechaValor.IsZero() {
+			fechaValor := m.FechaValor
+			transaccion.Bancario.FechaValor = &fechaValor
+		}
+	}
+	categoria := opciones.CategoriaIngresoID
+	if m.Monto < 0 {
+		transaccion.Tipo, transaccion.Monto = "egreso", -m.Monto
+		categoria = opciones.CategoriaEgresoID
+	}
+	if categoria == nil {
+		return nil, fmt.Errorf("%w de los %ss", errSinCategoria, transaccion.Tipo)
+	}
+	transaccion.CategoriaID = *categoria
+
+	if err := s.transacciones.prepareWith(ctx, transaccion, base, cuenta); err != nil {
+		return nil, err
+	}
+	return transaccion, nil
+}
+
+// errorDeFila indica si el error al preparar una transacción importada se
+// debe a los datos de su fila y no impide revisar las demás.
+func errorDeFila(err error) bool {
+	var sinCambio *RateNotFoundError
+	return errors.Is(err, errSinCategoria) || errors.Is(err, ErrInvalidMonto) || errors.Is(err, ErrInvalidMoneda) ||
+		errors.As(err, &sinCambio)
+}
+
+func (s *ImportacionService) GetAll(ctx context.Context, usuarioID primitive.ObjectID) ([]*models.Importacion, error) {
+	return s.importacionRepo.FindByUsuario(ctx, usuarioID)
+}
+
+// Deshacer elimina una importación junto con todas sus transacciones, aunque
+// se hayan editado después. Devuelve cuántas transacciones se eliminaron.
+func (s *ImportacionService) Deshacer(ctx context.Context, id, usuarioID primitive.ObjectID, client models.ClientInfo) (int64, error) {
+	existing, err := s.importacionRepo.FindByID(ctx, id, usuarioID)
+	if err != nil {
+		return 0, notFound(err)
+	}
+
+	var eliminadas int64
+	err = withTransaction(ctx, s.client, func(ctx mongo.SessionContext) error {
+		n, err := s.transaccionRepo.DeleteByImportacion(ctx, usuarioID, id)
+		if err != nil {
+			return err
+		}
+		eliminadas = n
+		return s.importacionRepo.Delete(ctx, id, usuarioID)
+	})
+	if err != nil {
+		return 0, notFound(err)
+	}
+
+	s.audit.Record(ctx, client, s.auditEntry(models.AuditImportacionDeshacer, id, existing, nil))
+	return eliminadas, nil
+}
+
+func (s *ImportacionService) auditEntry(accion string, id primitive.ObjectID, before, after *models.Importacion) *models.AuditLog {
+	return &models.AuditLog{
+		Accion:    accion,
+		Recurso:   "importacion",
+		RecursoID: objectIDPtr(id),
+		Detalle:   auditDiff(before, after),
+	}
+}
+
+// CreatePerfil crea un perfil del usuario indicado en perfil.UsuarioID.
+func (s *ImportacionService) CreatePerfil(ctx context.Context, perfil *models.PerfilImportacion, client models.ClientInfo) error {
+	if err := s.prepararPerfil(ctx, perfil); err != nil {
+		return err
+	}
+
+	if err := s.perfilRepo.Create(ctx, perfil); err != nil {
+		return err
+	}
+
+	s.audit.Record(ctx, client, s.perfilAuditEntry(models.AuditPerfilBancoCrear, perfil.ID, nil, perfil))
+	return nil
+}
+
+func (s *ImportacionService) GetPerfiles(ctx context.Context, usuarioID primitive.ObjectID) ([]*models.PerfilImportacion, error) {
+	return s.perfilRepo.FindByUsuario(ctx, usuarioID)
+}
+
+func (s *ImportacionService) GetPerfil(ctx context.Context, id, usuarioID primitive.ObjectID) (*models.PerfilImportacion, error) {
+	perfil, err := s.perfilRepo.FindByID(ctx, id, usuarioID)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return perfil, nil
+}
+
+// UpdatePerfil modifica un perfil del usuario indicado en perfil.UsuarioID.
+func (s *ImportacionService) UpdatePerfil(ctx context.Context, perfil *models.PerfilImportacion, client models.ClientInfo) error {
+	existing, err := s.GetPerfil(ctx, perfil.ID, perfil.UsuarioID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.prepararPerfil(ctx, perfil); err != nil {
+		return err
+	}
+
+	// La fecha de creación no se puede cambiar
+	perfil.CreatedAt = existing.CreatedAt
+
+	if err := s.perfilRepo.Update(ctx, perfil); err != nil {
+		return notFound(err)
+	}
+
+	s.audit.Record(ctx, client, s.perfilAuditEntry(models.AuditPerfilBancoEditar, perfil.ID, existing, perfil))
+	return nil
+}
+
+// DeletePerfil elimina un perfil. Las importaciones hechas con él se
+// conservan.
+func (s *ImportacionService) DeletePerfil(ctx context.Context, id, usuarioID primitive.ObjectID, client models.ClientInfo) error {
+	existing, err := s.GetPerfil(ctx, id, usuarioID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.perfilRepo.Delete(ctx, id, usuarioID); err != nil {
+		return notFound(err)
+	}
+
+	s.audit.Record(ctx, client, s.perfilAuditEntry(models.AuditPerfilBancoEliminar, id, existing, nil))
+	return nil
+}
+
+func (s *ImportacionService) perfilAuditEntry(accion string, id primitive.ObjectID, before, after *models.PerfilImportacion) *models.AuditLog {
+	return &models.AuditLog{
+		Accion:    accion,
+		Recurso:   "perfil_importacion",
+		RecursoID: objectIDPtr(id),
+		Detalle:   auditDiff(before, after),
+	}
+}
+
+// prepararPerfil valida el nombre, el mapeo y la cuenta por defecto del
+// perfil.
+func (s *ImportacionService) prepararPerfil(ctx context.Context, perfil *models.PerfilImportacion) error {
+	perfil.Nombre = strings.TrimSpace(perfil.Nombre)
+	if perfil.Nombre == "" {
+		return fmt.Errorf("%w: indique el nombre del perfil", ErrInvalidImportacion)
+	}
+	if err := importer.ValidarMapeo(&perfil.Mapeo); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidImportacion, err)
+	}
+	if perfil.CuentaID != nil {
+		if _, err := s.cuentaRepo.FindByID(ctx, *perfil.CuentaID, perfil.UsuarioID); err != nil {
+			if errors.Is(err, mongo.ErrNoDocuments) {
+				return ErrInvalidCuenta
+			}
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,455 @@
+package services
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"control-financiero/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+// referenciasResponse responde al distinct de las referencias ya importadas.
+func referenciasResponse(referencias ...interface{}) bson.D {
+	return bson.D{{Key: "ok", Value: 1}, {Key: "values", Value: append(bson.A{}, referencias...)}}
+}
+
+func TestImportacion_ImportarCSV(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	ctx := context.Background()
+	cuentaID := primitive.NewObjectID()
+	ingresos, egresos := primitive.NewObjectID(), primitive.NewObjectID()
+	mapeo := func() *models.MapeoImportacion {
+		return &models.MapeoImportacion{Fecha: "fecha", Descripcion: "concepto", Monto: "importe", Referencia: "ref"}
+	}
+
+	mt.Run("la vista previa marca errores, duplicadas y omitidas", func(mt *mtest.T) {
+		s := NewImportacionService(mt.DB)
+		csv := strings.Join([]string{
+			"fecha,concepto,importe,ref",
+			"2025-03-01,Sueldo,1500,A1",
+			"2025-03-02,Super,-80.5,A2",
+			"2025-03-03,Repetida,-10,A1",
+			"2025-03-04,Ya importada,-5,B9",
+			"ayer,Sin fecha,-3,C1",
+			"2025-03-05,Omitida,-1,C2",
+		}, "\n")
+		responses := []bson.D{
+			mtest.CreateCursorResponse(0, "test.cuentas", mtest.FirstBatch, cuentaDoc(cuentaID, "USD", "0", false)),
+			referenciasResponse("B9"),
+			propietarioResponse(t, "USD"),
+		}
+		mt.AddMockResponses(responses...)
+
+		resultado, err := s.ImportarCSV(ctx, propietario, []byte(csv), &models.OpcionesImportacion{
+			CuentaID:           &cuentaID,
+			Mapeo:              mapeo(),
+			CategoriaIngresoID: &ingresos,
+			CategoriaEgresoID:  &egresos,
+			Omitir:             []int{7},
+		}, models.ClientInfo{})
+		require.NoError(t, err)
+
+		assert.Nil(t, resultado.Importacion)
+		assert.Equal(t, ",", resultado.Formato.Delimitador)
+		assert.Equal(t, 2, resultado.Validas)
+		assert.Equal(t, 1, resultado.ConErrores)
+		assert.Equal(t, 2, resultado.Duplicadas)
+		assert.Equal(t, 1, resultado.Omitidas)
+
+		filas := resultado.Filas
+		require.Len(t, filas, 6)
+		assert.Equal(t, "ingreso", filas[0].Transaccion.Tipo)
+		assert.Equal(t, ingresos, filas[0].Transaccion.CategoriaID)
+		assert.Equal(t, "egreso", filas[1].Transaccion.Tipo)
+		assert.Equal(t, "80.5", filas[1].Transaccion.Monto.String())
+		assert.Equal(t, "USD", filas[1].Transaccion.Moneda)
+		assert.True(t, filas[2].Duplicada)
+		assert.True(t, filas[3].Duplicada)
+		assert.Equal(t, 6, filas[4].Fila)
+		assert.Equal(t, []string{"fecha inválida"}, filas[4].Errores)
+		assert.True(t, filas[5].Omitida)
+
+		// El usuario y la cuenta se leen una sola vez, no en cada fila
+		lecturas := map[string]int{}
+		for evt := mt.GetStartedEvent(); evt != nil; evt = mt.GetStartedEvent() {
+			assert.NotEqual(t, "insert", evt.CommandName)
+			if coleccion, ok := evt.Command.Lookup("find").StringValueOK(); ok {
+				lecturas[coleccion]++
+			}
+		}
+		assert.Equal(t, map[string]int{"cuentas": 1, "usuarios": 1}, lecturas)
+	})
+
+	mt.Run("sin categoría para un tipo sus filas tienen error", func(mt *mtest.T) {
+		s := NewImportacionService(mt.DB)
+		csv := "fecha,concepto,importe,ref\n2025-03-01,Sueldo,1500,A1\n2025-03-02,Super,-80.5,A2\n"
+		mt.AddMockResponses(
+			mtest.CreateCursorResponse(0, "test.cuentas", mtest.FirstBatch, cuentaDoc(cuentaID, "USD", "0", false)),
+			referenciasResponse(),
+			propietarioResponse(t, "USD"),
+		)
+
+		resultado, err := s.ImportarCSV(ctx, propietario, []byte(csv), &models.OpcionesImportacion{
+			CuentaID:           &cuentaID,
+			Mapeo:              mapeo(),
+			CategoriaIngresoID: &ingresos,
+		}, models.ClientInfo{})
+		require.NoError(t, err)
+		assert.Equal(t, 1, resultado.Validas)
+		assert.Equal(t, []string{"indique la categoría de los egresos"}, resultado.Filas[1].Errores)
+	})
+
+	mt.Run("al confirmar inserta las filas válidas con el lote", func(mt *mtest.T) {
+		s := NewImportacionService(mt.DB)
+		csv := "fecha;concepto;importe;ref\n01/03/2025;Sueldo;1.500,00;A1\n02/03/2025;Super;-80,50;A2\n"
+		responses := []bson.D{
+			mtest.CreateCursorResponse(0, "test.cuentas", mtest.FirstBatch, cuentaDoc(cuentaID, "USD", "0", false)),
+			referenciasResponse(),
+			propietarioResponse(t, "USD"),
+		}
+		responses = append(responses,
+			mtest.CreateSuccessResponse(),
+			mtest.CreateSuccessResponse(),
+			mtest.CreateSuccessResponse(), // commit
+			mtest.CreateSuccessResponse(), // auditoría
+		)
+		mt.AddMockResponses(responses...)
+
+		resultado, err := s.ImportarCSV(ctx, propietario, []byte(csv), &models.OpcionesImportacion{
+			Archivo:            "marzo.csv",
+			CuentaID:           &cuentaID,
+			Mapeo:              mapeo(),
+			CategoriaIngresoID: &ingresos,
+			CategoriaEgresoID:  &egresos,
+			Confirmar:          true,
+		}, models.ClientInfo{})
+		require.NoError(t, err)
+
+		importacion := resultado.Importacion
+		require.NotNil(t, importacion)
+		assert.Equal(t, 2, importacion.Cantidad)
+		assert.Equal(t, "marzo.csv", importacion.Archivo)
+		for _, fila := range resultado.Filas {
+			require.NotNil(t, fila.Transaccion.ImportacionID)
+			assert.Equal(t, importacion.ID, *fila.Transaccion.ImportacionID)
+		}
+		assert.Equal(t, "1500", resultado.Filas[0].Transaccion.Monto.String())
+
+		evt := startedEvent(t, mt, func(cmd bson.Raw) bool {
+			coleccion, _ := cmd.Lookup("insert").StringValueOK()
+			return coleccion == "transacciones"
+		})
+		require.NotNil(t, evt)
+		docs, err := evt.Command.Lookup("documents").Array().Values()
+		require.NoError(t, err)
+		assert.Len(t, docs, 2)
+		_, enTransaccion := evt.Command.Lookup("txnNumber").Int64OK()
+		assert.True(t, enTransaccion)
+	})
+
+	mt.Run("sin filas válidas no se confirma", func(mt *mtest.T) {
+		s := NewImportacionService(mt.DB)
+		mt.AddMockResponses(
+			mtest.CreateCursorResponse(0, "test.cuentas", mtest.FirstBatch, cuentaDoc(cuentaID, "USD", "0", false)),
+			referenciasResponse("A1"),
+			propietarioResponse(t, "USD"),
+		)
+
+		_, err := s.ImportarCSV(ctx, propietario, []byte("fecha,concepto,importe,ref\n2025-03-01,Sueldo,1500,A1\n"), &models.OpcionesImportacion{
+			CuentaID:           &cuentaID,
+			Mapeo:              mapeo(),
+			CategoriaIngresoID: &ingresos,
+			Confirmar:          true,
+		}, models.ClientInfo{})
+		assert.ErrorIs(t, err, ErrInvalidImportacion)
+	})
+
+	mt.Run("en una cuenta archivada", func(mt *mtest.T) {
+		s := NewImportacionService(mt.DB)
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "test.cuentas", mtest.FirstBatch, cuentaDoc(cuentaID, "USD", "0", true)))
+
+		_, err := s.ImportarCSV(ctx, propietario, []byte("fecha,importe\n2025-03-01,1\n"), &models.OpcionesImportacion{
+			CuentaID: &cuentaID,
+			Mapeo:    &models.MapeoImportacion{Fecha: "fecha", Monto: "importe"},
+		}, models.ClientInfo{})
+		assert.ErrorIs(t, err, ErrInvalidCuenta)
+	})
+
+	mt.Run("sin perfil ni mapeo", func(mt *mtest.T) {
+		s := NewImportacionService(mt.DB)
+		_, err := s.ImportarCSV(ctx, propietario, []byte("fecha,importe\n"), &models.OpcionesImportacion{CuentaID: &cuentaID}, models.ClientInfo{})
+		assert.ErrorIs(t, err, ErrInvalidImportacion)
+	})
+}
+
+// extractoOFX arma un OFX 1.x de la cuenta 191-1234 con los movimientos y,
+// si no está vacío, el saldo contable al 31 de enero.
+func extractoOFX(saldo string, movimientos ...string) []byte {
+	ofx := "OFXHEADER:100\nDATA:OFXSGML\n\n<OFX><BANKMSGSRSV1><STMTTRNRS><STMTRS><CURDEF>USD" +
+		"<BANKACCTFROM><BANKID>002<ACCTID>191-1234<ACCTTYPE>CHECKING</BANKACCTFROM><BANKTRANLIST>"
+	for _, m := range movimientos {
+		fecha, resto, _ := strings.Cut(m, " ")
+		monto, fitid, _ := strings.Cut(resto, " ")
+		ofx += "<STMTTRN><TRNTYPE>OTHER<DTPOSTED>" + fecha + "<TRNAMT>" + monto + "<FITID>" + fitid + "<NAME>Mov " + fitid + "</STMTTRN>"
+	}
+	ofx += "</BANKTRANLIST>"
+	if saldo != "" {
+		ofx += "<LEDGERBAL><BALAMT>" + saldo + "<DTASOF>20250131235959[-5:EST]</LEDGERBAL>"
+	}
+	return []byte(ofx + "</STMTRS></STMTTRNRS></BANKMSGSRSV1></OFX>")
+}
+
+func TestImportacion_ImportarOFX(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	ctx := context.Background()
+	cuentaID := primitive.NewObjectID()
+	ingresos, egresos := primitive.NewObjectID(), primitive.NewObjectID()
+	cuentaConNumero := func(numero string) bson.D {
+		return append(cuentaDoc(cuentaID, "USD", "0", false), bson.E{Key: "numeroCuenta", Value: numero})
+	}
+
+	mt.Run("usa la cuenta con el número del extracto y concilia el saldo", func(mt *mtest.T) {
+		s := NewImportacionService(mt.DB)
+		responses := []bson.D{
+			mtest.CreateCursorResponse(0, "test.cuentas", mtest.FirstBatch, cuentaConNumero("1911234")),
+			sumaResponse(t, "total", "-400"),
+			referenciasResponse("F2"),
+			propietarioResponse(t, "USD"),
+		}
+		mt.AddMockResponses(responses...)
+
+		data := extractoOFX("1000.00", "20250105 1500.00 F1", "20250110 -80.50 F2", "20250201 -20 F3")
+		resultado, err := s.Importar(ctx, propietario, data, &models.OpcionesImportacion{
+			CategoriaIngresoID: &ingresos,
+			CategoriaEgresoID:  &egresos,
+		}, models.ClientInfo{})
+		require.NoError(t, err)
+
+		assert.Equal(t, cuentaID, resultado.CuentaID)
+		assert.Equal(t, 2, resultado.Validas)
+		assert.True(t, resultado.Filas[1].Duplicada)
+		assert.Equal(t, "F1", resultado.Filas[0].Transaccion.Referencia)
+		assert.Equal(t, "Mov F1", resultado.Filas[0].Transaccion.Descripcion)
+
+		// 0 inicial - 400 previos + 1500 de F1; F2 ya estaba y F3 es posterior
+		conciliacion := resultado.Conciliacion
+		require.NotNil(t, conciliacion)
+		assert.Equal(t, "2025-01-31", conciliacion.Fecha.Format("2006-01-02"))
+		assert.Equal(t, "1000", conciliacion.SaldoBanco.String())
+		assert.Equal(t, "1100", conciliacion.SaldoCuenta.String())
+		assert.Equal(t, "-100", conciliacion.Diferencia.String())
+
+		evt := startedEvent(t, mt, func(cmd bson.Raw) bool {
+			coleccion, _ := cmd.Lookup("find").StringValueOK()
+			return coleccion == "cuentas"
+		})
+		require.NotNil(t, evt)
+		assert.Equal(t, "1911234", evt.Command.Lookup("filter", "numeroCuenta").StringValue())
+	})
+
+	mt.Run("guarda el número en la cuenta indicada al confirmar", func(mt *mtest.T) {
+		s := NewImportacionService(mt.DB)
+		responses := []bson.D{
+			mtest.CreateCursorResponse(0, "test.cuentas", mtest.FirstBatch, cuentaDoc(cuentaID, "USD", "0", false)),
+			referenciasResponse(),
+			propietarioResponse(t, "USD"),
+		}
+		responses = append(responses,
+			mtest.CreateSuccessResponse(),
+			mtest.CreateSuccessResponse(),
+			mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}),
+			mtest.CreateSuccessResponse(), // commit
+			mtest.CreateSuccessResponse(), // auditoría
+		)
+		mt.AddMockResponses(responses...)
+
+		resultado, err := s.Importar(ctx, propietario, extractoOFX("", "20250105 -30 F1"), &models.OpcionesImportacion{
+			CuentaID:          &cuentaID,
+			CategoriaEgresoID: &egresos,
+			Confirmar:         true,
+		}, models.ClientInfo{})
+		require.NoError(t, err)
+		require.NotNil(t, resultado.Importacion)
+		assert.Equal(t, "ofx", resultado.Importacion.Origen)
+		assert.Nil(t, resultado.Conciliacion)
+
+		evt := startedEvent(t, mt, func(cmd bson.Raw) bool {
+			coleccion, _ := cmd.Lookup("update").StringValueOK()
+			return coleccion == "cuentas"
+		})
+		require.NotNil(t, evt)
+		update := evt.Command.Lookup("updates").Array().Index(0).Value().Document()
+		assert.Equal(t, "1911234", update.Lookup("u", "$set", "numeroCuenta").StringValue())
+		_, enTransaccion := evt.Command.Lookup("txnNumber").Int64OK()
+		assert.True(t, enTransaccion)
+	})
+
+	mt.Run("el extracto es de otra cuenta", func(mt *mtest.T) {
+		s := NewImportacionService(mt.DB)
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "test.cuentas", mtest.FirstBatch, cuentaConNumero("999")))
+
+		_, err := s.Importar(ctx, propietario, extractoOFX("", "20250105 -30 F1"), &models.OpcionesImportacion{CuentaID: &cuentaID}, models.ClientInfo{})
+		assert.ErrorIs(t, err, ErrInvalidImportacion)
+	})
+
+	mt.Run("ninguna cuenta tiene el número", func(mt *mtest.T) {
+		s := NewImportacionService(mt.DB)
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "test.cuentas", mtest.FirstBatch))
+
+		_, err := s.Importar(ctx, propietario, extractoOFX("", "20250105 -30 F1"), &models.OpcionesImportacion{}, models.ClientInfo{})
+		assert.ErrorIs(t, err, ErrInvalidImportacion)
+		assert.Contains(t, err.Error(), "191-1234")
+	})
+}
+
+func TestImportacion_Deshacer(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	ctx := context.Background()
+
+	mt.Run("elimina el lote y sus transacciones", func(mt *mtest.T) {
+		s := NewImportacionService(mt.DB)
+		id := primitive.NewObjectID()
+		mt.AddMockResponses(
+			mtest.CreateCursorResponse(0, "test.importaciones", mtest.FirstBatch, bson.D{
+				{Key: "_id", Value: id},
+				{Key: "usuarioId", Value: propietario},
+				{Key: "origen", Value: "csv"},
+				{Key: "cantidad", Value: 3},
+			}),
+			mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 3}),
+			mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}),
+			mtest.CreateSuccessResponse(), // commit
+			mtest.CreateSuccessResponse(), // auditoría
+		)
+
+		eliminadas, err := s.Deshacer(ctx, id, propietario, models.ClientInfo{})
+		require.NoError(t, err)
+		assert.Equal(t, int64(3), eliminadas)
+
+		evt := startedEvent(t, mt, func(cmd bson.Raw) bool {
+			coleccion, _ := cmd.Lookup("delete").StringValueOK()
+			return coleccion == "transacciones"
+		})
+		require.NotNil(t, evt)
+		filtro := evt.Command.Lookup("deletes").Array().Index(0).Value().Document().Lookup("q").Document()
+		assert.Equal(t, id, filtro.Lookup("importacionId").ObjectID())
+		assert.Equal(t, propietario, filtro.Lookup("usuarioId").ObjectID())
+	})
+
+	mt.Run("de otro usuario", func(mt *mtest.T) {
+		s := NewImportacionService(mt.DB)
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "test.importaciones", mtest.FirstBatch))
+
+		_, err := s.Deshacer(ctx, primitive.NewObjectID(), propietario, models.ClientInfo{})
+		assert.ErrorIs(t, err, ErrNotFound)
+	})
+}
+
+func TestImportacion_CreatePerfil(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	ctx := context.Background()
+
+	mt.Run("valida el mapeo", func(mt *mtest.T) {
+		s := NewImportacionService(mt.DB)
+		err := s.CreatePerfil(ctx, &models.PerfilImportacion{
+			UsuarioID: propietario,
+			Nombre:    "Banco Ejemplo",
+			Mapeo:     models.MapeoImportacion{Fecha: "fecha", Monto: "importe", Debito: "cargo"},
+		}, models.ClientInfo{})
+		assert.ErrorIs(t, err, ErrInvalidImportacion)
+	})
+
+	mt.Run("guarda el mapeo normalizado", func(mt *mtest.T) {
+		s := NewImportacionService(mt.DB)
+		mt.AddMockResponses(mtest.CreateSuccessResponse(), mtest.CreateSuccessResponse())
+
+		perfil := &models.PerfilImportacion{
+			UsuarioID: propietario,
+			Nombre:    " Banco Ejemplo ",
+			Mapeo: models.MapeoImportacion{
+				FormatoCSV: models.FormatoCSV{Codificacion: "Windows-1252"},
+				Fecha:      " Fecha ", Debito: "Cargo", Credito: "Abono",
+			},
+		}
+		require.NoError(t, s.CreatePerfil(ctx, perfil, models.ClientInfo{}))
+		assert.Equal(t, "Banco Ejemplo", perfil.Nombre)
+		assert.Equal(t, "windows-1252", perfil.Mapeo.Codificacion)
+		assert.Equal(t, "Fecha", perfil.Mapeo.Fecha)
+	})
+}
+
+func TestImportacion_ImportarCamt(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	ctx := context.Background()
+	cuentaID := primitive.NewObjectID()
+	egresos := primitive.NewObjectID()
+
+	camt := `<?xml version="1.0" encoding="UTF-8"?>
+<Document xmlns="urn:iso:std:iso:20022:tech:xsd:camt.053.001.02"><BkToCstmrStmt><Stmt><Id>E1</Id>
+<Acct><Id><IBAN>ES91 2100 0418 4502 0005 1332</IBAN></Id><Ccy>USD</Ccy></Acct>
+<Ntry><Amt Ccy="USD">35.50</Amt><CdtDbtInd>DBIT</CdtDbtInd><Sts>BOOK</Sts>
+<BookgDt><Dt>2025-02-03</Dt></BookgDt><ValDt><Dt>2025-02-01</Dt></ValDt><AcctSvcrRef>B1</AcctSvcrRef>
+<NtryDtls><TxDtls><RltdPties><Cdtr><Nm>Iberdrola</Nm></Cdtr><CdtrAcct><Id><IBAN>ES7620770024003102575766</IBAN></Id></CdtrAcct></RltdPties>
+<RmtInf><Ustrd>Factura luz enero</Ustrd></RmtInf></TxDtls></NtryDtls></Ntry>
+<Ntry><Amt Ccy="USD">20</Amt><CdtDbtInd>DBIT</CdtDbtInd><Sts>PDNG</Sts><BookgDt><Dt>2025-02-04</Dt></BookgDt><AcctSvcrRef>B2</AcctSvcrRef></Ntry>
+</Stmt></BkToCstmrStmt></Document>`
+
+	mt.Run("guarda los datos bancarios y usa la referencia del banco", func(mt *mtest.T) {
+		s := NewImportacionService(mt.DB)
+		mt.AddMockResponses(
+			mtest.CreateCursorResponse(0, "test.cuentas", mtest.FirstBatch, cuentaDoc(cuentaID, "USD", "0", false)),
+			referenciasResponse(),
+			propietarioResponse(t, "USD"),
+			mtest.CreateSuccessResponse(),
+			mtest.CreateSuccessResponse(),
+			mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}),
+			mtest.CreateSuccessResponse(), // commit
+			mtest.CreateSuccessResponse(), // auditoría
+		)
+
+		resultado, err := s.Importar(ctx, propietario, []byte(camt), &models.OpcionesImportacion{
+			CuentaID:          &cuentaID,
+			CategoriaEgresoID: &egresos,
+			Confirmar:         true,
+		}, models.ClientInfo{})
+		require.NoError(t, err)
+		require.NotNil(t, resultado.Importacion)
+		assert.Equal(t, "camt053", resultado.Importacion.Origen)
+		assert.Equal(t, 1, resultado.Validas)
+		assert.Equal(t, []string{"el movimiento está pendiente de contabilizar"}, resultado.Filas[1].Errores)
+
+		transaccion := resultado.Filas[0].Transaccion
+		assert.Equal(t, "B1", transaccion.Referencia)
+		assert.Equal(t, "egreso", transaccion.Tipo)
+		assert.Equal(t, "Iberdrola - Factura luz enero", transaccion.Descripcion)
+		assert.Equal(t, "2025-02-03", transaccion.Fecha.Format("2006-01-02"))
+		require.NotNil(t, transaccion.Bancario)
+		assert.Equal(t, "Iberdrola", transaccion.Bancario.Contraparte)
+		assert.Equal(t, "ES7620770024003102575766", transaccion.Bancario.CuentaContraparte)
+		assert.Equal(t, "Factura luz enero", transaccion.Bancario.Concepto)
+		require.NotNil(t, transaccion.Bancario.FechaValor)
+		assert.Equal(t, "2025-02-01", transaccion.Bancario.FechaValor.Format("2006-01-02"))
+
+		evt := startedEvent(t, mt, func(cmd bson.Raw) bool {
+			coleccion, _ := cmd.Lookup("insert").StringValueOK()
+			return coleccion == "transacciones"
+		})
+		require.NotNil(t, evt)
+		doc := evt.Command.Lookup("documents").Array().Index(0).Value().Document()
+		assert.Equal(t, "Iberdrola", doc.Lookup("bancario", "contraparte").StringValue())
+
+		evt = startedEvent(t, mt, func(cmd bson.Raw) bool {
+			coleccion, _ := cmd.Lookup("update").StringValueOK()
+			return coleccion == "cuentas"
+		})
+		require.NotNil(t, evt)
+		update := evt.Command.Lookup("updates").Array().Index(0).Value().Document()
+		assert.Equal(t, "ES9121000418450200051332", update.Lookup("u", "$set", "numeroCuenta").StringValue())
+	})
+}
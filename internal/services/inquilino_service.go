@@ -0,0 +1,126 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"control-financiero/internal/models"
+	"control-financiero/internal/repositories"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ErrInvalidInquilino envuelve los errores de validación de un inquilino.
+var ErrInvalidInquilino = errors.New("inquilino inválido")
+
+// ErrInquilinoEnUso se devuelve al eliminar un inquilino con contratos.
+var ErrInquilinoEnUso = errors.New("el inquilino tiene contratos")
+
+type InquilinoService struct {
+	inquilinoRepo *repositories.InquilinoRepository
+	contratoRepo  *repositories.ContratoRepository
+	audit         *AuditService
+}
+
+func NewInquilinoService(db *mongo.Database) *InquilinoService {
+	return &InquilinoService{
+		inquilinoRepo: repositories.NewInquilinoRepository(db),
+		contratoRepo:  repositories.NewContratoRepository(db),
+		audit:         NewAuditService(db),
+	}
+}
+
+// Create crea un inquilino del usuario indicado en inquilino.UsuarioID.
+func (s *InquilinoService) Create(ctx context.Context, inquilino *models.Inquilino, client models.ClientInfo) error {
+	if err := prepararInquilino(inquilino); err != nil {
+		return err
+	}
+
+	if err := s.inquilinoRepo.Create(ctx, inquilino); err != nil {
+		return err
+	}
+
+	s.audit.Record(ctx, client, s.auditEntry(models.AuditInquilinoCrear, inquilino.ID, nil, inquilino))
+	return nil
+}
+
+func (s *InquilinoService) GetAll(ctx context.Context, usuarioID primitive.ObjectID) ([]*models.Inquilino, error) {
+	return s.inquilinoRepo.FindByUsuario(ctx, usuarioID)
+}
+
+func (s *InquilinoService) GetByID(ctx context.Context, id, usuarioID primitive.ObjectID) (*models.Inquilino, error) {
+	inquilino, err := s.inquilinoRepo.FindByID(ctx, id, usuarioID)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return inquilino, nil
+}
+
+// Update modifica un inquilino del usuario indicado en inquilino.UsuarioID.
+func (s *InquilinoService) Update(ctx context.Context, inquilino *models.Inquilino, client models.ClientInfo) error {
+	existing, err := s.GetByID(ctx, inquilino.ID, inquilino.UsuarioID)
+	if err != nil {
+		return err
+	}
+
+	if err := prepararInquilino(inquilino); err != nil {
+		return err
+	}
+
+	// La fecha de creación no se puede cambiar
+	inquilino.CreatedAt = existing.CreatedAt
+
+	if err := s.inquilinoRepo.Update(ctx, inquilino); err != nil {
+		return notFound(err)
+	}
+
+	s.audit.Record(ctx, client, s.auditEntry(models.AuditInquilinoEditar, inquilino.ID, existing, inquilino))
+	return nil
+}
+
+// Delete elimina un inquilino sin contratos.
+func (s *InquilinoService) Delete(ctx context.Context, id, usuarioID primitive.ObjectID, client models.ClientInfo) error {
+	existing, err := s.GetByID(ctx, id, usuarioID)
+	if err != nil {
+		return err
+	}
+
+	n, err := s.contratoRepo.CountByInquilino(ctx, usuarioID, id)
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		return ErrInquilinoEnUso
+	}
+
+	if err := s.inquilinoRepo.Delete(ctx, id, usuarioID); err != nil {
+		return notFound(err)
+	}
+
+	s.audit.Record(ctx, client, s.auditEntry(models.AuditInquilinoEliminar, id, existing, nil))
+	return nil
+}
+
+func (s *InquilinoService) auditEntry(accion string, id primitive.ObjectID, before, after *models.Inquilino) *models.AuditLog {
+	return &models.AuditLog{
+		Accion:    accion,
+		Recurso:   "inquilino",
+		RecursoID: objectIDPtr(id),
+		Detalle:   auditDiff(before, after),
+	}
+}
+
+func prepararInquilino(inquilino *models.Inquilino) error {
+	inquilino.Nombre = strings.TrimSpace(inquilino.Nombre)
+	inquilino.Documento = strings.TrimSpace(inquilino.Documento)
+	inquilino.Email = strings.ToLower(strings.TrimSpace(inquilino.Email))
+	inquilino.Telefono = strings.TrimSpace(inquilino.Telefono)
+	inquilino.Notas = strings.TrimSpace(inquilino.Notas)
+	if inquilino.Nombre == "" {
+		return fmt.Errorf("%w: indique el nombre", ErrInvalidInquilino)
+	}
+	return nil
+}
@@ -0,0 +1,197 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"control-financiero/internal/models"
+	"control-financiero/internal/money"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/event"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+// Estas pruebas usan el despliegue simulado del driver: el servidor responde
+// como lo haría MongoDB cuando el filtro no coincide con ningún documento, y
+// se verifica que el filtro enviado incluya siempre al propietario.
+
+var (
+	propietario = primitive.NewObjectID()
+	intruso     = primitive.NewObjectID()
+)
+
+// bsonDoc convierte un modelo en el documento que devolvería MongoDB, para
+// las respuestas simuladas.
+func bsonDoc(t *testing.T, v interface{}) bson.D {
+	raw, err := bson.Marshal(v)
+	require.NoError(t, err)
+
+	var doc bson.D
+	require.NoError(t, bson.Unmarshal(raw, &doc))
+	return doc
+}
+
+// sentFilter devuelve el filtro del último comando enviado al servidor,
+// sin contar la inserción en el registro de auditoría.
+func sentFilter(t *testing.T, mt *mtest.T) bson.Raw {
+	var evt *event.CommandStartedEvent
+	for e := mt.GetStartedEvent(); e != nil; e = mt.GetStartedEvent() {
+		if e.CommandName != "insert" {
+			evt = e
+		}
+	}
+	require.NotNil(t, evt)
+
+	switch evt.CommandName {
+	case "find":
+		return evt.Command.Lookup("filter").Document()
+	case "update", "delete":
+		values, err := evt.Command.Lookup(evt.CommandName + "s").Array().Values()
+		require.NoError(t, err)
+		require.Len(t, values, 1)
+		return values[0].Document().Lookup("q").Document()
+	}
+
+	t.Fatalf("comando inesperado: %s", evt.CommandName)
+	return nil
+}
+
+func TestTransaccion_OtroUsuarioNoPuedeAcceder(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	ctx := context.Background()
+	id := primitive.NewObjectID()
+
+	mt.Run("leer", func(mt *mtest.T) {
+		s := NewTransaccionService(mt.DB)
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "test.transacciones", mtest.FirstBatch))
+
+		_, err := s.GetByID(ctx, id, intruso)
+		assert.ErrorIs(t, err, ErrNotFound)
+
+		filter := sentFilter(t, mt)
+		assert.Equal(t, id, filter.Lookup("_id").ObjectID())
+		assert.Equal(t, intruso, filter.Lookup("usuarioId").ObjectID())
+	})
+
+	mt.Run("actualizar", func(mt *mtest.T) {
+		s := NewTransaccionService(mt.DB)
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "test.transacciones", mtest.FirstBatch))
+
+		err := s.Update(ctx, &models.Transaccion{ID: id, UsuarioID: intruso, Tipo: "egreso", Monto: money.MustParse("10")}, models.ClientInfo{})
+		assert.ErrorIs(t, err, ErrNotFound)
+
+		filter := sentFilter(t, mt)
+		assert.Equal(t, intruso, filter.Lookup("usuarioId").ObjectID())
+	})
+
+	mt.Run("eliminar", func(mt *mtest.T) {
+		s := NewTransaccionService(mt.DB)
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "test.transacciones", mtest.FirstBatch))
+
+		err := s.Delete(ctx, id, intruso, models.ClientInfo{})
+		assert.ErrorIs(t, err, ErrNotFound)
+
+		filter := sentFilter(t, mt)
+		assert.Equal(t, intruso, filter.Lookup("usuarioId").ObjectID())
+	})
+}
+
+func TestCategoria_OtroUsuarioNoPuedeAcceder(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	ctx := context.Background()
+	id := primitive.NewObjectID()
+
+	mt.Run("leer", func(mt *mtest.T) {
+		s := NewCategoriaService(mt.DB)
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "test.categorias", mtest.FirstBatch))
+
+		_, err := s.GetByID(ctx, id, intruso)
+		assert.ErrorIs(t, err, ErrNotFound)
+
+		// Solo se buscan categorías globales o del propio usuario
+		filter := sentFilter(t, mt)
+		values, err := filter.Lookup("$or").Array().Values()
+		require.NoError(t, err)
+		require.Len(t, values, 2)
+		assert.Equal(t, bson.TypeNull, values[0].Document().Lookup("usuarioId").Type)
+		assert.Equal(t, intruso, values[1].Document().Lookup("usuarioId").ObjectID())
+	})
+
+	mt.Run("actualizar", func(mt *mtest.T) {
+		s := NewCategoriaService(mt.DB)
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "test.categorias", mtest.FirstBatch))
+
+		err := s.Update(ctx, &models.Categoria{ID: id, Nombre: "Robada", Tipo: "egreso"}, intruso, false, models.ClientInfo{})
+		assert.ErrorIs(t, err, ErrNotFound)
+	})
+
+	mt.Run("eliminar", func(mt *mtest.T) {
+		s := NewCategoriaService(mt.DB)
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "test.categorias", mtest.FirstBatch))
+
+		err := s.Delete(ctx, id, intruso, false, models.ClientInfo{})
+		assert.ErrorIs(t, err, ErrNotFound)
+	})
+
+	mt.Run("propia", func(mt *mtest.T) {
+		s := NewCategoriaService(mt.DB)
+		mt.AddMockResponses(
+			mtest.CreateCursorResponse(0, "test.categorias", mtest.FirstBatch, bson.D{
+				{Key: "_id", Value: id},
+				{Key: "nombre", Value: "Mascotas"},
+				{Key: "tipo", Value: "egreso"},
+				{Key: "usuarioId", Value: propietario},
+			}),
+			bson.D{{Key: "ok", Value: 1}, {Key: "n", Value: 1}},
+			mtest.CreateSuccessResponse(),
+		)
+
+		err := s.Delete(ctx, id, propietario, false, models.ClientInfo{})
+		assert.NoError(t, err)
+
+		filter := sentFilter(t, mt)
+		assert.Equal(t, propietario, filter.Lookup("usuarioId").ObjectID())
+	})
+}
+
+func TestCategoria_GlobalEsSoloLectura(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	ctx := context.Background()
+	id := primitive.NewObjectID()
+	global := bson.D{
+		{Key: "_id", Value: id},
+		{Key: "nombre", Value: "Salario"},
+		{Key: "tipo", Value: "ingreso"},
+		{Key: "usuarioId", Value: nil},
+	}
+
+	mt.Run("usuario", func(mt *mtest.T) {
+		s := NewCategoriaService(mt.DB)
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "test.categorias", mtest.FirstBatch, global))
+
+		err := s.Update(ctx, &models.Categoria{ID: id, Nombre: "Sueldo", Tipo: "ingreso"}, propietario, false, models.ClientInfo{})
+		assert.ErrorIs(t, err, ErrForbidden)
+	})
+
+	mt.Run("admin", func(mt *mtest.T) {
+		s := NewCategoriaService(mt.DB)
+		mt.AddMockResponses(
+			mtest.CreateCursorResponse(0, "test.categorias", mtest.FirstBatch, global),
+			bson.D{{Key: "ok", Value: 1}, {Key: "n", Value: 1}, {Key: "nModified", Value: 1}},
+			mtest.CreateSuccessResponse(),
+		)
+
+		categoria := &models.Categoria{ID: id, Nombre: "Sueldo", Tipo: "ingreso"}
+		err := s.Update(ctx, categoria, propietario, true, models.ClientInfo{})
+		assert.NoError(t, err)
+		assert.Nil(t, categoria.UsuarioID)
+
+		// La actualización sigue limitada a la categoría global
+		filter := sentFilter(t, mt)
+		assert.Equal(t, bson.TypeNull, filter.Lookup("usuarioId").Type)
+	})
+}
@@ -0,0 +1,155 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"control-financiero/internal/auth"
+	"control-financiero/internal/models"
+	"control-financiero/internal/repositories"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	maxPersonalAccessTokens = 20
+	// patTouchInterval limita las escrituras de lastUsedAt a una por minuto
+	// por token, aunque se use en cada petición.
+	patTouchInterval = time.Minute
+	// patPrefixLength son los caracteres del token que se guardan en claro
+	// para reconocerlo en la lista.
+	patPrefixLength = len(auth.PersonalAccessTokenPrefix) + 6
+)
+
+// ErrInvalidAccessToken cubre el token inexistente, revocado o expirado y el
+// usuario que ya no está activo.
+var ErrInvalidAccessToken = errors.New("token de acceso inválido o revocado")
+
+type PersonalAccessTokenService struct {
+	tokenRepo *repositories.PersonalAccessTokenRepository
+	userRepo  *repositories.UsuarioRepository
+	audit     *AuditService
+}
+
+func NewPersonalAccessTokenService(db *mongo.Database) *PersonalAccessTokenService {
+	return &PersonalAccessTokenService{
+		tokenRepo: repositories.NewPersonalAccessTokenRepository(db),
+		userRepo:  repositories.NewUsuarioRepository(db),
+		audit:     NewAuditService(db),
+	}
+}
+
+func (s *PersonalAccessTokenService) GetByUsuario(ctx context.Context, usuarioID primitive.ObjectID) ([]*models.PersonalAccessToken, error) {
+	return s.tokenRepo.FindByUsuario(ctx, usuarioID)
+}
+
+// Create emite un token limitado a los scopes pedidos. Un usuario solo puede
+// conceder permisos que su rol ya tiene; además, al usarlo se vuelven a
+// cruzar con los permisos vigentes del rol.
+func (s *PersonalAccessTokenService) Create(ctx context.Context, usuarioID primitive.ObjectID, permisos []string, req *models.PersonalAccessTokenRequest, client models.ClientInfo) (*models.PersonalAccessTokenResponse, error) {
+	scopes := make([]string, 0, len(req.Scopes))
+	seen := make(map[string]bool, len(req.Scopes))
+	for _, scope := range req.Scopes {
+		if !auth.ValidPermission(scope) {
+			return nil, fmt.Errorf("permiso desconocido: %s", scope)
+		}
+		if !auth.HasPermission(permisos, scope) {
+			return nil, fmt.Errorf("no puede conceder un permiso que no tiene: %s", scope)
+		}
+		if !seen[scope] {
+			seen[scope] = true
+			scopes = append(scopes, scope)
+		}
+	}
+
+	existentes, err := s.tokenRepo.FindByUsuario(ctx, usuarioID)
+	if err != nil {
+		return nil, err
+	}
+	if len(existentes) >= maxPersonalAccessTokens {
+		return nil, fmt.Errorf("se alcanzó el máximo de %d tokens; revoque alguno antes de crear otro", maxPersonalAccessTokens)
+	}
+
+	token, err := auth.GeneratePersonalAccessToken()
+	if err != nil {
+		return nil, err
+	}
+
+	pat := &models.PersonalAccessToken{
+		UsuarioID: usuarioID,
+		Nombre:    req.Nombre,
+		TokenHash: auth.HashRefreshToken(token),
+		Prefijo:   token[:patPrefixLength],
+		Scopes:    scopes,
+	}
+	if req.ExpiresInDays > 0 {
+		expiresAt := time.Now().AddDate(0, 0, req.ExpiresInDays)
+		pat.ExpiresAt = &expiresAt
+	}
+
+	if err := s.tokenRepo.Create(ctx, pat); err != nil {
+		return nil, err
+	}
+
+	s.audit.Record(ctx, client, &models.AuditLog{
+		AfectadoID: objectIDPtr(usuarioID),
+		Accion:     models.AuditTokenAccesoCrear,
+		Recurso:    "token_acceso",
+		RecursoID:  objectIDPtr(pat.ID),
+		Detalle:    auditDiff(nil, pat),
+	})
+
+	return &models.PersonalAccessTokenResponse{Token: token, AccessToken: pat}, nil
+}
+
+func (s *PersonalAccessTokenService) Revoke(ctx context.Context, usuarioID, id primitive.ObjectID, client models.ClientInfo) error {
+	revoked, err := s.tokenRepo.Revoke(ctx, id, usuarioID)
+	if err != nil {
+		return err
+	}
+	if !revoked {
+		return ErrNotFound
+	}
+
+	s.audit.Record(ctx, client, &models.AuditLog{
+		AfectadoID: objectIDPtr(usuarioID),
+		Accion:     models.AuditTokenAccesoRevocar,
+		Recurso:    "token_acceso",
+		RecursoID:  objectIDPtr(id),
+	})
+	return nil
+}
+
+// Authenticate valida un token de acceso personal y registra su uso. El rol
+// se toma del usuario actual, no del momento en que se creó el token.
+func (s *PersonalAccessTokenService) Authenticate(ctx context.Context, token, ip string) (*models.Usuario, *models.PersonalAccessToken, error) {
+	pat, err := s.tokenRepo.FindActiveByHash(ctx, auth.HashRefreshToken(token))
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, nil, ErrInvalidAccessToken
+		}
+		return nil, nil, err
+	}
+
+	usuario, err := s.userRepo.FindByID(ctx, pat.UsuarioID)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, nil, ErrInvalidAccessToken
+		}
+		return nil, nil, err
+	}
+	if usuario.Estado != "active" {
+		return nil, nil, ErrInvalidAccessToken
+	}
+
+	// No registrar el uso no es motivo para rechazar la petición
+	if err := s.tokenRepo.TouchLastUsed(ctx, pat.ID, ip, patTouchInterval); err != nil {
+		log.Println("Error registrando el uso del token de acceso:", err)
+	}
+
+	return usuario, pat, nil
+}
@@ -0,0 +1,143 @@
+package services
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"control-financiero/internal/auth"
+	"control-financiero/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+func TestPersonalAccessToken_Create(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	ctx := context.Background()
+
+	mt.Run("guarda solo el hash", func(mt *mtest.T) {
+		s := NewPersonalAccessTokenService(mt.DB)
+		mt.AddMockResponses(
+			mtest.CreateCursorResponse(0, "test.personal_access_tokens", mtest.FirstBatch),
+			mtest.CreateSuccessResponse(),
+			mtest.CreateSuccessResponse(), // auditoría
+		)
+
+		req := &models.PersonalAccessTokenRequest{
+			Nombre: "Reportes",
+			Scopes: []string{auth.PermReportesRead, auth.PermReportesRead},
+		}
+		response, err := s.Create(ctx, propietario, auth.DefaultUserPermissions, req, models.ClientInfo{})
+		require.NoError(t, err)
+		assert.True(t, strings.HasPrefix(response.Token, auth.PersonalAccessTokenPrefix))
+		assert.Equal(t, []string{auth.PermReportesRead}, response.AccessToken.Scopes)
+		assert.Nil(t, response.AccessToken.ExpiresAt)
+
+		evt := mt.GetStartedEvent()
+		for evt != nil && evt.CommandName != "insert" {
+			evt = mt.GetStartedEvent()
+		}
+		require.NotNil(t, evt)
+		doc := evt.Command.Lookup("documents").Array().Index(0).Value().Document()
+		assert.Equal(t, auth.HashRefreshToken(response.Token), doc.Lookup("tokenHash").StringValue())
+		assert.True(t, strings.HasPrefix(response.Token, doc.Lookup("prefijo").StringValue()))
+		assert.NotContains(t, doc.String(), response.Token)
+
+		// La auditoría tampoco guarda el hash
+		audit := mt.GetStartedEvent()
+		require.NotNil(t, audit)
+		require.Equal(t, "audit_logs", audit.Command.Lookup("insert").StringValue())
+		assert.NotContains(t, audit.Command.String(), "tokenHash")
+	})
+
+	mt.Run("permiso que el rol no tiene", func(mt *mtest.T) {
+		s := NewPersonalAccessTokenService(mt.DB)
+
+		req := &models.PersonalAccessTokenRequest{Nombre: "Admin", Scopes: []string{auth.PermUsuariosWrite}}
+		_, err := s.Create(ctx, propietario, auth.DefaultUserPermissions, req, models.ClientInfo{})
+		assert.Error(t, err)
+
+		req.Scopes = []string{"cuentas:borrar"}
+		_, err = s.Create(ctx, propietario, []string{auth.PermAll}, req, models.ClientInfo{})
+		assert.Error(t, err)
+	})
+}
+
+func TestPersonalAccessToken_Authenticate(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	ctx := context.Background()
+	token := auth.PersonalAccessTokenPrefix + "token-de-prueba"
+
+	patDoc := func(usuarioID primitive.ObjectID) bson.D {
+		return bson.D{
+			{Key: "_id", Value: primitive.NewObjectID()},
+			{Key: "usuarioId", Value: usuarioID},
+			{Key: "tokenHash", Value: auth.HashRefreshToken(token)},
+			{Key: "scopes", Value: bson.A{auth.PermReportesRead}},
+			{Key: "revoked", Value: false},
+		}
+	}
+
+	mt.Run("registra el uso", func(mt *mtest.T) {
+		s := NewPersonalAccessTokenService(mt.DB)
+		usuario := usuarioActivo(t)
+		mt.AddMockResponses(
+			mtest.CreateCursorResponse(0, "test.personal_access_tokens", mtest.FirstBatch, patDoc(usuario.ID)),
+			mtest.CreateCursorResponse(0, "test.usuarios", mtest.FirstBatch, bsonDoc(t, usuario)),
+			bson.D{{Key: "ok", Value: 1}, {Key: "n", Value: 1}, {Key: "nModified", Value: 1}},
+		)
+
+		autenticado, pat, err := s.Authenticate(ctx, token, "203.0.113.7")
+		require.NoError(t, err)
+		assert.Equal(t, usuario.ID, autenticado.ID)
+		assert.Equal(t, []string{auth.PermReportesRead}, pat.Scopes)
+
+		// Solo se actualiza si el último uso registrado es antiguo
+		filter := sentFilter(t, mt)
+		assert.Equal(t, pat.ID, filter.Lookup("_id").ObjectID())
+		assert.NotNil(t, filter.Lookup("$or").Value)
+	})
+
+	mt.Run("usuario suspendido", func(mt *mtest.T) {
+		s := NewPersonalAccessTokenService(mt.DB)
+		usuario := usuarioActivo(t)
+		usuario.Estado = "suspended"
+		mt.AddMockResponses(
+			mtest.CreateCursorResponse(0, "test.personal_access_tokens", mtest.FirstBatch, patDoc(usuario.ID)),
+			mtest.CreateCursorResponse(0, "test.usuarios", mtest.FirstBatch, bsonDoc(t, usuario)),
+		)
+
+		_, _, err := s.Authenticate(ctx, token, "203.0.113.7")
+		assert.ErrorIs(t, err, ErrInvalidAccessToken)
+	})
+
+	mt.Run("revocado o inexistente", func(mt *mtest.T) {
+		s := NewPersonalAccessTokenService(mt.DB)
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "test.personal_access_tokens", mtest.FirstBatch))
+
+		_, _, err := s.Authenticate(ctx, token, "203.0.113.7")
+		assert.ErrorIs(t, err, ErrInvalidAccessToken)
+
+		filter := sentFilter(t, mt)
+		assert.False(t, filter.Lookup("revoked").Boolean())
+	})
+}
+
+func TestPersonalAccessToken_RevokeAjeno(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("revocar", func(mt *mtest.T) {
+		s := NewPersonalAccessTokenService(mt.DB)
+		mt.AddMockResponses(bson.D{{Key: "ok", Value: 1}, {Key: "n", Value: 0}, {Key: "nModified", Value: 0}})
+
+		err := s.Revoke(context.Background(), intruso, primitive.NewObjectID(), models.ClientInfo{})
+		assert.ErrorIs(t, err, ErrNotFound)
+
+		filter := sentFilter(t, mt)
+		assert.Equal(t, intruso, filter.Lookup("usuarioId").ObjectID())
+	})
+}
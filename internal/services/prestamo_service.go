@@ -0,0 +1,343 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strings"
+	"time"
+
+	"control-financiero/internal/models"
+	"control-financiero/internal/money"
+	"control-financiero/internal/repositories"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Tasa nominal anual máxima de un préstamo, en porcentaje
+const prestamoTasaMaxima = 1000
+
+// ErrInvalidPrestamo envuelve los errores de validación de un préstamo y de
+// las transacciones que se enlazan con él.
+var ErrInvalidPrestamo = errors.New("préstamo inválido")
+
+// ErrPrestamoEnUso se devuelve al eliminar un préstamo con transacciones
+// enlazadas o al cambiar sus condiciones.
+var ErrPrestamoEnUso = errors.New("el préstamo tiene transacciones enlazadas")
+
+type PrestamoService struct {
+	prestamoRepo    *repositories.PrestamoRepository
+	transaccionRepo *repositories.TransaccionRepository
+	userRepo        *repositories.UsuarioRepository
+	audit           *AuditService
+}
+
+func NewPrestamoService(db *mongo.Database) *PrestamoService {
+	return &PrestamoService{
+		prestamoRepo:    repositories.NewPrestamoRepository(db),
+		transaccionRepo: repositories.NewTransaccionRepository(db),
+		userRepo:        repositories.NewUsuarioRepository(db),
+		audit:           NewAuditService(db),
+	}
+}
+
+// Create crea un préstamo del usuario indicado en prestamo.UsuarioID con su
+// cronograma de cuotas.
+func (s *PrestamoService) Create(ctx context.Context, prestamo *models.Prestamo, client models.ClientInfo) error {
+	if err := s.prepare(ctx, prestamo); err != nil {
+		return err
+	}
+
+	if err := s.prestamoRepo.Create(ctx, prestamo); err != nil {
+		return err
+	}
+
+	resumirPrestamo(prestamo, nil, truncateDia(time.Now()))
+	s.audit.Record(ctx, client, s.auditEntry(models.AuditPrestamoCrear, prestamo.ID, nil, prestamo))
+	return nil
+}
+
+// GetAll devuelve los préstamos del usuario con lo pagado de cada cuota.
+func (s *PrestamoService) GetAll(ctx context.Context, usuarioID primitive.ObjectID) ([]*models.Prestamo, error) {
+	prestamos, err := s.prestamoRepo.FindByUsuario(ctx, usuarioID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.resumir(ctx, usuarioID, prestamos...); err != nil {
+		return nil, err
+	}
+	return prestamos, nil
+}
+
+// GetByID devuelve un préstamo del usuario con lo pagado de cada cuota.
+func (s *PrestamoService) GetByID(ctx context.Context, id, usuarioID primitive.ObjectID) (*models.Prestamo, error) {
+	prestamo, err := s.prestamoRepo.FindByID(ctx, id, usuarioID)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	if err := s.resumir(ctx, usuarioID, prestamo); err != nil {
+		return nil, err
+	}
+	return prestamo, nil
+}
+
+// Update modifica un préstamo del usuario indicado en prestamo.UsuarioID.
+// Con transacciones enlazadas solo cambian el nombre y la contraparte: otras
+// condiciones cambiarían el cronograma que ya se está pagando.
+func (s *PrestamoService) Update(ctx context.Context, prestamo *models.Prestamo, client models.ClientInfo) error {
+	existing, err := s.GetByID(ctx, prestamo.ID, prestamo.UsuarioID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.prepare(ctx, prestamo); err != nil {
+		return err
+	}
+	if !mismasCondiciones(prestamo, existing) {
+		n, err := s.transaccionRepo.CountByPrestamo(ctx, prestamo.UsuarioID, prestamo.ID)
+		if err != nil {
+			return err
+		}
+		if n > 0 {
+			return fmt.Errorf("%w: solo se pueden cambiar el nombre y la contraparte", ErrPrestamoEnUso)
+		}
+	}
+
+	// La fecha de creación no se puede cambiar
+	prestamo.CreatedAt = existing.CreatedAt
+
+	if err := s.prestamoRepo.Update(ctx, prestamo); err != nil {
+		return notFound(err)
+	}
+
+	if err := s.resumir(ctx, prestamo.UsuarioID, prestamo); err != nil {
+		return err
+	}
+	s.audit.Record(ctx, client, s.auditEntry(models.AuditPrestamoEditar, prestamo.ID, existing, prestamo))
+	return nil
+}
+
+// Delete elimina un préstamo sin transacciones enlazadas.
+func (s *PrestamoService) Delete(ctx context.Context, id, usuarioID primitive.ObjectID, client models.ClientInfo) error {
+	existing, err := s.GetByID(ctx, id, usuarioID)
+	if err != nil {
+		return err
+	}
+
+	n, err := s.transaccionRepo.CountByPrestamo(ctx, usuarioID, id)
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		return fmt.Errorf("%w: elimine antes el desembolso y los pagos", ErrPrestamoEnUso)
+	}
+
+	if err := s.prestamoRepo.Delete(ctx, id, usuarioID); err != nil {
+		return notFound(err)
+	}
+
+	s.audit.Record(ctx, client, s.auditEntry(models.AuditPrestamoEliminar, id, existing, nil))
+	return nil
+}
+
+func (s *PrestamoService) auditEntry(accion string, id primitive.ObjectID, before, after *models.Prestamo) *models.AuditLog {
+	return &models.AuditLog{
+		Accion:    accion,
+		Recurso:   "prestamo",
+		RecursoID: objectIDPtr(id),
+		Detalle:   auditDiff(before, after),
+	}
+}
+
+// prepare valida el préstamo, redondea el principal a los decimales de su
+// moneda y calcula el cronograma. Sin moneda se usa la moneda base.
+func (s *PrestamoService) prepare(ctx context.Context, prestamo *models.Prestamo) error {
+	prestamo.Nombre = strings.TrimSpace(prestamo.Nombre)
+	prestamo.Contraparte = strings.TrimSpace(prestamo.Contraparte)
+	if prestamo.Nombre == "" || prestamo.Contraparte == "" {
+		return fmt.Errorf("%w: indique el nombre y la contraparte", ErrInvalidPrestamo)
+	}
+
+	if prestamo.Moneda == "" {
+		usuario, err := s.userRepo.FindByID(ctx, prestamo.UsuarioID)
+		if err != nil {
+			return notFound(err)
+		}
+		prestamo.Moneda = usuario.Moneda()
+	}
+	moneda, err := normalizeMoneda(prestamo.Moneda)
+	if err != nil {
+		return err
+	}
+	prestamo.Moneda = moneda
+	prestamo.Principal = prestamo.Principal.Round(moneda)
+	if prestamo.Principal <= 0 {
+		return ErrInvalidMonto
+	}
+	if prestamo.TasaAnual < 0 || prestamo.TasaAnual > prestamoTasaMaxima*money.OneRate {
+		return fmt.Errorf("%w: la tasa anual debe estar entre 0 y %d %%", ErrInvalidPrestamo, prestamoTasaMaxima)
+	}
+	prestamo.Inicio = truncateDia(prestamo.Inicio)
+
+	prestamo.Cuotas, err = tablaAmortizacion(prestamo)
+	return err
+}
+
+// resumir calcula lo pagado de cada cuota y el resumen de los préstamos,
+// todos del mismo usuario.
+func (s *PrestamoService) resumir(ctx context.Context, usuarioID primitive.ObjectID, prestamos ...*models.Prestamo) error {
+	if len(prestamos) == 0 {
+		return nil
+	}
+	ids := make([]primitive.ObjectID, len(prestamos))
+	for i, p := range prestamos {
+		ids[i] = p.ID
+	}
+
+	pagos, err := s.transaccionRepo.SumPagosPrestamos(ctx, usuarioID, ids, nil)
+	if err != nil {
+		return err
+	}
+	porPrestamo := make(map[primitive.ObjectID]map[int]money.Amount, len(prestamos))
+	for _, p := range pagos {
+		if porPrestamo[p.PrestamoID] == nil {
+			porPrestamo[p.PrestamoID] = make(map[int]money.Amount)
+		}
+		porPrestamo[p.PrestamoID][p.Cuota] = p.Pagado
+	}
+
+	hoy := truncateDia(time.Now())
+	for _, p := range prestamos {
+		resumirPrestamo(p, porPrestamo[p.ID], hoy)
+	}
+	return nil
+}
+
+// resumirPrestamo reparte lo pagado de cada cuota entre el interés y el
+// capital, en ese orden, y marca como vencidas las cuotas impagas cuyo
+// vencimiento es anterior a hoy. pagos tiene lo pagado por número de cuota y
+// lo desembolsado en la cuota 0.
+func resumirPrestamo(prestamo *models.Prestamo, pagos map[int]money.Amount, hoy time.Time) {
+	prestamo.Desembolsado = pagos[0]
+	prestamo.CapitalPagado, prestamo.InteresPagado = 0, 0
+	prestamo.CuotasVencidas, prestamo.MontoVencido = 0, 0
+
+	for i := range prestamo.Cuotas {
+		cuota := &prestamo.Cuotas[i]
+		cuota.Pagado = pagos[cuota.Numero]
+		interes := min(cuota.Pagado, cuota.Interes)
+		prestamo.InteresPagado += interes
+		prestamo.CapitalPagado += min(cuota.Pagado-interes, cuota.Capital)
+
+		switch {
+		case cuota.Pagado >= cuota.Total:
+			cuota.Estado = models.CuotaPagada
+		case cuota.Vencimiento.Before(hoy):
+			cuota.Estado = models.CuotaVencida
+			prestamo.CuotasVencidas++
+			prestamo.MontoVencido += cuota.Total - cuota.Pagado
+		case cuota.Pagado > 0:
+			cuota.Estado = models.CuotaParcial
+		default:
+			cuota.Estado = models.CuotaPendiente
+		}
+	}
+	prestamo.Saldo = prestamo.Principal - prestamo.CapitalPagado
+}
+
+// mismasCondiciones indica si dos versiones de un préstamo tienen el mismo
+// cronograma y sentido.
+func mismasCondiciones(a, b *models.Prestamo) bool {
+	return a.Sentido == b.Sentido && a.Principal == b.Principal && a.Moneda == b.Moneda &&
+		a.TasaAnual == b.TasaAnual && a.Plazo == b.Plazo && a.Sistema == b.Sistema && a.Inicio.Equal(b.Inicio)
+}
+
+// tablaAmortizacion calcula el cronograma de cuotas mensuales del préstamo
+// con la tasa mensual, que es la nominal anual dividida entre 12. Cada
+// importe se redondea a los decimales de la moneda y la última cuota
+// amortiza el capital que quede, así las amortizaciones suman exactamente el
+// principal.
+func tablaAmortizacion(prestamo *models.Prestamo) ([]models.CuotaPrestamo, error) {
+	n := prestamo.Plazo
+	if n < 1 {
+		return nil, fmt.Errorf("%w: el plazo debe ser de al menos una cuota", ErrInvalidPrestamo)
+	}
+	principal := new(big.Rat).SetInt64(int64(prestamo.Principal))
+	// TasaAnual es un porcentaje en unidades de 10^-RateScale
+	tasa := big.NewRat(int64(prestamo.TasaAnual), 12*100*int64(money.OneRate))
+
+	var cuotaFija, amortizacionFija money.Amount
+	switch prestamo.Sistema {
+	case models.AmortizacionFrancesa:
+		cuota := new(big.Rat).Quo(principal, big.NewRat(int64(n), 1))
+		if tasa.Sign() > 0 {
+			// cuota = P·i·(1+i)^n / ((1+i)^n − 1)
+			exp := big.NewInt(int64(n))
+			factor := new(big.Rat).SetFrac(
+				new(big.Int).Exp(new(big.Int).Add(tasa.Denom(), tasa.Num()), exp, nil),
+				new(big.Int).Exp(tasa.Denom(), exp, nil),
+			)
+			cuota.Mul(principal, tasa)
+			cuota.Mul(cuota, factor)
+			cuota.Quo(cuota, factor.Sub(factor, big.NewRat(1, 1)))
+		}
+		cuotaFija = redondearRat(cuota, prestamo.Moneda)
+	case models.AmortizacionAlemana:
+		amortizacionFija = redondearRat(new(big.Rat).Quo(principal, big.NewRat(int64(n), 1)), prestamo.Moneda)
+	default:
+		return nil, fmt.Errorf("%w: sistema de amortización desconocido", ErrInvalidPrestamo)
+	}
+
+	cuotas := make([]models.CuotaPrestamo, n)
+	saldo := prestamo.Principal
+	for i := range cuotas {
+		interes := redondearRat(new(big.Rat).Mul(new(big.Rat).SetInt64(int64(saldo)), tasa), prestamo.Moneda)
+		capital := amortizacionFija
+		if prestamo.Sistema == models.AmortizacionFrancesa {
+			capital = max(cuotaFija-interes, 0)
+		}
+		if i == n-1 || capital > saldo {
+			capital = saldo
+		}
+		saldo -= capital
+
+		cuotas[i] = models.CuotaPrestamo{
+			Numero:      i + 1,
+			Vencimiento: sumarMeses(prestamo.Inicio, i+1),
+			Capital:     capital,
+			Interes:     interes,
+			Total:       capital + interes,
+			Saldo:       saldo,
+		}
+	}
+	return cuotas, nil
+}
+
+// redondearRat redondea x, en unidades de money.Amount, a los decimales de
+// la moneda con los empates hacia el lado contrario al cero, como
+// money.Amount.Round.
+func redondearRat(x *big.Rat, moneda string) money.Amount {
+	paso := int64(math.Pow10(money.Scale - money.Decimals(moneda)))
+	// round(x / paso) = trunc((2·num ± den·paso) / (2·den·paso))
+	medio := new(big.Int).Mul(x.Denom(), big.NewInt(paso))
+	num := new(big.Int).Lsh(x.Num(), 1)
+	if num.Sign() >= 0 {
+		num.Add(num, medio)
+	} else {
+		num.Sub(num, medio)
+	}
+	q := num.Quo(num, medio.Lsh(medio, 1))
+	return money.Amount(q.Int64() * paso)
+}
+
+// sumarMeses suma n meses a fecha. Si el día no existe en el mes de llegada
+// usa el último: el 31 de enero más un mes es el 28 o 29 de febrero.
+func sumarMeses(fecha time.Time, n int) time.Time {
+	y, m, d := fecha.Date()
+	primero := time.Date(y, m+time.Month(n), 1, 0, 0, 0, 0, time.UTC)
+	ultimo := primero.AddDate(0, 1, -1).Day()
+	return time.Date(primero.Year(), primero.Month(), min(d, ultimo), 0, 0, 0, 0, time.UTC)
+}
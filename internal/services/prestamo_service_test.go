@@ -0,0 +1,327 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"control-financiero/internal/models"
+	"control-financiero/internal/money"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+func pagosResponse(prestamoID primitive.ObjectID, pagos map[int]string) bson.D {
+	docs := make([]bson.D, 0, len(pagos))
+	for cuota, pagado := range pagos {
+		docs = append(docs, bson.D{
+			{Key: "prestamoId", Value: prestamoID},
+			{Key: "cuota", Value: cuota},
+			{Key: "pagado", Value: money.MustParse(pagado)},
+		})
+	}
+	return mtest.CreateCursorResponse(0, "test.transacciones", mtest.FirstBatch, docs...)
+}
+
+func TestTablaAmortizacion(t *testing.T) {
+	inicio := time.Date(2025, 1, 31, 0, 0, 0, 0, time.UTC)
+	nuevo := func(sistema, principal string, tasa money.Rate, moneda string, plazo int) *models.Prestamo {
+		return &models.Prestamo{
+			Principal: money.MustParse(principal),
+			TasaAnual: tasa,
+			Plazo:     plazo,
+			Sistema:   sistema,
+			Moneda:    moneda,
+			Inicio:    inicio,
+		}
+	}
+	sumaCapital := func(cuotas []models.CuotaPrestamo) money.Amount {
+		var suma money.Amount
+		for _, c := range cuotas {
+			suma += c.Capital
+		}
+		return suma
+	}
+
+	t.Run("francés", func(t *testing.T) {
+		cuotas, err := tablaAmortizacion(nuevo(models.AmortizacionFrancesa, "10000", money.MustParseRate("12"), "PEN", 12))
+		require.NoError(t, err)
+		require.Len(t, cuotas, 12)
+		assert.Equal(t, "888.49", cuotas[0].Total.String())
+		assert.Equal(t, "100", cuotas[0].Interes.String())
+		assert.Equal(t, "788.49", cuotas[0].Capital.String())
+		assert.Equal(t, "9211.51", cuotas[0].Saldo.String())
+		assert.Equal(t, "92.12", cuotas[1].Interes.String())
+		assert.Equal(t, "888.49", cuotas[1].Total.String())
+
+		// La última cuota cierra el saldo y apenas difiere de las demás
+		assert.Equal(t, money.MustParse("10000"), sumaCapital(cuotas))
+		assert.Zero(t, cuotas[11].Saldo)
+		assert.InDelta(t, int64(money.MustParse("888.49")), int64(cuotas[11].Total), float64(money.MustParse("0.05")))
+
+		// El día 31 vence el último día de los meses más cortos
+		assert.Equal(t, time.Date(2025, 2, 28, 0, 0, 0, 0, time.UTC), cuotas[0].Vencimiento)
+		assert.Equal(t, time.Date(2025, 3, 31, 0, 0, 0, 0, time.UTC), cuotas[1].Vencimiento)
+		assert.Equal(t, time.Date(2026, 1, 31, 0, 0, 0, 0, time.UTC), cuotas[11].Vencimiento)
+	})
+
+	t.Run("francés sin interés", func(t *testing.T) {
+		cuotas, err := tablaAmortizacion(nuevo(models.AmortizacionFrancesa, "1000", 0, "PEN", 3))
+		require.NoError(t, err)
+		require.Len(t, cuotas, 3)
+		assert.Equal(t, "333.33", cuotas[0].Total.String())
+		assert.Equal(t, "333.33", cuotas[1].Total.String())
+		assert.Equal(t, "333.34", cuotas[2].Total.String())
+		assert.Zero(t, cuotas[2].Interes)
+	})
+
+	t.Run("francés en una moneda sin decimales", func(t *testing.T) {
+		cuotas, err := tablaAmortizacion(nuevo(models.AmortizacionFrancesa, "100000", money.MustParseRate("12"), "JPY", 12))
+		require.NoError(t, err)
+		assert.Equal(t, "8885", cuotas[0].Total.String())
+		assert.Equal(t, "1000", cuotas[0].Interes.String())
+		assert.Equal(t, money.MustParse("100000"), sumaCapital(cuotas))
+	})
+
+	t.Run("alemán", func(t *testing.T) {
+		cuotas, err := tablaAmortizacion(nuevo(models.AmortizacionAlemana, "12000", money.MustParseRate("12"), "PEN", 12))
+		require.NoError(t, err)
+		require.Len(t, cuotas, 12)
+		for _, c := range cuotas {
+			assert.Equal(t, "1000", c.Capital.String())
+		}
+		assert.Equal(t, "120", cuotas[0].Interes.String())
+		assert.Equal(t, "1120", cuotas[0].Total.String())
+		assert.Equal(t, "110", cuotas[1].Interes.String())
+		assert.Equal(t, "10", cuotas[11].Interes.String())
+		assert.Zero(t, cuotas[11].Saldo)
+	})
+
+	t.Run("alemán con resto", func(t *testing.T) {
+		cuotas, err := tablaAmortizacion(nuevo(models.AmortizacionAlemana, "100", 0, "PEN", 3))
+		require.NoError(t, err)
+		assert.Equal(t, "33.33", cuotas[0].Capital.String())
+		assert.Equal(t, "33.34", cuotas[2].Capital.String())
+	})
+}
+
+func TestResumirPrestamo(t *testing.T) {
+	vence := func(mes time.Month) time.Time { return time.Date(2025, mes, 1, 0, 0, 0, 0, time.UTC) }
+	prestamo := &models.Prestamo{
+		Principal: money.MustParse("3000"),
+		Cuotas: []models.CuotaPrestamo{
+			{Numero: 1, Vencimiento: vence(2), Capital: money.MustParse("1000"), Interes: money.MustParse("30"), Total: money.MustParse("1030")},
+			{Numero: 2, Vencimiento: vence(3), Capital: money.MustParse("1000"), Interes: money.MustParse("20"), Total: money.MustParse("1020")},
+			{Numero: 3, Vencimiento: vence(4), Capital: money.MustParse("1000"), Interes: money.MustParse("10"), Total: money.MustParse("1010")},
+		},
+	}
+	pagos := map[int]money.Amount{
+		0: money.MustParse("3000"),
+		1: money.MustParse("1030"),
+		2: money.MustParse("15"),
+		3: money.MustParse("500"),
+	}
+
+	resumirPrestamo(prestamo, pagos, time.Date(2025, 3, 15, 0, 0, 0, 0, time.UTC))
+
+	assert.Equal(t, models.CuotaPagada, prestamo.Cuotas[0].Estado)
+	assert.Equal(t, models.CuotaVencida, prestamo.Cuotas[1].Estado)
+	assert.Equal(t, models.CuotaParcial, prestamo.Cuotas[2].Estado)
+	assert.Equal(t, "3000", prestamo.Desembolsado.String())
+	// Los pagos cubren primero el interés: 30 + 15 + 10
+	assert.Equal(t, "55", prestamo.InteresPagado.String())
+	assert.Equal(t, "1490", prestamo.CapitalPagado.String())
+	assert.Equal(t, "1510", prestamo.Saldo.String())
+	assert.Equal(t, 1, prestamo.CuotasVencidas)
+	assert.Equal(t, "1005", prestamo.MontoVencido.String())
+}
+
+func TestPrestamo_Create(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	ctx := context.Background()
+
+	mt.Run("calcula la moneda y el cronograma", func(mt *mtest.T) {
+		s := NewPrestamoService(mt.DB)
+		mt.AddMockResponses(propietarioResponse(t, "PEN"), mtest.CreateSuccessResponse(), mtest.CreateSuccessResponse())
+
+		prestamo := &models.Prestamo{
+			UsuarioID:   propietario,
+			Nombre:      " Auto ",
+			Contraparte: "Banco",
+			Sentido:     models.PrestamoRecibido,
+			Principal:   money.MustParse("5000.005"),
+			Plazo:       2,
+			Sistema:     models.AmortizacionAlemana,
+			Inicio:      time.Date(2025, 1, 10, 15, 0, 0, 0, time.UTC),
+		}
+		require.NoError(t, s.Create(ctx, prestamo, models.ClientInfo{}))
+		assert.Equal(t, "Auto", prestamo.Nombre)
+		assert.Equal(t, "PEN", prestamo.Moneda)
+		assert.Equal(t, "5000.01", prestamo.Principal.String())
+		assert.Equal(t, time.Date(2025, 1, 10, 0, 0, 0, 0, time.UTC), prestamo.Inicio)
+		require.Len(t, prestamo.Cuotas, 2)
+		assert.Equal(t, "5000.01", prestamo.Saldo.String())
+	})
+
+	mt.Run("rechaza tasas fuera de rango", func(mt *mtest.T) {
+		s := NewPrestamoService(mt.DB)
+		prestamo := &models.Prestamo{
+			UsuarioID:   propietario,
+			Nombre:      "Usura",
+			Contraparte: "Alguien",
+			Sentido:     models.PrestamoRecibido,
+			Principal:   money.MustParse("100"),
+			Moneda:      "PEN",
+			TasaAnual:   money.MustParseRate("1000.5"),
+			Plazo:       12,
+			Sistema:     models.AmortizacionFrancesa,
+		}
+		assert.ErrorIs(t, s.Create(ctx, prestamo, models.ClientInfo{}), ErrInvalidPrestamo)
+	})
+}
+
+func TestPrestamo_Update(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	ctx := context.Background()
+	existing := &models.Prestamo{
+		ID:          primitive.NewObjectID(),
+		UsuarioID:   propietario,
+		Nombre:      "Auto",
+		Contraparte: "Banco",
+		Sentido:     models.PrestamoRecibido,
+		Principal:   money.MustParse("1200"),
+		Moneda:      "PEN",
+		Plazo:       12,
+		Sistema:     models.AmortizacionAlemana,
+		Inicio:      time.Date(2025, 1, 10, 0, 0, 0, 0, time.UTC),
+	}
+	var err error
+	existing.Cuotas, err = tablaAmortizacion(existing)
+	require.NoError(t, err)
+
+	mt.Run("con pagos no cambian las condiciones", func(mt *mtest.T) {
+		s := NewPrestamoService(mt.DB)
+		mt.AddMockResponses(
+			mtest.CreateCursorResponse(0, "test.prestamos", mtest.FirstBatch, bsonDoc(t, existing)),
+			pagosResponse(existing.ID, map[int]string{1: "100"}),
+			countResponse(1),
+		)
+
+		prestamo := *existing
+		prestamo.Plazo = 24
+		assert.ErrorIs(t, s.Update(ctx, &prestamo, models.ClientInfo{}), ErrPrestamoEnUso)
+	})
+
+	mt.Run("con pagos cambia el nombre", func(mt *mtest.T) {
+		s := NewPrestamoService(mt.DB)
+		mt.AddMockResponses(
+			mtest.CreateCursorResponse(0, "test.prestamos", mtest.FirstBatch, bsonDoc(t, existing)),
+			pagosResponse(existing.ID, map[int]string{1: "100"}),
+			mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}, bson.E{Key: "nModified", Value: 1}),
+			pagosResponse(existing.ID, map[int]string{1: "100"}),
+			mtest.CreateSuccessResponse(),
+		)
+
+		prestamo := *existing
+		prestamo.Nombre = "Auto nuevo"
+		require.NoError(t, s.Update(ctx, &prestamo, models.ClientInfo{}))
+		assert.Equal(t, "100", prestamo.Cuotas[0].Pagado.String())
+	})
+}
+
+func TestTransaccion_Prestamo(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	ctx := context.Background()
+	prestamo := &models.Prestamo{
+		ID:        primitive.NewObjectID(),
+		UsuarioID: propietario,
+		Sentido:   models.PrestamoRecibido,
+		Principal: money.MustParse("3000"),
+		Moneda:    "PEN",
+		Plazo:     3,
+		Sistema:   models.AmortizacionAlemana,
+		TasaAnual: money.MustParseRate("12"),
+		Inicio:    time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+	var err error
+	prestamo.Cuotas, err = tablaAmortizacion(prestamo)
+	require.NoError(t, err)
+	require.Equal(t, "1030", prestamo.Cuotas[0].Total.String())
+
+	pago := func(monto string, cuota int) *models.Transaccion {
+		return &models.Transaccion{
+			UsuarioID: propietario,
+			Tipo:      "prestamo",
+			Monto:     money.MustParse(monto),
+			Fecha:     time.Date(2025, 2, 1, 0, 0, 0, 0, time.UTC),
+			Prestamo:  &models.EnlacePrestamo{ID: prestamo.ID, Cuota: cuota},
+		}
+	}
+
+	mt.Run("el pago de un préstamo recibido sale de la cuenta", func(mt *mtest.T) {
+		s := NewTransaccionService(mt.DB)
+		mt.AddMockResponses(
+			propietarioResponse(t, "PEN"),
+			mtest.CreateCursorResponse(0, "test.prestamos", mtest.FirstBatch, bsonDoc(t, prestamo)),
+			pagosResponse(prestamo.ID, map[int]string{0: "3000", 1: "500"}),
+			mtest.CreateSuccessResponse(),
+			mtest.CreateSuccessResponse(),
+		)
+
+		transaccion := pago("530", 1)
+		require.NoError(t, s.Create(ctx, transaccion, models.ClientInfo{}))
+		assert.Equal(t, models.PrestamoSalida, transaccion.Prestamo.Sentido)
+		assert.Equal(t, "-530", transaccion.Importe().String())
+	})
+
+	mt.Run("el desembolso de un préstamo recibido entra en la cuenta", func(mt *mtest.T) {
+		s := NewTransaccionService(mt.DB)
+		mt.AddMockResponses(
+			propietarioResponse(t, "PEN"),
+			mtest.CreateCursorResponse(0, "test.prestamos", mtest.FirstBatch, bsonDoc(t, prestamo)),
+			pagosResponse(prestamo.ID, nil),
+			mtest.CreateSuccessResponse(),
+			mtest.CreateSuccessResponse(),
+		)
+
+		transaccion := pago("3000", 0)
+		require.NoError(t, s.Create(ctx, transaccion, models.ClientInfo{}))
+		assert.Equal(t, models.PrestamoEntrada, transaccion.Prestamo.Sentido)
+	})
+
+	mt.Run("no se paga más que la cuota", func(mt *mtest.T) {
+		s := NewTransaccionService(mt.DB)
+		mt.AddMockResponses(
+			propietarioResponse(t, "PEN"),
+			mtest.CreateCursorResponse(0, "test.prestamos", mtest.FirstBatch, bsonDoc(t, prestamo)),
+			pagosResponse(prestamo.ID, map[int]string{1: "500"}),
+		)
+
+		err := s.Create(ctx, pago("530.01", 1), models.ClientInfo{})
+		require.ErrorIs(t, err, ErrInvalidPrestamo)
+		assert.Contains(t, err.Error(), "530")
+	})
+
+	mt.Run("la cuota debe existir", func(mt *mtest.T) {
+		s := NewTransaccionService(mt.DB)
+		mt.AddMockResponses(
+			propietarioResponse(t, "PEN"),
+			mtest.CreateCursorResponse(0, "test.prestamos", mtest.FirstBatch, bsonDoc(t, prestamo)),
+		)
+
+		assert.ErrorIs(t, s.Create(ctx, pago("10", 4), models.ClientInfo{}), ErrInvalidPrestamo)
+	})
+
+	mt.Run("solo se enlazan transacciones de tipo prestamo", func(mt *mtest.T) {
+		s := NewTransaccionService(mt.DB)
+		mt.AddMockResponses(propietarioResponse(t, "PEN"))
+
+		transaccion := pago("10", 1)
+		transaccion.Tipo = "egreso"
+		assert.ErrorIs(t, s.Create(ctx, transaccion, models.ClientInfo{}), ErrInvalidPrestamo)
+	})
+}
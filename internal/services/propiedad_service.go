@@ -0,0 +1,255 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"control-financiero/internal/models"
+	"control-financiero/internal/money"
+	"control-financiero/internal/repositories"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ErrInvalidPropiedad envuelve los errores de validación de una propiedad y
+// de las transacciones que se asignan a ella.
+var ErrInvalidPropiedad = errors.New("propiedad inválida")
+
+// ErrPropiedadEnUso se devuelve al eliminar una propiedad con contratos o
+// transacciones, o al quitar una unidad con contratos.
+var ErrPropiedadEnUso = errors.New("la propiedad tiene contratos o transacciones")
+
+type PropiedadService struct {
+	propiedadRepo   *repositories.PropiedadRepository
+	contratoRepo    *repositories.ContratoRepository
+	transaccionRepo *repositories.TransaccionRepository
+	userRepo        *repositories.UsuarioRepository
+	rates           *ExchangeRateService
+	audit           *AuditService
+}
+
+func NewPropiedadService(db *mongo.Database) *PropiedadService {
+	return &PropiedadService{
+		propiedadRepo:   repositories.NewPropiedadRepository(db),
+		contratoRepo:    repositories.NewContratoRepository(db),
+		transaccionRepo: repositories.NewTransaccionRepository(db),
+		userRepo:        repositories.NewUsuarioRepository(db),
+		rates:           NewExchangeRateService(db),
+		audit:           NewAuditService(db),
+	}
+}
+
+// Create crea una propiedad del usuario indicado en propiedad.UsuarioID.
+func (s *PropiedadService) Create(ctx context.Context, propiedad *models.Propiedad, client models.ClientInfo) error {
+	if err := prepararPropiedad(propiedad, nil); err != nil {
+		return err
+	}
+
+	if err := s.propiedadRepo.Create(ctx, propiedad); err != nil {
+		return err
+	}
+
+	s.audit.Record(ctx, client, s.auditEntry(models.AuditPropiedadCrear, propiedad.ID, nil, propiedad))
+	return nil
+}
+
+func (s *PropiedadService) GetAll(ctx context.Context, usuarioID primitive.ObjectID) ([]*models.Propiedad, error) {
+	return s.propiedadRepo.FindByUsuario(ctx, usuarioID)
+}
+
+func (s *PropiedadService) GetByID(ctx context.Context, id, usuarioID primitive.ObjectID) (*models.Propiedad, error) {
+	propiedad, err := s.propiedadRepo.FindByID(ctx, id, usuarioID)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return propiedad, nil
+}
+
+// Update modifica una propiedad del usuario indicado en propiedad.UsuarioID.
+// Las unidades sin ID son nuevas; las que faltan se quitan si no tienen
+// contratos.
+func (s *PropiedadService) Update(ctx context.Context, propiedad *models.Propiedad, client models.ClientInfo) error {
+	existing, err := s.GetByID(ctx, propiedad.ID, propiedad.UsuarioID)
+	if err != nil {
+		return err
+	}
+
+	if err := prepararPropiedad(propiedad, existing); err != nil {
+		return err
+	}
+
+	quitadas := make(map[primitive.ObjectID]bool)
+	for _, u := range existing.Unidades {
+		quitadas[u.ID] = true
+	}
+	for _, u := range propiedad.Unidades {
+		delete(quitadas, u.ID)
+	}
+	if len(quitadas) > 0 {
+		contratos, err := s.contratoRepo.Find(ctx, propiedad.UsuarioID, &propiedad.ID, nil)
+		if err != nil {
+			return err
+		}
+		for _, c := range contratos {
+			if c.UnidadID != nil && quitadas[*c.UnidadID] {
+				return fmt.Errorf("%w: no se puede quitar una unidad con contratos", ErrPropiedadEnUso)
+			}
+		}
+	}
+
+	// La fecha de creación no se puede cambiar
+	propiedad.CreatedAt = existing.CreatedAt
+
+	if err := s.propiedadRepo.Update(ctx, propiedad); err != nil {
+		return notFound(err)
+	}
+
+	s.audit.Record(ctx, client, s.auditEntry(models.AuditPropiedadEditar, propiedad.ID, existing, propiedad))
+	return nil
+}
+
+// Delete elimina una propiedad sin contratos ni transacciones asignadas.
+func (s *PropiedadService) Delete(ctx context.Context, id, usuarioID primitive.ObjectID, client models.ClientInfo) error {
+	existing, err := s.GetByID(ctx, id, usuarioID)
+	if err != nil {
+		return err
+	}
+
+	n, err := s.contratoRepo.CountByPropiedad(ctx, usuarioID, id)
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		if n, err = s.transaccionRepo.CountByPropiedad(ctx, usuarioID, id); err != nil {
+			return err
+		}
+	}
+	if n > 0 {
+		return ErrPropiedadEnUso
+	}
+
+	if err := s.propiedadRepo.Delete(ctx, id, usuarioID); err != nil {
+		return notFound(err)
+	}
+
+	s.audit.Record(ctx, client, s.auditEntry(models.AuditPropiedadEliminar, id, existing, nil))
+	return nil
+}
+
+// Resultados es el estado de resultados de la propiedad entre desde y hasta,
+// que pueden ser nil, en la moneda base del usuario. Cada transacción se
+// convierte con el tipo de cambio de su fecha y cada cargo esperado con el de
+// su vencimiento.
+func (s *PropiedadService) Resultados(ctx context.Context, id, usuarioID primitive.ObjectID, desde, hasta *time.Time) (*models.ResultadosPropiedad, error) {
+	if _, err := s.GetByID(ctx, id, usuarioID); err != nil {
+		return nil, err
+	}
+	usuario, err := s.userRepo.FindByID(ctx, usuarioID)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	resultados := &models.ResultadosPropiedad{
+		PropiedadID:  id,
+		Moneda:       usuario.Moneda(),
+		Desde:        desde,
+		Hasta:        hasta,
+		PorCategoria: make(map[string]money.Amount),
+	}
+
+	totales, err := s.transaccionRepo.SumByPropiedad(ctx, usuarioID, id, desde, hasta)
+	if err != nil {
+		return nil, err
+	}
+	tasas := make(map[string]money.Rate)
+	for _, t := range totales {
+		total, err := totalEnBase(ctx, s.rates, t, resultados.Moneda, tasas)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case t.Cobro:
+			resultados.Alquileres += total
+		case t.Tipo == "ingreso":
+			resultados.Ingresos += total
+		case t.Tipo == "egreso":
+			resultados.Egresos += total
+		default:
+			continue
+		}
+		resultados.PorCategoria[t.CategoriaID.Hex()] += total
+	}
+	resultados.Resultado = resultados.Alquileres + resultados.Ingresos - resultados.Egresos
+
+	contratos, err := s.contratoRepo.Find(ctx, usuarioID, &id, nil)
+	if err != nil {
+		return nil, err
+	}
+	limite := truncateDia(time.Now())
+	if hasta != nil {
+		limite = *hasta
+	}
+	for _, c := range contratos {
+		for _, cargo := range cargosContrato(c, limite) {
+			if (desde != nil && cargo.Vencimiento.Before(*desde)) || cargo.Vencimiento.After(limite) {
+				continue
+			}
+			monto, _, err := s.rates.Convert(ctx, cargo.Monto, c.Moneda, resultados.Moneda, cargo.Vencimiento)
+			if err != nil {
+				return nil, err
+			}
+			resultados.RentaEsperada += monto
+		}
+	}
+	return resultados, nil
+}
+
+func (s *PropiedadService) auditEntry(accion string, id primitive.ObjectID, before, after *models.Propiedad) *models.AuditLog {
+	return &models.AuditLog{
+		Accion:    accion,
+		Recurso:   "propiedad",
+		RecursoID: objectIDPtr(id),
+		Detalle:   auditDiff(before, after),
+	}
+}
+
+// prepararPropiedad valida la propiedad y asigna un ID a las unidades
+// nuevas. existing es la versión guardada al editar, o nil al crear: las
+// unidades con ID deben ser suyas.
+func prepararPropiedad(propiedad, existing *models.Propiedad) error {
+	propiedad.Nombre = strings.TrimSpace(propiedad.Nombre)
+	propiedad.Direccion = strings.TrimSpace(propiedad.Direccion)
+	if propiedad.Nombre == "" {
+		return fmt.Errorf("%w: indique el nombre", ErrInvalidPropiedad)
+	}
+
+	guardadas := make(map[primitive.ObjectID]bool)
+	if existing != nil {
+		for _, u := range existing.Unidades {
+			guardadas[u.ID] = true
+		}
+	}
+	if propiedad.Unidades == nil {
+		propiedad.Unidades = []models.UnidadPropiedad{}
+	}
+	nombres := make(map[string]bool, len(propiedad.Unidades))
+	for i := range propiedad.Unidades {
+		unidad := &propiedad.Unidades[i]
+		unidad.Nombre = strings.TrimSpace(unidad.Nombre)
+		clave := strings.ToLower(unidad.Nombre)
+		if unidad.Nombre == "" || nombres[clave] {
+			return fmt.Errorf("%w: las unidades deben tener nombres distintos", ErrInvalidPropiedad)
+		}
+		nombres[clave] = true
+
+		if unidad.ID.IsZero() {
+			unidad.ID = primitive.NewObjectID()
+		} else if !guardadas[unidad.ID] {
+			return fmt.Errorf("%w: la unidad %s no es de la propiedad", ErrInvalidPropiedad, unidad.ID.Hex())
+		}
+	}
+	return nil
+}
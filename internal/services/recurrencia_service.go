@@ -0,0 +1,531 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"control-financiero/internal/models"
+	"control-financiero/internal/recurrence"
+	"control-financiero/internal/repositories"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	recurrenciaOcurrenciasDefecto = 12
+	recurrenciaOcurrenciasMaximo  = 100
+
+	// Ocurrencias que se registran de una recurrencia en cada pasada del
+	// programador; las que falten quedan para la siguiente
+	recurrenciaLote = 500
+)
+
+// ErrInvalidRecurrencia envuelve los errores de validación de una
+// recurrencia y de sus excepciones.
+var ErrInvalidRecurrencia = errors.New("recurrencia inválida")
+
+// ErrOcurrenciaRegistrada se devuelve al cambiar una ocurrencia que el
+// programador ya procesó.
+var ErrOcurrenciaRegistrada = errors.New("la ocurrencia ya se procesó; edite o elimine su transacción")
+
+type RecurrenciaService struct {
+	recurrenciaRepo *repositories.RecurrenciaRepository
+	userRepo        *repositories.UsuarioRepository
+	cuentaRepo      *repositories.CuentaRepository
+	transacciones   *TransaccionService
+	audit           *AuditService
+}
+
+func NewRecurrenciaService(db *mongo.Database) *RecurrenciaService {
+	return &RecurrenciaService{
+		recurrenciaRepo: repositories.NewRecurrenciaRepository(db),
+		userRepo:        repositories.NewUsuarioRepository(db),
+		cuentaRepo:      repositories.NewCuentaRepository(db),
+		transacciones:   NewTransaccionService(db),
+		audit:           NewAuditService(db),
+	}
+}
+
+// Create crea una recurrencia del usuario indicado en recurrencia.UsuarioID.
+// Si Inicio ya pasó, el programador registra también las ocurrencias
+// anteriores a hoy.
+func (s *RecurrenciaService) Create(ctx context.Context, recurrencia *models.Recurrencia, client models.ClientInfo) error {
+	recurrencia.Excepciones, recurrencia.UltimaFecha = nil, nil
+	if err := s.prepare(ctx, recurrencia, nil); err != nil {
+		return err
+	}
+
+	if err := s.recurrenciaRepo.Create(ctx, recurrencia); err != nil {
+		return err
+	}
+
+	s.audit.Record(ctx, client, s.auditEntry(models.AuditRecurrenciaCrear, recurrencia.ID, nil, recurrencia))
+	return nil
+}
+
+func (s *RecurrenciaService) GetAll(ctx context.Context, usuarioID primitive.ObjectID) ([]*models.Recurrencia, error) {
+	return s.recurrenciaRepo.FindByUsuario(ctx, usuarioID)
+}
+
+func (s *RecurrenciaService) GetByID(ctx context.Context, id, usuarioID primitive.ObjectID) (*models.Recurrencia, error) {
+	recurrencia, err := s.recurrenciaRepo.FindByID(ctx, id, usuarioID)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return recurrencia, nil
+}
+
+// Update modifica la plantilla y la regla de una recurrencia. Las
+// ocurrencias ya procesadas no cambian ni se vuelven a registrar, y las
+// excepciones se conservan.
+func (s *RecurrenciaService) Update(ctx context.Context, recurrencia *models.Recurrencia, client models.ClientInfo) error {
+	existing, err := s.GetByID(ctx, recurrencia.ID, recurrencia.UsuarioID)
+	if err != nil {
+		return err
+	}
+
+	recurrencia.Excepciones = existing.Excepciones
+	recurrencia.UltimaFecha = existing.UltimaFecha
+	if err := s.prepare(ctx, recurrencia, existing); err != nil {
+		return err
+	}
+
+	// La fecha de creación no se puede cambiar
+	recurrencia.CreatedAt = existing.CreatedAt
+
+	if err := s.recurrenciaRepo.Update(ctx, recurrencia); err != nil {
+		return notFound(err)
+	}
+
+	s.audit.Record(ctx, client, s.auditEntry(models.AuditRecurrenciaEditar, recurrencia.ID, existing, recurrencia))
+	return nil
+}
+
+// Delete elimina la recurrencia. Las transacciones que ya registró se
+// conservan.
+func (s *RecurrenciaService) Delete(ctx context.Context, id, usuarioID primitive.ObjectID, client models.ClientInfo) error {
+	existing, err := s.GetByID(ctx, id, usuarioID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.recurrenciaRepo.Delete(ctx, id, usuarioID); err != nil {
+		return notFound(err)
+	}
+
+	s.audit.Record(ctx, client, s.auditEntry(models.AuditRecurrenciaEliminar, id, existing, nil))
+	return nil
+}
+
+// Ocurrencias devuelve las próximas ocurrencias pendientes de la recurrencia
+// tal como se registrarían, con sus excepciones aplicadas.
+func (s *RecurrenciaService) Ocurrencias(ctx context.Context, id, usuarioID primitive.ObjectID, limite int) ([]*models.OcurrenciaRecurrencia, error) {
+	recurrencia, err := s.GetByID(ctx, id, usuarioID)
+	if err != nil {
+		return nil, err
+	}
+	if limite <= 0 {
+		limite = recurrenciaOcurrenciasDefecto
+	}
+	if limite > recurrenciaOcurrenciasMaximo {
+		limite = recurrenciaOcurrenciasMaximo
+	}
+
+	ocurrencias := []*models.OcurrenciaRecurrencia{}
+	if recurrencia.Proxima == nil {
+		return ocurrencias, nil
+	}
+	regla, err := reglaRecurrencia(recurrencia)
+	if err != nil {
+		return nil, err
+	}
+	for _, fecha := range regla.Occurrences(recurrencia.Inicio, *recurrencia.Proxima, time.Time{}, limite) {
+		ocurrencias = append(ocurrencias, ocurrencia(recurrencia, fecha))
+	}
+	return ocurrencias, nil
+}
+
+// SetExcepcion omite o modifica una ocurrencia pendiente de la recurrencia.
+// Reemplaza la excepción que esa ocurrencia tuviera.
+func (s *RecurrenciaService) SetExcepcion(ctx context.Context, id, usuarioID primitive.ObjectID, excepcion models.ExcepcionRecurrencia, client models.ClientInfo) (*models.Recurrencia, error) {
+	existing, err := s.GetByID(ctx, id, usuarioID)
+	if err != nil {
+		return nil, err
+	}
+
+	excepcion.Fecha = truncateDia(excepcion.Fecha)
+	if err := checkOcurrencia(existing, excepcion.Fecha); err != nil {
+		return nil, err
+	}
+	if !excepcion.Omitir && excepcion.Monto == nil && excepcion.Descripcion == "" {
+		return nil, fmt.Errorf("%w: indique omitir, monto o descripción", ErrInvalidRecurrencia)
+	}
+	if excepcion.Monto != nil {
+		monto := excepcion.Monto.Round(existing.Moneda)
+		if monto <= 0 {
+			return nil, ErrInvalidMonto
+		}
+		excepcion.Monto = &monto
+	}
+
+	excepciones := []models.ExcepcionRecurrencia{excepcion}
+	for _, e := range existing.Excepciones {
+		if !e.Fecha.Equal(excepcion.Fecha) {
+			excepciones = append(excepciones, e)
+		}
+	}
+	sort.Slice(excepciones, func(i, j int) bool { return excepciones[i].Fecha.Before(excepciones[j].Fecha) })
+
+	return s.setExcepciones(ctx, existing, excepciones, client)
+}
+
+// DeleteExcepcion devuelve una ocurrencia pendiente a lo que indica la
+// plantilla. No hace nada si la ocurrencia no tenía excepción.
+func (s *RecurrenciaService) DeleteExcepcion(ctx context.Context, id, usuarioID primitive.ObjectID, fecha time.Time, client models.ClientInfo) (*models.Recurrencia, error) {
+	existing, err := s.GetByID(ctx, id, usuarioID)
+	if err != nil {
+		return nil, err
+	}
+
+	fecha = truncateDia(fecha)
+	if existing.UltimaFecha != nil && !fecha.After(*existing.UltimaFecha) {
+		return nil, ErrOcurrenciaRegistrada
+	}
+	if existing.Excepcion(fecha) == nil {
+		return existing, nil
+	}
+
+	var excepciones []models.ExcepcionRecurrencia
+	for _, e := range existing.Excepciones {
+		if !e.Fecha.Equal(fecha) {
+			excepciones = append(excepciones, e)
+		}
+	}
+	return s.setExcepciones(ctx, existing, excepciones, client)
+}
+
+func (s *RecurrenciaService) setExcepciones(ctx context.Context, existing *models.Recurrencia, excepciones []models.ExcepcionRecurrencia, client models.ClientInfo) (*models.Recurrencia, error) {
+	if err := s.recurrenciaRepo.SetExcepciones(ctx, existing.ID, existing.UsuarioID, excepciones); err != nil {
+		return nil, notFound(err)
+	}
+
+	recurrencia := *existing
+	recurrencia.Excepciones = excepciones
+	s.audit.Record(ctx, client, s.auditEntry(models.AuditRecurrenciaEditar, recurrencia.ID, existing, &recurrencia))
+	return &recurrencia, nil
+}
+
+// Run registra las ocurrencias vencidas al arrancar y después cada
+// intervalo, hasta que se cancela ctx. Puede correr en varias réplicas a la
+// vez: cada ocurrencia se registra una sola vez gracias al índice único de
+// las transacciones sobre recurrencia.id y recurrencia.fecha, y el avance de
+// cada recurrencia solo se guarda si nadie lo cambió mientras tanto.
+func (s *RecurrenciaService) Run(ctx context.Context, intervalo time.Duration) {
+	ticker := time.NewTicker(intervalo)
+	defer ticker.Stop()
+
+	for {
+		creadas, err := s.Materializar(ctx, time.Now())
+		if err != nil && ctx.Err() == nil {
+			log.Printf("⚠️  Error registrando las transacciones recurrentes: %v\n", err)
+		}
+		if creadas > 0 {
+			log.Printf("🔁 %d transacciones recurrentes registradas\n", creadas)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Materializar registra como transacciones las ocurrencias de todas las
+// recurrencias hasta el día de ahora, inclusive. Devuelve cuántas creó. Una
+// recurrencia que falla, por ejemplo porque su cuenta se archivó, guarda el
+// motivo en Error y se reintenta en la siguiente pasada sin frenar a las
+// demás.
+func (s *RecurrenciaService) Materializar(ctx context.Context, ahora time.Time) (int, error) {
+	hoy := truncateDia(ahora)
+	recurrencias, err := s.recurrenciaRepo.FindVencidas(ctx, hoy)
+	if err != nil {
+		return 0, err
+	}
+
+	total := 0
+	for _, recurrencia := range recurrencias {
+		if err := ctx.Err(); err != nil {
+			return total, err
+		}
+		creadas, err := s.procesar(ctx, recurrencia, hoy)
+		total += creadas
+		if err != nil {
+			log.Printf("⚠️  Recurrencia %s: %v\n", recurrencia.ID.Hex(), err)
+		}
+	}
+	return total, nil
+}
+
+// procesar registra las ocurrencias pendientes de la recurrencia hasta hoy y
+// guarda hasta dónde llegó. Si una ocurrencia falla se detiene en ella.
+func (s *RecurrenciaService) procesar(ctx context.Context, recurrencia *models.Recurrencia, hoy time.Time) (int, error) {
+	regla, err := reglaRecurrencia(recurrencia)
+	if err != nil {
+		return 0, err
+	}
+	anterior := *recurrencia.Proxima
+
+	creadas := 0
+	desde := anterior
+	var fallo error
+	for _, fecha := range regla.Occurrences(recurrencia.Inicio, anterior, hoy, recurrenciaLote) {
+		creada, err := s.registrar(ctx, recurrencia, fecha)
+		if err != nil {
+			desde, fallo = fecha, err
+			break
+		}
+		if creada {
+			creadas++
+		}
+		recurrencia.UltimaFecha = &fecha
+		desde = fecha.AddDate(0, 0, 1)
+	}
+
+	recurrencia.Proxima, recurrencia.Error = nil, ""
+	if proxima, ok := regla.Next(recurrencia.Inicio, desde); ok {
+		recurrencia.Proxima = &proxima
+	}
+	if fallo != nil {
+		recurrencia.Error = fallo.Error()
+	}
+	if _, err := s.recurrenciaRepo.Avanzar(ctx, recurrencia, anterior); err != nil {
+		return creadas, err
+	}
+	return creadas, fallo
+}
+
+// registrar crea la transacción de una ocurrencia, salvo que esté omitida o
+// que otra réplica ya la haya creado. Devuelve si la creó.
+func (s *RecurrenciaService) registrar(ctx context.Context, recurrencia *models.Recurrencia, fecha time.Time) (bool, error) {
+	o := ocurrencia(recurrencia, fecha)
+	if o.Omitida {
+		return false, nil
+	}
+
+	transaccion := &models.Transaccion{
+		UsuarioID:   recurrencia.UsuarioID,
+		Tipo:        recurrencia.Tipo,
+		CategoriaID: recurrencia.CategoriaID,
+		Monto:       o.Monto,
+		Moneda:      o.Moneda,
+		Fecha:       fecha,
+		Descripcion: o.Descripcion,
+		CuentaID:    recurrencia.CuentaID,
+		MetodoPago:  recurrencia.MetodoPago,
+		Tags:        recurrencia.Tags,
+		Recurrencia: &models.EnlaceRecurrencia{ID: recurrencia.ID, Fecha: fecha},
+	}
+	if err := s.transacciones.prepare(ctx, transaccion, nil); err != nil {
+		return false, err
+	}
+	if err := s.transacciones.transaccionRepo.Create(ctx, transaccion); err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	// La registra el sistema en nombre del usuario
+	entry := s.transacciones.auditEntry(models.AuditTransaccionCrear, transaccion.ID, nil, transaccion)
+	entry.AfectadoID = objectIDPtr(recurrencia.UsuarioID)
+	s.audit.Record(ctx, models.ClientInfo{}, entry)
+	return true, nil
+}
+
+// ocurrencia aplica la excepción de fecha, si la hay, a la plantilla.
+func ocurrencia(recurrencia *models.Recurrencia, fecha time.Time) *models.OcurrenciaRecurrencia {
+	o := &models.OcurrenciaRecurrencia{
+		Fecha:       fecha,
+		Monto:       recurrencia.Monto,
+		Moneda:      recurrencia.Moneda,
+		Descripcion: recurrencia.Descripcion,
+		Omitida:     recurrencia.Pausada,
+	}
+	if o.Descripcion == "" {
+		o.Descripcion = recurrencia.Nombre
+	}
+	if excepcion := recurrencia.Excepcion(fecha); excepcion != nil {
+		o.Modificada = true
+		o.Omitida = o.Omitida || excepcion.Omitir
+		if excepcion.Monto != nil {
+			o.Monto = *excepcion.Monto
+		}
+		if excepcion.Descripcion != "" {
+			o.Descripcion = excepcion.Descripcion
+		}
+	}
+	return o
+}
+
+// prepare valida la recurrencia, completa su moneda como se hace con las
+// transacciones y calcula la próxima ocurrencia a partir de la última
+// procesada. existing es la versión guardada al editar, o nil al crear.
+func (s *RecurrenciaService) prepare(ctx context.Context, recurrencia, existing *models.Recurrencia) error {
+	recurrencia.Nombre = strings.TrimSpace(recurrencia.Nombre)
+	if recurrencia.Nombre == "" {
+		return fmt.Errorf("%w: el nombre es obligatorio", ErrInvalidRecurrencia)
+	}
+	recurrencia.Inicio = truncateDia(recurrencia.Inicio)
+	if recurrencia.Fin != nil {
+		fin := truncateDia(*recurrencia.Fin)
+		if fin.Before(recurrencia.Inicio) {
+			return fmt.Errorf("%w: el fin es anterior al inicio", ErrInvalidRecurrencia)
+		}
+		recurrencia.Fin = &fin
+	}
+	if recurrencia.Frecuencia != models.FrecuenciaRRule {
+		recurrencia.RRule = ""
+	}
+	if recurrencia.Frecuencia != models.FrecuenciaMensual {
+		recurrencia.DiaMes = 0
+	}
+
+	usuario, err := s.userRepo.FindByID(ctx, recurrencia.UsuarioID)
+	if err != nil {
+		return notFound(err)
+	}
+	var cuenta *models.Cuenta
+	if recurrencia.CuentaID != nil {
+		cuenta, err = s.cuentaRepo.FindByID(ctx, *recurrencia.CuentaID, recurrencia.UsuarioID)
+		if err != nil {
+			if errors.Is(err, mongo.ErrNoDocuments) {
+				return ErrInvalidCuenta
+			}
+			return err
+		}
+		sinCambio := existing != nil && existing.CuentaID != nil && *existing.CuentaID == cuenta.ID
+		if cuenta.Archivada && !sinCambio {
+			return ErrInvalidCuenta
+		}
+	}
+
+	if recurrencia.Moneda == "" {
+		recurrencia.Moneda = usuario.Moneda()
+		if cuenta != nil {
+			recurrencia.Moneda = cuenta.Moneda
+		}
+	}
+	if recurrencia.Moneda, err = normalizeMoneda(recurrencia.Moneda); err != nil {
+		return err
+	}
+	if cuenta != nil && recurrencia.Moneda != cuenta.Moneda {
+		return fmt.Errorf("%w: la cuenta está en %s", ErrInvalidMoneda, cuenta.Moneda)
+	}
+	recurrencia.Monto = recurrencia.Monto.Round(recurrencia.Moneda)
+	if recurrencia.Monto <= 0 {
+		return ErrInvalidMonto
+	}
+
+	regla, err := reglaRecurrencia(recurrencia)
+	if err != nil {
+		return err
+	}
+	if _, ok := regla.Next(recurrencia.Inicio, recurrencia.Inicio); !ok {
+		return fmt.Errorf("%w: la regla no produce ninguna fecha", ErrInvalidRecurrencia)
+	}
+
+	// Las ocurrencias ya procesadas no se repiten aunque cambie la regla
+	desde := recurrencia.Inicio
+	if recurrencia.UltimaFecha != nil && !recurrencia.UltimaFecha.Before(desde) {
+		desde = recurrencia.UltimaFecha.AddDate(0, 0, 1)
+	}
+	recurrencia.Proxima, recurrencia.Error = nil, ""
+	if proxima, ok := regla.Next(recurrencia.Inicio, desde); ok {
+		recurrencia.Proxima = &proxima
+	}
+	return nil
+}
+
+func (s *RecurrenciaService) auditEntry(accion string, id primitive.ObjectID, before, after *models.Recurrencia) *models.AuditLog {
+	return &models.AuditLog{
+		Accion:    accion,
+		Recurso:   "recurrencia",
+		RecursoID: objectIDPtr(id),
+		Detalle:   auditDiff(before, after),
+	}
+}
+
+// reglaRecurrencia traduce la frecuencia de la recurrencia a una RRULE y le
+// aplica el fin.
+func reglaRecurrencia(recurrencia *models.Recurrencia) (*recurrence.Rule, error) {
+	var rrule string
+	switch recurrencia.Frecuencia {
+	case models.FrecuenciaDiaria:
+		rrule = "FREQ=DAILY"
+	case models.FrecuenciaSemanal:
+		rrule = "FREQ=WEEKLY"
+	case models.FrecuenciaMensual:
+		dia := recurrencia.DiaMes
+		if dia == 0 {
+			dia = recurrencia.Inicio.Day()
+		}
+		rrule = "FREQ=MONTHLY;" + diaDelMes(dia)
+	case models.FrecuenciaUltimoDiaHabil:
+		rrule = "FREQ=MONTHLY;BYDAY=MO,TU,WE,TH,FR;BYSETPOS=-1"
+	case models.FrecuenciaAnual:
+		rrule = fmt.Sprintf("FREQ=YEARLY;BYMONTH=%d;%s", recurrencia.Inicio.Month(), diaDelMes(recurrencia.Inicio.Day()))
+	case models.FrecuenciaRRule:
+		rrule = recurrencia.RRule
+	default:
+		return nil, fmt.Errorf("%w: frecuencia desconocida", ErrInvalidRecurrencia)
+	}
+	if recurrencia.Frecuencia != models.FrecuenciaRRule && recurrencia.Intervalo > 1 {
+		rrule += ";INTERVAL=" + strconv.Itoa(recurrencia.Intervalo)
+	}
+
+	regla, err := recurrence.Parse(rrule)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidRecurrencia, err)
+	}
+	if recurrencia.Fin != nil && (regla.Until.IsZero() || recurrencia.Fin.Before(regla.Until)) {
+		regla.Until = *recurrencia.Fin
+	}
+	return regla, nil
+}
+
+// diaDelMes elige el día dia de cada mes o, en los meses más cortos, el
+// último: el 31 cae el 30 de abril y el 28 o 29 de febrero.
+func diaDelMes(dia int) string {
+	if dia <= 28 {
+		return "BYMONTHDAY=" + strconv.Itoa(dia)
+	}
+	dias := make([]string, 0, dia-27)
+	for d := 28; d <= dia; d++ {
+		dias = append(dias, strconv.Itoa(d))
+	}
+	return "BYMONTHDAY=" + strings.Join(dias, ",") + ";BYSETPOS=-1"
+}
+
+// checkOcurrencia comprueba que fecha sea una ocurrencia de la recurrencia
+// que el programador aún no procesó.
+func checkOcurrencia(recurrencia *models.Recurrencia, fecha time.Time) error {
+	if recurrencia.UltimaFecha != nil && !fecha.After(*recurrencia.UltimaFecha) {
+		return ErrOcurrenciaRegistrada
+	}
+	regla, err := reglaRecurrencia(recurrencia)
+	if err != nil {
+		return err
+	}
+	if siguiente, ok := regla.Next(recurrencia.Inicio, fecha); !ok || !siguiente.Equal(fecha) {
+		return fmt.Errorf("%w: el %s no es una ocurrencia", ErrInvalidRecurrencia, fecha.Format(time.DateOnly))
+	}
+	return nil
+}
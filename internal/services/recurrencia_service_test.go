@@ -0,0 +1,282 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"control-financiero/internal/models"
+	"control-financiero/internal/money"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+func TestReglaRecurrencia(t *testing.T) {
+	dia := func(s string) time.Time {
+		d, err := time.Parse(time.DateOnly, s)
+		require.NoError(t, err)
+		return d
+	}
+	fin := dia("2024-04-10")
+
+	tests := []struct {
+		name        string
+		recurrencia models.Recurrencia
+		want        []string
+	}{
+		{"mensual el 31 o el último día",
+			models.Recurrencia{Frecuencia: models.FrecuenciaMensual, Inicio: dia("2024-01-31")},
+			[]string{"2024-01-31", "2024-02-29", "2024-03-31", "2024-04-30"}},
+		{"mensual con día y cada dos meses",
+			models.Recurrencia{Frecuencia: models.FrecuenciaMensual, DiaMes: 15, Intervalo: 2, Inicio: dia("2024-01-20")},
+			[]string{"2024-03-15", "2024-05-15", "2024-07-15", "2024-09-15"}},
+		{"último día hábil",
+			models.Recurrencia{Frecuencia: models.FrecuenciaUltimoDiaHabil, Inicio: dia("2024-03-01")},
+			[]string{"2024-03-29", "2024-04-30", "2024-05-31", "2024-06-28"}},
+		{"anual el 29 de febrero",
+			models.Recurrencia{Frecuencia: models.FrecuenciaAnual, Inicio: dia("2024-02-29")},
+			[]string{"2024-02-29", "2025-02-28", "2026-02-28", "2027-02-28"}},
+		{"semanal hasta el fin",
+			models.Recurrencia{Frecuencia: models.FrecuenciaSemanal, Inicio: dia("2024-03-20"), Fin: &fin},
+			[]string{"2024-03-20", "2024-03-27", "2024-04-03", "2024-04-10"}},
+		{"rrule",
+			models.Recurrencia{Frecuencia: models.FrecuenciaRRule, RRule: "FREQ=MONTHLY;BYDAY=1MO;COUNT=2", Intervalo: 5, Inicio: dia("2024-01-01")},
+			[]string{"2024-01-01", "2024-02-05"}},
+	}
+	for _, tt := range tests {
+		regla, err := reglaRecurrencia(&tt.recurrencia)
+		require.NoError(t, err, tt.name)
+
+		var got []string
+		for _, fecha := range regla.Occurrences(tt.recurrencia.Inicio, tt.recurrencia.Inicio, time.Time{}, 4) {
+			got = append(got, fecha.Format(time.DateOnly))
+		}
+		assert.Equal(t, tt.want, got, tt.name)
+	}
+
+	_, err := reglaRecurrencia(&models.Recurrencia{Frecuencia: models.FrecuenciaRRule, RRule: "FREQ=HOURLY"})
+	assert.ErrorIs(t, err, ErrInvalidRecurrencia)
+}
+
+func TestRecurrencia_Create(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	ctx := context.Background()
+
+	mt.Run("calcula la moneda y la próxima ocurrencia", func(mt *mtest.T) {
+		s := NewRecurrenciaService(mt.DB)
+		mt.AddMockResponses(propietarioResponse(t, "PEN"), mtest.CreateSuccessResponse(), mtest.CreateSuccessResponse())
+
+		recurrencia := &models.Recurrencia{
+			UsuarioID:  propietario,
+			Nombre:     " Alquiler ",
+			Tipo:       "egreso",
+			Monto:      money.MustParse("1200.555"),
+			Frecuencia: models.FrecuenciaMensual,
+			DiaMes:     31,
+			Inicio:     time.Date(2024, 2, 10, 18, 0, 0, 0, time.UTC),
+		}
+		require.NoError(t, s.Create(ctx, recurrencia, models.ClientInfo{}))
+		assert.Equal(t, "Alquiler", recurrencia.Nombre)
+		assert.Equal(t, "PEN", recurrencia.Moneda)
+		assert.Equal(t, "1200.56", recurrencia.Monto.String())
+		assert.Equal(t, time.Date(2024, 2, 10, 0, 0, 0, 0, time.UTC), recurrencia.Inicio)
+		require.NotNil(t, recurrencia.Proxima)
+		assert.Equal(t, time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC), *recurrencia.Proxima)
+	})
+
+	mt.Run("rechaza reglas sin fechas", func(mt *mtest.T) {
+		s := NewRecurrenciaService(mt.DB)
+		mt.AddMockResponses(propietarioResponse(t, "PEN"))
+
+		recurrencia := &models.Recurrencia{
+			UsuarioID:  propietario,
+			Nombre:     "Nunca",
+			Monto:      money.MustParse("10"),
+			Frecuencia: models.FrecuenciaRRule,
+			RRule:      "FREQ=YEARLY;BYMONTH=2;BYMONTHDAY=30",
+			Inicio:     time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		}
+		assert.ErrorIs(t, s.Create(ctx, recurrencia, models.ClientInfo{}), ErrInvalidRecurrencia)
+	})
+}
+
+func TestRecurrencia_Materializar(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	ctx := context.Background()
+	dia := func(d int) time.Time { return time.Date(2024, 5, d, 0, 0, 0, 0, time.UTC) }
+	otroMonto := money.MustParse("7.5")
+
+	diaria := func() *models.Recurrencia {
+		proxima := dia(1)
+		return &models.Recurrencia{
+			ID:          primitive.NewObjectID(),
+			UsuarioID:   propietario,
+			Nombre:      "Café",
+			Tipo:        "egreso",
+			Monto:       money.MustParse("5"),
+			Moneda:      "PEN",
+			Frecuencia:  models.FrecuenciaDiaria,
+			Inicio:      dia(1),
+			Proxima:     &proxima,
+			Excepciones: []models.ExcepcionRecurrencia{{Fecha: dia(2), Omitir: true}, {Fecha: dia(3), Monto: &otroMonto}},
+		}
+	}
+
+	mt.Run("registra las vencidas una sola vez y avanza", func(mt *mtest.T) {
+		s := NewRecurrenciaService(mt.DB)
+		recurrencia := diaria()
+		mt.AddMockResponses(
+			mtest.CreateCursorResponse(0, "test.recurrencias", mtest.FirstBatch, bsonDoc(t, recurrencia)),
+			// Día 1: se registra y se audita
+			propietarioResponse(t, "PEN"),
+			mtest.CreateSuccessResponse(),
+			mtest.CreateSuccessResponse(),
+			// Día 3: otra réplica ya la registró
+			propietarioResponse(t, "PEN"),
+			mtest.CreateWriteErrorsResponse(mtest.WriteError{Index: 0, Code: 11000, Message: "E11000 duplicate key error"}),
+			bson.D{{Key: "ok", Value: 1}, {Key: "n", Value: 1}, {Key: "nModified", Value: 1}},
+		)
+
+		creadas, err := s.Materializar(ctx, dia(3).Add(8*time.Hour))
+		require.NoError(t, err)
+		assert.Equal(t, 1, creadas)
+
+		inserts := []bson.Raw{}
+		avanzo := false
+		for evt := mt.GetStartedEvent(); evt != nil; evt = mt.GetStartedEvent() {
+			if evt.CommandName == "insert" && evt.Command.Lookup("insert").StringValue() == "transacciones" {
+				inserts = append(inserts, evt.Command.Lookup("documents").Array().Index(0).Value().Document())
+			}
+			if evt.CommandName == "update" {
+				update := evt.Command.Lookup("updates").Array().Index(0).Value().Document()
+				assert.Equal(t, dia(1), update.Lookup("q", "proxima").Time().UTC())
+				assert.Equal(t, dia(3), update.Lookup("u", "$set", "ultimaFecha").Time().UTC())
+				assert.Equal(t, dia(4), update.Lookup("u", "$set", "proxima").Time().UTC())
+				avanzo = true
+			}
+		}
+		assert.True(t, avanzo)
+		require.Len(t, inserts, 2)
+		assert.Equal(t, recurrencia.ID, inserts[0].Lookup("recurrencia", "id").ObjectID())
+		assert.Equal(t, dia(1), inserts[0].Lookup("recurrencia", "fecha").Time().UTC())
+		assert.Equal(t, "Café", inserts[0].Lookup("descripcion").StringValue())
+		assert.Equal(t, dia(3), inserts[1].Lookup("fecha").Time().UTC())
+		assert.Equal(t, "7.5", inserts[1].Lookup("monto").Decimal128().String())
+	})
+
+	mt.Run("una ocurrencia que falla se reintenta después", func(mt *mtest.T) {
+		s := NewRecurrenciaService(mt.DB)
+		recurrencia := diaria()
+		cuentaID := primitive.NewObjectID()
+		recurrencia.CuentaID = &cuentaID
+		mt.AddMockResponses(
+			mtest.CreateCursorResponse(0, "test.recurrencias", mtest.FirstBatch, bsonDoc(t, recurrencia)),
+			propietarioResponse(t, "PEN"),
+			mtest.CreateCursorResponse(0, "test.cuentas", mtest.FirstBatch, cuentaDoc(cuentaID, "PEN", "0", true)),
+			bson.D{{Key: "ok", Value: 1}, {Key: "n", Value: 1}, {Key: "nModified", Value: 1}},
+		)
+
+		creadas, err := s.Materializar(ctx, dia(3))
+		require.NoError(t, err)
+		assert.Zero(t, creadas)
+
+		evt := startedEvent(t, mt, func(cmd bson.Raw) bool { _, ok := cmd.Lookup("update").StringValueOK(); return ok })
+		update := evt.Command.Lookup("updates").Array().Index(0).Value().Document()
+		assert.Equal(t, dia(1), update.Lookup("u", "$set", "proxima").Time().UTC())
+		assert.Equal(t, ErrInvalidCuenta.Error(), update.Lookup("u", "$set", "error").StringValue())
+		_, err = update.LookupErr("u", "$set", "ultimaFecha")
+		assert.Error(t, err)
+	})
+
+	mt.Run("las pausadas avanzan sin registrar", func(mt *mtest.T) {
+		s := NewRecurrenciaService(mt.DB)
+		recurrencia := diaria()
+		recurrencia.Pausada = true
+		mt.AddMockResponses(
+			mtest.CreateCursorResponse(0, "test.recurrencias", mtest.FirstBatch, bsonDoc(t, recurrencia)),
+			bson.D{{Key: "ok", Value: 1}, {Key: "n", Value: 1}, {Key: "nModified", Value: 1}},
+		)
+
+		creadas, err := s.Materializar(ctx, dia(5))
+		require.NoError(t, err)
+		assert.Zero(t, creadas)
+
+		evt := startedEvent(t, mt, func(cmd bson.Raw) bool { _, ok := cmd.Lookup("update").StringValueOK(); return ok })
+		update := evt.Command.Lookup("updates").Array().Index(0).Value().Document()
+		assert.Equal(t, dia(6), update.Lookup("u", "$set", "proxima").Time().UTC())
+	})
+}
+
+func TestRecurrencia_Excepciones(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	ctx := context.Background()
+	dia := func(d int) time.Time { return time.Date(2024, 5, d, 0, 0, 0, 0, time.UTC) }
+
+	semanal := func() *models.Recurrencia {
+		ultima, proxima := dia(8), dia(15)
+		return &models.Recurrencia{
+			ID:          primitive.NewObjectID(),
+			UsuarioID:   propietario,
+			Nombre:      "Clases",
+			Tipo:        "egreso",
+			Monto:       money.MustParse("40"),
+			Moneda:      "PEN",
+			Frecuencia:  models.FrecuenciaSemanal,
+			Inicio:      dia(1),
+			UltimaFecha: &ultima,
+			Proxima:     &proxima,
+		}
+	}
+
+	mt.Run("omite una ocurrencia pendiente", func(mt *mtest.T) {
+		s := NewRecurrenciaService(mt.DB)
+		recurrencia := semanal()
+		mt.AddMockResponses(
+			mtest.CreateCursorResponse(0, "test.recurrencias", mtest.FirstBatch, bsonDoc(t, recurrencia)),
+			bson.D{{Key: "ok", Value: 1}, {Key: "n", Value: 1}, {Key: "nModified", Value: 1}},
+			mtest.CreateSuccessResponse(),
+		)
+
+		actualizada, err := s.SetExcepcion(ctx, recurrencia.ID, propietario, models.ExcepcionRecurrencia{Fecha: dia(22).Add(3 * time.Hour), Omitir: true}, models.ClientInfo{})
+		require.NoError(t, err)
+		require.Len(t, actualizada.Excepciones, 1)
+		assert.Equal(t, dia(22), actualizada.Excepciones[0].Fecha)
+	})
+
+	mt.Run("la vista previa aplica las excepciones", func(mt *mtest.T) {
+		s := NewRecurrenciaService(mt.DB)
+		recurrencia := semanal()
+		monto := money.MustParse("55")
+		recurrencia.Excepciones = []models.ExcepcionRecurrencia{{Fecha: dia(22), Monto: &monto, Descripcion: "Clase doble"}}
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "test.recurrencias", mtest.FirstBatch, bsonDoc(t, recurrencia)))
+
+		ocurrencias, err := s.Ocurrencias(ctx, recurrencia.ID, propietario, 3)
+		require.NoError(t, err)
+		require.Len(t, ocurrencias, 3)
+		assert.Equal(t, dia(15), ocurrencias[0].Fecha)
+		assert.Equal(t, "Clases", ocurrencias[0].Descripcion)
+		assert.False(t, ocurrencias[0].Modificada)
+		assert.Equal(t, "55", ocurrencias[1].Monto.String())
+		assert.Equal(t, "Clase doble", ocurrencias[1].Descripcion)
+		assert.True(t, ocurrencias[1].Modificada)
+		assert.Equal(t, dia(29), ocurrencias[2].Fecha)
+	})
+
+	mt.Run("no cambia las ya procesadas ni fechas fuera de la regla", func(mt *mtest.T) {
+		s := NewRecurrenciaService(mt.DB)
+		recurrencia := semanal()
+		mt.AddMockResponses(
+			mtest.CreateCursorResponse(0, "test.recurrencias", mtest.FirstBatch, bsonDoc(t, recurrencia)),
+			mtest.CreateCursorResponse(0, "test.recurrencias", mtest.FirstBatch, bsonDoc(t, recurrencia)),
+		)
+
+		_, err := s.SetExcepcion(ctx, recurrencia.ID, propietario, models.ExcepcionRecurrencia{Fecha: dia(8), Omitir: true}, models.ClientInfo{})
+		assert.ErrorIs(t, err, ErrOcurrenciaRegistrada)
+		_, err = s.SetExcepcion(ctx, recurrencia.ID, propietario, models.ExcepcionRecurrencia{Fecha: dia(16), Omitir: true}, models.ClientInfo{})
+		assert.ErrorIs(t, err, ErrInvalidRecurrencia)
+	})
+}
@@ -0,0 +1,126 @@
+package services
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"control-financiero/internal/models"
+	"control-financiero/internal/repositories"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type ReporteService struct {
+	userRepo      *repositories.UsuarioRepository
+	cuentas       *CuentaService
+	prestamos     *PrestamoService
+	contratos     *ContratoService
+	inquilinoRepo *repositories.InquilinoRepository
+	rates         *ExchangeRateService
+}
+
+func NewReporteService(db *mongo.Database) *ReporteService {
+	return &ReporteService{
+		userRepo:      repositories.NewUsuarioRepository(db),
+		cuentas:       NewCuentaService(db),
+		prestamos:     NewPrestamoService(db),
+		contratos:     NewContratoService(db),
+		inquilinoRepo: repositories.NewInquilinoRepository(db),
+		rates:         NewExchangeRateService(db),
+	}
+}
+
+// Patrimonio suma el saldo de todas las cuentas del usuario y el capital
+// pendiente de sus préstamos, a cobrar o a pagar, en su moneda base con los
+// tipos de cambio de hoy.
+func (s *ReporteService) Patrimonio(ctx context.Context, usuarioID primitive.ObjectID) (*models.Patrimonio, error) {
+	usuario, err := s.userRepo.FindByID(ctx, usuarioID)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	patrimonio := &models.Patrimonio{Moneda: usuario.Moneda(), Fecha: truncateDia(time.Now())}
+
+	cuentas, err := s.cuentas.GetAll(ctx, usuarioID, true)
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range cuentas {
+		saldo, _, err := s.rates.Convert(ctx, c.Saldo, c.Moneda, patrimonio.Moneda, patrimonio.Fecha)
+		if err != nil {
+			return nil, err
+		}
+		patrimonio.Cuentas += saldo
+	}
+
+	prestamos, err := s.prestamos.GetAll(ctx, usuarioID)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range prestamos {
+		saldo, _, err := s.rates.Convert(ctx, p.Saldo, p.Moneda, patrimonio.Moneda, patrimonio.Fecha)
+		if err != nil {
+			return nil, err
+		}
+		if p.Sentido == models.PrestamoOtorgado {
+			patrimonio.PrestamosPorCobrar += saldo
+		} else {
+			patrimonio.PrestamosPorPagar += saldo
+		}
+	}
+
+	patrimonio.Total = patrimonio.Cuentas + patrimonio.PrestamosPorCobrar - patrimonio.PrestamosPorPagar
+	return patrimonio, nil
+}
+
+// Morosidad devuelve la deuda vencida de cada inquilino con cargos impagos,
+// sumando sus contratos de la misma moneda, ordenada por inquilino.
+func (s *ReporteService) Morosidad(ctx context.Context, usuarioID primitive.ObjectID) ([]*models.Morosidad, error) {
+	contratos, err := s.contratos.GetAll(ctx, usuarioID, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	inquilinos, err := s.inquilinoRepo.FindByUsuario(ctx, usuarioID)
+	if err != nil {
+		return nil, err
+	}
+	nombres := make(map[primitive.ObjectID]string, len(inquilinos))
+	for _, i := range inquilinos {
+		nombres[i.ID] = i.Nombre
+	}
+
+	type clave struct {
+		inquilinoID primitive.ObjectID
+		moneda      string
+	}
+	porInquilino := make(map[clave]*models.Morosidad)
+	morosidad := []*models.Morosidad{}
+	for _, c := range contratos {
+		if c.Deuda == 0 {
+			continue
+		}
+		k := clave{c.InquilinoID, c.Moneda}
+		m := porInquilino[k]
+		if m == nil {
+			m = &models.Morosidad{InquilinoID: c.InquilinoID, Inquilino: nombres[c.InquilinoID], Moneda: c.Moneda}
+			porInquilino[k] = m
+			morosidad = append(morosidad, m)
+		}
+		m.Deuda += c.Deuda
+		m.CargosVencidos += c.CargosVencidos
+		for _, cargo := range c.Cargos {
+			if cargo.Estado == models.CargoVencido && (m.VencidaDesde.IsZero() || cargo.Vencimiento.Before(m.VencidaDesde)) {
+				m.VencidaDesde = cargo.Vencimiento
+			}
+		}
+	}
+
+	sort.Slice(morosidad, func(i, j int) bool {
+		if morosidad[i].Inquilino != morosidad[j].Inquilino {
+			return morosidad[i].Inquilino < morosidad[j].Inquilino
+		}
+		return morosidad[i].Moneda < morosidad[j].Moneda
+	})
+	return morosidad, nil
+}
@@ -0,0 +1,199 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"control-financiero/internal/auth"
+	"control-financiero/internal/models"
+	"control-financiero/internal/repositories"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// permisosTTL limita cuánto tarda en verse un cambio de rol hecho desde
+// otra réplica del servidor; los cambios locales invalidan la caché al momento.
+const permisosTTL = time.Minute
+
+// rolesSistema no se pueden eliminar porque el registro y el administrador
+// inicial dependen de ellos.
+var rolesSistema = map[string]bool{"admin": true, "user": true}
+
+type permisosEntry struct {
+	permisos  []string
+	expiresAt time.Time
+}
+
+// permisosCache es compartida por todas las instancias de RolService para que
+// la invalidación tras un cambio llegue también al middleware.
+var permisosCache = struct {
+	sync.RWMutex
+	entries map[string]permisosEntry
+}{entries: make(map[string]permisosEntry)}
+
+type RolService struct {
+	rolRepo  *repositories.RolRepository
+	userRepo *repositories.UsuarioRepository
+	audit    *AuditService
+}
+
+func NewRolService(db *mongo.Database) *RolService {
+	return &RolService{
+		rolRepo:  repositories.NewRolRepository(db),
+		userRepo: repositories.NewUsuarioRepository(db),
+		audit:    NewAuditService(db),
+	}
+}
+
+// Permisos devuelve los permisos del rol, usando la caché si está vigente.
+// Un rol inexistente no tiene permisos.
+func (s *RolService) Permisos(ctx context.Context, nombre string) ([]string, error) {
+	permisosCache.RLock()
+	entry, ok := permisosCache.entries[nombre]
+	permisosCache.RUnlock()
+	if ok && time.Now().Before(entry.expiresAt) {
+		return entry.permisos, nil
+	}
+
+	var permisos []string
+	rol, err := s.rolRepo.FindByNombre(ctx, nombre)
+	switch {
+	case err == nil:
+		permisos = rol.Permisos
+	case !errors.Is(err, mongo.ErrNoDocuments):
+		return nil, err
+	}
+
+	permisosCache.Lock()
+	permisosCache.entries[nombre] = permisosEntry{permisos: permisos, expiresAt: time.Now().Add(permisosTTL)}
+	permisosCache.Unlock()
+
+	return permisos, nil
+}
+
+func (s *RolService) GetAll(ctx context.Context) ([]*models.Rol, error) {
+	return s.rolRepo.FindAll(ctx)
+}
+
+func (s *RolService) GetByID(ctx context.Context, id primitive.ObjectID) (*models.Rol, error) {
+	rol, err := s.rolRepo.FindByID(ctx, id)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return rol, nil
+}
+
+// Exists indica si hay un rol con ese nombre.
+func (s *RolService) Exists(ctx context.Context, nombre string) (bool, error) {
+	_, err := s.rolRepo.FindByNombre(ctx, nombre)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (s *RolService) Create(ctx context.Context, rol *models.Rol, client models.ClientInfo) error {
+	if err := validatePermisos(rol.Permisos); err != nil {
+		return err
+	}
+
+	exists, err := s.Exists(ctx, rol.Nombre)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return errors.New("ya existe un rol con ese nombre")
+	}
+
+	if err := s.rolRepo.Create(ctx, rol); err != nil {
+		return err
+	}
+
+	invalidatePermisos(rol.Nombre)
+	s.audit.Record(ctx, client, s.auditEntry(models.AuditRolCrear, rol.ID, nil, rol))
+	return nil
+}
+
+func (s *RolService) Update(ctx context.Context, rol *models.Rol, client models.ClientInfo) error {
+	if err := validatePermisos(rol.Permisos); err != nil {
+		return err
+	}
+
+	existing, err := s.GetByID(ctx, rol.ID)
+	if err != nil {
+		return err
+	}
+
+	// Evitar que el administrador pierda el acceso a la gestión de roles
+	if existing.Nombre == "admin" && !auth.HasPermission(rol.Permisos, auth.PermRolesWrite) {
+		return errors.New("el rol admin debe conservar el permiso " + auth.PermRolesWrite)
+	}
+
+	rol.Nombre = existing.Nombre
+	rol.CreatedAt = existing.CreatedAt
+	if err := s.rolRepo.Update(ctx, rol); err != nil {
+		return notFound(err)
+	}
+
+	invalidatePermisos(existing.Nombre)
+	s.audit.Record(ctx, client, s.auditEntry(models.AuditRolEditar, rol.ID, existing, rol))
+	return nil
+}
+
+func (s *RolService) Delete(ctx context.Context, id primitive.ObjectID, client models.ClientInfo) error {
+	existing, err := s.GetByID(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if rolesSistema[existing.Nombre] {
+		return errors.New("no se puede eliminar un rol del sistema")
+	}
+
+	count, err := s.userRepo.CountByRol(ctx, existing.Nombre)
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return fmt.Errorf("el rol está asignado a %d usuario(s)", count)
+	}
+
+	if err := s.rolRepo.Delete(ctx, id); err != nil {
+		return err
+	}
+
+	invalidatePermisos(existing.Nombre)
+	s.audit.Record(ctx, client, s.auditEntry(models.AuditRolEliminar, id, existing, nil))
+	return nil
+}
+
+func (s *RolService) auditEntry(accion string, id primitive.ObjectID, before, after *models.Rol) *models.AuditLog {
+	return &models.AuditLog{
+		Accion:    accion,
+		Recurso:   "rol",
+		RecursoID: objectIDPtr(id),
+		Detalle:   auditDiff(before, after),
+	}
+}
+
+func validatePermisos(permisos []string) error {
+	if len(permisos) == 0 {
+		return errors.New("el rol debe tener al menos un permiso")
+	}
+	for _, p := range permisos {
+		if !auth.ValidPermission(p) {
+			return fmt.Errorf("permiso desconocido: %s", p)
+		}
+	}
+	return nil
+}
+
+func invalidatePermisos(nombre string) {
+	permisosCache.Lock()
+	delete(permisosCache.entries, nombre)
+	permisosCache.Unlock()
+}
@@ -0,0 +1,110 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"control-financiero/internal/models"
+	"control-financiero/internal/repositories"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type SesionService struct {
+	refreshTokenRepo *repositories.RefreshTokenRepository
+	audit            *AuditService
+}
+
+func NewSesionService(db *mongo.Database) *SesionService {
+	return &SesionService{
+		refreshTokenRepo: repositories.NewRefreshTokenRepository(db),
+		audit:            NewAuditService(db),
+	}
+}
+
+func (s *SesionService) GetByUsuario(ctx context.Context, usuarioID primitive.ObjectID) ([]*models.Sesion, error) {
+	tokens, err := s.refreshTokenRepo.FindActiveByUsuario(ctx, usuarioID)
+	if err != nil {
+		return nil, err
+	}
+
+	sesiones := make([]*models.Sesion, 0, len(tokens))
+	for _, rt := range tokens {
+		sesiones = append(sesiones, &models.Sesion{
+			ID:          rt.FamilyID,
+			Dispositivo: rt.Dispositivo,
+			IP:          rt.IP,
+			UserAgent:   rt.UserAgent,
+			StartedAt:   rt.StartedAt,
+			LastUsedAt:  rt.LastUsedAt,
+			ExpiresAt:   rt.ExpiresAt,
+		})
+	}
+
+	return sesiones, nil
+}
+
+func (s *SesionService) Revoke(ctx context.Context, usuarioID, sesionID primitive.ObjectID, client models.ClientInfo) error {
+	revoked, err := s.refreshTokenRepo.RevokeFamilyByUsuario(ctx, sesionID, usuarioID)
+	if err != nil {
+		return err
+	}
+	if !revoked {
+		return errors.New("sesión no encontrada")
+	}
+
+	s.audit.Record(ctx, client, &models.AuditLog{
+		AfectadoID: objectIDPtr(usuarioID),
+		Accion:     models.AuditSesionRevocar,
+		Recurso:    "sesion",
+		RecursoID:  objectIDPtr(sesionID),
+	})
+	return nil
+}
+
+// describeDevice resume el User-Agent en una etiqueta legible como
+// "Chrome en Windows". No pretende ser exhaustivo.
+func describeDevice(userAgent string) string {
+	if userAgent == "" {
+		return "Desconocido"
+	}
+
+	ua := strings.ToLower(userAgent)
+
+	navegador := "Otro cliente"
+	switch {
+	case strings.Contains(ua, "edg/"):
+		navegador = "Edge"
+	case strings.Contains(ua, "opr/") || strings.Contains(ua, "opera"):
+		navegador = "Opera"
+	case strings.Contains(ua, "firefox/"):
+		navegador = "Firefox"
+	case strings.Contains(ua, "chrome/") || strings.Contains(ua, "crios/"):
+		navegador = "Chrome"
+	case strings.Contains(ua, "safari/"):
+		navegador = "Safari"
+	case strings.Contains(ua, "curl/"):
+		navegador = "curl"
+	}
+
+	sistema := ""
+	switch {
+	case strings.Contains(ua, "android"):
+		sistema = "Android"
+	case strings.Contains(ua, "iphone") || strings.Contains(ua, "ipad"):
+		sistema = "iOS"
+	case strings.Contains(ua, "windows"):
+		sistema = "Windows"
+	case strings.Contains(ua, "mac os"):
+		sistema = "macOS"
+	case strings.Contains(ua, "linux"):
+		sistema = "Linux"
+	}
+
+	if sistema == "" {
+		return navegador
+	}
+	return navegador + " en " + sistema
+}
@@ -0,0 +1,54 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"control-financiero/internal/models"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// enlazarContrato valida el contrato y la propiedad de una transacción. Un
+// cobro enlazado con un contrato queda asignado a la propiedad del contrato;
+// las demás transacciones, como los gastos de mantenimiento, se asignan a una
+// propiedad indicándola.
+func (s *TransaccionService) enlazarContrato(ctx context.Context, transaccion *models.Transaccion) error {
+	if transaccion.PropiedadID != nil && transaccion.Tipo == "transferencia" {
+		return fmt.Errorf("%w: una transferencia no se asigna a una propiedad", ErrInvalidPropiedad)
+	}
+
+	enlace := transaccion.Contrato
+	if enlace == nil {
+		if transaccion.PropiedadID == nil {
+			return nil
+		}
+		if _, err := s.propiedadRepo.FindByID(ctx, *transaccion.PropiedadID, transaccion.UsuarioID); err != nil {
+			if errors.Is(err, mongo.ErrNoDocuments) {
+				return fmt.Errorf("%w: la propiedad no existe", ErrInvalidPropiedad)
+			}
+			return err
+		}
+		return nil
+	}
+	if transaccion.Tipo != "alquiler" {
+		return fmt.Errorf("%w: solo las transacciones de tipo alquiler se enlazan con un contrato", ErrInvalidContrato)
+	}
+
+	contrato, err := s.contratoRepo.FindByID(ctx, enlace.ID, transaccion.UsuarioID)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return fmt.Errorf("%w: el contrato no existe", ErrInvalidContrato)
+		}
+		return err
+	}
+	if transaccion.Moneda != contrato.Moneda {
+		return fmt.Errorf("%w: el contrato está en %s", ErrInvalidMoneda, contrato.Moneda)
+	}
+	if transaccion.PropiedadID != nil && *transaccion.PropiedadID != contrato.PropiedadID {
+		return fmt.Errorf("%w: el contrato es de otra propiedad", ErrInvalidContrato)
+	}
+	transaccion.PropiedadID = &contrato.PropiedadID
+	return nil
+}
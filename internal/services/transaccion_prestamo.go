@@ -0,0 +1,77 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"control-financiero/internal/models"
+	"control-financiero/internal/money"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// enlazarPrestamo valida el enlace de una transacción con un préstamo y
+// calcula su sentido: el desembolso entra en la cuenta de quien recibe el
+// préstamo y sale de la de quien lo otorga, y las cuotas al revés. Lo
+// desembolsado no puede superar el principal ni lo pagado de una cuota su
+// total.
+func (s *TransaccionService) enlazarPrestamo(ctx context.Context, transaccion *models.Transaccion) error {
+	enlace := transaccion.Prestamo
+	if enlace == nil {
+		return nil
+	}
+	if transaccion.Tipo != "prestamo" {
+		return fmt.Errorf("%w: solo las transacciones de tipo prestamo se enlazan con un préstamo", ErrInvalidPrestamo)
+	}
+
+	prestamo, err := s.prestamoRepo.FindByID(ctx, enlace.ID, transaccion.UsuarioID)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return fmt.Errorf("%w: el préstamo no existe", ErrInvalidPrestamo)
+		}
+		return err
+	}
+	if transaccion.Moneda != prestamo.Moneda {
+		return fmt.Errorf("%w: el préstamo está en %s", ErrInvalidMoneda, prestamo.Moneda)
+	}
+
+	limite := prestamo.Principal
+	if enlace.Cuota > 0 {
+		if enlace.Cuota > len(prestamo.Cuotas) {
+			return fmt.Errorf("%w: el préstamo tiene %d cuotas", ErrInvalidPrestamo, len(prestamo.Cuotas))
+		}
+		limite = prestamo.Cuotas[enlace.Cuota-1].Total
+	}
+
+	// Al editar no se cuenta lo que la transacción ya pagaba
+	var excluir *primitive.ObjectID
+	if !transaccion.ID.IsZero() {
+		excluir = &transaccion.ID
+	}
+	pagos, err := s.transaccionRepo.SumPagosPrestamos(ctx, transaccion.UsuarioID, []primitive.ObjectID{prestamo.ID}, excluir)
+	if err != nil {
+		return err
+	}
+	var pagado money.Amount
+	for _, p := range pagos {
+		if p.Cuota == enlace.Cuota {
+			pagado = p.Pagado
+		}
+	}
+	if pagado+transaccion.Monto > limite {
+		if enlace.Cuota == 0 {
+			return fmt.Errorf("%w: el desembolso supera lo que falta desembolsar (%s)", ErrInvalidPrestamo, limite-pagado)
+		}
+		return fmt.Errorf("%w: el pago supera lo pendiente de la cuota %d (%s)", ErrInvalidPrestamo, enlace.Cuota, limite-pagado)
+	}
+
+	desembolso := enlace.Cuota == 0
+	recibido := prestamo.Sentido == models.PrestamoRecibido
+	enlace.Sentido = models.PrestamoSalida
+	if desembolso == recibido {
+		enlace.Sentido = models.PrestamoEntrada
+	}
+	return nil
+}
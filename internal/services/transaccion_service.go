@@ -1,77 +1,425 @@
-package services
-
-import (
-	"context"
-	"time"
-
-	"control-financiero/internal/models"
-	"control-financiero/internal/repositories"
-
-	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo"
-)
-
-type TransaccionService struct {
-	transaccionRepo *repositories.TransaccionRepository
-}
-
-func NewTransaccionService(db *mongo.Database) *TransaccionService {
-	return &TransaccionService{
-		transaccionRepo: repositories.NewTransaccionRepository(db),
-	}
-}
-
-func (s *TransaccionService) Create(ctx context.Context, transaccion *models.Transaccion) error {
-	return s.transaccionRepo.Create(ctx, transaccion)
-}
-
-func (s *TransaccionService) GetByUsuario(ctx context.Context, usuarioID primitive.ObjectID) ([]*models.Transaccion, error) {
-	return s.transaccionRepo.FindByUsuario(ctx, usuarioID)
-}
-
-func (s *TransaccionService) GetByID(ctx context.Context, id primitive.ObjectID) (*models.Transaccion, error) {
-	return s.transaccionRepo.FindByID(ctx, id)
-}
-
-func (s *TransaccionService) Update(ctx context.Context, transaccion *models.Transaccion) error {
-	return s.transaccionRepo.Update(ctx, transaccion)
-}
-
-func (s *TransaccionService) Delete(ctx context.Context, id primitive.ObjectID) error {
-	return s.transaccionRepo.Delete(ctx, id)
-}
-
-func (s *TransaccionService) GetEstadisticas(ctx context.Context, usuarioID primitive.ObjectID, year, month int) (*models.EstadisticasResponse, error) {
-	// Calcular rango de fechas
-	start := time.Date(year, time.Month(month), 1, 0, 0, 0, 0, time.UTC)
-	end := start.AddDate(0, 1, 0).Add(-time.Second)
-
-	transacciones, err := s.transaccionRepo.FindByUsuarioAndRange(ctx, usuarioID, start, end)
-	if err != nil {
-		return nil, err
-	}
-
-	// Calcular estadísticas
-	var totalIngresos, totalEgresos float64
-	porCategoria := make(map[string]float64)
-
-	for _, t := range transacciones {
-		if t.Tipo == "ingreso" {
-			totalIngresos += t.Monto
-		} else if t.Tipo == "egreso" {
-			totalEgresos += t.Monto
-		}
-
-		// Agregar por categoría (esto requeriría hacer lookup de la categoría)
-		// Por simplicidad, usamos el ID de la categoría como string
-		key := t.CategoriaID.Hex()
-		porCategoria[key] += t.Monto
-	}
-
-	return &models.EstadisticasResponse{
-		TotalIngresos: totalIngresos,
-		TotalEgresos:  totalEgresos,
-		Balance:       totalIngresos - totalEgresos,
-		PorCategoria:  porCategoria,
-	}, nil
-}
+package services
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"control-financiero/internal/models"
+	"control-financiero/internal/money"
+	"control-financiero/internal/repositories"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	transaccionesLimiteDefecto = 50
+	transaccionesLimiteMaximo  = 200
+)
+
+// ErrInvalidMonto se devuelve cuando el monto queda en cero o negativo al
+// redondearlo a los decimales de la moneda.
+var ErrInvalidMonto = errors.New("el monto debe ser mayor que cero en la moneda indicada")
+
+// ErrInvalidDivisiones envuelve los errores de validación de las divisiones
+// de una transacción.
+var ErrInvalidDivisiones = errors.New("divisiones inválidas")
+
+// ErrInvalidCursor se devuelve cuando el cursor de paginación está mal
+// formado o corresponde a otro orden.
+var ErrInvalidCursor = errors.New("cursor inválido")
+
+type TransaccionService struct {
+	client          *mongo.Client
+	transaccionRepo *repositories.TransaccionRepository
+	userRepo        *repositories.UsuarioRepository
+	cuentaRepo      *repositories.CuentaRepository
+	prestamoRepo    *repositories.PrestamoRepository
+	contratoRepo    *repositories.ContratoRepository
+	propiedadRepo   *repositories.PropiedadRepository
+	rates           *ExchangeRateService
+	audit           *AuditService
+}
+
+func NewTransaccionService(db *mongo.Database) *TransaccionService {
+	return &TransaccionService{
+		client:          db.Client(),
+		transaccionRepo: repositories.NewTransaccionRepository(db),
+		userRepo:        repositories.NewUsuarioRepository(db),
+		cuentaRepo:      repositories.NewCuentaRepository(db),
+		prestamoRepo:    repositories.NewPrestamoRepository(db),
+		contratoRepo:    repositories.NewContratoRepository(db),
+		propiedadRepo:   repositories.NewPropiedadRepository(db),
+		rates:           NewExchangeRateService(db),
+		audit:           NewAuditService(db),
+	}
+}
+
+func (s *TransaccionService) Create(ctx context.Context, transaccion *models.Transaccion, client models.ClientInfo) error {
+	if transaccion.Tipo == "transferencia" {
+		return fmt.Errorf("%w: las transferencias se crean con sus dos patas a la vez", ErrInvalidTransferencia)
+	}
+	// Solo el programador de recurrencias y las importaciones enlazan
+	// transacciones con su origen, y solo Transferir crea patas
+	transaccion.Recurrencia = nil
+	transaccion.ImportacionID = nil
+	transaccion.Bancario = nil
+	transaccion.Transferencia = nil
+	if err := s.prepare(ctx, transaccion, nil); err != nil {
+		return err
+	}
+
+	if err := s.transaccionRepo.Create(ctx, transaccion); err != nil {
+		return err
+	}
+
+	s.audit.Record(ctx, client, s.auditEntry(models.AuditTransaccionCrear, transaccion.ID, nil, transaccion))
+	return nil
+}
+
+// List devuelve una página del listado de transacciones. cursor es el
+// NextCursor de la página anterior, o vacío para la primera.
+func (s *TransaccionService) List(ctx context.Context, filtro *models.TransaccionFilter, cursor string) (*models.TransaccionPage, error) {
+	if filtro.Orden == "" {
+		filtro.Orden = models.OrdenFechaDesc
+	}
+	if filtro.Limite <= 0 {
+		filtro.Limite = transaccionesLimiteDefecto
+	}
+	if filtro.Limite > transaccionesLimiteMaximo {
+		filtro.Limite = transaccionesLimiteMaximo
+	}
+
+	if cursor != "" {
+		despues, err := decodeTransaccionCursor(cursor)
+		// Un cursor de otro orden apuntaría a una posición sin sentido
+		if err != nil || despues.Orden != filtro.Orden {
+			return nil, ErrInvalidCursor
+		}
+		filtro.Despues = despues
+	}
+
+	total, err := s.transaccionRepo.Count(ctx, filtro)
+	if err != nil {
+		return nil, err
+	}
+
+	// Pedir una de más para saber si hay otra página
+	limite := filtro.Limite
+	filtro.Limite++
+	transacciones, err := s.transaccionRepo.Find(ctx, filtro)
+	filtro.Limite = limite
+	if err != nil {
+		return nil, err
+	}
+
+	page := &models.TransaccionPage{Transacciones: transacciones, Total: total}
+	if int64(len(transacciones)) > limite {
+		page.Transacciones = transacciones[:limite]
+		last := page.Transacciones[limite-1]
+		page.NextCursor, err = encodeTransaccionCursor(&models.TransaccionCursor{
+			Orden: filtro.Orden,
+			Fecha: last.Fecha,
+			Monto: last.Monto,
+			ID:    last.ID,
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return page, nil
+}
+
+func (s *TransaccionService) GetByID(ctx context.Context, id, usuarioID primitive.ObjectID) (*models.Transaccion, error) {
+	transaccion, err := s.transaccionRepo.FindByID(ctx, id, usuarioID)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return transaccion, nil
+}
+
+// Update modifica una transacción del usuario indicado en transaccion.UsuarioID.
+func (s *TransaccionService) Update(ctx context.Context, transaccion *models.Transaccion, client models.ClientInfo) error {
+	existing, err := s.GetByID(ctx, transaccion.ID, transaccion.UsuarioID)
+	if err != nil {
+		return err
+	}
+
+	if existing.Transferencia != nil {
+		return s.updateTransferencia(ctx, transaccion, existing, client)
+	}
+	if transaccion.Tipo == "transferencia" {
+		return fmt.Errorf("%w: las transferencias se crean con sus dos patas a la vez", ErrInvalidTransferencia)
+	}
+
+	if err := s.prepare(ctx, transaccion, existing); err != nil {
+		return err
+	}
+
+	// La fecha de creación, la recurrencia o importación de origen y los
+	// datos del extracto no se pueden cambiar, ni una transacción suelta
+	// convertirse en pata de una transferencia
+	transaccion.CreatedAt = existing.CreatedAt
+	transaccion.Recurrencia = existing.Recurrencia
+	transaccion.ImportacionID = existing.ImportacionID
+	transaccion.Bancario = existing.Bancario
+	transaccion.Transferencia = existing.Transferencia
+
+	if err := s.transaccionRepo.Update(ctx, transaccion); err != nil {
+		return notFound(err)
+	}
+
+	s.audit.Record(ctx, client, s.auditEntry(models.AuditTransaccionEditar, transaccion.ID, existing, transaccion))
+	return nil
+}
+
+func (s *TransaccionService) Delete(ctx context.Context, id, usuarioID primitive.ObjectID, client models.ClientInfo) error {
+	existing, err := s.GetByID(ctx, id, usuarioID)
+	if err != nil {
+		return err
+	}
+
+	// Las dos patas de una transferencia se eliminan juntas
+	if existing.Transferencia != nil {
+		return s.deleteTransferencia(ctx, existing, client)
+	}
+
+	if err := s.transaccionRepo.Delete(ctx, id, usuarioID); err != nil {
+		return notFound(err)
+	}
+
+	s.audit.Record(ctx, client, s.auditEntry(models.AuditTransaccionEliminar, id, existing, nil))
+	return nil
+}
+
+func (s *TransaccionService) auditEntry(accion string, id primitive.ObjectID, before, after *models.Transaccion) *models.AuditLog {
+	return &models.AuditLog{
+		Accion:    accion,
+		Recurso:   "transaccion",
+		RecursoID: objectIDPtr(id),
+		Detalle:   auditDiff(before, after),
+	}
+}
+
+// GetEstadisticas suma las transacciones del mes en la moneda base del
+// usuario, convirtiendo cada monto con el tipo de cambio de su fecha. El
+// flujo de caja suma al balance los alquileres cobrados y los movimientos de
+// préstamos.
+func (s *TransaccionService) GetEstadisticas(ctx context.Context, usuarioID primitive.ObjectID, year, month int) (*models.EstadisticasResponse, error) {
+	usuario, err := s.userRepo.FindByID(ctx, usuarioID)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	base := usuario.Moneda()
+
+	// Calcular rango de fechas
+	start := time.Date(year, time.Month(month), 1, 0, 0, 0, 0, time.UTC)
+	end := start.AddDate(0, 1, 0).Add(-time.Second)
+
+	totales, err := s.transaccionRepo.SumByTipoYCategoria(ctx, usuarioID, start, end)
+	if err != nil {
+		return nil, err
+	}
+
+	// Calcular estadísticas
+	var totalIngresos, totalEgresos, alquileres, prestamosEntradas, prestamosSalidas money.Amount
+	porCategoria := make(map[string]money.Amount)
+	tasas := make(map[string]money.Rate)
+
+	for _, t := range totales {
+		total, err := totalEnBase(ctx, s.rates, t, base, tasas)
+		if err != nil {
+			return nil, err
+		}
+
+		if t.Tipo == "ingreso" {
+			totalIngresos += total
+		} else if t.Tipo == "egreso" {
+			totalEgresos += total
+		} else if t.Cobro {
+			alquileres += total
+		} else if t.Sentido == models.PrestamoEntrada {
+			prestamosEntradas += total
+		} else if t.Sentido == models.PrestamoSalida {
+			prestamosSalidas += total
+		}
+
+		// Agregar por categoría (esto requeriría hacer lookup de la categoría)
+		// Por simplicidad, usamos el ID de la categoría como string
+		key := t.CategoriaID.Hex()
+		porCategoria[key] += total
+	}
+
+	return &models.EstadisticasResponse{
+		Moneda:            base,
+		TotalIngresos:     totalIngresos,
+		TotalEgresos:      totalEgresos,
+		Balance:           totalIngresos - totalEgresos,
+		PrestamosEntradas: prestamosEntradas,
+		PrestamosSalidas:  prestamosSalidas,
+		Alquileres:        alquileres,
+		FlujoCaja:         totalIngresos - totalEgresos + alquileres + prestamosEntradas - prestamosSalidas,
+		PorCategoria:      porCategoria,
+	}, nil
+}
+
+// totalEnBase convierte un total de SumByTipoYCategoria a la moneda base con
+// el tipo de cambio de su día. tasas guarda los tipos ya consultados, por
+// moneda y día.
+func totalEnBase(ctx context.Context, rates *ExchangeRateService, t *models.TotalTransacciones, base string, tasas map[string]money.Rate) (money.Amount, error) {
+	// Las transacciones sin moneda están en la moneda base
+	if t.Moneda == "" || t.Moneda == base {
+		return t.Total, nil
+	}
+	tasa, ok := tasas[t.Moneda+t.Dia]
+	if !ok {
+		dia, err := time.Parse(time.DateOnly, t.Dia)
+		if err != nil {
+			return 0, err
+		}
+		if tasa, err = rates.Rate(ctx, t.Moneda, base, dia); err != nil {
+			return 0, err
+		}
+		tasas[t.Moneda+t.Dia] = tasa
+	}
+	total, err := t.Total.Convert(tasa)
+	if err != nil {
+		return 0, err
+	}
+	return total.Round(base), nil
+}
+
+// prepare valida la cuenta, la moneda, el monto, el préstamo o contrato
+// enlazado y la propiedad de la transacción y guarda junto al monto original
+// su equivalente en la moneda base del usuario. Sin moneda se asume la de la
+// cuenta o, sin cuenta, la moneda base. existing es la versión guardada al
+// editar, o nil al crear.
+func (s *TransaccionService) prepare(ctx context.Context, transaccion, existing *models.Transaccion) error {
+	usuario, err := s.userRepo.FindByID(ctx, transaccion.UsuarioID)
+	if err != nil {
+		return notFound(err)
+	}
+
+	var cuenta *models.Cuenta
+	if transaccion.CuentaID != nil {
+		cuenta, err = s.cuentaRepo.FindByID(ctx, *transaccion.CuentaID, transaccion.UsuarioID)
+		if err != nil {
+			if errors.Is(err, mongo.ErrNoDocuments) {
+				return ErrInvalidCuenta
+			}
+			return err
+		}
+		// Una cuenta archivada conserva sus transacciones pero no admite otras
+		sinCambio := existing != nil && existing.CuentaID != nil && *existing.CuentaID == cuenta.ID
+		if cuenta.Archivada && !sinCambio {
+			return ErrInvalidCuenta
+		}
+	}
+	return s.prepareWith(ctx, transaccion, usuario.Moneda(), cuenta)
+}
+
+// prepareWith hace lo mismo que prepare con la moneda base del usuario y la
+// cuenta de la transacción, o nil si no tiene, ya leídas y validadas. Sirve
+// para preparar muchas transacciones de una misma cuenta, como las de una
+// importación, sin volver a leerlas en cada una.
+func (s *TransaccionService) prepareWith(ctx context.Context, transaccion *models.Transaccion, base string, cuenta *models.Cuenta) error {
+	var err error
+	if transaccion.Moneda == "" {
+		transaccion.Moneda = base
+		if cuenta != nil {
+			transaccion.Moneda = cuenta.Moneda
+		}
+	}
+	if transaccion.Moneda, err = normalizeMoneda(transaccion.Moneda); err != nil {
+		return err
+	}
+	if cuenta != nil && transaccion.Moneda != cuenta.Moneda {
+		return fmt.Errorf("%w: la cuenta está en %s", ErrInvalidMoneda, cuenta.Moneda)
+	}
+	if err := normalizeMonto(transaccion); err != nil {
+		return err
+	}
+	if err := normalizeDivisiones(transaccion); err != nil {
+		return err
+	}
+	if err := s.enlazarPrestamo(ctx, transaccion); err != nil {
+		return err
+	}
+	if err := s.enlazarContrato(ctx, transaccion); err != nil {
+		return err
+	}
+
+	montoBase, tasa, err := s.rates.Convert(ctx, transaccion.Monto, transaccion.Moneda, base, transaccion.Fecha)
+	if err != nil {
+		return err
+	}
+	transaccion.MontoBase, transaccion.MonedaBase, transaccion.TipoCambio = &montoBase, base, &tasa
+	return nil
+}
+
+// normalizeMonto redondea el monto a los decimales de la moneda, por ejemplo
+// a céntimos en PEN o a unidades en JPY.
+func normalizeMonto(transaccion *models.Transaccion) error {
+	transaccion.Monto = transaccion.Monto.Round(transaccion.Moneda)
+	if transaccion.Monto <= 0 {
+		return ErrInvalidMonto
+	}
+	return nil
+}
+
+// normalizeDivisiones redondea las divisiones a los decimales de la moneda y
+// comprueba que sumen exactamente el monto de la transacción.
+func normalizeDivisiones(transaccion *models.Transaccion) error {
+	if len(transaccion.Divisiones) == 0 {
+		return nil
+	}
+	if transaccion.Tipo == "transferencia" {
+		return fmt.Errorf("%w: una transferencia no se puede dividir", ErrInvalidDivisiones)
+	}
+	if len(transaccion.Divisiones) < 2 {
+		return fmt.Errorf("%w: indique al menos dos divisiones o use la categoría de la transacción", ErrInvalidDivisiones)
+	}
+
+	var suma money.Amount
+	for i := range transaccion.Divisiones {
+		division := &transaccion.Divisiones[i]
+		division.Monto = division.Monto.Round(transaccion.Moneda)
+		if division.Monto <= 0 {
+			return fmt.Errorf("%w: la división %d debe ser mayor que cero en %s", ErrInvalidDivisiones, i+1, transaccion.Moneda)
+		}
+		suma += division.Monto
+	}
+	if suma != transaccion.Monto {
+		return fmt.Errorf("%w: suman %s y el monto es %s", ErrInvalidDivisiones, suma, transaccion.Monto)
+	}
+	return nil
+}
+
+// El cursor es opaco para el cliente: JSON en base64 URL-safe.
+func encodeTransaccionCursor(c *models.TransaccionCursor) (string, error) {
+	raw, err := json.Marshal(c)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(raw), nil
+}
+
+func decodeTransaccionCursor(cursor string) (*models.TransaccionCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, err
+	}
+	var c models.TransaccionCursor
+	if err := json.Unmarshal(raw, &c); err != nil {
+		return nil, err
+	}
+	if c.ID.IsZero() {
+		return nil, ErrInvalidCursor
+	}
+	return &c, nil
+}
@@ -0,0 +1,516 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"control-financiero/internal/models"
+	"control-financiero/internal/money"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+func transaccionDoc(fecha time.Time, monto string) bson.D {
+	return bson.D{
+		{Key: "_id", Value: primitive.NewObjectID()},
+		{Key: "usuarioId", Value: propietario},
+		{Key: "tipo", Value: "egreso"},
+		{Key: "monto", Value: money.MustParse(monto)},
+		{Key: "fecha", Value: fecha},
+	}
+}
+
+func countResponse(n int64) bson.D {
+	return mtest.CreateCursorResponse(0, "test.transacciones", mtest.FirstBatch, bson.D{{Key: "n", Value: n}})
+}
+
+// propietarioResponse responde a la búsqueda del propietario con la moneda
+// base indicada; vacía usa la moneda por defecto.
+func propietarioResponse(t *testing.T, monedaBase string) bson.D {
+	usuario := usuarioActivo(t)
+	usuario.ID = propietario
+	usuario.MonedaBase = monedaBase
+	return mtest.CreateCursorResponse(0, "test.usuarios", mtest.FirstBatch, bsonDoc(t, usuario))
+}
+
+func TestTransaccion_List(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	ctx := context.Background()
+	hoy := time.Date(2024, 5, 10, 0, 0, 0, 0, time.UTC)
+
+	mt.Run("primera página", func(mt *mtest.T) {
+		s := NewTransaccionService(mt.DB)
+		docs := []bson.D{
+			transaccionDoc(hoy, "10"),
+			transaccionDoc(hoy.AddDate(0, 0, -1), "20"),
+			transaccionDoc(hoy.AddDate(0, 0, -2), "30"),
+		}
+		mt.AddMockResponses(
+			countResponse(7),
+			mtest.CreateCursorResponse(0, "test.transacciones", mtest.FirstBatch, docs...),
+		)
+
+		page, err := s.List(ctx, &models.TransaccionFilter{UsuarioID: propietario, Limite: 2}, "")
+		require.NoError(t, err)
+		assert.Equal(t, int64(7), page.Total)
+		require.Len(t, page.Transacciones, 2)
+		require.NotEmpty(t, page.NextCursor)
+
+		// El cursor apunta a la última transacción devuelta
+		cursor, err := decodeTransaccionCursor(page.NextCursor)
+		require.NoError(t, err)
+		assert.Equal(t, models.OrdenFechaDesc, cursor.Orden)
+		assert.Equal(t, page.Transacciones[1].ID, cursor.ID)
+		assert.True(t, page.Transacciones[1].Fecha.Equal(cursor.Fecha))
+
+		mt.GetStartedEvent() // count
+		evt := mt.GetStartedEvent()
+		require.Equal(t, "find", evt.CommandName)
+		assert.Equal(t, int64(3), evt.Command.Lookup("limit").AsInt64())
+		sort, err := evt.Command.Lookup("sort").Document().Elements()
+		require.NoError(t, err)
+		require.Len(t, sort, 2)
+		assert.Equal(t, "fecha", sort[0].Key())
+		assert.Equal(t, int32(-1), sort[0].Value().Int32())
+		assert.Equal(t, "_id", sort[1].Key())
+	})
+
+	mt.Run("última página", func(mt *mtest.T) {
+		s := NewTransaccionService(mt.DB)
+		mt.AddMockResponses(
+			countResponse(1),
+			mtest.CreateCursorResponse(0, "test.transacciones", mtest.FirstBatch, transaccionDoc(hoy, "10")),
+		)
+
+		page, err := s.List(ctx, &models.TransaccionFilter{UsuarioID: propietario}, "")
+		require.NoError(t, err)
+		assert.Len(t, page.Transacciones, 1)
+		assert.Empty(t, page.NextCursor)
+	})
+
+	mt.Run("página siguiente por monto", func(mt *mtest.T) {
+		s := NewTransaccionService(mt.DB)
+		mt.AddMockResponses(
+			countResponse(0),
+			mtest.CreateCursorResponse(0, "test.transacciones", mtest.FirstBatch),
+		)
+
+		id := primitive.NewObjectID()
+		cursor, err := encodeTransaccionCursor(&models.TransaccionCursor{Orden: models.OrdenMontoAsc, Monto: money.MustParse("25.10"), ID: id})
+		require.NoError(t, err)
+
+		_, err = s.List(ctx, &models.TransaccionFilter{UsuarioID: propietario, Orden: models.OrdenMontoAsc}, cursor)
+		require.NoError(t, err)
+
+		// El total no depende de la página
+		count := mt.GetStartedEvent()
+		require.Equal(t, "aggregate", count.CommandName)
+		assert.NotContains(t, count.Command.String(), "$gt")
+
+		filter := sentFilter(t, mt)
+		assert.Equal(t, propietario, filter.Lookup("usuarioId").ObjectID())
+		values, err := filter.Lookup("$or").Array().Values()
+		require.NoError(t, err)
+		require.Len(t, values, 2)
+		assert.Equal(t, "25.1", values[0].Document().Lookup("monto", "$gt").Decimal128().String())
+		assert.Equal(t, "25.1", values[1].Document().Lookup("monto").Decimal128().String())
+		assert.Equal(t, id, values[1].Document().Lookup("_id", "$gt").ObjectID())
+	})
+
+	mt.Run("cursor de otro orden", func(mt *mtest.T) {
+		s := NewTransaccionService(mt.DB)
+
+		cursor, err := encodeTransaccionCursor(&models.TransaccionCursor{Orden: models.OrdenFechaDesc, ID: primitive.NewObjectID()})
+		require.NoError(t, err)
+
+		_, err = s.List(ctx, &models.TransaccionFilter{UsuarioID: propietario, Orden: models.OrdenMontoDesc}, cursor)
+		assert.ErrorIs(t, err, ErrInvalidCursor)
+
+		_, err = s.List(ctx, &models.TransaccionFilter{UsuarioID: propietario}, "no-es-un-cursor")
+		assert.ErrorIs(t, err, ErrInvalidCursor)
+	})
+
+	mt.Run("filtros", func(mt *mtest.T) {
+		s := NewTransaccionService(mt.DB)
+		mt.AddMockResponses(
+			countResponse(0),
+			mtest.CreateCursorResponse(0, "test.transacciones", mtest.FirstBatch),
+		)
+
+		categoria := primitive.NewObjectID()
+		montoMin, montoMax := money.MustParse("5"), money.MustParse("50.5")
+		desde := hoy.AddDate(0, -1, 0)
+		_, err := s.List(ctx, &models.TransaccionFilter{
+			UsuarioID:   propietario,
+			Desde:       &desde,
+			Tipo:        "egreso",
+			CategoriaID: &categoria,
+			Tag:         "viaje",
+			MontoMin:    &montoMin,
+			MontoMax:    &montoMax,
+			Descripcion: "café (sucursal)",
+		}, "")
+		require.NoError(t, err)
+
+		filter := sentFilter(t, mt)
+		assert.Equal(t, "egreso", filter.Lookup("tipo").StringValue())
+		// La categoría y la etiqueta también se buscan en las divisiones
+		condiciones, err := filter.Lookup("$and").Array().Values()
+		require.NoError(t, err)
+		require.Len(t, condiciones, 2)
+		assert.Equal(t, categoria, condiciones[0].Document().Lookup("$or", "0", "categoriaId").ObjectID())
+		assert.Equal(t, categoria, condiciones[0].Document().Lookup("$or", "1", "divisiones.categoriaId").ObjectID())
+		assert.Equal(t, "viaje", condiciones[1].Document().Lookup("$or", "0", "tags").StringValue())
+		assert.Equal(t, "viaje", condiciones[1].Document().Lookup("$or", "1", "divisiones.tags").StringValue())
+		assert.Equal(t, desde, filter.Lookup("fecha", "$gte").Time().UTC())
+		assert.Equal(t, montoMin.Decimal128(), filter.Lookup("monto", "$gte").Decimal128())
+		assert.Equal(t, montoMax.Decimal128(), filter.Lookup("monto", "$lte").Decimal128())
+
+		// La búsqueda es literal aunque el texto tenga caracteres de regex
+		pattern, options := filter.Lookup("descripcion").Regex()
+		assert.Equal(t, `café \(sucursal\)`, pattern)
+		assert.Equal(t, "i", options)
+	})
+}
+
+func TestTransaccion_Create(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	fecha := time.Date(2024, 5, 3, 15, 0, 0, 0, time.UTC)
+
+	mt.Run("guarda el monto convertido a la moneda base", func(mt *mtest.T) {
+		s := NewTransaccionService(mt.DB)
+		mt.AddMockResponses(
+			propietarioResponse(t, "PEN"),
+			mtest.CreateCursorResponse(0, "test.exchange_rates", mtest.FirstBatch,
+				exchangeRateDoc("USD", "PEN", time.Date(2024, 5, 2, 0, 0, 0, 0, time.UTC), "3.7512")),
+			mtest.CreateCursorResponse(0, "test.exchange_rates", mtest.FirstBatch),
+			mtest.CreateSuccessResponse(),
+			mtest.CreateSuccessResponse(),
+		)
+
+		transaccion := &models.Transaccion{UsuarioID: propietario, Tipo: "egreso", Monto: money.MustParse("10.005"), Moneda: "usd", Fecha: fecha}
+		require.NoError(t, s.Create(context.Background(), transaccion, models.ClientInfo{}))
+		assert.Equal(t, "USD", transaccion.Moneda)
+		assert.Equal(t, "10.01", transaccion.Monto.String())
+		assert.Equal(t, "PEN", transaccion.MonedaBase)
+		assert.Equal(t, "3.7512", transaccion.TipoCambio.String())
+		assert.Equal(t, "37.55", transaccion.MontoBase.String())
+
+		// El cambio vigente es el último anterior a la fecha de la transacción
+		evt := mt.GetStartedEvent()
+		for evt != nil && evt.Command.Lookup("find").StringValue() != "exchange_rates" {
+			evt = mt.GetStartedEvent()
+		}
+		require.NotNil(t, evt)
+		assert.Equal(t, time.Date(2024, 5, 3, 0, 0, 0, 0, time.UTC), evt.Command.Lookup("filter", "fecha", "$lte").Time().UTC())
+	})
+
+	mt.Run("sin moneda usa la moneda base", func(mt *mtest.T) {
+		s := NewTransaccionService(mt.DB)
+		mt.AddMockResponses(propietarioResponse(t, "JPY"), mtest.CreateSuccessResponse(), mtest.CreateSuccessResponse())
+
+		transaccion := &models.Transaccion{UsuarioID: propietario, Tipo: "egreso", Monto: money.MustParse("1500.4"), Fecha: fecha}
+		require.NoError(t, s.Create(context.Background(), transaccion, models.ClientInfo{}))
+		assert.Equal(t, "JPY", transaccion.Moneda)
+		assert.Equal(t, "1500", transaccion.MontoBase.String())
+		assert.Equal(t, money.OneRate, *transaccion.TipoCambio)
+	})
+
+	mt.Run("rechaza monedas que no son ISO 4217", func(mt *mtest.T) {
+		s := NewTransaccionService(mt.DB)
+		mt.AddMockResponses(propietarioResponse(t, ""))
+
+		err := s.Create(context.Background(), &models.Transaccion{UsuarioID: propietario, Monto: money.MustParse("10"), Moneda: "S/", Fecha: fecha}, models.ClientInfo{})
+		assert.ErrorIs(t, err, ErrInvalidMoneda)
+	})
+
+	mt.Run("sin tipo de cambio no se guarda", func(mt *mtest.T) {
+		s := NewTransaccionService(mt.DB)
+		mt.AddMockResponses(
+			propietarioResponse(t, "PEN"),
+			mtest.CreateCursorResponse(0, "test.exchange_rates", mtest.FirstBatch),
+			mtest.CreateCursorResponse(0, "test.exchange_rates", mtest.FirstBatch),
+		)
+
+		err := s.Create(context.Background(), &models.Transaccion{UsuarioID: propietario, Monto: money.MustParse("10"), Moneda: "EUR", Fecha: fecha}, models.ClientInfo{})
+		var sinCambio *RateNotFoundError
+		require.ErrorAs(t, err, &sinCambio)
+		assert.Equal(t, "EUR", sinCambio.Moneda)
+		assert.Equal(t, "PEN", sinCambio.Base)
+	})
+}
+
+func TestTransaccion_Cuenta(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	fecha := time.Date(2024, 5, 3, 15, 0, 0, 0, time.UTC)
+	cuentaID := primitive.NewObjectID()
+
+	mt.Run("sin moneda usa la de la cuenta", func(mt *mtest.T) {
+		s := NewTransaccionService(mt.DB)
+		mt.AddMockResponses(
+			propietarioResponse(t, "USD"),
+			mtest.CreateCursorResponse(0, "test.cuentas", mtest.FirstBatch, cuentaDoc(cuentaID, "PEN", "0", false)),
+			mtest.CreateCursorResponse(0, "test.exchange_rates", mtest.FirstBatch,
+				exchangeRateDoc("PEN", "USD", time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC), "0.25")),
+			mtest.CreateCursorResponse(0, "test.exchange_rates", mtest.FirstBatch),
+			mtest.CreateSuccessResponse(),
+			mtest.CreateSuccessResponse(),
+		)
+
+		transaccion := &models.Transaccion{UsuarioID: propietario, Tipo: "egreso", Monto: money.MustParse("40"), Fecha: fecha, CuentaID: &cuentaID}
+		require.NoError(t, s.Create(context.Background(), transaccion, models.ClientInfo{}))
+		assert.Equal(t, "PEN", transaccion.Moneda)
+		assert.Equal(t, "10", transaccion.MontoBase.String())
+	})
+
+	mt.Run("la moneda debe ser la de la cuenta", func(mt *mtest.T) {
+		s := NewTransaccionService(mt.DB)
+		mt.AddMockResponses(
+			propietarioResponse(t, "USD"),
+			mtest.CreateCursorResponse(0, "test.cuentas", mtest.FirstBatch, cuentaDoc(cuentaID, "PEN", "0", false)),
+		)
+
+		err := s.Create(context.Background(), &models.Transaccion{UsuarioID: propietario, Monto: money.MustParse("40"), Moneda: "USD", Fecha: fecha, CuentaID: &cuentaID}, models.ClientInfo{})
+		assert.ErrorIs(t, err, ErrInvalidMoneda)
+	})
+
+	mt.Run("cuenta ajena o archivada", func(mt *mtest.T) {
+		s := NewTransaccionService(mt.DB)
+		mt.AddMockResponses(
+			propietarioResponse(t, ""),
+			mtest.CreateCursorResponse(0, "test.cuentas", mtest.FirstBatch),
+			propietarioResponse(t, ""),
+			mtest.CreateCursorResponse(0, "test.cuentas", mtest.FirstBatch, cuentaDoc(cuentaID, "USD", "0", true)),
+		)
+
+		transaccion := &models.Transaccion{UsuarioID: propietario, Monto: money.MustParse("40"), Fecha: fecha, CuentaID: &cuentaID}
+		assert.ErrorIs(t, s.Create(context.Background(), transaccion, models.ClientInfo{}), ErrInvalidCuenta)
+		assert.ErrorIs(t, s.Create(context.Background(), transaccion, models.ClientInfo{}), ErrInvalidCuenta)
+	})
+
+	mt.Run("se puede editar una transacción de una cuenta archivada", func(mt *mtest.T) {
+		s := NewTransaccionService(mt.DB)
+		existing := transaccionDoc(fecha, "40")
+		existing = append(existing, bson.E{Key: "cuentaId", Value: cuentaID})
+		mt.AddMockResponses(
+			mtest.CreateCursorResponse(0, "test.transacciones", mtest.FirstBatch, existing),
+			propietarioResponse(t, ""),
+			mtest.CreateCursorResponse(0, "test.cuentas", mtest.FirstBatch, cuentaDoc(cuentaID, "USD", "0", true)),
+			bson.D{{Key: "ok", Value: 1}, {Key: "n", Value: 1}, {Key: "nModified", Value: 1}},
+			mtest.CreateSuccessResponse(),
+		)
+
+		transaccion := &models.Transaccion{ID: existing[0].Value.(primitive.ObjectID), UsuarioID: propietario, Tipo: "egreso", Monto: money.MustParse("45"), Fecha: fecha, CuentaID: &cuentaID}
+		require.NoError(t, s.Update(context.Background(), transaccion, models.ClientInfo{}))
+	})
+}
+
+func TestTransaccion_Divisiones(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	fecha := time.Date(2024, 5, 3, 15, 0, 0, 0, time.UTC)
+	comida, limpieza := primitive.NewObjectID(), primitive.NewObjectID()
+
+	division := func(categoria primitive.ObjectID, monto string) models.Division {
+		return models.Division{CategoriaID: categoria, Monto: money.MustParse(monto)}
+	}
+
+	mt.Run("deben sumar el monto", func(mt *mtest.T) {
+		s := NewTransaccionService(mt.DB)
+		mt.AddMockResponses(
+			propietarioResponse(t, ""), mtest.CreateSuccessResponse(), mtest.CreateSuccessResponse(),
+			propietarioResponse(t, ""),
+			propietarioResponse(t, ""),
+		)
+
+		// Las divisiones se redondean como el monto antes de sumarlas
+		transaccion := &models.Transaccion{UsuarioID: propietario, Tipo: "egreso", CategoriaID: comida, Monto: money.MustParse("30.004"), Fecha: fecha,
+			Divisiones: []models.Division{division(comida, "20.001"), division(limpieza, "10")}}
+		require.NoError(t, s.Create(context.Background(), transaccion, models.ClientInfo{}))
+		assert.Equal(t, "20", transaccion.Divisiones[0].Monto.String())
+
+		transaccion = &models.Transaccion{UsuarioID: propietario, Tipo: "egreso", CategoriaID: comida, Monto: money.MustParse("30"), Fecha: fecha,
+			Divisiones: []models.Division{division(comida, "20"), division(limpieza, "9.99")}}
+		err := s.Create(context.Background(), transaccion, models.ClientInfo{})
+		assert.ErrorIs(t, err, ErrInvalidDivisiones)
+		assert.EqualError(t, err, "divisiones inválidas: suman 29.99 y el monto es 30")
+
+		transaccion = &models.Transaccion{UsuarioID: propietario, Tipo: "egreso", CategoriaID: comida, Monto: money.MustParse("30"), Fecha: fecha,
+			Divisiones: []models.Division{division(comida, "30")}}
+		assert.ErrorIs(t, s.Create(context.Background(), transaccion, models.ClientInfo{}), ErrInvalidDivisiones)
+	})
+
+	mt.Run("al editar sin divisiones se quitan", func(mt *mtest.T) {
+		s := NewTransaccionService(mt.DB)
+		existing := transaccionDoc(fecha, "30")
+		mt.AddMockResponses(
+			mtest.CreateCursorResponse(0, "test.transacciones", mtest.FirstBatch, existing),
+			propietarioResponse(t, ""),
+			bson.D{{Key: "ok", Value: 1}, {Key: "n", Value: 1}, {Key: "nModified", Value: 1}},
+			mtest.CreateSuccessResponse(),
+		)
+
+		transaccion := &models.Transaccion{ID: existing[0].Value.(primitive.ObjectID), UsuarioID: propietario, Tipo: "egreso", CategoriaID: comida, Monto: money.MustParse("30"), Fecha: fecha}
+		require.NoError(t, s.Update(context.Background(), transaccion, models.ClientInfo{}))
+
+		update := startedEvent(t, mt, func(cmd bson.Raw) bool {
+			_, err := cmd.LookupErr("updates")
+			return err == nil
+		})
+		_, err := update.Command.Lookup("updates").Array().Index(0).Value().Document().LookupErr("u", "$unset", "divisiones")
+		assert.NoError(t, err)
+	})
+}
+
+func TestTransaccion_GetEstadisticas(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	comida := primitive.NewObjectID()
+	sueldo := primitive.NewObjectID()
+
+	total := func(tipo string, categoria primitive.ObjectID, moneda, dia, monto string) bson.D {
+		d, err := primitive.ParseDecimal128(monto)
+		require.NoError(t, err)
+		return bson.D{
+			{Key: "tipo", Value: tipo},
+			{Key: "categoriaId", Value: categoria},
+			{Key: "moneda", Value: moneda},
+			{Key: "dia", Value: dia},
+			{Key: "total", Value: d},
+		}
+	}
+
+	mt.Run("suma en la base de datos", func(mt *mtest.T) {
+		s := NewTransaccionService(mt.DB)
+		mt.AddMockResponses(
+			propietarioResponse(t, ""),
+			mtest.CreateCursorResponse(0, "test.transacciones", mtest.FirstBatch,
+				total("ingreso", sueldo, "USD", "2024-05-01", "3000.10"),
+				total("egreso", comida, "USD", "2024-05-02", "1000.0000000000000"),
+				total("egreso", sueldo, "", "2024-05-03", "0.20"),
+			),
+		)
+
+		stats, err := s.GetEstadisticas(context.Background(), propietario, 2024, 5)
+		require.NoError(t, err)
+		assert.Equal(t, "USD", stats.Moneda)
+		assert.Equal(t, "3000.1", stats.TotalIngresos.String())
+		assert.Equal(t, "1000.2", stats.TotalEgresos.String())
+		assert.Equal(t, "1999.9", stats.Balance.String())
+		assert.Equal(t, "3000.3", stats.PorCategoria[sueldo.Hex()].String())
+
+		evt := mt.GetStartedEvent()
+		for evt != nil && evt.CommandName != "aggregate" {
+			evt = mt.GetStartedEvent()
+		}
+		require.NotNil(t, evt)
+		stages, err := evt.Command.Lookup("pipeline").Array().Values()
+		require.NoError(t, err)
+		assert.Equal(t, propietario, stages[0].Document().Lookup("$match", "usuarioId").ObjectID())
+		// Las transferencias no son ingresos ni egresos
+		assert.Equal(t, "transferencia", stages[0].Document().Lookup("$match", "tipo", "$ne").StringValue())
+		// Cada división se suma en su categoría; sin divisiones, la transacción
+		// entera es una línea
+		assert.Equal(t, "$divisiones", stages[1].Document().Lookup("$set", "lineas", "$cond", "1").StringValue())
+		assert.Equal(t, "$lineas", stages[2].Document().Lookup("$unwind").StringValue())
+		assert.Equal(t, "$lineas.categoriaId", stages[3].Document().Lookup("$group", "_id", "categoriaId").StringValue())
+		assert.Equal(t, "$lineas.monto", stages[3].Document().Lookup("$group", "total", "$sum").StringValue())
+	})
+	mt.Run("convierte con el tipo de cambio de cada día", func(mt *mtest.T) {
+		s := NewTransaccionService(mt.DB)
+		mt.AddMockResponses(
+			propietarioResponse(t, "PEN"),
+			mtest.CreateCursorResponse(0, "test.transacciones", mtest.FirstBatch,
+				total("ingreso", sueldo, "PEN", "2024-05-01", "3000"),
+				total("egreso", comida, "USD", "2024-05-01", "100"),
+				total("egreso", sueldo, "USD", "2024-05-01", "10"),
+				total("egreso", comida, "USD", "2024-05-20", "100"),
+			),
+			// 1 de mayo: solo hay cambio PEN→USD, se usa el inverso
+			mtest.CreateCursorResponse(0, "test.exchange_rates", mtest.FirstBatch),
+			mtest.CreateCursorResponse(0, "test.exchange_rates", mtest.FirstBatch,
+				exchangeRateDoc("PEN", "USD", time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC), "0.25")),
+			// 20 de mayo
+			mtest.CreateCursorResponse(0, "test.exchange_rates", mtest.FirstBatch,
+				exchangeRateDoc("USD", "PEN", time.Date(2024, 5, 20, 0, 0, 0, 0, time.UTC), "3.8")),
+			mtest.CreateCursorResponse(0, "test.exchange_rates", mtest.FirstBatch),
+		)
+
+		stats, err := s.GetEstadisticas(context.Background(), propietario, 2024, 5)
+		require.NoError(t, err)
+		assert.Equal(t, "PEN", stats.Moneda)
+		assert.Equal(t, "3000", stats.TotalIngresos.String())
+		assert.Equal(t, "820", stats.TotalEgresos.String()) // 400 + 40 + 380
+		assert.Equal(t, "780", stats.PorCategoria[comida.Hex()].String())
+		assert.Equal(t, "3040", stats.PorCategoria[sueldo.Hex()].String())
+	})
+
+	mt.Run("los préstamos solo cuentan en el flujo de caja", func(mt *mtest.T) {
+		s := NewTransaccionService(mt.DB)
+		prestamo := primitive.NewObjectID()
+		mt.AddMockResponses(
+			propietarioResponse(t, ""),
+			mtest.CreateCursorResponse(0, "test.transacciones", mtest.FirstBatch,
+				total("ingreso", sueldo, "USD", "2024-05-01", "3000"),
+				total("egreso", comida, "USD", "2024-05-02", "1000"),
+				append(total("prestamo", prestamo, "USD", "2024-05-03", "5000"), bson.E{Key: "sentido", Value: models.PrestamoEntrada}),
+				append(total("prestamo", prestamo, "USD", "2024-05-20", "450"), bson.E{Key: "sentido", Value: models.PrestamoSalida}),
+				total("prestamo", prestamo, "USD", "2024-05-21", "70"),
+			),
+		)
+
+		stats, err := s.GetEstadisticas(context.Background(), propietario, 2024, 5)
+		require.NoError(t, err)
+		assert.Equal(t, "2000", stats.Balance.String())
+		assert.Equal(t, "5000", stats.PrestamosEntradas.String())
+		assert.Equal(t, "450", stats.PrestamosSalidas.String())
+		assert.Equal(t, "6550", stats.FlujoCaja.String())
+
+		evt := startedEvent(t, mt, func(cmd bson.Raw) bool {
+			_, err := cmd.LookupErr("aggregate")
+			return err == nil
+		})
+		stages, err := evt.Command.Lookup("pipeline").Array().Values()
+		require.NoError(t, err)
+		assert.Equal(t, "$prestamo.sentido", stages[3].Document().Lookup("$group", "_id", "sentido").StringValue())
+	})
+
+	mt.Run("los alquileres cobrados suman al flujo de caja", func(mt *mtest.T) {
+		s := NewTransaccionService(mt.DB)
+		alquiler := primitive.NewObjectID()
+		mt.AddMockResponses(
+			propietarioResponse(t, ""),
+			mtest.CreateCursorResponse(0, "test.transacciones", mtest.FirstBatch,
+				total("ingreso", sueldo, "USD", "2024-05-01", "3000"),
+				append(total("alquiler", alquiler, "USD", "2024-05-05", "800"), bson.E{Key: "cobro", Value: true}),
+				total("alquiler", alquiler, "USD", "2024-05-06", "90"),
+			),
+		)
+
+		stats, err := s.GetEstadisticas(context.Background(), propietario, 2024, 5)
+		require.NoError(t, err)
+		assert.Equal(t, "3000", stats.Balance.String())
+		assert.Equal(t, "800", stats.Alquileres.String())
+		assert.Equal(t, "3800", stats.FlujoCaja.String())
+	})
+
+	mt.Run("falla si falta un tipo de cambio", func(mt *mtest.T) {
+		s := NewTransaccionService(mt.DB)
+		mt.AddMockResponses(
+			propietarioResponse(t, "PEN"),
+			mtest.CreateCursorResponse(0, "test.transacciones", mtest.FirstBatch,
+				total("egreso", comida, "EUR", "2024-05-07", "100"),
+			),
+			mtest.CreateCursorResponse(0, "test.exchange_rates", mtest.FirstBatch),
+			mtest.CreateCursorResponse(0, "test.exchange_rates", mtest.FirstBatch),
+		)
+
+		_, err := s.GetEstadisticas(context.Background(), propietario, 2024, 5)
+		var sinCambio *RateNotFoundError
+		require.ErrorAs(t, err, &sinCambio)
+		assert.Equal(t, "no hay tipo de cambio de EUR a PEN para el 2024-05-07", err.Error())
+	})
+}
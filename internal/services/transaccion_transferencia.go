@@ -0,0 +1,220 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"control-financiero/internal/models"
+	"control-financiero/internal/money"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ErrInvalidTransferencia envuelve los errores de validación de las
+// transferencias entre cuentas.
+var ErrInvalidTransferencia = errors.New("transferencia inválida")
+
+// Transferir mueve dinero entre dos cuentas del usuario. Crea la salida en la
+// cuenta de origen y la entrada en la de destino, convertida con el tipo de
+// cambio indicado, dentro de una misma transacción de MongoDB.
+func (s *TransaccionService) Transferir(ctx context.Context, usuarioID primitive.ObjectID, req *models.TransferenciaRequest, client models.ClientInfo) (*models.Transferencia, error) {
+	if req.CuentaOrigenID == req.CuentaDestinoID {
+		return nil, fmt.Errorf("%w: la cuenta de origen y la de destino son la misma", ErrInvalidTransferencia)
+	}
+
+	transferencia := &models.Transferencia{ID: primitive.NewObjectID()}
+	transferencia.Salida = &models.Transaccion{
+		UsuarioID:     usuarioID,
+		Tipo:          "transferencia",
+		Monto:         req.Monto,
+		Fecha:         req.Fecha,
+		Descripcion:   req.Descripcion,
+		CuentaID:      &req.CuentaOrigenID,
+		Tags:          req.Tags,
+		Transferencia: &models.EnlaceTransferencia{ID: transferencia.ID, Sentido: models.TransferenciaSalida},
+	}
+	if err := s.prepare(ctx, transferencia.Salida, nil); err != nil {
+		return nil, err
+	}
+
+	destino, err := s.cuentaRepo.FindByID(ctx, req.CuentaDestinoID, usuarioID)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, ErrInvalidCuenta
+		}
+		return nil, err
+	}
+	tasa, err := tasaTransferencia(transferencia.Salida.Moneda, destino.Moneda, req.TipoCambio)
+	if err != nil {
+		return nil, err
+	}
+	transferencia.Salida.Transferencia.Tasa = tasa
+
+	montoEntrada, err := convertirTransferencia(transferencia.Salida.Monto, tasa, destino.Moneda)
+	if err != nil {
+		return nil, err
+	}
+	transferencia.Entrada = &models.Transaccion{
+		UsuarioID:     usuarioID,
+		Tipo:          "transferencia",
+		Monto:         montoEntrada,
+		Moneda:        destino.Moneda,
+		Fecha:         req.Fecha,
+		Descripcion:   req.Descripcion,
+		CuentaID:      &destino.ID,
+		Tags:          req.Tags,
+		Transferencia: &models.EnlaceTransferencia{ID: transferencia.ID, Sentido: models.TransferenciaEntrada, Tasa: tasa},
+	}
+	if err := s.prepare(ctx, transferencia.Entrada, nil); err != nil {
+		return nil, err
+	}
+
+	err = withTransaction(ctx, s.client, func(ctx mongo.SessionContext) error {
+		if err := s.transaccionRepo.Create(ctx, transferencia.Salida); err != nil {
+			return err
+		}
+		return s.transaccionRepo.Create(ctx, transferencia.Entrada)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.audit.Record(ctx, client, &models.AuditLog{
+		Accion:    models.AuditTransferenciaCrear,
+		Recurso:   "transferencia",
+		RecursoID: objectIDPtr(transferencia.ID),
+		Detalle:   auditDiff(nil, transferencia),
+	})
+	return transferencia, nil
+}
+
+// updateTransferencia edita una pata de una transferencia y ajusta la otra:
+// las dos comparten fecha, descripción y etiquetas, y si cambia el monto el
+// de la otra se recalcula con el tipo de cambio de la transferencia.
+func (s *TransaccionService) updateTransferencia(ctx context.Context, transaccion, existing *models.Transaccion, client models.ClientInfo) error {
+	if transaccion.Tipo != "transferencia" || transaccion.CuentaID == nil {
+		return fmt.Errorf("%w: las patas de una transferencia no pueden cambiar de tipo ni quedarse sin cuenta", ErrInvalidTransferencia)
+	}
+
+	contraparte, err := s.transaccionRepo.FindContraparte(ctx, existing)
+	if err != nil {
+		return notFound(err)
+	}
+	if *transaccion.CuentaID == *contraparte.CuentaID {
+		return fmt.Errorf("%w: la cuenta de origen y la de destino son la misma", ErrInvalidTransferencia)
+	}
+
+	// El enlace no lo puede modificar el cliente
+	transaccion.Transferencia = existing.Transferencia
+	if err := s.prepare(ctx, transaccion, existing); err != nil {
+		return err
+	}
+	if transaccion.Moneda != existing.Moneda {
+		return fmt.Errorf("%w: no se puede cambiar la moneda de una transferencia; elimínela y créela de nuevo", ErrInvalidTransferencia)
+	}
+
+	otra := *contraparte
+	otra.Fecha, otra.Descripcion, otra.Tags = transaccion.Fecha, transaccion.Descripcion, transaccion.Tags
+	if transaccion.Monto != existing.Monto {
+		tasa := existing.Transferencia.Tasa
+		if existing.Transferencia.Sentido == models.TransferenciaEntrada {
+			tasa = tasa.Inverse()
+		}
+		if otra.Monto, err = convertirTransferencia(transaccion.Monto, tasa, otra.Moneda); err != nil {
+			return err
+		}
+	}
+	if err := s.prepare(ctx, &otra, contraparte); err != nil {
+		return err
+	}
+
+	// La fecha de creación no se puede cambiar
+	transaccion.CreatedAt = existing.CreatedAt
+
+	err = withTransaction(ctx, s.client, func(ctx mongo.SessionContext) error {
+		if err := s.transaccionRepo.Update(ctx, transaccion); err != nil {
+			return err
+		}
+		return s.transaccionRepo.Update(ctx, &otra)
+	})
+	if err != nil {
+		return notFound(err)
+	}
+
+	s.audit.Record(ctx, client, s.auditEntry(models.AuditTransaccionEditar, transaccion.ID, existing, transaccion))
+	s.audit.Record(ctx, client, s.auditEntry(models.AuditTransaccionEditar, otra.ID, contraparte, &otra))
+	return nil
+}
+
+// deleteTransferencia elimina las dos patas de una transferencia.
+func (s *TransaccionService) deleteTransferencia(ctx context.Context, existing *models.Transaccion, client models.ClientInfo) error {
+	// Si la otra pata ya no existe se elimina solo esta
+	contraparte, err := s.transaccionRepo.FindContraparte(ctx, existing)
+	if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+		return err
+	}
+
+	err = withTransaction(ctx, s.client, func(ctx mongo.SessionContext) error {
+		if err := s.transaccionRepo.Delete(ctx, existing.ID, existing.UsuarioID); err != nil {
+			return err
+		}
+		if contraparte == nil {
+			return nil
+		}
+		return s.transaccionRepo.Delete(ctx, contraparte.ID, contraparte.UsuarioID)
+	})
+	if err != nil {
+		return notFound(err)
+	}
+
+	s.audit.Record(ctx, client, s.auditEntry(models.AuditTransaccionEliminar, existing.ID, existing, nil))
+	if contraparte != nil {
+		s.audit.Record(ctx, client, s.auditEntry(models.AuditTransaccionEliminar, contraparte.ID, contraparte, nil))
+	}
+	return nil
+}
+
+// tasaTransferencia valida el tipo de cambio de una transferencia de moneda
+// a destino. Entre cuentas de la misma moneda es 1 y puede omitirse.
+func tasaTransferencia(moneda, destino string, tipoCambio *money.Rate) (money.Rate, error) {
+	if moneda == destino {
+		if tipoCambio != nil && *tipoCambio != money.OneRate {
+			return 0, fmt.Errorf("%w: las dos cuentas están en %s y el tipo de cambio debe ser 1", ErrInvalidTransferencia, moneda)
+		}
+		return money.OneRate, nil
+	}
+	if tipoCambio == nil {
+		return 0, fmt.Errorf("%w: indique el tipo de cambio de %s a %s", ErrInvalidTransferencia, moneda, destino)
+	}
+	return *tipoCambio, nil
+}
+
+// convertirTransferencia calcula el monto de la otra pata en su moneda.
+func convertirTransferencia(monto money.Amount, tasa money.Rate, moneda string) (money.Amount, error) {
+	convertido, err := monto.Convert(tasa)
+	if err != nil {
+		return 0, err
+	}
+	convertido = convertido.Round(moneda)
+	if convertido <= 0 {
+		return 0, ErrInvalidMonto
+	}
+	return convertido, nil
+}
+
+// withTransaction ejecuta fn en una transacción de MongoDB, que se reintenta
+// ante errores transitorios. Requiere un replica set.
+func withTransaction(ctx context.Context, client *mongo.Client, fn func(ctx mongo.SessionContext) error) error {
+	session, err := client.StartSession()
+	if err != nil {
+		return err
+	}
+	defer session.EndSession(ctx)
+
+	_, err = session.WithTransaction(ctx, func(ctx mongo.SessionContext) (interface{}, error) {
+		return nil, fn(ctx)
+	})
+	return err
+}
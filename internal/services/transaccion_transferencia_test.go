@@ -0,0 +1,266 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"control-financiero/internal/models"
+	"control-financiero/internal/money"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+func TestTransaccion_Transferir(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	ctx := context.Background()
+	fecha := time.Date(2024, 5, 3, 15, 0, 0, 0, time.UTC)
+	origen, destino := primitive.NewObjectID(), primitive.NewObjectID()
+
+	mt.Run("crea las dos patas en una transacción", func(mt *mtest.T) {
+		s := NewTransaccionService(mt.DB)
+		tasa := money.MustParseRate("3.75")
+		mt.AddMockResponses(
+			// Salida en USD, la moneda base
+			propietarioResponse(t, "USD"),
+			mtest.CreateCursorResponse(0, "test.cuentas", mtest.FirstBatch, cuentaDoc(origen, "USD", "0", false)),
+			// Entrada en PEN
+			mtest.CreateCursorResponse(0, "test.cuentas", mtest.FirstBatch, cuentaDoc(destino, "PEN", "0", false)),
+			propietarioResponse(t, "USD"),
+			mtest.CreateCursorResponse(0, "test.cuentas", mtest.FirstBatch, cuentaDoc(destino, "PEN", "0", false)),
+			mtest.CreateCursorResponse(0, "test.exchange_rates", mtest.FirstBatch),
+			mtest.CreateCursorResponse(0, "test.exchange_rates", mtest.FirstBatch,
+				exchangeRateDoc("USD", "PEN", time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC), "4")),
+			mtest.CreateSuccessResponse(),
+			mtest.CreateSuccessResponse(),
+			mtest.CreateSuccessResponse(), // commit
+			mtest.CreateSuccessResponse(), // auditoría
+		)
+
+		transferencia, err := s.Transferir(ctx, propietario, &models.TransferenciaRequest{
+			CuentaOrigenID:  origen,
+			CuentaDestinoID: destino,
+			Monto:           money.MustParse("100"),
+			TipoCambio:      &tasa,
+			Fecha:           fecha,
+		}, models.ClientInfo{})
+		require.NoError(t, err)
+
+		salida, entrada := transferencia.Salida, transferencia.Entrada
+		assert.Equal(t, "-100", salida.Importe().String())
+		assert.Equal(t, "375", entrada.Importe().String())
+		assert.Equal(t, "PEN", entrada.Moneda)
+		assert.Equal(t, transferencia.ID, salida.Transferencia.ID)
+		assert.Equal(t, transferencia.ID, entrada.Transferencia.ID)
+		assert.Equal(t, tasa, entrada.Transferencia.Tasa)
+		// En la moneda base se valora con el cambio del día, no el pactado
+		assert.Equal(t, "93.75", entrada.MontoBase.String())
+
+		var inserts, commit int
+		for evt := mt.GetStartedEvent(); evt != nil; evt = mt.GetStartedEvent() {
+			switch {
+			case evt.CommandName == "insert" && evt.Command.Lookup("insert").StringValue() == "transacciones":
+				inserts++
+				_, enTransaccion := evt.Command.Lookup("txnNumber").Int64OK()
+				assert.True(t, enTransaccion)
+			case evt.CommandName == "commitTransaction":
+				commit++
+			}
+		}
+		assert.Equal(t, 2, inserts)
+		assert.Equal(t, 1, commit)
+	})
+
+	mt.Run("entre monedas distintas exige el tipo de cambio", func(mt *mtest.T) {
+		s := NewTransaccionService(mt.DB)
+		mt.AddMockResponses(
+			propietarioResponse(t, "USD"),
+			mtest.CreateCursorResponse(0, "test.cuentas", mtest.FirstBatch, cuentaDoc(origen, "USD", "0", false)),
+			mtest.CreateCursorResponse(0, "test.cuentas", mtest.FirstBatch, cuentaDoc(destino, "PEN", "0", false)),
+		)
+
+		_, err := s.Transferir(ctx, propietario, &models.TransferenciaRequest{
+			CuentaOrigenID:  origen,
+			CuentaDestinoID: destino,
+			Monto:           money.MustParse("100"),
+			Fecha:           fecha,
+		}, models.ClientInfo{})
+		assert.ErrorIs(t, err, ErrInvalidTransferencia)
+	})
+
+	mt.Run("a la misma cuenta", func(mt *mtest.T) {
+		s := NewTransaccionService(mt.DB)
+		_, err := s.Transferir(ctx, propietario, &models.TransferenciaRequest{
+			CuentaOrigenID:  origen,
+			CuentaDestinoID: origen,
+			Monto:           money.MustParse("100"),
+			Fecha:           fecha,
+		}, models.ClientInfo{})
+		assert.ErrorIs(t, err, ErrInvalidTransferencia)
+	})
+
+	mt.Run("no se crean patas sueltas", func(mt *mtest.T) {
+		s := NewTransaccionService(mt.DB)
+		err := s.Create(ctx, &models.Transaccion{UsuarioID: propietario, Tipo: "transferencia", Monto: money.MustParse("1"), Fecha: fecha}, models.ClientInfo{})
+		assert.ErrorIs(t, err, ErrInvalidTransferencia)
+	})
+
+	mt.Run("una transacción suelta no se enlaza con una transferencia", func(mt *mtest.T) {
+		s := NewTransaccionService(mt.DB)
+		mt.AddMockResponses(propietarioResponse(t, "USD"), mtest.CreateSuccessResponse(), mtest.CreateSuccessResponse())
+
+		transaccion := &models.Transaccion{
+			UsuarioID: propietario, Tipo: "egreso", Monto: money.MustParse("10"), Fecha: fecha,
+			Transferencia: &models.EnlaceTransferencia{ID: primitive.NewObjectID(), Sentido: models.TransferenciaSalida},
+		}
+		require.NoError(t, s.Create(ctx, transaccion, models.ClientInfo{}))
+		assert.Nil(t, transaccion.Transferencia)
+
+		evt := startedEvent(t, mt, func(cmd bson.Raw) bool {
+			coleccion, _ := cmd.Lookup("insert").StringValueOK()
+			return coleccion == "transacciones"
+		})
+		require.NotNil(t, evt)
+		_, err := evt.Command.Lookup("documents").Array().Index(0).Value().Document().LookupErr("transferencia")
+		assert.Error(t, err)
+	})
+}
+
+func TestTransaccion_EditarTransferencia(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	ctx := context.Background()
+	fecha := time.Date(2024, 5, 3, 15, 0, 0, 0, time.UTC)
+	origen, destino := primitive.NewObjectID(), primitive.NewObjectID()
+	transferenciaID := primitive.NewObjectID()
+	tasa := money.MustParseRate("3.75")
+
+	pata := func(sentido string, cuenta primitive.ObjectID, moneda, monto string) bson.D {
+		doc := transaccionDoc(fecha, monto)
+		doc[2].Value = "transferencia"
+		return append(doc,
+			bson.E{Key: "moneda", Value: moneda},
+			bson.E{Key: "cuentaId", Value: cuenta},
+			bson.E{Key: "transferencia", Value: bson.D{
+				{Key: "id", Value: transferenciaID},
+				{Key: "sentido", Value: sentido},
+				{Key: "tasa", Value: tasa},
+			}},
+		)
+	}
+
+	mt.Run("editar la entrada recalcula la salida", func(mt *mtest.T) {
+		s := NewTransaccionService(mt.DB)
+		salida := pata(models.TransferenciaSalida, origen, "USD", "100")
+		entrada := pata(models.TransferenciaEntrada, destino, "PEN", "375")
+		mt.AddMockResponses(
+			mtest.CreateCursorResponse(0, "test.transacciones", mtest.FirstBatch, entrada),
+			mtest.CreateCursorResponse(0, "test.transacciones", mtest.FirstBatch, salida),
+			// prepare de la entrada, con cambio PEN→USD del día
+			propietarioResponse(t, "USD"),
+			mtest.CreateCursorResponse(0, "test.cuentas", mtest.FirstBatch, cuentaDoc(destino, "PEN", "0", false)),
+			mtest.CreateCursorResponse(0, "test.exchange_rates", mtest.FirstBatch,
+				exchangeRateDoc("PEN", "USD", time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC), "0.25")),
+			mtest.CreateCursorResponse(0, "test.exchange_rates", mtest.FirstBatch),
+			// prepare de la salida
+			propietarioResponse(t, "USD"),
+			mtest.CreateCursorResponse(0, "test.cuentas", mtest.FirstBatch, cuentaDoc(origen, "USD", "0", false)),
+			bson.D{{Key: "ok", Value: 1}, {Key: "n", Value: 1}, {Key: "nModified", Value: 1}},
+			bson.D{{Key: "ok", Value: 1}, {Key: "n", Value: 1}, {Key: "nModified", Value: 1}},
+			mtest.CreateSuccessResponse(), // commit
+			mtest.CreateSuccessResponse(),
+			mtest.CreateSuccessResponse(),
+		)
+
+		nuevaFecha := fecha.AddDate(0, 0, 1)
+		transaccion := &models.Transaccion{
+			ID:          entrada[0].Value.(primitive.ObjectID),
+			UsuarioID:   propietario,
+			Tipo:        "transferencia",
+			Monto:       money.MustParse("750"),
+			Fecha:       nuevaFecha,
+			Descripcion: "Ahorro",
+			CuentaID:    &destino,
+		}
+		require.NoError(t, s.Update(ctx, transaccion, models.ClientInfo{}))
+		assert.Equal(t, models.TransferenciaEntrada, transaccion.Transferencia.Sentido)
+
+		var updates []bson.Raw
+		for evt := mt.GetStartedEvent(); evt != nil; evt = mt.GetStartedEvent() {
+			if evt.CommandName == "update" {
+				updates = append(updates, evt.Command.Lookup("updates").Array().Index(0).Value().Document())
+			}
+		}
+		require.Len(t, updates, 2)
+		otra := updates[1]
+		assert.Equal(t, salida[0].Value, otra.Lookup("q", "_id").ObjectID())
+		assert.Equal(t, "200", otra.Lookup("u", "$set", "monto").Decimal128().String())
+		assert.Equal(t, "Ahorro", otra.Lookup("u", "$set", "descripcion").StringValue())
+		assert.Equal(t, nuevaFecha, otra.Lookup("u", "$set", "fecha").Time().UTC())
+	})
+
+	mt.Run("no se puede cambiar el tipo de una pata", func(mt *mtest.T) {
+		s := NewTransaccionService(mt.DB)
+		entrada := pata(models.TransferenciaEntrada, destino, "PEN", "375")
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "test.transacciones", mtest.FirstBatch, entrada))
+
+		err := s.Update(ctx, &models.Transaccion{
+			ID: entrada[0].Value.(primitive.ObjectID), UsuarioID: propietario, Tipo: "ingreso", Monto: money.MustParse("1"), Fecha: fecha, CuentaID: &destino,
+		}, models.ClientInfo{})
+		assert.ErrorIs(t, err, ErrInvalidTransferencia)
+	})
+
+	mt.Run("una transacción suelta no se convierte en pata", func(mt *mtest.T) {
+		s := NewTransaccionService(mt.DB)
+		existing := transaccionDoc(fecha, "40")
+		mt.AddMockResponses(
+			mtest.CreateCursorResponse(0, "test.transacciones", mtest.FirstBatch, existing),
+			propietarioResponse(t, "USD"),
+			bson.D{{Key: "ok", Value: 1}, {Key: "n", Value: 1}, {Key: "nModified", Value: 1}},
+			mtest.CreateSuccessResponse(),
+		)
+
+		transaccion := &models.Transaccion{
+			ID: existing[0].Value.(primitive.ObjectID), UsuarioID: propietario, Tipo: "egreso", Monto: money.MustParse("45"), Fecha: fecha,
+			Transferencia: &models.EnlaceTransferencia{ID: transferenciaID, Sentido: models.TransferenciaSalida},
+		}
+		require.NoError(t, s.Update(ctx, transaccion, models.ClientInfo{}))
+		assert.Nil(t, transaccion.Transferencia)
+
+		evt := startedEvent(t, mt, func(cmd bson.Raw) bool {
+			coleccion, _ := cmd.Lookup("update").StringValueOK()
+			return coleccion == "transacciones"
+		})
+		require.NotNil(t, evt)
+		_, err := evt.Command.Lookup("updates").Array().Index(0).Value().Document().LookupErr("u", "$set", "transferencia")
+		assert.Error(t, err)
+	})
+
+	mt.Run("eliminar una pata elimina la otra", func(mt *mtest.T) {
+		s := NewTransaccionService(mt.DB)
+		salida := pata(models.TransferenciaSalida, origen, "USD", "100")
+		entrada := pata(models.TransferenciaEntrada, destino, "PEN", "375")
+		mt.AddMockResponses(
+			mtest.CreateCursorResponse(0, "test.transacciones", mtest.FirstBatch, salida),
+			mtest.CreateCursorResponse(0, "test.transacciones", mtest.FirstBatch, entrada),
+			bson.D{{Key: "ok", Value: 1}, {Key: "n", Value: 1}},
+			bson.D{{Key: "ok", Value: 1}, {Key: "n", Value: 1}},
+			mtest.CreateSuccessResponse(), // commit
+			mtest.CreateSuccessResponse(),
+			mtest.CreateSuccessResponse(),
+		)
+
+		require.NoError(t, s.Delete(ctx, salida[0].Value.(primitive.ObjectID), propietario, models.ClientInfo{}))
+
+		var borradas []primitive.ObjectID
+		for evt := mt.GetStartedEvent(); evt != nil; evt = mt.GetStartedEvent() {
+			if evt.CommandName == "delete" {
+				borradas = append(borradas, evt.Command.Lookup("deletes").Array().Index(0).Value().Document().Lookup("q", "_id").ObjectID())
+			}
+		}
+		assert.Equal(t, []primitive.ObjectID{salida[0].Value.(primitive.ObjectID), entrada[0].Value.(primitive.ObjectID)}, borradas)
+	})
+}
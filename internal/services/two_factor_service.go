@@ -0,0 +1,164 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"control-financiero/internal/auth"
+	"control-financiero/internal/models"
+	"control-financiero/internal/repositories"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	// twoFactorIssuer es el nombre con el que aparece la cuenta en la app
+	// de autenticación.
+	twoFactorIssuer    = "Control Financiero"
+	recoveryCodesCount = 10
+)
+
+var (
+	ErrInvalidTwoFactorCode = errors.New("código de verificación inválido")
+	errTwoFactorEnabled     = errors.New("la verificación en dos pasos ya está activada")
+	errTwoFactorDisabled    = errors.New("la verificación en dos pasos no está activada")
+)
+
+type TwoFactorService struct {
+	userRepo *repositories.UsuarioRepository
+	audit    *AuditService
+}
+
+func NewTwoFactorService(db *mongo.Database) *TwoFactorService {
+	return &TwoFactorService{
+		userRepo: repositories.NewUsuarioRepository(db),
+		audit:    NewAuditService(db),
+	}
+}
+
+// Enroll genera un secreto nuevo pendiente de confirmar. Repetir la llamada
+// antes de confirmar reemplaza el secreto anterior.
+func (s *TwoFactorService) Enroll(ctx context.Context, usuarioID primitive.ObjectID) (*models.TwoFactorEnrollResponse, error) {
+	usuario, err := s.userRepo.FindByID(ctx, usuarioID)
+	if err != nil {
+		return nil, notFound(err)
+	}
+
+	if usuario.TwoFactorEnabled() {
+		return nil, errTwoFactorEnabled
+	}
+
+	secret, err := auth.GenerateTOTPSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	started, err := s.userRepo.StartTwoFactor(ctx, usuarioID, secret)
+	if err != nil {
+		return nil, err
+	}
+	if !started {
+		return nil, errTwoFactorEnabled
+	}
+
+	return &models.TwoFactorEnrollResponse{
+		Secret:     secret,
+		OTPAuthURI: auth.TOTPURI(twoFactorIssuer, usuario.Email, secret),
+	}, nil
+}
+
+// Confirm activa el 2FA con el primer código de la app y devuelve los códigos
+// de recuperación. Solo se muestran esta vez; se guardan como hash.
+func (s *TwoFactorService) Confirm(ctx context.Context, usuarioID primitive.ObjectID, code string, client models.ClientInfo) (*models.RecoveryCodesResponse, error) {
+	usuario, err := s.userRepo.FindByID(ctx, usuarioID)
+	if err != nil {
+		return nil, notFound(err)
+	}
+
+	if usuario.TwoFactor == nil {
+		return nil, errors.New("no hay una inscripción de verificación en dos pasos pendiente")
+	}
+	if usuario.TwoFactor.Enabled {
+		return nil, errTwoFactorEnabled
+	}
+
+	step, ok := auth.ValidateTOTP(usuario.TwoFactor.Secret, code, time.Now())
+	if !ok {
+		return nil, ErrInvalidTwoFactorCode
+	}
+
+	codes, err := auth.GenerateRecoveryCodes(recoveryCodesCount)
+	if err != nil {
+		return nil, err
+	}
+
+	hashes := make([]string, len(codes))
+	for i, c := range codes {
+		hashes[i] = auth.HashRefreshToken(c)
+	}
+
+	enabled, err := s.userRepo.EnableTwoFactor(ctx, usuarioID, step, hashes)
+	if err != nil {
+		return nil, err
+	}
+	if !enabled {
+		return nil, ErrInvalidTwoFactorCode
+	}
+
+	s.audit.Record(ctx, client, &models.AuditLog{Accion: models.AuditTwoFactorActivar, Recurso: "usuario", RecursoID: objectIDPtr(usuarioID)})
+	return &models.RecoveryCodesResponse{RecoveryCodes: codes}, nil
+}
+
+// Disable desactiva el 2FA; exige un código válido para que una sesión
+// robada no pueda quitarlo.
+func (s *TwoFactorService) Disable(ctx context.Context, usuarioID primitive.ObjectID, code string, client models.ClientInfo) error {
+	usuario, err := s.userRepo.FindByID(ctx, usuarioID)
+	if err != nil {
+		return notFound(err)
+	}
+
+	if !usuario.TwoFactorEnabled() {
+		return errTwoFactorDisabled
+	}
+
+	if err := s.Verify(ctx, usuario, code); err != nil {
+		return err
+	}
+
+	if err := s.userRepo.ResetTwoFactor(ctx, usuarioID); err != nil {
+		return err
+	}
+
+	s.audit.Record(ctx, client, &models.AuditLog{Accion: models.AuditTwoFactorDesactivar, Recurso: "usuario", RecursoID: objectIDPtr(usuarioID)})
+	return nil
+}
+
+// Verify acepta un código TOTP o, en su defecto, un código de recuperación.
+// Ambos se consumen de forma atómica para que no puedan usarse dos veces.
+func (s *TwoFactorService) Verify(ctx context.Context, usuario *models.Usuario, code string) error {
+	if !usuario.TwoFactorEnabled() {
+		return errTwoFactorDisabled
+	}
+
+	if step, ok := auth.ValidateTOTP(usuario.TwoFactor.Secret, code, time.Now()); ok {
+		used, err := s.userRepo.UseTOTPStep(ctx, usuario.ID, step)
+		if err != nil {
+			return err
+		}
+		if used {
+			return nil
+		}
+		return ErrInvalidTwoFactorCode
+	}
+
+	used, err := s.userRepo.UseRecoveryCode(ctx, usuario.ID, auth.HashRefreshToken(auth.NormalizeRecoveryCode(code)))
+	if err != nil {
+		return err
+	}
+	if !used {
+		return ErrInvalidTwoFactorCode
+	}
+	return nil
+}
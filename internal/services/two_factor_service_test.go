@@ -0,0 +1,108 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"control-financiero/internal/auth"
+	"control-financiero/internal/config"
+	"control-financiero/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// usuarioActivo devuelve un usuario con la contraseña "password123".
+func usuarioActivo(t *testing.T) *models.Usuario {
+	hash, err := bcrypt.GenerateFromPassword([]byte("password123"), bcrypt.MinCost)
+	require.NoError(t, err)
+
+	return &models.Usuario{
+		ID:           primitive.NewObjectID(),
+		Nombre:       "Ana",
+		Email:        "ana@example.com",
+		PasswordHash: string(hash),
+		Rol:          "user",
+		Estado:       "active",
+	}
+}
+
+func usuarioConTwoFactor(t *testing.T, secret string) *models.Usuario {
+	usuario := usuarioActivo(t)
+	usuario.TwoFactor = &models.TwoFactor{Secret: secret, Enabled: true}
+	return usuario
+}
+
+func TestTwoFactor_Verify(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	ctx := context.Background()
+
+	secret, err := auth.GenerateTOTPSecret()
+	require.NoError(t, err)
+
+	mt.Run("codigo reutilizado", func(mt *mtest.T) {
+		s := NewTwoFactorService(mt.DB)
+		usuario := usuarioConTwoFactor(t, secret)
+		code, err := auth.GenerateTOTP(secret, time.Now())
+		require.NoError(t, err)
+
+		// El servidor no modifica nada porque el paso ya fue usado
+		mt.AddMockResponses(bson.D{{Key: "ok", Value: 1}, {Key: "n", Value: 0}, {Key: "nModified", Value: 0}})
+
+		err = s.Verify(ctx, usuario, code)
+		assert.ErrorIs(t, err, ErrInvalidTwoFactorCode)
+
+		filter := sentFilter(t, mt)
+		assert.Equal(t, usuario.ID, filter.Lookup("_id").ObjectID())
+		assert.NotNil(t, filter.Lookup("twoFactor.lastStep", "$lt").Value)
+	})
+
+	mt.Run("codigo de recuperacion", func(mt *mtest.T) {
+		s := NewTwoFactorService(mt.DB)
+		usuario := usuarioConTwoFactor(t, secret)
+		mt.AddMockResponses(bson.D{{Key: "ok", Value: 1}, {Key: "n", Value: 1}, {Key: "nModified", Value: 1}})
+
+		err := s.Verify(ctx, usuario, "ABCDE-FGHIJ")
+		assert.NoError(t, err)
+
+		filter := sentFilter(t, mt)
+		assert.Equal(t, auth.HashRefreshToken("abcde-fghij"), filter.Lookup("twoFactor.recoveryCodes").StringValue())
+	})
+}
+
+func TestLogin_ConTwoFactorDevuelveChallenge(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	ctx := context.Background()
+
+	mt.Run("challenge", func(mt *mtest.T) {
+		s := NewAuthService(mt.DB, &config.Config{JWTSecret: "secret", JWTExpiration: "15m", RefreshExpiration: "1h"})
+		usuario := usuarioConTwoFactor(t, "JBSWY3DPEHPK3PXP")
+
+		mt.AddMockResponses(
+			mtest.CreateCursorResponse(0, "test.login_attempts", mtest.FirstBatch),
+			mtest.CreateCursorResponse(0, "test.usuarios", mtest.FirstBatch, bsonDoc(t, usuario)),
+			mtest.CreateSuccessResponse(),
+		)
+
+		response, challenge, err := s.Login(ctx, &models.LoginRequest{Email: usuario.Email, Password: "password123"}, models.ClientInfo{})
+		require.NoError(t, err)
+		assert.Nil(t, response)
+		require.NotNil(t, challenge)
+		assert.True(t, challenge.TwoFactorRequired)
+		assert.NotEmpty(t, challenge.ChallengeToken)
+
+		// Solo se guarda el hash del challenge
+		evt := mt.GetStartedEvent()
+		for next := mt.GetStartedEvent(); next != nil; next = mt.GetStartedEvent() {
+			evt = next
+		}
+		require.Equal(t, "insert", evt.CommandName)
+		doc := evt.Command.Lookup("documents").Array().Index(0).Value().Document()
+		assert.Equal(t, auth.HashRefreshToken(challenge.ChallengeToken), doc.Lookup("tokenHash").StringValue())
+	})
+}
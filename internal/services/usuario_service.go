@@ -1,89 +1,181 @@
-package services
-
-import (
-	"context"
-	"errors"
-
-	"control-financiero/internal/models"
-	"control-financiero/internal/repositories"
-
-	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo"
-)
-
-type UsuarioService struct {
-	userRepo *repositories.UsuarioRepository
-}
-
-func NewUsuarioService(db *mongo.Database) *UsuarioService {
-	return &UsuarioService{
-		userRepo: repositories.NewUsuarioRepository(db),
-	}
-}
-
-func (s *UsuarioService) GetAll(ctx context.Context) ([]*models.Usuario, error) {
-	usuarios, err := s.userRepo.FindAll(ctx)
-	if err != nil {
-		return nil, err
-	}
-
-	// Ocultar password hash
-	for _, u := range usuarios {
-		u.PasswordHash = ""
-	}
-
-	return usuarios, nil
-}
-
-func (s *UsuarioService) GetByID(ctx context.Context, id primitive.ObjectID) (*models.Usuario, error) {
-	usuario, err := s.userRepo.FindByID(ctx, id)
-	if err != nil {
-		return nil, err
-	}
-
-	usuario.PasswordHash = ""
-	return usuario, nil
-}
-
-func (s *UsuarioService) Update(ctx context.Context, usuario *models.Usuario) error {
-	existing, err := s.userRepo.FindByID(ctx, usuario.ID)
-	if err != nil {
-		return err
-	}
-
-	// Solo permitir actualizar ciertos campos
-	existing.Nombre = usuario.Nombre
-	existing.Foto = usuario.Foto
-
-	return s.userRepo.Update(ctx, existing)
-}
-
-func (s *UsuarioService) Approve(ctx context.Context, id primitive.ObjectID) error {
-	return s.userRepo.UpdateEstado(ctx, id, "active")
-}
-
-func (s *UsuarioService) Activate(ctx context.Context, id primitive.ObjectID) error {
-	return s.userRepo.UpdateEstado(ctx, id, "active")
-}
-
-func (s *UsuarioService) Deactivate(ctx context.Context, id primitive.ObjectID) error {
-	return s.userRepo.UpdateEstado(ctx, id, "suspended")
-}
-
-func (s *UsuarioService) ChangeRole(ctx context.Context, id primitive.ObjectID, rol string) error {
-	if rol != "admin" && rol != "user" {
-		return errors.New("rol inválido")
-	}
-
-	usuario, err := s.userRepo.FindByID(ctx, id)
-	if err != nil {
-		return err
-	}
-
-	usuario.Rol = rol
-	return s.userRepo.Update(ctx, usuario)
-}
-
-func (s *UsuarioService) Delete(ctx context.Context, id primitive.ObjectID) error {
-	return s.userRepo.Delete(ctx, id)
-}
+package services
+
+import (
+	"context"
+	"errors"
+
+	"control-financiero/internal/models"
+	"control-financiero/internal/repositories"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type UsuarioService struct {
+	userRepo         *repositories.UsuarioRepository
+	refreshTokenRepo *repositories.RefreshTokenRepository
+	rolRepo          *repositories.RolRepository
+	audit            *AuditService
+}
+
+func NewUsuarioService(db *mongo.Database) *UsuarioService {
+	return &UsuarioService{
+		userRepo:         repositories.NewUsuarioRepository(db),
+		refreshTokenRepo: repositories.NewRefreshTokenRepository(db),
+		rolRepo:          repositories.NewRolRepository(db),
+		audit:            NewAuditService(db),
+	}
+}
+
+func (s *UsuarioService) GetAll(ctx context.Context) ([]*models.Usuario, error) {
+	usuarios, err := s.userRepo.FindAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Ocultar password hash
+	for _, u := range usuarios {
+		u.PasswordHash = ""
+	}
+
+	return usuarios, nil
+}
+
+func (s *UsuarioService) GetByID(ctx context.Context, id primitive.ObjectID) (*models.Usuario, error) {
+	usuario, err := s.userRepo.FindByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	usuario.PasswordHash = ""
+	return usuario, nil
+}
+
+func (s *UsuarioService) Update(ctx context.Context, usuario *models.Usuario, client models.ClientInfo) error {
+	existing, err := s.userRepo.FindByID(ctx, usuario.ID)
+	if err != nil {
+		return err
+	}
+	before := *existing
+
+	// Solo permitir actualizar ciertos campos
+	existing.Nombre = usuario.Nombre
+	existing.Foto = usuario.Foto
+	if usuario.MonedaBase != "" {
+		// Los reportes se recalculan en la nueva moneda; las transacciones
+		// guardadas conservan el monto base con el que se registraron
+		if existing.MonedaBase, err = normalizeMoneda(usuario.MonedaBase); err != nil {
+			return err
+		}
+	}
+
+	if err := s.userRepo.Update(ctx, existing); err != nil {
+		return err
+	}
+
+	s.audit.Record(ctx, client, s.auditEntry(models.AuditPerfilEditar, existing.ID, auditDiff(&before, existing)))
+	return nil
+}
+
+func (s *UsuarioService) Approve(ctx context.Context, id primitive.ObjectID, client models.ClientInfo) error {
+	return s.changeEstado(ctx, id, "active", models.AuditUsuarioAprobar, client)
+}
+
+func (s *UsuarioService) Activate(ctx context.Context, id primitive.ObjectID, client models.ClientInfo) error {
+	return s.changeEstado(ctx, id, "active", models.AuditUsuarioActivar, client)
+}
+
+func (s *UsuarioService) Deactivate(ctx context.Context, id primitive.ObjectID, client models.ClientInfo) error {
+	if err := s.changeEstado(ctx, id, "suspended", models.AuditUsuarioSuspender, client); err != nil {
+		return err
+	}
+
+	// Un usuario suspendido no debe poder renovar su sesión
+	return s.refreshTokenRepo.RevokeAllByUsuario(ctx, id)
+}
+
+func (s *UsuarioService) changeEstado(ctx context.Context, id primitive.ObjectID, estado, accion string, client models.ClientInfo) error {
+	usuario, err := s.userRepo.FindByID(ctx, id)
+	if err != nil {
+		return notFound(err)
+	}
+
+	if err := s.userRepo.UpdateEstado(ctx, id, estado); err != nil {
+		return err
+	}
+
+	s.audit.Record(ctx, client, s.auditEntry(accion, id, bson.M{
+		"antes":   bson.M{"estado": usuario.Estado},
+		"despues": bson.M{"estado": estado},
+	}))
+	return nil
+}
+
+func (s *UsuarioService) ChangeRole(ctx context.Context, id primitive.ObjectID, rol string, client models.ClientInfo) error {
+	if _, err := s.rolRepo.FindByNombre(ctx, rol); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return errors.New("rol inválido")
+		}
+		return err
+	}
+
+	usuario, err := s.userRepo.FindByID(ctx, id)
+	if err != nil {
+		return notFound(err)
+	}
+	anterior := usuario.Rol
+
+	usuario.Rol = rol
+	if err := s.userRepo.Update(ctx, usuario); err != nil {
+		return err
+	}
+
+	s.audit.Record(ctx, client, s.auditEntry(models.AuditUsuarioCambiarRol, id, bson.M{
+		"antes":   bson.M{"rol": anterior},
+		"despues": bson.M{"rol": rol},
+	}))
+
+	// Forzar un nuevo login para que los tokens reflejen el nuevo rol
+	return s.refreshTokenRepo.RevokeAllByUsuario(ctx, id)
+}
+
+// ResetTwoFactor quita el 2FA de un usuario que perdió su dispositivo y sus
+// códigos de recuperación.
+func (s *UsuarioService) ResetTwoFactor(ctx context.Context, id primitive.ObjectID, client models.ClientInfo) error {
+	if err := s.userRepo.ResetTwoFactor(ctx, id); err != nil {
+		return notFound(err)
+	}
+
+	s.audit.Record(ctx, client, s.auditEntry(models.AuditUsuarioRestablecer2FA, id, nil))
+	return nil
+}
+
+func (s *UsuarioService) Delete(ctx context.Context, id primitive.ObjectID, client models.ClientInfo) error {
+	usuario, err := s.userRepo.FindByID(ctx, id)
+	if err != nil {
+		return notFound(err)
+	}
+
+	if err := s.userRepo.Delete(ctx, id); err != nil {
+		return err
+	}
+
+	s.audit.Record(ctx, client, s.auditEntry(models.AuditUsuarioEliminar, id, auditDiff(usuario, nil)))
+	return nil
+}
+
+// auditEntry registra una acción sobre la cuenta id, que queda como afectado
+// aunque la haga un administrador.
+func (s *UsuarioService) auditEntry(accion string, id primitive.ObjectID, detalle bson.M) *models.AuditLog {
+	entry := &models.AuditLog{
+		Accion:     accion,
+		Recurso:    "usuario",
+		RecursoID:  objectIDPtr(id),
+		AfectadoID: objectIDPtr(id),
+	}
+	if detalle != nil {
+		entry.Detalle = detalle
+	}
+	return entry
+}
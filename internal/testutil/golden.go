@@ -0,0 +1,41 @@
+// Package testutil reúne ayudas compartidas por las pruebas de varios
+// paquetes.
+package testutil
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Con -update se reescriben los .golden con el resultado actual, por ejemplo:
+//
+//	go test ./internal/ofx -update
+var update = flag.Bool("update", false, "reescribe los archivos .golden")
+
+// Golden compara v, en JSON indentado, con el archivo .golden que acompaña al
+// archivo de prueba archivo: el mismo nombre con la extensión .golden.
+func Golden(t *testing.T, archivo string, v interface{}) {
+	t.Helper()
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	require.NoError(t, enc.Encode(v))
+	got := buf.Bytes()
+
+	golden := strings.TrimSuffix(archivo, filepath.Ext(archivo)) + ".golden"
+	if *update {
+		require.NoError(t, os.WriteFile(golden, got, 0o644))
+	}
+	want, err := os.ReadFile(golden)
+	require.NoError(t, err)
+	assert.Equal(t, string(want), string(got))
+}
@@ -80,7 +80,9 @@ const api = {
                 skipAuth: true,
                 body: JSON.stringify({ refreshToken })
             });
+            if (!data.accessToken) return false;
             localStorage.setItem(TOKEN_KEY, data.accessToken);
+            localStorage.setItem(REFRESH_TOKEN_KEY, data.refreshToken);
             updateTokenExpiry();
             return true;
         } catch (error) {
@@ -89,11 +91,17 @@ const api = {
     },
     login: (data) => api.request('/auth/login', { method: 'POST', skipAuth: true, body: JSON.stringify(data) }),
     register: (data) => api.request('/auth/register', { method: 'POST', skipAuth: true, body: JSON.stringify(data) }),
+    forgotPassword: (email) => api.request('/auth/forgot-password', { method: 'POST', skipAuth: true, body: JSON.stringify({ email }) }),
+    resetPassword: (data) => api.request('/auth/reset-password', { method: 'POST', skipAuth: true, body: JSON.stringify(data) }),
+    loginTwoFactor: (data) => api.request('/auth/login/2fa', { method: 'POST', skipAuth: true, body: JSON.stringify(data) }),
+    exchangeCode: (code) => api.request('/auth/exchange', { method: 'POST', skipAuth: true, body: JSON.stringify({ code }) }),
+    getProviders: () => api.request('/auth/providers', { skipAuth: true }),
+    logout: (refreshToken) => api.request('/auth/logout', { method: 'POST', skipAuth: true, body: JSON.stringify({ refreshToken }) }),
     getProfile: () => api.request('/perfil'),
     updateProfile: (data) => api.request('/perfil', { method: 'PUT', body: JSON.stringify(data) }),
     getCategories: () => api.request('/categorias'),
     createCategory: (data) => api.request('/categorias', { method: 'POST', body: JSON.stringify(data) }),
-    getTransactions: () => api.request('/transacciones'),
+    getTransactions: (limite = 10) => api.request(`/transacciones?limite=${limite}`),
     createTransaction: (data) => api.request('/transacciones', { method: 'POST', body: JSON.stringify(data) }),
     deleteTransaction: (id) => api.request(`/transacciones/${id}`, { method: 'DELETE' }),
     getStatistics: (year, month) => api.request(`/reportes/estadisticas?year=${year}&month=${month}`),
@@ -183,6 +191,10 @@ function showDashboard() {
 }
 
 function logout() {
+    const refreshToken = localStorage.getItem(REFRESH_TOKEN_KEY);
+    if (refreshToken) {
+        api.logout(refreshToken).catch(() => {});
+    }
     localStorage.clear();
     currentUser = null;
     if (sessionTimer) clearInterval(sessionTimer);
@@ -215,10 +227,14 @@ function initLoginEvents() {
     $('#loginForm').addEventListener('submit', async (e) => {
         e.preventDefault();
         try {
-            const data = await api.login({
+            const data = await completeTwoFactor(await api.login({
                 email: $('#loginEmail').value,
                 password: $('#loginPassword').value
-            });
+            }));
+            if (!data.accessToken) {
+                if (data.error) showNotification(data.error, 'error');
+                return false;
+            }
             localStorage.setItem(TOKEN_KEY, data.accessToken);
             localStorage.setItem(REFRESH_TOKEN_KEY, data.refreshToken);
             currentUser = data.usuario;
@@ -244,20 +260,59 @@ function initLoginEvents() {
                 email: $('#registerEmail').value,
                 password: password
             });
-            showNotification('Registro exitoso. Espera aprobación del admin.', 'success');
+            showNotification('Registro exitoso. Revisa tu correo para verificarlo y espera la aprobación del admin.', 'success');
         } catch (error) {
             showNotification('Error al registrarse', 'error');
         }
     });
+    $('#forgotPasswordLink')?.addEventListener('click', async (e) => {
+        e.preventDefault();
+        const email = window.prompt('Correo de tu cuenta:', $('#loginEmail').value);
+        if (!email) return;
+        const data = await api.forgotPassword(email.trim());
+        showNotification(data.mensaje || data.error, data.error ? 'error' : 'success');
+    });
     $('#googleLoginBtn').addEventListener('click', () => {
         window.location.href = `${API_BASE_URL}/auth/google`;
     });
+    renderProviders();
     $('#logoutLink').addEventListener('click', (e) => {
         e.preventDefault();
         logout();
     });
 }
 
+// Si el usuario tiene verificación en dos pasos, pide el código de la app
+// (o un código de recuperación) y completa el login
+async function completeTwoFactor(data) {
+    if (!data.twoFactorRequired) return data;
+    const code = window.prompt('Código de verificación (app de autenticación o código de recuperación):');
+    if (!code) return { error: 'Login cancelado' };
+    return api.loginTwoFactor({ challengeToken: data.challengeToken, code: code.trim() });
+}
+
+// Botones para los proveedores OIDC configurados además de Google
+async function renderProviders() {
+    const container = $('#oidcProviders');
+    if (!container) return;
+    try {
+        const providers = await api.getProviders();
+        if (!Array.isArray(providers)) return;
+        $('#googleLoginBtn').style.display = providers.some(p => p.name === 'google') ? '' : 'none';
+        providers.filter(p => p.name !== 'google').forEach(p => {
+            const btn = document.createElement('button');
+            btn.className = 'btn btn-google btn-block';
+            btn.textContent = `Continuar con ${p.displayName}`;
+            btn.addEventListener('click', () => {
+                window.location.href = `${API_BASE_URL}/auth/${encodeURIComponent(p.name)}`;
+            });
+            container.appendChild(btn);
+        });
+    } catch (error) {
+        console.error('No se pudieron cargar los proveedores', error);
+    }
+}
+
 // ===== DASHBOARD =====
 async function loadDashboardData() {
     try {
@@ -268,11 +323,11 @@ async function loadDashboardData() {
             api.getStatistics(now.getFullYear(), now.getMonth() + 1)
         ]);
         $('#userName').textContent = currentUser?.nombre || 'Usuario';
-        $('#ingresosTotal').textContent = formatCurrency(stats.totalIngresos || 0);
-        $('#egresosTotal').textContent = formatCurrency(stats.totalEgresos || 0);
-        $('#balanceTotal').textContent = formatCurrency((stats.totalIngresos || 0) - (stats.totalEgresos || 0));
+        $('#ingresosTotal').textContent = formatCurrency(stats.totalIngresos || 0, stats.moneda);
+        $('#egresosTotal').textContent = formatCurrency(stats.totalEgresos || 0, stats.moneda);
+        $('#balanceTotal').textContent = formatCurrency((stats.totalIngresos || 0) - (stats.totalEgresos || 0), stats.moneda);
         updateCharts(stats);
-        displayRecentTransactions(transactions.slice(0, 10));
+        displayRecentTransactions(transactions.transacciones);
     } catch (error) {
         console.error('Error:', error);
     }
@@ -336,15 +391,35 @@ function initNavigation() {
 }
 
 // ===== INICIALIZACIÓN =====
-document.addEventListener('DOMContentLoaded', () => {
-    const urlParams = new URLSearchParams(window.location.search);
-    const token = urlParams.get('token');
-    const refreshToken = urlParams.get('refreshToken');
-    if (token && refreshToken) {
-        localStorage.setItem(TOKEN_KEY, token);
-        localStorage.setItem(REFRESH_TOKEN_KEY, refreshToken);
-        updateTokenExpiry();
+document.addEventListener('DOMContentLoaded', async () => {
+    // Login con un proveedor OIDC: el callback deja un código de un solo uso en el fragmento
+    const hashParams = new URLSearchParams(window.location.hash.slice(1));
+    const code = hashParams.get('code');
+    const resetToken = hashParams.get('reset');
+    if (resetToken) {
         window.history.replaceState({}, document.title, window.location.pathname);
+        const newPassword = window.prompt('Nueva contraseña (mínimo 6 caracteres):');
+        if (newPassword) {
+            const data = await api.resetPassword({ token: resetToken, newPassword });
+            showNotification(data.mensaje || data.error, data.error ? 'error' : 'success');
+        }
+    }
+    const queryParams = new URLSearchParams(window.location.search);
+    if (queryParams.get('email_verificado')) {
+        window.history.replaceState({}, document.title, window.location.pathname);
+        showNotification('Correo verificado. Un administrador aprobará tu cuenta.', 'success');
+    }
+    if (code) {
+        window.history.replaceState({}, document.title, window.location.pathname);
+        const data = await completeTwoFactor(await api.exchangeCode(code));
+        if (data.accessToken) {
+            localStorage.setItem(TOKEN_KEY, data.accessToken);
+            localStorage.setItem(REFRESH_TOKEN_KEY, data.refreshToken);
+            localStorage.setItem(USER_KEY, JSON.stringify(data.usuario));
+            updateTokenExpiry();
+        } else {
+            showNotification(data.error || 'No se pudo completar el login', 'error');
+        }
     }
     initLoginEvents();
     initNavigation();